@@ -1,5 +1,50 @@
 #!/bin/bash
+# Copyright (c) 2021 Red Hat, Inc.
+# Copyright Contributors to the Open Cluster Management project
+
+# Runs the e2e scenarios in a local kind cluster.
+#
+# DOCKER_IMAGE_AND_TAG  image to test. When empty the image is built from the working tree
+# KIND_CLUSTER_NAME     name of the kind cluster (default: management-ingress-e2e)
+# KEEP_CLUSTER          do not delete the cluster at the end when set to "true"
+
 set -e
 
-export DOCKER_IMAGE_AND_TAG=${1}
-# make docker/run
\ No newline at end of file
+_script_dir=$(dirname "$0")
+_root_dir=$(cd "${_script_dir}/.." && pwd)
+
+export DOCKER_IMAGE_AND_TAG=${1:-${DOCKER_IMAGE_AND_TAG}}
+export KIND_CLUSTER_NAME=${KIND_CLUSTER_NAME:-management-ingress-e2e}
+export KUBECONFIG=${KUBECONFIG:-$(mktemp)}
+
+cleanup() {
+  if [[ "${KEEP_CLUSTER}" != "true" ]]; then
+    kind delete cluster --name "${KIND_CLUSTER_NAME}"
+  fi
+}
+trap cleanup EXIT
+
+if ! kind get clusters | grep -q "^${KIND_CLUSTER_NAME}$"; then
+  kind create cluster --name "${KIND_CLUSTER_NAME}" --config "${_root_dir}/test/e2e/kind.yaml" --kubeconfig "${KUBECONFIG}"
+fi
+
+if [[ -z "${DOCKER_IMAGE_AND_TAG}" ]]; then
+  DOCKER_IMAGE_AND_TAG=management-ingress:e2e
+  docker build -t "${DOCKER_IMAGE_AND_TAG}" "${_root_dir}"
+else
+  docker tag "${DOCKER_IMAGE_AND_TAG}" management-ingress:e2e
+fi
+kind load docker-image management-ingress:e2e --name "${KIND_CLUSTER_NAME}"
+
+kubectl apply -f "${_root_dir}/test/e2e/manifests/controller.yaml"
+
+# default certificate used by the catch-all server
+_tmp_dir=$(mktemp -d)
+openssl req -x509 -nodes -days 1 -newkey rsa:2048 -subj "/CN=e2e.local" \
+  -keyout "${_tmp_dir}/tls.key" -out "${_tmp_dir}/tls.crt" 2>/dev/null
+kubectl -n management-ingress-e2e create secret tls default-cert \
+  --cert "${_tmp_dir}/tls.crt" --key "${_tmp_dir}/tls.key" --dry-run=client -o yaml | kubectl apply -f -
+
+kubectl -n management-ingress-e2e rollout status deployment/management-ingress --timeout=300s
+
+go test -v -tags e2e -timeout 30m "${_root_dir}/test/e2e/..."
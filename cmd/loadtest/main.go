@@ -0,0 +1,260 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// loadtest drives synthetic Ingress and Service churn against a running
+// management-ingress controller and reports the reload frequency, the
+// configuration render latency and the error rate observed in the data plane.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+)
+
+const (
+	loadTestLabel = "management-ingress-loadtest"
+)
+
+func main() {
+	var (
+		flags = pflag.NewFlagSet("", pflag.ExitOnError)
+
+		kubeConfigFile = flags.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information.")
+		namespace      = flags.String("namespace", apiv1.NamespaceDefault, "Namespace where the synthetic objects are created.")
+		ingresses      = flags.Int("ingresses", 10, "Number of synthetic Ingress objects to create.")
+		iterations     = flags.Int("iterations", 20, "Number of churn iterations. Each iteration updates every synthetic Ingress.")
+		interval       = flags.Duration("interval", 5*time.Second, "Time to wait between churn iterations.")
+		metricsURL     = flags.String("metrics-url", "http://127.0.0.1:10254/metrics", "URL of the controller metrics endpoint.")
+		targetURL      = flags.String("target-url", "", "URL used to probe the data plane during the test. Disabled if empty.")
+		probeRate      = flags.Duration("probe-interval", 100*time.Millisecond, "Time between data plane probes.")
+		keep           = flags.Bool("keep", false, "Do not remove the synthetic objects at the end of the test.")
+	)
+
+	flags.AddGoFlagSet(flag.CommandLine)
+	if err := flags.Parse(os.Args); err != nil {
+		glog.Fatal(err)
+	}
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeConfigFile},
+		&clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		glog.Fatalf("unexpected error reading kubeconfig: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		glog.Fatalf("unexpected error creating kubernetes client: %v", err)
+	}
+
+	before, err := scrape(*metricsURL)
+	if err != nil {
+		glog.Fatalf("unexpected error reading metrics: %v", err)
+	}
+
+	probe := &probeResult{}
+	stopCh := make(chan struct{})
+	probeDone := make(chan struct{})
+	if *targetURL != "" {
+		go func() {
+			runProbe(*targetURL, *probeRate, probe, stopCh)
+			close(probeDone)
+		}()
+	} else {
+		close(probeDone)
+	}
+
+	start := time.Now()
+	if err := createObjects(client, *namespace, *ingresses); err != nil {
+		glog.Fatalf("unexpected error creating synthetic objects: %v", err)
+	}
+
+	for i := 0; i < *iterations; i++ {
+		time.Sleep(*interval)
+		if err := churn(client, *namespace, *ingresses, i); err != nil {
+			glog.Errorf("unexpected error updating synthetic objects: %v", err)
+		}
+	}
+
+	// wait for the last changes to be applied
+	time.Sleep(*interval)
+	close(stopCh)
+	<-probeDone
+	elapsed := time.Since(start)
+
+	after, err := scrape(*metricsURL)
+	if err != nil {
+		glog.Fatalf("unexpected error reading metrics: %v", err)
+	}
+
+	if !*keep {
+		deleteObjects(client, *namespace)
+	}
+
+	report(before, after, probe, elapsed)
+}
+
+func createObjects(client kubernetes.Interface, ns string, count int) error {
+	pathType := networking.PathTypePrefix
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%v-%v", loadTestLabel, i)
+
+		_, err := client.CoreV1().Services(ns).Create(context.TODO(), &apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"app": loadTestLabel},
+			},
+			Spec: apiv1.ServiceSpec{
+				Ports: []apiv1.ServicePort{{Port: 8080, TargetPort: intstr.FromInt(8080)}},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+
+		_, err = client.NetworkingV1().Ingresses(ns).Create(context.TODO(), &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"app": loadTestLabel},
+				Annotations: map[string]string{
+					class.IngressKey: class.DefaultClass,
+				},
+			},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{{
+								Path:     fmt.Sprintf("/%v", name),
+								PathType: &pathType,
+								Backend: networking.IngressBackend{
+									Service: &networking.IngressServiceBackend{
+										Name: name,
+										Port: networking.ServiceBackendPort{Number: 8080},
+									},
+								},
+							}},
+						},
+					},
+				}},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// churn changes the path of every synthetic Ingress to force a new
+// configuration to be rendered and reloaded
+func churn(client kubernetes.Interface, ns string, count, iteration int) error {
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%v-%v", loadTestLabel, i)
+		ing, err := client.NetworkingV1().Ingresses(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		ing.Spec.Rules[0].HTTP.Paths[0].Path = fmt.Sprintf("/%v/%v", name, iteration)
+		if _, err := client.NetworkingV1().Ingresses(ns).Update(context.TODO(), ing, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteObjects(client kubernetes.Interface, ns string) {
+	opts := metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%v", loadTestLabel)}
+
+	err := client.NetworkingV1().Ingresses(ns).DeleteCollection(context.TODO(), metav1.DeleteOptions{}, opts)
+	if err != nil {
+		glog.Warningf("unexpected error removing synthetic ingresses: %v", err)
+	}
+
+	svcs, err := client.CoreV1().Services(ns).List(context.TODO(), opts)
+	if err != nil {
+		glog.Warningf("unexpected error listing synthetic services: %v", err)
+		return
+	}
+	for _, svc := range svcs.Items {
+		if err := client.CoreV1().Services(ns).Delete(context.TODO(), svc.Name, metav1.DeleteOptions{}); err != nil {
+			glog.Warningf("unexpected error removing service %v: %v", svc.Name, err)
+		}
+	}
+}
+
+type probeResult struct {
+	requests int
+	errors   int
+}
+
+// runProbe sends requests to the data plane until stopCh is closed.
+// Connection errors and 5xx responses are counted as errors.
+func runProbe(url string, interval time.Duration, result *probeResult, stopCh chan struct{}) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			// #nosec
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			result.requests++
+			resp, err := client.Get(url)
+			if err != nil {
+				result.errors++
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				result.errors++
+			}
+		}
+	}
+}
+
+func report(before, after *snapshot, probe *probeResult, elapsed time.Duration) {
+	reloads := after.reloads - before.reloads
+	reloadErrors := after.reloadErrors - before.reloadErrors
+	renders := after.renderCount - before.renderCount
+
+	fmt.Printf("duration:             %v\n", elapsed.Round(time.Second))
+	fmt.Printf("reloads:              %v (%.2f/min)\n", reloads, reloads/elapsed.Minutes())
+	fmt.Printf("reload errors:        %v\n", reloadErrors)
+	if renders > 0 {
+		latency := time.Duration((after.renderSum - before.renderSum) / renders * float64(time.Second))
+		fmt.Printf("render latency (avg): %v\n", latency)
+	}
+	if probe.requests > 0 {
+		fmt.Printf("data plane errors:    %v/%v (%.2f%%)\n", probe.errors, probe.requests,
+			float64(probe.errors)*100/float64(probe.requests))
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// snapshot contains the value of the controller metrics used in the report
+type snapshot struct {
+	reloads      float64
+	reloadErrors float64
+	renderCount  float64
+	renderSum    float64
+}
+
+func scrape(url string) (*snapshot, error) {
+	// #nosec
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v reading %v", resp.StatusCode, url)
+	}
+
+	return parseMetrics(resp.Body)
+}
+
+func parseMetrics(r io.Reader) (*snapshot, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{}
+	if mf, ok := families[metric.PrometheusNamespace+"_config_reloads_total"]; ok {
+		for _, m := range mf.GetMetric() {
+			switch labelValue(m, "result") {
+			case "success":
+				s.reloads = m.GetCounter().GetValue()
+			case "error":
+				s.reloadErrors = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	if mf, ok := families[metric.PrometheusNamespace+"_config_render_duration_seconds"]; ok {
+		for _, m := range mf.GetMetric() {
+			s.renderCount += float64(m.GetHistogram().GetSampleCount())
+			s.renderSum += m.GetHistogram().GetSampleSum()
+		}
+	}
+
+	return s, nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
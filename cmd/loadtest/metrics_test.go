@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetrics(t *testing.T) {
+	input := `# HELP management_ingress_config_reloads_total Cumulative number of NGINX configuration reloads by result
+# TYPE management_ingress_config_reloads_total counter
+management_ingress_config_reloads_total{result="error"} 2
+management_ingress_config_reloads_total{result="success"} 10
+# HELP management_ingress_config_render_duration_seconds Time spent rendering the NGINX configuration template
+# TYPE management_ingress_config_render_duration_seconds histogram
+management_ingress_config_render_duration_seconds_bucket{le="+Inf"} 12
+management_ingress_config_render_duration_seconds_sum 0.6
+management_ingress_config_render_duration_seconds_count 12
+`
+
+	s, err := parseMetrics(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.reloads != 10 {
+		t.Errorf("expected 10 reloads but returned %v", s.reloads)
+	}
+	if s.reloadErrors != 2 {
+		t.Errorf("expected 2 reload errors but returned %v", s.reloadErrors)
+	}
+	if s.renderCount != 12 {
+		t.Errorf("expected 12 renders but returned %v", s.renderCount)
+	}
+	if s.renderSum != 0.6 {
+		t.Errorf("expected 0.6 seconds rendering but returned %v", s.renderSum)
+	}
+}
@@ -26,21 +26,44 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	"github.com/stolostron/management-ingress/pkg/ingress"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/capabilities"
 	"github.com/stolostron/management-ingress/pkg/ingress/controller"
 	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+	"github.com/stolostron/management-ingress/pkg/ingress/controller/process"
+	"github.com/stolostron/management-ingress/pkg/ingress/schema"
+	"github.com/stolostron/management-ingress/pkg/ingress/status"
+	"github.com/stolostron/management-ingress/pkg/ingress/targetgroup"
+	"github.com/stolostron/management-ingress/pkg/k8s"
 	ing_net "github.com/stolostron/management-ingress/pkg/net"
+	"github.com/stolostron/management-ingress/pkg/version"
 )
 
+// configSchema describes the flags, the ConfigMap keys and the annotations
+// of the controller, served in /schema
+var configSchema *schema.Schema
+
 func parseFlags() (bool, *controller.Configuration, error) {
 	var (
 		flags = pflag.NewFlagSet("", pflag.ExitOnError)
@@ -50,15 +73,23 @@ func parseFlags() (bool, *controller.Configuration, error) {
 			"http://localhost:8080. If not specified, the assumption is that the binary runs inside a "+
 			"Kubernetes cluster and local discovery is attempted.")
 		kubeConfigFile = flags.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information.")
+		kubeContext    = flags.String("kubeconfig-context", "", `Context of the kubeconfig of --kubeconfig, or of
+		$KUBECONFIG and ~/.kube/config if not set, selecting the cluster the controller watches. The current
+		context if empty.`)
 
 		configMap = flags.String("configmap", "",
 			`Name of the ConfigMap that contains the custom configuration to use`)
 
-		httpPort  = flags.Int("http-port", 8080, `Indicates the port to use for HTTP traffic`)
-		httpsPort = flags.Int("https-port", 8443, `Indicates the port to use for HTTPS traffic`)
+		httpPort     = flags.Int("http-port", 8080, `Indicates the port to use for HTTP traffic`)
+		httpsPort    = flags.Int("https-port", 8443, `Indicates the port to use for HTTPS traffic`)
+		statusPort   = flags.Int("status-port", 10254, `Indicates the port to use to expose health checks and metrics`)
+		internalPort = flags.Int("internal-port", 10246, `Indicates the port NGINX listens on localhost to report
+		its state to the controller`)
 
 		showVersion = flags.Bool("version", false,
 			`Shows release information about the NGINX Ingress controller`)
+		printSchema = flags.Bool("print-schema", false, `Print the JSON Schema of the flags, the ConfigMap keys
+		and the annotations, and exit.`)
 
 		resyncPeriod = flags.Duration("sync-period", 600*time.Second,
 			`Relist and confirm cloud resources this often. Default is 10 minutes`)
@@ -71,14 +102,250 @@ func parseFlags() (bool, *controller.Configuration, error) {
 		syncRateLimit = flags.Float32("sync-rate-limit", 0.3,
 			`Define the sync frequency upper limit`)
 
+		syncQueueSize = flags.Int("sync-queue-size", 1024,
+			`Maximum number of distinct objects waiting to be synced. Updates of the same object are
+		merged and new objects are dropped when the queue is full. Zero means unbounded.`)
+
+		drainPeriod = flags.Duration("upstream-drain-period", 0,
+			`Time the locations of a Service that was deleted or lost its ClusterIP keep routing to it, so the
+		requests sent while its pods terminate complete, and the terminating endpoints still serving of the
+		backends balanced by NGINX stay in their upstream. Disabled if zero.`)
+
+		maintenanceWindows = flags.StringSlice("maintenance-window", nil, `Period, in UTC, in which the reloads with
+		only deferrable changes are applied, in the form [days ]HH:MM-HH:MM, like "sat,sun 01:00-05:00" or
+		"22:00-06:00". Can be repeated. Reloads are never deferred if empty.`)
+		deferrableChanges = flags.StringSlice("deferrable-changes", controller.DefaultDeferrableChanges, `Changes of
+		the ingress model, in the form <kind>/<action> of the model diff API, that wait for a maintenance window.
+		Reloads with any other change are applied immediately.`)
+
+		reportHealth = flags.Bool("report-health", false, `Write the health of the Ingresses (Programmed, Pending or
+		Error) in the health and health-message annotations, for GitOps health checks. Requires permission to patch
+		Ingresses.`)
+
+		changeFreezeSelector = flags.String("change-freeze-selector", "", `Label selector of the Ingresses whose
+		changes are held until a revision is approved with the /changes/approve endpoint. The last approved version
+		is served in the meantime. Disabled if empty.`)
+		changeFreezeConfigMap = flags.String("change-freeze-configmap", "", `ConfigMap (in the form namespace/name)
+		with the approved versions of the Ingresses of --change-freeze-selector. Required with the selector.`)
+
 		defSSLCertificate = flags.String("default-ssl-certificate", "kube-system/router-certs", `Name of the secret
 		that contains a SSL certificate to be used as default for a HTTPS catch-all server.
 		Takes the form <namespace>/<secret name>.`)
 
+		defSSLCertificates = flags.StringSlice("default-ssl-certificates", nil, `Default certificate of the
+		servers of a group of hostnames, in the form <hosts>=<namespace>/<secret name>, where hosts is a hostname
+		or a wildcard like *.internal.example.com. Used by the servers without a certificate in the Ingress TLS
+		section. Can be repeated.`)
+
+		sharedCertNamespace = flags.String("shared-certificates-namespace", "", `Platform namespace whose
+		secrets with the shared-certificate-alias annotation can be used by the Ingresses of the namespaces of
+		their shared-certificate-namespaces annotation, through the shared-certificate annotation. Disabled if
+		empty.`)
+
+		acmeChallenges = flags.Bool("acme-challenges", true, `Serve the Ingresses of cert-manager solving ACME
+		HTTP-01 challenges regardless of their class, and the challenge paths without the authentication of their
+		Ingress and before the regular expressions.`)
+
 		updateStatus = flags.Bool("update-status", true, `Indicates if the
 		ingress controller should update the Ingress status IP/hostname. Default is true`)
 
-		electionID = flags.String("election-id", "ingress-controller-leader", `Election id to use for status update.`)
+		updateStatusOnShutdown = flags.Bool("update-status-on-shutdown", true, `Remove the address from the
+		status of the Ingresses when the last replica stops. Disable it so the restarts of a single replica, like
+		in its upgrades, do not blank the status.`)
+		shutdownGrace = flags.Duration("shutdown-grace", 0, `Time the last replica waits on shutdown for a new
+		replica, like the one of an upgrade, before removing its address from the status of the Ingresses. The new
+		replica keeps the status, so the DNS records of the Ingresses do not flap. Must be shorter than the
+		termination grace period of the pod. Disabled if zero.`)
+
+		statusDryRun = flags.Bool("status-dry-run", false, `Log the Ingresses whose status would be updated,
+		with the addresses added and removed, without writing it. Useful when other systems own the status of the
+		Ingresses of the cluster.`)
+
+		publishService = flags.String("publish-service", "", `Service, as namespace/name, fronting the
+		controller, like a Service of type LoadBalancer, whose load balancer addresses and external IPs are
+		published in the status of the Ingresses instead of the addresses of the nodes of the replicas. Requires
+		--update-status.`)
+
+		publishStatusAddresses = flags.StringSlice("publish-status-address", nil, `Comma-separated IPs and
+		hostnames published in the status of the Ingresses instead of the addresses of the replicas, like a VIP
+		external to the cluster. Requires --update-status.`)
+
+		statusAddressType = flags.String("status-address-type", status.AddressIP, `Addresses of the nodes of
+		the replicas published in the status of the Ingresses: ip, hostname, or both. Some external-dns setups
+		require hostnames.`)
+		statusNodeAddressTypes = flags.StringSlice("status-node-address-types", []string{"InternalIP"}, `Comma-separated
+		types of the addresses of the nodes of the replicas published in the status of the Ingresses, in order of
+		preference: InternalIP, ExternalIP, InternalDNS, ExternalDNS or Hostname. The first type the node has an
+		address of is used, like ExternalIP,InternalIP in the clouds.`)
+		statusIPFamily = flags.String("status-ip-family", status.IPFamilyDual, `Families of the IPs of the nodes of
+		the replicas published in the status of the Ingresses: ipv4, ipv6, or dual for both in the dual-stack
+		clusters.`)
+
+		statusUpdateInterval = flags.Duration("status-update-interval", status.DefaultUpdateInterval, `Interval
+		between the syncs of the status of all the Ingresses by the leader, besides the ones of the changes of the
+		replicas and of the --publish-service. Longer in large clusters to reduce the load on the API server.`)
+
+		statusUpdateWorkers = flags.Int("status-update-workers", status.DefaultUpdateWorkers, `Number of
+		Ingresses whose status is updated concurrently by the leader. Higher in clusters with thousands of
+		Ingresses, lower to reduce the load on small API servers.`)
+
+		electionID       = flags.String("election-id", "ingress-controller-leader", `Election id to use for status update.`)
+		electionLockType = flags.String("election-lock-type", status.DefaultLockType, `Resource
+		of the leader election: configmaps (previous releases), leases, or configmapsleases to migrate from the
+		ConfigMap to the Lease while replicas of previous releases run.`)
+		electionNamespace = flags.String("election-namespace", "", `Namespace of the lock of the leader
+		election, like a namespace shared by the controllers of an operator. The namespace of the pod if empty.`)
+		electionLockName = flags.String("election-lock-name", "", `Name of the lock of the leader election,
+		for the controllers sharing a namespace. The --election-id and the class if empty.`)
+
+		configDir = flags.String("config-dir", "/opt/ibm/router/nginx/conf",
+			`Directory where the NGINX configuration is written. Must be writable.`)
+		sslDir = flags.String("ssl-dir", ingress.DefaultSSLDirectory,
+			`Directory where the SSL certificates referenced in the configuration are written. Must be writable.`)
+		tempDir = flags.String("temp-dir", "/tmp",
+			`Directory used for the NGINX pid, request buffers and temporal files. Must be writable.`)
+
+		modelCacheDir = flags.String("model-cache-dir", "", `Directory used to persist the last ingress model and
+		NGINX configuration. On restart the cached configuration is served until the informers are synced. Disabled if empty.`)
+
+		enableModelAPI = flags.Bool("enable-model-api", false, `Expose the changes of the ingress model in
+		/model/diffs on the status port, with the snapshot, explain and validation endpoints. Clients must send a
+		Kubernetes token of a user allowed to list Ingresses.`)
+
+		webhookURLs = flags.StringSlice("webhook-url", nil, `URL that receives a signed POST request when
+		routes change or a reload fails. Can be repeated.`)
+		webhookSecretFile = flags.String("webhook-secret-file", "", `File with the key used to sign the webhook
+		payloads with HMAC-SHA256.`)
+		webhookRetries = flags.Int("webhook-retries", 3, `Number of retries of a failed webhook notification.`)
+
+		preflightInterval = flags.Duration("preflight-interval", 0, `Interval between the DNS, TCP and TLS checks
+		of the configured backends and the OIDC issuer. Disabled if zero.`)
+		preflightTimeout = flags.Duration("preflight-timeout", 5*time.Second, `Timeout of the checks of a backend.`)
+		breakGlassAfter  = flags.Duration("break-glass-after", 0, `Time the OIDC issuer must be unreachable in the
+		preflight checks before the routes with the break-glass-auth annotation accept their alternate
+		authentication. Requires --preflight-interval. Disabled if zero.`)
+
+		spiffeSocket = flags.String("spiffe-endpoint-socket", "", `Unix socket of the SPIFFE Workload API, like
+		unix:///run/spire/sockets/agent.sock. The X509-SVID of the controller is presented to the backends with
+		the upstream-spiffe-id annotation. Disabled if empty.`)
+		upstreamIdentityInterval = flags.Duration("upstream-identity-interval", 30*time.Second, `Interval
+		between the checks of the SPIFFE IDs of the endpoints of the backends with the upstream-spiffe-id
+		annotation.`)
+
+		serviceAccountAudience = flags.String("service-account-audience", "management-ingress", `Audience of
+		the projected ServiceAccount tokens accepted in the Ingresses with the service-account auth type.`)
+		authCacheTTL = flags.Duration("auth-cache-ttl", time.Minute, `Time the result of the validation of a
+		token is cached. The tokens are cached by a salted hash. Disabled if zero.`)
+		authCacheSize = flags.Int("auth-cache-size", 4096, `Maximum number of tokens in the validation cache.`)
+
+		startupGate = flags.Bool("startup-gate", false, `Hold the start of NGINX and the readiness until the
+		default certificate, the services of --startup-gate-services and the OIDC issuer of OIDC_ISSUER_URL
+		exist, retrying with a backoff.`)
+		startupGateServices = flags.StringSlice("startup-gate-services", nil, `Services, as <namespace>/<name>,
+		required by --startup-gate, like the auth service.`)
+
+		unixSocketDirs = flags.StringSlice("unix-socket-dir", nil, `Directory with the Unix sockets the Ingresses
+		can proxy to with the unix-socket annotation, like the hostPath of a node-local agent. Can be repeated.
+		The annotation is ignored if empty.`)
+
+		enableCanaryAPI = flags.Bool("enable-canary-api", false, `Expose the weights and header routes of the
+		canaries of the canary-backend annotation in /canary/weights on the status port, for progressive
+		delivery tools. Clients must send a Kubernetes token of a user allowed to patch Ingresses.`)
+
+		canaryRoutes = flags.StringSlice("canary-route", nil, `URL probed through the local listeners after each
+		reload, like https://console.example.com/healthz. When a route that passed before responds with a 5xx
+		status or does not respond, the previous configuration is restored. Can be repeated.`)
+
+		configHistoryDir = flags.String("config-history-dir", "", `Directory where the last rendered configurations
+		are kept with their trigger, timestamp and model hash, to compare them and roll back with the /config
+		endpoints. Disabled if empty.`)
+		configHistorySize = flags.Int("config-history-size", 10, `Number of configurations kept in
+		--config-history-dir.`)
+
+		luaFilterBundle = flags.String("lua-filter-bundle", "", `Directory with the Lua filters Ingresses can
+		run with the lua-filters annotation. Every <name>.lua file requires a <name>.lua.sig file with its base64
+		encoded ed25519 signature. Disabled if empty.`)
+		luaFilterPublicKey = flags.String("lua-filter-public-key", "", `PEM file with the ed25519 public key used
+		to verify the Lua filters.`)
+		luaFilterMaxInstructions = flags.Int("lua-filter-max-instructions", 1000000, `Maximum number of Lua
+		instructions a filter can run in each phase of a request.`)
+
+		capabilitiesConfigMap = flags.String("capabilities-configmap", "", `ConfigMap, in the namespace of the
+		pod, where every replica publishes its release, annotations, CRDs, modes and subsystems for the
+		management-ingress operator, which enables a new feature once all the replicas support it. Disabled if
+		empty.`)
+		capabilitiesInterval = flags.Duration("capabilities-interval", time.Minute, `Interval between the
+		publications of the capabilities of the replica.`)
+
+		offline = flags.Bool("offline", false, `Offline mode, for disconnected hubs: the telemetry is disabled and
+		--webhook-url and --aws-target-group-arn, which call services outside of the cluster, are rejected. The
+		controller still calls the agent of the node of --vip-agent-url, and the preflight checks still reach
+		the backends, including the external names of ExternalName Services.`)
+		telemetryEndpoint = flags.String("telemetry-endpoint", "", `URL that receives the anonymized usage of the
+		features: the annotations and modes in use and the scale of the configuration, rounded. Opt-in, disabled
+		if empty or with --offline.`)
+		telemetryInterval = flags.Duration("telemetry-interval", 24*time.Hour, `Interval between the telemetry
+		reports.`)
+
+		annotationPlugins = flags.StringSlice("annotation-plugin", nil, `Unix socket of an external annotation
+		plugin, like unix:///run/plugins/example.sock, serving the AnnotationPlugin gRPC service. Its data is
+		available to the template in the Plugins of the locations. Can be repeated.`)
+		annotationPluginTimeout = flags.Duration("annotation-plugin-timeout", time.Second, `Timeout of the calls
+		to the external annotation plugins.`)
+
+		externalDNS = flags.Bool("publish-external-dns", false, `Publish the DNS records of the hosts of the
+		Ingresses for external-dns, with the addresses of their status: the target and TTL annotations or, with the
+		ExternalDNSEndpoints feature gate, a DNSEndpoint per Ingress. Requires --update-status.`)
+		externalDNSTargets = flags.StringSlice("external-dns-target", nil, `Targets of the DNS records instead of
+		the addresses of the status, like the hostname of a cloud load balancer. Can be repeated.`)
+		externalDNSTTL = flags.Duration("external-dns-ttl", 0, `TTL of the DNS records. The default of the DNS
+		provider if zero.`)
+
+		targetGroupARN = flags.String("aws-target-group-arn", "", `ARN of the AWS target group of a Network or
+		Application Load Balancer where the leader registers the ready replicas, instead of a Service of type
+		LoadBalancer. Requires --update-status. Disabled if empty.`)
+		targetGroupType = flags.String("aws-target-type", "ip", `Type of the targets of the target group: ip for
+		the IP addresses of the pods or instance for the EC2 instances of their nodes.`)
+		targetGroupPort     = flags.Int("aws-target-port", 0, `Port of the targets, the HTTPS port if zero.`)
+		targetGroupInterval = flags.Duration("aws-target-group-interval", 10*time.Second, `Interval between the
+		updates of the targets of the target group.`)
+
+		vipAddress = flags.String("vip", "", `Virtual IP address announced from the replica elected among the
+		replicas with a healthy NGINX, through the agent of --vip-agent-url, and published in the status of the
+		Ingresses. Requires --update-status. Disabled if empty.`)
+		vipAgentURL = flags.String("vip-agent-url", "", `Base URL of the API of the agent of the node, like a
+		gratuitous ARP or BGP speaker, announcing the VIP.`)
+		vipInterval = flags.Duration("vip-check-interval", 2*time.Second, `Interval between the checks of NGINX
+		of the replica announcing the VIP.`)
+
+		deschedulerHint = flags.Bool("descheduler-hint", false, `Mark the replicas sharing a node with an older
+		replica with the descheduler.alpha.kubernetes.io/evict annotation, so a descheduler strategy like
+		RemoveDuplicates can evict them. Requires --update-status.`)
+
+		statusRepairQPS = flags.Float32("status-repair-qps", 5, `Maximum requests per second to the API server
+		of the repairs of the status of the Ingresses. Unlimited if zero.`)
+		statusRepairInterval = flags.Duration("status-repair-interval", 0, `Interval between the repairs of the
+		status of all the Ingresses of the class, read from the API server, by the leader. Disabled if zero.
+		Requires --update-status.`)
+		enableStatusRepairAPI = flags.Bool("enable-status-repair-api", false, `Expose /status/repair on the
+		status port to start and follow a repair of the status of the Ingresses. Clients must send a Kubernetes token of a
+		user allowed to update Ingresses. Requires --update-status.`)
+		statusSummaryConfigMap = flags.String("status-summary-configmap", "", `ConfigMap, as namespace/name, where
+		the leader publishes the addresses of the status, the hosts of the Ingresses and the number of ready
+		replicas, for the clients discovering the entry points of the hub. Requires --update-status. Disabled if
+		empty.`)
+
+		featureGates = flags.StringToString("feature-gates", nil, `Features to enable, like
+		ExternalDNSEndpoints=true.`)
+
+		leakDetectorInterval = flags.Duration("leak-detector-interval", 0,
+			`Interval between heap and goroutine samples of the leak detector. Disabled if zero.`)
+		leakDetectorWindow = flags.Int("leak-detector-window", 12,
+			`Number of consecutive growing samples required to report a leak.`)
+		leakDetectorThreshold = flags.Float64("leak-detector-threshold", 0.5,
+			`Minimum relative growth (0.5 = 50%) within the window required to report a leak.`)
+		leakDetectorDir = flags.String("leak-detector-profile-dir", os.TempDir(),
+			`Directory where the leak detector writes heap and goroutine profiles. Disabled if empty.`)
 	)
 
 	if err := flag.Set("logtostderr", "true"); err != nil {
@@ -104,7 +371,45 @@ func parseFlags() (bool, *controller.Configuration, error) {
 		return true, nil, nil
 	}
 
+	defaultGates, err := controller.ParseFeatureGates(nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// the flags added after the first schema, reported with their release
+	for _, name := range []string{
+		"acme-challenges", "annotation-plugin", "annotation-plugin-timeout", "auth-cache-size", "auth-cache-ttl",
+		"aws-target-group-arn", "aws-target-group-interval", "aws-target-port", "aws-target-type",
+		"break-glass-after", "canary-route", "capabilities-configmap", "capabilities-interval",
+		"change-freeze-configmap", "change-freeze-selector", "config-dir", "config-history-dir",
+		"config-history-size", "default-ssl-certificates", "deferrable-changes", "descheduler-hint",
+		"election-lock-name", "election-lock-type", "election-namespace", "enable-canary-api", "enable-model-api",
+		"enable-status-repair-api", "external-dns-target", "external-dns-ttl", "feature-gates", "internal-port",
+		"kubeconfig-context", "leak-detector-interval", "leak-detector-profile-dir", "leak-detector-window",
+		"lua-filter-bundle", "lua-filter-max-instructions", "lua-filter-public-key", "maintenance-window",
+		"model-cache-dir", "offline", "preflight-interval", "preflight-timeout", "print-schema",
+		"publish-external-dns", "publish-service", "publish-status-address", "report-health",
+		"service-account-audience", "shared-certificates-namespace", "shutdown-grace", "spiffe-endpoint-socket",
+		"ssl-dir", "startup-gate", "startup-gate-services", "status-address-type", "status-dry-run",
+		"status-ip-family", "status-node-address-types", "status-port", "status-repair-interval",
+		"status-summary-configmap", "status-update-interval", "status-update-workers", "sync-queue-size",
+		"telemetry-endpoint", "telemetry-interval", "temp-dir", "unix-socket-dir", "update-status-on-shutdown",
+		"upstream-drain-period", "upstream-identity-interval", "vip", "vip-agent-url", "vip-check-interval",
+		"webhook-retries", "webhook-secret-file", "webhook-url",
+	} {
+		if err := flags.SetAnnotation(name, schema.SinceAnnotation, []string{"2.8.0"}); err != nil {
+			return false, nil, err
+		}
+	}
+	configSchema = schema.New(version.RELEASE, flags, defaultGates)
+	if *printSchema {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return true, nil, enc.Encode(configSchema)
+	}
+
 	parser.AnnotationsPrefix = *annotationsPrefix
+	ingress.DefaultSSLDirectory = *sslDir
 
 	// check port collisions
 	if !ing_net.IsPortAvailable(*httpPort) {
@@ -115,19 +420,348 @@ func parseFlags() (bool, *controller.Configuration, error) {
 		return false, nil, fmt.Errorf("Port %v is already in use. Please check the flag --https-port", *httpsPort)
 	}
 
+	if !ing_net.IsPortAvailable(*statusPort) {
+		return false, nil, fmt.Errorf("Port %v is already in use. Please check the flag --status-port", *statusPort)
+	}
+
+	// a controller running as non-root can only bind privileged ports with CAP_NET_BIND_SERVICE
+	for _, port := range []int{*httpPort, *httpsPort} {
+		if !process.NeedsBindCapability(port) {
+			continue
+		}
+
+		if process.CanRaiseAmbient(process.CapNetBindService) {
+			continue
+		}
+		ok, err := process.CanGrantFileCapability(process.CapNetBindService)
+		if err != nil {
+			return false, nil, err
+		}
+		if !ok {
+			return false, nil, fmt.Errorf("Port %v requires root or the NET_BIND_SERVICE capability in the bounding set "+
+				"of the container without allowPrivilegeEscalation: false. "+
+				"Use an unprivileged port and map it in the Service or the hostPort of the pod", port)
+		}
+	}
+
+	var webhookSecret []byte
+	if *webhookSecretFile != "" {
+		b, err := ioutil.ReadFile(*webhookSecretFile)
+		if err != nil {
+			return false, nil, fmt.Errorf("unexpected error reading webhook secret: %v", err)
+		}
+		webhookSecret = bytes.TrimSpace(b)
+	}
+
+	if *offline && *telemetryEndpoint != "" {
+		glog.Warningf("ignoring --telemetry-endpoint in offline mode")
+		*telemetryEndpoint = ""
+	}
+	if *offline && len(*webhookURLs) > 0 {
+		return false, nil, fmt.Errorf("--webhook-url is not allowed with --offline")
+	}
+	if *offline && *targetGroupARN != "" {
+		return false, nil, fmt.Errorf("--aws-target-group-arn is not allowed with --offline")
+	}
+	if *telemetryEndpoint != "" {
+		u, err := url.Parse(*telemetryEndpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return false, nil, fmt.Errorf("invalid --telemetry-endpoint %q, expected an HTTP or HTTPS URL", *telemetryEndpoint)
+		}
+		if *telemetryInterval < time.Hour {
+			return false, nil, fmt.Errorf("--telemetry-interval must be at least 1h")
+		}
+	}
+
+	if *capabilitiesConfigMap != "" && *capabilitiesInterval <= 0 {
+		return false, nil, fmt.Errorf("--capabilities-interval must be positive")
+	}
+
+	var luaFilterKey ed25519.PublicKey
+	if *luaFilterBundle != "" {
+		if !capabilities.Compiled(capabilities.Lua) {
+			return false, nil, fmt.Errorf("--lua-filter-bundle requires the Lua filters, excluded by the nolua build tag")
+		}
+		if *luaFilterPublicKey == "" {
+			return false, nil, fmt.Errorf("--lua-filter-public-key is required to verify the Lua filters")
+		}
+		var err error
+		luaFilterKey, err = readLuaFilterKey(*luaFilterPublicKey)
+		if err != nil {
+			return false, nil, fmt.Errorf("unexpected error reading Lua filter public key: %v", err)
+		}
+	}
+
+	defaultCertificates, err := controller.ParseDefaultCertificates(*defSSLCertificates)
+	if err != nil {
+		return false, nil, err
+	}
+
+	windows, err := controller.ParseMaintenanceWindows(*maintenanceWindows)
+	if err != nil {
+		return false, nil, err
+	}
+	deferrable, err := controller.ParseDeferrableChanges(*deferrableChanges)
+	if err != nil {
+		return false, nil, err
+	}
+
+	gates, err := controller.ParseFeatureGates(*featureGates)
+	if err != nil {
+		return false, nil, err
+	}
+	if *externalDNS && !*updateStatus {
+		return false, nil, fmt.Errorf("--publish-external-dns requires --update-status")
+	}
+	if gates[controller.ExternalDNSEndpoints] && !*externalDNS {
+		return false, nil, fmt.Errorf("the %v feature gate requires --publish-external-dns", controller.ExternalDNSEndpoints)
+	}
+
+	if *targetGroupARN != "" {
+		if !*updateStatus {
+			return false, nil, fmt.Errorf("--aws-target-group-arn requires --update-status")
+		}
+		if _, err := targetgroup.ParseARN(*targetGroupARN); err != nil {
+			return false, nil, err
+		}
+		if *targetGroupType != targetgroup.TargetTypeIP && *targetGroupType != targetgroup.TargetTypeInstance {
+			return false, nil, fmt.Errorf("invalid --aws-target-type %q, expected %v or %v", *targetGroupType,
+				targetgroup.TargetTypeIP, targetgroup.TargetTypeInstance)
+		}
+		if *targetGroupPort == 0 {
+			*targetGroupPort = *httpsPort
+		}
+	}
+
+	if *vipAddress != "" {
+		if !*updateStatus {
+			return false, nil, fmt.Errorf("--vip requires --update-status")
+		}
+		if net.ParseIP(*vipAddress) == nil {
+			return false, nil, fmt.Errorf("invalid --vip %q, expected an IP address", *vipAddress)
+		}
+		if *vipAgentURL == "" {
+			return false, nil, fmt.Errorf("--vip requires --vip-agent-url")
+		}
+	}
+
+	for _, svc := range *startupGateServices {
+		if _, _, err := k8s.ParseNameNS(svc); err != nil {
+			return false, nil, fmt.Errorf("invalid --startup-gate-services: %v", err)
+		}
+	}
+
+	for _, dir := range *unixSocketDirs {
+		if !filepath.IsAbs(dir) {
+			return false, nil, fmt.Errorf("invalid --unix-socket-dir %q, expected an absolute path", dir)
+		}
+	}
+
+	for _, route := range *canaryRoutes {
+		u, err := url.Parse(route)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return false, nil, fmt.Errorf("invalid --canary-route %q, expected an HTTP or HTTPS URL", route)
+		}
+	}
+
+	if *configHistoryDir != "" && *configHistorySize < 1 {
+		return false, nil, fmt.Errorf("--config-history-size must be positive")
+	}
+
+	if *breakGlassAfter > 0 && *preflightInterval <= 0 {
+		return false, nil, fmt.Errorf("--break-glass-after requires --preflight-interval")
+	}
+
+	if *deschedulerHint && !*updateStatus {
+		return false, nil, fmt.Errorf("--descheduler-hint requires --update-status")
+	}
+
+	if (*statusRepairInterval > 0 || *enableStatusRepairAPI) && !*updateStatus {
+		return false, nil, fmt.Errorf("--status-repair-interval and --enable-status-repair-api require --update-status")
+	}
+
+	if *publishService != "" {
+		if !*updateStatus {
+			return false, nil, fmt.Errorf("--publish-service requires --update-status")
+		}
+		if *vipAddress != "" {
+			return false, nil, fmt.Errorf("--publish-service and --vip are mutually exclusive")
+		}
+		if _, _, err := k8s.ParseNameNS(*publishService); err != nil {
+			return false, nil, fmt.Errorf("invalid --publish-service: %v", err)
+		}
+	}
+
+	if len(*publishStatusAddresses) > 0 {
+		if !*updateStatus {
+			return false, nil, fmt.Errorf("--publish-status-address requires --update-status")
+		}
+		if *vipAddress != "" || *publishService != "" {
+			return false, nil, fmt.Errorf("--publish-status-address, --publish-service and --vip are mutually exclusive")
+		}
+		for _, addr := range *publishStatusAddresses {
+			if addr == "" || (strings.ContainsAny(addr, " /:") && net.ParseIP(addr) == nil) {
+				return false, nil, fmt.Errorf("invalid --publish-status-address %q, expected an IP address or a hostname", addr)
+			}
+		}
+	}
+
+	if !status.IsValidAddressType(*statusAddressType) {
+		return false, nil, fmt.Errorf("invalid --status-address-type %q, expected one of %v", *statusAddressType, status.AddressTypes)
+	}
+
+	nodeAddressTypes := []apiv1.NodeAddressType{}
+	for _, addressType := range *statusNodeAddressTypes {
+		if !status.IsValidNodeAddressType(addressType) {
+			return false, nil, fmt.Errorf("invalid --status-node-address-types %q, expected %v", addressType, status.NodeAddressTypes)
+		}
+		nodeAddressTypes = append(nodeAddressTypes, apiv1.NodeAddressType(addressType))
+	}
+
+	if !status.IsValidIPFamily(*statusIPFamily) {
+		return false, nil, fmt.Errorf("invalid --status-ip-family %q, expected one of %v", *statusIPFamily, status.IPFamilies)
+	}
+
+	if !status.IsValidLockType(*electionLockType) {
+		return false, nil, fmt.Errorf("invalid --election-lock-type %q, expected one of %v", *electionLockType, status.LockTypes)
+	}
+	if *electionNamespace != "" {
+		if errs := validation.IsDNS1123Label(*electionNamespace); len(errs) > 0 {
+			return false, nil, fmt.Errorf("invalid --election-namespace %q: %v", *electionNamespace, strings.Join(errs, ", "))
+		}
+	}
+	if *electionLockName != "" {
+		if errs := validation.IsDNS1123Subdomain(*electionLockName); len(errs) > 0 {
+			return false, nil, fmt.Errorf("invalid --election-lock-name %q: %v", *electionLockName, strings.Join(errs, ", "))
+		}
+	}
+
+	if *statusSummaryConfigMap != "" {
+		if !*updateStatus {
+			return false, nil, fmt.Errorf("--status-summary-configmap requires --update-status")
+		}
+		if _, _, err := k8s.ParseNameNS(*statusSummaryConfigMap); err != nil {
+			return false, nil, fmt.Errorf("invalid --status-summary-configmap: %v", err)
+		}
+	}
+
+	if *shutdownGrace < 0 {
+		return false, nil, fmt.Errorf("--shutdown-grace must not be negative")
+	}
+
+	if *statusUpdateInterval <= 0 {
+		return false, nil, fmt.Errorf("--status-update-interval must be positive")
+	}
+
+	if *statusUpdateWorkers <= 0 {
+		return false, nil, fmt.Errorf("--status-update-workers must be positive")
+	}
+
+	if *statusRepairQPS < 0 {
+		return false, nil, fmt.Errorf("--status-repair-qps must not be negative")
+	}
+
+	var freezeSelector labels.Selector
+	if *changeFreezeSelector != "" {
+		freezeSelector, err = labels.Parse(*changeFreezeSelector)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid change freeze selector: %v", err)
+		}
+		if _, _, err := k8s.ParseNameNS(*changeFreezeConfigMap); err != nil {
+			return false, nil, fmt.Errorf("--change-freeze-selector requires --change-freeze-configmap: %v", err)
+		}
+	}
+
 	config := &controller.Configuration{
-		APIServerHost:         *apiserverHost,
-		KubeConfigFile:        *kubeConfigFile,
-		UpdateStatus:          *updateStatus,
-		ElectionID:            *electionID,
-		ResyncPeriod:          *resyncPeriod,
-		Namespace:             *watchNamespace,
-		ConfigMapName:         *configMap,
-		SyncRateLimit:         *syncRateLimit,
-		DefaultSSLCertificate: *defSSLCertificate,
+		APIServerHost:            *apiserverHost,
+		KubeConfigFile:           *kubeConfigFile,
+		KubeContext:              *kubeContext,
+		UpdateStatus:             *updateStatus,
+		UpdateStatusOnShutdown:   *updateStatusOnShutdown,
+		StatusDryRun:             *statusDryRun,
+		ShutdownGrace:            *shutdownGrace,
+		ExternalDNS:              *externalDNS,
+		ExternalDNSTargets:       *externalDNSTargets,
+		ExternalDNSTTL:           *externalDNSTTL,
+		TargetGroupARN:           *targetGroupARN,
+		TargetGroupType:          *targetGroupType,
+		TargetGroupPort:          *targetGroupPort,
+		TargetGroupInterval:      *targetGroupInterval,
+		VIP:                      *vipAddress,
+		PublishService:           *publishService,
+		PublishStatusAddresses:   *publishStatusAddresses,
+		StatusAddressType:        *statusAddressType,
+		StatusIPFamily:           *statusIPFamily,
+		StatusNodeAddressTypes:   nodeAddressTypes,
+		VIPAgentURL:              *vipAgentURL,
+		VIPInterval:              *vipInterval,
+		DeschedulerHint:          *deschedulerHint,
+		StatusRepairQPS:          *statusRepairQPS,
+		StatusRepairInterval:     *statusRepairInterval,
+		EnableStatusRepairAPI:    *enableStatusRepairAPI,
+		StatusSummaryConfigMap:   *statusSummaryConfigMap,
+		FeatureGates:             gates,
+		ElectionID:               *electionID,
+		ElectionLockType:         *electionLockType,
+		ElectionNamespace:        *electionNamespace,
+		ElectionLockName:         *electionLockName,
+		StatusUpdateInterval:     *statusUpdateInterval,
+		StatusUpdateWorkers:      *statusUpdateWorkers,
+		ResyncPeriod:             *resyncPeriod,
+		Namespace:                *watchNamespace,
+		ConfigMapName:            *configMap,
+		SyncRateLimit:            *syncRateLimit,
+		SyncQueueSize:            *syncQueueSize,
+		DrainPeriod:              *drainPeriod,
+		MaintenanceWindows:       windows,
+		DeferrableChanges:        deferrable,
+		ChangeFreezeSelector:     freezeSelector,
+		ChangeFreezeConfigMap:    *changeFreezeConfigMap,
+		ReportHealth:             *reportHealth,
+		DefaultSSLCertificate:    *defSSLCertificate,
+		DefaultSSLCertificates:   defaultCertificates,
+		SharedCertNamespace:      *sharedCertNamespace,
+		ACMEChallenges:           *acmeChallenges,
+		ModelCacheDir:            *modelCacheDir,
+		EnableModelAPI:           *enableModelAPI,
+		WebhookURLs:              *webhookURLs,
+		WebhookSecret:            webhookSecret,
+		WebhookRetries:           *webhookRetries,
+		ConfigDir:                *configDir,
+		PreflightInterval:        *preflightInterval,
+		PreflightTimeout:         *preflightTimeout,
+		BreakGlassAfter:          *breakGlassAfter,
+		ServiceAccountAudience:   *serviceAccountAudience,
+		AuthCacheTTL:             *authCacheTTL,
+		AuthCacheSize:            *authCacheSize,
+		StartupGate:              *startupGate,
+		StartupGateServices:      *startupGateServices,
+		UnixSocketDirs:           *unixSocketDirs,
+		EnableCanaryAPI:          *enableCanaryAPI,
+		CanaryRoutes:             *canaryRoutes,
+		ConfigHistoryDir:         *configHistoryDir,
+		ConfigHistorySize:        *configHistorySize,
+		SPIFFESocket:             *spiffeSocket,
+		UpstreamIdentityInterval: *upstreamIdentityInterval,
+		LuaFilterBundle:          *luaFilterBundle,
+		LuaFilterPublicKey:       luaFilterKey,
+		LuaFilterMaxInstructions: *luaFilterMaxInstructions,
+		AnnotationPlugins:        *annotationPlugins,
+		TelemetryEndpoint:        *telemetryEndpoint,
+		TelemetryInterval:        *telemetryInterval,
+		CapabilitiesConfigMap:    *capabilitiesConfigMap,
+		CapabilitiesInterval:     *capabilitiesInterval,
+		AnnotationPluginTimeout:  *annotationPluginTimeout,
+		TempDir:                  *tempDir,
+		LeakDetectorInterval:     *leakDetectorInterval,
+		LeakDetectorWindow:       *leakDetectorWindow,
+		LeakDetectorThreshold:    *leakDetectorThreshold,
+		LeakDetectorDir:          *leakDetectorDir,
 		ListenPorts: &ngx_config.ListenPorts{
-			HTTP:  *httpPort,
-			HTTPS: *httpsPort,
+			HTTP:     *httpPort,
+			HTTPS:    *httpsPort,
+			Status:   *statusPort,
+			Internal: *internalPort,
 		},
 	}
 
@@ -0,0 +1,46 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+func TestStatusUpdateIntervalFlag(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	defer func(sslDir string) { ingress.DefaultSSLDirectory = sslDir }(ingress.DefaultSSLDirectory)
+	defer func(prefix string) { parser.AnnotationsPrefix = prefix }(parser.AnnotationsPrefix)
+
+	testCases := []struct {
+		interval string
+		expected time.Duration
+		err      bool
+	}{
+		{"0s", 0, true},
+		{"-1m", 0, true},
+		{"30s", 30 * time.Second, false},
+	}
+
+	for _, tc := range testCases {
+		os.Args = []string{"nginx-ingress-controller", "--status-update-interval=" + tc.interval}
+		_, conf, err := parseFlags()
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected an error with --status-update-interval=%v", tc.interval)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if conf.StatusUpdateInterval != tc.expected {
+			t.Errorf("expected the update interval %v but returned %v", tc.expected, conf.StatusUpdateInterval)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build !nolua
+
+package main
+
+import (
+	"crypto/ed25519"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/filters"
+)
+
+// readLuaFilterKey reads the public key that verifies the Lua filters
+func readLuaFilterKey(path string) (ed25519.PublicKey, error) {
+	return filters.ReadPublicKey(path)
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build nolua
+
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// readLuaFilterKey fails, the Lua filters are not compiled in the controller
+func readLuaFilterKey(path string) (ed25519.PublicKey, error) {
+	return nil, fmt.Errorf("the Lua filters are excluded by the nolua build tag")
+}
@@ -13,27 +13,45 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/stolostron/management-ingress/pkg/file"
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/breakglass"
 	"github.com/stolostron/management-ingress/pkg/ingress/controller"
+	"github.com/stolostron/management-ingress/pkg/ingress/history"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+	"github.com/stolostron/management-ingress/pkg/ingress/oidcverify"
+	"github.com/stolostron/management-ingress/pkg/ingress/revocation"
+	"github.com/stolostron/management-ingress/pkg/ingress/saauth"
 	"github.com/stolostron/management-ingress/pkg/version"
 )
 
 func main() {
-	fmt.Println(version.String())
+	// the release goes to stderr, stdout only contains the output of
+	// --print-schema
+	fmt.Fprintln(os.Stderr, version.String())
 
 	showVersion, conf, err := parseFlags()
 	if showVersion {
@@ -49,7 +67,14 @@ func main() {
 		glog.Fatal(err)
 	}
 
-	kubeClient, err := createApiserverClient(conf.APIServerHost, conf.KubeConfigFile)
+	// with a read-only root filesystem these must be emptyDir or tmpfs mounts
+	for _, dir := range []string{conf.ConfigDir, conf.TempDir, ingress.DefaultSSLDirectory} {
+		if err := fs.MkdirAll(dir, 0700); err != nil {
+			glog.Fatalf("unexpected error creating writable directory %v: %v", dir, err)
+		}
+	}
+
+	kubeClient, err := createApiserverClient(conf.APIServerHost, conf.KubeConfigFile, conf.KubeContext)
 	if err != nil {
 		handleFatalInitError(err)
 	}
@@ -67,8 +92,86 @@ func main() {
 
 	conf.Client = kubeClient
 
+	if conf.FeatureGates[controller.ExternalDNSEndpoints] || conf.FeatureGates[controller.OIDCRoutePolicies] {
+		conf.DynamicClient, err = createDynamicClient(conf.APIServerHost, conf.KubeConfigFile, conf.KubeContext)
+		if err != nil {
+			handleFatalInitError(err)
+		}
+	}
+
 	ngx := controller.NewNGINXController(conf, fs)
 
+	prometheus.MustRegister(metric.NewBudgetCollector(conf.ListenPorts.Internal),
+		metric.NewCustomCounterCollector(conf.ListenPorts.Internal),
+		metric.NewRejectedRequestCollector(conf.ListenPorts.Internal),
+		metric.NewWebsocketSessionCollector(conf.ListenPorts.Internal),
+		metric.NewWebsocketRejectionCollector(conf.ListenPorts.Internal),
+		metric.NewWebsocketCloseCollector(conf.ListenPorts.Internal),
+		metric.NewTaggedRequestBytesCollector(conf.ListenPorts.Internal),
+		metric.NewTaggedResponseBytesCollector(conf.ListenPorts.Internal),
+		metric.NewUploadsInProgressCollector(conf.ListenPorts.Internal),
+		metric.NewUploadBytesCollector(conf.ListenPorts.Internal),
+		metric.NewUploadRequestCollector(conf.ListenPorts.Internal),
+		metric.NewBackendRequestCollector(conf.ListenPorts.Internal),
+		metric.NewBackendActiveRequestCollector(conf.ListenPorts.Internal),
+		metric.NewSurgeRejectionCollector(conf.ListenPorts.Internal),
+		metric.NewFairnessRejectionCollector(conf.ListenPorts.Internal),
+		metric.NewTierRequestCollector(conf.ListenPorts.Internal),
+		metric.NewTierRejectionCollector(conf.ListenPorts.Internal),
+		metric.NewEjectedEndpointCollector(conf.ListenPorts.Internal))
+
+	mux := http.NewServeMux()
+	registerHandlers(mux)
+	tokenAuth := saauth.New(kubeClient, conf.ServiceAccountAudience, conf.AuthCacheTTL, conf.AuthCacheSize)
+	mux.Handle("/auth/service-account", saauth.Handler(tokenAuth))
+	mux.Handle("/auth/groups", saauth.GroupsHandler(tokenAuth))
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		mux.Handle("/auth/oidc", oidcverify.Handler(oidcverify.New(issuer, nil)))
+	}
+	mux.Handle("/auth/client-certificate", revocation.Handler(ngx.ClientCertificateChecker()))
+	mux.Handle("/auth/break-glass", breakglass.Handler(ngx.BreakGlassChecker()))
+	mux.Handle("/readyz", readinessHandler(ngx))
+	mux.Handle("/capabilities", capabilitiesHandler(ngx))
+	mux.Handle("/schema", schemaHandler())
+	mux.Handle("/telemetry", telemetryHandler(ngx))
+	mux.Handle("/is-leader", leaderHandler(ngx))
+	if conf.EnableModelAPI {
+		auth := modeldiff.TokenAuthorizer{Client: kubeClient}
+		mux.Handle("/model/diffs", modeldiff.Handler(ngx.ModelEvents(), auth))
+		mux.Handle("/model/snapshot", modeldiff.RequireToken(auth, snapshotHandler(ngx)))
+		mux.Handle("/model/explain", modeldiff.RequireToken(auth, explainHandler(ngx)))
+		mux.Handle("/model/validate", modeldiff.RequireMethodToken(auth, http.MethodPost, validateHandler(ngx)))
+	}
+	if conf.ChangeFreezeSelector != nil {
+		mux.Handle("/changes/pending", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, pendingChangesHandler(ngx)))
+		mux.Handle("/changes/approve", modeldiff.RequireNamespaceToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "approve"},
+			http.MethodPost, "namespace", approveChangeHandler(ngx)))
+	}
+	if len(conf.MaintenanceWindows) > 0 {
+		mux.Handle("/reload/deferred", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, deferredReloadHandler(ngx)))
+		mux.Handle("/reload/apply", modeldiff.RequireMethodToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "update"},
+			http.MethodPost, applyReloadHandler(ngx)))
+	}
+	if conf.ConfigHistoryDir != "" {
+		mux.Handle("/config/revisions", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, revisionsHandler(ngx)))
+		mux.Handle("/config/revisions/diff", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, revisionDiffHandler(ngx)))
+		mux.Handle("/config/rollback", modeldiff.RequireMethodToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "update"},
+			http.MethodPost, rollbackHandler(ngx)))
+		mux.Handle("/config/release", modeldiff.RequireMethodToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "update"},
+			http.MethodPost, releaseHandler(ngx)))
+	}
+	if conf.EnableCanaryAPI {
+		mux.Handle("/canary/weights", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, canaryWeightHandler(ngx)))
+		mux.Handle("/canary/weights/update", modeldiff.RequireMethodToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "patch"},
+			http.MethodPost, updateCanaryWeightHandler(ngx)))
+	}
+	if conf.EnableStatusRepairAPI {
+		mux.Handle("/status/repair", modeldiff.RequireToken(modeldiff.TokenAuthorizer{Client: kubeClient}, statusRepairHandler(ngx)))
+		mux.Handle("/status/repair/start", modeldiff.RequireMethodToken(modeldiff.TokenAuthorizer{Client: kubeClient, Verb: "update"},
+			http.MethodPost, startStatusRepairHandler(ngx)))
+	}
+	go startHTTPServer(conf.ListenPorts.Status, mux)
+
 	go handleSigterm(ngx, func(code int) {
 		os.Exit(code)
 	})
@@ -97,14 +200,409 @@ func handleSigterm(ngx *controller.NGINXController, exit exiter) {
 	exit(exitCode)
 }
 
+// registerHandlers adds the health check and metrics endpoints to the mux
+func registerHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.Handle("/metrics", promhttp.Handler())
+}
+
+// readinessHandler returns the state of the startup gate, with the status
+// 503 until the required references exist
+func readinessHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := ngx.StartupGate()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			glog.Warningf("unexpected error writing the startup gate: %v", err)
+		}
+	})
+}
+
+// capabilitiesHandler returns the optional subsystems enabled in the
+// controller
+func capabilitiesHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ngx.Capabilities()); err != nil {
+			glog.Warningf("unexpected error writing capabilities: %v", err)
+		}
+	})
+}
+
+// schemaHandler returns the JSON Schema of the configuration
+func schemaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		if err := json.NewEncoder(w).Encode(configSchema); err != nil {
+			glog.Warningf("unexpected error writing the schema: %v", err)
+		}
+	})
+}
+
+// telemetryHandler returns the report sent to the telemetry endpoint, so
+// the administrator can review it
+func telemetryHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := ngx.TelemetryReport()
+		if report == nil {
+			http.Error(w, "the telemetry is disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			glog.Warningf("unexpected error writing the telemetry report: %v", err)
+		}
+	})
+}
+
+// statusLeader reports whether this replica is the leader updating the
+// status of the Ingresses
+type statusLeader interface {
+	IsStatusLeader() (bool, error)
+}
+
+// leaderHandler returns whether this replica is the leader updating the
+// status of the Ingresses
+func leaderHandler(ngx statusLeader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leader, err := ngx.IsStatusLeader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]bool{"leader": leader}); err != nil {
+			glog.Warningf("unexpected error writing the leadership: %v", err)
+		}
+	})
+}
+
+// snapshotHandler returns the running model and configuration
+func snapshotHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := ngx.Snapshot()
+		if err != nil {
+			glog.Errorf("unexpected error creating snapshot: %v", err)
+			http.Error(w, "unable to create snapshot", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := s.Write(w); err != nil {
+			glog.Warningf("unexpected error writing snapshot: %v", err)
+		}
+	})
+}
+
+// explainHandler returns the effective configuration of the Ingress
+// in the namespace and name query parameters
+func explainHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace := r.URL.Query().Get("namespace")
+		name := r.URL.Query().Get("name")
+		if namespace == "" || name == "" {
+			http.Error(w, "namespace and name are required", http.StatusBadRequest)
+			return
+		}
+
+		e, err := ngx.Explain(namespace, name)
+		if apierrors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			glog.Errorf("unexpected error explaining ingress %v/%v: %v", namespace, name, err)
+			http.Error(w, "unable to explain ingress", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			glog.Warningf("unexpected error writing explanation: %v", err)
+		}
+	})
+}
+
+// validateHandler validates the Ingress manifests of the request body, in
+// YAML or JSON, against the running model without applying them
+func validateHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ings, err := controller.DecodeIngresses(http.MaxBytesReader(w, r.Body, maxValidateBodySize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid manifests: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ngx.Validate(ings)); err != nil {
+			glog.Warningf("unexpected error writing validation report: %v", err)
+		}
+	})
+}
+
+// deferredReloadHandler returns the changes waiting for the next
+// maintenance window
+func deferredReloadHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := ngx.DeferredReload()
+		if d == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(d); err != nil {
+			glog.Warningf("unexpected error writing deferred reload: %v", err)
+		}
+	})
+}
+
+// applyReloadHandler applies the deferred changes without waiting for the
+// maintenance window
+func applyReloadHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ngx.ApplyDeferredReload() {
+			http.Error(w, "there are no deferred changes", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// pendingChangesHandler returns the changes of the frozen Ingresses
+// waiting for approval
+func pendingChangesHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ngx.PendingChanges()); err != nil {
+			glog.Warningf("unexpected error writing pending changes: %v", err)
+		}
+	})
+}
+
+// approveChangeHandler approves the revision of the Ingress in the
+// namespace, name and revision query parameters
+func approveChangeHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		namespace, name, revision := q.Get("namespace"), q.Get("name"), q.Get("revision")
+		if namespace == "" || name == "" || revision == "" {
+			http.Error(w, "namespace, name and revision are required", http.StatusBadRequest)
+			return
+		}
+
+		err := ngx.ApproveChange(namespace, name, revision)
+		switch {
+		case apierrors.IsNotFound(err):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case apierrors.IsConflict(err):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			glog.Errorf("unexpected error approving ingress %v/%v: %v", namespace, name, err)
+			http.Error(w, "unable to approve the change", http.StatusInternalServerError)
+		default:
+			glog.Infof("revision %v of ingress %v/%v approved", revision, namespace, name)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
+
+// revisionsHandler returns the revisions of the configuration history and
+// the revision pinned by a rollback
+func revisionsHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := ngx.Revisions()
+		if err != nil {
+			glog.Errorf("unexpected error listing the revisions: %v", err)
+			http.Error(w, "unable to list the revisions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"pinned":    ngx.PinnedRevision(),
+			"revisions": revisions,
+		}); err != nil {
+			glog.Warningf("unexpected error writing the revisions: %v", err)
+		}
+	})
+}
+
+// revisionQuery returns the revision in a query parameter
+func revisionQuery(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("%v must be a revision", name)
+	}
+	return id, nil
+}
+
+// revisionDiffHandler returns the unified diff between the revisions in
+// the from and to query parameters
+func revisionDiffHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, err := revisionQuery(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := revisionQuery(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		diff, err := ngx.DiffRevisions(from, to)
+		switch {
+		case errors.Is(err, history.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			glog.Errorf("unexpected error comparing the revisions %v and %v: %v", from, to, err)
+			http.Error(w, "unable to compare the revisions", http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write(diff)
+		}
+	})
+}
+
+// rollbackHandler restores the revision in the revision query parameter
+func rollbackHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := revisionQuery(r, "revision")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = ngx.Rollback(id)
+		switch {
+		case errors.Is(err, history.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
+
+// releaseHandler resumes the reloads of the model after a rollback
+func releaseHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ngx.ReleaseRollback() {
+			http.Error(w, "there is no rollback", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// canaryErrorStatus returns the status of the errors of the canary weights
+func canaryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, controller.ErrIngressNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, controller.ErrNoCanary):
+		return http.StatusConflict
+	case errors.Is(err, controller.ErrInvalidCanaryWeight):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// canaryWeightHandler returns the weight of the canary of the Ingress in
+// the namespace and ingress query parameters
+func canaryWeightHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		weight, err := ngx.CanaryWeight(r.URL.Query().Get("namespace"), r.URL.Query().Get("ingress"))
+		if err != nil {
+			http.Error(w, err.Error(), canaryErrorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(weight); err != nil {
+			glog.Warningf("unexpected error writing the canary weight: %v", err)
+		}
+	})
+}
+
+// updateCanaryWeightHandler sets the weight of the canary of an Ingress
+// from a JSON body
+func updateCanaryWeightHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var weight controller.CanaryWeight
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCanaryWeightBodySize)).Decode(&weight); err != nil {
+			http.Error(w, fmt.Sprintf("invalid canary weight: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := ngx.SetCanaryWeight(weight); err != nil {
+			glog.Warningf("unexpected error updating the canary of ingress %v/%v: %v", weight.Namespace, weight.Ingress, err)
+			http.Error(w, err.Error(), canaryErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// statusRepairHandler returns the status repair in progress or the last one
+func statusRepairHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last := ngx.LastStatusRepair()
+		if last == nil {
+			http.Error(w, "no status repair was started", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(last); err != nil {
+			glog.Warningf("unexpected error writing the status repair: %v", err)
+		}
+	})
+}
+
+// startStatusRepairHandler starts a repair of the status of the Ingresses
+func startStatusRepairHandler(ngx *controller.NGINXController) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ngx.StartStatusRepair(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func startHTTPServer(port int, mux *http.ServeMux) {
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%v", port),
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      300 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		// the model streams replace the write timeout
+		ConnContext: modeldiff.ConnContext,
+	}
+	glog.Fatal(server.ListenAndServe())
+}
+
 // createApiserverClient creates new Kubernetes Apiserver client. When kubeconfig or apiserverHost param is empty
 // the function assumes that it is running inside a Kubernetes cluster and attempts to
 // discover the Apiserver. Otherwise, it connects to the Apiserver specified.
 //
 // apiserverHost param is in the format of protocol://address:port/pathPrefix, e.g.http://localhost:8001.
 // kubeConfig location of kubeconfig file
-func createApiserverClient(apiserverHost string, kubeConfig string) (*kubernetes.Clientset, error) {
-	cfg, err := buildConfigFromFlags(apiserverHost, kubeConfig)
+func createApiserverClient(apiserverHost, kubeConfig, kubeContext string) (*kubernetes.Clientset, error) {
+	cfg, err := buildConfigFromFlags(apiserverHost, kubeConfig, kubeContext)
 	if err != nil {
 		return nil, err
 	}
@@ -131,6 +629,20 @@ func createApiserverClient(apiserverHost string, kubeConfig string) (*kubernetes
 	return client, nil
 }
 
+// createDynamicClient creates a client for the custom resources not known
+// by the typed clientset, like the DNSEndpoints of external-dns
+func createDynamicClient(apiserverHost, kubeConfig, kubeContext string) (dynamic.Interface, error) {
+	cfg, err := buildConfigFromFlags(apiserverHost, kubeConfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.QPS = defaultQPS
+	cfg.Burst = defaultBurst
+
+	return dynamic.NewForConfig(cfg)
+}
+
 const (
 	// High enough QPS to fit all expected use cases. QPS=0 is not set here, because
 	// client code is overriding it.
@@ -140,12 +652,20 @@ const (
 	defaultBurst = 1e6
 
 	fakeCertificate = "default-fake-certificate"
+
+	// maxValidateBodySize is the maximum size of the manifests validated
+	// in a request
+	maxValidateBodySize = 10 << 20
+
+	// maxCanaryWeightBodySize is the maximum size of the canary weights
+	// updated in a request
+	maxCanaryWeightBodySize = 64 << 10
 )
 
-// buildConfigFromFlags builds REST config based on master URL and kubeconfig path.
-// If both of them are empty then in cluster config is used.
-func buildConfigFromFlags(masterURL, kubeconfigPath string) (*rest.Config, error) {
-	if kubeconfigPath == "" && masterURL == "" {
+// buildConfigFromFlags builds REST config based on master URL, kubeconfig path and context.
+// If all are empty, the in-cluster configuration is used.
+func buildConfigFromFlags(masterURL, kubeconfigPath, kubeContext string) (*rest.Config, error) {
+	if kubeconfigPath == "" && masterURL == "" && kubeContext == "" {
 		kubeconfig, err := rest.InClusterConfig()
 		if err != nil {
 			return nil, err
@@ -154,9 +674,14 @@ func buildConfigFromFlags(masterURL, kubeconfigPath string) (*rest.Config, error
 		return kubeconfig, nil
 	}
 
-	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath},
+	// a context without kubeconfig is searched in $KUBECONFIG and ~/.kube/config
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath}
+	if kubeconfigPath == "" && kubeContext != "" {
+		rules = clientcmd.NewDefaultClientConfigLoadingRules()
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
 		&clientcmd.ConfigOverrides{
+			CurrentContext: kubeContext,
 			ClusterInfo: clientcmdapi.Cluster{
 				Server: masterURL,
 			},
@@ -0,0 +1,47 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeStatusLeader struct {
+	leader bool
+	err    error
+}
+
+func (f fakeStatusLeader) IsStatusLeader() (bool, error) {
+	return f.leader, f.err
+}
+
+func TestLeaderHandler(t *testing.T) {
+	testCases := []struct {
+		name   string
+		leader fakeStatusLeader
+		code   int
+		body   string
+	}{
+		{"leader", fakeStatusLeader{leader: true}, http.StatusOK, `{"leader":true}`},
+		{"not leader", fakeStatusLeader{}, http.StatusOK, `{"leader":false}`},
+		{"disabled", fakeStatusLeader{err: fmt.Errorf("the update of the Ingress status is disabled")}, http.StatusNotFound, "disabled"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			leaderHandler(tc.leader).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/is-leader", nil))
+			if w.Code != tc.code {
+				t.Errorf("expected the status code %v but returned %v", tc.code, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.body) {
+				t.Errorf("expected a body containing %q but returned %q", tc.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// revision lists, compares and restores the configurations kept by a
+// running management-ingress controller started with --config-history-dir.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/history"
+)
+
+const usage = `usage: revision <command> [flags]
+
+commands:
+  list      list the configurations kept by the controller, the newest first
+  diff      show the differences between two revisions
+  rollback  restore a revision and hold the reloads until release
+  release   resume the reloads of the Ingresses after a rollback
+`
+
+// client sends the requests to the status port of the controller
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "rollback":
+		err = rollback(os.Args[2:])
+	case "release":
+		err = release(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		glog.Fatal(err)
+	}
+}
+
+// parse parses the flags of a command, with the flags of the controller
+// connection, and returns the client
+func parse(flags *pflag.FlagSet, args []string) (*client, error) {
+	var (
+		url       = flags.String("url", "http://127.0.0.1:10254", "URL of the status port of the controller.")
+		tokenFile = flags.String("token-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "File with the bearer token used to authenticate.")
+		timeout   = flags.Duration("timeout", 30*time.Second, "Timeout of the request.")
+	)
+
+	flags.AddGoFlagSet(flag.CommandLine)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	token, err := ioutil.ReadFile(*tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error reading token: %v", err)
+	}
+
+	return &client{
+		url:   strings.TrimSuffix(*url, "/"),
+		token: strings.TrimSpace(string(token)),
+		http:  &http.Client{Timeout: *timeout},
+	}, nil
+}
+
+// do sends a request and returns the body of a successful response
+func (c *client) do(method, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, c.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error requesting %v: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %v requesting %v: %v", resp.StatusCode, path, strings.TrimSpace(string(b)))
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func list(args []string) error {
+	c, err := parse(pflag.NewFlagSet("list", pflag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	b, err := c.do(http.MethodGet, "/config/revisions")
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Pinned    int                `json:"pinned"`
+		Revisions []history.Revision `json:"revisions"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tTIMESTAMP\tMODEL\tTRIGGER")
+	for _, rev := range result.Revisions {
+		hash := rev.ModelHash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", rev.ID, rev.Timestamp.Format(time.RFC3339), hash, rev.Trigger)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if result.Pinned > 0 {
+		fmt.Printf("\nthe configuration is pinned to the revision %v until release\n", result.Pinned)
+	}
+	return nil
+}
+
+func diff(args []string) error {
+	var (
+		flags = pflag.NewFlagSet("diff", pflag.ExitOnError)
+
+		from = flags.Int("from", 0, "Revision compared.")
+		to   = flags.Int("to", 0, "Revision compared with --from.")
+	)
+	c, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if *from < 1 || *to < 1 {
+		return fmt.Errorf("--from and --to are required")
+	}
+
+	b, err := c.do(http.MethodGet, fmt.Sprintf("/config/revisions/diff?from=%v&to=%v", *from, *to))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func rollback(args []string) error {
+	var (
+		flags = pflag.NewFlagSet("rollback", pflag.ExitOnError)
+
+		revision = flags.Int("revision", 0, "Revision restored.")
+	)
+	c, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	if *revision < 1 {
+		return fmt.Errorf("--revision is required")
+	}
+
+	if _, err := c.do(http.MethodPost, fmt.Sprintf("/config/rollback?revision=%v", *revision)); err != nil {
+		return err
+	}
+	fmt.Printf("rolling back to the revision %v, the changes of the Ingresses are held until release\n", *revision)
+	return nil
+}
+
+func release(args []string) error {
+	c, err := parse(pflag.NewFlagSet("release", pflag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.do(http.MethodPost, "/config/release"); err != nil {
+		return err
+	}
+	fmt.Println("released, the configuration follows the Ingresses again")
+	return nil
+}
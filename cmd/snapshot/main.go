@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// snapshot exports the routing state of a running management-ingress
+// controller and validates exported snapshots against a target cluster.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/snapshot"
+)
+
+const usage = `usage: snapshot <command> [flags]
+
+commands:
+  export    download the snapshot of a running controller
+  validate  check the objects referenced by a snapshot exist in a cluster
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "validate":
+		err = validate(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		glog.Fatal(err)
+	}
+}
+
+func export(args []string) error {
+	var (
+		flags = pflag.NewFlagSet("export", pflag.ExitOnError)
+
+		url       = flags.String("url", "http://127.0.0.1:10254/model/snapshot", "URL of the controller snapshot endpoint.")
+		tokenFile = flags.String("token-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "File with the bearer token used to authenticate.")
+		output    = flags.String("output", "", "File where the snapshot is written. Defaults to stdout.")
+		timeout   = flags.Duration("timeout", 30*time.Second, "Timeout of the request.")
+	)
+
+	flags.AddGoFlagSet(flag.CommandLine)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	token, err := ioutil.ReadFile(*tokenFile)
+	if err != nil {
+		return fmt.Errorf("unexpected error reading token: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, *url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unexpected error requesting snapshot: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %v requesting snapshot: %v", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	// decode before writing to reject incomplete or incompatible snapshots
+	s, err := snapshot.Read(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return s.Write(os.Stdout)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.Write(f)
+}
+
+func validate(args []string) error {
+	var (
+		flags = pflag.NewFlagSet("validate", pflag.ExitOnError)
+
+		kubeConfigFile = flags.String("kubeconfig", "", "Path to kubeconfig file of the target cluster.")
+		file           = flags.String("file", "", "Snapshot file to validate.")
+	)
+
+	flags.AddGoFlagSet(flag.CommandLine)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("--file is required")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := snapshot.Read(f)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeConfigFile},
+		&clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("unexpected error reading kubeconfig: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("unexpected error creating kubernetes client: %v", err)
+	}
+
+	errs := s.Validate(context.Background(), client)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("snapshot is not valid in the target cluster: %v problem(s) found", len(errs))
+	}
+
+	fmt.Printf("snapshot created %v (release %v) is valid\n", s.Created.Format(time.RFC3339), s.ControllerRelease)
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package accesslog
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// Off disables the access log of the location
+	Off = "off"
+	// Stdout writes the access log to the standard output of NGINX
+	Stdout = "stdout"
+	// Stderr writes the access log to the standard error of NGINX
+	Stderr = "stderr"
+)
+
+// nameRegex matches the names of the files of the access logs, created in
+// the directory of the access log of the controller
+var nameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Config contains the access log of a location
+type Config struct {
+	// Destination is off, stdout, stderr or the name of a file in the
+	// directory of the access log of the controller. Empty for the access
+	// log of the controller
+	Destination string `json:"destination,omitempty"`
+	// Sample is the ratio of the requests logged, with up to four decimals.
+	// Zero if all the requests are logged
+	Sample float64 `json:"sample,omitempty"`
+}
+
+// Enabled returns true if the location does not use the access log of the
+// controller
+func (c Config) Enabled() bool {
+	return c.Destination != "" || c.Sampled()
+}
+
+// Sampled returns true if only a part of the requests is logged
+func (c Config) Sampled() bool {
+	return c.Sample > 0 && c.Sample < 1
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Destination != c2.Destination {
+		return false
+	}
+	if c1.Sample != c2.Sample {
+		return false
+	}
+
+	return true
+}
+
+type accesslog struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new access log annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return accesslog{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to send
+// the access log of the locations to another destination and to log only a
+// sample of their requests
+func (a accesslog) Parse(ing *networking.Ingress) (interface{}, error) {
+	destination, err := parser.GetStringAnnotation("access-log-destination", ing)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	sample, serr := parser.GetStringAnnotation("access-log-sample", ing)
+	if serr != nil && !errors.IsMissingAnnotations(serr) {
+		return &Config{}, serr
+	}
+	if err != nil && serr != nil {
+		return &Config{}, err
+	}
+
+	c := &Config{}
+	switch {
+	case destination == "", destination == Off, destination == Stdout, destination == Stderr:
+		c.Destination = destination
+	case nameRegex.MatchString(destination):
+		c.Destination = destination
+	default:
+		return &Config{}, errors.NewInvalidAnnotationContent("access-log-destination", destination)
+	}
+
+	if sample != "" {
+		ratio, err := strconv.ParseFloat(sample, 64)
+		// NGINX supports percentages with two decimals
+		ratio = math.Round(ratio*10000) / 10000
+		if err != nil || ratio <= 0 || ratio > 1 {
+			return c, errors.NewInvalidAnnotationContent("access-log-sample", sample)
+		}
+		c.Sample = ratio
+	}
+	return c, nil
+}
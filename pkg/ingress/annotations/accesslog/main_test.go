@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package accesslog
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	destination := parser.GetAnnotationWithPrefix("access-log-destination")
+	sample := parser.GetAnnotationWithPrefix("access-log-sample")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{destination: "stdout"}, &Config{Destination: Stdout}, false},
+		{map[string]string{destination: "off"}, &Config{Destination: Off}, false},
+		{map[string]string{destination: "audit"}, &Config{Destination: "audit"}, false},
+		{map[string]string{destination: "/etc/passwd"}, &Config{}, true},
+		{map[string]string{destination: "syslog:server=10.0.0.1"}, &Config{}, true},
+		{map[string]string{sample: "0.01"}, &Config{Sample: 0.01}, false},
+		{map[string]string{sample: "0.123456"}, &Config{Sample: 0.1235}, false},
+		{map[string]string{destination: "health", sample: "0.1"}, &Config{Destination: "health", Sample: 0.1}, false},
+		{map[string]string{sample: "1"}, &Config{Sample: 1}, false},
+		{map[string]string{sample: "0"}, &Config{}, true},
+		{map[string]string{sample: "0.00001"}, &Config{}, true},
+		{map[string]string{sample: "10%"}, &Config{}, true},
+		{map[string]string{destination: "audit", sample: "2"}, &Config{Destination: "audit"}, true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
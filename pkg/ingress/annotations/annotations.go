@@ -17,23 +17,56 @@ limitations under the License.
 package annotations
 
 import (
+	"sort"
+
 	"github.com/golang/glog"
 	"github.com/imdario/mergo"
 
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/accesslog"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/auth"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/authz"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/backup"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/breakglass"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/budget"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/cachepolicy"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/canary"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/certrevocation"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/compression"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/connection"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/costtag"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/customcounters"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/deadline"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/fairness"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/grouprouting"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/locale"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/locationmodifier"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/luafilters"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/outlier"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/profile"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/proxy"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/replayprotection"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/secureupstream"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/serviceaccounts"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/servicemesh"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/sharedcert"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/signedurl"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/slowstart"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/snippet"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/surge"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/tlsheaders"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/unixsocket"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamhashby"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamhashload"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamuri"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/websocket"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/xforwardedprefix"
 	"github.com/stolostron/management-ingress/pkg/ingress/errors"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
@@ -45,17 +78,59 @@ const DeniedKeyName = "Denied"
 // Ingress defines the valid annotations present in one NGINX Ingress rule
 type Ingress struct {
 	metav1.ObjectMeta
-	AuthType             string
-	AuthzType            string
-	ConfigurationSnippet string
-	LocationModifier     string
-	UpstreamHashBy       string
-	UpstreamURI          string
-	Rewrite              rewrite.Config
-	SecureUpstream       secureupstream.Config
-	XForwardedPrefix     bool
-	Proxy                proxy.Config
-	Connection           connection.Config
+	AuthType               string
+	AuthzType              string
+	ConfigurationSnippet   string
+	LocationModifier       string
+	UpstreamHashBy         string
+	UpstreamHashLoadFactor float64
+	SlowStart              int
+	OutlierDetection       outlier.Config
+	UpstreamIdentity       upstreamidentity.Config
+	ServiceMesh            servicemesh.Config
+	IPFamily               ipfamily.Config
+	UpstreamURI            string
+	Rewrite                rewrite.Config
+	SecureUpstream         secureupstream.Config
+	XForwardedPrefix       bool
+	Proxy                  proxy.Config
+	Connection             connection.Config
+	Budget                 budget.Config
+	Backup                 backup.Config
+	LuaFilters             []string
+	CustomCounters         []customcounters.Counter
+	AllowedServiceAccounts []string
+	TLSHeaders             []string
+	Websocket              websocket.Config
+	CostTag                []string
+	DisableCompression     bool
+	CachePolicy            cachepolicy.Config
+	SignedURL              signedurl.Config
+	ReplayProtection       replayprotection.Config
+	Profile                profile.Config
+	SurgeProtection        surge.Config
+	Fairness               fairness.Config
+	Deadline               deadline.Config
+	ClientCertRevocation   certrevocation.Config
+	AccessLog              accesslog.Config
+	Locale                 locale.Config
+	UnixSocket             unixsocket.Config
+	Canary                 canary.Config
+	GroupRouting           grouprouting.Config
+	BreakGlass             breakglass.Config
+	// SharedCertificate is the alias of the certificate of the platform
+	// namespace used by the TLS hosts without a secret
+	SharedCertificate string
+	// Plugins contains the data of the registered annotation plugins, by
+	// plugin name
+	Plugins map[string]interface{}
+	// FailedPlugins are the annotation plugins that failed without a
+	// previous result for the Ingress
+	FailedPlugins []string
+
+	// Errors contains the annotations with invalid values. The parsers
+	// use the default value in their place.
+	Errors []error
 }
 
 // Extractor defines the annotation parsers to be used in the extraction of annotations
@@ -67,17 +142,47 @@ type Extractor struct {
 func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 	return Extractor{
 		map[string]parser.IngressAnnotation{
-			"AuthType":             auth.NewParser(cfg),
-			"AuthzType":            authz.NewParser(cfg),
-			"ConfigurationSnippet": snippet.NewParser(cfg),
-			"SecureUpstream":       secureupstream.NewParser(cfg),
-			"Rewrite":              rewrite.NewParser(cfg),
-			"UpstreamHashBy":       upstreamhashby.NewParser(cfg),
-			"XForwardedPrefix":     xforwardedprefix.NewParser(cfg),
-			"LocationModifier":     locationmodifier.NewParser(cfg),
-			"UpstreamURI":          upstreamuri.NewParser(cfg),
-			"Proxy":                proxy.NewParser(cfg),
-			"Connection":           connection.NewParser(cfg),
+			"AuthType":               auth.NewParser(cfg),
+			"AuthzType":              authz.NewParser(cfg),
+			"ConfigurationSnippet":   snippet.NewParser(cfg),
+			"SecureUpstream":         secureupstream.NewParser(cfg),
+			"Rewrite":                rewrite.NewParser(cfg),
+			"UpstreamHashBy":         upstreamhashby.NewParser(cfg),
+			"UpstreamHashLoadFactor": upstreamhashload.NewParser(cfg),
+			"SlowStart":              slowstart.NewParser(cfg),
+			"OutlierDetection":       outlier.NewParser(cfg),
+			"UpstreamIdentity":       upstreamidentity.NewParser(cfg),
+			"ServiceMesh":            servicemesh.NewParser(cfg),
+			"IPFamily":               ipfamily.NewParser(cfg),
+			"XForwardedPrefix":       xforwardedprefix.NewParser(cfg),
+			"LocationModifier":       locationmodifier.NewParser(cfg),
+			"UpstreamURI":            upstreamuri.NewParser(cfg),
+			"Proxy":                  proxy.NewParser(cfg),
+			"Connection":             connection.NewParser(cfg),
+			"Budget":                 budget.NewParser(cfg),
+			"Backup":                 backup.NewParser(cfg),
+			"LuaFilters":             luafilters.NewParser(cfg),
+			"CustomCounters":         customcounters.NewParser(cfg),
+			"AllowedServiceAccounts": serviceaccounts.NewParser(cfg),
+			"TLSHeaders":             tlsheaders.NewParser(cfg),
+			"Websocket":              websocket.NewParser(cfg),
+			"CostTag":                costtag.NewParser(cfg),
+			"DisableCompression":     compression.NewParser(cfg),
+			"CachePolicy":            cachepolicy.NewParser(cfg),
+			"SignedURL":              signedurl.NewParser(cfg),
+			"ReplayProtection":       replayprotection.NewParser(cfg),
+			"Profile":                profile.NewParser(cfg),
+			"SurgeProtection":        surge.NewParser(cfg),
+			"Fairness":               fairness.NewParser(cfg),
+			"Deadline":               deadline.NewParser(cfg),
+			"ClientCertRevocation":   certrevocation.NewParser(cfg),
+			"SharedCertificate":      sharedcert.NewParser(cfg),
+			"AccessLog":              accesslog.NewParser(cfg),
+			"Locale":                 locale.NewParser(cfg),
+			"UnixSocket":             unixsocket.NewParser(cfg),
+			"Canary":                 canary.NewParser(cfg),
+			"GroupRouting":           grouprouting.NewParser(cfg),
+			"BreakGlass":             breakglass.NewParser(cfg),
 		},
 	}
 }
@@ -97,6 +202,14 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 				continue
 			}
 
+			if errors.IsInvalidContent(err) {
+				pia.Errors = append(pia.Errors, err)
+				if val != nil {
+					data[name] = val
+				}
+				continue
+			}
+
 			if !errors.IsLocationDenied(err) {
 				continue
 			}
@@ -116,10 +229,20 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 		}
 	}
 
+	plugins, errs, failed := plugin.Extract(ing)
+	pia.Errors = append(pia.Errors, errs...)
+
+	// the parsers run in random order
+	sort.Slice(pia.Errors, func(i, j int) bool {
+		return pia.Errors[i].Error() < pia.Errors[j].Error()
+	})
+
 	err := mergo.MapWithOverwrite(pia, data)
 	if err != nil {
 		glog.Errorf("unexpected error merging extracted annotations: %v", err)
 	}
+	pia.Plugins = plugins
+	pia.FailedPlugins = failed
 
 	return pia
 }
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package annotations
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestExtractErrors(t *testing.T) {
+	ec := NewAnnotationExtractor(&resolver.Mock{})
+
+	testCases := []struct {
+		annotations map[string]string
+		errors      int
+	}{
+		{map[string]string{}, 0},
+		{map[string]string{
+			parser.GetAnnotationWithPrefix("auth-type"):       "id-token",
+			parser.GetAnnotationWithPrefix("proxy-body-size"): "8m",
+		}, 0},
+		{map[string]string{
+			parser.GetAnnotationWithPrefix("auth-type"):         "basic",
+			parser.GetAnnotationWithPrefix("location-modifier"): "^~",
+			parser.GetAnnotationWithPrefix("proxy-body-size"):   "8mb",
+		}, 3},
+	}
+
+	for _, testCase := range testCases {
+		ing := &networking.Ingress{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:        "foo",
+				Namespace:   api.NamespaceDefault,
+				Annotations: testCase.annotations,
+			},
+		}
+
+		anns := ec.Extract(ing)
+		if len(anns.Errors) != testCase.errors {
+			t.Errorf("expected %v errors but returned %v: %v", testCase.errors, len(anns.Errors), anns.Errors)
+		}
+	}
+}
+
+func TestExtractKeepsValidValues(t *testing.T) {
+	ec := NewAnnotationExtractor(&resolver.Mock{})
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix("proxy-read-timeout"): "120",
+				parser.GetAnnotationWithPrefix("proxy-body-size"):    "invalid",
+			},
+		},
+	}
+
+	anns := ec.Extract(ing)
+	if anns.Proxy.ReadTimeout != 120 {
+		t.Errorf("expected 120 as read-timeout but returned %v", anns.Proxy.ReadTimeout)
+	}
+	if anns.Proxy.BodySize != "1m" {
+		t.Errorf("expected 1m as body-size but returned %v", anns.Proxy.BodySize)
+	}
+}
@@ -12,7 +12,6 @@ restricted by GSA ADP Schedule Contract with IBM Corp.
 package auth
 
 import (
-	"github.com/pkg/errors"
 	networking "k8s.io/api/networking/v1"
 
 	"github.com/stolostron/management-ingress/pkg/ingress"
@@ -32,9 +31,5 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the upstream servers should use SSL
 func (a at) Parse(ing *networking.Ingress) (interface{}, error) {
-	ca, _ := parser.GetStringAnnotation("auth-type", ing)
-	if ca != ingress.IDToken && ca != ingress.AccessToken {
-		return "", errors.Errorf("Auth type %v is not supported", ca)
-	}
-	return ca, nil
+	return parser.GetEnumAnnotation("auth-type", ing, ingress.IDToken, ingress.AccessToken, ingress.ServiceAccount)
 }
@@ -12,7 +12,6 @@ restricted by GSA ADP Schedule Contract with IBM Corp.
 package authz
 
 import (
-	"github.com/pkg/errors"
 	networking "k8s.io/api/networking/v1"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
@@ -31,9 +30,5 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the upstream servers should use SSL
 func (a at) Parse(ing *networking.Ingress) (interface{}, error) {
-	ca, _ := parser.GetStringAnnotation("authz-type", ing)
-	if ca != "rbac" {
-		return "", errors.Errorf("Authz type %v is not supported", ca)
-	}
-	return ca, nil
+	return parser.GetEnumAnnotation("authz-type", ing, "rbac")
 }
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package backup
+
+import (
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// Config contains the Service, in the namespace of the Ingress, used when
+// the backends of the Ingress do not accept connections
+type Config struct {
+	Service string `json:"service,omitempty"`
+	Port    int    `json:"port,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Service != c2.Service {
+		return false
+	}
+	if c1.Port != c2.Port {
+		return false
+	}
+
+	return true
+}
+
+type backup struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new backup service annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return backup{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to define the backup service, with the format <name>:<port>
+func (a backup) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("backup-service", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(val, ":")
+	if len(parts) != 2 || len(validation.IsDNS1035Label(parts[0])) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("backup-service", val)
+	}
+
+	port, err := strconv.Atoi(parts[1])
+	if err != nil || len(validation.IsValidPortNum(port)) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("backup-service", val)
+	}
+
+	return &Config{Service: parts[0], Port: port}, nil
+}
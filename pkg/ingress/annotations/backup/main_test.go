@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package backup
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("backup-service")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{annotation: "maintenance:8080"}, &Config{Service: "maintenance", Port: 8080}, false},
+		{map[string]string{annotation: "maintenance"}, nil, true},
+		{map[string]string{annotation: "maintenance:http"}, nil, true},
+		{map[string]string{annotation: "maintenance:0"}, nil, true},
+		{map[string]string{annotation: "Maintenance:80"}, nil, true},
+		{map[string]string{annotation: "other/maintenance:80"}, nil, true},
+		{map[string]string{}, nil, false},
+		{nil, nil, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package breakglass
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// ClientCertificate accepts the client certificates issued by the CAs
+	// in the ca.crt key of the Secret
+	ClientCertificate = "client-certificate"
+	// Token accepts the bearer token in the token key of the Secret
+	Token = "token"
+)
+
+// Config contains the alternate authentication of a location, accepted
+// while the OIDC issuer is unreachable
+type Config struct {
+	// Method is client-certificate or token
+	Method string `json:"method,omitempty"`
+	// Secret is the <namespace>/<name> of the Secret with the CAs or the
+	// token
+	Secret string `json:"secret,omitempty"`
+}
+
+// Enabled returns true if the location has a break-glass authentication
+func (c Config) Enabled() bool {
+	return c.Method != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Method != c2.Method {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+
+	return true
+}
+
+type breakglass struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new break-glass authentication annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return breakglass{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to accept
+// an alternate authentication while the OIDC issuer is unreachable. The
+// Secret is in the namespace of the Ingress, and the location must have an
+// auth-type.
+func (a breakglass) Parse(ing *networking.Ingress) (interface{}, error) {
+	method, err := parser.GetEnumAnnotation("break-glass-auth", ing, ClientCertificate, Token)
+	if err != nil {
+		return &Config{}, err
+	}
+	if _, err := parser.GetStringAnnotation("auth-type", ing); err != nil {
+		return &Config{}, errors.NewInvalidAnnotationContent("break-glass-auth", method)
+	}
+
+	name, err := parser.GetStringAnnotation("break-glass-secret", ing)
+	if err != nil {
+		return &Config{}, errors.NewInvalidAnnotationContent("break-glass-secret", "")
+	}
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return &Config{}, errors.NewInvalidAnnotationContent("break-glass-secret", name)
+	}
+
+	return &Config{
+		Method: method,
+		Secret: fmt.Sprintf("%v/%v", ing.Namespace, name),
+	}, nil
+}
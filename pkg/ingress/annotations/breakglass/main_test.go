@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package breakglass
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	method := parser.GetAnnotationWithPrefix("break-glass-auth")
+	secret := parser.GetAnnotationWithPrefix("break-glass-secret")
+	authType := parser.GetAnnotationWithPrefix("auth-type")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		err         bool
+	}{
+		{map[string]string{authType: "access-token", method: "token", secret: "console-break-glass"},
+			&Config{Method: Token, Secret: "default/console-break-glass"}, false},
+		{map[string]string{authType: "id-token", method: "client-certificate", secret: "admin-ca"},
+			&Config{Method: ClientCertificate, Secret: "default/admin-ca"}, false},
+		{map[string]string{method: "token", secret: "console-break-glass"}, &Config{}, true},
+		{map[string]string{authType: "access-token", method: "password", secret: "console-break-glass"}, &Config{}, true},
+		{map[string]string{authType: "access-token", method: "token"}, &Config{}, true},
+		{map[string]string{authType: "access-token", method: "token", secret: "default/console"}, &Config{}, true},
+		{nil, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !reflect.DeepEqual(p, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package budget
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// Config contains the latency and response size budgets of a location.
+// Zero disables a budget.
+type Config struct {
+	// Latency is the maximum time in milliseconds to serve a request
+	Latency int `json:"latency,omitempty"`
+	// ResponseSize is the maximum number of bytes of a response body
+	ResponseSize int64 `json:"responseSize,omitempty"`
+}
+
+// Enabled returns true if any of the budgets is set
+func (c Config) Enabled() bool {
+	return c.Latency > 0 || c.ResponseSize > 0
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Latency != c2.Latency {
+		return false
+	}
+	if c1.ResponseSize != c2.ResponseSize {
+		return false
+	}
+
+	return true
+}
+
+type budget struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new latency and response size budget annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return budget{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// declare the latency and response size budgets. Invalid values disable
+// the budget and the invalid annotations are returned as error.
+func (a budget) Parse(ing *networking.Ingress) (interface{}, error) {
+	var invalid error
+	check := func(err error) bool {
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	c := &Config{}
+	if d, err := parser.GetDurationAnnotation("latency-budget", ing); check(err) {
+		c.Latency = int((d + time.Millisecond - 1) / time.Millisecond)
+	}
+	if s, err := parser.GetSizeAnnotation("max-response-size", ing); check(err) {
+		c.ResponseSize = bytes(s)
+	}
+
+	return c, invalid
+}
+
+// bytes converts a valid NGINX size to bytes
+func bytes(s string) int64 {
+	mult := int64(1)
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	case "g":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n * mult
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package budget
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	latency := parser.GetAnnotationWithPrefix("latency-budget")
+	size := parser.GetAnnotationWithPrefix("max-response-size")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{latency: "2"}, &Config{Latency: 2000}, false},
+		{map[string]string{latency: "250ms"}, &Config{Latency: 250}, false},
+		{map[string]string{size: "512"}, &Config{ResponseSize: 512}, false},
+		{map[string]string{size: "1m", latency: "1.5s"}, &Config{Latency: 1500, ResponseSize: 1 << 20}, false},
+		{map[string]string{size: "10K"}, &Config{ResponseSize: 10 << 10}, false},
+		{map[string]string{size: "1x", latency: "1s"}, &Config{Latency: 1000}, true},
+		{map[string]string{latency: "soon"}, &Config{}, true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cachepolicy
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+var cacheControlRegex = regexp.MustCompile(`^[A-Za-z0-9=, _-]+$`)
+
+// Config contains the cache headers policy of a location
+type Config struct {
+	// CacheControl is the Cache-Control directives of the responses
+	CacheControl string `json:"cacheControl,omitempty"`
+	// AppendCacheControl appends CacheControl to the directives of the
+	// backend instead of replacing them
+	AppendCacheControl bool `json:"appendCacheControl,omitempty"`
+	// StripETag removes the ETag of the backend responses
+	StripETag bool `json:"stripETag,omitempty"`
+	// StripSetCookie removes the cookies set by the backend
+	StripSetCookie bool `json:"stripSetCookie,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.CacheControl != c2.CacheControl {
+		return false
+	}
+	if c1.AppendCacheControl != c2.AppendCacheControl {
+		return false
+	}
+	if c1.StripETag != c2.StripETag {
+		return false
+	}
+	if c1.StripSetCookie != c2.StripSetCookie {
+		return false
+	}
+
+	return true
+}
+
+type cachepolicy struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new cache policy annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return cachepolicy{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the cache headers of the responses. Invalid values are ignored
+// and the invalid annotations are returned as error.
+func (a cachepolicy) Parse(ing *networking.Ingress) (interface{}, error) {
+	var invalid error
+	check := func(err error) bool {
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	c := &Config{}
+	if v, err := parser.GetStringAnnotation("cache-control", ing); check(err) {
+		v = strings.TrimSpace(v)
+		if cacheControlRegex.MatchString(v) {
+			c.CacheControl = v
+		} else {
+			check(errors.NewInvalidAnnotationContent("cache-control", v))
+		}
+	}
+	if v, err := parser.GetStringAnnotation("cache-control-mode", ing); check(err) {
+		switch v {
+		case "override":
+		case "append":
+			c.AppendCacheControl = true
+		default:
+			check(errors.NewInvalidAnnotationContent("cache-control-mode", v))
+		}
+	}
+	if v, err := parser.GetStringAnnotation("etag", ing); check(err) {
+		switch v {
+		case "keep":
+		case "strip":
+			c.StripETag = true
+		default:
+			check(errors.NewInvalidAnnotationContent("etag", v))
+		}
+	}
+	if v, err := parser.GetBoolAnnotation("strip-set-cookie", ing); check(err) {
+		c.StripSetCookie = v
+	}
+	// there is nothing to append without directives
+	if c.CacheControl == "" {
+		c.AppendCacheControl = false
+	}
+
+	return c, invalid
+}
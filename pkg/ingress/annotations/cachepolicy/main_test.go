@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cachepolicy
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	cacheControl := parser.GetAnnotationWithPrefix("cache-control")
+	mode := parser.GetAnnotationWithPrefix("cache-control-mode")
+	etag := parser.GetAnnotationWithPrefix("etag")
+	cookie := parser.GetAnnotationWithPrefix("strip-set-cookie")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{cacheControl: "public, max-age=3600"}, &Config{CacheControl: "public, max-age=3600"}, false},
+		{map[string]string{cacheControl: "s-maxage=600", mode: "append"}, &Config{CacheControl: "s-maxage=600", AppendCacheControl: true}, false},
+		{map[string]string{etag: "strip", cookie: "true"}, &Config{StripETag: true, StripSetCookie: true}, false},
+		{map[string]string{etag: "keep"}, &Config{}, false},
+		{map[string]string{mode: "append"}, &Config{}, false},
+		{map[string]string{cacheControl: `no-cache"; add_header X y`}, &Config{}, true},
+		{map[string]string{cacheControl: "no-store", mode: "merge"}, &Config{CacheControl: "no-store"}, true},
+		{map[string]string{etag: "weak"}, &Config{}, true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package canary
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+var (
+	headerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	valueRegex  = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+)
+
+// Config contains the canary backend of the locations of an Ingress and
+// the share of the requests sent to it
+type Config struct {
+	Service string `json:"service"`
+	Port    int    `json:"port"`
+	// Weight is the percentage of the requests sent to the canary
+	Weight int `json:"weight"`
+	// Header and HeaderValue route the requests with the value in the
+	// header to the canary, whatever the weight
+	Header      string `json:"header,omitempty"`
+	HeaderValue string `json:"headerValue,omitempty"`
+	// Upstream is the name of the upstream of the canary. Empty while the
+	// canary Service is not available
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Enabled returns true if the Ingress has a canary backend
+func (c Config) Enabled() bool {
+	return c.Service != ""
+}
+
+// Equal tests for equality between two Config types. The weight and the
+// header route are applied by NGINX without a reload, so they are not
+// compared.
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Service != c2.Service {
+		return false
+	}
+	if c1.Port != c2.Port {
+		return false
+	}
+	if c1.Upstream != c2.Upstream {
+		return false
+	}
+
+	return true
+}
+
+// ValidWeight returns an error if the weight is not a percentage
+func ValidWeight(weight int) error {
+	if weight < 0 || weight > 100 {
+		return fmt.Errorf("the weight %v is not between 0 and 100", weight)
+	}
+	return nil
+}
+
+// ParseHeaderRoute returns the header and the value of a header route, as
+// <header>=<value>
+func ParseHeaderRoute(route string) (string, string, error) {
+	parts := strings.SplitN(route, "=", 2)
+	if len(parts) != 2 || !headerRegex.MatchString(parts[0]) || !valueRegex.MatchString(parts[1]) {
+		return "", "", fmt.Errorf("the header route %q is not <header>=<value>", route)
+	}
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+type canary struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new canary annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return canary{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to send
+// a share of the requests of the locations to a canary Service in the
+// namespace of the Ingress
+func (a canary) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("canary-backend", ing)
+	if err != nil {
+		return nil, err
+	}
+	backend := strings.Split(val, ":")
+	if len(backend) != 2 || len(validation.IsDNS1035Label(backend[0])) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("canary-backend", val)
+	}
+	port, err := strconv.Atoi(backend[1])
+	if err != nil || len(validation.IsValidPortNum(port)) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("canary-backend", val)
+	}
+	c := &Config{Service: backend[0], Port: port}
+
+	weight, err := parser.GetIntAnnotation("canary-weight", ing)
+	if err == nil && ValidWeight(weight) != nil {
+		return c, errors.NewInvalidAnnotationContent("canary-weight", weight)
+	}
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return c, err
+	}
+	c.Weight = weight
+
+	route, err := parser.GetStringAnnotation("canary-header", ing)
+	if err == nil {
+		c.Header, c.HeaderValue, err = ParseHeaderRoute(route)
+		if err != nil {
+			return c, errors.NewInvalidAnnotationContent("canary-header", route)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package canary
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	backend := parser.GetAnnotationWithPrefix("canary-backend")
+	weight := parser.GetAnnotationWithPrefix("canary-weight")
+	header := parser.GetAnnotationWithPrefix("canary-header")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		err         bool
+	}{
+		{map[string]string{backend: "console-canary:3000"}, &Config{Service: "console-canary", Port: 3000}, false},
+		{map[string]string{backend: "console-canary:3000", weight: "25", header: "X-Canary=always"},
+			&Config{Service: "console-canary", Port: 3000, Weight: 25, Header: "x-canary", HeaderValue: "always"}, false},
+		{map[string]string{backend: "console-canary:3000", weight: "101"}, &Config{Service: "console-canary", Port: 3000}, true},
+		{map[string]string{backend: "console-canary:3000", header: "X-Canary"}, &Config{Service: "console-canary", Port: 3000}, true},
+		{map[string]string{backend: "console-canary"}, nil, true},
+		{map[string]string{weight: "25"}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !reflect.DeepEqual(p, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c1 := &Config{Service: "console-canary", Port: 3000, Weight: 10, Upstream: "default-console-canary-3000"}
+	c2 := &Config{Service: "console-canary", Port: 3000, Weight: 50, Header: "x-canary", HeaderValue: "always", Upstream: "default-console-canary-3000"}
+	if !c1.Equal(c2) {
+		t.Errorf("expected the weight and the header route to be ignored")
+	}
+	c2.Upstream = ""
+	if c1.Equal(c2) {
+		t.Errorf("expected the upstream to be compared")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package certrevocation
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// SoftFail accepts the client certificates whose revocation status is
+	// unknown
+	SoftFail = "soft-fail"
+	// HardFail rejects the client certificates whose revocation status is
+	// unknown
+	HardFail = "hard-fail"
+)
+
+// Config contains the revocation checks of the client certificates of a
+// location
+type Config struct {
+	// Secret is the <namespace>/<name> of the secret with the CAs and the
+	// CRLs, empty if the certificates are not checked
+	Secret string `json:"secret,omitempty"`
+	// OCSP is true if the OCSP responders of the certificates are checked
+	OCSP bool `json:"ocsp,omitempty"`
+	// Policy is soft-fail or hard-fail
+	Policy string `json:"policy,omitempty"`
+}
+
+// Enabled returns true if the client certificates are checked
+func (c Config) Enabled() bool {
+	return c.Secret != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.OCSP != c2.OCSP {
+		return false
+	}
+	if c1.Policy != c2.Policy {
+		return false
+	}
+
+	return true
+}
+
+type certrevocation struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new client certificate revocation annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return certrevocation{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to check
+// the revocation of the client certificates. The CAs and the CRLs are read
+// from a secret in the namespace of the Ingress.
+func (a certrevocation) Parse(ing *networking.Ingress) (interface{}, error) {
+	name, err := parser.GetStringAnnotation("client-cert-revocation-secret", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return &Config{}, errors.NewInvalidAnnotationContent("client-cert-revocation-secret", name)
+	}
+
+	c := &Config{Secret: fmt.Sprintf("%v/%v", ing.Namespace, name), Policy: SoftFail}
+	var invalid error
+	if ocsp, err := parser.GetBoolAnnotation("client-cert-ocsp", ing); err == nil {
+		c.OCSP = ocsp
+	} else if errors.IsInvalidContent(err) {
+		invalid = err
+	}
+	if policy, err := parser.GetEnumAnnotation("client-cert-revocation-policy", ing, SoftFail, HardFail); err == nil {
+		c.Policy = policy
+	} else if errors.IsInvalidContent(err) {
+		invalid = errors.AppendInvalidContent(invalid, err)
+	}
+
+	return c, invalid
+}
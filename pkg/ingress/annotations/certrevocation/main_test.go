@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package certrevocation
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	secret := parser.GetAnnotationWithPrefix("client-cert-revocation-secret")
+	ocsp := parser.GetAnnotationWithPrefix("client-cert-ocsp")
+	policy := parser.GetAnnotationWithPrefix("client-cert-revocation-policy")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{secret: "cluster-ca"}, &Config{Secret: "default/cluster-ca", Policy: SoftFail}, false},
+		{map[string]string{secret: "cluster-ca", ocsp: "true", policy: "hard-fail"}, &Config{Secret: "default/cluster-ca", OCSP: true, Policy: HardFail}, false},
+		{map[string]string{secret: "cluster-ca", policy: "ignore"}, &Config{Secret: "default/cluster-ca", Policy: SoftFail}, true},
+		{map[string]string{secret: "cluster-ca", ocsp: "yes please"}, &Config{Secret: "default/cluster-ca", Policy: SoftFail}, true},
+		{map[string]string{secret: "other/cluster-ca"}, &Config{}, true},
+		{map[string]string{ocsp: "true"}, &Config{}, false},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package compression
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type compression struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new compression annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return compression{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used
+// to disable the compression of the responses in the locations
+func (a compression) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parser.GetBoolAnnotation("disable-compression", ing)
+}
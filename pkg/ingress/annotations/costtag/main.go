@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package costtag
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+var (
+	headerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	claimRegex  = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+)
+
+type costtag struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new cost tag annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return costtag{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the sources of the cost attribution tag of the requests, tried
+// in order: namespace, header:<name> or claim:<name>
+func (a costtag) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("cost-tag", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []string
+	for _, s := range strings.Split(val, "|") {
+		s = strings.TrimSpace(s)
+		kind, name := s, ""
+		if i := strings.Index(s, ":"); i >= 0 {
+			kind, name = s[:i], s[i+1:]
+		}
+
+		switch {
+		case kind == "namespace" && name == "":
+		case kind == "header" && headerRegex.MatchString(name):
+			s = kind + ":" + strings.ToLower(name)
+		case kind == "claim" && claimRegex.MatchString(name):
+		default:
+			return nil, errors.NewInvalidAnnotationContent("cost-tag", val)
+		}
+		sources = append(sources, s)
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package costtag
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("cost-tag")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{map[string]string{annotation: "namespace"}, []string{"namespace"}, false},
+		{map[string]string{annotation: "header:X-Tenant | claim:tenant_id | namespace"}, []string{"header:x-tenant", "claim:tenant_id", "namespace"}, false},
+		{map[string]string{annotation: "claim:https://example.com/project"}, nil, true},
+		{map[string]string{annotation: "header:"}, nil, true},
+		{map[string]string{annotation: "namespace:default"}, nil, true},
+		{map[string]string{annotation: "cookie:tenant"}, nil, true},
+		{map[string]string{}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		names, _ := i.([]string)
+
+		if !reflect.DeepEqual(names, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, names, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package customcounters
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+var (
+	nameRegex   = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	statusRegex = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+	methodRegex = regexp.MustCompile(`^[A-Z]+$`)
+	headerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// Counter counts the requests matching all the conditions. Empty
+// conditions match every request.
+type Counter struct {
+	Name string `json:"name"`
+	// Status is the status code, or the first digits of the status code
+	// when the annotation uses a class like 2xx
+	Status string `json:"status,omitempty"`
+	Method string `json:"method,omitempty"`
+	// Header is the lower case name of a request header that must be present
+	Header string `json:"header,omitempty"`
+}
+
+type customcounters struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new custom counters annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return customcounters{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define counters with the format <name>=<condition>&<condition>,... where
+// the conditions are status:<code or class>, method:<method> and
+// header:<name>
+func (a customcounters) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("custom-counters", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := errors.NewInvalidAnnotationContent("custom-counters", val)
+
+	var counters []Counter
+	names := map[string]bool{}
+	for _, def := range strings.Split(val, ",") {
+		parts := strings.SplitN(strings.TrimSpace(def), "=", 2)
+		if len(parts) != 2 || !nameRegex.MatchString(parts[0]) || names[parts[0]] {
+			return nil, invalid
+		}
+		names[parts[0]] = true
+
+		c := Counter{Name: parts[0]}
+		for _, cond := range strings.Split(parts[1], "&") {
+			kv := strings.SplitN(cond, ":", 2)
+			if len(kv) != 2 {
+				return nil, invalid
+			}
+
+			switch kv[0] {
+			case "status":
+				if c.Status != "" || !statusRegex.MatchString(kv[1]) {
+					return nil, invalid
+				}
+				c.Status = strings.TrimSuffix(kv[1], "xx")
+			case "method":
+				if c.Method != "" || !methodRegex.MatchString(kv[1]) {
+					return nil, invalid
+				}
+				c.Method = kv[1]
+			case "header":
+				if c.Header != "" || !headerRegex.MatchString(kv[1]) {
+					return nil, invalid
+				}
+				c.Header = strings.ToLower(kv[1])
+			default:
+				return nil, invalid
+			}
+		}
+
+		counters = append(counters, c)
+	}
+
+	return counters, nil
+}
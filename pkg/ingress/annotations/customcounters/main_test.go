@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package customcounters
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("custom-counters")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []Counter
+		invalid     bool
+	}{
+		{map[string]string{annotation: "imports=method:POST&status:2xx"},
+			[]Counter{{Name: "imports", Method: "POST", Status: "2"}}, false},
+		{map[string]string{annotation: "imports=header:X-Cluster-Import, not_found=status:404"},
+			[]Counter{{Name: "imports", Header: "x-cluster-import"}, {Name: "not_found", Status: "404"}}, false},
+		{map[string]string{annotation: "imports=status:2xx&status:5xx"}, nil, true},
+		{map[string]string{annotation: "imports=status:6xx"}, nil, true},
+		{map[string]string{annotation: "imports=method:post"}, nil, true},
+		{map[string]string{annotation: "imports=query:a"}, nil, true},
+		{map[string]string{annotation: "Imports=status:200"}, nil, true},
+		{map[string]string{annotation: "a=status:200,a=status:500"}, nil, true},
+		{map[string]string{annotation: "imports"}, nil, true},
+		{map[string]string{}, nil, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		counters, _ := i.([]Counter)
+
+		if !reflect.DeepEqual(counters, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, counters, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package deadline
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// RequestDeadline sends the remaining time in milliseconds in the
+	// X-Request-Deadline header
+	RequestDeadline = "x-request-deadline"
+	// GRPCTimeout sends the remaining time in the grpc-timeout header
+	GRPCTimeout = "grpc-timeout"
+)
+
+// Config contains the header used to send the remaining time of the
+// request to the backend
+type Config struct {
+	// Header is x-request-deadline or grpc-timeout, empty if disabled
+	Header string `json:"header,omitempty"`
+}
+
+// Enabled returns true if the remaining time is sent to the backend
+func (c Config) Enabled() bool {
+	return c.Header != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Header != c2.Header {
+		return false
+	}
+
+	return true
+}
+
+type deadline struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new deadline header annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return deadline{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to send
+// the remaining time of the requests to the backend
+func (a deadline) Parse(ing *networking.Ingress) (interface{}, error) {
+	header, err := parser.GetEnumAnnotation("deadline-header", ing, RequestDeadline, GRPCTimeout)
+	if err != nil {
+		return &Config{}, err
+	}
+	return &Config{Header: header}, nil
+}
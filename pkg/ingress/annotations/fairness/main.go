@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package fairness
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// ClientIP identifies the clients by their address
+	ClientIP = "ip"
+	// Subject identifies the clients by the authenticated ServiceAccount, or
+	// the user of their bearer token reviewed by the API server, and by their
+	// address when the request has neither
+	Subject = "subject"
+)
+
+// Config contains the limit of the concurrent requests of a client in a
+// location, so one client can not use all the capacity of the backend
+type Config struct {
+	// MaxRequests is the maximum number of requests in progress of the
+	// same client, zero if disabled
+	MaxRequests int `json:"maxRequests,omitempty"`
+	// Key is how the clients are identified, ip or subject
+	Key string `json:"key,omitempty"`
+}
+
+// Enabled returns true if the limit is set
+func (c Config) Enabled() bool {
+	return c.MaxRequests > 0
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.MaxRequests != c2.MaxRequests {
+		return false
+	}
+	if c1.Key != c2.Key {
+		return false
+	}
+
+	return true
+}
+
+type fairness struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new fairness limit annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return fairness{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// limit the concurrent requests per client. An invalid limit disables it,
+// an invalid key uses the client address.
+func (a fairness) Parse(ing *networking.Ingress) (interface{}, error) {
+	max, err := parser.GetIntAnnotation("max-requests-per-client", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+	if max <= 0 {
+		return &Config{}, errors.NewInvalidAnnotationContent("max-requests-per-client", max)
+	}
+
+	c := &Config{MaxRequests: max, Key: ClientIP}
+	key, err := parser.GetEnumAnnotation("fairness-key", ing, ClientIP, Subject)
+	if err == nil {
+		c.Key = key
+	} else if errors.IsInvalidContent(err) {
+		return c, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package grouprouting
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// deny is the target of the routes rejecting the requests
+const deny = "deny"
+
+// authTypes are the auth types authenticating the caller, the values of
+// ingress.IDToken, ingress.AccessToken and ingress.ServiceAccount
+var authTypes = []string{"id-token", "access-token", "service-account"}
+
+var groupRegex = regexp.MustCompile(`^[A-Za-z0-9:._@-]+$`)
+
+// Route sends the requests of the callers in a group to a Service, in the
+// namespace of the Ingress, or rejects them
+type Route struct {
+	Group   string `json:"group"`
+	Service string `json:"service,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Deny    bool   `json:"deny,omitempty"`
+	// Upstream is the name of the upstream of the Service. Empty while the
+	// Service is not available, the requests then go to the backend of the
+	// location
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Config contains the routes of the locations selected by the groups of
+// the authenticated caller, reviewed by the API server. The first route of
+// a group of the caller is used, and the requests without one go to the
+// backend of the location.
+type Config struct {
+	Routes []Route `json:"routes,omitempty"`
+}
+
+// Enabled returns true if the locations have group routes
+func (c Config) Enabled() bool {
+	return len(c.Routes) > 0
+}
+
+// HasUpstreams returns true if a route sends the requests to a Service
+func (c Config) HasUpstreams() bool {
+	for _, r := range c.Routes {
+		if r.Upstream != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if len(c1.Routes) != len(c2.Routes) {
+		return false
+	}
+	for i := range c1.Routes {
+		if c1.Routes[i] != c2.Routes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type grouprouting struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new group routing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return grouprouting{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to route
+// the requests by the groups of the authenticated caller, reviewed by the
+// API server. The routes have the format <group>=<service>:<port> or
+// <group>=deny, comma separated, and require an auth-type. The groups are
+// not read from the claims of the token: the group-claim annotation is
+// rejected.
+func (a grouprouting) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("group-routes", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+	if _, err := parser.GetEnumAnnotation("auth-type", ing, authTypes...); err != nil {
+		return &Config{}, errors.NewInvalidAnnotationContent("group-routes", val)
+	}
+	if claim, err := parser.GetStringAnnotation("group-claim", ing); err == nil {
+		return &Config{}, errors.NewInvalidAnnotationContent("group-claim", claim)
+	}
+
+	c := &Config{}
+
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(val, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || !groupRegex.MatchString(parts[0]) || seen[parts[0]] {
+			return &Config{}, errors.NewInvalidAnnotationContent("group-routes", val)
+		}
+		seen[parts[0]] = true
+
+		if parts[1] == deny {
+			c.Routes = append(c.Routes, Route{Group: parts[0], Deny: true})
+			continue
+		}
+		backend := strings.Split(parts[1], ":")
+		if len(backend) != 2 || len(validation.IsDNS1035Label(backend[0])) > 0 {
+			return &Config{}, errors.NewInvalidAnnotationContent("group-routes", val)
+		}
+		port, err := strconv.Atoi(backend[1])
+		if err != nil || len(validation.IsValidPortNum(port)) > 0 {
+			return &Config{}, errors.NewInvalidAnnotationContent("group-routes", val)
+		}
+		c.Routes = append(c.Routes, Route{Group: parts[0], Service: backend[0], Port: port})
+	}
+	return c, nil
+}
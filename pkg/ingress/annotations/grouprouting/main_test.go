@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package grouprouting
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	routes := parser.GetAnnotationWithPrefix("group-routes")
+	claim := parser.GetAnnotationWithPrefix("group-claim")
+	authType := parser.GetAnnotationWithPrefix("auth-type")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		err         bool
+	}{
+		{map[string]string{authType: "access-token", routes: "system:cluster-admins=admin-console:3000, auditors=deny"},
+			&Config{Routes: []Route{
+				{Group: "system:cluster-admins", Service: "admin-console", Port: 3000},
+				{Group: "auditors", Deny: true},
+			}}, false},
+		{map[string]string{authType: "service-account", routes: "admins=admin-console:3000"},
+			&Config{Routes: []Route{{Group: "admins", Service: "admin-console", Port: 3000}}}, false},
+		{map[string]string{authType: "id-token", routes: "admins=admin-console:3000", claim: "roles"}, &Config{}, true},
+		{map[string]string{authType: "none", routes: "admins=admin-console:3000"}, &Config{}, true},
+		{map[string]string{routes: "admins=admin-console:3000"}, &Config{}, true},
+		{map[string]string{authType: "id-token", routes: "admins=admin-console"}, &Config{}, true},
+		{map[string]string{authType: "id-token", routes: "admins=deny,admins=admin-console:3000"}, &Config{}, true},
+		{nil, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !reflect.DeepEqual(p, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
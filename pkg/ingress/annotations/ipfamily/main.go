@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package ipfamily
+
+import (
+	"net"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// IPv4 restricts to IPv4 addresses
+	IPv4 = "ipv4"
+	// IPv6 restricts to IPv6 addresses
+	IPv6 = "ipv6"
+)
+
+// Config contains the address families of the hosts of an Ingress and of
+// the connections to its backends. Empty families are not restricted.
+type Config struct {
+	// Serve is the family of the addresses the hosts are served on
+	Serve string `json:"serve,omitempty"`
+	// Upstream is the family of the ClusterIP and endpoints of the
+	// backends
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Serve != c2.Serve {
+		return false
+	}
+	if c1.Upstream != c2.Upstream {
+		return false
+	}
+
+	return true
+}
+
+// Matches returns true if the IP address is of the family, or the family
+// is empty
+func Matches(family, ip string) bool {
+	if family == "" {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	return (addr.To4() != nil) == (family == IPv4)
+}
+
+type ipFamily struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new IP family annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return ipFamily{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// restrict the address families of the hosts and of the backends
+func (a ipFamily) Parse(ing *networking.Ingress) (interface{}, error) {
+	serve, err := parser.GetEnumAnnotation("ip-family", ing, IPv4, IPv6)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return Config{}, err
+	}
+	upstream, err := parser.GetEnumAnnotation("upstream-ip-family", ing, IPv4, IPv6)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return Config{}, err
+	}
+	return Config{Serve: serve, Upstream: upstream}, nil
+}
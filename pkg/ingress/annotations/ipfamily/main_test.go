@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package ipfamily
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	serve := parser.GetAnnotationWithPrefix("ip-family")
+	upstream := parser.GetAnnotationWithPrefix("upstream-ip-family")
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		invalid     bool
+	}{
+		{map[string]string{serve: "ipv6"}, Config{Serve: IPv6}, false},
+		{map[string]string{upstream: "ipv4"}, Config{Upstream: IPv4}, false},
+		{map[string]string{serve: "ipv4", upstream: "ipv6"}, Config{Serve: IPv4, Upstream: IPv6}, false},
+		{map[string]string{serve: "dual"}, Config{}, true},
+		{map[string]string{upstream: "IPv6"}, Config{}, true},
+		{map[string]string{}, Config{}, false},
+		{nil, Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		if cfg := i.(Config); cfg != testCase.expected {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, cfg, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	testCases := []struct {
+		family, ip string
+		expected   bool
+	}{
+		{"", "10.0.0.1", true},
+		{IPv4, "10.0.0.1", true},
+		{IPv4, "fd00::1", false},
+		{IPv6, "fd00::1", true},
+		{IPv6, "10.0.0.1", false},
+		{IPv4, "", false},
+	}
+	for _, tc := range testCases {
+		if m := Matches(tc.family, tc.ip); m != tc.expected {
+			t.Errorf("expected %v matching %q with %q but got %v", tc.expected, tc.ip, tc.family, m)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package locale
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// AcceptLanguage is the default header used to select the variant. Its
+// values are matched with the first language of the header.
+const AcceptLanguage = "accept-language"
+
+var (
+	headerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	valueRegex  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Variant is a Service, in the namespace of the Ingress, selected by a
+// value of the header
+type Variant struct {
+	Value   string `json:"value"`
+	Service string `json:"service"`
+	Port    int    `json:"port"`
+}
+
+// Config contains the variants of the backend of the locations selected
+// by a header. The requests without a variant go to the backend of the
+// location.
+type Config struct {
+	// Header is the lowercase name of the header
+	Header   string    `json:"header,omitempty"`
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// Enabled returns true if the locations have variants
+func (c Config) Enabled() bool {
+	return len(c.Variants) > 0
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Header != c2.Header {
+		return false
+	}
+	if len(c1.Variants) != len(c2.Variants) {
+		return false
+	}
+	for i := range c1.Variants {
+		if c1.Variants[i] != c2.Variants[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type locale struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new locale routing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return locale{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to route
+// the requests to a variant of the backend by the value of a header, like
+// the language of Accept-Language. The variants have the format
+// <value>=<service>:<port>, comma separated, and the values are compared
+// without case.
+func (a locale) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("locale-backends", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	c := &Config{Header: AcceptLanguage}
+	header, err := parser.GetStringAnnotation("locale-header", ing)
+	if err == nil {
+		if !headerRegex.MatchString(header) {
+			return &Config{}, errors.NewInvalidAnnotationContent("locale-header", header)
+		}
+		c.Header = strings.ToLower(header)
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !valueRegex.MatchString(parts[0]) || seen[strings.ToLower(parts[0])] {
+			return &Config{}, errors.NewInvalidAnnotationContent("locale-backends", val)
+		}
+		backend := strings.Split(parts[1], ":")
+		if len(backend) != 2 || len(validation.IsDNS1035Label(backend[0])) > 0 {
+			return &Config{}, errors.NewInvalidAnnotationContent("locale-backends", val)
+		}
+		port, err := strconv.Atoi(backend[1])
+		if err != nil || len(validation.IsValidPortNum(port)) > 0 {
+			return &Config{}, errors.NewInvalidAnnotationContent("locale-backends", val)
+		}
+
+		value := strings.ToLower(parts[0])
+		seen[value] = true
+		c.Variants = append(c.Variants, Variant{Value: value, Service: backend[0], Port: port})
+	}
+	return c, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package locale
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	backends := parser.GetAnnotationWithPrefix("locale-backends")
+	header := parser.GetAnnotationWithPrefix("locale-header")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{backends: "fr=console-fr:3000, DE=console-de:3000"}, &Config{
+			Header: AcceptLanguage,
+			Variants: []Variant{
+				{Value: "fr", Service: "console-fr", Port: 3000},
+				{Value: "de", Service: "console-de", Port: 3000},
+			},
+		}, false},
+		{map[string]string{backends: "dark=console-dark:8443", header: "X-Console-Theme"}, &Config{
+			Header:   "x-console-theme",
+			Variants: []Variant{{Value: "dark", Service: "console-dark", Port: 8443}},
+		}, false},
+		{map[string]string{backends: "fr=console-fr:3000", header: "X Theme"}, &Config{}, true},
+		{map[string]string{backends: "fr=console-fr"}, &Config{}, true},
+		{map[string]string{backends: "fr=console-fr:0"}, &Config{}, true},
+		{map[string]string{backends: "fr=Console:3000"}, &Config{}, true},
+		{map[string]string{backends: "fr=a:1,FR=b:2"}, &Config{}, true},
+		{map[string]string{backends: "fr fr=a:1"}, &Config{}, true},
+		{map[string]string{header: "X-Console-Theme"}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -15,7 +15,6 @@ import (
 	networking "k8s.io/api/networking/v1"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
-	"github.com/stolostron/management-ingress/pkg/ingress/errors"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
 )
 
@@ -31,9 +30,5 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the upstream servers should use SSL
 func (a at) Parse(ing *networking.Ingress) (interface{}, error) {
-	ca, _ := parser.GetStringAnnotation("location-modifier", ing)
-	if ca != "~" && ca != "=" && ca != "~*" {
-		return "", errors.Errorf("Location modifier type %v is not supported", ca)
-	}
-	return ca, nil
+	return parser.GetEnumAnnotation("location-modifier", ing, "~", "=", "~*")
 }
@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package luafilters
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/filters"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type luafilters struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new Lua filters annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return luafilters{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the comma separated list of filters run, in order, in the locations
+func (a luafilters) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("lua-filters", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(val, ",") {
+		name = strings.TrimSpace(name)
+		if !filters.NameRegex.MatchString(name) {
+			return nil, errors.NewInvalidAnnotationContent("lua-filters", val)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
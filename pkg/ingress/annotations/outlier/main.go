@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package outlier
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultEjectionTime        = 30
+	defaultMaxEjectionPercent  = 10
+)
+
+// Config contains the outlier detection of a backend. The endpoints with
+// consecutive failures are ejected from the balancing for a while.
+type Config struct {
+	// Enabled is true if the outlier detection is enabled
+	Enabled bool `json:"enabled,omitempty"`
+	// ConsecutiveFailures is the number of consecutive failed requests
+	// that ejects an endpoint
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// MaxLatency is the time in milliseconds after which a response counts
+	// as a failure, zero if the latency is not checked
+	MaxLatency int `json:"maxLatency,omitempty"`
+	// EjectionTime is the time in seconds an endpoint is ejected
+	EjectionTime int `json:"ejectionTime,omitempty"`
+	// MaxEjectionPercent is the maximum percentage of the endpoints
+	// ejected at the same time. One endpoint can always be ejected when
+	// the backend has several.
+	MaxEjectionPercent int `json:"maxEjectionPercent,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.ConsecutiveFailures != c2.ConsecutiveFailures {
+		return false
+	}
+	if c1.MaxLatency != c2.MaxLatency {
+		return false
+	}
+	if c1.EjectionTime != c2.EjectionTime {
+		return false
+	}
+	if c1.MaxEjectionPercent != c2.MaxEjectionPercent {
+		return false
+	}
+
+	return true
+}
+
+type outlier struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new outlier detection annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return outlier{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to eject
+// the endpoints of the backend that fail consecutive requests, with a 5xx
+// status or a response slower than the maximum latency. Invalid values
+// keep the default and the invalid annotations are returned as error.
+func (a outlier) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation("outlier-detection", ing)
+	if err != nil || !enabled {
+		return &Config{}, err
+	}
+
+	var invalid error
+	check := func(name string, v int, err error) bool {
+		if err == nil && v <= 0 {
+			err = errors.NewInvalidAnnotationContent(name, v)
+		}
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	c := &Config{
+		Enabled:             true,
+		ConsecutiveFailures: defaultConsecutiveFailures,
+		EjectionTime:        defaultEjectionTime,
+		MaxEjectionPercent:  defaultMaxEjectionPercent,
+	}
+	if v, err := parser.GetIntAnnotation("outlier-consecutive-failures", ing); check("outlier-consecutive-failures", v, err) {
+		c.ConsecutiveFailures = v
+	}
+	if d, err := parser.GetDurationAnnotation("outlier-max-latency", ing); check("outlier-max-latency", int(d), err) {
+		c.MaxLatency = int((d + time.Millisecond - 1) / time.Millisecond)
+	}
+	if d, err := parser.GetDurationAnnotation("outlier-ejection-time", ing); check("outlier-ejection-time", int(d), err) {
+		c.EjectionTime = int((d + time.Second - 1) / time.Second)
+	}
+	if v, err := parser.GetIntAnnotation("outlier-max-ejection-percent", ing); check("outlier-max-ejection-percent", v, err) {
+		if v > 100 {
+			v = 100
+		}
+		c.MaxEjectionPercent = v
+	}
+
+	return c, invalid
+}
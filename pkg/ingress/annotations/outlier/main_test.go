@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package outlier
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enabled := parser.GetAnnotationWithPrefix("outlier-detection")
+	failures := parser.GetAnnotationWithPrefix("outlier-consecutive-failures")
+	latency := parser.GetAnnotationWithPrefix("outlier-max-latency")
+	ejection := parser.GetAnnotationWithPrefix("outlier-ejection-time")
+	percent := parser.GetAnnotationWithPrefix("outlier-max-ejection-percent")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	defaults := &Config{Enabled: true, ConsecutiveFailures: 5, EjectionTime: 30, MaxEjectionPercent: 10}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{enabled: "true"}, defaults, false},
+		{map[string]string{enabled: "false", failures: "3"}, &Config{}, false},
+		{map[string]string{failures: "3"}, &Config{}, false},
+		{map[string]string{enabled: "true", failures: "3", latency: "1500ms", ejection: "1m", percent: "50"},
+			&Config{Enabled: true, ConsecutiveFailures: 3, MaxLatency: 1500, EjectionTime: 60, MaxEjectionPercent: 50}, false},
+		{map[string]string{enabled: "true", percent: "200"}, &Config{Enabled: true, ConsecutiveFailures: 5, EjectionTime: 30, MaxEjectionPercent: 100}, false},
+		{map[string]string{enabled: "true", failures: "0"}, defaults, true},
+		{map[string]string{enabled: "true", latency: "slow"}, defaults, true},
+		{map[string]string{enabled: "maybe"}, &Config{}, true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
@@ -27,7 +27,11 @@ package parser
 
 import (
 	"fmt"
+	"net"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	networking "k8s.io/api/networking/v1"
 
@@ -78,6 +82,81 @@ func (a ingAnnotations) parseInt(name string) (int, error) {
 	return 0, errors.ErrMissingAnnotations
 }
 
+// parse converts the value of the annotation using fn. Errors returned
+// by fn are reported as invalid content of the annotation.
+func (a ingAnnotations) parse(name string, fn func(string) (interface{}, error)) (interface{}, error) {
+	val, ok := a[name]
+	if !ok {
+		return nil, errors.ErrMissingAnnotations
+	}
+
+	v, err := fn(strings.TrimSpace(val))
+	if err != nil {
+		return nil, errors.NewInvalidAnnotationContent(name, val)
+	}
+	return v, nil
+}
+
+// sizeRegex matches NGINX sizes like 512, 4k or 1m
+// http://nginx.org/en/docs/syntax.html
+var sizeRegex = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+func parseDuration(val string) (interface{}, error) {
+	// values without unit are seconds, like in NGINX
+	if i, err := strconv.Atoi(val); err == nil {
+		if i < 0 {
+			return nil, fmt.Errorf("negative duration")
+		}
+		return time.Duration(i) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return nil, err
+	}
+	if d < 0 {
+		return nil, fmt.Errorf("negative duration")
+	}
+	return d, nil
+}
+
+func parseSize(val string) (interface{}, error) {
+	if !sizeRegex.MatchString(val) {
+		return nil, fmt.Errorf("invalid size")
+	}
+	return val, nil
+}
+
+func parseCIDRList(val string) (interface{}, error) {
+	var cidrs []string
+	for _, v := range strings.Split(val, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %v", v)
+			}
+			cidrs = append(cidrs, ip.String())
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, ipnet.String())
+	}
+
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("empty list")
+	}
+	return cidrs, nil
+}
+
 func checkAnnotation(name string, ing *networking.Ingress) error {
 	if ing == nil || len(ing.GetAnnotations()) == 0 {
 		return errors.ErrMissingAnnotations
@@ -119,6 +198,72 @@ func GetIntAnnotation(name string, ing *networking.Ingress) (int, error) {
 	return ingAnnotations(ing.GetAnnotations()).parseInt(v)
 }
 
+// GetDurationAnnotation extracts a duration from an Ingress annotation.
+// Values without unit are seconds.
+func GetDurationAnnotation(name string, ing *networking.Ingress) (time.Duration, error) {
+	v := GetAnnotationWithPrefix(name)
+	err := checkAnnotation(v, ing)
+	if err != nil {
+		return 0, err
+	}
+	d, err := ingAnnotations(ing.GetAnnotations()).parse(v, parseDuration)
+	if err != nil {
+		return 0, err
+	}
+	return d.(time.Duration), nil
+}
+
+// GetSizeAnnotation extracts an NGINX size (like 8k or 1m) from an Ingress annotation
+func GetSizeAnnotation(name string, ing *networking.Ingress) (string, error) {
+	v := GetAnnotationWithPrefix(name)
+	err := checkAnnotation(v, ing)
+	if err != nil {
+		return "", err
+	}
+	s, err := ingAnnotations(ing.GetAnnotations()).parse(v, parseSize)
+	if err != nil {
+		return "", err
+	}
+	return s.(string), nil
+}
+
+// GetCIDRListAnnotation extracts a comma separated list of IP addresses
+// and CIDRs from an Ingress annotation. CIDRs are returned normalized.
+func GetCIDRListAnnotation(name string, ing *networking.Ingress) ([]string, error) {
+	v := GetAnnotationWithPrefix(name)
+	err := checkAnnotation(v, ing)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ingAnnotations(ing.GetAnnotations()).parse(v, parseCIDRList)
+	if err != nil {
+		return nil, err
+	}
+	return l.([]string), nil
+}
+
+// GetEnumAnnotation extracts a string from an Ingress annotation that
+// must be one of the allowed values
+func GetEnumAnnotation(name string, ing *networking.Ingress, allowed ...string) (string, error) {
+	v := GetAnnotationWithPrefix(name)
+	err := checkAnnotation(v, ing)
+	if err != nil {
+		return "", err
+	}
+	e, err := ingAnnotations(ing.GetAnnotations()).parse(v, func(val string) (interface{}, error) {
+		for _, a := range allowed {
+			if val == a {
+				return val, nil
+			}
+		}
+		return nil, fmt.Errorf("expected one of %v", allowed)
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.(string), nil
+}
+
 // GetAnnotationWithPrefix returns the prefix of ingress annotations
 func GetAnnotationWithPrefix(suffix string) string {
 	return fmt.Sprintf("%v/%v", AnnotationsPrefix, suffix)
@@ -17,7 +17,9 @@ limitations under the License.
 package parser
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
@@ -153,3 +155,176 @@ func TestGetIntAnnotation(t *testing.T) {
 		delete(data, test.field)
 	}
 }
+
+func TestGetDurationAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	_, err := GetDurationAnnotation("", nil)
+	if err == nil {
+		t.Errorf("expected error but retuned nil")
+	}
+
+	tests := []struct {
+		name   string
+		field  string
+		value  string
+		exp    time.Duration
+		expErr bool
+	}{
+		{"valid - seconds without unit", "duration", "5", 5 * time.Second, false},
+		{"valid - milliseconds", "duration", "500ms", 500 * time.Millisecond, false},
+		{"valid - minutes", "duration", " 2m ", 2 * time.Minute, false},
+		{"invalid - negative", "duration", "-1", 0, true},
+		{"invalid - unit", "duration", "5x", 0, true},
+	}
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	for _, test := range tests {
+		data[GetAnnotationWithPrefix(test.field)] = test.value
+
+		d, err := GetDurationAnnotation(test.field, ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but retuned nil", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		}
+		if d != test.exp {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.name, test.exp, d)
+		}
+
+		delete(data, test.field)
+	}
+}
+
+func TestGetSizeAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	_, err := GetSizeAnnotation("", nil)
+	if err == nil {
+		t.Errorf("expected error but retuned nil")
+	}
+
+	tests := []struct {
+		name   string
+		field  string
+		value  string
+		exp    string
+		expErr bool
+	}{
+		{"valid - bytes", "size", "512", "512", false},
+		{"valid - kilobytes", "size", "8k", "8k", false},
+		{"valid - megabytes", "size", "1M", "1M", false},
+		{"invalid - unit", "size", "1kb", "", true},
+		{"invalid - empty", "size", "", "", true},
+	}
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	for _, test := range tests {
+		data[GetAnnotationWithPrefix(test.field)] = test.value
+
+		s, err := GetSizeAnnotation(test.field, ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but retuned nil", test.name)
+			}
+			continue
+		}
+		if s != test.exp {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.name, test.exp, s)
+		}
+
+		delete(data, test.field)
+	}
+}
+
+func TestGetCIDRListAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	_, err := GetCIDRListAnnotation("", nil)
+	if err == nil {
+		t.Errorf("expected error but retuned nil")
+	}
+
+	tests := []struct {
+		name   string
+		field  string
+		value  string
+		exp    []string
+		expErr bool
+	}{
+		{"valid - single IP", "cidr", "10.0.0.1", []string{"10.0.0.1"}, false},
+		{"valid - list", "cidr", "10.0.0.1/8, 192.168.0.0/16,", []string{"10.0.0.0/8", "192.168.0.0/16"}, false},
+		{"valid - IPv6", "cidr", "2001:db8::/32", []string{"2001:db8::/32"}, false},
+		{"invalid - IP", "cidr", "10.0.0.300", nil, true},
+		{"invalid - empty", "cidr", ",", nil, true},
+	}
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	for _, test := range tests {
+		data[GetAnnotationWithPrefix(test.field)] = test.value
+
+		l, err := GetCIDRListAnnotation(test.field, ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but retuned nil", test.name)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(l, test.exp) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.name, test.exp, l)
+		}
+
+		delete(data, test.field)
+	}
+}
+
+func TestGetEnumAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	_, err := GetEnumAnnotation("", nil, "a")
+	if err == nil {
+		t.Errorf("expected error but retuned nil")
+	}
+
+	tests := []struct {
+		name   string
+		field  string
+		value  string
+		exp    string
+		expErr bool
+	}{
+		{"valid - http", "enum", "http", "http", false},
+		{"valid - https", "enum", "https", "https", false},
+		{"invalid - grpc", "enum", "grpc", "", true},
+	}
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	for _, test := range tests {
+		data[GetAnnotationWithPrefix(test.field)] = test.value
+
+		e, err := GetEnumAnnotation(test.field, ing, "http", "https")
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but retuned nil", test.name)
+			}
+			continue
+		}
+		if e != test.exp {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.name, test.exp, e)
+		}
+
+		delete(data, test.field)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+const (
+	// the methods of the AnnotationPlugin service of plugin.proto
+	describeMethod = "/managementingress.plugin.v1.AnnotationPlugin/Describe"
+	parseMethod    = "/managementingress.plugin.v1.AnnotationPlugin/Parse"
+
+	// maxMessageSize is the maximum size of a response of a plugin
+	maxMessageSize = 1 << 20
+	// DefaultTimeout is the maximum time of a call to an external plugin
+	DefaultTimeout = time.Second
+)
+
+// Remote is an external plugin serving the AnnotationPlugin service of
+// plugin.proto on a unix socket. The calls share a single gRPC connection.
+type Remote struct {
+	name     string
+	contract string
+	timeout  time.Duration
+	conn     *grpc.ClientConn
+}
+
+// Dial connects to the external plugin listening on socket, with the
+// unix:///path format or a plain path, and returns it once described. The
+// calls to the plugin time out after timeout, DefaultTimeout if zero.
+func Dial(ctx context.Context, socket string, timeout time.Duration) (*Remote, error) {
+	path, err := socketPath(socket)
+	if err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := grpc.DialContext(ctx, "unix://"+path,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{}), grpc.MaxCallRecvMsgSize(maxMessageSize)))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Remote{timeout: timeout, conn: conn}
+	resp := &describeResponse{}
+	if err := conn.Invoke(ctx, describeMethod, &describeRequest{contracts: SupportedContracts}, resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected error describing plugin %v: %v", socket, err)
+	}
+	r.name = resp.name
+	r.contract = resp.contract
+	return r, nil
+}
+
+// socketPath returns the path of a unix socket, with the unix:///path format
+// or a plain path
+func socketPath(socket string) (string, error) {
+	switch {
+	case strings.HasPrefix(socket, "unix://"):
+		socket = strings.TrimPrefix(socket, "unix://")
+	case strings.HasPrefix(socket, "unix:"):
+		socket = strings.TrimPrefix(socket, "unix:")
+	case strings.Contains(socket, "://"):
+		return "", fmt.Errorf("the plugin socket %v is not a unix socket", socket)
+	}
+	if !strings.HasPrefix(socket, "/") {
+		return "", fmt.Errorf("the plugin socket %v is not an absolute path", socket)
+	}
+	return socket, nil
+}
+
+// Name returns the name described by the plugin
+func (r *Remote) Name() string {
+	return r.name
+}
+
+// Contract returns the contract version described by the plugin
+func (r *Remote) Contract() string {
+	return r.contract
+}
+
+// Close closes the connection to the plugin
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+// Parse sends the annotations of the Ingress to the plugin and returns the
+// JSON document of its response
+func (r *Remote) Parse(ing *networking.Ingress) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	req := &parseRequest{namespace: ing.Namespace, name: ing.Name, annotations: ing.Annotations}
+	resp := &parseResponse{}
+	if err := r.conn.Invoke(ctx, parseMethod, req, resp); err != nil {
+		return nil, err
+	}
+
+	var val interface{}
+	if len(resp.data) > 0 {
+		if err := json.Unmarshal(resp.data, &val); err != nil {
+			return nil, fmt.Errorf("invalid data in the ParseResponse: %v", err)
+		}
+	}
+	if resp.invalidName != "" {
+		return val, errors.NewInvalidAnnotationContent(resp.invalidName, resp.invalidValue)
+	}
+	if val == nil {
+		return nil, errors.ErrMissingAnnotations
+	}
+	return val, nil
+}
+
+// request is a request of plugin.proto, encoded with protowire as the
+// controller does not generate the code of the service
+type request interface {
+	marshal() []byte
+}
+
+// response is a response of plugin.proto
+type response interface {
+	unmarshal([]byte) error
+}
+
+// codec encodes the messages of plugin.proto in the gRPC calls
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(request)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(response)
+	if !ok {
+		return fmt.Errorf("unexpected message %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+// Name is the content subtype of the calls, the one of the protobuf codec
+func (codec) Name() string {
+	return "proto"
+}
+
+type describeRequest struct {
+	contracts []string
+}
+
+func (m *describeRequest) marshal() []byte {
+	var b []byte
+	for _, c := range m.contracts {
+		b = appendString(b, 1, c)
+	}
+	return b
+}
+
+type describeResponse struct {
+	name     string
+	contract string
+}
+
+func (m *describeResponse) unmarshal(b []byte) error {
+	return parseFields(b, func(num protowire.Number, value []byte) {
+		switch num {
+		case 1:
+			m.name = string(value)
+		case 2:
+			m.contract = string(value)
+		}
+	})
+}
+
+type parseRequest struct {
+	namespace   string
+	name        string
+	annotations map[string]string
+}
+
+func (m *parseRequest) marshal() []byte {
+	names := make([]string, 0, len(m.annotations))
+	for name := range m.annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := appendString(appendString(nil, 1, m.namespace), 2, m.name)
+	for _, name := range names {
+		// a map entry is a message with the key in field 1 and the value
+		// in field 2
+		entry := appendString(appendString(nil, 1, name), 2, m.annotations[name])
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+type parseResponse struct {
+	data         []byte
+	invalidName  string
+	invalidValue string
+}
+
+func (m *parseResponse) unmarshal(b []byte) error {
+	return parseFields(b, func(num protowire.Number, value []byte) {
+		switch num {
+		case 1:
+			m.data = append([]byte(nil), value...)
+		case 2:
+			m.invalidName = string(value)
+		case 3:
+			m.invalidValue = string(value)
+		}
+	})
+}
+
+// appendString appends a string field to a protobuf message
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// parseFields calls fn with the length delimited fields of a protobuf
+// message, skipping the other types
+func parseFields(msg []byte, fn func(protowire.Number, []byte)) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			msg = msg[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		fn(num, value)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin/plugintest"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+// rawCodec passes the protobuf messages of the tests as bytes
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) { return *v.(*[]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+func (rawCodec) Name() string { return "proto" }
+
+// serveTier serves the tier plugin with the AnnotationPlugin gRPC service
+func serveTier(t *testing.T, contract string) string {
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(fn func(req []byte) []byte) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+		return func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			var req []byte
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			resp := fn(req)
+			return &resp, nil
+		}
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "managementingress.plugin.v1.AnnotationPlugin",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "Describe", Handler: handler(func([]byte) []byte {
+				return appendString(appendString(nil, 1, "remoteTier"), 2, contract)
+			})},
+			{MethodName: "Parse", Handler: handler(func(req []byte) []byte {
+				annotations := map[string]string{}
+				for _, entry := range fields(t, req)[3] {
+					kv := fields(t, entry)
+					annotations[string(kv[1][0])] = string(kv[2][0])
+				}
+				var resp []byte
+				val, err := parseTier(annotations)
+				if val != nil {
+					data, _ := json.Marshal(val)
+					resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+					resp = protowire.AppendBytes(resp, data)
+				}
+				if errors.IsInvalidContent(err) {
+					resp = appendString(resp, 2, tierAnnotation)
+					resp = appendString(resp, 3, annotations[tierAnnotation])
+				}
+				return resp
+			})},
+		},
+	}, struct{}{})
+	go server.Serve(l)
+	t.Cleanup(server.Stop)
+	return "unix://" + socket
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// fields returns the length delimited fields of a protobuf message
+func fields(t *testing.T, msg []byte) map[protowire.Number][][]byte {
+	fields := map[protowire.Number][][]byte{}
+	for len(msg) > 0 {
+		num, _, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		value, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		fields[num] = append(fields[num], value)
+	}
+	return fields
+}
+
+func TestRemoteConformance(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := plugin.Dial(ctx, serveTier(t, plugin.ContractVersion), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if p.Name() != "remoteTier" || p.Contract() != plugin.ContractVersion {
+		t.Fatalf("expected the plugin remoteTier %v but described %v %v", plugin.ContractVersion, p.Name(), p.Contract())
+	}
+	plugintest.Conformance(t, p, tierCases)
+}
+
+func TestRemoteContract(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := plugin.Dial(ctx, serveTier(t, "v0"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := plugin.Register(p); err == nil {
+		plugin.Unregister(p.Name())
+		t.Errorf("expected an error registering a plugin with an unsupported contract")
+	}
+
+	if _, err := plugin.Dial(ctx, "tcp://127.0.0.1:8081", time.Second); err == nil {
+		t.Errorf("expected an error with a TCP socket")
+	}
+}
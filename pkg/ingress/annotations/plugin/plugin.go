@@ -0,0 +1,217 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package plugin lets downstream distributions add annotation handlers to
+// the controller. A plugin is compiled in, registered from an init
+// function, or runs in a separate process reached with gRPC through a unix
+// socket. The data returned by a plugin for an Ingress is available to the
+// template in the Plugins of its locations, by plugin name.
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+// ContractVersion is the version of the contract between the controller and
+// the plugins implemented by this controller. A new version is added when
+// the Plugin interface, the data available to the template or the gRPC
+// service change in an incompatible way.
+const ContractVersion = "v1"
+
+// SupportedContracts are the contract versions the controller accepts
+var SupportedContracts = []string{ContractVersion}
+
+// nameRegexp is the format of the plugin names, usable as a field in the
+// template, like $location.Plugins.myPlugin
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,62}$`)
+
+// Plugin parses the annotations of an Ingress into data for the template
+type Plugin interface {
+	// Name is the key of the data of the plugin in the locations
+	Name() string
+	// Contract is the contract version implemented by the plugin
+	Contract() string
+	// Parse returns the data of the Ingress. It returns an error of
+	// errors.IsMissingAnnotations when the Ingress does not use the
+	// plugin, and of errors.IsInvalidContent, optionally with the data
+	// to use in its place, when an annotation is invalid.
+	Parse(ing *networking.Ingress) (interface{}, error)
+}
+
+var (
+	mu      sync.RWMutex
+	plugins = map[string]Plugin{}
+)
+
+// Validate returns an error if the name or the contract of a plugin is not
+// valid
+func Validate(p Plugin) error {
+	if !nameRegexp.MatchString(p.Name()) {
+		return fmt.Errorf("invalid plugin name %q, expected %v", p.Name(), nameRegexp)
+	}
+	for _, c := range SupportedContracts {
+		if p.Contract() == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("plugin %v implements contract %q, supported: %v", p.Name(), p.Contract(), SupportedContracts)
+}
+
+// Register adds a plugin to the registry. It fails if the plugin is not
+// valid or another plugin has the same name.
+func Register(p Plugin) error {
+	if err := Validate(p); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := plugins[p.Name()]; ok {
+		return fmt.Errorf("plugin %v is already registered", p.Name())
+	}
+	plugins[p.Name()] = p
+	glog.Infof("registered annotation plugin %v (contract %v)", p.Name(), p.Contract())
+	return nil
+}
+
+// MustRegister is like Register but panics on error, for the init
+// functions of compiled in plugins
+func MustRegister(p Plugin) {
+	if err := Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes a plugin from the registry
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(plugins, name)
+	delete(results, name)
+}
+
+// Registered returns the registered plugins, sorted by name
+func Registered() []Plugin {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// result is the last result of a plugin for an Ingress
+type result struct {
+	resourceVersion string
+	val             interface{}
+	err             error
+}
+
+// results contains the last result of the plugins by plugin name and
+// Ingress, guarded by mu
+var results = map[string]map[string]result{}
+
+// Forget removes the results of the plugins for a deleted Ingress
+func Forget(ing *networking.Ingress) {
+	key := ing.Namespace + "/" + ing.Name
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range results {
+		delete(r, key)
+	}
+}
+
+// parse runs a plugin on an Ingress. The result is cached until the
+// resourceVersion of the Ingress changes. When the plugin fails, the last
+// result for the Ingress is kept, and ok is false without one.
+func parse(p Plugin, ing *networking.Ingress) (r result, ok bool) {
+	key := ing.Namespace + "/" + ing.Name
+	mu.RLock()
+	last, found := results[p.Name()][key]
+	mu.RUnlock()
+	if found && ing.ResourceVersion != "" && last.resourceVersion == ing.ResourceVersion {
+		return last, true
+	}
+
+	val, err := p.Parse(ing)
+	if err != nil && !errors.IsMissingAnnotations(err) && !errors.IsInvalidContent(err) {
+		if found {
+			glog.Warningf("unexpected error running plugin %v on Ingress %v, keeping its last result: %v", p.Name(), key, err)
+			return last, true
+		}
+		glog.Errorf("unexpected error running plugin %v on Ingress %v: %v", p.Name(), key, err)
+		return result{err: err}, false
+	}
+
+	r = result{resourceVersion: ing.ResourceVersion, val: val, err: err}
+	mu.Lock()
+	defer mu.Unlock()
+	// the plugin can be unregistered during the call
+	if _, registered := plugins[p.Name()]; registered {
+		if results[p.Name()] == nil {
+			results[p.Name()] = map[string]result{}
+		}
+		results[p.Name()][key] = r
+	}
+	return r, true
+}
+
+// Extract runs the registered plugins concurrently on an Ingress. It
+// returns their data by plugin name, nil without data, the invalid
+// annotations, and the plugins that failed without a previous result for
+// the Ingress, whose locations must not be served.
+func Extract(ing *networking.Ingress) (map[string]interface{}, []error, []string) {
+	type outcome struct {
+		result
+		ok bool
+	}
+
+	list := Registered()
+	outcomes := make([]outcome, len(list))
+	var wg sync.WaitGroup
+	for i, p := range list {
+		wg.Add(1)
+		go func(i int, p Plugin) {
+			defer wg.Done()
+			r, ok := parse(p, ing)
+			outcomes[i] = outcome{r, ok}
+		}(i, p)
+	}
+	wg.Wait()
+
+	var data map[string]interface{}
+	var errs []error
+	var failed []string
+	for i, p := range list {
+		o := outcomes[i]
+		if !o.ok {
+			failed = append(failed, p.Name())
+			errs = append(errs, fmt.Errorf("the annotation plugin %v failed: %v", p.Name(), o.err))
+			continue
+		}
+		if o.err != nil {
+			if errors.IsMissingAnnotations(o.err) {
+				continue
+			}
+			errs = append(errs, o.err)
+		}
+		if o.val == nil {
+			continue
+		}
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data[p.Name()] = o.val
+	}
+	return data, errs, failed
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package plugin_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin/plugintest"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+const tierAnnotation = "example.com/tier"
+
+// tierPlugin is a compiled in plugin returning the tier of an Ingress
+type tierPlugin struct {
+	name     string
+	contract string
+}
+
+func (p tierPlugin) Name() string     { return p.name }
+func (p tierPlugin) Contract() string { return p.contract }
+
+func (p tierPlugin) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parseTier(ing.Annotations)
+}
+
+func parseTier(annotations map[string]string) (interface{}, error) {
+	tier, ok := annotations[tierAnnotation]
+	if !ok {
+		return nil, errors.ErrMissingAnnotations
+	}
+	switch tier {
+	case "gold", "silver":
+		return map[string]interface{}{"tier": tier}, nil
+	}
+	return map[string]interface{}{"tier": "silver"}, errors.NewInvalidAnnotationContent(tierAnnotation, tier)
+}
+
+var tierCases = []plugintest.Case{
+	{Name: "other annotations", Annotations: map[string]string{"example.com/other": "gold"}},
+	{Name: "gold", Annotations: map[string]string{tierAnnotation: "gold"}, Want: map[string]string{"tier": "gold"}},
+	{Name: "invalid", Annotations: map[string]string{tierAnnotation: "platinum"}, Invalid: true,
+		Want: map[string]string{"tier": "silver"}},
+}
+
+func TestConformance(t *testing.T) {
+	plugintest.Conformance(t, tierPlugin{name: "tier", contract: plugin.ContractVersion}, tierCases)
+}
+
+func TestRegister(t *testing.T) {
+	tests := map[string]struct {
+		plugin plugin.Plugin
+		valid  bool
+	}{
+		"valid":                {tierPlugin{name: "tier", contract: "v1"}, true},
+		"duplicated name":      {tierPlugin{name: "tier", contract: "v1"}, false},
+		"name with a dash":     {tierPlugin{name: "my-tier", contract: "v1"}, false},
+		"empty name":           {tierPlugin{contract: "v1"}, false},
+		"unsupported contract": {tierPlugin{name: "tier2", contract: "v2"}, false},
+	}
+
+	defer plugin.Unregister("tier")
+	for _, name := range []string{"valid", "duplicated name", "name with a dash", "empty name", "unsupported contract"} {
+		tc := tests[name]
+		err := plugin.Register(tc.plugin)
+		if (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid %v but returned %v", name, tc.valid, err)
+		}
+	}
+	if n := len(plugin.Registered()); n != 1 {
+		t.Errorf("expected 1 registered plugin but returned %v", n)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	plugin.MustRegister(tierPlugin{name: "tier", contract: "v1"})
+	defer plugin.Unregister("tier")
+
+	tests := map[string]struct {
+		annotations map[string]string
+		data        map[string]interface{}
+		errors      int
+	}{
+		"without annotations": {nil, nil, 0},
+		"gold": {map[string]string{tierAnnotation: "gold"},
+			map[string]interface{}{"tier": map[string]interface{}{"tier": "gold"}}, 0},
+		"invalid": {map[string]string{tierAnnotation: "bronze"},
+			map[string]interface{}{"tier": map[string]interface{}{"tier": "silver"}}, 1},
+	}
+
+	for name, tc := range tests {
+		ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: tc.annotations}}
+		data, errs, failed := plugin.Extract(ing)
+		if !reflect.DeepEqual(data, tc.data) {
+			t.Errorf("%v: expected %v but returned %v", name, tc.data, data)
+		}
+		if len(errs) != tc.errors {
+			t.Errorf("%v: expected %v errors but returned %v", name, tc.errors, errs)
+		}
+		for _, err := range errs {
+			if !errors.IsInvalidContent(err) {
+				t.Errorf("%v: expected an invalid content error but returned %v", name, err)
+			}
+		}
+		if len(failed) > 0 {
+			t.Errorf("%v: expected no failed plugins but returned %v", name, failed)
+		}
+	}
+}
+
+// flakyPlugin counts its calls and fails when down
+type flakyPlugin struct {
+	calls int32
+	down  int32
+}
+
+func (p *flakyPlugin) Name() string     { return "flaky" }
+func (p *flakyPlugin) Contract() string { return plugin.ContractVersion }
+
+func (p *flakyPlugin) Parse(ing *networking.Ingress) (interface{}, error) {
+	atomic.AddInt32(&p.calls, 1)
+	if atomic.LoadInt32(&p.down) == 1 {
+		return nil, fmt.Errorf("unavailable")
+	}
+	return ing.ResourceVersion, nil
+}
+
+func TestExtractCache(t *testing.T) {
+	p := &flakyPlugin{}
+	plugin.MustRegister(p)
+	defer plugin.Unregister(p.Name())
+
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", ResourceVersion: "1"}}
+	plugin.Extract(ing)
+	plugin.Extract(ing)
+	if p.calls != 1 {
+		t.Errorf("expected 1 call with the same resourceVersion but returned %v", p.calls)
+	}
+
+	// the last result is kept when the plugin fails
+	p.down = 1
+	ing.ResourceVersion = "2"
+	data, errs, failed := plugin.Extract(ing)
+	if data["flaky"] != "1" || len(errs) > 0 || len(failed) > 0 {
+		t.Errorf("expected the last result but returned %v, %v and %v", data, errs, failed)
+	}
+	if p.calls != 2 {
+		t.Errorf("expected 2 calls but returned %v", p.calls)
+	}
+
+	// without a last result the plugin fails closed
+	plugin.Forget(ing)
+	data, errs, failed = plugin.Extract(ing)
+	if data != nil || len(errs) != 1 || !reflect.DeepEqual(failed, []string{"flaky"}) {
+		t.Errorf("expected the plugin failed but returned %v, %v and %v", data, errs, failed)
+	}
+}
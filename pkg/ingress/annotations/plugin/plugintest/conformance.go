@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package plugintest checks that an annotation plugin meets the contract
+// of the controller. Plugin authors run Conformance from their tests, with
+// the plugin itself or with a plugin.Remote connected to their server.
+package plugintest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+// Case is an Ingress parsed by the plugin in the conformance tests
+type Case struct {
+	Name        string
+	Annotations map[string]string
+	// Invalid is true if the annotations contain an invalid value
+	Invalid bool
+	// Want is the expected data, compared in JSON. Nil if the Ingress does
+	// not use the plugin.
+	Want interface{}
+}
+
+// Conformance checks the name and the contract of the plugin, and that it
+// parses the cases like expected
+func Conformance(t *testing.T, p plugin.Plugin, cases []Case) {
+	t.Helper()
+
+	if err := plugin.Validate(p); err != nil {
+		t.Fatalf("invalid plugin: %v", err)
+	}
+
+	t.Run("without annotations", func(t *testing.T) {
+		val, err := p.Parse(ingress(nil))
+		if err != nil && !errors.IsMissingAnnotations(err) {
+			t.Errorf("expected no error or a missing annotations error but returned %v", err)
+		}
+		if val != nil {
+			t.Errorf("expected no data but returned %v", val)
+		}
+	})
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			val, err := p.Parse(ingress(c.Annotations))
+			switch {
+			case c.Invalid:
+				if !errors.IsInvalidContent(err) {
+					t.Errorf("expected an invalid content error but returned %v", err)
+				}
+			case c.Want == nil:
+				if err != nil && !errors.IsMissingAnnotations(err) {
+					t.Errorf("expected no error or a missing annotations error but returned %v", err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			// the data is compared to detect changes and stored with the
+			// configuration of the locations
+			got, err := json.Marshal(val)
+			if err != nil {
+				t.Fatalf("the data can't be encoded in JSON: %v", err)
+			}
+			if c.Want != nil || !c.Invalid {
+				want, err := json.Marshal(c.Want)
+				if err != nil {
+					t.Fatalf("unexpected error encoding the expected data: %v", err)
+				}
+				if !jsonEqual(got, want) {
+					t.Errorf("expected data %s but returned %s", want, got)
+				}
+			}
+
+			again, _ := p.Parse(ingress(c.Annotations))
+			if !reflect.DeepEqual(val, again) {
+				t.Errorf("the data of the same Ingress changed from %v to %v", val, again)
+			}
+		})
+	}
+}
+
+func ingress(annotations map[string]string) *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "conformance",
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: annotations,
+		},
+	}
+}
+
+// jsonEqual compares two JSON documents regardless of the types used to
+// encode them
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package profile
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// Upload is the profile of the locations receiving large or resumable
+	// uploads, like the tus protocol
+	Upload = "upload"
+
+	// UploadTimeout is the minimum time in seconds the upload locations
+	// wait for the client or the backend
+	UploadTimeout = 3600
+)
+
+// Config contains the settings of the profile of a location
+type Config struct {
+	// Name is the profile of the location
+	Name string `json:"name,omitempty"`
+	// BodySize is the maximum size of the request bodies, 0 is unlimited
+	// and empty keeps the limit of the proxy annotations
+	BodySize string `json:"bodySize,omitempty"`
+	// Timeout is the minimum time in seconds to wait for the client or
+	// the backend to send data
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// IsUpload returns true if the location uses the upload profile
+func (c Config) IsUpload() bool {
+	return c.Name == Upload
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Name != c2.Name {
+		return false
+	}
+	if c1.BodySize != c2.BodySize {
+		return false
+	}
+	if c1.Timeout != c2.Timeout {
+		return false
+	}
+
+	return true
+}
+
+type profile struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new location profile annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return profile{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// select a predefined set of settings for the locations. The upload
+// profile streams the request bodies without a size limit, unless the
+// proxy-body-size annotation sets one. An invalid proxy-body-size keeps the
+// default limit of the proxy annotations instead of removing it.
+func (a profile) Parse(ing *networking.Ingress) (interface{}, error) {
+	name, err := parser.GetEnumAnnotation("profile", ing, Upload)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	c := &Config{
+		Name:     name,
+		BodySize: "0",
+		Timeout:  UploadTimeout,
+	}
+	// invalid sizes are reported by the proxy parser
+	v, err := parser.GetSizeAnnotation("proxy-body-size", ing)
+	switch {
+	case err == nil:
+		c.BodySize = v
+	case !errors.IsMissingAnnotations(err):
+		c.BodySize = ""
+	}
+
+	return c, nil
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package proxy
 
 import (
+	"time"
+
 	networking "k8s.io/api/networking/v1"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
 )
 
@@ -77,32 +80,50 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 }
 
 // ParseAnnotations parses the annotations contained in the ingress
-// rule used to configure upstream check parameters
+// rule used to configure upstream check parameters.
+// Invalid values are replaced with the defaults and the invalid
+// annotations are returned as error.
 func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
-	ct, err := parser.GetIntAnnotation("proxy-connect-timeout", ing)
-	if err != nil {
-		ct = DefaultProxyConfig.ConnectTimeout
+	var invalid error
+	check := func(err error) bool {
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	ct := DefaultProxyConfig.ConnectTimeout
+	if d, err := parser.GetDurationAnnotation("proxy-connect-timeout", ing); check(err) {
+		ct = seconds(d)
 	}
 
-	st, err := parser.GetIntAnnotation("proxy-send-timeout", ing)
-	if err != nil {
-		st = DefaultProxyConfig.SendTimeout
+	st := DefaultProxyConfig.SendTimeout
+	if d, err := parser.GetDurationAnnotation("proxy-send-timeout", ing); check(err) {
+		st = seconds(d)
 	}
 
-	rt, err := parser.GetIntAnnotation("proxy-read-timeout", ing)
-	if err != nil {
-		rt = DefaultProxyConfig.ReadTimeout
+	rt := DefaultProxyConfig.ReadTimeout
+	if d, err := parser.GetDurationAnnotation("proxy-read-timeout", ing); check(err) {
+		rt = seconds(d)
 	}
 
-	bufs, err := parser.GetStringAnnotation("proxy-buffer-size", ing)
-	if err != nil || bufs == "" {
-		bufs = DefaultProxyConfig.BufferSize
+	bufs := DefaultProxyConfig.BufferSize
+	if s, err := parser.GetSizeAnnotation("proxy-buffer-size", ing); check(err) {
+		bufs = s
 	}
 
-	bs, err := parser.GetStringAnnotation("proxy-body-size", ing)
-	if err != nil || bs == "" {
-		bs = DefaultProxyConfig.BodySize
+	bs := DefaultProxyConfig.BodySize
+	if s, err := parser.GetSizeAnnotation("proxy-body-size", ing); check(err) {
+		bs = s
 	}
 
-	return &Config{bs, ct, st, rt, bufs}, nil
+	return &Config{bs, ct, st, rt, bufs}, invalid
+}
+
+// seconds rounds up the duration to whole seconds, the unit used in the template
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
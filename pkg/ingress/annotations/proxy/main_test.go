@@ -114,6 +114,34 @@ func TestProxy(t *testing.T) {
 	}
 }
 
+func TestProxyWithInvalidAnnotations(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("proxy-connect-timeout")] = "1500ms"
+	data[parser.GetAnnotationWithPrefix("proxy-send-timeout")] = "abc"
+	data[parser.GetAnnotationWithPrefix("proxy-buffer-size")] = "1kb"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if err == nil {
+		t.Errorf("expected an error parsing invalid annotations")
+	}
+	p, ok := i.(*Config)
+	if !ok {
+		t.Fatalf("expected a Config type")
+	}
+	if p.ConnectTimeout != 2 {
+		t.Errorf("expected 2 as connect-timeout but returned %v", p.ConnectTimeout)
+	}
+	if p.SendTimeout != 60 {
+		t.Errorf("expected 60 as send-timeout but returned %v", p.SendTimeout)
+	}
+	if p.BufferSize != "4k" {
+		t.Errorf("expected 4k as buffer-size but returned %v", p.BufferSize)
+	}
+}
+
 func TestProxyWithNoAnnotation(t *testing.T) {
 	ing := buildIngress()
 
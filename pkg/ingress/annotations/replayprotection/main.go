@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package replayprotection
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// defaultWindow is the default age, in seconds, of the oldest request
+	// accepted
+	defaultWindow = 300
+	// maxWindow limits the nonces kept by NGINX
+	maxWindow = 3600
+)
+
+// Config contains the keys used to validate the signed requests of a
+// location and the window in which they are accepted
+type Config struct {
+	// Secret is the <namespace>/<name> of the secret with the keys
+	Secret string `json:"secret"`
+	// KeysFile contains the path to the file with the keys of the secret
+	KeysFile string `json:"keysFile"`
+	// Checksum contains the SHA1 hash of the keys
+	Checksum string `json:"checksum"`
+	// Window is the maximum difference, in seconds, between the timestamp
+	// of a request and the time it is received
+	Window int `json:"window"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.KeysFile != c2.KeysFile {
+		return false
+	}
+	if c1.Checksum != c2.Checksum {
+		return false
+	}
+	if c1.Window != c2.Window {
+		return false
+	}
+
+	return true
+}
+
+type replayProtection struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new replay protection annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return replayProtection{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// reject the webhooks without a valid signature, nonce and timestamp. The
+// keys are read from a secret in the namespace of the Ingress.
+func (a replayProtection) Parse(ing *networking.Ingress) (interface{}, error) {
+	name, err := parser.GetStringAnnotation("replay-protection-secret", ing)
+	if err != nil {
+		return nil, err
+	}
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("replay-protection-secret", name)
+	}
+
+	window, err := parser.GetIntAnnotation("replay-protection-window", ing)
+	if errors.IsMissingAnnotations(err) {
+		window = defaultWindow
+	} else if err != nil || window <= 0 || window > maxWindow {
+		return nil, errors.NewInvalidAnnotationContent("replay-protection-window", window)
+	}
+
+	return &Config{Secret: fmt.Sprintf("%v/%v", ing.Namespace, name), Window: window}, nil
+}
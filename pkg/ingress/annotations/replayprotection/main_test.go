@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package replayprotection
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	secret := parser.GetAnnotationWithPrefix("replay-protection-secret")
+	window := parser.GetAnnotationWithPrefix("replay-protection-window")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		err         bool
+	}{
+		{map[string]string{secret: "webhook-keys"}, &Config{Secret: "default/webhook-keys", Window: 300}, false},
+		{map[string]string{secret: "webhook-keys", window: "60"}, &Config{Secret: "default/webhook-keys", Window: 60}, false},
+		{map[string]string{secret: "webhook-keys", window: "0"}, nil, true},
+		{map[string]string{secret: "webhook-keys", window: "86400"}, nil, true},
+		{map[string]string{secret: "other/webhook-keys"}, nil, true},
+		{map[string]string{window: "60"}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package serviceaccounts
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type serviceaccounts struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new allowed ServiceAccounts annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return serviceaccounts{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the comma separated list of ServiceAccounts, as <namespace>/<name>
+// or <namespace>/*, allowed in the locations with the service-account
+// auth type
+func (a serviceaccounts) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("allowed-service-accounts", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []string
+	for _, sa := range strings.Split(val, ",") {
+		sa = strings.TrimSpace(sa)
+		parts := strings.Split(sa, "/")
+		if len(parts) != 2 || len(validation.IsDNS1123Label(parts[0])) > 0 {
+			return nil, errors.NewInvalidAnnotationContent("allowed-service-accounts", val)
+		}
+		if parts[1] != "*" && len(validation.IsDNS1123Subdomain(parts[1])) > 0 {
+			return nil, errors.NewInvalidAnnotationContent("allowed-service-accounts", val)
+		}
+		allowed = append(allowed, sa)
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package serviceaccounts
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("allowed-service-accounts")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		invalid     bool
+	}{
+		{map[string]string{annotation: "ns/import-controller"}, []string{"ns/import-controller"}, false},
+		{map[string]string{annotation: "ns/a, other/*"}, []string{"ns/a", "other/*"}, false},
+		{map[string]string{annotation: "import-controller"}, nil, true},
+		{map[string]string{annotation: "*/*"}, nil, true},
+		{map[string]string{annotation: "ns/a/b"}, nil, true},
+		{map[string]string{}, nil, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		allowed, _ := i.([]string)
+
+		if !reflect.DeepEqual(allowed, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, allowed, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
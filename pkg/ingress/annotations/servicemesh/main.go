@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package servicemesh
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// Istio sets the Host header to the name of the Service, used by the
+	// Envoy sidecars to route the request
+	Istio = "istio"
+	// Linkerd sets the l5d-dst-override header to the name and port of the
+	// Service, used by the Linkerd proxies to route the request
+	Linkerd = "linkerd"
+)
+
+// Config contains the service mesh of the pods of a backend. The requests
+// are sent in plain HTTP to the ClusterIP, so the mesh sidecars encrypt
+// them and balance them to the pods.
+type Config struct {
+	// Mesh is istio or linkerd, empty if the pods are not in a mesh
+	Mesh string `json:"mesh,omitempty"`
+}
+
+// Enabled returns true if the pods of the backend are in a service mesh
+func (c Config) Enabled() bool {
+	return c.Mesh != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Mesh != c2.Mesh {
+		return false
+	}
+
+	return true
+}
+
+type serviceMesh struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new service mesh annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return serviceMesh{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the service mesh of the backends
+func (a serviceMesh) Parse(ing *networking.Ingress) (interface{}, error) {
+	mesh, err := parser.GetEnumAnnotation("service-mesh", ing, Istio, Linkerd)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{Mesh: mesh}, nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package sharedcert
+
+import (
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type sharedcert struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new shared certificate annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sharedcert{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// reference, by its alias, a certificate published by the platform
+// namespace. It is used by the TLS hosts of the Ingress without a secret.
+func (a sharedcert) Parse(ing *networking.Ingress) (interface{}, error) {
+	alias, err := parser.GetStringAnnotation("shared-certificate", ing)
+	if err != nil {
+		return nil, err
+	}
+	if len(validation.IsDNS1123Label(alias)) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("shared-certificate", alias)
+	}
+	return alias, nil
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package sharedcert
+
+import (
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "team-a"}}
+	p := NewParser(&resolver.Mock{})
+
+	if _, err := p.Parse(ing); !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation but returned %v", err)
+	}
+
+	for alias, valid := range map[string]bool{"apps-wildcard": true, "apps": true, "Apps": false, "platform/apps": false, "-apps": false} {
+		ing.Annotations = map[string]string{parser.GetAnnotationWithPrefix("shared-certificate"): alias}
+		val, err := p.Parse(ing)
+		if valid && (err != nil || val != alias) {
+			t.Errorf("expected %v to be valid but returned %v, %v", alias, val, err)
+		}
+		if !valid && !errors.IsInvalidContent(err) {
+			t.Errorf("expected %v to be invalid but returned %v", alias, err)
+		}
+	}
+}
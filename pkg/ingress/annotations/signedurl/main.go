@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package signedurl
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// Config contains the keys used to validate the signed URLs of a location
+type Config struct {
+	// Secret is the <namespace>/<name> of the secret with the keys
+	Secret string `json:"secret"`
+	// KeysFile contains the path to the file with the keys of the secret
+	KeysFile string `json:"keysFile"`
+	// Checksum contains the SHA1 hash of the keys
+	Checksum string `json:"checksum"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.KeysFile != c2.KeysFile {
+		return false
+	}
+	if c1.Checksum != c2.Checksum {
+		return false
+	}
+
+	return true
+}
+
+type signedurl struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new signed URL annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return signedurl{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// require signed URLs in the locations. The keys are read from a secret
+// in the namespace of the Ingress.
+func (a signedurl) Parse(ing *networking.Ingress) (interface{}, error) {
+	name, err := parser.GetStringAnnotation("signed-url-secret", ing)
+	if err != nil {
+		return nil, err
+	}
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return nil, errors.NewInvalidAnnotationContent("signed-url-secret", name)
+	}
+
+	return &Config{Secret: fmt.Sprintf("%v/%v", ing.Namespace, name)}, nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package slowstart
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type slowstart struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new slow start annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return slowstart{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to ramp
+// up the traffic sent to the new endpoints of the backend. The period is
+// returned in seconds, rounded up.
+func (a slowstart) Parse(ing *networking.Ingress) (interface{}, error) {
+	d, err := parser.GetDurationAnnotation("slow-start", ing)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.NewInvalidAnnotationContent("slow-start", d)
+	}
+	return int((d + time.Second - 1) / time.Second), nil
+}
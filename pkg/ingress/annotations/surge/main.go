@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package surge
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+const (
+	// Reject answers 503 with a Retry-After header to the requests over the
+	// limit of the ready pods
+	Reject = "reject"
+	// Queue holds the requests over the limit of the ready pods up to the
+	// queue timeout before rejecting them
+	Queue = "queue"
+
+	defaultMinReady       = 50
+	defaultRequestsPerPod = 10
+	defaultQueueTimeout   = 5
+	defaultRetryAfter     = 10
+)
+
+// Config contains the surge protection of a location, applied while the
+// ready pods of the backend Service are below a percentage of its pods
+type Config struct {
+	// Mode is reject or queue, empty if disabled
+	Mode string `json:"mode,omitempty"`
+	// MinReady is the percentage of ready pods below which the backend is
+	// protected
+	MinReady int `json:"minReady,omitempty"`
+	// RequestsPerPod is the number of concurrent requests allowed per
+	// ready pod while the backend is protected
+	RequestsPerPod int `json:"requestsPerPod,omitempty"`
+	// QueueTimeout is the maximum time in seconds a request is queued
+	QueueTimeout int `json:"queueTimeout,omitempty"`
+	// RetryAfter is the value in seconds of the Retry-After header of the
+	// rejected requests
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// Enabled returns true if the location has surge protection
+func (c Config) Enabled() bool {
+	return c.Mode != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Mode != c2.Mode {
+		return false
+	}
+	if c1.MinReady != c2.MinReady {
+		return false
+	}
+	if c1.RequestsPerPod != c2.RequestsPerPod {
+		return false
+	}
+	if c1.QueueTimeout != c2.QueueTimeout {
+		return false
+	}
+	if c1.RetryAfter != c2.RetryAfter {
+		return false
+	}
+
+	return true
+}
+
+type surge struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new surge protection annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return surge{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// protect the backend while most of its pods are not ready, like during a
+// rollout. Invalid values keep the default and the invalid
+// annotations are returned as error.
+func (a surge) Parse(ing *networking.Ingress) (interface{}, error) {
+	mode, err := parser.GetEnumAnnotation("surge-protection", ing, Reject, Queue)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	var invalid error
+	check := func(name string, v int, err error) bool {
+		if err == nil && v <= 0 {
+			err = errors.NewInvalidAnnotationContent(name, v)
+		}
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	c := &Config{
+		Mode:           mode,
+		MinReady:       defaultMinReady,
+		RequestsPerPod: defaultRequestsPerPod,
+		QueueTimeout:   defaultQueueTimeout,
+		RetryAfter:     defaultRetryAfter,
+	}
+	if v, err := parser.GetIntAnnotation("surge-min-ready-percent", ing); check("surge-min-ready-percent", v, err) {
+		if v > 100 {
+			v = 100
+		}
+		c.MinReady = v
+	}
+	if v, err := parser.GetIntAnnotation("surge-requests-per-pod", ing); check("surge-requests-per-pod", v, err) {
+		c.RequestsPerPod = v
+	}
+	if d, err := parser.GetDurationAnnotation("surge-queue-timeout", ing); check("surge-queue-timeout", int(d), err) {
+		c.QueueTimeout = int((d + time.Second - 1) / time.Second)
+	}
+	if d, err := parser.GetDurationAnnotation("surge-retry-after", ing); check("surge-retry-after", int(d), err) {
+		c.RetryAfter = int((d + time.Second - 1) / time.Second)
+	}
+
+	return c, invalid
+}
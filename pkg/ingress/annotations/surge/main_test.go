@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package surge
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	mode := parser.GetAnnotationWithPrefix("surge-protection")
+	minReady := parser.GetAnnotationWithPrefix("surge-min-ready-percent")
+	perPod := parser.GetAnnotationWithPrefix("surge-requests-per-pod")
+	queueTimeout := parser.GetAnnotationWithPrefix("surge-queue-timeout")
+	retryAfter := parser.GetAnnotationWithPrefix("surge-retry-after")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	defaults := func(mode string) *Config {
+		return &Config{Mode: mode, MinReady: 50, RequestsPerPod: 10, QueueTimeout: 5, RetryAfter: 10}
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{mode: "reject"}, defaults(Reject), false},
+		{map[string]string{mode: "queue"}, defaults(Queue), false},
+		{map[string]string{mode: "drop"}, &Config{}, true},
+		{map[string]string{minReady: "80"}, &Config{}, false},
+		{map[string]string{mode: "queue", minReady: "80", perPod: "4", queueTimeout: "1500ms", retryAfter: "1m"},
+			&Config{Mode: Queue, MinReady: 80, RequestsPerPod: 4, QueueTimeout: 2, RetryAfter: 60}, false},
+		{map[string]string{mode: "reject", minReady: "150"}, &Config{Mode: Reject, MinReady: 100, RequestsPerPod: 10, QueueTimeout: 5, RetryAfter: 10}, false},
+		{map[string]string{mode: "reject", perPod: "0"}, defaults(Reject), true},
+		{map[string]string{mode: "reject", queueTimeout: "soon"}, defaults(Reject), true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
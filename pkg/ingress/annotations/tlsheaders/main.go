@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package tlsheaders
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// Header is a request header with a value of the TLS connection
+type Header struct {
+	// Name of the header sent to the backend
+	Name string
+	// Variable is the NGINX variable with the value
+	Variable string
+}
+
+// Headers are the headers that can be stamped on the requests
+var Headers = map[string]Header{
+	"sni":                {"X-TLS-SNI", "$ssl_server_name"},
+	"protocol":           {"X-TLS-Protocol", "$ssl_protocol"},
+	"cipher":             {"X-TLS-Cipher", "$ssl_cipher"},
+	"fingerprint":        {"X-TLS-Fingerprint", "$tls_fingerprint"},
+	"client-subject":     {"X-TLS-Client-Subject", "$ssl_client_s_dn"},
+	"client-issuer":      {"X-TLS-Client-Issuer", "$ssl_client_i_dn"},
+	"client-fingerprint": {"X-TLS-Client-Fingerprint", "$ssl_client_fingerprint"},
+}
+
+type tlsheaders struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new TLS headers annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return tlsheaders{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// define the comma separated list of TLS values sent to the backend
+func (a tlsheaders) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("tls-headers", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(val, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := Headers[name]; !ok {
+			return nil, errors.NewInvalidAnnotationContent("tls-headers", val)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// HasClientCertificate returns true if one of the headers
+// needs the certificate of the client
+func HasClientCertificate(names []string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, "client-") {
+			return true
+		}
+	}
+	return false
+}
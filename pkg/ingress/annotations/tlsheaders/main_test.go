@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package tlsheaders
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("tls-headers")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{map[string]string{annotation: "sni"}, []string{"sni"}, false},
+		{map[string]string{annotation: "SNI, protocol,client-subject"}, []string{"sni", "protocol", "client-subject"}, false},
+		{map[string]string{annotation: "sni,fingerprint,sni"}, []string{"sni", "fingerprint"}, false},
+		{map[string]string{annotation: "sni,"}, nil, true},
+		{map[string]string{annotation: "client-san"}, nil, true},
+		{map[string]string{}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		names, _ := i.([]string)
+
+		if !reflect.DeepEqual(names, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, names, testCase.annotations)
+		}
+		if (err != nil) != testCase.err {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.err, err, testCase.annotations)
+		}
+	}
+}
+
+func TestHasClientCertificate(t *testing.T) {
+	if HasClientCertificate([]string{"sni", "fingerprint"}) {
+		t.Errorf("expected no client certificate for the server values")
+	}
+	if !HasClientCertificate([]string{"sni", "client-issuer"}) {
+		t.Errorf("expected a client certificate for client-issuer")
+	}
+}
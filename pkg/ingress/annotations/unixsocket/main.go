@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package unixsocket
+
+import (
+	"path/filepath"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// maxPathLength is the size of the path of a Unix socket address, without
+// the terminating null
+const maxPathLength = 107
+
+var pathRegex = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+
+// Config contains the Unix socket the paths of an Ingress are proxied to,
+// instead of their Services
+type Config struct {
+	Path string `json:"path"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return c1.Path == c2.Path
+}
+
+type unixSocket struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new Unix socket annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return unixSocket{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to proxy
+// the requests to a Unix socket in the pod of the controller, like the
+// socket of a node-local agent mounted from the host
+func (a unixSocket) Parse(ing *networking.Ingress) (interface{}, error) {
+	path, err := parser.GetStringAnnotation("unix-socket", ing)
+	if err != nil {
+		return nil, err
+	}
+	if !pathRegex.MatchString(path) || filepath.Clean(path) != path || len(path) > maxPathLength {
+		return nil, errors.NewInvalidAnnotationContent("unix-socket", path)
+	}
+
+	return &Config{Path: path}, nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package upstreamhashload
+
+import (
+	"strconv"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+type upstreamhashload struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new bounded load annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamhashload{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to bound
+// the load of the pods selected by the upstream-hash-by key. The value is
+// the maximum load of a pod relative to the average, 1.25 allows 25% more
+// requests in progress than the average.
+func (a upstreamhashload) Parse(ing *networking.Ingress) (interface{}, error) {
+	v, err := parser.GetStringAnnotation("upstream-hash-load-factor", ing)
+	if err != nil {
+		return 0.0, err
+	}
+
+	factor, err := strconv.ParseFloat(v, 64)
+	if err != nil || factor < 1 {
+		return 0.0, errors.NewInvalidAnnotationContent("upstream-hash-load-factor", v)
+	}
+	return factor, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package upstreamidentity
+
+import (
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+	"github.com/stolostron/management-ingress/pkg/ingress/spiffe"
+)
+
+// Config contains the SPIFFE IDs accepted from the endpoints of a backend.
+// NGINX presents the X509-SVID of the controller to them and only sends
+// requests to the endpoints whose SVID was verified. The SVIDs must also
+// have the DNS name of the service, verified by NGINX on every connection.
+type Config struct {
+	IDs []string `json:"ids,omitempty"`
+}
+
+// Enabled returns true if the SPIFFE IDs of the endpoints are verified
+func (c Config) Enabled() bool {
+	return len(c.IDs) > 0
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if len(c1.IDs) != len(c2.IDs) {
+		return false
+	}
+	for i := range c1.IDs {
+		if c1.IDs[i] != c2.IDs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServerName returns the DNS name of the service the SVIDs of its endpoints
+// must have
+func ServerName(namespace, name string) string {
+	return name + "." + namespace + ".svc"
+}
+
+type upstreamIdentity struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new upstream SPIFFE ID annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamIdentity{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// verify the SPIFFE IDs of the backends, a comma separated list
+func (a upstreamIdentity) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("upstream-spiffe-id", ing)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var ids []string
+	for _, id := range strings.Split(val, ",") {
+		id = strings.TrimSpace(id)
+		if err := spiffe.ValidateID(id); err != nil {
+			return Config{}, errors.NewInvalidAnnotationContent("upstream-spiffe-id", val)
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return Config{IDs: ids}, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package upstreamidentity
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("upstream-spiffe-id")
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		invalid     bool
+	}{
+		{map[string]string{annotation: "spiffe://example.org/ns/hub/sa/api"}, []string{"spiffe://example.org/ns/hub/sa/api"}, false},
+		{map[string]string{annotation: "spiffe://example.org/web, spiffe://example.org/api"}, []string{"spiffe://example.org/api", "spiffe://example.org/web"}, false},
+		{map[string]string{annotation: "spiffe://example.org/api,"}, nil, true},
+		{map[string]string{annotation: "https://example.org/api"}, nil, true},
+		{map[string]string{annotation: "api"}, nil, true},
+		{map[string]string{}, nil, false},
+		{nil, nil, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		if ids := i.(Config).IDs; !reflect.DeepEqual(ids, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, ids, testCase.annotations)
+		}
+		if errors.IsInvalidContent(err) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
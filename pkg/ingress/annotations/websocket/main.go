@@ -0,0 +1,95 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package websocket
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// Config contains the limits of the websocket sessions of a location.
+// Zero disables a limit.
+type Config struct {
+	// MaxConnections is the maximum number of sessions of the location
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// MaxConnectionsPerIP is the maximum number of sessions of the
+	// location opened from the same client address
+	MaxConnectionsPerIP int `json:"maxConnectionsPerIP,omitempty"`
+	// IdleTimeout is the time in seconds without data in either direction
+	// after which the upgraded and streaming connections are closed
+	IdleTimeout int `json:"idleTimeout,omitempty"`
+}
+
+// Enabled returns true if any of the limits is set
+func (c Config) Enabled() bool {
+	return c.MaxConnections > 0 || c.MaxConnectionsPerIP > 0 || c.IdleTimeout > 0
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.MaxConnections != c2.MaxConnections {
+		return false
+	}
+	if c1.MaxConnectionsPerIP != c2.MaxConnectionsPerIP {
+		return false
+	}
+	if c1.IdleTimeout != c2.IdleTimeout {
+		return false
+	}
+
+	return true
+}
+
+type websocket struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new websocket session limits annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return websocket{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// limit the concurrent websocket sessions and their idle time. Invalid
+// values disable the limit and the invalid annotations are returned
+// as error.
+func (a websocket) Parse(ing *networking.Ingress) (interface{}, error) {
+	var invalid error
+	check := func(name string, v int, err error) bool {
+		if err == nil && v < 0 {
+			err = errors.NewInvalidAnnotationContent(name, v)
+		}
+		if err == nil {
+			return true
+		}
+		if errors.IsInvalidContent(err) {
+			invalid = errors.AppendInvalidContent(invalid, err)
+		}
+		return false
+	}
+
+	c := &Config{}
+	if v, err := parser.GetIntAnnotation("max-websocket-connections", ing); check("max-websocket-connections", v, err) {
+		c.MaxConnections = v
+	}
+	if v, err := parser.GetIntAnnotation("max-websocket-connections-per-ip", ing); check("max-websocket-connections-per-ip", v, err) {
+		c.MaxConnectionsPerIP = v
+	}
+	if d, err := parser.GetDurationAnnotation("idle-timeout", ing); check("idle-timeout", int(d), err) {
+		c.IdleTimeout = int((d + time.Second - 1) / time.Second)
+	}
+
+	return c, invalid
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package websocket
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	max := parser.GetAnnotationWithPrefix("max-websocket-connections")
+	perIP := parser.GetAnnotationWithPrefix("max-websocket-connections-per-ip")
+	idle := parser.GetAnnotationWithPrefix("idle-timeout")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		invalid     bool
+	}{
+		{map[string]string{max: "200"}, &Config{MaxConnections: 200}, false},
+		{map[string]string{perIP: "5"}, &Config{MaxConnectionsPerIP: 5}, false},
+		{map[string]string{max: "200", perIP: "5"}, &Config{MaxConnections: 200, MaxConnectionsPerIP: 5}, false},
+		{map[string]string{max: "many", perIP: "5"}, &Config{MaxConnectionsPerIP: 5}, true},
+		{map[string]string{max: "-1"}, &Config{}, true},
+		{map[string]string{idle: "10m"}, &Config{IdleTimeout: 600}, false},
+		{map[string]string{idle: "1500ms", max: "10"}, &Config{MaxConnections: 10, IdleTimeout: 2}, false},
+		{map[string]string{idle: "never"}, &Config{}, true},
+		{map[string]string{}, &Config{}, false},
+		{nil, &Config{}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		i, err := ap.Parse(ing)
+		p, _ := i.(*Config)
+
+		if !p.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, p, testCase.annotations)
+		}
+		if (err != nil) != testCase.invalid {
+			t.Errorf("expected invalid %v but returned %v, annotations: %s", testCase.invalid, err, testCase.annotations)
+		}
+	}
+}
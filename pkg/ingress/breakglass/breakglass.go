@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package breakglass checks the alternate authentication of the locations
+// with the break-glass-auth annotation, accepted while the OIDC issuer is
+// unreachable: a client certificate issued by the CAs of a Secret or the
+// static token of a Secret.
+package breakglass
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/breakglass"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// MethodHeader contains the method of the location
+	MethodHeader = "X-Break-Glass-Method"
+	// SecretHeader contains the URL encoded <namespace>/<name> of the Secret
+	SecretHeader = "X-Break-Glass-Secret"
+	// CertificateHeader contains the URL encoded PEM client certificate
+	CertificateHeader = "X-Client-Certificate"
+
+	// CAKey is the key of the Secret with the PEM certificates of the CAs
+	// issuing the client certificates
+	CAKey = "ca.crt"
+	// TokenKey is the key of the Secret with the token
+	TokenKey = "token"
+)
+
+var (
+	// ErrInactive is returned while the break-glass authentication is not
+	// active
+	ErrInactive = errors.New("the break-glass authentication is not active")
+	// ErrRejected is returned for the credentials not accepted
+	ErrRejected = errors.New("the credentials are not accepted")
+)
+
+// SecretGetter returns a Secret by <namespace>/<name>
+type SecretGetter func(name string) (*apiv1.Secret, error)
+
+// Checker checks the credentials of the requests while Active returns true
+type Checker struct {
+	Active func() bool
+	Secret SecretGetter
+}
+
+// Check returns the subject of the accepted credentials of a request, the
+// client certificate or the bearer token in the Authorization header
+func (c *Checker) Check(method, secretName string, cert *x509.Certificate, authorization string) (string, error) {
+	if !c.Active() {
+		return "", ErrInactive
+	}
+	secret, err := c.Secret(secretName)
+	if err != nil {
+		return "", err
+	}
+
+	switch method {
+	case breakglass.ClientCertificate:
+		if cert == nil {
+			return "", ErrRejected
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(secret.Data[CAKey]) {
+			return "", fmt.Errorf("the secret %v has no CA in %v", secretName, CAKey)
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:     pool,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			return "", ErrRejected
+		}
+		return cert.Subject.String(), nil
+	case breakglass.Token:
+		expected := strings.TrimSpace(string(secret.Data[TokenKey]))
+		if expected == "" {
+			return "", fmt.Errorf("the secret %v has no %v", secretName, TokenKey)
+		}
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			return "", ErrRejected
+		}
+		return "token of " + secretName, nil
+	default:
+		return "", fmt.Errorf("unknown method %q", method)
+	}
+}
+
+// clientCertificate returns the client certificate of the subrequest, nil
+// without one
+func clientCertificate(r *http.Request) *x509.Certificate {
+	value, err := url.QueryUnescape(r.Header.Get(CertificateHeader))
+	if err != nil {
+		return nil
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// Handler checks the credentials of the subrequests sent by NGINX. It
+// answers 200 if they are accepted and 403 otherwise. Every accepted
+// request is logged. It only accepts requests from the loopback interface.
+func Handler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		method := r.Header.Get(MethodHeader)
+		// the argument of the subrequest is not decoded by NGINX
+		secret, err := url.QueryUnescape(r.Header.Get(SecretHeader))
+		if err != nil {
+			http.Error(w, "invalid secret", http.StatusBadRequest)
+			return
+		}
+		subject, err := c.Check(method, secret, clientCertificate(r), r.Header.Get("Authorization"))
+		switch {
+		case err == nil:
+			glog.Warningf("accepting break-glass %v of %v for %v", method, subject, r.Header.Get("X-Original-URI"))
+			metric.IncBreakGlassRequest(method, "accepted")
+			w.WriteHeader(http.StatusOK)
+		case err == ErrInactive:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			if err != ErrRejected {
+				glog.Warningf("unexpected error checking the break-glass %v: %v", method, err)
+			}
+			metric.IncBreakGlassRequest(method, "rejected")
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	})
+}
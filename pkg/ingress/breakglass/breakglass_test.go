@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package breakglass
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/breakglass"
+)
+
+// newCertificate returns a certificate signed by parent, self signed if nil
+func newCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestCheck(t *testing.T) {
+	ca, caKey := newCertificate(t, "break-glass", nil, nil)
+	admin, _ := newCertificate(t, "admin", ca, caKey)
+	other, _ := newCertificate(t, "other", nil, nil)
+
+	secrets := map[string]*apiv1.Secret{
+		"ocm/admin-ca": {Data: map[string][]byte{CAKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})}},
+		"ocm/token":    {Data: map[string][]byte{TokenKey: []byte("s3cr3t\n")}},
+	}
+	active := false
+	c := &Checker{
+		Active: func() bool { return active },
+		Secret: func(name string) (*apiv1.Secret, error) {
+			if s, ok := secrets[name]; ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("secret %v was not found", name)
+		},
+	}
+
+	if _, err := c.Check(breakglass.Token, "ocm/token", nil, "Bearer s3cr3t"); err != ErrInactive {
+		t.Errorf("expected the credentials to be ignored while inactive but returned %v", err)
+	}
+
+	active = true
+	testCases := []struct {
+		method, secret string
+		cert           *x509.Certificate
+		authorization  string
+		err            bool
+	}{
+		{breakglass.Token, "ocm/token", nil, "Bearer s3cr3t", false},
+		{breakglass.Token, "ocm/token", nil, "Bearer other", true},
+		{breakglass.Token, "ocm/token", nil, "s3cr3t", true},
+		{breakglass.Token, "ocm/missing", nil, "Bearer s3cr3t", true},
+		{breakglass.ClientCertificate, "ocm/admin-ca", admin, "", false},
+		{breakglass.ClientCertificate, "ocm/admin-ca", other, "", true},
+		{breakglass.ClientCertificate, "ocm/admin-ca", nil, "", true},
+		{breakglass.ClientCertificate, "ocm/token", admin, "", true},
+	}
+	for _, tc := range testCases {
+		subject, err := c.Check(tc.method, tc.secret, tc.cert, tc.authorization)
+		if (err != nil) != tc.err {
+			t.Errorf("%v with %v: expected error %v but returned %v", tc.method, tc.secret, tc.err, err)
+		}
+		if err == nil && subject == "" {
+			t.Errorf("%v with %v: expected the subject of the credentials", tc.method, tc.secret)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := &Checker{
+		Active: func() bool { return true },
+		Secret: func(name string) (*apiv1.Secret, error) {
+			if name != "ocm/token" {
+				return nil, fmt.Errorf("secret %v was not found", name)
+			}
+			return &apiv1.Secret{Data: map[string][]byte{TokenKey: []byte("s3cr3t")}}, nil
+		},
+	}
+	h := Handler(c)
+
+	testCases := []struct {
+		secret   string
+		expected int
+	}{
+		{"ocm%2Ftoken", http.StatusOK},
+		{"ocm/token", http.StatusOK},
+		{"ocm%2Fmissing", http.StatusForbidden},
+		{"ocm%2", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/auth/break-glass", nil)
+		r.RemoteAddr = "127.0.0.1:4000"
+		r.Header.Set(MethodHeader, breakglass.Token)
+		r.Header.Set(SecretHeader, tc.secret)
+		r.Header.Set("Authorization", "Bearer s3cr3t")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.expected {
+			t.Errorf("expected %v with the secret %q but returned %v", tc.expected, tc.secret, w.Code)
+		}
+	}
+}
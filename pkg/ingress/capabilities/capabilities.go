@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package capabilities reports the optional subsystems of the controller.
+// A subsystem is enabled when it is compiled in the controller, which the
+// build tag no<name> prevents, and its module is present in NGINX, so
+// minimal images can be built without them.
+package capabilities
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+)
+
+const (
+	// WAF is the ModSecurity web application firewall
+	WAF = "waf"
+	// GeoIP resolves the country of the clients
+	GeoIP = "geoip"
+	// Lua runs the signed Lua filters of the lua-filters annotation
+	Lua = "lua"
+	// OpenTelemetry exports the traces of the requests
+	OpenTelemetry = "otel"
+)
+
+// module describes how a subsystem is found in NGINX: a dynamic module or
+// an argument of the build, printed by nginx -V
+type module struct {
+	file     string
+	argument string
+}
+
+var modules = map[string]module{
+	WAF:           {file: "/etc/nginx/modules/ngx_http_modsecurity_module.so", argument: "ModSecurity-nginx"},
+	GeoIP:         {file: "/etc/nginx/modules/ngx_http_geoip_module.so", argument: "--with-http_geoip_module"},
+	Lua:           {file: "/etc/nginx/modules/ngx_http_lua_module.so", argument: "ngx_lua"},
+	OpenTelemetry: {file: "/etc/nginx/modules/ngx_http_opentracing_module.so", argument: "nginx-opentracing"},
+}
+
+// compiled contains the subsystems compiled in the controller, set by the
+// files guarded by their build tag
+var compiled = map[string]bool{}
+
+// Capability is the state of a subsystem
+type Capability struct {
+	Name string `json:"name"`
+	// Compiled is false when the controller was built with the no<name>
+	// build tag
+	Compiled bool `json:"compiled"`
+	// Available is true when the module is present in NGINX
+	Available bool `json:"available"`
+}
+
+// Enabled returns true if the subsystem can be used
+func (c Capability) Enabled() bool {
+	return c.Compiled && c.Available
+}
+
+func (c Capability) String() string {
+	switch {
+	case !c.Compiled:
+		return fmt.Sprintf("%v=disabled (built with the no%v tag)", c.Name, c.Name)
+	case !c.Available:
+		return fmt.Sprintf("%v=disabled (no NGINX module)", c.Name)
+	default:
+		return fmt.Sprintf("%v=enabled", c.Name)
+	}
+}
+
+// Report contains the state of every subsystem
+type Report struct {
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Get returns the state of the subsystem
+func (r Report) Get(name string) Capability {
+	for _, c := range r.Capabilities {
+		if c.Name == name {
+			return c
+		}
+	}
+	return Capability{Name: name}
+}
+
+// Enabled returns true if the subsystem can be used
+func (r Report) Enabled(name string) bool {
+	return r.Get(name).Enabled()
+}
+
+// Compiled returns true if the subsystem is compiled in the controller
+func Compiled(name string) bool {
+	return compiled[name]
+}
+
+func (r Report) String() string {
+	s := make([]string, 0, len(r.Capabilities))
+	for _, c := range r.Capabilities {
+		s = append(s, c.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+// Detect returns the subsystems compiled in the controller and present in
+// the NGINX binary. Only the dynamic modules are checked when the binary
+// can't be run.
+func Detect(binary string) Report {
+	out, _ := exec.Command(binary, "-V").CombinedOutput()
+	return detect(string(out), func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+}
+
+func detect(version string, exists func(string) bool) Report {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := Report{}
+	for _, name := range names {
+		m := modules[name]
+		r.Capabilities = append(r.Capabilities, Capability{
+			Name:      name,
+			Compiled:  compiled[name],
+			Available: strings.Contains(version, m.argument) || exists(m.file),
+		})
+	}
+	return r
+}
+
+// Apply disables the settings of the configuration that require disabled
+// subsystems, and returns the disabled settings
+func (r Report) Apply(cfg *ngx_config.Configuration) []string {
+	var disabled []string
+	if !r.Enabled(WAF) && (cfg.EnableModsecurity || cfg.EnableOWASPCoreRules) {
+		cfg.EnableModsecurity = false
+		cfg.EnableOWASPCoreRules = false
+		disabled = append(disabled, "enable-modsecurity", "enable-owasp-modsecurity-crs")
+	}
+	if !r.Enabled(OpenTelemetry) && cfg.EnableOpentracing {
+		cfg.EnableOpentracing = false
+		disabled = append(disabled, "enable-opentracing")
+	}
+	if !r.Enabled(GeoIP) && strings.Contains(cfg.VtsDefaultFilterKey, "$geoip_") {
+		// the default key uses the country of the client, only reported
+		// when the VTS status is used
+		cfg.VtsDefaultFilterKey = ""
+		if cfg.EnableVtsStatus {
+			disabled = append(disabled, "vts-default-filter-key")
+		}
+	}
+	return disabled
+}
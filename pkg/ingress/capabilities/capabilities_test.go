@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package capabilities
+
+import (
+	"reflect"
+	"testing"
+
+	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+)
+
+// compileAll compiles every subsystem, whatever the build tags of the test
+func compileAll() func() {
+	previous := compiled
+	compiled = map[string]bool{WAF: true, GeoIP: true, Lua: true, OpenTelemetry: true}
+	return func() { compiled = previous }
+}
+
+func TestDetect(t *testing.T) {
+	defer compileAll()()
+
+	version := "nginx version: openresty/1.19.3.2\nconfigure arguments: --prefix=/opt/ibm/router/nginx --add-module=../ngx_lua-0.10.19"
+	files := map[string]bool{"/etc/nginx/modules/ngx_http_opentracing_module.so": true}
+
+	r := detect(version, func(path string) bool { return files[path] })
+
+	expected := map[string]bool{WAF: false, GeoIP: false, Lua: true, OpenTelemetry: true}
+	for name, enabled := range expected {
+		if r.Enabled(name) != enabled {
+			t.Errorf("expected %v enabled %v but got %v", name, enabled, r)
+		}
+	}
+	if r.Enabled("unknown") {
+		t.Errorf("expected unknown subsystems to be disabled")
+	}
+
+	compiled[Lua] = false
+	r = detect(version, func(path string) bool { return files[path] })
+	if r.Enabled(Lua) {
+		t.Errorf("expected lua to be disabled when not compiled")
+	}
+	if s := r.String(); s != "geoip=disabled (no NGINX module), lua=disabled (built with the nolua tag), "+
+		"otel=enabled, waf=disabled (no NGINX module)" {
+		t.Errorf("unexpected report %q", s)
+	}
+}
+
+func TestApply(t *testing.T) {
+	r := detect("", func(string) bool { return false })
+
+	cfg := ngx_config.NewDefault()
+	cfg.EnableModsecurity = true
+	cfg.EnableOpentracing = true
+	cfg.EnableVtsStatus = true
+	disabled := r.Apply(&cfg)
+
+	expected := []string{"enable-modsecurity", "enable-owasp-modsecurity-crs", "enable-opentracing", "vts-default-filter-key"}
+	if !reflect.DeepEqual(disabled, expected) {
+		t.Errorf("expected %v to be disabled but got %v", expected, disabled)
+	}
+	if cfg.EnableModsecurity || cfg.EnableOpentracing || cfg.VtsDefaultFilterKey != "" {
+		t.Errorf("expected the settings of the missing modules to be disabled")
+	}
+
+	// the GeoIP variables of the default key are removed without warning
+	// when the VTS status is not used
+	cfg = ngx_config.NewDefault()
+	if disabled := r.Apply(&cfg); len(disabled) > 0 || cfg.VtsDefaultFilterKey != "" {
+		t.Errorf("expected the GeoIP key removed silently but got %v and %q", disabled, cfg.VtsDefaultFilterKey)
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build !nolua
+
+package capabilities
+
+func init() {
+	compiled[Lua] = true
+}
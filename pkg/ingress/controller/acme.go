@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/certrevocation"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/replayprotection"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/signedurl"
+)
+
+const (
+	// acmeSolverLabel is the label of the Ingresses created by cert-manager
+	// to solve the HTTP-01 challenges
+	acmeSolverLabel = "acme.cert-manager.io/http01-solver"
+	// acmeChallengePath is the prefix of the paths of the HTTP-01 challenges
+	acmeChallengePath = "/.well-known/acme-challenge/"
+	// acmeGroup and acmeChallengeKind are the resource of cert-manager
+	// owning the Ingresses of the solvers
+	acmeGroup         = "acme.cert-manager.io"
+	acmeChallengeKind = "Challenge"
+)
+
+// isACMESolver returns true if the Ingress was created by cert-manager to
+// solve an ACME HTTP-01 challenge: it has the label of the solvers and is
+// controlled by a Challenge. The label alone can be set by any user
+// creating Ingresses.
+func isACMESolver(ing *networking.Ingress) bool {
+	if ing.Labels[acmeSolverLabel] != "true" {
+		return false
+	}
+	ref := metav1.GetControllerOf(ing)
+	if ref == nil || ref.Kind != acmeChallengeKind {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	return err == nil && gv.Group == acmeGroup
+}
+
+// isACMEChallengePath returns true if path is the path of the token of an
+// ACME HTTP-01 challenge
+func isACMEChallengePath(path string) bool {
+	token := strings.TrimPrefix(path, acmeChallengePath)
+	return token != path && token != "" && !strings.Contains(token, "/")
+}
+
+// servedIngress returns the Ingress served by the controller, nil if it is
+// not served: the Ingresses of its class and, with ACMEChallenges, the
+// challenge paths of the solvers of cert-manager of any class
+func (n *NGINXController) servedIngress(ing *networking.Ingress) *networking.Ingress {
+	if class.IsValid(ing) {
+		return ing
+	}
+	if n.cfg.ACMEChallenges && isACMESolver(ing) {
+		return acmeSolverIngress(ing)
+	}
+	return nil
+}
+
+// isValidIngress returns true if the controller serves the Ingress
+func (n *NGINXController) isValidIngress(ing *networking.Ingress) bool {
+	return n.servedIngress(ing) != nil
+}
+
+// acmeSolverIngress returns the Ingress of a solver of another class with
+// only the Exact paths of the challenges, without its TLS, default backend
+// and annotations, or nil if it has no challenge path
+func acmeSolverIngress(ing *networking.Ingress) *networking.Ingress {
+	solver := &networking.Ingress{
+		ObjectMeta: *ing.ObjectMeta.DeepCopy(),
+	}
+	solver.Annotations = map[string]string{}
+	if value, ok := ing.Annotations[class.IngressKey]; ok {
+		solver.Annotations[class.IngressKey] = value
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := []networking.HTTPIngressPath{}
+		for _, path := range rule.HTTP.Paths {
+			if path.PathType == nil || *path.PathType != networking.PathTypeExact ||
+				!isACMEChallengePath(path.Path) {
+				continue
+			}
+			paths = append(paths, *path.DeepCopy())
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		solver.Spec.Rules = append(solver.Spec.Rules, networking.IngressRule{
+			Host: rule.Host,
+			IngressRuleValue: networking.IngressRuleValue{
+				HTTP: &networking.HTTPIngressRuleValue{Paths: paths},
+			},
+		})
+	}
+	if len(solver.Spec.Rules) == 0 {
+		return nil
+	}
+	return solver
+}
+
+// exemptACMEChallenge serves the location of the challenge of a solver of
+// cert-manager as is, with the highest priority and without the
+// authentication of the rest of its Ingress. Other locations are not
+// changed.
+func exemptACMEChallenge(loc *ingress.Location) {
+	if loc.Ingress == nil || !isACMESolver(loc.Ingress) || !isACMEChallengePath(loc.Path) {
+		return
+	}
+
+	// an exact location has precedence over the regular expressions
+	loc.LocationModifier = "="
+	loc.Rewrite = rewrite.Config{}
+	loc.UpstreamURI = ""
+	loc.AuthType = ""
+	loc.AuthzType = ""
+	loc.AllowedServiceAccounts = nil
+	loc.SignedURL = signedurl.Config{}
+	loc.ReplayProtection = replayprotection.Config{}
+	loc.ClientCertRevocation = certrevocation.Config{}
+	loc.LuaFilters = nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/signedurl"
+)
+
+// buildACMESolver returns an Ingress of the solver of cert-manager of
+// another class with the paths
+func buildACMESolver(paths ...string) *networking.Ingress {
+	exact := networking.PathTypeExact
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:        "cm-acme-http-solver-x2k9f",
+		Namespace:   "ns",
+		Annotations: map[string]string{class.IngressKey: "openshift-default", "ingress.open-cluster-management.io/configuration-snippet": "return 200;"},
+		Labels:      map[string]string{acmeSolverLabel: "true"},
+		OwnerReferences: []metav1.OwnerReference{
+			*metav1.NewControllerRef(&metav1.ObjectMeta{Name: "console-tls-1-challenge", UID: "ch"},
+				schema.GroupVersionKind{Group: acmeGroup, Version: "v1", Kind: acmeChallengeKind}),
+		},
+	}}
+	rule := networking.IngressRule{
+		Host:             "console.hub.example.com",
+		IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{}},
+	}
+	for _, path := range paths {
+		rule.HTTP.Paths = append(rule.HTTP.Paths, networking.HTTPIngressPath{Path: path, PathType: &exact})
+	}
+	ing.Spec.Rules = []networking.IngressRule{rule}
+	ing.Spec.TLS = []networking.IngressTLS{{Hosts: []string{"console.hub.example.com"}, SecretName: "stolen"}}
+	return ing
+}
+
+func TestIsValidIngress(t *testing.T) {
+	solver := buildACMESolver("/.well-known/acme-challenge/Rv4bX9FOc2n7k")
+	other := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:        "console",
+		Annotations: map[string]string{class.IngressKey: "openshift-default"},
+	}}
+	labelled := solver.DeepCopy()
+	labelled.OwnerReferences = nil
+
+	n := &NGINXController{cfg: &Configuration{ACMEChallenges: true}}
+	if !n.isValidIngress(solver) || n.isValidIngress(other) {
+		t.Errorf("expected only the ACME solver of another class to be valid")
+	}
+	if n.isValidIngress(labelled) {
+		t.Errorf("expected an Ingress of another class with only the label of the solvers to be ignored")
+	}
+	n.cfg.ACMEChallenges = false
+	if n.isValidIngress(solver) {
+		t.Errorf("expected the ACME solver of another class to be ignored when disabled")
+	}
+}
+
+func TestServedACMESolver(t *testing.T) {
+	n := &NGINXController{cfg: &Configuration{ACMEChallenges: true}}
+
+	hijack := buildACMESolver("/", "/api")
+	if served := n.servedIngress(hijack); served != nil {
+		t.Errorf("expected the solver of another class without challenge paths to be rejected but got %+v", served)
+	}
+
+	solver := buildACMESolver("/.well-known/acme-challenge/Rv4bX9FOc2n7k", "/api", "/.well-known/acme-challenge/")
+	served := n.servedIngress(solver)
+	if served == nil {
+		t.Fatalf("expected the solver of another class to be served")
+	}
+	paths := served.Spec.Rules[0].HTTP.Paths
+	if len(paths) != 1 || paths[0].Path != "/.well-known/acme-challenge/Rv4bX9FOc2n7k" {
+		t.Errorf("expected only the challenge path to be served but got %+v", paths)
+	}
+	if len(served.Spec.TLS) != 0 {
+		t.Errorf("expected the TLS of the solver of another class to be ignored")
+	}
+	expected := map[string]string{class.IngressKey: "openshift-default"}
+	if !reflect.DeepEqual(served.Annotations, expected) {
+		t.Errorf("expected the annotations %v but got %v", expected, served.Annotations)
+	}
+}
+
+func TestExemptACMEChallenge(t *testing.T) {
+	solver := buildACMESolver("/.well-known/acme-challenge/Rv4bX9FOc2n7k")
+	loc := &ingress.Location{
+		Path:                   "/.well-known/acme-challenge/Rv4bX9FOc2n7k",
+		Ingress:                solver,
+		AuthType:               ingress.IDToken,
+		AuthzType:              "rbac",
+		AllowedServiceAccounts: []string{"ns/*"},
+		Rewrite:                rewrite.Config{Target: "/"},
+		SignedURL:              signedurl.Config{Secret: "ns/keys"},
+		LuaFilters:             []string{"sign"},
+	}
+	exemptACMEChallenge(loc)
+	expected := &ingress.Location{
+		Path:             "/.well-known/acme-challenge/Rv4bX9FOc2n7k",
+		Ingress:          solver,
+		LocationModifier: "=",
+	}
+	if !reflect.DeepEqual(loc, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, loc)
+	}
+
+	labelled := solver.DeepCopy()
+	labelled.OwnerReferences = nil
+	for _, test := range []struct {
+		path string
+		ing  *networking.Ingress
+	}{
+		{"/", solver},
+		{"/.well-known/acme-challenge/", solver},
+		{"/api/.well-known/acme-challenge/token", solver},
+		{"/.well-known/acme-challenge/token", labelled},
+		{"/.well-known/acme-challenge/token", nil},
+	} {
+		loc := &ingress.Location{Path: test.path, Ingress: test.ing, AuthType: ingress.IDToken}
+		exemptACMEChallenge(loc)
+		if loc.AuthType != ingress.IDToken || loc.LocationModifier != "" {
+			t.Errorf("expected location %v not to change but returned %+v", test.path, loc)
+		}
+	}
+}
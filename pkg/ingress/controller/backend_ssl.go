@@ -31,7 +31,6 @@ import (
 	networking "k8s.io/api/networking/v1"
 
 	"github.com/stolostron/management-ingress/pkg/ingress"
-	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
 	"github.com/stolostron/management-ingress/pkg/net/ssl"
 )
@@ -173,9 +172,8 @@ func (ic *NGINXController) checkSSLChainIssues() {
 // In this case we call syncSecret.
 func (ic *NGINXController) checkMissingSecrets() {
 	for _, obj := range ic.listers.Ingress.List() {
-		ing := obj.(*networking.Ingress)
-
-		if !class.IsValid(ing) {
+		ing := ic.servedIngress(obj.(*networking.Ingress))
+		if ing == nil {
 			continue
 		}
 
@@ -190,6 +188,12 @@ func (ic *NGINXController) checkMissingSecrets() {
 			}
 		}
 
+		if key := ic.sharedCertificateKey(ing); key != "" {
+			if _, ok := ic.sslCertTracker.Get(key); !ok {
+				ic.syncSecret(key)
+			}
+		}
+
 		key, _ := parser.GetStringAnnotation("auth-tls-secret", ing)
 		if key == "" {
 			continue
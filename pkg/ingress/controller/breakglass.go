@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/breakglass"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// breakGlassFile is the state file read by NGINX with the state of the
+// break-glass authentication, active or inactive
+const breakGlassFile = "break-glass"
+
+// breakGlassSecret returns a secret used by an Ingress for its break-glass
+// authentication, so the break-glass handler can not read other secrets
+func (n *NGINXController) breakGlassSecret(name string) (*apiv1.Secret, error) {
+	for _, item := range n.listers.IngressAnnotation.List() {
+		if item.(*annotations.Ingress).BreakGlass.Secret == name {
+			return n.listers.Secret.GetByName(name)
+		}
+	}
+	return nil, fmt.Errorf("the secret %v is not used for the break-glass authentication", name)
+}
+
+// BreakGlassActive returns true while the break-glass authentication of
+// the locations is accepted
+func (n *NGINXController) BreakGlassActive() bool {
+	return atomic.LoadInt32(&n.breakGlassActive) == 1
+}
+
+// BreakGlassChecker returns the checker of the break-glass authentication,
+// used by NGINX in a subrequest
+func (n *NGINXController) BreakGlassChecker() *breakglass.Checker {
+	return &breakglass.Checker{
+		Active: n.BreakGlassActive,
+		Secret: n.breakGlassSecret,
+	}
+}
+
+// updateBreakGlass activates the break-glass authentication when the OIDC
+// issuer has been unreachable for --break-glass-after, and deactivates it
+// once the issuer is reachable. While active, every preflight run logs a
+// warning and emits an event in the pod of the controller.
+func (n *NGINXController) updateBreakGlass(reachable bool, now time.Time) {
+	if n.cfg.BreakGlassAfter <= 0 {
+		return
+	}
+
+	pod := podReference()
+	if reachable {
+		n.issuerDownSince = time.Time{}
+		if atomic.SwapInt32(&n.breakGlassActive, 0) == 1 {
+			glog.Infof("the OIDC issuer is reachable again, deactivating the break-glass authentication")
+			if pod.Name != "" && pod.Namespace != "" {
+				n.recorder.Event(pod, apiv1.EventTypeNormal, "BreakGlassDeactivated", "the OIDC issuer is reachable, the break-glass authentication is not accepted anymore")
+			}
+		}
+	} else {
+		if n.issuerDownSince.IsZero() {
+			n.issuerDownSince = now
+		}
+		down := now.Sub(n.issuerDownSince)
+		if down < n.cfg.BreakGlassAfter {
+			return
+		}
+		atomic.StoreInt32(&n.breakGlassActive, 1)
+		glog.Warningf("the OIDC issuer is unreachable since %v, the break-glass authentication is active", n.issuerDownSince.Format(time.RFC3339))
+		if pod.Name != "" && pod.Namespace != "" {
+			n.recorder.Eventf(pod, apiv1.EventTypeWarning, "BreakGlassActive", "the OIDC issuer is unreachable for %v, the break-glass authentication is accepted",
+				down.Round(time.Second))
+		}
+	}
+
+	active := n.BreakGlassActive()
+	metric.SetBreakGlassActive(active)
+	state := map[string]bool{}
+	content := "inactive\n"
+	if active {
+		state["active"] = true
+		content = "active\n"
+	}
+	if err := n.breakGlass.replace(state, []byte(content)); err != nil {
+		glog.Warningf("unexpected error writing the state of the break-glass authentication: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+)
+
+func TestUpdateBreakGlass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "break-glass")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := &NGINXController{
+		cfg:        &Configuration{BreakGlassAfter: 5 * time.Minute},
+		recorder:   record.NewFakeRecorder(10),
+		breakGlass: newStateFile(dir, breakGlassFile),
+	}
+	state := func() string {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, breakGlassFile))
+		return string(b)
+	}
+
+	now := time.Now()
+	n.updateBreakGlass(false, now)
+	n.updateBreakGlass(false, now.Add(4*time.Minute))
+	if n.BreakGlassActive() || state() != "" {
+		t.Errorf("expected the break-glass authentication to be inactive before --break-glass-after")
+	}
+
+	n.updateBreakGlass(false, now.Add(5*time.Minute))
+	if !n.BreakGlassActive() || state() != "active\n" {
+		t.Errorf("expected the break-glass authentication to be active but the state is %q", state())
+	}
+
+	n.updateBreakGlass(true, now.Add(6*time.Minute))
+	if n.BreakGlassActive() || state() != "inactive\n" {
+		t.Errorf("expected the break-glass authentication to be inactive but the state is %q", state())
+	}
+	n.updateBreakGlass(false, now.Add(7*time.Minute))
+	if n.BreakGlassActive() {
+		t.Errorf("expected the outage to be measured again after the issuer was reachable")
+	}
+}
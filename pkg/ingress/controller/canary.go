@@ -0,0 +1,229 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// canaryAttempts is the number of probes of a route after a reload
+	// before it is considered failed, as the old workers can serve the
+	// first requests
+	canaryAttempts = 3
+	// canaryInterval is the time between the probes of a route
+	canaryInterval = time.Second
+	// canaryTimeout is the timeout of a probe
+	canaryTimeout = 5 * time.Second
+	// canaryDeadline is the time the probes of all the routes can take
+	canaryDeadline = 15 * time.Second
+)
+
+// canaryProber sends the probes of the canary routes to the local
+// listeners, with the host of the route
+type canaryProber struct {
+	httpAddr  string
+	httpsAddr string
+	interval  time.Duration
+	// deadline is the time the probes of all the routes can take
+	deadline time.Duration
+}
+
+// probe returns an error if the route does not respond or responds with a
+// 5xx status. The redirects are not followed.
+func (p canaryProber) probe(ctx context.Context, route string) error {
+	u, err := url.Parse(route)
+	if err != nil {
+		return err
+	}
+
+	addr := p.httpAddr
+	if u.Scheme == "https" {
+		addr = p.httpsAddr
+	}
+	client := &http.Client{
+		Timeout: canaryTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+			// #nosec
+			TLSClientConfig:   &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, route, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeAttempts probes route until it passes, the attempts run out or ctx
+// is done, and returns the error of the last attempt
+func (p canaryProber) probeAttempts(ctx context.Context, route string) error {
+	err := p.probe(ctx, route)
+	for attempt := 1; attempt < canaryAttempts && err != nil; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.interval):
+		}
+		err = p.probe(ctx, route)
+	}
+	return err
+}
+
+// probeAll returns the error of every route failing all the attempts. The
+// routes are probed concurrently, and the attempts stop at the deadline.
+func (p canaryProber) probeAll(routes []string) map[string]error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.deadline)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := make(map[string]error)
+	for _, route := range routes {
+		wg.Add(1)
+		go func(route string) {
+			defer wg.Done()
+			if err := p.probeAttempts(ctx, route); err != nil {
+				mu.Lock()
+				failed[route] = err
+				mu.Unlock()
+			}
+		}(route)
+	}
+	wg.Wait()
+	return failed
+}
+
+// regressions returns the failed routes that passed the previous
+// verification, sorted. Every route is expected to pass before the first
+// verification.
+func regressions(failed map[string]error, passed map[string]bool) []string {
+	var routes []string
+	for route := range failed {
+		if passed == nil || passed[route] {
+			routes = append(routes, route)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// configurationHash returns the hash of a configuration rejected by the
+// canary routes
+func configurationHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkRejected returns an error if the configuration was rolled back by
+// the canary routes, so it is not reloaded again until it changes
+func (n *NGINXController) checkRejected(content []byte) error {
+	if n.canaryRejected != "" && n.canaryRejected == configurationHash(content) {
+		return fmt.Errorf("the configuration was rolled back by the canary routes, waiting for a change")
+	}
+	return nil
+}
+
+// verifyReload probes the canary routes after the reload of content. If a
+// route that passed before fails, the previous configuration is restored,
+// content is not reloaded again until it changes, and an error is
+// returned. Without a verified previous configuration the new one is kept
+// and a warning event is emitted.
+func (n *NGINXController) verifyReload(previous, content []byte) error {
+	if len(n.cfg.CanaryRoutes) == 0 {
+		return nil
+	}
+
+	prober := canaryProber{
+		httpAddr:  fmt.Sprintf("127.0.0.1:%v", n.cfg.ListenPorts.HTTP),
+		httpsAddr: fmt.Sprintf("127.0.0.1:%v", n.cfg.ListenPorts.HTTPS),
+		interval:  canaryInterval,
+		deadline:  canaryDeadline,
+	}
+	failed := prober.probeAll(n.cfg.CanaryRoutes)
+	regressed := regressions(failed, n.canaryPassed)
+
+	passed := make(map[string]bool, len(n.cfg.CanaryRoutes))
+	for _, route := range n.cfg.CanaryRoutes {
+		passed[route] = failed[route] == nil
+	}
+
+	if len(regressed) == 0 {
+		n.canaryPassed = passed
+		n.canaryRejected = ""
+		metric.IncReloadVerification("pass")
+		return nil
+	}
+
+	var reasons []string
+	for _, route := range regressed {
+		reasons = append(reasons, fmt.Sprintf("%v (%v)", route, failed[route]))
+	}
+	summary := strings.Join(reasons, ", ")
+	pod := podReference()
+	hasPod := pod.Name != "" && pod.Namespace != ""
+
+	// the configuration before the first verification, like the one of
+	// the image, is not restored
+	if len(previous) > 0 && n.canaryPassed != nil {
+		err := n.writeConfig(previous)
+		if err == nil {
+			err = n.master.Reload()
+		}
+		if err == nil {
+			glog.Errorf("canary routes failed after the reload, restored the previous configuration: %v", summary)
+			metric.IncReloadVerification("rollback")
+			n.canaryRejected = configurationHash(content)
+			if hasPod {
+				n.recorder.Eventf(pod, apiv1.EventTypeWarning, "ReloadRolledBack",
+					"canary routes failed, restored the previous configuration until the next change: %v", summary)
+			}
+			return fmt.Errorf("canary routes failed after the reload: %v", summary)
+		}
+		glog.Errorf("unexpected error restoring the previous configuration: %v", err)
+	}
+
+	glog.Errorf("canary routes failed after the reload and the previous configuration can not be restored: %v", summary)
+	metric.IncReloadVerification("regressed")
+	n.canaryPassed = passed
+	if hasPod {
+		n.recorder.Eventf(pod, apiv1.EventTypeWarning, "ReloadRegressed", "canary routes failed after the reload: %v", summary)
+	}
+	return nil
+}
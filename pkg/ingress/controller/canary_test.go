@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanaryProber(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Host != "console.example.com":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/login":
+			http.Redirect(w, r, "https://oauth.example.com/authorize", http.StatusFound)
+		}
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	p := canaryProber{
+		httpAddr:  strings.TrimPrefix(plain.URL, "http://"),
+		httpsAddr: strings.TrimPrefix(secure.URL, "https://"),
+		deadline:  canaryDeadline,
+	}
+	failed := p.probeAll([]string{
+		"http://console.example.com/healthz",
+		"https://console.example.com/login",
+		"https://console.example.com/broken",
+	})
+	if len(failed) != 1 || failed["https://console.example.com/broken"] == nil {
+		t.Errorf("expected only the broken route to fail but returned %v", failed)
+	}
+}
+
+func TestCanaryProberDeadline(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	p := canaryProber{
+		httpAddr: strings.TrimPrefix(slow.URL, "http://"),
+		interval: time.Millisecond,
+		deadline: 100 * time.Millisecond,
+	}
+	start := time.Now()
+	failed := p.probeAll([]string{
+		"http://a.example.com/",
+		"http://b.example.com/",
+		"http://c.example.com/",
+	})
+	if len(failed) != 3 {
+		t.Errorf("expected every route to fail at the deadline but returned %v", failed)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the routes probed concurrently within the deadline but took %v", elapsed)
+	}
+}
+
+func TestCheckRejected(t *testing.T) {
+	n := &NGINXController{}
+	content := []byte("http {}")
+	if err := n.checkRejected(content); err != nil {
+		t.Errorf("expected no error without a rejected configuration but returned %v", err)
+	}
+
+	n.canaryRejected = configurationHash(content)
+	if err := n.checkRejected(content); err == nil {
+		t.Errorf("expected an error reloading the rejected configuration again")
+	}
+	if err := n.checkRejected([]byte("http { server {} }")); err != nil {
+		t.Errorf("expected no error after the configuration changed but returned %v", err)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	failed := map[string]error{
+		"https://a/": fmt.Errorf("status 502"),
+		"https://b/": fmt.Errorf("status 503"),
+	}
+
+	if res := regressions(failed, nil); !reflect.DeepEqual(res, []string{"https://a/", "https://b/"}) {
+		t.Errorf("expected every failed route to regress before the first verification but returned %v", res)
+	}
+	passed := map[string]bool{"https://a/": false, "https://b/": true}
+	if res := regressions(failed, passed); !reflect.DeepEqual(res, []string{"https://b/"}) {
+		t.Errorf("expected only the route that passed before to regress but returned %v", res)
+	}
+}
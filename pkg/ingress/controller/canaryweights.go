@@ -0,0 +1,207 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/canary"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+// canaryWeightsFile is the state file with the weights and the header
+// routes of the canaries, read by NGINX without a reload
+const canaryWeightsFile = "canary-weights"
+
+var (
+	// ErrIngressNotFound is returned for the Ingresses not in the store
+	ErrIngressNotFound = errors.New("the ingress does not exist")
+	// ErrNoCanary is returned for the Ingresses without a canary backend
+	ErrNoCanary = errors.New("the ingress has no canary-backend annotation")
+	// ErrInvalidCanaryWeight is returned for the weights and header routes
+	// rejected by the annotations
+	ErrInvalidCanaryWeight = errors.New("invalid canary weight")
+)
+
+// CanaryWeight is the share of the requests of an Ingress sent to its
+// canary backend
+type CanaryWeight struct {
+	Namespace string `json:"namespace"`
+	Ingress   string `json:"ingress"`
+	// Weight is the percentage of the requests sent to the canary
+	Weight int `json:"weight"`
+	// Header and HeaderValue route the requests with the value in the
+	// header to the canary, none if empty
+	Header      string `json:"header,omitempty"`
+	HeaderValue string `json:"headerValue,omitempty"`
+}
+
+func canaryUpstreamName(ing *networking.Ingress, c canary.Config) string {
+	return fmt.Sprintf("%v-%v-%v", ing.GetNamespace(), c.Service, c.Port)
+}
+
+// createCanaryUpstream creates the upstream of the canary backend of the
+// Ingress. The canary uses the upstream settings of the Ingress.
+func (n *NGINXController) createCanaryUpstream(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) {
+	if !anns.Canary.Enabled() {
+		return
+	}
+	name := canaryUpstreamName(ing, anns.Canary)
+	if _, ok := upstreams[name]; ok {
+		return
+	}
+
+	glog.V(3).Infof("creating upstream %v of the canary of ingress %v/%v", name, ing.Namespace, ing.Name)
+	ups := newUpstream(name)
+	ups.Port = intstr.FromInt(anns.Canary.Port)
+	ups.Secure = anns.SecureUpstream.Secure
+	ups.SecureCACert = anns.SecureUpstream.CACert
+	ups.ClientCACert = anns.SecureUpstream.ClientCACert
+	ups.IPFamily = anns.IPFamily.Upstream
+
+	svcKey := fmt.Sprintf("%v/%v", ing.GetNamespace(), anns.Canary.Service)
+	s, err := n.listers.Service.GetByName(svcKey)
+	if err != nil {
+		glog.Warningf("error obtaining service of the canary: %v", err)
+		return
+	}
+	ups.Service = s
+	ups.ClusterIP = serviceClusterIP(s, ups.IPFamily)
+	upstreams[name] = ups
+}
+
+// canaryConfig returns the canary of the locations of the Ingress, with
+// the upstream of the canary if it is available
+func canaryConfig(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) canary.Config {
+	c := anns.Canary
+	if !c.Enabled() {
+		return c
+	}
+	name := canaryUpstreamName(ing, c)
+	if ups, ok := upstreams[name]; ok && ups.HasAddress() {
+		c.Upstream = name
+	}
+	return c
+}
+
+// canaryWeights returns the keys of the Ingresses with a canary and the
+// content of the canary weights file, one line per Ingress with its key,
+// weight, header and value, sorted by key. The missing header routes are
+// written as -.
+func canaryWeights(anns []*annotations.Ingress) (map[string]bool, []byte) {
+	sort.Slice(anns, func(i, j int) bool {
+		if anns[i].Namespace != anns[j].Namespace {
+			return anns[i].Namespace < anns[j].Namespace
+		}
+		return anns[i].Name < anns[j].Name
+	})
+
+	var buf bytes.Buffer
+	keys := map[string]bool{}
+	for _, a := range anns {
+		if !a.Canary.Enabled() {
+			continue
+		}
+		key := fmt.Sprintf("%v/%v", a.Namespace, a.Name)
+		keys[key] = true
+		header, value := a.Canary.Header, a.Canary.HeaderValue
+		if header == "" {
+			header, value = "-", "-"
+		}
+		fmt.Fprintf(&buf, "%v %v %v %v\n", key, a.Canary.Weight, header, value)
+	}
+	return keys, buf.Bytes()
+}
+
+// updateCanaryWeights writes the weights of the canaries of the Ingresses
+func (n *NGINXController) updateCanaryWeights() {
+	var anns []*annotations.Ingress
+	for _, item := range n.listers.IngressAnnotation.List() {
+		anns = append(anns, item.(*annotations.Ingress))
+	}
+	if err := n.canaryWeights.replace(canaryWeights(anns)); err != nil {
+		glog.Warningf("unexpected error writing the canary weights: %v", err)
+	}
+}
+
+// CanaryWeight returns the weight and the header route of the canary of an
+// Ingress
+func (n *NGINXController) CanaryWeight(namespace, name string) (*CanaryWeight, error) {
+	obj, exists, err := n.listers.Ingress.GetByKey(fmt.Sprintf("%v/%v", namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrIngressNotFound
+	}
+	anns := n.getIngressAnnotations(obj.(*networking.Ingress))
+	if !anns.Canary.Enabled() {
+		return nil, ErrNoCanary
+	}
+	return &CanaryWeight{
+		Namespace:   namespace,
+		Ingress:     name,
+		Weight:      anns.Canary.Weight,
+		Header:      anns.Canary.Header,
+		HeaderValue: anns.Canary.HeaderValue,
+	}, nil
+}
+
+// SetCanaryWeight persists the weight and the header route of the canary
+// of an Ingress in its annotations and applies them without a reload
+func (n *NGINXController) SetCanaryWeight(w CanaryWeight) error {
+	if err := canary.ValidWeight(w.Weight); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCanaryWeight, err)
+	}
+	var route interface{}
+	if w.Header != "" {
+		header := fmt.Sprintf("%v=%v", w.Header, w.HeaderValue)
+		if _, _, err := canary.ParseHeaderRoute(header); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCanaryWeight, err)
+		}
+		route = header
+	}
+	if _, err := n.CanaryWeight(w.Namespace, w.Ingress); err != nil {
+		return err
+	}
+
+	// a null annotation is removed by the merge patch
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				parser.GetAnnotationWithPrefix("canary-weight"): strconv.Itoa(w.Weight),
+				parser.GetAnnotationWithPrefix("canary-header"): route,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ing, err := n.cfg.Client.NetworkingV1().Ingresses(w.Namespace).Patch(context.TODO(), w.Ingress,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+
+	// the informer applies the same annotations later, the weights are
+	// written now so the tools see the change at once
+	n.extractAnnotations(ing)
+	n.updateCanaryWeights()
+	glog.Infof("the canary of ingress %v/%v receives %v%% of the requests", w.Namespace, w.Ingress, w.Weight)
+	return nil
+}
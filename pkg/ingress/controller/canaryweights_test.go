@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/canary"
+)
+
+func TestCanaryWeights(t *testing.T) {
+	anns := []*annotations.Ingress{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "api"},
+			Canary:     canary.Config{Service: "api-canary", Port: 80, Weight: 25, Header: "x-canary", HeaderValue: "always"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "console", Name: "console"},
+			Canary:     canary.Config{Service: "console-canary", Port: 3000},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "console", Name: "static"},
+		},
+	}
+
+	keys, content := canaryWeights(anns)
+	if expected := map[string]bool{"search/api": true, "console/console": true}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the Ingresses with a canary %v but returned %v", expected, keys)
+	}
+	expected := "console/console 0 - -\nsearch/api 25 x-canary always\n"
+	if string(content) != expected {
+		t.Errorf("expected the canary weights\n%v\nbut returned\n%v", expected, string(content))
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/revocation"
+)
+
+// revocationSecret returns a secret used by an Ingress to check the
+// revocation of the client certificates, so the revocation handler can
+// not read other secrets
+func (n *NGINXController) revocationSecret(name string) (*apiv1.Secret, error) {
+	if !n.usesRevocationSecret(name) {
+		return nil, fmt.Errorf("the secret %v is not used to check client certificates", name)
+	}
+	return n.listers.Secret.GetByName(name)
+}
+
+// usesRevocationSecret returns true if an Ingress checks the client
+// certificates with the CAs and CRLs of the secret
+func (n *NGINXController) usesRevocationSecret(key string) bool {
+	for _, item := range n.listers.IngressAnnotation.List() {
+		if item.(*annotations.Ingress).ClientCertRevocation.Secret == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCertificateChecker returns the checker of the revocation of the
+// client certificates, used by NGINX in a subrequest
+func (n *NGINXController) ClientCertificateChecker() *revocation.Checker {
+	return n.clientCerts
+}
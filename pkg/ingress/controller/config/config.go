@@ -19,6 +19,7 @@ import (
 	"github.com/golang/glog"
 
 	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/sysinfo"
 )
 
 const (
@@ -73,6 +74,13 @@ const (
 	// Parameters for a shared memory zone that will keep states for various keys.
 	// http://nginx.org/en/docs/http/ngx_http_limit_conn_module.html#limit_conn_zone
 	defaultLimitConnZoneVariable = "$binary_remote_addr"
+
+	// Upper limit of worker processes configured automatically
+	maxWorkerProcesses = 16
+
+	// Memory limits used to scale buffers and shared dictionaries
+	smallMemoryLimit = 512 * 1024 * 1024
+	largeMemoryLimit = 4 * 1024 * 1024 * 1024
 )
 
 // Configuration represents the content of nginx.conf file
@@ -321,6 +329,8 @@ type Configuration struct {
 
 	// Enables or disables the use of the nginx module that compresses responses using the "gzip" method
 	// http://nginx.org/en/docs/http/ngx_http_gzip_module.html
+	// By default this is disabled: compressing responses with secrets, like CSRF tokens, and data
+	// reflected from the request exposes them to compression side channels like BREACH
 	UseGzip bool `json:"use-gzip,omitempty"`
 
 	// Enables or disables the use of the NGINX Brotli Module for compression
@@ -346,6 +356,52 @@ type Configuration struct {
 	// http://nginx.org/en/docs/ngx_core_module.html#worker_processes
 	WorkerProcesses string `json:"worker-processes,omitempty"`
 
+	// Defines the user and group used by the worker processes when the master
+	// process runs as root. Ignored by NGINX otherwise.
+	// http://nginx.org/en/docs/ngx_core_module.html#user
+	WorkerUser string `json:"worker-user,omitempty" since:"2.8.0"`
+
+	// LuaSharedDictTokensSize sets the size of the shared memory zone used to
+	// cache validated tokens between worker processes
+	// https://github.com/openresty/lua-nginx-module#lua_shared_dict
+	// By default it is tuned using the memory limit of the container
+	LuaSharedDictTokensSize string `json:"lua-shared-dict-tokens-size,omitempty" since:"2.8.0"`
+
+	// LuaSocketPoolSize sets the number of idle connections of the Lua
+	// cosockets kept per worker and upstream
+	// https://github.com/openresty/lua-nginx-module#lua_socket_pool_size
+	// By default it is tuned using the memory limit of the container
+	LuaSocketPoolSize int `json:"lua-socket-pool-size,omitempty" since:"2.8.0"`
+
+	// LuaRegexCacheMaxEntries sets the number of compiled regular
+	// expressions of the Lua code cached per worker
+	// https://github.com/openresty/lua-nginx-module#lua_regex_cache_max_entries
+	// By default it is tuned using the memory limit of the container
+	LuaRegexCacheMaxEntries int `json:"lua-regex-cache-max-entries,omitempty" since:"2.8.0"`
+
+	// RequestNormalization sets how strictly the request URIs are checked before
+	// they are proxied. permissive rejects NUL bytes, invalid percent-encoding and
+	// .. segments, strict also rejects encoded slashes, double encoding and
+	// repeated slashes. off disables the checks.
+	// Default: permissive
+	RequestNormalization string `json:"request-normalization,omitempty" since:"2.8.0"`
+
+	// MaxRequestHeaders is the maximum number of headers of a request. Requests
+	// with more headers are rejected with 431 unless RequestNormalization is off
+	// Default: 100
+	MaxRequestHeaders int `json:"max-request-headers,omitempty" since:"2.8.0"`
+
+	// EnableBackendMetrics enables the requests and active requests metrics
+	// per backend Service, used to autoscale the backends on the traffic
+	// seen by the ingress controller
+	// Default: true
+	EnableBackendMetrics bool `json:"enable-backend-metrics,omitempty" since:"2.8.0"`
+
+	// ClusterDomain is the DNS domain of the cluster, used in the names of
+	// the Services sent to the service mesh sidecars
+	// Default: cluster.local
+	ClusterDomain string `json:"cluster-domain,omitempty" since:"2.8.0"`
+
 	// Defines a timeout for a graceful shutdown of worker processes
 	// http://nginx.org/en/docs/ngx_core_module.html#worker_shutdown_timeout
 	WorkerShutdownTimeout string `json:"worker-shutdown-timeout,omitempty"`
@@ -427,11 +483,83 @@ type Configuration struct {
 	// Default: 308
 	HTTPRedirectCode int `json:"http-redirect-code"`
 
+	// SecurityTxt is the content of /.well-known/security.txt in all the
+	// servers. Disabled if empty
+	SecurityTxt string `json:"security-txt,omitempty" since:"2.8.0"`
+
+	// RobotsTxt is the content of /robots.txt in all the servers. Disabled
+	// if empty
+	RobotsTxt string `json:"robots-txt,omitempty" since:"2.8.0"`
+
+	// ChangePasswordURL is the redirect of /.well-known/change-password in
+	// all the servers, an absolute URL or a path. Disabled if empty
+	ChangePasswordURL string `json:"change-password-url,omitempty" since:"2.8.0"`
+
+	// HostDefaults are the default headers and banner of the responses of
+	// the hosts, by hostname or wildcard like *.apps.example.com
+	HostDefaults map[string]HostDefaults `json:"host-defaults,omitempty" since:"2.8.0"`
+
+	// RequestTiers are the limits of the requests of each tier, by tier
+	// name. The requests are not classified if empty
+	RequestTiers map[string]RequestTier `json:"request-tiers,omitempty" since:"2.8.0"`
+
+	// RequestClassifiers assign the requests to the tiers, the first one
+	// matching wins. DefaultRequestClassifiers are used if empty
+	RequestClassifiers []RequestClassifier `json:"request-classifiers,omitempty" since:"2.8.0"`
+
 	// Name server/s used to resolve names of upstream servers into IP addresses.
 	// The file /etc/resolv.conf is used as DNS resolution configuration.
 	Resolver []net.IP
 }
 
+// HostDefaults are applied to all the locations of a host
+type HostDefaults struct {
+	// Headers are added to the responses, replacing the default security
+	// headers with the same name
+	Headers map[string]string `json:"headers,omitempty"`
+	// Banner is a text shown at the top of the HTML pages, like a
+	// classification banner
+	Banner string `json:"banner,omitempty"`
+	// BannerColor is the background color of the banner
+	BannerColor string `json:"bannerColor,omitempty"`
+}
+
+// RequestTier contains the limits of the requests of a tier
+type RequestTier struct {
+	// Rate is the number of requests per second accepted from a client
+	// address. Not limited if zero
+	Rate float64 `json:"rate,omitempty"`
+	// Burst is the number of requests over the rate delayed before the
+	// requests are rejected
+	Burst int `json:"burst,omitempty"`
+	// MaxConcurrent is the number of requests of the tier in progress. Not
+	// limited if zero
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// QueueTimeout is the number of seconds a request over MaxConcurrent
+	// waits before it is rejected
+	QueueTimeout int `json:"queueTimeout,omitempty"`
+	// Priority orders the waiting requests of the tiers, the highest first
+	Priority int `json:"priority,omitempty"`
+}
+
+// RequestClassifier matches the requests of a tier
+type RequestClassifier struct {
+	Tier string `json:"tier"`
+	// Methods are the HTTP methods matched, all if empty
+	Methods []string `json:"methods,omitempty"`
+	// Path is a regular expression matched with the path, all if empty
+	Path string `json:"path,omitempty"`
+}
+
+// DefaultRequestClassifiers assign the requests to the auth, static,
+// api-read and api-write tiers
+var DefaultRequestClassifiers = []RequestClassifier{
+	{Tier: "auth", Path: `^/(oauth2?|auth|login|logout|callback|idprovider)(/|$)`},
+	{Tier: "static", Methods: []string{"GET", "HEAD"}, Path: `\.(css|js|map|png|jpe?g|gif|svg|ico|woff2?|ttf|eot)$`},
+	{Tier: "api-read", Methods: []string{"GET", "HEAD", "OPTIONS"}},
+	{Tier: "api-write"},
+}
+
 // TemplateConfig contains the nginx configuration to render the file nginx.conf
 type TemplateConfig struct {
 	ProxySetHeaders map[string]string
@@ -446,13 +574,21 @@ type TemplateConfig struct {
 	IsIPV6Enabled   bool
 	RedirectServers map[string]string
 	ListenPorts     *ListenPorts
+	// TempDir is the writable directory used for the pid and temporal files
+	TempDir string
+	// LuaFiltersDir is the directory with the installed Lua filters
+	LuaFiltersDir            string
+	LuaFilterMaxInstructions int
 }
 
 // ListenPorts describe the ports required to run the
 // NGINX Ingress controller
 type ListenPorts struct {
-	HTTP  int
-	HTTPS int
+	HTTP   int
+	HTTPS  int
+	Status int
+	// Internal is the port NGINX listens on localhost to report its state
+	Internal int
 }
 
 // NewDefault returns the default nginx configuration
@@ -460,11 +596,6 @@ func NewDefault() Configuration {
 	defIPCIDR := make([]string, 0)
 	defIPCIDR = append(defIPCIDR, "0.0.0.0/0")
 	defBindAddress := make([]string, 0)
-	workerProcesses := runtime.NumCPU()
-	// put worker process to no more than 16
-	if workerProcesses > 16 {
-		workerProcesses = 16
-	}
 
 	cfg := Configuration{
 		AllowBackendServerHeader:     false,
@@ -498,6 +629,10 @@ func NewDefault() Configuration {
 		LogFormatStream:              logFormatStream,
 		LogFormatUpstream:            logFormatUpstream,
 		MaxWorkerConnections:         512,
+		MaxRequestHeaders:            100,
+		RequestNormalization:         "permissive",
+		EnableBackendMetrics:         true,
+		ClusterDomain:                "cluster.local",
 		MapHashBucketSize:            64,
 		ProxyRealIPCIDR:              defIPCIDR,
 		ServerNameHashMaxSize:        1024,
@@ -514,8 +649,10 @@ func NewDefault() Configuration {
 		SSLSessionTickets:            true,
 		SSLSessionTimeout:            sslSessionTimeout,
 		EnableBrotli:                 true,
-		UseGzip:                      true,
-		WorkerProcesses:              strconv.Itoa(workerProcesses),
+		UseGzip:                      false,
+		LuaSharedDictTokensSize:      "256k",
+		LuaSocketPoolSize:            30,
+		LuaRegexCacheMaxEntries:      1024,
 		WorkerShutdownTimeout:        "10s",
 		LoadBalanceAlgorithm:         defaultLoadBalancerAlgorithm,
 		VtsStatusZoneSize:            "10m",
@@ -532,6 +669,8 @@ func NewDefault() Configuration {
 		ZipkinServiceName:            "nginx",
 	}
 
+	autotune(&cfg, sysinfo.NumCPU(), sysinfo.MemoryLimit(), sysinfo.CacheLineSize())
+
 	if glog.V(5) {
 		cfg.ErrorLogLevel = "debug"
 	}
@@ -542,3 +681,49 @@ func NewDefault() Configuration {
 
 	return cfg
 }
+
+// UpstreamLogFormat returns the format of the access log set in
+// log-format-upstream, or empty to keep the default format of NGINX
+func (cfg Configuration) UpstreamLogFormat() string {
+	if cfg.LogFormatUpstream == logFormatUpstream {
+		return ""
+	}
+	return cfg.LogFormatUpstream
+}
+
+// autotune adjusts the defaults that depend on the resources available to
+// the container. Every value can still be overridden using the configmap.
+func autotune(cfg *Configuration, cpus int, memLimit int64, cacheLine int) {
+	workerProcesses := cpus
+	// put worker process to no more than 16
+	if workerProcesses > maxWorkerProcesses {
+		workerProcesses = maxWorkerProcesses
+	}
+	if workerProcesses < 1 {
+		workerProcesses = 1
+	}
+	cfg.WorkerProcesses = strconv.Itoa(workerProcesses)
+
+	if cacheLine > cfg.MapHashBucketSize {
+		cfg.MapHashBucketSize = cacheLine
+	}
+
+	switch {
+	case memLimit > 0 && memLimit < smallMemoryLimit:
+		cfg.ClientBodyBufferSize = "8k"
+		cfg.LuaSharedDictTokensSize = "128k"
+		cfg.LuaSocketPoolSize = 16
+		cfg.LuaRegexCacheMaxEntries = 256
+		cfg.MaxWorkerConnections = 512
+	case memLimit == 0 || memLimit >= largeMemoryLimit:
+		cfg.ClientBodyBufferSize = "16k"
+		cfg.LuaSharedDictTokensSize = "1m"
+		cfg.LuaSocketPoolSize = 64
+		cfg.LuaRegexCacheMaxEntries = 4096
+		cfg.MaxWorkerConnections = 1024
+	}
+
+	glog.V(2).Infof("autotuned configuration for %v (cpus=%v memory=%v): worker-processes=%v client-body-buffer-size=%v lua-shared-dict-tokens-size=%v lua-socket-pool-size=%v lua-regex-cache-max-entries=%v",
+		runtime.GOARCH, cpus, memLimit, cfg.WorkerProcesses, cfg.ClientBodyBufferSize, cfg.LuaSharedDictTokensSize,
+		cfg.LuaSocketPoolSize, cfg.LuaRegexCacheMaxEntries)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package config
+
+import (
+	"testing"
+)
+
+func TestAutotune(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		cpus                    int
+		memLimit                int64
+		cacheLine               int
+		workerProcesses         string
+		mapHashBucketSize       int
+		clientBodyBufferSize    string
+		luaSharedDictTokensSize string
+		luaSocketPoolSize       int
+		luaRegexCacheMaxEntries int
+		maxWorkerConnections    int
+	}{
+		{"small memory", 2, 256 * 1024 * 1024, 64, "2", 64, "8k", "128k", 16, 256, 512},
+		{"medium memory", 4, 1024 * 1024 * 1024, 64, "4", 64, "8k", "256k", 30, 1024, 512},
+		{"large memory", 8, 8 * 1024 * 1024 * 1024, 128, "8", 128, "16k", "1m", 64, 4096, 1024},
+		{"unlimited memory", 32, 0, 32, "16", 64, "16k", "1m", 64, 4096, 1024},
+		{"no cpus", 0, 0, 64, "1", 64, "16k", "1m", 64, 4096, 1024},
+	}
+
+	for _, tc := range testCases {
+		cfg := Configuration{
+			MapHashBucketSize:       64,
+			ClientBodyBufferSize:    "8k",
+			LuaSharedDictTokensSize: "256k",
+			LuaSocketPoolSize:       30,
+			LuaRegexCacheMaxEntries: 1024,
+			MaxWorkerConnections:    512,
+		}
+		autotune(&cfg, tc.cpus, tc.memLimit, tc.cacheLine)
+
+		if cfg.WorkerProcesses != tc.workerProcesses {
+			t.Errorf("%v: expected %v worker processes but returned %v", tc.name, tc.workerProcesses, cfg.WorkerProcesses)
+		}
+		if cfg.MapHashBucketSize != tc.mapHashBucketSize {
+			t.Errorf("%v: expected a map hash bucket size of %v but returned %v", tc.name, tc.mapHashBucketSize, cfg.MapHashBucketSize)
+		}
+		if cfg.ClientBodyBufferSize != tc.clientBodyBufferSize {
+			t.Errorf("%v: expected a client body buffer size of %v but returned %v", tc.name, tc.clientBodyBufferSize, cfg.ClientBodyBufferSize)
+		}
+		if cfg.LuaSharedDictTokensSize != tc.luaSharedDictTokensSize {
+			t.Errorf("%v: expected a tokens dict of %v but returned %v", tc.name, tc.luaSharedDictTokensSize, cfg.LuaSharedDictTokensSize)
+		}
+		if cfg.LuaSocketPoolSize != tc.luaSocketPoolSize {
+			t.Errorf("%v: expected a socket pool size of %v but returned %v", tc.name, tc.luaSocketPoolSize, cfg.LuaSocketPoolSize)
+		}
+		if cfg.LuaRegexCacheMaxEntries != tc.luaRegexCacheMaxEntries {
+			t.Errorf("%v: expected %v cached regular expressions but returned %v", tc.name, tc.luaRegexCacheMaxEntries, cfg.LuaRegexCacheMaxEntries)
+		}
+		if cfg.MaxWorkerConnections != tc.maxWorkerConnections {
+			t.Errorf("%v: expected %v worker connections but returned %v", tc.name, tc.maxWorkerConnections, cfg.MaxWorkerConnections)
+		}
+	}
+}
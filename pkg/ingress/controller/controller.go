@@ -12,6 +12,7 @@ restricted by GSA ADP Schedule Contract with IBM Corp.
 package controller
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"sort"
 	"sync/atomic"
@@ -21,17 +22,22 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
 
 	"github.com/stolostron/management-ingress/pkg/ingress"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
-	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/backup"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/proxy"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
 	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
 	"github.com/stolostron/management-ingress/pkg/task"
 )
@@ -48,7 +54,11 @@ const (
 type Configuration struct {
 	APIServerHost  string
 	KubeConfigFile string
-	Client         clientset.Interface
+	// KubeContext is the context of the kubeconfig used out of the
+	// cluster. The current context if empty
+	KubeContext string
+
+	Client clientset.Interface
 
 	ResyncPeriod  time.Duration
 	ConfigMapName string
@@ -56,13 +66,216 @@ type Configuration struct {
 	Namespace string
 
 	DefaultSSLCertificate string
+	// DefaultSSLCertificates are the default certificates of groups of hostnames
+	DefaultSSLCertificates []DefaultCertificate
+	// SharedCertNamespace is the platform namespace whose secrets
+	// can be shared with the Ingresses of other namespaces by alias.
+	// Disabled if empty
+	SharedCertNamespace string
+
+	// ACMEChallenges serves the ACME HTTP-01 challenges of cert-manager of
+	// any class, without authentication
+	ACMEChallenges bool
 
 	UpdateStatus bool
+	// UpdateStatusOnShutdown removes the address from the status of the
+	// Ingresses when the last replica stops
+	UpdateStatusOnShutdown bool
+	// ShutdownGrace is the time the last replica waits on shutdown for a
+	// new one before removing its address from the status
+	ShutdownGrace time.Duration
+	// StatusDryRun logs the changes of the status instead of writing them
+	StatusDryRun bool
 	ElectionID   string
+	// ElectionLockType is the resource of the leader election
+	ElectionLockType string
+	// ElectionNamespace and ElectionLockName are the namespace and the
+	// name of the lock of the leader election, the namespace of the pod
+	// and ElectionID with the class if empty
+	ElectionNamespace string
+	ElectionLockName  string
+	// StatusUpdateInterval is the interval between the syncs of the status
+	// of all the Ingresses
+	StatusUpdateInterval time.Duration
+	// StatusUpdateWorkers is the number of Ingresses whose status is
+	// updated concurrently
+	StatusUpdateWorkers int
+
+	// ExternalDNS publishes the DNS records of the Ingresses for
+	// external-dns, with ExternalDNSTargets instead of the addresses of
+	// their status if set
+	ExternalDNS        bool
+	ExternalDNSTargets []string
+	ExternalDNSTTL     time.Duration
+	// DynamicClient creates the DNSEndpoints and watches the
+	// OIDCRoutePolicies. Only set with the ExternalDNSEndpoints or the
+	// OIDCRoutePolicies feature gates
+	DynamicClient dynamic.Interface
+
+	// TargetGroupARN is the AWS target group where the leader registers
+	// the ready replicas, as TargetGroupType targets on TargetGroupPort,
+	// every TargetGroupInterval. Disabled if empty
+	TargetGroupARN      string
+	TargetGroupType     string
+	TargetGroupPort     int
+	TargetGroupInterval time.Duration
+
+	// VIP is announced by the agent of VIPAgentURL of the node of the
+	// replica elected among the replicas with a healthy data plane,
+	// checked every VIPInterval, and published in the status of the
+	// Ingresses. Disabled if empty
+	VIP         string
+	VIPAgentURL string
+	VIPInterval time.Duration
+
+	// PublishService is the Service, as namespace/name, whose load
+	// balancer addresses are published in the status of the Ingresses.
+	// The addresses of the nodes of the replicas are published if empty
+	PublishService string
+	// PublishStatusAddresses are the static IPs and hostnames published in
+	// the status of the Ingresses, like an external VIP. Disabled if empty
+	PublishStatusAddresses []string
+	// StatusAddressType selects whether the IPs, the hostnames or both
+	// are published for the nodes of the replicas
+	StatusAddressType string
+	// StatusIPFamily selects whether the IPv4, the IPv6 or both IPs of
+	// the nodes of the replicas are published
+	StatusIPFamily string
+	// StatusNodeAddressTypes are the types of the node addresses
+	// published, in order of preference
+	StatusNodeAddressTypes []apiv1.NodeAddressType
+
+	// DeschedulerHint marks the co-located replicas as evictable
+	DeschedulerHint bool
+
+	// StatusRepairQPS limits the requests to the API server of the repairs
+	// of the status of the Ingresses, started every StatusRepairInterval
+	// if set, or through the status port with EnableStatusRepairAPI
+	StatusRepairQPS       float32
+	StatusRepairInterval  time.Duration
+	EnableStatusRepairAPI bool
+
+	// StatusSummaryConfigMap is the ConfigMap, as namespace/name, where the
+	// leader publishes the addresses and the health of the controller.
+	// Disabled if empty
+	StatusSummaryConfigMap string
+
+	// FeatureGates contains the state of the features disabled by default
+	FeatureGates map[string]bool
 
 	ListenPorts *ngx_config.ListenPorts
 
 	SyncRateLimit float32
+	SyncQueueSize int
+
+	// DrainPeriod is the time the locations of a removed backend are kept
+	DrainPeriod time.Duration
+
+	// MaintenanceWindows are the periods the reloads with only deferrable
+	// changes are applied. Reloads are never deferred if empty
+	MaintenanceWindows []MaintenanceWindow
+	// DeferrableChanges are the changes, as kind/action, that can wait
+	// for a maintenance window
+	DeferrableChanges map[string]bool
+
+	// ReportHealth writes the health of the Ingresses in annotations
+	ReportHealth bool
+
+	// ChangeFreezeSelector selects the Ingresses whose changes are held
+	// until approved. Nil disables the change freeze
+	ChangeFreezeSelector labels.Selector
+	// ChangeFreezeConfigMap is the ConfigMap of the approved versions of
+	// the frozen Ingresses, as <namespace>/<name>
+	ChangeFreezeConfigMap string
+
+	// PreflightInterval is the time between reachability checks of the
+	// backends. Zero disables the checks
+	PreflightInterval time.Duration
+	PreflightTimeout  time.Duration
+	// BreakGlassAfter is the time the OIDC issuer must be unreachable
+	// before the break-glass authentication of the locations is accepted
+	BreakGlassAfter time.Duration
+
+	// SPIFFESocket is the unix socket of the SPIFFE Workload API that
+	// provides the X509-SVID presented to the backends. Empty if disabled
+	SPIFFESocket string
+	// UpstreamIdentityInterval is the time between the checks of the
+	// SPIFFE IDs of the endpoints
+	UpstreamIdentityInterval time.Duration
+
+	// ServiceAccountAudience is the audience of the ServiceAccount tokens
+	// accepted in the locations with the service-account auth type
+	ServiceAccountAudience string
+	// AuthCacheTTL is the time the result of the validation of a token
+	// is cached
+	AuthCacheTTL time.Duration
+	// AuthCacheSize is the maximum number of tokens in the cache
+	AuthCacheSize int
+
+	// StartupGate holds the start of NGINX and the readiness until the
+	// default certificate, the StartupGateServices and the OIDC issuer
+	// exist
+	StartupGate bool
+	// StartupGateServices are the services, as <namespace>/<name>,
+	// required to start, like the auth service
+	StartupGateServices []string
+
+	// UnixSocketDirs are the directories with the Unix sockets the
+	// Ingresses can proxy to with the unix-socket annotation
+	UnixSocketDirs []string
+
+	// EnableCanaryAPI exposes the weights of the canaries of the Ingresses
+	// to the progressive delivery tools
+	EnableCanaryAPI bool
+
+	// CanaryRoutes are the URLs probed through the local listeners after
+	// each reload
+	CanaryRoutes []string
+
+	// ConfigHistoryDir is the directory with the last rendered
+	// configurations. Disabled if empty
+	ConfigHistoryDir string
+	// ConfigHistorySize is the number of configurations kept
+	ConfigHistorySize int
+
+	// LuaFilterBundle is the directory with the signed Lua filters
+	LuaFilterBundle          string
+	LuaFilterPublicKey       ed25519.PublicKey
+	LuaFilterMaxInstructions int
+
+	// CapabilitiesConfigMap is the ConfigMap, in the namespace of the
+	// pod, where the replica publishes its capabilities for the operator.
+	// Empty if disabled
+	CapabilitiesConfigMap string
+	CapabilitiesInterval  time.Duration
+
+	// TelemetryEndpoint receives the anonymized usage of the features.
+	// Empty if disabled, always in offline mode
+	TelemetryEndpoint string
+	TelemetryInterval time.Duration
+
+	// AnnotationPlugins are the unix sockets of the external annotation
+	// plugins
+	AnnotationPlugins       []string
+	AnnotationPluginTimeout time.Duration
+
+	ModelCacheDir string
+
+	EnableModelAPI bool
+
+	WebhookURLs    []string
+	WebhookSecret  []byte
+	WebhookRetries int
+
+	// ConfigDir is the directory where nginx.conf is written
+	ConfigDir string
+	// TempDir is the writable directory used for temporal files
+	TempDir string
+
+	LeakDetectorInterval  time.Duration
+	LeakDetectorWindow    int
+	LeakDetectorThreshold float64
+	LeakDetectorDir       string
 }
 
 // SetForceReload sets if the ingress controller should be reloaded or not
@@ -85,11 +298,18 @@ func (n *NGINXController) syncIngress(item interface{}) error {
 		return nil
 	}
 
+	if pinned := n.PinnedRevision(); pinned > 0 {
+		err := n.applyRollback(pinned)
+		n.reportHealth()
+		return err
+	}
+
 	if element, ok := item.(task.Element); ok {
 		if name, ok := element.Key.(string); ok {
 			if obj, exists, _ := n.listers.Ingress.GetByKey(name); exists {
-				ing := obj.(*networking.Ingress)
-				n.readSecrets(ing)
+				if ing := n.servedIngress(obj.(*networking.Ingress)); ing != nil {
+					n.readSecrets(ing)
+				}
 			}
 		}
 	}
@@ -106,7 +326,12 @@ func (n *NGINXController) syncIngress(item interface{}) error {
 	var ingresses []*networking.Ingress
 	for _, ingIf := range ings {
 		ing := ingIf.(*networking.Ingress)
-		if !class.IsValid(ing) {
+		if n.freeze != nil {
+			if ing = n.freeze.served(ing); ing == nil {
+				continue
+			}
+		}
+		if ing = n.servedIngress(ing); ing == nil {
 			continue
 		}
 
@@ -114,29 +339,89 @@ func (n *NGINXController) syncIngress(item interface{}) error {
 	}
 
 	upstreams, servers := n.getBackendServers(ingresses)
+	if n.drain != nil {
+		var next time.Duration
+		upstreams, servers, next = n.drain.apply(n.runningConfig, upstreams, servers, time.Now())
+		if next > 0 {
+			n.drain.schedule(next, func() {
+				n.syncQueue.Enqueue(&networking.Ingress{})
+			})
+		}
+	}
 
 	pcfg := ingress.Configuration{
 		Backends: upstreams,
 		Servers:  servers,
 	}
 
-	if n.runningConfig.Equal(&pcfg) {
+	// the weights of the canaries are applied without a reload
+	n.updateCanaryWeights()
+
+	// the model is reloaded after a rollback even without changes
+	if n.appliedRevision == 0 && n.runningConfig.Equal(&pcfg) {
 		glog.V(3).Infof("skipping backend reload (no changes detected)")
+		if n.health != nil {
+			n.health.applied(ingresses, &pcfg)
+			n.reportHealth()
+		}
 		return nil
 	}
 
+	if n.reloads != nil && atomic.LoadInt32(&n.forceReload) == 0 {
+		changes := modeldiff.Diff(n.runningConfig, &pcfg)
+		if deferred, next := n.reloads.shouldDefer(changes, time.Now()); deferred {
+			glog.Infof("deferring backend reload with %v changes to the next maintenance window in %v", len(changes), next)
+			n.reloads.schedule(next, func() {
+				n.syncQueue.Enqueue(&networking.Ingress{})
+			})
+			n.reportHealth()
+			return nil
+		}
+	}
+
 	glog.Infof("backend reload required")
 
+	// the new locations must find the state of their backends
+	n.updateStateFiles(&pcfg)
+
 	err := n.OnUpdate(pcfg)
 	if err != nil {
+		metric.IncReloadErrorCount()
 		glog.Errorf("unexpected failure restarting the backend: \n%v", err)
+		n.modelEvents.Publish(modeldiff.Event{
+			Timestamp:   time.Now(),
+			Changes:     modeldiff.Diff(n.runningConfig, &pcfg),
+			ReloadError: err.Error(),
+		})
+		if n.health != nil {
+			n.health.failed(err)
+			n.reportHealth()
+		}
 		return err
 	}
 
+	metric.IncReloadCount()
 	glog.Infof("ingress backend successfully reloaded...")
-
-	n.runningConfig = &pcfg
+	n.appliedRevision = 0
+	n.recordRevision(revisionTrigger(item), &pcfg)
+
+	// the snapshots of the streams contain the changes of their sequence
+	changes := modeldiff.Diff(n.runningConfig, &pcfg)
+	n.setRunningConfig(&pcfg)
+	if len(changes) > 0 {
+		n.modelEvents.Publish(modeldiff.Event{
+			Timestamp: time.Now(),
+			Changes:   changes,
+		})
+	}
 	n.SetForceReload(false)
+	if n.reloads != nil {
+		n.reloads.applied()
+	}
+	if n.health != nil {
+		n.health.applied(ingresses, &pcfg)
+		n.reportHealth()
+	}
 
 	return nil
 }
@@ -152,6 +437,10 @@ func (n *NGINXController) readSecrets(ing *networking.Ingress) {
 		n.syncSecret(key)
 	}
 
+	if key := n.sharedCertificateKey(ing); key != "" {
+		n.syncSecret(key)
+	}
+
 	key, _ := parser.GetStringAnnotation("auth-tls-secret", ing)
 	if key == "" {
 		return
@@ -210,12 +499,30 @@ func (n *NGINXController) createUpstreams(data []*networking.Ingress, ku *ingres
 			}
 			if upstreams[defBackend].UpstreamHashBy == "" {
 				upstreams[defBackend].UpstreamHashBy = anns.UpstreamHashBy
+				if anns.UpstreamHashBy != "" {
+					upstreams[defBackend].HashLoadFactor = anns.UpstreamHashLoadFactor
+				}
+			}
+			if upstreams[defBackend].SlowStart == 0 {
+				upstreams[defBackend].SlowStart = anns.SlowStart
 			}
+			if !upstreams[defBackend].OutlierDetection.Enabled {
+				upstreams[defBackend].OutlierDetection = anns.OutlierDetection
+			}
+			n.setBackup(upstreams[defBackend], ing, anns.Backup)
+			n.setUpstreamIdentity(upstreams[defBackend], anns.UpstreamIdentity)
+			n.setServiceMesh(upstreams[defBackend], anns.ServiceMesh)
+			upstreams[defBackend].IPFamily = anns.IPFamily.Upstream
 			if upstreams[defBackend].ClientCACert.Secret == "" {
 				upstreams[defBackend].ClientCACert = anns.SecureUpstream.ClientCACert
 			}
 		}
 
+		if anns.UnixSocket.Path != "" {
+			n.createUnixSocketUpstream(upstreams, ing, anns)
+			continue
+		}
+
 		for _, rule := range ing.Spec.Rules {
 			if rule.HTTP == nil {
 				continue
@@ -252,7 +559,20 @@ func (n *NGINXController) createUpstreams(data []*networking.Ingress, ku *ingres
 
 				if upstreams[name].UpstreamHashBy == "" {
 					upstreams[name].UpstreamHashBy = anns.UpstreamHashBy
+					if anns.UpstreamHashBy != "" {
+						upstreams[name].HashLoadFactor = anns.UpstreamHashLoadFactor
+					}
 				}
+				if upstreams[name].SlowStart == 0 {
+					upstreams[name].SlowStart = anns.SlowStart
+				}
+				if !upstreams[name].OutlierDetection.Enabled {
+					upstreams[name].OutlierDetection = anns.OutlierDetection
+				}
+				n.setBackup(upstreams[name], ing, anns.Backup)
+				n.setUpstreamIdentity(upstreams[name], anns.UpstreamIdentity)
+				n.setServiceMesh(upstreams[name], anns.ServiceMesh)
+				upstreams[name].IPFamily = anns.IPFamily.Upstream
 
 				if upstreams[name].ClientCACert.Secret == "" {
 					upstreams[name].ClientCACert = anns.SecureUpstream.ClientCACert
@@ -267,14 +587,50 @@ func (n *NGINXController) createUpstreams(data []*networking.Ingress, ku *ingres
 				}
 
 				upstreams[name].Service = s
-				upstreams[name].ClusterIP = s.Spec.ClusterIP
+				upstreams[name].ClusterIP = serviceClusterIP(s, upstreams[name].IPFamily)
 			}
 		}
+
+		n.createVariantUpstreams(upstreams, ing, anns)
+		n.createCanaryUpstream(upstreams, ing, anns)
+		n.createGroupUpstreams(upstreams, ing, anns)
 	}
 
 	return upstreams
 }
 
+// setBackup configures the backup service of an upstream. The first Ingress
+// with a backup service referencing the upstream is used.
+func (n *NGINXController) setBackup(ups *ingress.Backend, ing *networking.Ingress, cfg backup.Config) {
+	if ups.Backup != nil || cfg.Service == "" {
+		return
+	}
+
+	if ups.UpstreamHashBy != "" || ups.BalancesEndpoints() {
+		glog.Warningf("ignoring backup service of Ingress %v/%v: it can not be used with upstream-hash-by, slow-start or outlier-detection",
+			ing.GetNamespace(), ing.GetName())
+		return
+	}
+
+	svcKey := fmt.Sprintf("%v/%v", ing.GetNamespace(), cfg.Service)
+	s, err := n.listers.Service.GetByName(svcKey)
+	if err != nil {
+		glog.Warningf("error obtaining backup service: %v", err)
+		return
+	}
+
+	if s.Spec.ClusterIP == "" || s.Spec.ClusterIP == apiv1.ClusterIPNone {
+		glog.Warningf("ignoring backup service %v: it does not have a ClusterIP", svcKey)
+		return
+	}
+
+	ups.Backup = &ingress.BackupServer{
+		Service:   svcKey,
+		ClusterIP: s.Spec.ClusterIP,
+		Port:      cfg.Port,
+	}
+}
+
 // createServers initializes a map that contains information about the list of
 // FDQN referenced by ingress rules and the common name field in the referenced
 // SSL certificates. Each server is configured with location / using a default
@@ -293,6 +649,7 @@ func (n *NGINXController) createServers(data []*networking.Ingress,
 		defaultPemFileName = defaultCertificate.PemFileName
 		defaultPemSHA = defaultCertificate.PemSHA
 	}
+	defaultCertificates := n.newDefaultCertificates()
 
 	// initialize the default server
 	servers[defServerName] = &ingress.Server{
@@ -350,6 +707,7 @@ func (n *NGINXController) createServers(data []*networking.Ingress,
 
 			servers[host] = &ingress.Server{
 				Hostname: host,
+				IPFamily: n.serverIPFamily(ing, host, anns.IPFamily.Serve),
 				Locations: []*ingress.Location{
 					{
 						Path:    rootLocation,
@@ -394,14 +752,29 @@ func (n *NGINXController) createServers(data []*networking.Ingress,
 				continue
 			}
 
-			if tlsSecretName == "" {
+			key := ""
+			if tlsSecretName != "" {
+				key = fmt.Sprintf("%v/%v", ing.Namespace, tlsSecretName)
+			} else if alias := n.getIngressAnnotations(ing).SharedCertificate; alias != "" {
+				key, err = n.sharedCertificate(alias, ing.Namespace)
+				if err != nil {
+					glog.Warningf("ingress %v/%v for host %v: %v", ing.Namespace, ing.Name, host, err)
+				}
+			}
+
+			if key == "" {
 				glog.V(3).Infof("host %v is listed on tls section but secretName is empty. Using default cert", host)
+				if cert := defaultCertificates.get(host); cert != nil {
+					servers[host].SSLCertificate = cert.PemFileName
+					servers[host].SSLPemChecksum = cert.PemSHA
+					servers[host].SSLExpireTime = cert.ExpireTime
+					continue
+				}
 				servers[host].SSLCertificate = defaultPemFileName
 				servers[host].SSLPemChecksum = defaultPemSHA
 				continue
 			}
 
-			key := fmt.Sprintf("%v/%v", ing.Namespace, tlsSecretName)
 			bc, exists := n.sslCertTracker.Get(key)
 			if !exists {
 				glog.Warningf("ssl certificate \"%v\" does not exist in local store", key)
@@ -418,6 +791,18 @@ func (n *NGINXController) createServers(data []*networking.Ingress,
 		}
 	}
 
+	// servers without a certificate use the default certificate of their hostname group
+	for host, server := range servers {
+		if host == defServerName || server.SSLCertificate != "" {
+			continue
+		}
+		if cert := defaultCertificates.get(host); cert != nil {
+			server.SSLCertificate = cert.PemFileName
+			server.SSLPemChecksum = cert.PemSHA
+			server.SSLExpireTime = cert.ExpireTime
+		}
+	}
+
 	return servers
 }
 
@@ -431,6 +816,26 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 	for _, ing := range ingresses {
 		anns := n.getIngressAnnotations(ing)
 
+		// without keys the locations reject all the requests
+		signedURL := anns.SignedURL
+		if signedURL.Secret != "" {
+			var err error
+			signedURL, err = n.writeSignedURLKeys(signedURL)
+			if err != nil {
+				glog.Warningf("unexpected error reading signed URL keys of ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+			}
+		}
+		replayProtection := anns.ReplayProtection
+		if replayProtection.Secret != "" {
+			var err error
+			replayProtection, err = n.writeReplayProtectionKeys(replayProtection)
+			if err != nil {
+				glog.Warningf("unexpected error reading replay protection keys of ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+			}
+		}
+		canaryCfg := canaryConfig(upstreams, ing, anns)
+		groupRouting := groupRoutingConfig(upstreams, ing, anns)
+
 		for _, rule := range ing.Spec.Rules {
 			host := rule.Host
 			if host == "" {
@@ -452,6 +857,9 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 					ing.GetNamespace(),
 					path.Backend.Service.Name,
 					fmt.Sprintf("%d", path.Backend.Service.Port.Number))
+				if anns.UnixSocket.Path != "" {
+					upsName = unixSocketUpstreamName(ing)
+				}
 
 				ups := upstreams[upsName]
 
@@ -466,7 +874,7 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 					if loc.Path == nginxPath {
 						addLoc = false
 
-						if ups.ClusterIP == "" {
+						if !ups.HasAddress() {
 							break
 						}
 
@@ -484,31 +892,77 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 						loc.UpstreamURI = anns.UpstreamURI
 						loc.LocationModifier = anns.LocationModifier
 						loc.Connection = anns.Connection
+						loc.Budget = anns.Budget
+						loc.LuaFilters = anns.LuaFilters
+						loc.CustomCounters = anns.CustomCounters
+						loc.AllowedServiceAccounts = anns.AllowedServiceAccounts
+						loc.TLSHeaders = anns.TLSHeaders
+						loc.Websocket = anns.Websocket
+						loc.CostTag = anns.CostTag
+						loc.DisableCompression = anns.DisableCompression
+						loc.CachePolicy = anns.CachePolicy
+						loc.SignedURL = signedURL
+						loc.ReplayProtection = replayProtection
+						loc.Profile = anns.Profile
+						loc.SurgeProtection = anns.SurgeProtection
+						loc.Fairness = anns.Fairness
+						loc.Deadline = anns.Deadline
+						loc.ClientCertRevocation = anns.ClientCertRevocation
+						loc.AccessLog = anns.AccessLog
+						loc.Locale = anns.Locale
+						loc.Canary = canaryCfg
+						loc.GroupRouting = groupRouting
+						loc.BreakGlass = anns.BreakGlass
+						loc.Plugins = anns.Plugins
+						loc.FailedPlugins = anns.FailedPlugins
 						break
 					}
 				}
 				// is a new location
 				if addLoc {
 					glog.V(3).Infof("adding location %v in ingress rule %v/%v upstream %v", nginxPath, ing.Namespace, ing.Name, ups.Name)
-					if ups.ClusterIP == "" {
+					if !ups.HasAddress() {
 						continue
 					}
 
 					loc := &ingress.Location{
-						Path:                 nginxPath,
-						Backend:              ups.Name,
-						Service:              ups.Service,
-						Port:                 ups.Port,
-						Ingress:              ing,
-						ConfigurationSnippet: anns.ConfigurationSnippet,
-						Rewrite:              anns.Rewrite,
-						Proxy:                anns.Proxy,
-						XForwardedPrefix:     anns.XForwardedPrefix,
-						AuthType:             anns.AuthType,
-						AuthzType:            anns.AuthzType,
-						LocationModifier:     anns.LocationModifier,
-						UpstreamURI:          anns.UpstreamURI,
-						Connection:           anns.Connection,
+						Path:                   nginxPath,
+						Backend:                ups.Name,
+						Service:                ups.Service,
+						Port:                   ups.Port,
+						Ingress:                ing,
+						ConfigurationSnippet:   anns.ConfigurationSnippet,
+						Rewrite:                anns.Rewrite,
+						Proxy:                  anns.Proxy,
+						XForwardedPrefix:       anns.XForwardedPrefix,
+						AuthType:               anns.AuthType,
+						AuthzType:              anns.AuthzType,
+						LocationModifier:       anns.LocationModifier,
+						UpstreamURI:            anns.UpstreamURI,
+						Connection:             anns.Connection,
+						Budget:                 anns.Budget,
+						LuaFilters:             anns.LuaFilters,
+						CustomCounters:         anns.CustomCounters,
+						AllowedServiceAccounts: anns.AllowedServiceAccounts,
+						TLSHeaders:             anns.TLSHeaders,
+						Websocket:              anns.Websocket,
+						CostTag:                anns.CostTag,
+						DisableCompression:     anns.DisableCompression,
+						CachePolicy:            anns.CachePolicy,
+						SignedURL:              signedURL,
+						ReplayProtection:       replayProtection,
+						Profile:                anns.Profile,
+						SurgeProtection:        anns.SurgeProtection,
+						Fairness:               anns.Fairness,
+						Deadline:               anns.Deadline,
+						ClientCertRevocation:   anns.ClientCertRevocation,
+						AccessLog:              anns.AccessLog,
+						Locale:                 anns.Locale,
+						Canary:                 canaryCfg,
+						GroupRouting:           groupRouting,
+						BreakGlass:             anns.BreakGlass,
+						Plugins:                anns.Plugins,
+						FailedPlugins:          anns.FailedPlugins,
 					}
 
 					server.Locations = append(server.Locations, loc)
@@ -517,11 +971,21 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 		}
 	}
 
+	if n.cfg.ACMEChallenges {
+		for _, server := range servers {
+			for _, loc := range server.Locations {
+				exemptACMEChallenge(loc)
+			}
+		}
+	}
+
+	n.applyOIDCPolicies(servers)
+
 	aUpstreams := make([]*ingress.Backend, 0, len(upstreams))
 
 	// create the list of upstreams and skip those without endpoints
 	for _, upstream := range upstreams {
-		if upstream.ClusterIP == "" {
+		if !upstream.HasAddress() {
 			continue
 		}
 		aUpstreams = append(aUpstreams, upstream)
@@ -543,7 +1007,7 @@ func (n *NGINXController) getBackendServers(ingresses []*networking.Ingress) ([]
 }
 
 // GetAuthCertificate is used by the auth-tls annotations to get a cert from a secret
-func (n NGINXController) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+func (n *NGINXController) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
 	if _, exists := n.sslCertTracker.Get(name); !exists {
 		n.syncSecret(name)
 	}
@@ -567,24 +1031,61 @@ func (n NGINXController) GetAuthCertificate(name string) (*resolver.AuthSSLCert,
 }
 
 // GetSecret searches for a secret in the local secrets Store
-func (n NGINXController) GetSecret(name string) (*apiv1.Secret, error) {
+func (n *NGINXController) GetSecret(name string) (*apiv1.Secret, error) {
 	return n.listers.Secret.GetByName(name)
 }
 
 // GetService searches for a service in the local secrets Store
-func (n NGINXController) GetService(name string) (*apiv1.Service, error) {
+func (n *NGINXController) GetService(name string) (*apiv1.Service, error) {
 	return n.listers.Service.GetByName(name)
 }
 
 func (n *NGINXController) extractAnnotations(ing *networking.Ingress) {
 	glog.V(3).Infof("updating annotations information for ingress %v/%v", ing.Namespace, ing.Name)
 	anns := n.annotations.Extract(ing)
+	for _, name := range anns.LuaFilters {
+		if !n.luaFilters.Has(name) {
+			anns.Errors = append(anns.Errors, fmt.Errorf("the Lua filter %v is not in the signed bundle, requests will be rejected", name))
+		}
+	}
+	if len(anns.Errors) > 0 {
+		msg := utilerrors.NewAggregate(anns.Errors).Error()
+		glog.Warningf("invalid annotations in ingress %v/%v: %v", ing.Namespace, ing.Name, msg)
+		// the resyncs do not repeat the event of the same errors
+		if !sameErrors(n.previousAnnotationErrors(ing), anns.Errors) {
+			n.recorder.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotations", msg)
+		}
+	}
+
 	err := n.listers.IngressAnnotation.Update(anns)
 	if err != nil {
 		glog.Errorf("unexpected error updating annotations information for ingress %v/%v: %v", anns.Namespace, anns.Name, err)
 	}
 }
 
+// previousAnnotationErrors returns the errors of the annotations of the
+// Ingress last extracted, if any
+func (n *NGINXController) previousAnnotationErrors(ing *networking.Ingress) []error {
+	item, exists, err := n.listers.IngressAnnotation.GetByKey(fmt.Sprintf("%v/%v", ing.Namespace, ing.Name))
+	if err != nil || !exists {
+		return nil
+	}
+	return item.(*annotations.Ingress).Errors
+}
+
+// sameErrors returns true if both lists have the same messages
+func sameErrors(a, b []error) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Error() != b[i].Error() {
+			return false
+		}
+	}
+	return true
+}
+
 // getByIngress returns the parsed annotations from an Ingress
 func (n *NGINXController) getIngressAnnotations(ing *networking.Ingress) *annotations.Ingress {
 	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
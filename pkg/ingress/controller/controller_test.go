@@ -0,0 +1,54 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+func TestExtractAnnotationsEvents(t *testing.T) {
+	sl := &ingress.StoreLister{}
+	sl.IngressAnnotation.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	recorder := record.NewFakeRecorder(10)
+	n := &NGINXController{cfg: &Configuration{}, listers: sl, recorder: recorder}
+	n.annotations = annotations.NewAnnotationExtractor(n)
+
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "console",
+		Annotations: map[string]string{
+			parser.GetAnnotationWithPrefix("surge-protection"):       "reject",
+			parser.GetAnnotationWithPrefix("surge-requests-per-pod"): "0",
+			parser.GetAnnotationWithPrefix("surge-retry-after"):      "never",
+		}}}
+
+	n.extractAnnotations(ing)
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected an event but returned %v", len(recorder.Events))
+	}
+	e := <-recorder.Events
+	if !strings.Contains(e, "surge-requests-per-pod") || !strings.Contains(e, "surge-retry-after") {
+		t.Errorf("expected every invalid annotation in the event but returned %q", e)
+	}
+
+	// a resync does not repeat the event
+	n.extractAnnotations(ing)
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no event for the same errors but returned %v", <-recorder.Events)
+	}
+
+	ing.Annotations[parser.GetAnnotationWithPrefix("surge-retry-after")] = "10s"
+	n.extractAnnotations(ing)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected an event for the new errors but returned %v", len(recorder.Events))
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// DefaultCertificate is the default certificate of the servers
+// of a group of hostnames
+type DefaultCertificate struct {
+	// Hosts is a hostname or a wildcard like *.example.com that
+	// matches all the subdomains of example.com
+	Hosts string
+	// Secret is the <namespace>/<name> of the secret with the certificate
+	Secret string
+}
+
+// ParseDefaultCertificates parses a list of <hosts>=<namespace>/<secret name>
+func ParseDefaultCertificates(values []string) ([]DefaultCertificate, error) {
+	certs := make([]DefaultCertificate, 0, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid default certificate %q, expected <hosts>=<namespace>/<secret name>", v)
+		}
+		hosts, secret := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if hosts == "" || strings.Contains(strings.TrimPrefix(hosts, "*."), "*") {
+			return nil, fmt.Errorf("invalid hosts %q in default certificate %q", parts[0], v)
+		}
+		if ns := strings.Split(secret, "/"); len(ns) != 2 || ns[0] == "" || ns[1] == "" {
+			return nil, fmt.Errorf("invalid secret %q in default certificate %q", parts[1], v)
+		}
+		certs = append(certs, DefaultCertificate{Hosts: hosts, Secret: secret})
+	}
+	return certs, nil
+}
+
+// matchDefaultCertificate returns the secret of the default certificate of host.
+// An exact hostname is preferred over wildcards, and longer wildcards over shorter
+// ones. If several groups are equally specific, the first one is used.
+func matchDefaultCertificate(certs []DefaultCertificate, host string) (string, bool) {
+	host = strings.ToLower(host)
+	secret, best := "", -1
+	for _, c := range certs {
+		score := -1
+		switch {
+		case c.Hosts == host:
+			score = len(host) + 1
+		case strings.HasPrefix(c.Hosts, "*.") && strings.HasSuffix(host, c.Hosts[1:]):
+			score = len(c.Hosts) - 1
+		}
+		if score > best {
+			secret, best = c.Secret, score
+		}
+	}
+	return secret, best >= 0
+}
+
+// defaultCertificates reads the default certificates of the hostname groups
+// once per sync
+type defaultCertificates struct {
+	n     *NGINXController
+	certs map[string]*ingress.SSLCert
+}
+
+func (n *NGINXController) newDefaultCertificates() *defaultCertificates {
+	return &defaultCertificates{n: n, certs: make(map[string]*ingress.SSLCert)}
+}
+
+// get returns the default certificate of the hostname group of host,
+// or nil if the host is not in a group or its certificate is not valid
+func (d *defaultCertificates) get(host string) *ingress.SSLCert {
+	secret, ok := matchDefaultCertificate(d.n.cfg.DefaultSSLCertificates, host)
+	if !ok {
+		return nil
+	}
+	if cert, ok := d.certs[secret]; ok {
+		return cert
+	}
+
+	cert, err := d.n.getPemCertificate(secret)
+	if err != nil {
+		glog.Warningf("unexpected error reading default certificate %v of host %v: %v", secret, host, err)
+	}
+	d.certs[secret] = cert
+	return cert
+}
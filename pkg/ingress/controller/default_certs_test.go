@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestParseDefaultCertificates(t *testing.T) {
+	certs, err := ParseDefaultCertificates([]string{"*.internal.example.com=kube-system/internal", "Console.example.com = ns/console"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []DefaultCertificate{
+		{Hosts: "*.internal.example.com", Secret: "kube-system/internal"},
+		{Hosts: "console.example.com", Secret: "ns/console"},
+	}
+	if len(certs) != len(expected) || certs[0] != expected[0] || certs[1] != expected[1] {
+		t.Errorf("expected %v but returned %v", expected, certs)
+	}
+
+	for _, v := range []string{"example.com", "=ns/secret", "*.example.com=secret", "a.*.example.com=ns/secret", "example.com=ns/"} {
+		if _, err := ParseDefaultCertificates([]string{v}); err == nil {
+			t.Errorf("expected an error parsing %v", v)
+		}
+	}
+}
+
+func TestMatchDefaultCertificate(t *testing.T) {
+	certs := []DefaultCertificate{
+		{Hosts: "*.example.com", Secret: "ns/external"},
+		{Hosts: "*.internal.example.com", Secret: "ns/internal"},
+		{Hosts: "api.internal.example.com", Secret: "ns/api"},
+		{Hosts: "*.example.com", Secret: "ns/duplicated"},
+	}
+
+	testCases := []struct {
+		host     string
+		expected string
+		found    bool
+	}{
+		{"console.example.com", "ns/external", true},
+		{"Console.Internal.example.com", "ns/internal", true},
+		{"api.internal.example.com", "ns/api", true},
+		{"example.com", "", false},
+		{"example.org", "", false},
+	}
+
+	for _, tc := range testCases {
+		secret, found := matchDefaultCertificate(certs, tc.host)
+		if secret != tc.expected || found != tc.found {
+			t.Errorf("expected %v (%v) for %v but returned %v (%v)", tc.expected, tc.found, tc.host, secret, found)
+		}
+	}
+}
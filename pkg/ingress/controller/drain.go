@@ -0,0 +1,221 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// drainTracker keeps the backends removed from the model, and the locations
+// that used them, for a grace period. The upstreams point to the ClusterIP
+// of the services, so pods leaving the endpoints are drained by kube-proxy,
+// but a Service that is deleted or loses its ClusterIP removes the location
+// in the next reload and the requests sent to it during the termination of
+// its pods fail. The endpoints of the backends balanced by NGINX are
+// drained by the endpointDrainTracker.
+type drainTracker struct {
+	period time.Duration
+	// since contains the time a backend was first missing from the model
+	since map[string]time.Time
+	// timer triggers a sync when the next draining backend expires
+	timer *time.Timer
+}
+
+func newDrainTracker(period time.Duration) *drainTracker {
+	return &drainTracker{
+		period: period,
+		since:  make(map[string]time.Time),
+	}
+}
+
+// apply adds to the backends and servers the backends of the running
+// configuration removed less than period ago, with their locations unless
+// the path is now served by another backend. It returns the time until the
+// next draining backend expires, or zero if no backend is draining.
+func (d *drainTracker) apply(running *ingress.Configuration, backends []*ingress.Backend,
+	servers []*ingress.Server, now time.Time) ([]*ingress.Backend, []*ingress.Server, time.Duration) {
+
+	current := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		current[b.Name] = true
+		delete(d.since, b.Name)
+	}
+
+	var next time.Duration
+	for _, b := range running.Backends {
+		if current[b.Name] {
+			continue
+		}
+
+		start, ok := d.since[b.Name]
+		if !ok {
+			start = now
+			d.since[b.Name] = now
+		}
+
+		remaining := d.period - now.Sub(start)
+		if remaining <= 0 {
+			glog.V(2).Infof("backend %v drained", b.Name)
+			delete(d.since, b.Name)
+			continue
+		}
+
+		var drained bool
+		servers, drained = drainLocations(running.Servers, servers, b.Name)
+		if !drained {
+			delete(d.since, b.Name)
+			continue
+		}
+
+		glog.V(2).Infof("backend %v was removed, draining for %v", b.Name, remaining)
+		backends = append(backends, b)
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+
+	sort.SliceStable(servers, func(i, j int) bool {
+		return servers[i].Hostname < servers[j].Hostname
+	})
+
+	return backends, servers, next
+}
+
+// schedule runs fn after d, replacing the previously scheduled call
+func (d *drainTracker) schedule(after time.Duration, fn func()) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(after, fn)
+}
+
+// drainLocations copies the locations of the running servers using the
+// backend to the new servers. It returns false if no location was copied.
+func drainLocations(running, servers []*ingress.Server, backend string) ([]*ingress.Server, bool) {
+	var drained bool
+	for _, rs := range running {
+		for _, loc := range rs.Locations {
+			if loc.Backend != backend {
+				continue
+			}
+
+			var server *ingress.Server
+			for _, s := range servers {
+				if s.Hostname == rs.Hostname {
+					server = s
+					break
+				}
+			}
+			if server == nil {
+				s := *rs
+				s.Locations = nil
+				server = &s
+				servers = append(servers, server)
+			}
+
+			exists := false
+			for _, l := range server.Locations {
+				if l.Path == loc.Path {
+					exists = true
+					break
+				}
+			}
+			if exists {
+				continue
+			}
+
+			server.Locations = append(server.Locations, loc)
+			sort.SliceStable(server.Locations, func(i, j int) bool {
+				return server.Locations[i].Path > server.Locations[j].Path
+			})
+			drained = true
+		}
+	}
+
+	return servers, drained
+}
+
+// endpointDrainTracker keeps the endpoints of the backends balanced by
+// NGINX that are terminating but still serving, from the conditions of
+// their EndpointSlices, for the drain period since they were first seen
+// terminating. The endpoints that stop serving are removed at once.
+type endpointDrainTracker struct {
+	period time.Duration
+
+	mu sync.Mutex
+	// since contains the time each endpoint was first seen terminating per
+	// backend
+	since map[string]map[string]time.Time
+	// timer updates the endpoints when the next draining endpoint expires
+	timer *time.Timer
+}
+
+func newEndpointDrainTracker(period time.Duration) *endpointDrainTracker {
+	return &endpointDrainTracker{
+		period: period,
+		since:  map[string]map[string]time.Time{},
+	}
+}
+
+// observe records the terminating endpoints of a backend and returns the
+// ones terminating for less than the drain period
+func (d *endpointDrainTracker) observe(backend string, terminating []string, now time.Time) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.since[backend]
+	current := make(map[string]time.Time, len(terminating))
+	var draining []string
+	for _, ep := range terminating {
+		since, ok := previous[ep]
+		if !ok {
+			since = now
+		}
+		current[ep] = since
+		if now.Sub(since) < d.period {
+			draining = append(draining, ep)
+		}
+	}
+	d.since[backend] = current
+	return draining
+}
+
+// next forgets the backends that are not balanced by NGINX anymore and
+// returns the time until the next draining endpoint expires, or zero if no
+// endpoint is draining
+func (d *endpointDrainTracker) next(backends map[string]bool, now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Duration
+	for name, endpoints := range d.since {
+		if !backends[name] {
+			delete(d.since, name)
+			continue
+		}
+		for _, since := range endpoints {
+			remaining := d.period - now.Sub(since)
+			if remaining > 0 && (next == 0 || remaining < next) {
+				next = remaining
+			}
+		}
+	}
+	return next
+}
+
+// schedule runs fn after d, replacing the previously scheduled call
+func (d *endpointDrainTracker) schedule(after time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(after, fn)
+}
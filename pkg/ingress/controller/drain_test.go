@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestDrainTracker(t *testing.T) {
+	running := &ingress.Configuration{
+		Backends: []*ingress.Backend{{Name: "default-api-80"}, {Name: "default-ui-80"}},
+		Servers: []*ingress.Server{
+			{
+				Hostname: "_",
+				Locations: []*ingress.Location{
+					{Path: "/ui", Backend: "default-ui-80"},
+					{Path: "/api", Backend: "default-api-80"},
+				},
+			},
+		},
+	}
+
+	// default-api-80 was removed. Every sync creates a new model
+	model := func() ([]*ingress.Backend, []*ingress.Server) {
+		return []*ingress.Backend{{Name: "default-ui-80"}}, []*ingress.Server{
+			{Hostname: "_", Locations: []*ingress.Location{{Path: "/ui", Backend: "default-ui-80"}}},
+		}
+	}
+
+	d := newDrainTracker(time.Minute)
+	now := time.Now()
+
+	backends, servers := model()
+	b, s, next := d.apply(running, backends, servers, now)
+	if len(b) != 2 {
+		t.Fatalf("expected the removed backend to be draining but returned %v backends", len(b))
+	}
+	if len(s) != 1 || len(s[0].Locations) != 2 || s[0].Locations[1].Path != "/api" {
+		t.Fatalf("expected the location of the removed backend to be kept but returned %+v", s[0].Locations)
+	}
+	if next != time.Minute {
+		t.Errorf("expected next expiration in %v but returned %v", time.Minute, next)
+	}
+
+	running = &ingress.Configuration{Backends: b, Servers: s}
+	backends, servers = model()
+	_, _, next = d.apply(running, backends, servers, now.Add(40*time.Second))
+	if next != 20*time.Second {
+		t.Errorf("expected next expiration in %v but returned %v", 20*time.Second, next)
+	}
+
+	backends, servers = model()
+	b, s, next = d.apply(running, backends, servers, now.Add(time.Minute))
+	if len(b) != 1 || len(s[0].Locations) != 1 || next != 0 {
+		t.Errorf("expected the backend to be drained but returned %v backends and %v locations", len(b), len(s[0].Locations))
+	}
+	if len(d.since) != 0 {
+		t.Errorf("expected no draining backends but returned %v", d.since)
+	}
+}
+
+func TestDrainReplacedPath(t *testing.T) {
+	running := &ingress.Configuration{
+		Backends: []*ingress.Backend{{Name: "default-api-80"}},
+		Servers: []*ingress.Server{
+			{Hostname: "_", Locations: []*ingress.Location{{Path: "/api", Backend: "default-api-80"}}},
+		},
+	}
+
+	// the path is now served by another backend
+	backends := []*ingress.Backend{{Name: "default-api-v2-80"}}
+	servers := []*ingress.Server{
+		{Hostname: "_", Locations: []*ingress.Location{{Path: "/api", Backend: "default-api-v2-80"}}},
+	}
+
+	d := newDrainTracker(time.Minute)
+	b, s, next := d.apply(running, backends, servers, time.Now())
+	if len(b) != 1 || len(s[0].Locations) != 1 || s[0].Locations[0].Backend != "default-api-v2-80" || next != 0 {
+		t.Errorf("expected the new backend to replace the old one but returned %+v", s[0].Locations)
+	}
+}
+
+func TestEndpointDrainTracker(t *testing.T) {
+	d := newEndpointDrainTracker(time.Minute)
+	now := time.Now()
+	backends := map[string]bool{"search-cache-80": true}
+
+	draining := d.observe("search-cache-80", []string{"10.0.0.3:8080"}, now)
+	if len(draining) != 1 {
+		t.Fatalf("expected the terminating endpoint to be draining but returned %v", draining)
+	}
+	if next := d.next(backends, now); next != time.Minute {
+		t.Errorf("expected next expiration in %v but returned %v", time.Minute, next)
+	}
+
+	// the period starts when the endpoint is first seen terminating
+	draining = d.observe("search-cache-80", []string{"10.0.0.3:8080", "10.0.0.4:8080"}, now.Add(40*time.Second))
+	if len(draining) != 2 {
+		t.Fatalf("expected two draining endpoints but returned %v", draining)
+	}
+	if next := d.next(backends, now.Add(40*time.Second)); next != 20*time.Second {
+		t.Errorf("expected next expiration in %v but returned %v", 20*time.Second, next)
+	}
+
+	draining = d.observe("search-cache-80", []string{"10.0.0.3:8080", "10.0.0.4:8080"}, now.Add(time.Minute))
+	if len(draining) != 1 || draining[0] != "10.0.0.4:8080" {
+		t.Errorf("expected the first endpoint to be drained but returned %v", draining)
+	}
+
+	// the endpoints that stop serving are not draining anymore
+	if draining = d.observe("search-cache-80", nil, now.Add(time.Minute)); len(draining) != 0 {
+		t.Errorf("expected no draining endpoints but returned %v", draining)
+	}
+	if next := d.next(map[string]bool{}, now.Add(time.Minute)); next != 0 || len(d.since) != 0 {
+		t.Errorf("expected the backends not balanced anymore to be forgotten")
+	}
+}
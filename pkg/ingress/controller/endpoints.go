@@ -0,0 +1,191 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+)
+
+// backendEndpointsFile is the state file, in the temporal directory, with
+// the ready endpoints of the backends balanced by NGINX, with bounded load
+// or slow start, instead of sent to the ClusterIP
+const backendEndpointsFile = "backend-endpoints"
+
+// backendPortName returns the name of the port of the Service of the
+// backend, the name of the ports of its endpoints
+func backendPortName(b *ingress.Backend) (string, bool) {
+	if b.Service == nil {
+		return "", false
+	}
+	for _, p := range b.Service.Spec.Ports {
+		if (b.Port.Type == intstr.Int && p.Port == b.Port.IntVal) || (b.Port.Type == intstr.String && p.Name == b.Port.StrVal) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// backendEndpoints returns the ready endpoints of the port of the backend,
+// of its address family, sorted
+func backendEndpoints(b *ingress.Backend, ep *apiv1.Endpoints) []string {
+	portName, found := backendPortName(b)
+	if !found {
+		return nil
+	}
+
+	var endpoints []string
+	for _, subset := range ep.Subsets {
+		for _, port := range subset.Ports {
+			if port.Name != portName {
+				continue
+			}
+			for _, addr := range subset.Addresses {
+				if !ipfamily.Matches(b.IPFamily, addr.IP) {
+					continue
+				}
+				endpoints = append(endpoints, net.JoinHostPort(addr.IP, strconv.Itoa(int(port.Port))))
+			}
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// terminatingEndpoints returns the endpoints of the port of the backend, of
+// its address family, that are terminating but still serving in the
+// EndpointSlices of its Service, sorted
+func terminatingEndpoints(b *ingress.Backend, slices []*discovery.EndpointSlice) []string {
+	portName, found := backendPortName(b)
+	if !found {
+		return nil
+	}
+
+	var endpoints []string
+	for _, slice := range slices {
+		for _, port := range slice.Ports {
+			if port.Port == nil || (port.Name == nil && portName != "") || (port.Name != nil && *port.Name != portName) {
+				continue
+			}
+			for _, ep := range slice.Endpoints {
+				c := ep.Conditions
+				if c.Serving == nil || !*c.Serving || c.Terminating == nil || !*c.Terminating {
+					continue
+				}
+				for _, addr := range ep.Addresses {
+					if ipfamily.Matches(b.IPFamily, addr) {
+						endpoints = append(endpoints, net.JoinHostPort(addr, strconv.Itoa(int(*port.Port))))
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// balancedEndpoints returns the content of the backend endpoints file, one
+// line per backend balanced by NGINX with its name and ready endpoints, and
+// the keys of their Services. NGINX keeps the time each endpoint was first
+// seen ready for the slow start. The draining endpoints of the backend, if draining is not nil, follow with a - prefix.
+// Only the endpoints allowed by the workload identity are written. Backends
+// without endpoints are omitted, so NGINX sends their requests to the
+// ClusterIP, or fails them if the backend verifies the SPIFFE IDs of the
+// endpoints.
+func balancedEndpoints(backends []*ingress.Backend, endpoints func(string) (*apiv1.Endpoints, bool), draining func(*ingress.Backend) []string, identity *workloadIdentity) ([]byte, map[string]bool) {
+	sorted := make([]*ingress.Backend, 0, len(backends))
+	for _, b := range backends {
+		if b.BalancesEndpoints() && b.Service != nil {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var buf bytes.Buffer
+	services := map[string]bool{}
+	for _, b := range sorted {
+		key := fmt.Sprintf("%v/%v", b.Service.Namespace, b.Service.Name)
+		services[key] = true
+		ep, ok := endpoints(key)
+		if !ok {
+			continue
+		}
+		var eps, drained []string
+		for _, e := range backendEndpoints(b, ep) {
+			if identity.allowed(b, e) {
+				eps = append(eps, e)
+			}
+		}
+		if draining != nil {
+			for _, e := range draining(b) {
+				if identity.allowed(b, e) {
+					drained = append(drained, e)
+				}
+			}
+		}
+		if len(eps) == 0 && len(drained) == 0 {
+			continue
+		}
+
+		fields := append([]string{b.Name}, eps...)
+		for _, e := range drained {
+			fields = append(fields, "-"+e)
+		}
+		fmt.Fprintf(&buf, "%v\n", strings.Join(fields, " "))
+	}
+	return buf.Bytes(), services
+}
+
+// updateBalancedEndpoints writes the endpoints of the backends balanced by
+// NGINX of the configuration. With a drain period, the file is written
+// again when the next draining endpoint expires.
+func (n *NGINXController) updateBalancedEndpoints(cfg *ingress.Configuration) {
+	if cfg == nil {
+		return
+	}
+	now := time.Now()
+	var draining func(*ingress.Backend) []string
+	if n.endpointDrain != nil {
+		draining = func(b *ingress.Backend) []string {
+			slices := n.listers.EndpointSlice.GetServiceEndpointSlices(b.Service.Namespace, b.Service.Name)
+			return n.endpointDrain.observe(b.Name, terminatingEndpoints(b, slices), now)
+		}
+	}
+	content, services := balancedEndpoints(cfg.Backends, n.endpointsByKey, draining, n.identity)
+	if err := n.balancedEndpoints.replace(services, content); err != nil {
+		glog.Warningf("unexpected error writing the endpoints of the balanced backends: %v", err)
+	}
+
+	if n.endpointDrain == nil {
+		return
+	}
+	balanced := map[string]bool{}
+	for _, b := range cfg.Backends {
+		if b.BalancesEndpoints() {
+			balanced[b.Name] = true
+		}
+	}
+	if next := n.endpointDrain.next(balanced, now); next > 0 {
+		n.endpointDrain.schedule(next, func() {
+			n.runningConfigLock.RLock()
+			defer n.runningConfigLock.RUnlock()
+			n.updateBalancedEndpoints(n.runningConfig)
+		})
+	}
+}
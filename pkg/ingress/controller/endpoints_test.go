@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestBalancedEndpoints(t *testing.T) {
+	svc := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "cache"},
+		Spec: apiv1.ServiceSpec{
+			Ports: []apiv1.ServicePort{{Name: "http", Port: 80}, {Name: "metrics", Port: 9090}},
+		},
+	}
+	endpoints := map[string]*apiv1.Endpoints{
+		"search/cache": {
+			Subsets: []apiv1.EndpointSubset{{
+				Addresses:         []apiv1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.1"}},
+				NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.3"}},
+				Ports:             []apiv1.EndpointPort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+			}},
+		},
+	}
+	lookup := func(key string) (*apiv1.Endpoints, bool) {
+		ep, ok := endpoints[key]
+		return ep, ok
+	}
+
+	backends := []*ingress.Backend{
+		{Name: "search-cache-80", Service: svc, Port: intstr.FromInt(80), HashLoadFactor: 1.25},
+		{Name: "search-cache-http", Service: svc, Port: intstr.FromString("http"), HashLoadFactor: 2},
+		{Name: "search-cache-81", Service: svc, Port: intstr.FromInt(81), HashLoadFactor: 1.25},
+		{Name: "search-api-80", Service: &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "api"}}, Port: intstr.FromInt(80)},
+	}
+
+	content, services := balancedEndpoints(backends, lookup, nil, nil)
+	expected := "search-cache-80 10.0.0.1:8080 10.0.0.2:8080\nsearch-cache-http 10.0.0.1:8080 10.0.0.2:8080\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but returned %q", expected, content)
+	}
+	if len(services) != 1 || !services["search/cache"] {
+		t.Errorf("expected only the service balanced by NGINX but returned %v", services)
+	}
+}
+
+func TestTerminatingEndpoints(t *testing.T) {
+	svc := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "cache"},
+		Spec: apiv1.ServiceSpec{
+			Ports: []apiv1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+	b := &ingress.Backend{Name: "search-cache-80", Service: svc, Port: intstr.FromInt(80), SlowStart: 60}
+
+	yes, no := true, false
+	name, port := "http", int32(8080)
+	slices := []*discovery.EndpointSlice{{
+		Ports: []discovery.EndpointPort{{Name: &name, Port: &port}},
+		Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Conditions: discovery.EndpointConditions{Ready: &yes, Serving: &yes, Terminating: &no}},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discovery.EndpointConditions{Ready: &no, Serving: &yes, Terminating: &yes}},
+			{Addresses: []string{"10.0.0.2"}, Conditions: discovery.EndpointConditions{Ready: &no, Serving: &no, Terminating: &yes}},
+		},
+	}}
+
+	terminating := terminatingEndpoints(b, slices)
+	if len(terminating) != 1 || terminating[0] != "10.0.0.3:8080" {
+		t.Fatalf("expected only the terminating endpoint still serving but returned %v", terminating)
+	}
+
+	endpoints := func(string) (*apiv1.Endpoints, bool) {
+		return &apiv1.Endpoints{Subsets: []apiv1.EndpointSubset{{
+			Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+			Ports:     []apiv1.EndpointPort{{Name: "http", Port: 8080}},
+		}}}, true
+	}
+	draining := func(*ingress.Backend) []string {
+		return terminating
+	}
+	content, _ := balancedEndpoints([]*ingress.Backend{b}, endpoints, draining, nil)
+	expected := "search-cache-80 10.0.0.1:8080 -10.0.0.3:8080\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but returned %q", expected, content)
+	}
+}
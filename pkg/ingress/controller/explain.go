@@ -0,0 +1,128 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+)
+
+// Explanation is the effective configuration generated for an Ingress
+type Explanation struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Ignored is true when the Ingress class is not handled by the controller
+	Ignored bool `json:"ignored"`
+	// Annotations contains the parsed annotations, with the defaults applied
+	Annotations *annotations.Ingress `json:"annotations"`
+	// InvalidAnnotations contains the annotations replaced by their defaults
+	InvalidAnnotations []string           `json:"invalidAnnotations,omitempty"`
+	Servers            []ExplainedServer  `json:"servers"`
+	Backends           []ExplainedBackend `json:"backends"`
+}
+
+// ExplainedServer is a server with the locations generated for an Ingress
+type ExplainedServer struct {
+	Hostname       string             `json:"hostname"`
+	SSLCertificate string             `json:"sslCertificate,omitempty"`
+	SSLPemChecksum string             `json:"sslPemChecksum,omitempty"`
+	SSLExpireTime  time.Time          `json:"sslExpireTime,omitempty"`
+	Locations      []ingress.Location `json:"locations"`
+}
+
+// ExplainedBackend is a backend used by an Ingress and its endpoints
+type ExplainedBackend struct {
+	*ingress.Backend
+	Endpoints         []string `json:"endpoints"`
+	NotReadyEndpoints []string `json:"notReadyEndpoints,omitempty"`
+}
+
+// Explain returns the configuration of the running model generated for
+// the Ingress namespace/name
+func (n *NGINXController) Explain(namespace, name string) (*Explanation, error) {
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	obj, exists, err := n.listers.Ingress.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(networking.Resource("ingresses"), key)
+	}
+	ing := obj.(*networking.Ingress)
+
+	anns := *n.getIngressAnnotations(ing)
+	e := &Explanation{
+		Namespace:   namespace,
+		Name:        name,
+		Ignored:     !n.isValidIngress(ing),
+		Annotations: &anns,
+	}
+	for _, err := range anns.Errors {
+		e.InvalidAnnotations = append(e.InvalidAnnotations, err.Error())
+	}
+	anns.Errors = nil
+
+	n.runningConfigLock.RLock()
+	cfg := n.runningConfig
+	n.runningConfigLock.RUnlock()
+	if cfg == nil {
+		return e, nil
+	}
+
+	backends := make(map[string]bool)
+	for _, server := range cfg.Servers {
+		s := ExplainedServer{
+			Hostname:       server.Hostname,
+			SSLCertificate: server.SSLCertificate,
+			SSLPemChecksum: server.SSLPemChecksum,
+			SSLExpireTime:  server.SSLExpireTime,
+		}
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.Namespace != namespace || loc.Ingress.Name != name {
+				continue
+			}
+			l := *loc
+			// the backend and the Ingress are explained separately
+			l.Ingress = nil
+			l.Service = nil
+			s.Locations = append(s.Locations, l)
+			backends[loc.Backend] = true
+		}
+		if len(s.Locations) > 0 {
+			e.Servers = append(e.Servers, s)
+		}
+	}
+
+	for _, b := range cfg.Backends {
+		if !backends[b.Name] {
+			continue
+		}
+		eb := ExplainedBackend{Backend: b}
+		if b.Service != nil {
+			if ep, err := n.listers.Endpoint.GetServiceEndpoints(b.Service); err == nil {
+				for _, subset := range ep.Subsets {
+					for _, port := range subset.Ports {
+						for _, addr := range subset.Addresses {
+							eb.Endpoints = append(eb.Endpoints, net.JoinHostPort(addr.IP, strconv.Itoa(int(port.Port))))
+						}
+						for _, addr := range subset.NotReadyAddresses {
+							eb.NotReadyEndpoints = append(eb.NotReadyEndpoints, net.JoinHostPort(addr.IP, strconv.Itoa(int(port.Port))))
+						}
+					}
+				}
+			}
+		}
+		e.Backends = append(e.Backends, eb)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+func TestExplain(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "default", Name: "console"}
+	ing := &networking.Ingress{ObjectMeta: meta}
+	other := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+	svc := &apiv1.Service{ObjectMeta: meta}
+
+	sl := &ingress.StoreLister{}
+	sl.Ingress.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.IngressAnnotation.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.Endpoint.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.Ingress.Add(ing)
+	sl.IngressAnnotation.Add(&annotations.Ingress{
+		ObjectMeta: meta,
+		Errors:     []error{errors.NewInvalidAnnotationContent("proxy-read-timeout", "x")},
+	})
+	sl.Endpoint.Add(&apiv1.Endpoints{
+		ObjectMeta: meta,
+		Subsets: []apiv1.EndpointSubset{{
+			Addresses:         []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+			NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}},
+			Ports:             []apiv1.EndpointPort{{Port: 3000}},
+		}},
+	})
+
+	n := &NGINXController{
+		cfg:     &Configuration{},
+		listers: sl,
+		runningConfig: &ingress.Configuration{
+			Backends: []*ingress.Backend{
+				{Name: "default-console-3000", Service: svc},
+				{Name: "default-other-80"},
+			},
+			Servers: []*ingress.Server{{
+				Hostname:       "_",
+				SSLCertificate: "/etc/ssl/default.pem",
+				Locations: []*ingress.Location{
+					{Path: "/console", Backend: "default-console-3000", Ingress: ing, Service: svc},
+					{Path: "/other", Backend: "default-other-80", Ingress: other},
+				},
+			}},
+		},
+	}
+
+	e, err := n.Explain("default", "console")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(e.InvalidAnnotations) != 1 || e.Annotations.Errors != nil {
+		t.Errorf("expected one invalid annotation but returned %v", e.InvalidAnnotations)
+	}
+	if len(e.Servers) != 1 || len(e.Servers[0].Locations) != 1 || e.Servers[0].Locations[0].Path != "/console" {
+		t.Fatalf("expected the /console location but returned %+v", e.Servers)
+	}
+	if e.Servers[0].SSLCertificate != "/etc/ssl/default.pem" {
+		t.Errorf("expected the default certificate but returned %v", e.Servers[0].SSLCertificate)
+	}
+	if len(e.Backends) != 1 || e.Backends[0].Name != "default-console-3000" {
+		t.Fatalf("expected the console backend but returned %+v", e.Backends)
+	}
+	b := e.Backends[0]
+	if len(b.Endpoints) != 1 || b.Endpoints[0] != "10.0.0.1:3000" || len(b.NotReadyEndpoints) != 1 {
+		t.Errorf("unexpected endpoints %v (not ready %v)", b.Endpoints, b.NotReadyEndpoints)
+	}
+
+	if _, err := n.Explain("default", "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error but returned %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	// ExternalDNSEndpoints creates a DNSEndpoint per Ingress instead of
+	// writing the external-dns annotations
+	ExternalDNSEndpoints = "ExternalDNSEndpoints"
+
+	// NextTemplate renders the configuration with the candidate template
+	// instead of shadow rendering it
+	NextTemplate = "NextTemplate"
+
+	// OIDCRoutePolicies applies the audiences, scopes and forwarded claims
+	// of the OIDCRoutePolicy resources to the routes
+	OIDCRoutePolicies = "OIDCRoutePolicies"
+)
+
+// featureGates contains the features that are disabled by default
+var featureGates = map[string]bool{
+	ExternalDNSEndpoints: false,
+	NextTemplate:         false,
+	OIDCRoutePolicies:    false,
+}
+
+// ParseFeatureGates validates the features, in the form <name>=<bool>,
+// and returns the state of all of them
+func ParseFeatureGates(values map[string]string) (map[string]bool, error) {
+	gates := map[string]bool{}
+	for name, enabled := range featureGates {
+		gates[name] = enabled
+	}
+	for name, value := range values {
+		if _, ok := featureGates[name]; !ok {
+			known := make([]string, 0, len(featureGates))
+			for k := range featureGates {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown feature gate %q, expected one of %v", name, known)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of the feature gate %v", value, name)
+		}
+		gates[name] = enabled
+	}
+	return gates, nil
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	gates, err := ParseFeatureGates(nil)
+	if err != nil || gates[ExternalDNSEndpoints] {
+		t.Errorf("expected the features disabled by default but returned %v, %v", gates, err)
+	}
+	gates, err = ParseFeatureGates(map[string]string{ExternalDNSEndpoints: "true"})
+	if err != nil || !gates[ExternalDNSEndpoints] {
+		t.Errorf("expected %v enabled but returned %v, %v", ExternalDNSEndpoints, gates, err)
+	}
+	if _, err := ParseFeatureGates(map[string]string{"Teleport": "true"}); err == nil {
+		t.Errorf("expected an error with an unknown feature gate")
+	}
+	if _, err := ParseFeatureGates(map[string]string{ExternalDNSEndpoints: "maybe"}); err == nil {
+		t.Errorf("expected an error with an invalid value")
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+// lastAppliedAnnotation is set by kubectl apply and does not change the
+// routing
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// ingressRevision returns a hash of the fields of the Ingress that change
+// the routing or the freeze: the spec, the labels and the annotations,
+// except the ones set by the controller or kubectl
+func ingressRevision(ing *networking.Ingress) string {
+	anns := map[string]string{}
+	for k, v := range ing.GetAnnotations() {
+		if k == lastAppliedAnnotation || k == healthAnnotation() || k == healthMessageAnnotation() {
+			continue
+		}
+		anns[k] = v
+	}
+
+	b, _ := json.Marshal(approvedVersion{ing.Labels, anns, ing.Spec})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// approvedVersion is the version of a frozen Ingress approved, persisted
+// in the ConfigMap of the approvals
+type approvedVersion struct {
+	Labels      map[string]string      `json:"labels"`
+	Annotations map[string]string      `json:"annotations"`
+	Spec        networking.IngressSpec `json:"spec"`
+}
+
+// approvalKey returns the key of the approved version of an Ingress in the
+// ConfigMap, <namespace>.<name>: the namespaces do not contain dots
+func approvalKey(namespace, name string) string {
+	return namespace + "." + name
+}
+
+// PendingChange is a change of a frozen Ingress waiting for approval
+type PendingChange struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Revision is the revision that must be approved
+	Revision string `json:"revision"`
+	// ApprovedRevision is the revision served until then, empty if the
+	// Ingress was never approved
+	ApprovedRevision string `json:"approvedRevision,omitempty"`
+	// Since is the time the Ingress has pending changes
+	Since time.Time `json:"since"`
+}
+
+// changeFreeze holds the changes of the Ingresses matching a selector until
+// they are approved, serving the last approved version in the meantime. The
+// approved versions are kept in a ConfigMap, so they survive the restarts
+// and are shared by the replicas.
+type changeFreeze struct {
+	selector labels.Selector
+	// approvals contains the ConfigMap of the approved versions
+	approvals     cache.Store
+	configMapKey  string
+	configMapName string
+	namespace     string
+
+	mu      sync.Mutex
+	pending map[string]*PendingChange
+}
+
+func newChangeFreeze(selector labels.Selector, configMap string, approvals cache.Store) *changeFreeze {
+	ns, name, _ := k8s.ParseNameNS(configMap)
+	return &changeFreeze{
+		selector:      selector,
+		approvals:     approvals,
+		configMapKey:  configMap,
+		configMapName: name,
+		namespace:     ns,
+		pending:       map[string]*PendingChange{},
+	}
+}
+
+// approved returns the approved version of the Ingress, nil if it is not
+// frozen
+func (f *changeFreeze) approved(ing *networking.Ingress) *networking.Ingress {
+	obj, exists, err := f.approvals.GetByKey(f.configMapKey)
+	if err != nil || !exists {
+		return nil
+	}
+	data, ok := obj.(*apiv1.ConfigMap).Data[approvalKey(ing.Namespace, ing.Name)]
+	if !ok {
+		return nil
+	}
+
+	var v approvedVersion
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		glog.Warningf("ignoring the approved version of ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return nil
+	}
+	approved := ing.DeepCopy()
+	approved.Labels = v.Labels
+	approved.Annotations = v.Annotations
+	approved.Spec = v.Spec
+	return approved
+}
+
+// admit returns the version of the Ingress to serve, nil if no version was
+// approved, and true if ing has new changes waiting for approval. Ingresses
+// stay frozen after they stop matching the selector until that change is
+// approved.
+func (f *changeFreeze) admit(ing *networking.Ingress, now time.Time) (*networking.Ingress, bool) {
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+	matches := f.selector.Matches(labels.Set(ing.Labels))
+	approved := f.approved(ing)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer func() {
+		metric.SetPendingChanges(len(f.pending))
+	}()
+
+	if approved == nil && !matches {
+		delete(f.pending, key)
+		return ing, false
+	}
+
+	revision := ingressRevision(ing)
+	if approved != nil && ingressRevision(approved) == revision {
+		delete(f.pending, key)
+		return ing, false
+	}
+
+	p, ok := f.pending[key]
+	if !ok {
+		p = &PendingChange{Namespace: ing.Namespace, Name: ing.Name, Since: now}
+		f.pending[key] = p
+	}
+	changed := p.Revision != revision
+	p.Revision = revision
+	p.ApprovedRevision = ""
+	if approved != nil {
+		p.ApprovedRevision = ingressRevision(approved)
+	}
+	return approved, changed
+}
+
+// served returns the version of the Ingress admitted last, nil if it has
+// no approved version
+func (f *changeFreeze) served(ing *networking.Ingress) *networking.Ingress {
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+
+	f.mu.Lock()
+	_, pending := f.pending[key]
+	f.mu.Unlock()
+	if !pending {
+		return ing
+	}
+	return f.approved(ing)
+}
+
+// isPending returns the pending change of an Ingress, or nil
+func (f *changeFreeze) isPending(ing *networking.Ingress) *PendingChange {
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.pending[key]; ok {
+		c := *p
+		return &c
+	}
+	return nil
+}
+
+// remove forgets the pending change of a deleted Ingress. Its approved
+// version is removed with the next approval.
+func (f *changeFreeze) remove(ing *networking.Ingress) {
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.pending, key)
+	metric.SetPendingChanges(len(f.pending))
+}
+
+// list returns the pending changes sorted by namespace and name
+func (f *changeFreeze) list() []PendingChange {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changes := make([]PendingChange, 0, len(f.pending))
+	for _, p := range f.pending {
+		changes = append(changes, *p)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Namespace != changes[j].Namespace {
+			return changes[i].Namespace < changes[j].Namespace
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// admitIngress returns the version of the Ingress to serve, or nil, and
+// reports the new pending changes in an event
+func (n *NGINXController) admitIngress(ing *networking.Ingress) *networking.Ingress {
+	if n.freeze == nil {
+		return ing
+	}
+	served, pending := n.freeze.admit(ing, time.Now())
+	if pending {
+		glog.Infof("holding the changes of ingress %v/%v until revision %v is approved", ing.Namespace, ing.Name, ingressRevision(ing))
+		n.recorder.Eventf(ing, apiv1.EventTypeNormal, "ChangePending",
+			"the changes are held until revision %v is approved with /changes/approve", ingressRevision(ing))
+	}
+	return served
+}
+
+// createChangeFreezeInformer watches the ConfigMap of the approved versions.
+// A change of the approvals syncs the Ingresses.
+func (n *NGINXController) createChangeFreezeInformer(configMap string) (cache.Store, cache.Controller) {
+	ns, name, _ := k8s.ParseNameNS(configMap)
+	client := n.cfg.Client.CoreV1().ConfigMaps(ns)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	changed := func(obj interface{}) {
+		// an empty Ingress syncs without a trigger, like a rollback
+		n.syncQueue.Enqueue(&networking.Ingress{})
+	}
+
+	return cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector
+				return client.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector
+				return client.Watch(context.TODO(), options)
+			},
+		},
+		&apiv1.ConfigMap{}, n.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{
+			AddFunc: changed,
+			UpdateFunc: func(old, cur interface{}) {
+				if !reflect.DeepEqual(old.(*apiv1.ConfigMap).Data, cur.(*apiv1.ConfigMap).Data) {
+					changed(cur)
+				}
+			},
+			DeleteFunc: changed,
+		})
+}
+
+// PendingChanges returns the changes waiting for approval, nil if the
+// change freeze is disabled
+func (n *NGINXController) PendingChanges() []PendingChange {
+	if n.freeze == nil {
+		return nil
+	}
+	return n.freeze.list()
+}
+
+// ApproveChange approves the revision of an Ingress writing it in the
+// ConfigMap of the approved versions. The revision must be the current one,
+// so the approved changes are the ones that were reviewed. The approval of
+// an Ingress that does not match the selector anymore releases it, and the
+// approved versions of the deleted Ingresses are removed.
+func (n *NGINXController) ApproveChange(namespace, name, revision string) error {
+	obj, exists, err := n.listers.Ingress.GetByKey(fmt.Sprintf("%v/%v", namespace, name))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return apierrors.NewNotFound(networking.Resource("ingresses"), fmt.Sprintf("%v/%v", namespace, name))
+	}
+	ing := obj.(*networking.Ingress)
+	if current := ingressRevision(ing); current != revision {
+		return apierrors.NewConflict(networking.Resource("ingresses"), name,
+			fmt.Errorf("the current revision is %v", current))
+	}
+
+	anns := map[string]string{}
+	for k, v := range ing.Annotations {
+		if k != lastAppliedAnnotation && k != healthAnnotation() && k != healthMessageAnnotation() {
+			anns[k] = v
+		}
+	}
+	version, err := json.Marshal(approvedVersion{ing.Labels, anns, ing.Spec})
+	if err != nil {
+		return err
+	}
+
+	f := n.freeze
+	client := n.cfg.Client.CoreV1().ConfigMaps(f.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := client.Get(context.TODO(), f.configMapName, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if create {
+			cm = &apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: f.namespace, Name: f.configMapName}}
+		} else if err != nil {
+			return err
+		}
+
+		data := map[string]string{}
+		for k, v := range cm.Data {
+			parts := strings.SplitN(k, ".", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if _, exists, _ := n.listers.Ingress.GetByKey(parts[0] + "/" + parts[1]); exists {
+				data[k] = v
+			}
+		}
+		if f.selector.Matches(labels.Set(ing.Labels)) {
+			data[approvalKey(namespace, name)] = string(version)
+		} else {
+			delete(data, approvalKey(namespace, name))
+		}
+		cm.Data = data
+
+		if create {
+			_, err = client.Create(context.TODO(), cm, metav1.CreateOptions{})
+		} else {
+			_, err = client.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		}
+		return err
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func frozenIngress(backend string, lbls map[string]string, anns map[string]string) *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "console",
+			Labels:      lbls,
+			Annotations: anns,
+		},
+		Spec: networking.IngressSpec{
+			DefaultBackend: &networking.IngressBackend{
+				Service: &networking.IngressServiceBackend{Name: backend, Port: networking.ServiceBackendPort{Number: 80}},
+			},
+		},
+	}
+}
+
+// approve writes the approved version of the Ingress in the ConfigMap of
+// the store
+func approve(t *testing.T, store cache.Store, ing *networking.Ingress) {
+	b, err := json.Marshal(approvedVersion{ing.Labels, ing.Annotations, ing.Spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add(&apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "approvals"},
+		Data:       map[string]string{approvalKey(ing.Namespace, ing.Name): string(b)},
+	})
+}
+
+func TestIngressRevision(t *testing.T) {
+	ing := frozenIngress("console", nil, map[string]string{"a": "b"})
+	rev := ingressRevision(ing)
+
+	ing.Annotations[lastAppliedAnnotation] = "{}"
+	ing.Annotations[healthAnnotation()] = HealthProgrammed
+	if res := ingressRevision(ing); res != rev {
+		t.Errorf("expected the annotations of kubectl and the controller to keep the revision %v but returned %v", rev, res)
+	}
+
+	ing.Annotations["a"] = "c"
+	if res := ingressRevision(ing); res == rev {
+		t.Errorf("expected a new revision after an annotation change")
+	}
+}
+
+func TestChangeFreeze(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	f := newChangeFreeze(labels.SelectorFromSet(labels.Set{"env": "production"}), "kube-system/approvals", store)
+	prod := map[string]string{"env": "production"}
+	now := time.Now()
+
+	// Ingresses not matching the selector are not held
+	if res, pending := f.admit(frozenIngress("v1", nil, nil), now); res == nil || pending {
+		t.Errorf("expected an Ingress out of the selector to be served")
+	}
+
+	// a new frozen Ingress is not served until approved
+	v1 := frozenIngress("v1", prod, nil)
+	if res, pending := f.admit(v1, now); res != nil || !pending {
+		t.Errorf("expected an unapproved Ingress to be pending")
+	}
+	if res, pending := f.admit(v1, now); res != nil || pending {
+		t.Errorf("expected the same revision to be reported once")
+	}
+	if f.served(v1) != nil {
+		t.Errorf("expected an unapproved Ingress not to be served")
+	}
+
+	// the annotations of the Ingress do not approve it
+	selfApproved := frozenIngress("v1", prod, map[string]string{"ingress.open-cluster-management.io/approved-revision": ingressRevision(v1)})
+	if res, _ := f.admit(selfApproved, now); res != nil {
+		t.Errorf("expected an Ingress approved by its annotations not to be served")
+	}
+
+	approve(t, store, v1)
+	if res, pending := f.admit(v1, now); res != v1 || pending {
+		t.Errorf("expected the approved Ingress to be served")
+	}
+
+	// changes are held and the approved version is served, after a restart too
+	f = newChangeFreeze(labels.SelectorFromSet(labels.Set{"env": "production"}), "kube-system/approvals", store)
+	v2 := frozenIngress("v2", prod, nil)
+	if res, pending := f.admit(v2, now); res == nil || res.Spec.DefaultBackend.Service.Name != "v1" || !pending {
+		t.Errorf("expected the approved version to be served while the change is pending, returned %v", res)
+	}
+	if res := f.served(v2); res == nil || res.Spec.DefaultBackend.Service.Name != "v1" {
+		t.Errorf("expected the approved version to be served, returned %v", res)
+	}
+	changes := f.list()
+	if len(changes) != 1 || changes[0].Revision != ingressRevision(v2) || changes[0].ApprovedRevision != ingressRevision(v1) {
+		t.Errorf("unexpected pending changes %+v", changes)
+	}
+
+	// leaving the selector is a change too
+	unlabeled := frozenIngress("v1", nil, nil)
+	if res, _ := f.admit(unlabeled, now); res == nil || res.Labels["env"] != "production" {
+		t.Errorf("expected the Ingress to stay frozen after leaving the selector")
+	}
+
+	// reverting the change clears it
+	if res, _ := f.admit(v1, now); res != v1 || len(f.list()) != 0 {
+		t.Errorf("expected the approved revision to clear the pending changes")
+	}
+
+	f.admit(v2, now)
+	f.remove(v2)
+	if len(f.pending) != 0 {
+		t.Errorf("expected a deleted Ingress to be forgotten")
+	}
+}
+
+func TestApproveChange(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sl := &ingress.StoreLister{}
+	sl.Ingress.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	n := &NGINXController{
+		cfg:     &Configuration{Client: client},
+		listers: sl,
+		freeze: newChangeFreeze(labels.SelectorFromSet(labels.Set{"env": "production"}), "kube-system/approvals",
+			cache.NewStore(cache.MetaNamespaceKeyFunc)),
+	}
+
+	v1 := frozenIngress("v1", map[string]string{"env": "production"}, nil)
+	sl.Ingress.Add(v1)
+
+	if err := n.ApproveChange("default", "console", "other"); !apierrors.IsConflict(err) {
+		t.Errorf("expected a conflict approving another revision but returned %v", err)
+	}
+	if err := n.ApproveChange("default", "other", ingressRevision(v1)); !apierrors.IsNotFound(err) {
+		t.Errorf("expected an error approving a missing Ingress but returned %v", err)
+	}
+
+	// the approved versions of the deleted Ingresses are removed
+	client.CoreV1().ConfigMaps("kube-system").Create(context.TODO(), &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "approvals"},
+		Data:       map[string]string{approvalKey("default", "deleted"): "{}"},
+	}, metav1.CreateOptions{})
+	if err := n.ApproveChange("default", "console", ingressRevision(v1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("kube-system").Get(context.TODO(), "approvals", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cm.Data) != 1 {
+		t.Errorf("expected the approved version of the Ingress only but returned %v", cm.Data)
+	}
+
+	n.freeze.approvals.Add(cm)
+	if res := n.freeze.approved(v1); res == nil || ingressRevision(res) != ingressRevision(v1) {
+		t.Errorf("expected the approved version of the Ingress but returned %v", res)
+	}
+
+	// approving an Ingress out of the selector releases it
+	released := frozenIngress("v2", nil, nil)
+	sl.Ingress.Update(released)
+	if err := n.ApproveChange("default", "console", ingressRevision(released)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cm, _ = client.CoreV1().ConfigMaps("kube-system").Get(context.TODO(), "approvals", metav1.GetOptions{})
+	if len(cm.Data) != 0 {
+		t.Errorf("expected the Ingress to be released but returned %v", cm.Data)
+	}
+}
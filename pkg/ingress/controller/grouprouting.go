@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/grouprouting"
+)
+
+func groupUpstreamName(ing *networking.Ingress, r grouprouting.Route) string {
+	return fmt.Sprintf("%v-%v-%v", ing.GetNamespace(), r.Service, r.Port)
+}
+
+// createGroupUpstreams creates the upstreams of the Services of the group
+// routes of the Ingress. They use the upstream settings of the Ingress.
+func (n *NGINXController) createGroupUpstreams(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) {
+	for _, route := range anns.GroupRouting.Routes {
+		if route.Deny {
+			continue
+		}
+		name := groupUpstreamName(ing, route)
+		if _, ok := upstreams[name]; ok {
+			continue
+		}
+
+		glog.V(3).Infof("creating upstream %v of the group %v", name, route.Group)
+		ups := newUpstream(name)
+		ups.Port = intstr.FromInt(route.Port)
+		ups.Secure = anns.SecureUpstream.Secure
+		ups.SecureCACert = anns.SecureUpstream.CACert
+		ups.ClientCACert = anns.SecureUpstream.ClientCACert
+		ups.IPFamily = anns.IPFamily.Upstream
+
+		svcKey := fmt.Sprintf("%v/%v", ing.GetNamespace(), route.Service)
+		s, err := n.listers.Service.GetByName(svcKey)
+		if err != nil {
+			glog.Warningf("error obtaining service of the group %v: %v", route.Group, err)
+			continue
+		}
+		ups.Service = s
+		ups.ClusterIP = serviceClusterIP(s, ups.IPFamily)
+		upstreams[name] = ups
+	}
+}
+
+// groupRoutingConfig returns the group routes of the locations of the
+// Ingress, with the upstreams of the Services that are available
+func groupRoutingConfig(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) grouprouting.Config {
+	c := grouprouting.Config{}
+	for _, route := range anns.GroupRouting.Routes {
+		if !route.Deny {
+			if ups, ok := upstreams[groupUpstreamName(ing, route)]; ok && ups.HasAddress() {
+				route.Upstream = ups.Name
+			} else {
+				glog.Warningf("the requests of the group %v of ingress %v/%v go to the backend of the location: service %v is not available",
+					route.Group, ing.Namespace, ing.Name, route.Service)
+			}
+		}
+		c.Routes = append(c.Routes, route)
+	}
+	return c
+}
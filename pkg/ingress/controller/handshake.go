@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+	"github.com/stolostron/management-ingress/pkg/ingress/externaldns"
+	"github.com/stolostron/management-ingress/pkg/ingress/handshake"
+	"github.com/stolostron/management-ingress/pkg/ingress/schema"
+	"github.com/stolostron/management-ingress/pkg/version"
+)
+
+// modes returns the enabled modes of operation of the controller
+func (n *NGINXController) modes() []string {
+	cfg := n.cfg
+	enabled := map[string]bool{
+		"update-status":          cfg.UpdateStatus,
+		"external-dns":           cfg.ExternalDNS,
+		"aws-target-group":       cfg.TargetGroupARN != "",
+		"vip":                    cfg.VIP != "",
+		"publish-service":        cfg.PublishService != "",
+		"publish-status-address": len(cfg.PublishStatusAddresses) > 0,
+		"spiffe":                 cfg.SPIFFESocket != "",
+		"lua-filters":            cfg.LuaFilterBundle != "",
+		"maintenance-windows":    len(cfg.MaintenanceWindows) > 0,
+		"change-freeze":          cfg.ChangeFreezeSelector != nil,
+		"model-cache":            cfg.ModelCacheDir != "",
+		"model-api":              cfg.EnableModelAPI,
+		"health-report":          cfg.ReportHealth,
+		"descheduler-hint":       cfg.DeschedulerHint,
+		"shared-certificates":    cfg.SharedCertNamespace != "",
+		"acme-challenges":        cfg.ACMEChallenges,
+		"startup-gate":           cfg.StartupGate,
+		"canary-routes":          len(cfg.CanaryRoutes) > 0,
+		"config-history":         cfg.ConfigHistoryDir != "",
+		"unix-sockets":           len(cfg.UnixSocketDirs) > 0,
+		"canary-api":             cfg.EnableCanaryAPI,
+		"break-glass":            cfg.BreakGlassAfter > 0,
+		"status-repair":          cfg.StatusRepairInterval > 0 || cfg.EnableStatusRepairAPI,
+		"status-summary":         cfg.StatusSummaryConfigMap != "",
+	}
+
+	var modes []string
+	for mode, ok := range enabled {
+		if ok {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+// handshakeCapabilities returns the capabilities of the replica published
+// for the operator
+func (n *NGINXController) handshakeCapabilities() handshake.Capabilities {
+	c := handshake.Capabilities{
+		Release:      version.RELEASE,
+		Modes:        n.modes(),
+		FeatureGates: n.cfg.FeatureGates,
+	}
+	for _, o := range schema.Annotations() {
+		c.Annotations = append(c.Annotations, o.Name)
+	}
+	if n.cfg.ExternalDNS && n.cfg.FeatureGates[ExternalDNSEndpoints] {
+		gvr := externaldns.DNSEndpoints
+		c.CRDs = append(c.CRDs, fmt.Sprintf("%v.%v/%v", gvr.Resource, gvr.Group, gvr.Version))
+	}
+	for _, s := range n.capabilities.Capabilities {
+		if s.Enabled() {
+			c.Subsystems = append(c.Subsystems, s.Name)
+		}
+	}
+	for _, p := range plugin.Registered() {
+		c.Plugins = append(c.Plugins, fmt.Sprintf("%v/%v", p.Name(), p.Contract()))
+	}
+	return c
+}
+
+// newCapabilityPublisher returns the publisher of the capabilities of the
+// replica in the ConfigMap of the handshake
+func (n *NGINXController) newCapabilityPublisher() *handshake.Publisher {
+	return &handshake.Publisher{
+		Client:       n.cfg.Client,
+		Namespace:    os.Getenv("POD_NAMESPACE"),
+		Name:         n.cfg.CapabilitiesConfigMap,
+		Pod:          os.Getenv("POD_NAME"),
+		Interval:     n.cfg.CapabilitiesInterval,
+		Capabilities: n.handshakeCapabilities,
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// HealthProgrammed means the current version of the Ingress is served
+	HealthProgrammed = "Programmed"
+	// HealthPending means the current version of the Ingress is waiting
+	// for a reload, a maintenance window or an approval
+	HealthPending = "Pending"
+	// HealthError means the Ingress can not be served as defined
+	HealthError = "Error"
+
+	// maxHealthMessage is the maximum length of the health message
+	maxHealthMessage = 512
+	// healthPatchQPS and healthPatchBurst limit the patches of the health
+	// of the Ingresses
+	healthPatchQPS   = 5
+	healthPatchBurst = 10
+)
+
+// healthAnnotation returns the annotation with the health of an Ingress
+func healthAnnotation() string {
+	return parser.GetAnnotationWithPrefix("health")
+}
+
+// healthMessageAnnotation returns the annotation with the reason of the
+// health of an Ingress
+func healthMessageAnnotation() string {
+	return parser.GetAnnotationWithPrefix("health-message")
+}
+
+// healthTracker keeps the revisions of the Ingresses in the running
+// configuration and the error of the last reload
+type healthTracker struct {
+	mu sync.Mutex
+	// programmed contains the revision of each Ingress served by NGINX
+	programmed map[string]string
+	// routed contains the Ingresses with a location in the running
+	// configuration
+	routed      map[string]bool
+	reloadError string
+	// culprits contains the Ingresses of the locations the last reload
+	// error points to, empty if unknown
+	culprits []string
+	// reported contains the health written in each Ingress, until the
+	// lister has the patched version
+	reported map[string]reportedHealth
+
+	// queue contains the keys of the Ingresses whose health changed,
+	// patched by a single worker
+	queue   workqueue.RateLimitingInterface
+	limiter flowcontrol.RateLimiter
+}
+
+// reportedHealth is the health written in an Ingress and the resource
+// version of the Ingress before the patch
+type reportedHealth struct {
+	value  string
+	before string
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		programmed: map[string]string{},
+		routed:     map[string]bool{},
+		reported:   map[string]reportedHealth{},
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "health"),
+		limiter:    flowcontrol.NewTokenBucketRateLimiter(healthPatchQPS, healthPatchBurst),
+	}
+}
+
+// applied records the Ingresses of the running configuration after a
+// successful reload or a sync without changes
+func (h *healthTracker) applied(ings []*networking.Ingress, cfg *ingress.Configuration) {
+	programmed := make(map[string]string, len(ings))
+	for _, ing := range ings {
+		programmed[fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)] = ingressRevision(ing)
+	}
+	routed := map[string]bool{}
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress != nil {
+				routed[fmt.Sprintf("%v/%v", loc.Ingress.Namespace, loc.Ingress.Name)] = true
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.programmed = programmed
+	h.routed = routed
+	h.reloadError = ""
+	h.culprits = nil
+}
+
+// failed records the error of a reload
+func (h *healthTracker) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reloadError = reloadReason(err.Error())
+	h.culprits = nil
+	if ce, ok := err.(*configError); ok {
+		h.culprits = ce.ingresses
+	}
+}
+
+// reloadReason returns the first error of NGINX in the output of a failed
+// reload, or its first line
+func reloadReason(output string) string {
+	var first string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "[emerg]") || strings.Contains(line, "[error]") {
+			return line
+		}
+		if first == "" && strings.Trim(line, "-") != "" {
+			first = line
+		}
+	}
+	return first
+}
+
+// ingressState is the state of an Ingress in the tracker
+type ingressState struct {
+	programmed  string
+	routed      bool
+	reloadError string
+	culprits    []string
+}
+
+// lookup returns the state of an Ingress
+func (h *healthTracker) lookup(key string) ingressState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return ingressState{
+		programmed:  h.programmed[key],
+		routed:      h.routed[key],
+		reloadError: h.reloadError,
+		culprits:    h.culprits,
+	}
+}
+
+// configError is an invalid configuration with the Ingresses of the
+// locations its errors point to
+type configError struct {
+	error
+	ingresses []string
+}
+
+// configErrorLine matches the lines of the configuration in the errors of
+// NGINX, like "in /tmp/nginx-test123/nginx.conf:456"
+var configErrorLine = regexp.MustCompile(`nginx\.conf:(\d+)`)
+
+// errorIngresses returns the Ingresses of the locations of the rendered
+// configuration the NGINX output points to, sorted. Each location starts
+// with a "# ingress <namespace>/<name>" comment.
+func errorIngresses(content []byte, output string) []string {
+	lines := strings.Split(string(content), "\n")
+	found := map[string]bool{}
+	for _, match := range configErrorLine.FindAllStringSubmatch(output, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(lines) {
+			continue
+		}
+		for i := n - 1; i >= 0; i-- {
+			line := strings.TrimSpace(lines[i])
+			if strings.HasPrefix(line, "## start server") || strings.HasPrefix(line, "## end server") {
+				break
+			}
+			if line == "# ingress" || strings.HasPrefix(line, "# ingress ") {
+				// the locations without Ingress have an empty comment
+				if key := strings.TrimSpace(strings.TrimPrefix(line, "# ingress")); key != "" {
+					found[key] = true
+				}
+				break
+			}
+		}
+	}
+
+	ingresses := make([]string, 0, len(found))
+	for key := range found {
+		ingresses = append(ingresses, key)
+	}
+	sort.Strings(ingresses)
+	return ingresses
+}
+
+// ingressHealth returns the health of the current version of an Ingress and
+// the reason
+func (n *NGINXController) ingressHealth(ing *networking.Ingress) (string, string) {
+	if n.freeze != nil {
+		if p := n.freeze.isPending(ing); p != nil {
+			return HealthPending, fmt.Sprintf("revision %v is waiting for approval", p.Revision)
+		}
+	}
+
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+	state := n.health.lookup(key)
+	if state.programmed != ingressRevision(ing) {
+		if state.reloadError != "" {
+			culprit := len(state.culprits) == 0
+			for _, c := range state.culprits {
+				culprit = culprit || c == key
+			}
+			if culprit {
+				return HealthError, fmt.Sprintf("the reload failed: %v", state.reloadError)
+			}
+			return HealthPending, fmt.Sprintf("the reload is blocked by the configuration of ingress %v", strings.Join(state.culprits, ", "))
+		}
+		if id := n.PinnedRevision(); id > 0 {
+			return HealthError, fmt.Sprintf("the configuration is rolled back to the revision %v", id)
+		}
+		if d := n.DeferredReload(); d != nil {
+			return HealthPending, fmt.Sprintf("the reload is deferred to the maintenance window at %v", d.NextWindow.Format(time.RFC3339))
+		}
+		return HealthPending, "waiting for the reload"
+	}
+
+	if anns := n.getIngressAnnotations(ing); len(anns.Errors) > 0 {
+		return HealthError, utilerrors.NewAggregate(anns.Errors).Error()
+	}
+	if !state.routed {
+		return HealthError, "no path of the Ingress is served, they are taken by other Ingresses or have no rules"
+	}
+	return HealthProgrammed, ""
+}
+
+// ingressHealthValue returns the health of an Ingress, its message
+// truncated, and both joined to compare them
+func (n *NGINXController) ingressHealthValue(ing *networking.Ingress) (string, string, string) {
+	state, message := n.ingressHealth(ing)
+	if len(message) > maxHealthMessage {
+		message = message[:maxHealthMessage]
+	}
+	return state, message, state + "\n" + message
+}
+
+// healthChanged returns true if the health of the Ingress differs from its
+// annotations. The Ingresses patched before the lister has the new version
+// are not changed.
+func (h *healthTracker) healthChanged(key string, ing *networking.Ingress, value string) bool {
+	anns := ing.GetAnnotations()
+	if anns[healthAnnotation()]+"\n"+anns[healthMessageAnnotation()] == value {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.reported[key]
+	return !ok || r.value != value || r.before != ing.ResourceVersion
+}
+
+// reportHealth queues the Ingresses whose health changed, patched by
+// runHealthReports. Only the status update leader writes them when there
+// are several replicas.
+func (n *NGINXController) reportHealth() {
+	if n.health == nil || (n.syncStatus != nil && !n.syncStatus.IsLeader()) {
+		return
+	}
+
+	keys := map[string]bool{}
+	for _, obj := range n.listers.Ingress.List() {
+		ing := obj.(*networking.Ingress)
+		if !class.IsValid(ing) {
+			continue
+		}
+		key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+		keys[key] = true
+		if _, _, value := n.ingressHealthValue(ing); n.health.healthChanged(key, ing, value) {
+			n.health.queue.Add(key)
+		}
+	}
+
+	// the deleted Ingresses are forgotten
+	n.health.mu.Lock()
+	for key := range n.health.reported {
+		if !keys[key] {
+			delete(n.health.reported, key)
+		}
+	}
+	n.health.mu.Unlock()
+}
+
+// runHealthReports patches the health of the queued Ingresses, rate
+// limited, until the queue is shut down
+func (n *NGINXController) runHealthReports() {
+	for {
+		item, quit := n.health.queue.Get()
+		if quit {
+			return
+		}
+		key := item.(string)
+		if err := n.patchHealth(key); err != nil {
+			category := errors.CategoryOf(err)
+			metric.IncSyncError("health", string(category))
+			glog.Warningf("unexpected error updating the health of ingress %v (%v): %v", key, category, err)
+			n.health.queue.AddRateLimited(key)
+		} else {
+			n.health.queue.Forget(key)
+		}
+		n.health.queue.Done(key)
+	}
+}
+
+// patchHealth writes the current health of an Ingress in its annotations if
+// it changed
+func (n *NGINXController) patchHealth(key string) error {
+	obj, exists, err := n.listers.Ingress.GetByKey(key)
+	if err != nil || !exists {
+		return err
+	}
+	ing := obj.(*networking.Ingress)
+	if !class.IsValid(ing) {
+		return nil
+	}
+	state, message, value := n.ingressHealthValue(ing)
+	if !n.health.healthChanged(key, ing, value) {
+		return nil
+	}
+
+	var messageValue interface{}
+	if message != "" {
+		messageValue = message
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				healthAnnotation():        state,
+				healthMessageAnnotation(): messageValue,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	n.health.limiter.Accept()
+	glog.V(2).Infof("updating the health of ingress %v to %v", key, state)
+	_, err = n.cfg.Client.NetworkingV1().Ingresses(ing.Namespace).Patch(context.TODO(), ing.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+
+	n.health.mu.Lock()
+	n.health.reported[key] = reportedHealth{value: value, before: ing.ResourceVersion}
+	n.health.mu.Unlock()
+	return nil
+}
+
+// onlyHealthChanged returns true if an update of an Ingress only changed
+// its health annotations, so the patches of the health do not sync
+func onlyHealthChanged(old, cur *networking.Ingress) bool {
+	o, c := old.GetAnnotations(), cur.GetAnnotations()
+	if o[healthAnnotation()] == c[healthAnnotation()] && o[healthMessageAnnotation()] == c[healthMessageAnnotation()] {
+		return false
+	}
+	return ingressRevision(old) == ingressRevision(cur)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+func TestIngressHealth(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "default", Name: "console", Labels: map[string]string{"env": "production"}}
+	ing := &networking.Ingress{ObjectMeta: meta}
+
+	sl := &ingress.StoreLister{}
+	sl.IngressAnnotation.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.IngressAnnotation.Add(&annotations.Ingress{ObjectMeta: meta})
+
+	n := &NGINXController{
+		listers: sl,
+		health:  newHealthTracker(),
+	}
+
+	if state, _ := n.ingressHealth(ing); state != HealthPending {
+		t.Errorf("expected a new Ingress to be pending but returned %v", state)
+	}
+
+	// the Ingresses without locations are not served
+	n.health.applied([]*networking.Ingress{ing}, &ingress.Configuration{})
+	if state, _ := n.ingressHealth(ing); state != HealthError {
+		t.Errorf("expected an Ingress without locations to be an error but returned %v", state)
+	}
+
+	cfg := &ingress.Configuration{Servers: []*ingress.Server{
+		{Hostname: "_", Locations: []*ingress.Location{{Path: "/", Ingress: ing}}},
+	}}
+	n.health.applied([]*networking.Ingress{ing}, cfg)
+	if state, msg := n.ingressHealth(ing); state != HealthProgrammed || msg != "" {
+		t.Errorf("expected the Ingress to be programmed but returned %v %v", state, msg)
+	}
+
+	// the health annotations do not change the revision
+	reported := ing.DeepCopy()
+	reported.Annotations = map[string]string{healthAnnotation(): HealthProgrammed}
+	if state, _ := n.ingressHealth(reported); state != HealthProgrammed {
+		t.Errorf("expected the health annotations to keep the Ingress programmed but returned %v", state)
+	}
+
+	changed := ing.DeepCopy()
+	changed.Annotations = map[string]string{"ingress.open-cluster-management.io/proxy-read-timeout": "120"}
+	n.health.failed(fmt.Errorf("\n-----\nError: exit status 1\nnginx: [emerg] invalid directive\nmore output"))
+	if state, msg := n.ingressHealth(changed); state != HealthError || msg != "the reload failed: nginx: [emerg] invalid directive" {
+		t.Errorf("expected a failed reload to be an error but returned %v %v", state, msg)
+	}
+
+	// the errors in the locations of another Ingress only block the reload
+	n.health.failed(&configError{fmt.Errorf("nginx: [emerg] invalid directive"), []string{"default/api"}})
+	if state, msg := n.ingressHealth(changed); state != HealthPending || msg != "the reload is blocked by the configuration of ingress default/api" {
+		t.Errorf("expected a reload failed by another Ingress to be pending but returned %v %v", state, msg)
+	}
+	n.health.failed(&configError{fmt.Errorf("nginx: [emerg] invalid directive"), []string{"default/console"}})
+	if state, _ := n.ingressHealth(changed); state != HealthError {
+		t.Errorf("expected the Ingress of the error to be an error but returned %v", state)
+	}
+	if state, _ := n.ingressHealth(ing); state != HealthProgrammed {
+		t.Errorf("expected the running version to stay programmed but returned %v", state)
+	}
+
+	sl.IngressAnnotation.Update(&annotations.Ingress{
+		ObjectMeta: meta,
+		Errors:     []error{errors.NewInvalidAnnotationContent("proxy-read-timeout", "x")},
+	})
+	if state, _ := n.ingressHealth(ing); state != HealthError {
+		t.Errorf("expected invalid annotations to be an error but returned %v", state)
+	}
+
+	n.freeze = newChangeFreeze(labels.SelectorFromSet(labels.Set{"env": "production"}), "kube-system/approvals",
+		cache.NewStore(cache.MetaNamespaceKeyFunc))
+	n.freeze.admit(ing, time.Now())
+	if state, _ := n.ingressHealth(ing); state != HealthPending {
+		t.Errorf("expected a change waiting for approval to be pending but returned %v", state)
+	}
+}
+
+func TestErrorIngresses(t *testing.T) {
+	content := []byte(`http {
+    ## start server _
+    server {
+        # ingress default/console
+        location /console {
+            proxy_pass http://console;
+        }
+        # ingress
+        location / {
+            return 404;
+        }
+        # ingress default/api
+        location /api {
+            invalid;
+        }
+        listen 80;
+    }
+    ## end server _
+}`)
+
+	for _, tc := range []struct {
+		output   string
+		expected []string
+	}{
+		{`nginx: [emerg] unknown directive "invalid" in /tmp/nginx-test1/nginx.conf:14`, []string{"default/api"}},
+		{`nginx: [emerg] invalid port in /tmp/nginx-test1/nginx.conf:6`, []string{"default/console"}},
+		{`nginx: [emerg] invalid parameter in /tmp/nginx-test1/nginx.conf:10`, []string{}},
+		{`nginx: [emerg] invalid port in /tmp/nginx-test1/nginx.conf:1`, []string{}},
+		{`nginx: [emerg] no line`, []string{}},
+	} {
+		ingresses := errorIngresses(content, tc.output)
+		if !reflect.DeepEqual(ingresses, tc.expected) {
+			t.Errorf("expected %v for %q but returned %v", tc.expected, tc.output, ingresses)
+		}
+	}
+}
+
+func TestHealthReports(t *testing.T) {
+	h := newHealthTracker()
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "console", ResourceVersion: "1"}}
+	value := HealthProgrammed + "\n"
+
+	if !h.healthChanged("default/console", ing, value) {
+		t.Errorf("expected the health to change without annotations")
+	}
+
+	// the lister does not have the patched version yet
+	h.reported["default/console"] = reportedHealth{value: value, before: "1"}
+	if h.healthChanged("default/console", ing, value) {
+		t.Errorf("expected the patched health not to change")
+	}
+
+	// the annotations were changed by another client
+	ing.ResourceVersion = "3"
+	if !h.healthChanged("default/console", ing, value) {
+		t.Errorf("expected the health to change with other annotations")
+	}
+
+	ing.Annotations = map[string]string{healthAnnotation(): HealthProgrammed}
+	if h.healthChanged("default/console", ing, value) {
+		t.Errorf("expected the health in the annotations not to change")
+	}
+
+	// the patches of the health do not sync
+	cur := ing.DeepCopy()
+	cur.Annotations[healthAnnotation()] = HealthError
+	if !onlyHealthChanged(ing, cur) {
+		t.Errorf("expected only the health to change")
+	}
+	cur.Annotations["ingress.open-cluster-management.io/proxy-read-timeout"] = "120"
+	if onlyHealthChanged(ing, cur) {
+		t.Errorf("expected the annotations to change")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/history"
+	"github.com/stolostron/management-ingress/pkg/task"
+)
+
+// errHistoryDisabled is returned by the revision operations without a
+// configuration history
+var errHistoryDisabled = fmt.Errorf("the configuration history is disabled")
+
+// revisionTrigger returns the reason of a reload from the item of the
+// sync queue
+func revisionTrigger(item interface{}) string {
+	if element, ok := item.(task.Element); ok {
+		if key, ok := element.Key.(string); ok && key != "" {
+			return "sync of " + key
+		}
+	}
+	return "sync"
+}
+
+// recordRevision stores the running configuration in the history
+func (n *NGINXController) recordRevision(trigger string, model *ingress.Configuration) {
+	if n.history == nil {
+		return
+	}
+	content, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		glog.Warningf("unexpected error reading the configuration of the revision: %v", err)
+		return
+	}
+	rev, err := n.history.Record(trigger, history.ModelHash(model), content)
+	if err != nil {
+		glog.Warningf("unexpected error recording the revision: %v", err)
+		return
+	}
+	glog.V(2).Infof("recorded the configuration revision %v (%v)", rev.ID, trigger)
+}
+
+// Revisions returns the revisions of the configuration history, the
+// newest first
+func (n *NGINXController) Revisions() ([]history.Revision, error) {
+	if n.history == nil {
+		return nil, errHistoryDisabled
+	}
+	return n.history.List()
+}
+
+// DiffRevisions returns the unified diff between the configurations of two
+// revisions
+func (n *NGINXController) DiffRevisions(from, to int) ([]byte, error) {
+	if n.history == nil {
+		return nil, errHistoryDisabled
+	}
+	for _, id := range []int{from, to} {
+		if _, _, err := n.history.Get(id); err != nil {
+			return nil, err
+		}
+	}
+
+	// executing diff returns the exit code 1 when the files differ
+	// #nosec
+	out, err := exec.Command("diff", "-u", n.history.Path(from), n.history.Path(to)).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	return out, err
+}
+
+// PinnedRevision returns the revision restored by a rollback, or zero if
+// the configuration follows the model
+func (n *NGINXController) PinnedRevision() int {
+	return int(atomic.LoadInt32(&n.pinnedRevision))
+}
+
+// Rollback restores the configuration of a revision and holds the reloads
+// of the model until ReleaseRollback is called. The configuration is
+// validated before the rollback is queued.
+func (n *NGINXController) Rollback(id int) error {
+	if n.history == nil {
+		return errHistoryDisabled
+	}
+	_, content, err := n.history.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := n.validate(content); err != nil {
+		return fmt.Errorf("the configuration of the revision %v is not valid anymore: %v", id, err)
+	}
+
+	glog.Infof("rolling back the configuration to the revision %v", id)
+	atomic.StoreInt32(&n.pinnedRevision, int32(id))
+	n.syncQueue.Enqueue(&networking.Ingress{})
+	return nil
+}
+
+// ReleaseRollback resumes the reloads of the model after a rollback. It
+// returns false if there is no rollback.
+func (n *NGINXController) ReleaseRollback() bool {
+	if atomic.SwapInt32(&n.pinnedRevision, 0) == 0 {
+		return false
+	}
+	glog.Infof("releasing the rollback, the configuration follows the model again")
+	n.syncQueue.Enqueue(&networking.Ingress{})
+	return true
+}
+
+// applyRollback reloads NGINX with the configuration of the pinned
+// revision, once. It runs in the sync queue, so it never races with the
+// reloads of the model.
+func (n *NGINXController) applyRollback(id int) error {
+	if n.appliedRevision == id {
+		glog.V(3).Infof("skipping backend reload (pinned to the revision %v)", id)
+		return nil
+	}
+
+	rev, content, err := n.history.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := n.writeConfig(content); err != nil {
+		return err
+	}
+	if err := n.master.Reload(); err != nil {
+		return err
+	}
+	n.appliedRevision = id
+
+	trigger := fmt.Sprintf("rollback to the revision %v", id)
+	if _, err := n.history.Record(trigger, rev.ModelHash, content); err != nil {
+		glog.Warningf("unexpected error recording the revision: %v", err)
+	}
+	if pod := podReference(); pod.Name != "" && pod.Namespace != "" {
+		n.recorder.Eventf(pod, apiv1.EventTypeWarning, "ConfigRolledBack", "the configuration was rolled back to the revision %v of %v",
+			id, rev.Timestamp.Format(time.RFC3339))
+	}
+	glog.Infof("the configuration was rolled back to the revision %v", id)
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/history"
+	"github.com/stolostron/management-ingress/pkg/task"
+)
+
+func TestRevisionTrigger(t *testing.T) {
+	if res := revisionTrigger(task.Element{Key: "default/console"}); res != "sync of default/console" {
+		t.Errorf("expected the key of the item but returned %v", res)
+	}
+	if res := revisionTrigger(task.Element{Key: ""}); res != "sync" {
+		t.Errorf("expected a sync without key but returned %v", res)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := &NGINXController{}
+	if _, err := n.Revisions(); err != errHistoryDisabled {
+		t.Errorf("expected an error without history but returned %v", err)
+	}
+
+	n.history = history.New(dir, 10)
+	for _, content := range []string{"worker_processes 1;\n", "worker_processes 2;\n"} {
+		if _, err := n.history.Record("sync", "", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := n.DiffRevisions(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error comparing the revisions: %v", err)
+	}
+	if !strings.Contains(string(diff), "-worker_processes 1;") || !strings.Contains(string(diff), "+worker_processes 2;") {
+		t.Errorf("expected a unified diff but returned %s", diff)
+	}
+	if _, err := n.DiffRevisions(1, 3); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("expected a missing revision but returned %v", err)
+	}
+	if n.ReleaseRollback() {
+		t.Errorf("expected no rollback to release")
+	}
+}
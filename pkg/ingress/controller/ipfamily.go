@@ -0,0 +1,72 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+	ngx_template "github.com/stolostron/management-ingress/pkg/ingress/controller/template"
+)
+
+// ipv6Enabled returns true if NGINX listens on IPv6 addresses
+func (n *NGINXController) ipv6Enabled() bool {
+	if !n.isIPV6Enabled {
+		return false
+	}
+	if n.configmap == nil {
+		return true
+	}
+	return !ngx_template.ReadConfig(n.configmap.Data).DisableIpv6
+}
+
+// serverIPFamily returns the address family a server of the Ingress listens
+// on. The default server always listens on both, and ipv6 is ignored when
+// NGINX does not listen on IPv6 addresses.
+func (n *NGINXController) serverIPFamily(ing *networking.Ingress, host, family string) string {
+	if family == "" || host == defServerName {
+		return ""
+	}
+	if family == ipfamily.IPv6 && !n.ipv6Enabled() {
+		glog.Warningf("ignoring the ipv6 family of host %v of Ingress %v/%v: IPv6 is not enabled", host, ing.Namespace, ing.Name)
+		return ""
+	}
+	return family
+}
+
+// serviceClusterIP returns the ClusterIP of the Service of the address
+// family, the primary one if empty. A dual-stack Service has a ClusterIP
+// per family.
+func serviceClusterIP(s *apiv1.Service, family string) string {
+	if family == "" || s.Spec.ClusterIP == "" || s.Spec.ClusterIP == apiv1.ClusterIPNone {
+		return s.Spec.ClusterIP
+	}
+
+	for _, ip := range clusterIPs(s) {
+		if ipfamily.Matches(family, ip) {
+			return ip
+		}
+	}
+	glog.Warningf("service %v/%v has no %v ClusterIP", s.Namespace, s.Name, family)
+	return ""
+}
+
+// hasIPFamily returns true if the Service has a ClusterIP of the family
+func hasIPFamily(s *apiv1.Service, family string) bool {
+	for _, ip := range clusterIPs(s) {
+		if ipfamily.Matches(family, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func clusterIPs(s *apiv1.Service) []string {
+	if len(s.Spec.ClusterIPs) > 0 {
+		return s.Spec.ClusterIPs
+	}
+	return []string{s.Spec.ClusterIP}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+)
+
+func TestServerIPFamily(t *testing.T) {
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "hub", Name: "api"}}
+
+	n := &NGINXController{isIPV6Enabled: true}
+	if f := n.serverIPFamily(ing, "api.example.com", ipfamily.IPv6); f != ipfamily.IPv6 {
+		t.Errorf("expected the ipv6 family but returned %q", f)
+	}
+	if f := n.serverIPFamily(ing, defServerName, ipfamily.IPv4); f != "" {
+		t.Errorf("expected the default server to listen on both families but returned %q", f)
+	}
+
+	n.configmap = &apiv1.ConfigMap{Data: map[string]string{"disable-ipv6": "true"}}
+	if f := n.serverIPFamily(ing, "api.example.com", ipfamily.IPv6); f != "" {
+		t.Errorf("expected ipv6 to be ignored without IPv6 but returned %q", f)
+	}
+	if f := n.serverIPFamily(ing, "api.example.com", ipfamily.IPv4); f != ipfamily.IPv4 {
+		t.Errorf("expected the ipv4 family but returned %q", f)
+	}
+}
+
+func TestServiceClusterIP(t *testing.T) {
+	dual := &apiv1.Service{Spec: apiv1.ServiceSpec{
+		ClusterIP:  "10.96.0.10",
+		ClusterIPs: []string{"10.96.0.10", "fd00:10:96::a"},
+	}}
+	single := &apiv1.Service{Spec: apiv1.ServiceSpec{ClusterIP: "10.96.0.11"}}
+
+	testCases := []struct {
+		svc      *apiv1.Service
+		family   string
+		expected string
+	}{
+		{dual, "", "10.96.0.10"},
+		{dual, ipfamily.IPv4, "10.96.0.10"},
+		{dual, ipfamily.IPv6, "fd00:10:96::a"},
+		{single, ipfamily.IPv4, "10.96.0.11"},
+		{single, ipfamily.IPv6, ""},
+	}
+	for _, tc := range testCases {
+		if ip := serviceClusterIP(tc.svc, tc.family); ip != tc.expected {
+			t.Errorf("expected %q with family %q but returned %q", tc.expected, tc.family, ip)
+		}
+	}
+}
+
+func TestBackendEndpointsIPFamily(t *testing.T) {
+	svc := &apiv1.Service{Spec: apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Name: "https", Port: 443}}}}
+	ep := &apiv1.Endpoints{Subsets: []apiv1.EndpointSubset{{
+		Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "fd00::1"}},
+		Ports:     []apiv1.EndpointPort{{Name: "https", Port: 8443}},
+	}}}
+
+	b := &ingress.Backend{Service: svc, Port: intstr.FromInt(443), IPFamily: ipfamily.IPv6}
+	if eps := backendEndpoints(b, ep); !reflect.DeepEqual(eps, []string{"[fd00::1]:8443"}) {
+		t.Errorf("expected only the IPv6 endpoint but returned %v", eps)
+	}
+}
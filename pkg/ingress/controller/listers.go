@@ -26,6 +26,7 @@ import (
 	"github.com/golang/glog"
 
 	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/stolostron/management-ingress/pkg/ingress"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
 )
 
 type cacheController struct {
@@ -42,6 +44,12 @@ type cacheController struct {
 	Service   cache.Controller
 	Secret    cache.Controller
 	Configmap cache.Controller
+	// EndpointSlice is nil without the upstream drain period
+	EndpointSlice cache.Controller
+	// OIDCPolicy is nil without the OIDCRoutePolicies feature gate
+	OIDCPolicy cache.Controller
+	// ChangeFreeze is nil without the change freeze
+	ChangeFreeze cache.Controller
 }
 
 func (c *cacheController) Run(stopCh chan struct{}) {
@@ -51,14 +59,28 @@ func (c *cacheController) Run(stopCh chan struct{}) {
 	go c.Secret.Run(stopCh)
 	go c.Configmap.Run(stopCh)
 
-	// Wait for all involved caches to be synced, before processing items from the queue is started
-	if !cache.WaitForCacheSync(stopCh,
+	synced := []cache.InformerSynced{
 		c.Ingress.HasSynced,
 		c.Endpoint.HasSynced,
 		c.Service.HasSynced,
 		c.Secret.HasSynced,
 		c.Configmap.HasSynced,
-	) {
+	}
+	if c.EndpointSlice != nil {
+		go c.EndpointSlice.Run(stopCh)
+		synced = append(synced, c.EndpointSlice.HasSynced)
+	}
+	if c.OIDCPolicy != nil {
+		go c.OIDCPolicy.Run(stopCh)
+		synced = append(synced, c.OIDCPolicy.HasSynced)
+	}
+	if c.ChangeFreeze != nil {
+		go c.ChangeFreeze.Run(stopCh)
+		synced = append(synced, c.ChangeFreeze.HasSynced)
+	}
+
+	// Wait for all involved caches to be synced, before processing items from the queue is started
+	if !cache.WaitForCacheSync(stopCh, synced...) {
 		runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 	}
 }
@@ -67,15 +89,21 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 	ingEventHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			addIng := obj.(*networking.Ingress)
-			if !class.IsValid(addIng) {
+			if !n.isValidIngress(addIng) {
 				a := addIng.GetAnnotations()[class.IngressKey]
 				glog.Infof("ignoring add for ingress %v based on annotation %v with value %v", addIng.Name, class.IngressKey, a)
 				return
 			}
 
-			n.extractAnnotations(addIng)
+			if ing := n.admitIngress(addIng); ing != nil {
+				n.extractAnnotations(ing)
+			}
 			n.recorder.Eventf(addIng, apiv1.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", addIng.Namespace, addIng.Name))
 			n.syncQueue.Enqueue(obj)
+			// the status of the new Ingresses is set without waiting for the resync
+			if n.syncStatus != nil {
+				n.syncStatus.Trigger()
+			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			delIng, ok := obj.(*networking.Ingress)
@@ -92,11 +120,15 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 					return
 				}
 			}
-			if !class.IsValid(delIng) {
+			if !n.isValidIngress(delIng) {
 				glog.Infof("ignoring delete for ingress %v based on annotation %v", delIng.Name, class.IngressKey)
 				return
 			}
 			n.recorder.Eventf(delIng, apiv1.EventTypeNormal, "DELETE", fmt.Sprintf("Ingress %s/%s", delIng.Namespace, delIng.Name))
+			if n.freeze != nil {
+				n.freeze.remove(delIng)
+			}
+			plugin.Forget(delIng)
 			if err := n.listers.IngressAnnotation.Delete(delIng); err != nil {
 				glog.Errorf("failed to delete ingress annotation: %#v", err)
 				return
@@ -106,13 +138,19 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 		UpdateFunc: func(old, cur interface{}) {
 			oldIng := old.(*networking.Ingress)
 			curIng := cur.(*networking.Ingress)
-			validOld := class.IsValid(oldIng)
-			validCur := class.IsValid(curIng)
+			if onlyHealthChanged(oldIng, curIng) {
+				return
+			}
+			validOld := n.isValidIngress(oldIng)
+			validCur := n.isValidIngress(curIng)
 
 			c := curIng.GetAnnotations()[class.IngressKey]
 			if !validOld && validCur {
 				glog.Infof("creating ingress %v/%v based on annotation %v with value '%v'", curIng.Namespace, curIng.Name, class.IngressKey, c)
 				n.recorder.Eventf(curIng, apiv1.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", curIng.Namespace, curIng.Name))
+				if n.syncStatus != nil {
+					n.syncStatus.Trigger()
+				}
 			} else if validOld && !validCur {
 				glog.Infof("removing ingress %v/%v based on annotation %v with value '%v'", curIng.Namespace, curIng.Name, class.IngressKey, c)
 				n.recorder.Eventf(curIng, apiv1.EventTypeNormal, "DELETE", fmt.Sprintf("Ingress %s/%s", curIng.Namespace, curIng.Name))
@@ -120,12 +158,23 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 				n.recorder.Eventf(curIng, apiv1.EventTypeNormal, "UPDATE", fmt.Sprintf("Ingress %s/%s", curIng.Namespace, curIng.Name))
 			}
 
-			n.extractAnnotations(curIng)
+			if ing := n.admitIngress(curIng); ing != nil {
+				n.extractAnnotations(ing)
+			}
 			n.syncQueue.Enqueue(cur)
 		},
 	}
 
 	secrEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sec := obj.(*apiv1.Secret)
+			if key := fmt.Sprintf("%v/%v", sec.Namespace, sec.Name); n.usesSignedURLSecret(key) || n.usesReplayProtectionSecret(key) {
+				n.syncQueue.Enqueue(sec)
+			}
+			if n.isSharedCertificate(sec) {
+				n.syncSecret(fmt.Sprintf("%v/%v", sec.Namespace, sec.Name))
+			}
+		},
 		UpdateFunc: func(old, cur interface{}) {
 			if !reflect.DeepEqual(old, cur) {
 				sec := cur.(*apiv1.Secret)
@@ -134,6 +183,19 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 				if exists {
 					n.syncSecret(key)
 				}
+				if n.usesSignedURLSecret(key) || n.usesReplayProtectionSecret(key) {
+					n.syncQueue.Enqueue(sec)
+				}
+				// the alias or the policy of a shared certificate changed
+				oldSec := old.(*apiv1.Secret)
+				if n.isSharedCertificate(sec) || n.isSharedCertificate(oldSec) {
+					if !exists && n.isSharedCertificate(sec) {
+						n.syncSecret(key)
+					}
+					if !reflect.DeepEqual(oldSec.Annotations, sec.Annotations) {
+						n.syncQueue.Enqueue(sec)
+					}
+				}
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -217,7 +279,27 @@ func (n *NGINXController) createListers(stopCh chan struct{}) (*ingress.StoreLis
 
 	lister.Endpoint.Store, controller.Endpoint = cache.NewInformer(
 		cache.NewListWatchFromClient(n.cfg.Client.CoreV1().RESTClient(), "endpoints", n.cfg.Namespace, fields.Everything()),
-		&apiv1.Endpoints{}, n.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{})
+		&apiv1.Endpoints{}, n.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{
+			AddFunc: n.endpointsChanged,
+			UpdateFunc: func(old, cur interface{}) {
+				n.endpointsChanged(cur)
+			},
+			DeleteFunc: n.endpointsChanged,
+		})
+
+	// the terminating endpoints are only in the EndpointSlices
+	lister.EndpointSlice.Store = cache_client.NewStore(cache_client.MetaNamespaceKeyFunc)
+	if n.cfg.DrainPeriod > 0 {
+		lister.EndpointSlice.Store, controller.EndpointSlice = cache.NewInformer(
+			cache.NewListWatchFromClient(n.cfg.Client.DiscoveryV1().RESTClient(), "endpointslices", n.cfg.Namespace, fields.Everything()),
+			&discovery.EndpointSlice{}, n.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{
+				AddFunc: n.endpointsChanged,
+				UpdateFunc: func(old, cur interface{}) {
+					n.endpointsChanged(cur)
+				},
+				DeleteFunc: n.endpointsChanged,
+			})
+	}
 
 	lister.Secret.Store, controller.Secret = cache.NewInformer(
 		cache.NewListWatchFromClient(n.cfg.Client.CoreV1().RESTClient(), "secrets", watchNs, fields.Everything()),
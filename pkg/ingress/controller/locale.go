@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+)
+
+// createVariantUpstreams creates the upstreams of the locale variants of
+// the Ingress. The variants use the upstream settings of the Ingress.
+func (n *NGINXController) createVariantUpstreams(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) {
+	for _, variant := range anns.Locale.Variants {
+		name := fmt.Sprintf("%v-%v-%v", ing.GetNamespace(), variant.Service, variant.Port)
+		if _, ok := upstreams[name]; ok {
+			continue
+		}
+
+		glog.V(3).Infof("creating upstream %v of the locale %v", name, variant.Value)
+		ups := newUpstream(name)
+		ups.Port = intstr.FromInt(variant.Port)
+		ups.Secure = anns.SecureUpstream.Secure
+		ups.SecureCACert = anns.SecureUpstream.CACert
+		ups.ClientCACert = anns.SecureUpstream.ClientCACert
+		ups.IPFamily = anns.IPFamily.Upstream
+
+		svcKey := fmt.Sprintf("%v/%v", ing.GetNamespace(), variant.Service)
+		s, err := n.listers.Service.GetByName(svcKey)
+		if err != nil {
+			glog.Warningf("error obtaining service of the locale %v: %v", variant.Value, err)
+			continue
+		}
+		ups.Service = s
+		ups.ClusterIP = serviceClusterIP(s, ups.IPFamily)
+		upstreams[name] = ups
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build !nolua
+
+package controller
+
+import (
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/filters"
+)
+
+// loadLuaFilters installs the filters of the bundle with a valid signature.
+// Filters that fail verification are skipped.
+func (n *NGINXController) loadLuaFilters() {
+	b, errs := filters.Load(n.cfg.LuaFilterBundle, n.cfg.LuaFilterPublicKey)
+	for _, err := range errs {
+		glog.Warningf("skipping Lua filter: %v", err)
+	}
+
+	if err := b.Install(n.luaFiltersDir()); err != nil {
+		glog.Errorf("unexpected error installing Lua filters: %v", err)
+		return
+	}
+
+	glog.Infof("installed Lua filters: %v", b.Names())
+	n.luaFilters = sets.NewString(b.Names()...)
+}
+
+// luaFiltersDir returns the directory where the Lua filters are installed
+func (n *NGINXController) luaFiltersDir() string {
+	return filepath.Join(filepath.Dir(cfgPath), "filters")
+}
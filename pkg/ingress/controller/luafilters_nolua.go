@@ -0,0 +1,16 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build nolua
+
+package controller
+
+// loadLuaFilters is never called, the Lua subsystem is not compiled in the
+// controller
+func (n *NGINXController) loadLuaFilters() {}
+
+// luaFiltersDir returns an empty directory, so the template does not load
+// the Lua filters, removed from the image
+func (n *NGINXController) luaFiltersDir() string {
+	return ""
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+)
+
+// DefaultDeferrableChanges are the changes applied in the maintenance
+// windows by default: changes in the settings of existing locations, like
+// most annotations. New or removed routes, backends and certificates are
+// applied immediately.
+var DefaultDeferrableChanges = []string{"location/changed"}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// MaintenanceWindow is a daily or weekly period, in UTC, in which the
+// deferred reloads are applied
+type MaintenanceWindow struct {
+	// Days are the week days the window starts, every day if empty
+	Days []time.Weekday
+	// Start and End are minutes since midnight. Windows with an end
+	// before the start finish the next day.
+	Start int
+	End   int
+}
+
+// ParseMaintenanceWindows parses windows in the form [days ]HH:MM-HH:MM,
+// where days is a comma separated list like sat,sun
+func ParseMaintenanceWindows(values []string) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	for _, v := range values {
+		fields := strings.Fields(v)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid maintenance window %q, expected [days ]HH:MM-HH:MM", v)
+		}
+
+		w := MaintenanceWindow{}
+		if len(fields) == 2 {
+			for _, d := range strings.Split(fields[0], ",") {
+				day, ok := weekdays[strings.ToLower(d)]
+				if !ok {
+					return nil, fmt.Errorf("invalid day %q in maintenance window %q", d, v)
+				}
+				w.Days = append(w.Days, day)
+			}
+		}
+
+		period := strings.Split(fields[len(fields)-1], "-")
+		if len(period) != 2 {
+			return nil, fmt.Errorf("invalid maintenance window %q, expected [days ]HH:MM-HH:MM", v)
+		}
+		var err error
+		if w.Start, err = parseMinutes(period[0]); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %v", v, err)
+		}
+		if w.End, err = parseMinutes(period[1]); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %v", v, err)
+		}
+		if w.Start == w.End {
+			return nil, fmt.Errorf("invalid maintenance window %q: empty period", v)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseMinutes(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseDeferrableChanges validates changes in the form kind/action, where
+// kind and action are the ones reported by the model diff API
+func ParseDeferrableChanges(values []string) (map[string]bool, error) {
+	valid := map[string]bool{}
+	for _, kind := range []string{modeldiff.KindBackend, modeldiff.KindServer, modeldiff.KindLocation, modeldiff.KindCertificate} {
+		for _, action := range []string{modeldiff.ActionAdded, modeldiff.ActionRemoved, modeldiff.ActionChanged} {
+			valid[kind+"/"+action] = true
+		}
+	}
+
+	deferrable := map[string]bool{}
+	for _, v := range values {
+		if !valid[v] {
+			return nil, fmt.Errorf("invalid change %q, expected <kind>/<action> like location/changed", v)
+		}
+		deferrable[v] = true
+	}
+	return deferrable, nil
+}
+
+func (w MaintenanceWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if t is inside the window
+func (w MaintenanceWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.startsOn(t.Weekday()) && m >= w.Start && m < w.End
+	}
+	// the window started the day before
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	return (w.startsOn(t.Weekday()) && m >= w.Start) || (w.startsOn(yesterday) && m < w.End)
+}
+
+// nextStart returns the first start of the window after t
+func (w MaintenanceWindow) nextStart(t time.Time) time.Time {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	for d := 0; d <= 7; d++ {
+		day := midnight.AddDate(0, 0, d)
+		start := day.Add(time.Duration(w.Start) * time.Minute)
+		if start.After(t) && w.startsOn(day.Weekday()) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// DeferredReload describes the changes waiting for a maintenance window
+type DeferredReload struct {
+	Changes []modeldiff.Change `json:"changes"`
+	// Since is the time the first change was deferred
+	Since time.Time `json:"since"`
+	// NextWindow is the start of the next maintenance window
+	NextWindow time.Time `json:"nextWindow"`
+}
+
+// reloadScheduler defers the reloads with only non urgent changes to the
+// next maintenance window
+type reloadScheduler struct {
+	windows    []MaintenanceWindow
+	deferrable map[string]bool
+
+	mu sync.Mutex
+	// deferred is nil when no reload is waiting
+	deferred *DeferredReload
+	// override applies the next reload immediately
+	override bool
+	// timer triggers a sync when the next window starts
+	timer *time.Timer
+}
+
+func newReloadScheduler(windows []MaintenanceWindow, deferrable map[string]bool) *reloadScheduler {
+	return &reloadScheduler{
+		windows:    windows,
+		deferrable: deferrable,
+	}
+}
+
+// inWindow returns true if t is inside a maintenance window
+func (s *reloadScheduler) inWindow(t time.Time) bool {
+	for _, w := range s.windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindow returns the start of the next maintenance window after t
+func (s *reloadScheduler) nextWindow(t time.Time) time.Time {
+	var next time.Time
+	for _, w := range s.windows {
+		if start := w.nextStart(t); next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// urgent returns true if a change must be applied immediately
+func (s *reloadScheduler) urgent(changes []modeldiff.Change) bool {
+	for _, c := range changes {
+		if !s.deferrable[c.Kind+"/"+c.Action] {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldDefer returns true if the reload with the changes must wait for
+// the next maintenance window, and the time until it starts
+func (s *reloadScheduler) shouldDefer(changes []modeldiff.Change, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.override || len(changes) == 0 || s.urgent(changes) || s.inWindow(now) {
+		return false, 0
+	}
+
+	next := s.nextWindow(now)
+	if s.deferred == nil {
+		s.deferred = &DeferredReload{Since: now}
+	}
+	s.deferred.Changes = changes
+	s.deferred.NextWindow = next
+	return true, next.Sub(now)
+}
+
+// applied clears the deferred reload and the override after a reload
+func (s *reloadScheduler) applied() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deferred = nil
+	s.override = false
+}
+
+// applyNow makes the next sync reload even outside the maintenance
+// windows. It returns false if there is no deferred reload.
+func (s *reloadScheduler) applyNow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deferred == nil {
+		return false
+	}
+	s.override = true
+	return true
+}
+
+// status returns a copy of the deferred reload, or nil
+func (s *reloadScheduler) status() *DeferredReload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deferred == nil {
+		return nil
+	}
+	d := *s.deferred
+	return &d
+}
+
+// schedule runs fn after d, replacing the previously scheduled call
+func (s *reloadScheduler) schedule(after time.Duration, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(after, fn)
+}
+
+// DeferredReload returns the changes waiting for the next maintenance
+// window, or nil if there are none or the windows are disabled
+func (n *NGINXController) DeferredReload() *DeferredReload {
+	if n.reloads == nil {
+		return nil
+	}
+	return n.reloads.status()
+}
+
+// ApplyDeferredReload applies the deferred changes without waiting for the
+// maintenance window. It returns false if there are no deferred changes.
+func (n *NGINXController) ApplyDeferredReload() bool {
+	if n.reloads == nil || !n.reloads.applyNow() {
+		return false
+	}
+	glog.Infof("applying the deferred backend reload before the maintenance window")
+	n.syncQueue.Enqueue(&networking.Ingress{})
+	return true
+}
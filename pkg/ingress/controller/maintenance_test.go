@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows([]string{"Sat,sun 01:00-05:30", "22:00-06:00"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(windows) != 2 {
+		t.Fatalf("expected 2 windows but returned %v", windows)
+	}
+	if w := windows[0]; len(w.Days) != 2 || w.Days[0] != time.Saturday || w.Days[1] != time.Sunday || w.Start != 60 || w.End != 330 {
+		t.Errorf("unexpected weekly window %+v", w)
+	}
+	if w := windows[1]; len(w.Days) != 0 || w.Start != 1320 || w.End != 360 {
+		t.Errorf("unexpected daily window %+v", w)
+	}
+
+	for _, v := range []string{"", "01:00", "sat 01:00-25:00", "someday 01:00-02:00", "01:00-01:00", "sat sun 01:00-02:00"} {
+		if _, err := ParseMaintenanceWindows([]string{v}); err == nil {
+			t.Errorf("expected an error parsing %q", v)
+		}
+	}
+}
+
+func TestParseDeferrableChanges(t *testing.T) {
+	if _, err := ParseDeferrableChanges([]string{"location/changed", "certificate/changed"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseDeferrableChanges([]string{"location/renamed"}); err == nil {
+		t.Errorf("expected an error parsing an unknown action")
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	windows, _ := ParseMaintenanceWindows([]string{"fri 22:00-02:00"})
+	w := windows[0]
+
+	// 2021-06-04 is a Friday
+	testCases := []struct {
+		time     string
+		contains bool
+		next     string
+	}{
+		{"2021-06-04T21:59:00Z", false, "2021-06-04T22:00:00Z"},
+		{"2021-06-04T22:00:00Z", true, "2021-06-11T22:00:00Z"},
+		{"2021-06-05T01:59:00Z", true, "2021-06-11T22:00:00Z"},
+		{"2021-06-05T02:00:00Z", false, "2021-06-11T22:00:00Z"},
+		{"2021-06-06T01:00:00Z", false, "2021-06-11T22:00:00Z"},
+	}
+
+	for _, tc := range testCases {
+		now, _ := time.Parse(time.RFC3339, tc.time)
+		if res := w.contains(now); res != tc.contains {
+			t.Errorf("expected %v to be in the window %v but returned %v", tc.time, tc.contains, res)
+		}
+		if res := w.nextStart(now).Format(time.RFC3339); res != tc.next {
+			t.Errorf("expected the window after %v to start at %v but returned %v", tc.time, tc.next, res)
+		}
+	}
+}
+
+func TestReloadScheduler(t *testing.T) {
+	windows, _ := ParseMaintenanceWindows([]string{"02:00-04:00"})
+	deferrable, _ := ParseDeferrableChanges(DefaultDeferrableChanges)
+	s := newReloadScheduler(windows, deferrable)
+
+	now, _ := time.Parse(time.RFC3339, "2021-06-04T12:00:00Z")
+	cosmetic := []modeldiff.Change{{Kind: modeldiff.KindLocation, Action: modeldiff.ActionChanged, Name: "example.com/"}}
+	rotation := append(cosmetic, modeldiff.Change{Kind: modeldiff.KindCertificate, Action: modeldiff.ActionChanged, Name: "example.com"})
+
+	deferred, next := s.shouldDefer(cosmetic, now)
+	if !deferred || next != 14*time.Hour {
+		t.Errorf("expected the reload to be deferred 14h but returned %v %v", deferred, next)
+	}
+	if d := s.status(); d == nil || len(d.Changes) != 1 || !d.Since.Equal(now) {
+		t.Errorf("unexpected deferred reload %+v", d)
+	}
+
+	if deferred, _ := s.shouldDefer(rotation, now); deferred {
+		t.Errorf("expected an urgent change to be applied immediately")
+	}
+	if deferred, _ := s.shouldDefer(cosmetic, now.Add(15*time.Hour)); deferred {
+		t.Errorf("expected the reload to be applied in the maintenance window")
+	}
+
+	if !s.applyNow() {
+		t.Errorf("expected the deferred reload to be applied on demand")
+	}
+	if deferred, _ := s.shouldDefer(cosmetic, now); deferred {
+		t.Errorf("expected the override to apply the reload")
+	}
+
+	s.applied()
+	if s.status() != nil || s.applyNow() {
+		t.Errorf("expected no deferred reload after the reload")
+	}
+}
@@ -13,6 +13,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -20,18 +21,21 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/flowcontrol"
 
@@ -39,21 +43,48 @@ import (
 	"github.com/stolostron/management-ingress/pkg/ingress"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/capabilities"
 	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
 	"github.com/stolostron/management-ingress/pkg/ingress/controller/process"
 	ngx_template "github.com/stolostron/management-ingress/pkg/ingress/controller/template"
+	"github.com/stolostron/management-ingress/pkg/ingress/externaldns"
+	"github.com/stolostron/management-ingress/pkg/ingress/history"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/ingress/modelcache"
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+	"github.com/stolostron/management-ingress/pkg/ingress/notifier"
+	"github.com/stolostron/management-ingress/pkg/ingress/revocation"
+	"github.com/stolostron/management-ingress/pkg/ingress/snapshot"
 	"github.com/stolostron/management-ingress/pkg/ingress/status"
 	"github.com/stolostron/management-ingress/pkg/ingress/store"
+	"github.com/stolostron/management-ingress/pkg/ingress/targetgroup"
+	"github.com/stolostron/management-ingress/pkg/ingress/telemetry"
+	"github.com/stolostron/management-ingress/pkg/k8s"
 	ing_net "github.com/stolostron/management-ingress/pkg/net"
 	"github.com/stolostron/management-ingress/pkg/net/dns"
 	"github.com/stolostron/management-ingress/pkg/task"
+	"github.com/stolostron/management-ingress/pkg/version"
 	"github.com/stolostron/management-ingress/pkg/watch"
+	"github.com/stolostron/management-ingress/pkg/watchdog"
+)
+
+const (
+	// masterQuitTimeout is the maximum time to wait for NGINX to finish the
+	// requests in progress during shutdown
+	masterQuitTimeout = 5 * time.Minute
 )
 
 var (
 	tmplPath    = "/opt/ibm/router/nginx/template/nginx.tmpl"
 	cfgPath     = "/opt/ibm/router/nginx/conf/nginx.conf"
 	nginxBinary = "/opt/ibm/router/nginx/sbin/nginx"
+	// nginxPrefix is the prefix of the NGINX build, with the Lua files in
+	// its conf directory
+	nginxPrefix = "/opt/ibm/router/nginx"
+
+	// nextTmplPath is the candidate template, shadow rendered until the
+	// NextTemplate feature gate activates it
+	nextTmplPath = "/opt/ibm/router/nginx/template/nginx-next.tmpl"
 )
 
 // NewNGINXController creates a new NGINX Ingress controller.
@@ -100,29 +131,105 @@ func NewNGINXController(config *Configuration, fs file.Filesystem) *NGINXControl
 
 		// create an empty configuration.
 		runningConfig: &ingress.Configuration{},
+
+		modelEvents: modeldiff.NewBroadcaster(),
+
+		readiness:         newStateFile(config.TempDir, backendReadinessFile),
+		balancedEndpoints: newStateFile(config.TempDir, backendEndpointsFile),
+		canaryWeights:     newStateFile(config.TempDir, canaryWeightsFile),
+		breakGlass:        newStateFile(config.TempDir, breakGlassFile),
+	}
+
+	n.master = process.NewMaster(n.masterCommand)
+
+	if config.ConfigDir != "" {
+		cfgPath = filepath.Join(config.ConfigDir, "nginx.conf")
+	}
+
+	if config.ModelCacheDir != "" {
+		n.modelCache = modelcache.New(config.ModelCacheDir, ingress.DefaultSSLDirectory)
+	}
+
+	if config.DrainPeriod > 0 {
+		n.drain = newDrainTracker(config.DrainPeriod)
+		n.endpointDrain = newEndpointDrainTracker(config.DrainPeriod)
+	}
+
+	if config.SPIFFESocket != "" {
+		n.identity = newWorkloadIdentity(ingress.DefaultSSLDirectory)
+	}
+
+	if config.ReportHealth {
+		n.health = newHealthTracker()
+	}
+
+	if len(config.MaintenanceWindows) > 0 {
+		n.reloads = newReloadScheduler(config.MaintenanceWindows, config.DeferrableChanges)
+	}
+
+	n.capabilities = capabilities.Detect(ngx)
+	glog.Infof("capabilities: %v", n.capabilities)
+
+	if config.LuaFilterBundle != "" {
+		if n.capabilities.Enabled(capabilities.Lua) {
+			n.loadLuaFilters()
+		} else {
+			glog.Warningf("ignoring the Lua filters of %v: %v", config.LuaFilterBundle, n.capabilities.Get(capabilities.Lua))
+		}
 	}
 
 	n.listers, n.controllers = n.createListers(n.stopCh)
+	if config.FeatureGates[OIDCRoutePolicies] {
+		n.oidcPolicies, n.controllers.OIDCPolicy = n.createOIDCPolicyInformer()
+	}
+	if config.ChangeFreezeSelector != nil {
+		var approvals cache.Store
+		approvals, n.controllers.ChangeFreeze = n.createChangeFreezeInformer(config.ChangeFreezeConfigMap)
+		n.freeze = newChangeFreeze(config.ChangeFreezeSelector, config.ChangeFreezeConfigMap, approvals)
+	}
+	n.clientCerts = revocation.New(n.revocationSecret)
 
-	n.syncQueue = task.NewTaskQueue(n.syncIngress)
+	n.syncQueue = task.NewBoundedTaskQueue("sync", config.SyncQueueSize, n.syncIngress, nil)
+
+	for _, socket := range config.AnnotationPlugins {
+		if err := registerRemotePlugin(socket, config.AnnotationPluginTimeout); err != nil {
+			glog.Fatalf("unexpected error registering annotation plugin %v: %v", socket, err)
+		}
+	}
 
 	n.annotations = annotations.NewAnnotationExtractor(n)
 
-	if config.UpdateStatus {
-		n.syncStatus = status.NewStatusSyncer(status.Config{
-			Client:              config.Client,
-			IngressLister:       n.listers.Ingress,
-			ElectionID:          config.ElectionID,
-			IngressClass:        class.IngressClass,
-			DefaultIngressClass: class.DefaultClass,
-		})
+	if config.TelemetryEndpoint != "" {
+		n.telemetry = n.newTelemetryReporter()
+	}
+
+	if config.StartupGate {
+		n.gate = &startupGate{}
+	}
+
+	if config.ConfigHistoryDir != "" {
+		n.history = history.New(config.ConfigHistoryDir, config.ConfigHistorySize)
+	}
+
+	if config.UpdateStatus && !k8s.IsRunningInPod() {
+		glog.Warning("Update of ingress status is disabled (not running in a pod, POD_NAME or POD_NAMESPACE is not set)")
+	} else if config.UpdateStatus {
+		n.leaderTasks = status.NewLeaderTasks()
+		n.syncStatus = status.NewStatusSyncer(n.statusConfig())
 	} else {
 		glog.Warning("Update of ingress status is disabled (flag --update-status=false was specified)")
 	}
 
+	activeTmplPath := tmplPath
+	if config.FeatureGates[NextTemplate] {
+		activeTmplPath = nextTmplPath
+	} else {
+		n.loadShadowTemplate(fs)
+	}
+
 	var onChange func()
 	onChange = func() {
-		template, err := ngx_template.NewTemplate(tmplPath, fs)
+		template, err := ngx_template.NewTemplate(activeTmplPath, fs)
 		if err != nil {
 			// this error is different from the rest because it must be clear why nginx is not working
 			glog.Errorf(`
@@ -138,7 +245,7 @@ Error loading new template : %v
 		n.SetForceReload(true)
 	}
 
-	ngxTpl, err := ngx_template.NewTemplate(tmplPath, fs)
+	ngxTpl, err := ngx_template.NewTemplate(activeTmplPath, fs)
 	if err != nil {
 		glog.Fatalf("invalid NGINX template: %v", err)
 	}
@@ -147,17 +254,55 @@ Error loading new template : %v
 
 	// TODO: refactor
 	if _, ok := fs.(*file.DefaultFs); !ok {
-		watch.NewDummyFileWatcher(tmplPath, onChange)
+		watch.NewDummyFileWatcher(activeTmplPath, onChange)
 	} else {
-		_, err = watch.NewFileWatcher(tmplPath, onChange)
+		_, err = watch.NewFileWatcher(activeTmplPath, onChange)
 		if err != nil {
-			glog.Fatalf("unexpected error watching template %v: %v", tmplPath, err)
+			glog.Fatalf("unexpected error watching template %v: %v", activeTmplPath, err)
 		}
 	}
 
 	return n
 }
 
+// statusConfig returns the configuration of the status syncer from the
+// flags of the controller
+func (n *NGINXController) statusConfig() status.Config {
+	// validated with the flags, empty if disabled
+	summaryNamespace, summaryName, _ := k8s.ParseNameNS(n.cfg.StatusSummaryConfigMap)
+	return status.Config{
+		LeaderTasks:            n.leaderTasks,
+		Client:                 n.cfg.Client,
+		IngressLister:          n.listers.Ingress,
+		ElectionID:             n.cfg.ElectionID,
+		LockType:               n.cfg.ElectionLockType,
+		ElectionNamespace:      n.cfg.ElectionNamespace,
+		ElectionLockName:       n.cfg.ElectionLockName,
+		UpdateInterval:         n.cfg.StatusUpdateInterval,
+		UpdateWorkers:          n.cfg.StatusUpdateWorkers,
+		UpdateStatusOnShutdown: n.cfg.UpdateStatusOnShutdown,
+		ShutdownGrace:          n.cfg.ShutdownGrace,
+		DryRun:                 n.cfg.StatusDryRun,
+		IngressClass:           class.IngressClass,
+		DefaultIngressClass:    class.DefaultClass,
+		ExternalDNS:            newExternalDNS(n.cfg),
+		TargetGroup:            newTargetGroup(n.cfg),
+		TargetGroupInterval:    n.cfg.TargetGroupInterval,
+		VIP:                    n.cfg.VIP,
+		PublishService:         n.cfg.PublishService,
+		PublishStatusAddresses: n.cfg.PublishStatusAddresses,
+		AddressType:            n.cfg.StatusAddressType,
+		IPFamily:               n.cfg.StatusIPFamily,
+		NodeAddressPreference:  n.cfg.StatusNodeAddressTypes,
+		Recorder:               n.recorder,
+		DeschedulerHint:        n.cfg.DeschedulerHint,
+		RepairQPS:              n.cfg.StatusRepairQPS,
+		RepairInterval:         n.cfg.StatusRepairInterval,
+		SummaryNamespace:       summaryNamespace,
+		SummaryName:            summaryName,
+	}
+}
+
 // NGINXController ...
 type NGINXController struct {
 	cfg *Configuration
@@ -165,6 +310,13 @@ type NGINXController struct {
 	listers     *ingress.StoreLister
 	controllers *cacheController
 
+	// oidcPolicies contains the OIDCRoutePolicies, nil without the
+	// OIDCRoutePolicies feature gate
+	oidcPolicies cache.Store
+	// oidcPolicyRejections contains the locations whose OIDCRoutePolicies
+	// reject the requests, as <host><path>, to record the events once
+	oidcPolicyRejections map[string]bool
+
 	annotations annotations.Extractor
 
 	recorder record.EventRecorder
@@ -172,6 +324,8 @@ type NGINXController struct {
 	syncQueue *task.Queue
 
 	syncStatus status.Sync
+	// leaderTasks run in the status leader, nil without --update-status
+	leaderTasks *status.LeaderTasks
 
 	// local store of SSL certificates
 	// (only certificates used in ingress)
@@ -186,15 +340,22 @@ type NGINXController struct {
 
 	stopCh chan struct{}
 
-	// ngxErrCh channel used to detect errors with the nginx processes
-	ngxErrCh chan error
+	// master supervises the NGINX master process
+	master *process.Master
 
 	// runningConfig contains the running configuration in the Backend
 	runningConfig *ingress.Configuration
+	// runningConfigLock protects runningConfig from readers outside the sync loop
+	runningConfigLock sync.RWMutex
 
 	forceReload int32
 
 	t *ngx_template.Template
+	// shadow is the candidate template rendered next to t to report the
+	// differences before its activation. Nil if there is no candidate
+	shadow *ngx_template.Template
+	// lastShadowDiff avoids logging the same differences after every sync
+	lastShadowDiff ngx_template.Comparison
 
 	configmap *apiv1.ConfigMap
 
@@ -204,15 +365,269 @@ type NGINXController struct {
 	// returns true if IPV6 is enabled in the pod
 	isIPV6Enabled bool
 
-	isShuttingDown bool
+	// isShuttingDown is 1 once Stop is called, read by the VIP election
+	isShuttingDown int32
 
 	fileSystem file.Filesystem
+
+	// modelCache persists the running configuration. Nil if disabled
+	modelCache *modelcache.Cache
+
+	// modelEvents publishes the changes of the running configuration
+	modelEvents *modeldiff.Broadcaster
+
+	// drain keeps the removed backends during the drain period. Nil if disabled
+	drain *drainTracker
+	// endpointDrain keeps the terminating endpoints of the backends
+	// balanced by NGINX during the drain period. Nil if disabled
+	endpointDrain *endpointDrainTracker
+
+	// reloads defers the non urgent reloads to the maintenance windows.
+	// Nil if disabled
+	reloads *reloadScheduler
+
+	// freeze holds the changes of the frozen Ingresses until approved.
+	// Nil if disabled
+	freeze *changeFreeze
+
+	// health tracks the Ingresses served by NGINX to report their health.
+	// Nil if disabled
+	health *healthTracker
+
+	// readiness writes the readiness of the backends with surge protection
+	readiness *stateFile
+	// balancedEndpoints writes the endpoints of the backends balanced by
+	// NGINX, with bounded load or slow start
+	balancedEndpoints *stateFile
+	// canaryWeights writes the weights and the header routes of the
+	// canaries, changed without a reload
+	canaryWeights *stateFile
+	// breakGlass writes whether the break-glass authentication is active
+	breakGlass *stateFile
+	// breakGlassActive is 1 while the break-glass authentication is active
+	breakGlassActive int32
+	// issuerDownSince is the first preflight run the OIDC issuer was
+	// unreachable in, zero while it is reachable
+	issuerDownSince time.Time
+
+	// identity keeps the X509-SVID of the controller and the verified
+	// SPIFFE IDs of the endpoints. Nil if the Workload API is disabled
+	identity *workloadIdentity
+
+	// clientCerts checks the revocation of the client certificates
+	clientCerts *revocation.Checker
+
+	// luaFilters contains the names of the filters of the signed bundle
+	luaFilters sets.String
+
+	// capabilities contains the optional subsystems compiled in the
+	// controller and present in NGINX
+	capabilities capabilities.Report
+
+	// telemetry reports the usage of the features. Nil if disabled
+	telemetry *telemetry.Reporter
+
+	// gate holds the start until the required references exist. Nil if
+	// disabled
+	gate *startupGate
+
+	// preflightFailed contains the targets of the last preflight run and
+	// whether they were unreachable
+	preflightFailed map[string]bool
+
+	// canaryPassed contains the canary routes of the last verified reload
+	// and whether they passed. Nil before the first verification
+	canaryPassed map[string]bool
+	// canaryRejected is the hash of the configuration rolled back by the
+	// canary routes, not reloaded again until the configuration changes
+	canaryRejected string
+
+	// history keeps the last rendered configurations. Nil if disabled
+	history *history.History
+	// pinnedRevision is the revision requested by a rollback, zero if the
+	// configuration follows the model
+	pinnedRevision int32
+	// appliedRevision is the revision restored by the last rollback, zero
+	// after a reload of the model. Only used by the sync queue
+	appliedRevision int
+}
+
+// setRunningConfig replaces the running configuration
+func (n *NGINXController) setRunningConfig(cfg *ingress.Configuration) {
+	n.runningConfigLock.Lock()
+	defer n.runningConfigLock.Unlock()
+	n.runningConfig = cfg
+	n.modelEvents.SetModel(cfg)
+}
+
+// writeConfig replaces the NGINX configuration file. The readers of the
+// running configuration, like the snapshots, never see a partial file.
+func (n *NGINXController) writeConfig(content []byte) error {
+	n.runningConfigLock.Lock()
+	defer n.runningConfigLock.Unlock()
+	return ioutil.WriteFile(cfgPath, content, 0600)
+}
+
+// Snapshot returns the running model and the NGINX configuration generated from it
+func (n *NGINXController) Snapshot() (*snapshot.Snapshot, error) {
+	n.runningConfigLock.RLock()
+	defer n.runningConfigLock.RUnlock()
+
+	content, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.New(n.runningConfig, content, version.RELEASE), nil
+}
+
+// Capabilities returns the optional subsystems compiled in the controller
+// and present in NGINX
+func (n *NGINXController) Capabilities() capabilities.Report {
+	return n.capabilities
+}
+
+// ModelEvents returns the broadcaster of the changes applied in every reload
+func (n *NGINXController) ModelEvents() *modeldiff.Broadcaster {
+	return n.modelEvents
+}
+
+// newLeakDetector returns a watchdog that reports suspected leaks as
+// events in the pod running the controller
+func (n *NGINXController) newLeakDetector() *watchdog.Watchdog {
+	pod := podReference()
+
+	return watchdog.New(watchdog.Config{
+		Interval:   n.cfg.LeakDetectorInterval,
+		Window:     n.cfg.LeakDetectorWindow,
+		Threshold:  n.cfg.LeakDetectorThreshold,
+		ProfileDir: n.cfg.LeakDetectorDir,
+		Notify: func(resource, message string) {
+			if pod.Name == "" || pod.Namespace == "" {
+				return
+			}
+			n.recorder.Event(pod, apiv1.EventTypeWarning, "LeakSuspected", message)
+		},
+	})
+}
+
+// newExternalDNS returns the publisher of the hints consumed by
+// external-dns, or nil when the publication is disabled
+func newExternalDNS(config *Configuration) *externaldns.Publisher {
+	if !config.ExternalDNS {
+		return nil
+	}
+
+	return externaldns.New(externaldns.Config{
+		Targets:   config.ExternalDNSTargets,
+		TTL:       config.ExternalDNSTTL,
+		Endpoints: config.FeatureGates[ExternalDNSEndpoints],
+		Dynamic:   config.DynamicClient,
+	}, config.Client)
+}
+
+// newTargetGroup returns the registrar of the replicas in the AWS target
+// group, or nil when the registration is disabled
+func newTargetGroup(config *Configuration) *targetgroup.Registrar {
+	if config.TargetGroupARN == "" {
+		return nil
+	}
+
+	region, err := targetgroup.ParseARN(config.TargetGroupARN)
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+	client, err := targetgroup.NewClient(region)
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+
+	r, err := targetgroup.New(targetgroup.Config{
+		ARN:        config.TargetGroupARN,
+		TargetType: config.TargetGroupType,
+		Port:       config.TargetGroupPort,
+		Owner:      fmt.Sprintf("%v/%v", os.Getenv("POD_NAMESPACE"), config.ElectionID),
+		API:        client,
+	}, config.Client)
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+	return r
+}
+
+// podReference returns the reference to the pod running the controller,
+// used to emit events not related to an Ingress
+func podReference() *apiv1.ObjectReference {
+	return &apiv1.ObjectReference{
+		Kind:      "Pod",
+		Name:      os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+	}
+}
+
+// masterCommand returns the command used to start the NGINX master process
+func (n *NGINXController) masterCommand() *exec.Cmd {
+	// #nosec
+	cmd := exec.Command(n.binary, "-c", cfgPath)
+	cmd.SysProcAttr = process.SysProcAttr(n.cfg.ListenPorts.HTTP, n.cfg.ListenPorts.HTTPS)
+	return cmd
+}
+
+// restoreModelCache writes the cached configuration and uses the cached
+// model as the running configuration. It returns false if the cache is
+// disabled, missing or the configuration is not valid.
+func (n *NGINXController) restoreModelCache() bool {
+	if n.modelCache == nil {
+		return false
+	}
+
+	model, content, err := n.modelCache.Load()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("unexpected error reading the model cache: %v", err)
+		}
+		return false
+	}
+
+	// the SSL directory is empty when the pod is recreated
+	if err := n.modelCache.RestoreSSLFiles(); err != nil {
+		glog.Warningf("unexpected error restoring the cached certificates: %v", err)
+		return false
+	}
+
+	// referenced files like the Secrets of other volumes may not exist
+	// anymore
+	if err := n.validate(content); err != nil {
+		glog.Warningf("ignoring invalid cached configuration: %v", err)
+		return false
+	}
+
+	if err := n.writeConfig(content); err != nil {
+		glog.Warningf("unexpected error writing the cached configuration: %v", err)
+		return false
+	}
+
+	n.setRunningConfig(model)
+	return true
 }
 
 // Start start a new NGINX master process running in foreground.
 func (n *NGINXController) Start() {
 	glog.Infof("starting Ingress controller")
 
+	// the Services with surge protection are not protected until their
+	// readiness is known, without warnings of a missing file
+	if err := n.readiness.create(); err != nil {
+		glog.Warningf("unexpected error creating the readiness of the backends: %v", err)
+	}
+
+	// serve the previous configuration while the informers are synced
+	restored := n.restoreModelCache()
+	if restored {
+		glog.Info("starting NGINX process with the cached configuration...")
+		n.start()
+	}
+
 	n.controllers.Run(n.stopCh)
 
 	// initial sync of secrets to avoid unnecessary reloads
@@ -220,13 +635,14 @@ func (n *NGINXController) Start() {
 	for _, obj := range n.listers.Ingress.List() {
 		ing := obj.(*networking.Ingress)
 
-		if !class.IsValid(ing) {
+		served := n.servedIngress(ing)
+		if served == nil {
 			a := ing.GetAnnotations()[class.IngressKey]
 			glog.Infof("ignoring add for ingress %v based on annotation %v with value %v", ing.Name, class.IngressKey, a)
 			continue
 		}
 
-		n.readSecrets(ing)
+		n.readSecrets(served)
 	}
 
 	if n.syncStatus != nil {
@@ -235,29 +651,87 @@ func (n *NGINXController) Start() {
 
 	go wait.Until(n.checkMissingSecrets, 30*time.Second, n.stopCh)
 
-	done := make(chan error, 1)
-	// #nosec
-	cmd := exec.Command(n.binary, "-c", cfgPath)
+	if n.health != nil {
+		go n.runHealthReports()
+	}
 
-	// put nginx in another process group to prevent it
-	// to receive signals meant for the controller
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-		Pgid:    0,
+	if n.cfg.LeakDetectorInterval > 0 {
+		go n.newLeakDetector().Run(n.stopCh)
 	}
 
-	glog.Info("starting NGINX process...")
-	n.start(cmd)
+	if n.cfg.PreflightInterval > 0 {
+		go wait.Until(n.runPreflight, n.cfg.PreflightInterval, n.stopCh)
+	}
+
+	if n.identity != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-n.stopCh
+			cancel()
+		}()
+		go n.watchWorkloadIdentity(ctx)
+		go n.runUpstreamIdentity(ctx)
+	}
+
+	if n.cfg.VIP != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-n.stopCh
+			cancel()
+		}()
+		go n.runVIP(ctx)
+	}
+
+	if n.cfg.CapabilitiesConfigMap != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-n.stopCh
+			cancel()
+		}()
+		go n.newCapabilityPublisher().Run(ctx)
+	}
+
+	if n.telemetry != nil && n.leaderTasks != nil {
+		// a single report per cluster
+		n.leaderTasks.Register("telemetry", n.telemetry.Run)
+	} else if n.telemetry != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-n.stopCh
+			cancel()
+		}()
+		go n.telemetry.Run(ctx)
+	}
+
+	if len(n.cfg.WebhookURLs) > 0 {
+		events, _ := n.modelEvents.Subscribe()
+		go notifier.New(notifier.Config{
+			URLs:    n.cfg.WebhookURLs,
+			Secret:  n.cfg.WebhookSecret,
+			Retries: n.cfg.WebhookRetries,
+		}, os.Getenv("POD_NAME")).Run(events)
+	}
+
+	// a cached configuration is served while the references are missing
+	if n.gate != nil && !n.waitForStartupGate() {
+		return
+	}
+
+	if !restored {
+		glog.Info("starting NGINX process...")
+		n.start()
+	}
 
 	go n.syncQueue.Run(time.Second, n.stopCh)
 	// force initial sync
 	n.syncQueue.Enqueue(&networking.Ingress{})
 
+	stopCh := n.stopCh
 	for {
 		select {
-		case err := <-done:
-			if n.isShuttingDown {
-				break
+		case err := <-n.master.Exit:
+			if n.shuttingDown() {
+				continue
 			}
 
 			// if the nginx master process dies the workers continue to process requests,
@@ -267,24 +741,26 @@ func (n *NGINXController) Start() {
 			// To avoid this issue we restart nginx in case of errors.
 			if process.IsRespawnIfRequired(err) {
 				process.WaitUntilPortIsAvailable(n.cfg.ListenPorts.HTTP)
-				// release command resources
-				if err := cmd.Process.Release(); err != nil {
-					glog.Warningf("unexpected error release command resources: %v", err)
-				}
-				// #nosec
-				cmd = exec.Command(n.binary, "-c", cfgPath)
 				// start a new nginx master process if the controller is not being stopped
-				n.start(cmd)
+				if err := n.master.Restart(); err != nil {
+					glog.Fatalf("nginx error: %v", err)
+				}
 			}
-		case <-n.stopCh:
-			break
+		case <-stopCh:
+			// Stop is waiting for the master process to exit
+			stopCh = nil
 		}
 	}
 }
 
+// shuttingDown returns true once Stop is called
+func (n *NGINXController) shuttingDown() bool {
+	return atomic.LoadInt32(&n.isShuttingDown) == 1
+}
+
 // Stop gracefully stops the NGINX master process.
 func (n *NGINXController) Stop() error {
-	n.isShuttingDown = true
+	atomic.StoreInt32(&n.isShuttingDown, 1)
 
 	n.stopLock.Lock()
 	defer n.stopLock.Unlock()
@@ -297,46 +773,27 @@ func (n *NGINXController) Stop() error {
 	glog.Infof("shutting down controller queues")
 	close(n.stopCh)
 	go n.syncQueue.Shutdown()
+	if n.health != nil {
+		n.health.queue.ShutDown()
+	}
 	if n.syncStatus != nil {
 		n.syncStatus.Shutdown()
 	}
 
 	// Send stop signal to Nginx
 	glog.Info("stopping NGINX process...")
-	// #nosec
-	cmd := exec.Command(n.binary, "-c", cfgPath, "-s", "quit")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
+	if err := n.master.Quit(masterQuitTimeout); err != nil {
 		return err
 	}
 
-	// Wait for the Nginx process disappear
-	timer := time.NewTicker(time.Second * 1)
-	for range timer.C {
-		if !process.IsNginxRunning() {
-			glog.Info("NGINX process has stopped")
-			timer.Stop()
-			break
-		}
-	}
-
+	glog.Info("NGINX process has stopped")
 	return nil
 }
 
-func (n *NGINXController) start(cmd *exec.Cmd) {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
+func (n *NGINXController) start() {
+	if err := n.master.Start(); err != nil {
 		glog.Fatalf("nginx error: %v", err)
-		n.ngxErrCh <- err
-		return
 	}
-
-	go func() {
-		n.ngxErrCh <- cmd.Wait()
-	}()
 }
 
 // SetConfig sets the configured configmap
@@ -349,6 +806,9 @@ func (n *NGINXController) SetConfig(cmap *apiv1.ConfigMap) {
 	}
 
 	c := ngx_template.ReadConfig(m)
+	if disabled := n.capabilities.Apply(&c); len(disabled) > 0 {
+		glog.Warningf("ignoring %v of the configmap: their NGINX modules are not available", disabled)
+	}
 	if c.SSLSessionTicketKey != "" {
 		d, err := base64.StdEncoding.DecodeString(c.SSLSessionTicketKey)
 		if err != nil {
@@ -356,8 +816,10 @@ func (n *NGINXController) SetConfig(cmap *apiv1.ConfigMap) {
 			c.SSLSessionTicketKey = ""
 		}
 
-		if err := ioutil.WriteFile("/etc/nginx/tickets.key", d, 0600); err != nil {
-			glog.Warningf("unexpected error writing /etc/nginx/tickets.key: %v", err)
+		// the SSL directory is writable with a read-only root filesystem
+		key := filepath.Join(ingress.DefaultSSLDirectory, "tickets.key")
+		if err := ioutil.WriteFile(key, d, 0600); err != nil {
+			glog.Warningf("unexpected error writing %v: %v", key, err)
 		}
 	}
 }
@@ -373,6 +835,7 @@ func (n *NGINXController) SetConfig(cmap *apiv1.ConfigMap) {
 func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 	cfg := ngx_template.ReadConfig(n.configmap.Data)
 	cfg.Resolver = n.resolver
+	n.capabilities.Apply(&cfg)
 
 	// the limit of open files is per worker process
 	// and we leave some room to avoid consuming all the FDs available
@@ -396,23 +859,29 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		Cfg:           cfg,
 		IsIPV6Enabled: n.isIPV6Enabled && !cfg.DisableIpv6,
 		ListenPorts:   n.cfg.ListenPorts,
+		TempDir:       n.cfg.TempDir,
+
+		LuaFiltersDir:            n.luaFiltersDir(),
+		LuaFilterMaxInstructions: n.cfg.LuaFilterMaxInstructions,
 	}
 
+	start := time.Now()
 	content, err := n.t.Write(tc)
+	metric.ObserveRenderDuration(time.Since(start))
 
 	if err != nil {
 		return err
 	}
 
-	err = n.testTemplate(content)
+	err = n.validate(content)
 	if err != nil {
-		return err
+		return &configError{err, errorIngresses(content, err.Error())}
 	}
 
 	if glog.V(2) {
 		src, _ := ioutil.ReadFile(cfgPath)
 		if !bytes.Equal(src, content) {
-			tmpfile, err := ioutil.TempFile("", "new-nginx-cfg")
+			tmpfile, err := ioutil.TempFile(n.cfg.TempDir, "new-nginx-cfg")
 			if err != nil {
 				return err
 			}
@@ -440,37 +909,82 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		}
 	}
 
-	err = ioutil.WriteFile(cfgPath, content, 0600)
-	if err != nil {
+	if err := n.checkRejected(content); err != nil {
 		return err
 	}
-	// #nosec
-	o, err := exec.Command(n.binary, "-s", "reload", "-c", cfgPath).CombinedOutput()
+
+	previous, _ := ioutil.ReadFile(cfgPath)
+	err = n.writeConfig(content)
 	if err != nil {
-		return fmt.Errorf("%v\n%v", err, string(o))
+		return err
+	}
+	if err := n.master.Reload(); err != nil {
+		return err
+	}
+	if err := n.verifyReload(previous, content); err != nil {
+		return err
+	}
+
+	n.shadowRender(tc, content)
+
+	if n.modelCache != nil {
+		if err := n.modelCache.Save(&ingressCfg, content); err != nil {
+			glog.Warningf("unexpected error updating the model cache: %v", err)
+		}
 	}
 
 	return nil
 }
 
+// validate checks the configuration with "nginx -t" in a sandbox directory
+// passed as the prefix, so the relative paths like the error log never
+// point to the files of the serving instance. The configuration keeps the
+// serving paths, the test does not write the pid file nor signal the
+// master. The sandbox of the last invalid configuration is kept as
+// nginx-test-failed, in the temporal directory, so it can be inspected
+// manually.
+func (n *NGINXController) validate(content []byte) error {
+	sandbox, err := ioutil.TempDir(n.cfg.TempDir, "nginx-test")
+	if err != nil {
+		return err
+	}
+
+	if err := n.testTemplate(sandbox, content); err != nil {
+		failed := filepath.Join(n.cfg.TempDir, "nginx-test-failed")
+		// #nosec
+		os.RemoveAll(failed)
+		if rerr := os.Rename(sandbox, failed); rerr != nil {
+			glog.Warningf("unexpected error keeping the invalid configuration: %v", rerr)
+			// #nosec
+			os.RemoveAll(sandbox)
+		}
+		return err
+	}
+
+	return os.RemoveAll(sandbox)
+}
+
 // testTemplate checks if the NGINX configuration inside the byte array is valid
-// running the command "nginx -t" using a temporal file.
-func (n NGINXController) testTemplate(cfg []byte) error {
+// running the command "nginx -t" using a file in the given directory as the
+// prefix. Its conf directory links to the one of the NGINX build, with the
+// Lua files loaded during the test.
+func (n *NGINXController) testTemplate(dir string, cfg []byte) error {
 	if len(cfg) == 0 {
 		return fmt.Errorf("invalid nginx configuration (empty)")
 	}
-	tmpfile, err := ioutil.TempFile("", "nginx-cfg")
-	if err != nil {
+	if err := os.Symlink(filepath.Join(nginxPrefix, "conf"), filepath.Join(dir, "conf")); err != nil {
 		return err
 	}
-	// #nosec
-	defer tmpfile.Close()
-	err = ioutil.WriteFile(tmpfile.Name(), cfg, 0600)
+	if err := os.Mkdir(filepath.Join(dir, "logs"), 0755); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, "nginx.conf")
+	err := ioutil.WriteFile(name, cfg, 0600)
 	if err != nil {
 		return err
 	}
 	// #nosec
-	out, err := exec.Command(n.binary, "-t", "-c", tmpfile.Name()).CombinedOutput()
+	out, err := exec.Command(n.binary, "-t", "-p", dir+"/", "-c", name).CombinedOutput()
 	if err != nil {
 		// this error is different from the rest because it must be clear why nginx is not working
 		oe := fmt.Sprintf(`
@@ -482,9 +996,5 @@ Error: %v
 		return errors.New(oe)
 	}
 
-	if err := os.Remove(tmpfile.Name()); err != nil {
-		return err
-	}
-
 	return nil
 }
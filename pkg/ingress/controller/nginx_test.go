@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestValidateSandbox(t *testing.T) {
+	dir := t.TempDir()
+	// the fake nginx records its arguments and rejects the invalid
+	// configurations
+	binary := filepath.Join(dir, "nginx")
+	script := `#!/bin/sh
+echo "$@" > ` + dir + `/args
+! grep -q invalid "$5"
+`
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmp := filepath.Join(dir, "tmp")
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := &NGINXController{binary: binary, cfg: &Configuration{TempDir: tmp}}
+
+	if err := n.validate([]byte("events {}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args, _ := ioutil.ReadFile(filepath.Join(dir, "args"))
+	fields := strings.Fields(string(args))
+	if len(fields) != 5 || fields[0] != "-t" || fields[1] != "-p" || !strings.HasPrefix(fields[2], tmp+"/nginx-test") {
+		t.Errorf("expected the test with the sandbox as the prefix but returned %q", args)
+	}
+	if entries, _ := ioutil.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("expected the sandbox of a valid configuration to be removed")
+	}
+
+	// only the sandbox of the last invalid configuration is kept
+	for i := 0; i < 2; i++ {
+		if err := n.validate([]byte("invalid")); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	entries, _ := ioutil.ReadDir(tmp)
+	if len(entries) != 1 || entries[0].Name() != "nginx-test-failed" {
+		t.Errorf("expected only the last invalid sandbox but returned %v", entries)
+	}
+	if _, err := os.Lstat(filepath.Join(tmp, "nginx-test-failed", "conf")); err != nil {
+		t.Errorf("expected the conf directory of the NGINX build in the sandbox: %v", err)
+	}
+}
+
+func TestStatusConfig(t *testing.T) {
+	n := &NGINXController{
+		cfg:     &Configuration{StatusUpdateInterval: 30 * time.Second, StatusSummaryConfigMap: "ocm/ingress-status"},
+		listers: &ingress.StoreLister{},
+	}
+
+	c := n.statusConfig()
+	if c.UpdateInterval != 30*time.Second {
+		t.Errorf("expected the update interval 30s but returned %v", c.UpdateInterval)
+	}
+	if c.SummaryNamespace != "ocm" || c.SummaryName != "ingress-status" {
+		t.Errorf("expected the summary ocm/ingress-status but returned %v/%v", c.SummaryNamespace, c.SummaryName)
+	}
+}
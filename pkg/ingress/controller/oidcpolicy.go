@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/oidcpolicy"
+)
+
+// createOIDCPolicyInformer watches the OIDCRoutePolicies. A change of a
+// policy syncs the Ingresses.
+func (n *NGINXController) createOIDCPolicyInformer() (cache.Store, cache.Controller) {
+	client := n.cfg.DynamicClient.Resource(oidcpolicy.Resource)
+	changed := func(obj interface{}) {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			if _, err := oidcpolicy.FromUnstructured(u); err != nil {
+				glog.Warningf("ignoring %v", err)
+			}
+		}
+		// an empty Ingress syncs without a trigger, like a rollback
+		n.syncQueue.Enqueue(&networking.Ingress{})
+	}
+
+	return cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.Watch(context.TODO(), options)
+			},
+		},
+		&unstructured.Unstructured{}, n.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{
+			AddFunc: changed,
+			UpdateFunc: func(old, cur interface{}) {
+				oldObj, curObj := old.(*unstructured.Unstructured), cur.(*unstructured.Unstructured)
+				if !reflect.DeepEqual(oldObj.Object["spec"], curObj.Object["spec"]) {
+					changed(cur)
+				}
+			},
+			DeleteFunc: changed,
+		})
+}
+
+// routePolicies returns the valid OIDCRoutePolicies, sorted by name
+func (n *NGINXController) routePolicies() []oidcpolicy.Policy {
+	var policies []oidcpolicy.Policy
+	for _, item := range n.oidcPolicies.List() {
+		u, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		p, err := oidcpolicy.FromUnstructured(u)
+		if err != nil {
+			glog.V(3).Infof("ignoring %v", err)
+			continue
+		}
+		policies = append(policies, *p)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+	return policies
+}
+
+// applyOIDCPolicies sets the rules of the OIDCRoutePolicies that apply to
+// the locations of the servers. The tokens are verified with the keys of
+// the issuer of OIDC_ISSUER_URL: without an issuer, or on the locations
+// without an auth-type, the rules reject the requests and a Warning event
+// is recorded on the Ingress once.
+func (n *NGINXController) applyOIDCPolicies(servers map[string]*ingress.Server) {
+	if n.oidcPolicies == nil {
+		return
+	}
+
+	verified := os.Getenv("OIDC_ISSUER_URL") != ""
+	rejected := map[string]bool{}
+	policies := n.routePolicies()
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			loc.OIDCPolicies = oidcpolicy.Match(policies, server.Hostname, loc.Path)
+			if len(loc.OIDCPolicies) == 0 || (verified && loc.AuthType != "") {
+				continue
+			}
+
+			reason := "the location has no auth-type"
+			if !verified {
+				reason = "OIDC_ISSUER_URL is not set"
+			}
+			for i := range loc.OIDCPolicies {
+				loc.OIDCPolicies[i].Reject = true
+			}
+
+			key := fmt.Sprintf("%v%v", server.Hostname, loc.Path)
+			rejected[key] = true
+			if n.oidcPolicyRejections[key] {
+				continue
+			}
+			glog.Warningf("the OIDCRoutePolicy %v rejects the requests to %v: the tokens can not be verified, %v",
+				loc.OIDCPolicies[0].Policy, key, reason)
+			if loc.Ingress != nil {
+				n.recorder.Eventf(loc.Ingress, apiv1.EventTypeWarning, "OIDCPolicyRejected",
+					"the OIDCRoutePolicy %v rejects the requests to %v: the tokens can not be verified, %v",
+					loc.OIDCPolicies[0].Policy, key, reason)
+			}
+		}
+	}
+	n.oidcPolicyRejections = rejected
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"os"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestApplyOIDCPolicies(t *testing.T) {
+	defer os.Setenv("OIDC_ISSUER_URL", os.Getenv("OIDC_ISSUER_URL"))
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "ingress.open-cluster-management.io/v1alpha1",
+		"kind":       "OIDCRoutePolicy",
+		"metadata":   map[string]interface{}{"name": "console"},
+		"spec":       map[string]interface{}{"rules": []interface{}{map[string]interface{}{"path": "/"}}},
+	}})
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "default"}}
+
+	testCases := []struct {
+		issuer   string
+		authType string
+		reject   bool
+	}{
+		{"https://oauth.example.com", ingress.AccessToken, false},
+		{"https://oauth.example.com", "", true},
+		{"", ingress.AccessToken, true},
+	}
+
+	for _, tc := range testCases {
+		os.Setenv("OIDC_ISSUER_URL", tc.issuer)
+		recorder := record.NewFakeRecorder(10)
+		n := &NGINXController{oidcPolicies: store, recorder: recorder}
+		loc := &ingress.Location{Path: "/", Ingress: ing, AuthType: tc.authType}
+		servers := map[string]*ingress.Server{"example.com": {Hostname: "example.com", Locations: []*ingress.Location{loc}}}
+
+		// the event is only recorded by the first sync
+		for i := 0; i < 2; i++ {
+			n.applyOIDCPolicies(servers)
+			if len(loc.OIDCPolicies) != 1 || loc.OIDCPolicies[0].Reject != tc.reject {
+				t.Errorf("expected the policy rejecting the requests %v with %q and %q but returned %+v",
+					tc.reject, tc.issuer, tc.authType, loc.OIDCPolicies)
+			}
+		}
+		if events := len(recorder.Events); (events == 1) != tc.reject || events > 1 {
+			t.Errorf("expected one event %v but returned %v", tc.reject, events)
+		}
+	}
+}
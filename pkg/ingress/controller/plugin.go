@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/plugin"
+)
+
+// pluginDialTimeout is the maximum time to describe an external plugin when
+// the controller starts, like a sidecar starting with the controller
+const pluginDialTimeout = 30 * time.Second
+
+// registerRemotePlugin registers the external annotation plugin listening
+// on socket
+func registerRemotePlugin(socket string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginDialTimeout)
+	defer cancel()
+
+	var p *plugin.Remote
+	var lastErr error
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		p, lastErr = plugin.Dial(ctx, socket, timeout)
+		if lastErr != nil {
+			glog.V(2).Infof("waiting for annotation plugin %v: %v", socket, lastErr)
+			return false, nil
+		}
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		return lastErr
+	}
+	return plugin.Register(p)
+}
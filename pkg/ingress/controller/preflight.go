@@ -0,0 +1,169 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/ingress/preflight"
+)
+
+// oidcIssuerTarget is the name of the target of the OIDC issuer
+const oidcIssuerTarget = "oidc-issuer"
+
+// preflightTargets returns the targets to verify for the backends of the
+// running configuration, and the Ingresses that use each backend
+func (n *NGINXController) preflightTargets() ([]preflight.Target, map[string][]*networking.Ingress) {
+	n.runningConfigLock.RLock()
+	cfg := n.runningConfig
+	n.runningConfigLock.RUnlock()
+
+	ingresses := make(map[string][]*networking.Ingress)
+	seen := make(map[string]bool)
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Backend == "" {
+				continue
+			}
+			key := fmt.Sprintf("%v/%v/%v", loc.Backend, loc.Ingress.Namespace, loc.Ingress.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ingresses[loc.Backend] = append(ingresses[loc.Backend], loc.Ingress)
+		}
+	}
+
+	var targets []preflight.Target
+	for _, b := range cfg.Backends {
+		if b.Service == nil || b.ClusterIP == "" {
+			continue
+		}
+		port, ok := servicePort(b)
+		if !ok {
+			continue
+		}
+		targets = append(targets, preflight.Target{
+			Name:    b.Name,
+			Host:    fmt.Sprintf("%v.%v.svc", b.Service.Name, b.Service.Namespace),
+			Address: b.ClusterIP,
+			Port:    port,
+			TLS:     b.Secure,
+		})
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		if t, err := urlTarget(oidcIssuerTarget, issuer); err == nil {
+			targets = append(targets, t)
+		} else {
+			glog.Warningf("unexpected error parsing OIDC_ISSUER_URL: %v", err)
+		}
+	}
+
+	return targets, ingresses
+}
+
+// servicePort returns the number of the service port of the backend
+func servicePort(b *ingress.Backend) (int, bool) {
+	if b.Port.IntValue() > 0 {
+		return b.Port.IntValue(), true
+	}
+	for _, p := range b.Service.Spec.Ports {
+		if p.Name == b.Port.String() {
+			return int(p.Port), true
+		}
+	}
+	return 0, false
+}
+
+// urlTarget returns the target of the host of an URL
+func urlTarget(name, rawURL string) (preflight.Target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return preflight.Target{}, err
+	}
+
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if u.Port() != "" {
+		if port, err = strconv.Atoi(u.Port()); err != nil {
+			return preflight.Target{}, err
+		}
+	}
+
+	return preflight.Target{
+		Name:    name,
+		Host:    u.Hostname(),
+		Address: u.Hostname(),
+		Port:    port,
+		TLS:     u.Scheme == "https",
+	}, nil
+}
+
+// runPreflight verifies the targets and emits an event in the Ingresses
+// using a backend when it becomes unreachable or reachable again
+func (n *NGINXController) runPreflight() {
+	targets, ingresses := n.preflightTargets()
+	checker := preflight.Checker{Timeout: n.cfg.PreflightTimeout}
+
+	// failed contains every target of this run and whether its checks failed
+	failed := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		err := checker.Check(context.TODO(), t)
+		failed[t.Name] = err != nil
+		metric.SetBackendReachable(t.Name, err == nil)
+		if t.Name == oidcIssuerTarget {
+			n.updateBreakGlass(err == nil, time.Now())
+		}
+
+		if err == nil {
+			if n.preflightFailed[t.Name] {
+				glog.Infof("backend %v is reachable again", t.Name)
+				for _, ing := range ingresses[t.Name] {
+					n.recorder.Eventf(ing, apiv1.EventTypeNormal, "BackendReachable", "backend %v is reachable", t.Name)
+				}
+			}
+			continue
+		}
+
+		if perr, ok := err.(*preflight.Error); ok {
+			metric.IncBackendCheckFailure(perr.Check)
+		}
+		if n.preflightFailed[t.Name] {
+			continue
+		}
+
+		glog.Warningf("backend %v is unreachable: %v", t.Name, err)
+		if t.Name == oidcIssuerTarget {
+			if pod := podReference(); pod.Name != "" && pod.Namespace != "" {
+				n.recorder.Eventf(pod, apiv1.EventTypeWarning, "BackendUnreachable", "OIDC issuer %v is unreachable: %v", t.Host, err)
+			}
+		}
+		for _, ing := range ingresses[t.Name] {
+			n.recorder.Eventf(ing, apiv1.EventTypeWarning, "BackendUnreachable", "backend %v is unreachable: %v", t.Name, err)
+		}
+	}
+
+	// backends removed from the configuration are not reported
+	for name := range n.preflightFailed {
+		if _, ok := failed[name]; !ok {
+			metric.DeleteBackendReachable(name)
+		}
+	}
+	n.preflightFailed = failed
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/preflight"
+)
+
+func TestServicePort(t *testing.T) {
+	svc := &apiv1.Service{
+		Spec: apiv1.ServiceSpec{
+			Ports: []apiv1.ServicePort{{Name: "https", Port: 8443}},
+		},
+	}
+
+	testCases := []struct {
+		port     intstr.IntOrString
+		expected int
+		ok       bool
+	}{
+		{intstr.FromInt(80), 80, true},
+		{intstr.FromString("https"), 8443, true},
+		{intstr.FromString("http"), 0, false},
+	}
+
+	for _, tc := range testCases {
+		port, ok := servicePort(&ingress.Backend{Service: svc, Port: tc.port})
+		if port != tc.expected || ok != tc.ok {
+			t.Errorf("expected %v (%v) for %v but returned %v (%v)", tc.expected, tc.ok, tc.port.String(), port, ok)
+		}
+	}
+}
+
+func TestURLTarget(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected preflight.Target
+	}{
+		{"https://oauth.example.com", preflight.Target{Name: "t", Host: "oauth.example.com", Address: "oauth.example.com", Port: 443, TLS: true}},
+		{"http://oauth.example.com:8080/path", preflight.Target{Name: "t", Host: "oauth.example.com", Address: "oauth.example.com", Port: 8080}},
+	}
+
+	for _, tc := range testCases {
+		target, err := urlTarget("t", tc.url)
+		if err != nil || target != tc.expected {
+			t.Errorf("expected %+v for %v but returned %+v (%v)", tc.expected, tc.url, target, err)
+		}
+	}
+}
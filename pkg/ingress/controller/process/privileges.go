@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// CapNetBindService allows binding sockets to ports below 1024
+	CapNetBindService = 10
+
+	// privilegedPortLimit is the first unprivileged port
+	privilegedPortLimit = 1024
+)
+
+// procStatus is the file used to read the capabilities of the current process
+var procStatus = "/proc/self/status"
+
+// IsPrivilegedPort returns true if binding the port requires root or CAP_NET_BIND_SERVICE
+func IsPrivilegedPort(port int) bool {
+	return port > 0 && port < privilegedPortLimit
+}
+
+// NeedsBindCapability returns true if the process does not run as root and any
+// of the ports is privileged
+func NeedsBindCapability(ports ...int) bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+
+	for _, port := range ports {
+		if IsPrivilegedPort(port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The capability sets of /proc/self/status
+const (
+	// CapInheritable is the set preserved across an exec, required for ambient capabilities
+	CapInheritable = "CapInh"
+	// CapPermitted is the set the process can use, required for ambient capabilities
+	CapPermitted = "CapPrm"
+	// CapEffective is the set the kernel checks
+	CapEffective = "CapEff"
+	// CapBounding limits the capabilities granted by the file capabilities of a binary
+	CapBounding = "CapBnd"
+)
+
+// readStatus returns the value of the field of /proc/self/status
+func readStatus(field string) (string, error) {
+	f, err := os.Open(procStatus)
+	if err != nil {
+		return "", err
+	}
+	// #nosec
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, field+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, field+":")), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%v not found in %v", field, procStatus)
+}
+
+// HasCapability returns true if the capability is in the set (CapPermitted,
+// CapBounding...) of the current process
+func HasCapability(set string, capability uint) (bool, error) {
+	value, err := readStatus(set)
+	if err != nil {
+		return false, err
+	}
+
+	mask, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return false, fmt.Errorf("unexpected %v value %q: %v", set, value, err)
+	}
+
+	return mask&(1<<capability) != 0, nil
+}
+
+// CanRaiseAmbient returns true if the capability can be passed to a child
+// process as ambient capability, which requires it in the permitted and
+// inheritable sets
+func CanRaiseAmbient(capability uint) bool {
+	for _, set := range []string{CapPermitted, CapInheritable} {
+		if ok, err := HasCapability(set, capability); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CanGrantFileCapability returns true if the file capabilities of a binary
+// can grant the capability to the process executing it, which requires it in
+// the bounding set and privilege escalation not being disabled
+// (allowPrivilegeEscalation: false sets no_new_privs)
+func CanGrantFileCapability(capability uint) (bool, error) {
+	ok, err := HasCapability(CapBounding, capability)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	noNewPrivs, err := readStatus("NoNewPrivs")
+	if err != nil {
+		return false, err
+	}
+
+	return noNewPrivs != "1", nil
+}
+
+// SysProcAttr returns the attributes used to start the NGINX master process.
+// NGINX runs in its own process group to avoid receiving the signals meant for
+// the controller. When the controller does not run as root, any of the ports
+// is privileged and CAP_NET_BIND_SERVICE is in its permitted and inheritable
+// sets, it is passed as ambient capability, so it is the only privilege NGINX
+// inherits. Otherwise NGINX relies on the file capability of its binary.
+func SysProcAttr(ports ...int) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+
+	if NeedsBindCapability(ports...) && CanRaiseAmbient(CapNetBindService) {
+		attr.AmbientCaps = []uintptr{CapNetBindService}
+	}
+
+	return attr
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIsPrivilegedPort(t *testing.T) {
+	cases := map[int]bool{
+		0:     false,
+		80:    true,
+		443:   true,
+		1023:  true,
+		1024:  false,
+		8443:  false,
+		65535: false,
+	}
+
+	for port, expected := range cases {
+		if r := IsPrivilegedPort(port); r != expected {
+			t.Errorf("returned %v for port %v but expected %v", r, port, expected)
+		}
+	}
+}
+
+// writeStatus replaces procStatus with a file with the content and returns
+// the function restoring it
+func writeStatus(t *testing.T, content string) func() {
+	f, err := ioutil.TempFile("", "status")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal file: %v", err)
+	}
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		t.Fatalf("unexpected error writing temporal file: %v", err)
+	}
+	f.Close()
+
+	old := procStatus
+	procStatus = f.Name()
+	return func() {
+		procStatus = old
+		os.Remove(f.Name())
+	}
+}
+
+func TestHasCapability(t *testing.T) {
+	defer writeStatus(t, "Name:\tnginx\nCapInh:\t0000000000000000\nCapPrm:\t0000000000000400\n"+
+		"CapEff:\t0000000000000000\nCapBnd:\t0000000000000401\nNoNewPrivs:\t0\n")()
+
+	has, err := HasCapability(CapPermitted, CapNetBindService)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !has {
+		t.Errorf("expected CAP_NET_BIND_SERVICE in the permitted set")
+	}
+
+	has, err = HasCapability(CapEffective, CapNetBindService)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if has {
+		t.Errorf("expected CAP_NET_BIND_SERVICE not to be in the effective set")
+	}
+
+	has, err = HasCapability(CapPermitted, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if has {
+		t.Errorf("expected CAP_CHOWN not to be in the permitted set")
+	}
+
+	if _, err := HasCapability("CapAmb", 0); err == nil {
+		t.Errorf("expected an error with a missing set")
+	}
+}
+
+func TestCanRaiseAmbient(t *testing.T) {
+	cases := map[string]bool{
+		"CapInh:\t0000000000000000\nCapPrm:\t0000000000000400\n": false,
+		"CapInh:\t0000000000000400\nCapPrm:\t0000000000000000\n": false,
+		"CapInh:\t0000000000000400\nCapPrm:\t0000000000000400\n": true,
+	}
+
+	for status, expected := range cases {
+		restore := writeStatus(t, status)
+		if r := CanRaiseAmbient(CapNetBindService); r != expected {
+			t.Errorf("returned %v for %q but expected %v", r, status, expected)
+		}
+		restore()
+	}
+}
+
+func TestCanGrantFileCapability(t *testing.T) {
+	cases := map[string]bool{
+		"CapBnd:\t0000000000000000\nNoNewPrivs:\t0\n": false,
+		"CapBnd:\t0000000000000400\nNoNewPrivs:\t1\n": false,
+		"CapBnd:\t0000000000000400\nNoNewPrivs:\t0\n": true,
+	}
+
+	for status, expected := range cases {
+		restore := writeStatus(t, status)
+		r, err := CanGrantFileCapability(CapNetBindService)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", status, err)
+		}
+		if r != expected {
+			t.Errorf("returned %v for %q but expected %v", r, status, expected)
+		}
+		restore()
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// Master supervises the NGINX master process. Reload and quit are sent as
+// signals to the process started by the controller instead of running the
+// binary with -s, so no additional process or pid file lookup is required
+// and it works under restrictive seccomp and AppArmor profiles.
+type Master struct {
+	newCmd func() *exec.Cmd
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	exited chan struct{}
+
+	// Exit receives the result of the master process when it terminates
+	Exit chan error
+}
+
+// NewMaster returns a supervisor that uses newCmd to create the command
+// every time the master process is started
+func NewMaster(newCmd func() *exec.Cmd) *Master {
+	return &Master{
+		newCmd: newCmd,
+		Exit:   make(chan error, 1),
+	}
+}
+
+// Start starts a new master process
+func (m *Master) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cmd := m.newCmd()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	m.cmd = cmd
+	m.exited = exited
+	metric.SetNginxMasterUp(true)
+	glog.Infof("NGINX master process started (pid %v)", cmd.Process.Pid)
+
+	go func() {
+		err := cmd.Wait()
+		metric.SetNginxMasterUp(false)
+		close(exited)
+		m.Exit <- err
+	}()
+
+	return nil
+}
+
+// Restart starts a new master process after an unexpected exit
+func (m *Master) Restart() error {
+	metric.IncNginxMasterRestarts()
+	return m.Start()
+}
+
+// Reload tells the master process to read the configuration again
+func (m *Master) Reload() error {
+	return m.signal(syscall.SIGHUP)
+}
+
+// Quit gracefully stops the master process and waits until it exits
+func (m *Master) Quit(timeout time.Duration) error {
+	if err := m.signal(syscall.SIGQUIT); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	exited := m.exited
+	m.mu.Unlock()
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("NGINX master process did not exit after %v", timeout)
+	}
+}
+
+// Pid returns the pid of the master process or zero if it was not started
+func (m *Master) Pid() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cmd == nil || m.cmd.Process == nil {
+		return 0
+	}
+	return m.cmd.Process.Pid
+}
+
+func (m *Master) signal(sig syscall.Signal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cmd == nil || m.cmd.Process == nil {
+		metric.IncNginxSignalError(sig.String())
+		return fmt.Errorf("NGINX master process is not running")
+	}
+
+	if err := m.cmd.Process.Signal(sig); err != nil {
+		metric.IncNginxSignalError(sig.String())
+		return fmt.Errorf("unexpected error sending %v to NGINX master process %v: %v", sig, m.cmd.Process.Pid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package process
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMaster(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep binary not available")
+	}
+
+	m := NewMaster(func() *exec.Cmd {
+		return exec.Command(sleep, "60")
+	})
+
+	if err := m.Reload(); err == nil {
+		t.Errorf("expected an error reloading a process that was not started")
+	}
+
+	if err := m.Start(); err != nil {
+		t.Fatalf("unexpected error starting the process: %v", err)
+	}
+	if m.Pid() == 0 {
+		t.Errorf("expected a pid after start")
+	}
+
+	if err := m.Quit(5 * time.Second); err != nil {
+		t.Fatalf("unexpected error stopping the process: %v", err)
+	}
+
+	select {
+	case err := <-m.Exit:
+		if err == nil {
+			t.Errorf("expected an error from a process terminated by a signal")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the exit of the process")
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/replayprotection"
+)
+
+// writeReplayProtectionKeys writes the keys of the secret of c to the SSL
+// directory and returns the configuration with the file and its checksum
+func (n *NGINXController) writeReplayProtectionKeys(c replayprotection.Config) (replayprotection.Config, error) {
+	keysFile, checksum, err := n.writeKeysFile("replay-protection", c.Secret)
+	if err != nil {
+		return c, fmt.Errorf("invalid replay protection secret %v: %v", c.Secret, err)
+	}
+	c.KeysFile, c.Checksum = keysFile, checksum
+	return c, nil
+}
+
+// usesReplayProtectionSecret returns true if an Ingress requires webhooks
+// signed with the keys of the secret
+func (n *NGINXController) usesReplayProtectionSecret(key string) bool {
+	for _, item := range n.listers.IngressAnnotation.List() {
+		if item.(*annotations.Ingress).ReplayProtection.Secret == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/servicemesh"
+)
+
+// setServiceMesh configures the service mesh of the pods of an upstream.
+// The first Ingress with the annotation referencing the upstream is used.
+// The sidecars encrypt the requests, so they are sent in plain HTTP, and
+// upstream-spiffe-id, which requires TLS to the pods, takes precedence.
+func (n *NGINXController) setServiceMesh(ups *ingress.Backend, cfg servicemesh.Config) {
+	if ups.ServiceMesh.Enabled() || !cfg.Enabled() {
+		return
+	}
+	if ups.UpstreamIdentity.Enabled() {
+		glog.Warningf("ignoring the %v service mesh of upstream %v, its SPIFFE IDs are verified", cfg.Mesh, ups.Name)
+		return
+	}
+	ups.ServiceMesh = cfg
+	ups.Secure = false
+}
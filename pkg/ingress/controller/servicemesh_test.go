@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/servicemesh"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+)
+
+func TestSetServiceMesh(t *testing.T) {
+	n := &NGINXController{}
+
+	// the sidecars encrypt the requests
+	ups := &ingress.Backend{Name: "hub-api-443", Secure: true, SlowStart: 60}
+	n.setServiceMesh(ups, servicemesh.Config{Mesh: servicemesh.Linkerd})
+	if ups.ServiceMesh.Mesh != servicemesh.Linkerd || ups.Secure {
+		t.Errorf("expected a plain HTTP linkerd upstream but returned %+v", ups)
+	}
+	if ups.BalancesEndpoints() {
+		t.Errorf("expected the mesh to balance the endpoints")
+	}
+
+	// the first Ingress referencing the upstream is used
+	n.setServiceMesh(ups, servicemesh.Config{Mesh: servicemesh.Istio})
+	if ups.ServiceMesh.Mesh != servicemesh.Linkerd {
+		t.Errorf("expected the first service mesh but returned %v", ups.ServiceMesh.Mesh)
+	}
+
+	// the SPIFFE IDs require TLS to the pods
+	ups = &ingress.Backend{Name: "hub-api-443", Secure: true, UpstreamIdentity: upstreamidentity.Config{IDs: []string{"spiffe://example.org/api"}}}
+	n.setServiceMesh(ups, servicemesh.Config{Mesh: servicemesh.Istio})
+	if ups.ServiceMesh.Enabled() || !ups.Secure {
+		t.Errorf("expected the upstream-spiffe-id to take precedence but returned %+v", ups)
+	}
+}
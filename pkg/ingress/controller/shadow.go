@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/file"
+	ngx_config "github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+	ngx_template "github.com/stolostron/management-ingress/pkg/ingress/controller/template"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/watch"
+)
+
+// loadShadowTemplate loads the candidate template, when present, and
+// reloads it after every change
+func (n *NGINXController) loadShadowTemplate(fs file.Filesystem) {
+	if _, err := fs.Stat(nextTmplPath); err != nil {
+		glog.V(2).Infof("no candidate NGINX template %v, shadow rendering is disabled", nextTmplPath)
+		return
+	}
+
+	onChange := func() {
+		template, err := ngx_template.NewTemplate(nextTmplPath, fs)
+		if err != nil {
+			glog.Warningf("unexpected error loading the candidate NGINX template: %v", err)
+			metric.IncShadowRender("error", 0)
+			return
+		}
+		n.shadow = template
+		n.lastShadowDiff = ngx_template.Comparison{}
+		glog.Infof("candidate NGINX template %v loaded for shadow rendering", nextTmplPath)
+	}
+	onChange()
+
+	if _, ok := fs.(*file.DefaultFs); !ok {
+		watch.NewDummyFileWatcher(nextTmplPath, onChange)
+		return
+	}
+	if _, err := watch.NewFileWatcher(nextTmplPath, onChange); err != nil {
+		glog.Warningf("unexpected error watching the candidate template %v: %v", nextTmplPath, err)
+	}
+}
+
+// shadowRender renders the model with the candidate template and reports
+// the differences with the configuration of the active template. The
+// candidate never affects the configuration in use.
+func (n *NGINXController) shadowRender(tc ngx_config.TemplateConfig, content []byte) {
+	shadow := n.shadow
+	if shadow == nil {
+		return
+	}
+
+	next, err := shadow.Write(tc)
+	if err != nil {
+		glog.Warningf("unexpected error shadow rendering the candidate NGINX template: %v", err)
+		metric.IncShadowRender("error", 0)
+		return
+	}
+
+	c := ngx_template.Compare(content, next)
+	if c.Equal() {
+		glog.V(3).Infof("the candidate NGINX template renders the same configuration")
+		metric.IncShadowRender("match", 0)
+		n.lastShadowDiff = c
+		return
+	}
+	metric.IncShadowRender("differ", c.Removed+c.Added)
+
+	if reflect.DeepEqual(c, n.lastShadowDiff) {
+		return
+	}
+	n.lastShadowDiff = c
+	glog.Warningf("the candidate NGINX template renders a different configuration: %v lines removed and %v added, first difference at line %v\n%v",
+		c.Removed, c.Added, c.FirstLine, strings.Join(c.Samples, "\n"))
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+var (
+	// sharedCertificateAlias is the annotation of the secrets of the
+	// platform namespace with the alias used by the Ingresses
+	sharedCertificateAlias = parser.GetAnnotationWithPrefix("shared-certificate-alias")
+	// sharedCertificateNamespaces is the annotation of the shared secrets
+	// with the namespaces allowed to use them, comma separated patterns
+	// like team-a or team-*
+	sharedCertificateNamespaces = parser.GetAnnotationWithPrefix("shared-certificate-namespaces")
+)
+
+// sharedCertificateAllowed returns true if the shared secret can be used
+// by the Ingresses of the namespace. No namespace is allowed by default.
+func sharedCertificateAllowed(secret *apiv1.Secret, namespace string) bool {
+	for _, pattern := range strings.Split(secret.Annotations[sharedCertificateNamespaces], ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSharedCertificate returns true if the secret is published by the
+// platform namespace with an alias
+func (n *NGINXController) isSharedCertificate(secret *apiv1.Secret) bool {
+	return n.cfg.SharedCertNamespace != "" &&
+		secret.Namespace == n.cfg.SharedCertNamespace &&
+		secret.Annotations[sharedCertificateAlias] != ""
+}
+
+// sharedCertificate returns the <namespace>/<name> of the secret of the
+// platform namespace published with alias, if the Ingresses of namespace
+// may use it. If several secrets have the same alias, the first one by
+// name is used, so a rotation to a new secret takes effect when the alias
+// is removed from the old one.
+func (n *NGINXController) sharedCertificate(alias, namespace string) (string, error) {
+	if n.cfg.SharedCertNamespace == "" {
+		return "", fmt.Errorf("shared certificates are disabled")
+	}
+
+	var secrets []*apiv1.Secret
+	for _, obj := range n.listers.Secret.List() {
+		secret := obj.(*apiv1.Secret)
+		if n.isSharedCertificate(secret) && secret.Annotations[sharedCertificateAlias] == alias {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		return "", fmt.Errorf("there is no shared certificate %v", alias)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	if len(secrets) > 1 {
+		glog.Warningf("%v secrets publish the shared certificate %v, using %v", len(secrets), alias, secrets[0].Name)
+	}
+
+	secret := secrets[0]
+	if !sharedCertificateAllowed(secret, namespace) {
+		return "", fmt.Errorf("the namespace %v is not allowed to use the shared certificate %v", namespace, alias)
+	}
+	return fmt.Sprintf("%v/%v", secret.Namespace, secret.Name), nil
+}
+
+// sharedCertificateKey returns the <namespace>/<name> of the shared secret
+// used by the Ingress, or an empty string if it does not use one
+func (n *NGINXController) sharedCertificateKey(ing *networking.Ingress) string {
+	alias, _ := parser.GetStringAnnotation("shared-certificate", ing)
+	if alias == "" || n.cfg.SharedCertNamespace == "" {
+		return ""
+	}
+	key, err := n.sharedCertificate(alias, ing.Namespace)
+	if err != nil {
+		glog.V(3).Infof("ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+func buildSharedSecret(namespace, name, alias, namespaces string) *apiv1.Secret {
+	return &apiv1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      name,
+		Annotations: map[string]string{
+			sharedCertificateAlias:      alias,
+			sharedCertificateNamespaces: namespaces,
+		},
+	}}
+}
+
+func TestSharedCertificate(t *testing.T) {
+	ic := buildGenericControllerForBackendSSL()
+	ic.cfg.SharedCertNamespace = "platform"
+	for _, s := range []*apiv1.Secret{
+		buildSharedSecret("platform", "apps-2021", "apps", "team-*, billing"),
+		buildSharedSecret("platform", "apps-2022", "apps", "*"),
+		buildSharedSecret("platform", "internal", "internal", ""),
+		buildSharedSecret("team-a", "fake", "internal", "*"),
+	} {
+		ic.listers.Secret.Add(s)
+	}
+
+	testCases := []struct {
+		alias, namespace, expected string
+	}{
+		{"apps", "team-a", "platform/apps-2021"},
+		{"apps", "billing", "platform/apps-2021"},
+		{"apps", "other", ""},
+		{"internal", "team-a", ""},
+		{"missing", "team-a", ""},
+	}
+	for _, tc := range testCases {
+		key, err := ic.sharedCertificate(tc.alias, tc.namespace)
+		if key != tc.expected || (err == nil) != (tc.expected != "") {
+			t.Errorf("expected %q for %v in %v but returned %q, %v", tc.expected, tc.alias, tc.namespace, key, err)
+		}
+	}
+
+	// rotation to the new secret
+	ic.listers.Secret.Delete(buildSharedSecret("platform", "apps-2021", "", ""))
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "other",
+		Name:        "console",
+		Annotations: map[string]string{parser.GetAnnotationWithPrefix("shared-certificate"): "apps"},
+	}}
+	if key := ic.sharedCertificateKey(ing); key != "platform/apps-2022" {
+		t.Errorf("expected the rotated secret but returned %q", key)
+	}
+
+	ic.cfg.SharedCertNamespace = ""
+	if key := ic.sharedCertificateKey(ing); key != "" {
+		t.Errorf("expected no shared certificate when disabled but returned %q", key)
+	}
+}
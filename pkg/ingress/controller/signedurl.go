@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/management-ingress/pkg/file"
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/signedurl"
+)
+
+// keyIDRegex matches the secret keys used as key ids of the signed URLs
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// signedURLKeys returns the content of the keys file of a secret: one
+// line per key with the key id and the base64 encoded key, sorted by id
+func signedURLKeys(data map[string][]byte) ([]byte, error) {
+	ids := make([]string, 0, len(data))
+	for id, key := range data {
+		if !keyIDRegex.MatchString(id) || len(key) == 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("there are no keys")
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		fmt.Fprintf(&buf, "%v %v\n", id, base64.StdEncoding.EncodeToString(data[id]))
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSignedURLKeys writes the keys of the secret of c to the SSL
+// directory and returns the configuration with the file and its checksum
+func (n *NGINXController) writeSignedURLKeys(c signedurl.Config) (signedurl.Config, error) {
+	keysFile, checksum, err := n.writeKeysFile("signed-url", c.Secret)
+	if err != nil {
+		return c, fmt.Errorf("invalid signed URL secret %v: %v", c.Secret, err)
+	}
+	c.KeysFile, c.Checksum = keysFile, checksum
+	return c, nil
+}
+
+// writeKeysFile writes the keys of a secret to <prefix>-<namespace>-<name>.keys
+// in the SSL directory, if they changed, and returns the file and its
+// checksum
+func (n *NGINXController) writeKeysFile(prefix, key string) (string, string, error) {
+	secret, err := n.listers.Secret.GetByName(key)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := signedURLKeys(secret.Data)
+	if err != nil {
+		return "", "", err
+	}
+
+	keysFile := fmt.Sprintf("%v/%v-%v.keys", ingress.DefaultSSLDirectory, prefix, strings.Replace(key, "/", "-", -1))
+	if current, err := ioutil.ReadFile(keysFile); err != nil || !bytes.Equal(current, content) {
+		if err := ioutil.WriteFile(keysFile, content, 0600); err != nil {
+			return "", "", err
+		}
+	}
+	return keysFile, file.SHA1(keysFile), nil
+}
+
+// usesSignedURLSecret returns true if an Ingress requires URLs signed
+// with the keys of the secret
+func (n *NGINXController) usesSignedURLSecret(key string) bool {
+	for _, item := range n.listers.IngressAnnotation.List() {
+		if item.(*annotations.Ingress).SignedURL.Secret == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestSignedURLKeys(t *testing.T) {
+	content, err := signedURLKeys(map[string][]byte{
+		"v2":          []byte("second"),
+		"v1":          []byte("first"),
+		"invalid key": []byte("ignored"),
+		"empty":       {},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "v1 Zmlyc3Q=\nv2 c2Vjb25k\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but returned %q", expected, content)
+	}
+
+	if _, err := signedURLKeys(map[string][]byte{"empty": {}}); err == nil {
+		t.Errorf("expected an error without keys")
+	}
+}
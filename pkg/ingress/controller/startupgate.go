@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/ingress/preflight"
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+const (
+	// startupGateInitialDelay is the delay before the second check of the
+	// required references, doubled after each check
+	startupGateInitialDelay = time.Second
+	// startupGateMaxDelay is the maximum delay between two checks
+	startupGateMaxDelay = time.Minute
+)
+
+// StartupGateStatus is the state of the references required to start
+type StartupGateStatus struct {
+	Ready bool `json:"ready"`
+	// Missing contains the references that do not exist yet, like
+	// secret kube-system/router-certs
+	Missing   []string  `json:"missing,omitempty"`
+	Attempts  int       `json:"attempts"`
+	LastCheck time.Time `json:"lastCheck,omitempty"`
+}
+
+// startupGate holds the start of NGINX until the required references exist
+type startupGate struct {
+	mu     sync.RWMutex
+	status StartupGateStatus
+}
+
+// requiredReference is a resource the configuration depends on
+type requiredReference struct {
+	kind string
+	name string
+}
+
+func (r requiredReference) String() string {
+	return r.kind + " " + r.name
+}
+
+// requiredReferences returns the default certificate, the services of
+// --startup-gate-services and the OIDC issuer of OIDC_ISSUER_URL
+func (n *NGINXController) requiredReferences() []requiredReference {
+	var refs []requiredReference
+	if n.cfg.DefaultSSLCertificate != "" {
+		refs = append(refs, requiredReference{"secret", n.cfg.DefaultSSLCertificate})
+	}
+	for _, svc := range n.cfg.StartupGateServices {
+		refs = append(refs, requiredReference{"service", svc})
+	}
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		refs = append(refs, requiredReference{oidcIssuerTarget, issuer})
+	}
+	return refs
+}
+
+// checkReference returns an error if the reference does not exist, or the
+// OIDC issuer is unreachable
+func (n *NGINXController) checkReference(ctx context.Context, ref requiredReference) error {
+	switch ref.kind {
+	case "secret":
+		ns, name, err := k8s.ParseNameNS(ref.name)
+		if err != nil {
+			return err
+		}
+		_, err = n.cfg.Client.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	case "service":
+		ns, name, err := k8s.ParseNameNS(ref.name)
+		if err != nil {
+			return err
+		}
+		_, err = n.cfg.Client.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	case oidcIssuerTarget:
+		t, err := urlTarget(oidcIssuerTarget, ref.name)
+		if err != nil {
+			return err
+		}
+		return preflight.Checker{Timeout: n.cfg.PreflightTimeout}.Check(ctx, t)
+	}
+	return fmt.Errorf("unknown reference %v", ref)
+}
+
+// waitForStartupGate checks the required references, retrying with a
+// backoff, until they all exist or the controller stops. It returns false
+// if the controller stopped first.
+func (n *NGINXController) waitForStartupGate() bool {
+	refs := n.requiredReferences()
+	delay := startupGateInitialDelay
+	for {
+		var missing []string
+		for _, ref := range refs {
+			if err := n.checkReference(context.TODO(), ref); err != nil {
+				glog.V(2).Infof("required %v is not available: %v", ref, err)
+				missing = append(missing, ref.String())
+			}
+		}
+
+		n.gate.mu.Lock()
+		n.gate.status.Attempts++
+		n.gate.status.LastCheck = time.Now()
+		n.gate.status.Missing = missing
+		n.gate.status.Ready = len(missing) == 0
+		attempts := n.gate.status.Attempts
+		n.gate.mu.Unlock()
+		metric.SetStartupGateMissing(len(missing))
+
+		if len(missing) == 0 {
+			glog.Infof("the required references are available after %v checks", attempts)
+			return true
+		}
+
+		glog.Warningf("holding the start until the required references exist: %v (check %v, retrying in %v)",
+			strings.Join(missing, ", "), attempts, delay)
+		if attempts == 1 {
+			if pod := podReference(); pod.Name != "" && pod.Namespace != "" {
+				n.recorder.Eventf(pod, apiv1.EventTypeWarning, "StartupGated", "waiting for %v", strings.Join(missing, ", "))
+			}
+		}
+
+		select {
+		case <-n.stopCh:
+			return false
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > startupGateMaxDelay {
+			delay = startupGateMaxDelay
+		}
+	}
+}
+
+// StartupGate returns the state of the references required to start. The
+// controller is ready when the gate is disabled.
+func (n *NGINXController) StartupGate() StartupGateStatus {
+	if n.gate == nil {
+		return StartupGateStatus{Ready: true}
+	}
+	n.gate.mu.RLock()
+	defer n.gate.mu.RUnlock()
+	status := n.gate.status
+	status.Missing = append([]string(nil), status.Missing...)
+	return status
+}
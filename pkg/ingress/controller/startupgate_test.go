@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestWaitForStartupGate(t *testing.T) {
+	client := testclient.NewSimpleClientset(&apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "auth"},
+	})
+	n := &NGINXController{
+		cfg: &Configuration{
+			Client:                client,
+			DefaultSSLCertificate: "kube-system/router-certs",
+			StartupGate:           true,
+			StartupGateServices:   []string{"ns/auth"},
+		},
+		recorder: record.NewFakeRecorder(10),
+		gate:     &startupGate{},
+		stopCh:   make(chan struct{}),
+	}
+
+	close(n.stopCh)
+	if n.waitForStartupGate() {
+		t.Fatalf("expected the gate to be held without the default certificate")
+	}
+	status := n.StartupGate()
+	if status.Ready || status.Attempts != 1 || !reflect.DeepEqual(status.Missing, []string{"secret kube-system/router-certs"}) {
+		t.Errorf("expected the default certificate to be missing but returned %+v", status)
+	}
+
+	client.Tracker().Add(&apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "router-certs"}})
+	if !n.waitForStartupGate() {
+		t.Fatalf("expected the gate to open with the required references")
+	}
+	if status := n.StartupGate(); !status.Ready || len(status.Missing) != 0 {
+		t.Errorf("expected the controller to be ready but returned %+v", status)
+	}
+
+	n.gate = nil
+	if !n.StartupGate().Ready {
+		t.Errorf("expected the controller to be ready without the gate")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// stateFile is a file read by NGINX with the state of some Services that
+// changes without a reload, like the readiness of their pods. The upstreams
+// point to the ClusterIP of the Services, so reloading on every change of
+// their endpoints is avoided.
+type stateFile struct {
+	path string
+
+	mu sync.Mutex
+	// services contains the keys of the Services in the file
+	services map[string]bool
+	content  []byte
+}
+
+func newStateFile(dir, name string) *stateFile {
+	return &stateFile{
+		path:     filepath.Join(dir, name),
+		services: map[string]bool{},
+	}
+}
+
+// uses returns true if the state of the Service is in the file
+func (f *stateFile) uses(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.services[key]
+}
+
+// create writes the file without Services, so NGINX finds it from the
+// start, before the state of the Services is known
+func (f *stateFile) create() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services = map[string]bool{}
+	return f.write([]byte{})
+}
+
+// replace writes the content of the file if it changed. The file is not
+// created until there are Services, unless created at startup.
+func (f *stateFile) replace(services map[string]bool, content []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services = services
+	if (f.content == nil && len(services) == 0) || (f.content != nil && bytes.Equal(f.content, content)) {
+		return nil
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return f.write(content)
+}
+
+// write replaces the file with the content
+func (f *stateFile) write(content []byte) error {
+	// NGINX must never read a partial file
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.content = content
+	return nil
+}
+
+// endpointsByKey returns the endpoints of a Service
+func (n *NGINXController) endpointsByKey(key string) (*apiv1.Endpoints, bool) {
+	obj, exists, err := n.listers.Endpoint.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*apiv1.Endpoints), true
+}
+
+// updateStateFiles writes the state files of the Services of the
+// configuration
+func (n *NGINXController) updateStateFiles(cfg *ingress.Configuration) {
+	n.updateBackendReadiness(cfg)
+	n.updateBalancedEndpoints(cfg)
+	if n.identity != nil && cfg != nil && hasUpstreamIdentity(cfg) {
+		// the new endpoints are verified before they are balanced
+		n.identity.refresh()
+	}
+}
+
+// endpointsChanged updates the state files when the Endpoints, or an
+// EndpointSlice, of a Service in them change
+func (n *NGINXController) endpointsChanged(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	var key string
+	switch ep := obj.(type) {
+	case *apiv1.Endpoints:
+		key = fmt.Sprintf("%v/%v", ep.Namespace, ep.Name)
+	case *discovery.EndpointSlice:
+		key = fmt.Sprintf("%v/%v", ep.Namespace, ep.Labels[discovery.LabelServiceName])
+	default:
+		return
+	}
+	if !n.readiness.uses(key) && !n.balancedEndpoints.uses(key) {
+		return
+	}
+
+	n.runningConfigLock.RLock()
+	defer n.runningConfigLock.RUnlock()
+	n.updateStateFiles(n.runningConfig)
+}
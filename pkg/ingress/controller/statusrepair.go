@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/status"
+)
+
+// errStatusDisabled is returned by the status repairs without
+// --update-status
+var errStatusDisabled = fmt.Errorf("the update of the Ingress status is disabled")
+
+// IsStatusLeader returns true if this replica is the leader updating the
+// status of the Ingresses
+func (n *NGINXController) IsStatusLeader() (bool, error) {
+	if n.syncStatus == nil {
+		return false, errStatusDisabled
+	}
+	return n.syncStatus.IsLeader(), nil
+}
+
+// StartStatusRepair starts a repair of the status of all the Ingresses of
+// the class, with the rate of --status-repair-qps. Only the status leader
+// repairs the status.
+func (n *NGINXController) StartStatusRepair() error {
+	if n.syncStatus == nil {
+		return errStatusDisabled
+	}
+	return n.syncStatus.StartRepair()
+}
+
+// LastStatusRepair returns the status repair in progress or the last one,
+// nil if none was started
+func (n *NGINXController) LastStatusRepair() *status.RepairReport {
+	if n.syncStatus == nil {
+		return nil
+	}
+	return n.syncStatus.LastRepair()
+}
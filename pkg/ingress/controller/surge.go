@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// backendReadinessFile is the state file, in the temporal directory, with
+// the ready and total pods of the Services of the locations with surge
+// protection
+const backendReadinessFile = "backend-readiness"
+
+// podReadiness returns the number of ready pods of the endpoints and the
+// number of pods, counting each address once
+func podReadiness(ep *apiv1.Endpoints) (int, int) {
+	ready := map[string]bool{}
+	notReady := map[string]bool{}
+	for _, subset := range ep.Subsets {
+		for _, addr := range subset.Addresses {
+			ready[addr.IP] = true
+		}
+		for _, addr := range subset.NotReadyAddresses {
+			notReady[addr.IP] = true
+		}
+	}
+	for ip := range ready {
+		delete(notReady, ip)
+	}
+	return len(ready), len(ready) + len(notReady)
+}
+
+// surgeServices returns the keys of the Services of the locations with
+// surge protection
+func surgeServices(cfg *ingress.Configuration) map[string]bool {
+	services := map[string]bool{}
+	if cfg == nil {
+		return services
+	}
+	for _, server := range cfg.Servers {
+		for _, loc := range server.Locations {
+			if loc.SurgeProtection.Enabled() && loc.Service != nil {
+				services[fmt.Sprintf("%v/%v", loc.Service.Namespace, loc.Service.Name)] = true
+			}
+		}
+	}
+	return services
+}
+
+// backendReadiness returns the content of the readiness file, one line
+// per Service with its key, ready pods and pods. Services without
+// endpoints are omitted, so NGINX does not protect them.
+func backendReadiness(services map[string]bool, endpoints func(string) (*apiv1.Endpoints, bool)) []byte {
+	keys := make([]string, 0, len(services))
+	for key := range services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		ep, ok := endpoints(key)
+		if !ok {
+			continue
+		}
+		ready, total := podReadiness(ep)
+		fmt.Fprintf(&buf, "%v %v %v\n", key, ready, total)
+	}
+	return buf.Bytes()
+}
+
+// updateBackendReadiness writes the readiness of the Services with surge
+// protection of the configuration
+func (n *NGINXController) updateBackendReadiness(cfg *ingress.Configuration) {
+	services := surgeServices(cfg)
+	if err := n.readiness.replace(services, backendReadiness(services, n.endpointsByKey)); err != nil {
+		glog.Warningf("unexpected error writing the readiness of the backends: %v", err)
+	}
+}
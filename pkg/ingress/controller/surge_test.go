@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestPodReadiness(t *testing.T) {
+	ep := &apiv1.Endpoints{
+		Subsets: []apiv1.EndpointSubset{
+			{
+				Addresses:         []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+				NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.3"}},
+			},
+			{
+				// the same pods with another port
+				Addresses:         []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+				NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}},
+			},
+		},
+	}
+
+	ready, total := podReadiness(ep)
+	if ready != 1 || total != 3 {
+		t.Errorf("expected 1 ready pod of 3 but returned %v of %v", ready, total)
+	}
+}
+
+func TestBackendReadiness(t *testing.T) {
+	endpoints := map[string]*apiv1.Endpoints{
+		"search/api": {
+			Subsets: []apiv1.EndpointSubset{{
+				Addresses:         []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+				NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}},
+			}},
+		},
+		"console/ui": {},
+	}
+	lookup := func(key string) (*apiv1.Endpoints, bool) {
+		ep, ok := endpoints[key]
+		return ep, ok
+	}
+
+	content := backendReadiness(map[string]bool{"search/api": true, "console/ui": true, "missing/svc": true}, lookup)
+	expected := "console/ui 0 0\nsearch/api 1 2\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but returned %q", expected, content)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := newStateFile(dir, backendReadinessFile)
+	if err := f.replace(map[string]bool{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, backendReadinessFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no state file without services")
+	}
+
+	if err := f.replace(map[string]bool{"search/api": true}, []byte("search/api 1 2\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, backendReadinessFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != "search/api 1 2\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if !f.uses("search/api") || f.uses("other/svc") {
+		t.Errorf("expected only the services in the file to be used")
+	}
+
+	// the file created at startup is empty until the services are known
+	f = newStateFile(dir, backendReadinessFile)
+	if err := f.create(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err = ioutil.ReadFile(filepath.Join(dir, backendReadinessFile))
+	if err != nil || len(content) != 0 {
+		t.Errorf("expected an empty state file at startup but returned %q %v", content, err)
+	}
+	if err := f.replace(map[string]bool{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.replace(map[string]bool{"search/api": true}, []byte("search/api 1 2\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ = ioutil.ReadFile(filepath.Join(dir, backendReadinessFile))
+	if string(content) != "search/api 1 2\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
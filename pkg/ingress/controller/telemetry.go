@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang/glog"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/schema"
+	"github.com/stolostron/management-ingress/pkg/ingress/telemetry"
+	"github.com/stolostron/management-ingress/pkg/version"
+)
+
+// unknownAnnotation counts the annotations with the prefix of the
+// controller that it does not read, whose names could be private
+const unknownAnnotation = "unknown"
+
+// telemetryReport returns the usage of the features, before it is
+// anonymized
+func (n *NGINXController) telemetryReport(installation string) telemetry.Report {
+	known := map[string]bool{}
+	for _, o := range schema.Annotations() {
+		known[o.Name] = true
+	}
+
+	report := telemetry.Report{
+		Installation: installation,
+		Release:      version.RELEASE,
+		Annotations:  map[string]int{},
+		Modes:        n.modes(),
+	}
+
+	prefix := parser.AnnotationsPrefix + "/"
+	for _, obj := range n.listers.Ingress.List() {
+		ing := obj.(*networking.Ingress)
+		if !class.IsValid(ing) {
+			continue
+		}
+		report.Scale.Ingresses++
+
+		for key := range ing.Annotations {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(key, prefix)
+			if !known[key] {
+				name = unknownAnnotation
+			}
+			report.Annotations[name]++
+		}
+	}
+
+	n.runningConfigLock.RLock()
+	cfg := n.runningConfig
+	n.runningConfigLock.RUnlock()
+	if cfg != nil {
+		for _, server := range cfg.Servers {
+			if server.Hostname != defServerName {
+				report.Scale.Hosts++
+			}
+		}
+		report.Scale.Backends = len(cfg.Backends)
+	}
+	return report
+}
+
+// newTelemetryReporter returns the reporter of the usage of the features,
+// identifying the cluster by the random ID in the installation ConfigMap
+// of the namespace of the controller
+func (n *NGINXController) newTelemetryReporter() *telemetry.Reporter {
+	installation, err := telemetry.LoadInstallationID(context.TODO(), n.cfg.Client, podReference().Namespace, telemetry.InstallationConfigMap)
+	if err != nil {
+		glog.Warningf("unexpected error reading the installation ID of the telemetry: %v", err)
+	}
+
+	return &telemetry.Reporter{
+		Endpoint: n.cfg.TelemetryEndpoint,
+		Interval: n.cfg.TelemetryInterval,
+		Collect: func() telemetry.Report {
+			return n.telemetryReport(installation)
+		},
+	}
+}
+
+// TelemetryReport returns the report sent to the telemetry endpoint, nil
+// if the telemetry is disabled
+func (n *NGINXController) TelemetryReport() *telemetry.Report {
+	if n.telemetry == nil {
+		return nil
+	}
+	report := n.telemetry.Report()
+	return &report
+}
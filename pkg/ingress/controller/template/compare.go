@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package template
+
+import (
+	"bytes"
+	"sort"
+)
+
+// maxSamples is the maximum number of differing lines kept in a Comparison
+const maxSamples = 10
+
+// Comparison contains the differences between the configurations rendered
+// by two templates for the same model
+type Comparison struct {
+	// FirstLine is the first line, starting at 1, where the configurations
+	// differ. Zero if they are equal.
+	FirstLine int
+	// Removed and Added are the number of lines only present in the first
+	// and in the second configuration, regardless of their order
+	Removed int
+	Added   int
+	// Samples contains some of the removed lines, prefixed with -, and the
+	// added lines, prefixed with +
+	Samples []string
+}
+
+// Equal returns true if the configurations are the same
+func (c Comparison) Equal() bool {
+	return c.FirstLine == 0
+}
+
+// Compare returns the differences between two configurations. The lines
+// are compared as multisets, in linear time for the large configurations,
+// so a line moved to another position is only reported in FirstLine.
+func Compare(a, b []byte) Comparison {
+	if bytes.Equal(a, b) {
+		return Comparison{}
+	}
+
+	linesA := bytes.Split(a, []byte("\n"))
+	linesB := bytes.Split(b, []byte("\n"))
+
+	c := Comparison{}
+	for i := 0; ; i++ {
+		if i >= len(linesA) || i >= len(linesB) || !bytes.Equal(linesA[i], linesB[i]) {
+			c.FirstLine = i + 1
+			break
+		}
+	}
+
+	count := map[string]int{}
+	for _, l := range linesA {
+		count[string(l)]++
+	}
+	for _, l := range linesB {
+		count[string(l)]--
+	}
+
+	var removed, added []string
+	for line, n := range count {
+		for ; n > 0; n-- {
+			c.Removed++
+			removed = append(removed, "-"+line)
+		}
+		for ; n < 0; n++ {
+			c.Added++
+			added = append(added, "+"+line)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	for _, samples := range [][]string{removed, added} {
+		for _, s := range samples {
+			if len(c.Samples) == maxSamples {
+				break
+			}
+			c.Samples = append(c.Samples, s)
+		}
+	}
+	return c
+}
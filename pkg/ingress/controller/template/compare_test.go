@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package template
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	active := []byte("http {\n    gzip on;\n    server {\n        listen 443;\n    }\n}\n")
+
+	if c := Compare(active, active); !c.Equal() {
+		t.Errorf("expected the same configuration but returned %+v", c)
+	}
+
+	next := []byte("http {\n    server {\n        listen 443;\n        http2 on;\n    }\n    gzip on;\n    gzip_types text/css;\n}\n")
+	expected := Comparison{
+		FirstLine: 2,
+		Removed:   0,
+		Added:     2,
+		Samples:   []string{"+        http2 on;", "+    gzip_types text/css;"},
+	}
+	if c := Compare(active, next); !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, c)
+	}
+
+	expected = Comparison{
+		FirstLine: 2,
+		Removed:   2,
+		Added:     0,
+		Samples:   []string{"-        http2 on;", "-    gzip_types text/css;"},
+	}
+	if c := Compare(next, active); !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, c)
+	}
+
+	if c := Compare(active, append(active, "\n"...)); c.Equal() || c.FirstLine != 8 || c.Added != 1 {
+		t.Errorf("expected a trailing difference at line 8 but returned %+v", c)
+	}
+}
@@ -20,14 +20,18 @@ limitations under the License.
 package template
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
 
 	"github.com/mitchellh/mapstructure"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/controller/config"
 	ing_net "github.com/stolostron/management-ingress/pkg/net"
@@ -41,10 +45,23 @@ const (
 	bindAddress          = "bind-address"
 	httpRedirectCode     = "http-redirect-code"
 	proxyStreamResponses = "proxy-stream-responses"
+	requestNormalization = "request-normalization"
+	changePasswordURL    = "change-password-url"
+	hostDefaults         = "host-defaults"
+	requestTiers         = "request-tiers"
+	requestClassifiers   = "request-classifiers"
+
+	// maxBannerLength is the maximum length of the banner of a host
+	maxBannerLength = 200
 )
 
 var (
-	validRedirectCodes = []int{301, 302, 307, 308}
+	validRedirectCodes         = []int{301, 302, 307, 308}
+	validRequestNormalizations = []string{"off", "permissive", "strict"}
+
+	headerNameRegex  = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	tierNameRegex    = regexp.MustCompile(`^[a-z0-9-]+$`)
+	bannerColorRegex = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
 )
 
 // ReadConfig obtains the configuration defined by the user merged with the defaults.
@@ -124,12 +141,55 @@ func ReadConfig(src map[string]string) config.Configuration {
 		}
 	}
 
+	normalization := config.NewDefault().RequestNormalization
+	if val, ok := conf[requestNormalization]; ok {
+		delete(conf, requestNormalization)
+		if stringInSlice(val, validRequestNormalizations) {
+			normalization = val
+		} else {
+			glog.Warningf("%v is not a valid request normalization. Using the default.", val)
+		}
+	}
+
+	var passwordURL string
+	if val, ok := conf[changePasswordURL]; ok {
+		delete(conf, changePasswordURL)
+		if validRedirectURL(val) {
+			passwordURL = val
+		} else {
+			glog.Warningf("%v is not a valid change password URL, expected an HTTP or HTTPS URL or a path", val)
+		}
+	}
+
+	var defaults map[string]config.HostDefaults
+	if val, ok := conf[hostDefaults]; ok {
+		delete(conf, hostDefaults)
+		defaults = parseHostDefaults(val)
+	}
+
+	var tiers map[string]config.RequestTier
+	if val, ok := conf[requestTiers]; ok {
+		delete(conf, requestTiers)
+		tiers = parseRequestTiers(val)
+	}
+
+	var classifiers []config.RequestClassifier
+	if val, ok := conf[requestClassifiers]; ok {
+		delete(conf, requestClassifiers)
+		classifiers = parseRequestClassifiers(val)
+	}
+
 	to := config.NewDefault()
 	to.ProxyRealIPCIDR = proxylist
 	to.BindAddressIpv4 = bindAddressIpv4List
 	to.BindAddressIpv6 = bindAddressIpv6List
 	to.HTTPRedirectCode = redirectCode
 	to.ProxyStreamResponses = streamResponses
+	to.RequestNormalization = normalization
+	to.ChangePasswordURL = passwordURL
+	to.HostDefaults = defaults
+	to.RequestTiers = tiers
+	to.RequestClassifiers = classifiers
 
 	config := &mapstructure.DecoderConfig{
 		Metadata:         nil,
@@ -150,6 +210,123 @@ func ReadConfig(src map[string]string) config.Configuration {
 	return to
 }
 
+// parseHostDefaults returns the defaults of the hosts in the JSON value of
+// the ConfigMap. The invalid hosts, headers and banners are ignored.
+func parseHostDefaults(val string) map[string]config.HostDefaults {
+	var parsed map[string]config.HostDefaults
+	if err := json.Unmarshal([]byte(val), &parsed); err != nil {
+		glog.Warningf("%v is not valid: %v", hostDefaults, err)
+		return nil
+	}
+
+	defaults := make(map[string]config.HostDefaults, len(parsed))
+	for host, d := range parsed {
+		host = strings.ToLower(host)
+		if len(validation.IsDNS1123Subdomain(host)) > 0 && len(validation.IsWildcardDNS1123Subdomain(host)) > 0 {
+			glog.Warningf("ignoring the defaults of %v: it is not a hostname or a wildcard", host)
+			continue
+		}
+
+		headers := map[string]string{}
+		for name, value := range d.Headers {
+			if !headerNameRegex.MatchString(name) || strings.ContainsAny(value, "\"\\$") || strings.IndexFunc(value, isControl) >= 0 {
+				glog.Warningf("ignoring the header %v of %v: the name or the value is not valid", name, host)
+				continue
+			}
+			headers[name] = value
+		}
+		d.Headers = headers
+
+		if len(d.Banner) > maxBannerLength || strings.IndexFunc(d.Banner, isControl) >= 0 {
+			glog.Warningf("ignoring the banner of %v: it must be a line of up to %v characters", host, maxBannerLength)
+			d.Banner = ""
+		}
+		if d.BannerColor != "" && !bannerColorRegex.MatchString(d.BannerColor) {
+			glog.Warningf("ignoring the banner color %v of %v, expected #rrggbb or a name", d.BannerColor, host)
+			d.BannerColor = ""
+		}
+		defaults[host] = d
+	}
+	return defaults
+}
+
+// parseRequestTiers returns the tiers in the JSON value of the ConfigMap.
+// The tiers with invalid names or negative limits are ignored.
+func parseRequestTiers(val string) map[string]config.RequestTier {
+	var parsed map[string]config.RequestTier
+	if err := json.Unmarshal([]byte(val), &parsed); err != nil {
+		glog.Warningf("%v is not valid: %v", requestTiers, err)
+		return nil
+	}
+
+	tiers := make(map[string]config.RequestTier, len(parsed))
+	for name, tier := range parsed {
+		if !tierNameRegex.MatchString(name) {
+			glog.Warningf("ignoring the request tier %v: the name must contain lowercase letters, digits and -", name)
+			continue
+		}
+		if tier.Rate < 0 || tier.Burst < 0 || tier.MaxConcurrent < 0 || tier.QueueTimeout < 0 {
+			glog.Warningf("ignoring the request tier %v: the limits can not be negative", name)
+			continue
+		}
+		tiers[name] = tier
+	}
+	return tiers
+}
+
+// parseRequestClassifiers returns the classifiers in the JSON value of the
+// ConfigMap. The classifiers with invalid tiers, methods or paths are
+// ignored.
+func parseRequestClassifiers(val string) []config.RequestClassifier {
+	var parsed []config.RequestClassifier
+	if err := json.Unmarshal([]byte(val), &parsed); err != nil {
+		glog.Warningf("%v is not valid: %v", requestClassifiers, err)
+		return nil
+	}
+
+	var classifiers []config.RequestClassifier
+	for i, c := range parsed {
+		if !tierNameRegex.MatchString(c.Tier) {
+			glog.Warningf("ignoring the request classifier %v: the tier %q is not valid", i, c.Tier)
+			continue
+		}
+		valid := true
+		for j, method := range c.Methods {
+			c.Methods[j] = strings.ToUpper(method)
+			valid = valid && headerNameRegex.MatchString(method)
+		}
+		if _, err := regexp.Compile(c.Path); err != nil || strings.IndexFunc(c.Path, isControl) >= 0 {
+			valid = false
+		}
+		if !valid {
+			glog.Warningf("ignoring the request classifier %v: the methods or the path %q are not valid", i, c.Path)
+			continue
+		}
+		classifiers = append(classifiers, c)
+	}
+	return classifiers
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// validRedirectURL returns true if the value is an absolute HTTP or HTTPS
+// URL or a path that can be used in a return directive
+func validRedirectURL(val string) bool {
+	if strings.ContainsAny(val, " \t\n\"'$;{}\\") {
+		return false
+	}
+	u, err := url.Parse(val)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(val, "/") && !strings.HasPrefix(val, "//")
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func filterErrors(codes []int) []int {
 	var fa []int
 	for _, code := range codes {
@@ -171,3 +348,13 @@ func intInSlice(i int, list []int) bool {
 	}
 	return false
 }
+
+func stringInSlice(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package template
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/kylelemons/godebug/pretty"
@@ -54,6 +55,7 @@ func TestMergeConfigMapToStruct(t *testing.T) {
 	def.ErrorLogPath = "/var/log/test/error.log"
 	def.EnableDynamicTLSRecords = false
 	def.UseProxyProtocol = true
+	def.UseGzip = true
 	def.GzipTypes = "text/html"
 	def.ProxyRealIPCIDR = []string{"1.1.1.1/8", "2.2.2.2/24"}
 	def.BindAddressIpv4 = []string{"1.1.1.1", "2.2.2.2"}
@@ -85,3 +87,97 @@ func TestDefaultLoadBalance(t *testing.T) {
 		t.Errorf("default load balance algorithm wrong")
 	}
 }
+
+func TestAutotunedOverride(t *testing.T) {
+	conf := map[string]string{
+		"worker-processes":            "3",
+		"map-hash-bucket-size":        "256",
+		"client-body-buffer-size":     "32k",
+		"lua-shared-dict-tokens-size": "2m",
+		"lua-socket-pool-size":        "8",
+		"lua-regex-cache-max-entries": "100",
+		"max-worker-connections":      "2048",
+	}
+	to := ReadConfig(conf)
+	if to.WorkerProcesses != "3" || to.MapHashBucketSize != 256 || to.ClientBodyBufferSize != "32k" ||
+		to.LuaSharedDictTokensSize != "2m" || to.LuaSocketPoolSize != 8 || to.LuaRegexCacheMaxEntries != 100 ||
+		to.MaxWorkerConnections != 2048 {
+		t.Errorf("expected the ConfigMap values to override the autotuned ones but returned %+v", to)
+	}
+}
+
+func TestRequestNormalization(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"strict", "strict"},
+		{"off", "off"},
+		{"paranoid", "permissive"},
+	}
+
+	for _, tc := range testCases {
+		to := ReadConfig(map[string]string{"request-normalization": tc.value, "max-request-headers": "50"})
+		if to.RequestNormalization != tc.expected {
+			t.Errorf("expected %v for %v but returned %v", tc.expected, tc.value, to.RequestNormalization)
+		}
+		if to.MaxRequestHeaders != 50 {
+			t.Errorf("expected 50 headers but returned %v", to.MaxRequestHeaders)
+		}
+	}
+
+	if to := ReadConfig(map[string]string{}); to.RequestNormalization != "permissive" {
+		t.Errorf("expected permissive by default but returned %v", to.RequestNormalization)
+	}
+}
+
+func TestChangePasswordURL(t *testing.T) {
+	testCases := map[string]string{
+		"https://oauth.example.com/password": "https://oauth.example.com/password",
+		"/account/password":                  "/account/password",
+		"//evil.example.com":                 "",
+		"javascript:alert(1)":                "",
+		"/password; return 200 x":            "",
+	}
+	for value, expected := range testCases {
+		if to := ReadConfig(map[string]string{"change-password-url": value}); to.ChangePasswordURL != expected {
+			t.Errorf("expected %q for %v but returned %q", expected, value, to.ChangePasswordURL)
+		}
+	}
+}
+
+func TestHostDefaults(t *testing.T) {
+	to := ReadConfig(map[string]string{"host-defaults": `{
+		"Console.example.com": {"headers": {"X-Environment": "prod", "X-Bad": "a\"; return 200 \"b", "Bad Name": "x"}, "banner": "Production", "bannerColor": "red;"},
+		"*.apps.example.com": {"banner": "Staging", "bannerColor": "#ffa500"},
+		"not a host": {"banner": "ignored"}
+	}`})
+
+	expected := map[string]config.HostDefaults{
+		"console.example.com": {Headers: map[string]string{"X-Environment": "prod"}, Banner: "Production"},
+		"*.apps.example.com":  {Headers: map[string]string{}, Banner: "Staging", BannerColor: "#ffa500"},
+	}
+	if !reflect.DeepEqual(to.HostDefaults, expected) {
+		t.Errorf("expected %v but returned %v", expected, to.HostDefaults)
+	}
+
+	if to := ReadConfig(map[string]string{"host-defaults": "not json"}); to.HostDefaults != nil {
+		t.Errorf("expected no defaults but returned %v", to.HostDefaults)
+	}
+}
+
+func TestRequestTiers(t *testing.T) {
+	to := ReadConfig(map[string]string{
+		"request-tiers":       `{"api-read": {"rate": 50, "burst": 100}, "API": {"rate": 1}, "auth": {"maxConcurrent": -1}}`,
+		"request-classifiers": `[{"tier": "api-read", "methods": ["get"]}, {"tier": "static", "path": "("}, {"tier": "auth", "path": "^/oauth"}]`,
+	})
+
+	tiers := map[string]config.RequestTier{"api-read": {Rate: 50, Burst: 100}}
+	if !reflect.DeepEqual(to.RequestTiers, tiers) {
+		t.Errorf("expected %v but returned %v", tiers, to.RequestTiers)
+	}
+	classifiers := []config.RequestClassifier{{Tier: "api-read", Methods: []string{"GET"}}, {Tier: "auth", Path: "^/oauth"}}
+	if !reflect.DeepEqual(to.RequestClassifiers, classifiers) {
+		t.Errorf("expected %v but returned %v", classifiers, to.RequestClassifiers)
+	}
+}
@@ -17,23 +17,40 @@ limitations under the License.
 package template
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	text_template "text/template"
 
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
 
 	"github.com/stolostron/management-ingress/pkg/file"
 	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/accesslog"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/breakglass"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/budget"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/customcounters"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/grouprouting"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/locale"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/profile"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/tlsheaders"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/websocket"
 	"github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+	"github.com/stolostron/management-ingress/pkg/ingress/oidcpolicy"
 	ing_net "github.com/stolostron/management-ingress/pkg/net"
 )
 
@@ -128,6 +145,26 @@ var (
 		"buildForwardedFor":     buildForwardedFor,
 		"formatIP":              formatIP,
 		"getIngressInformation": getIngressInformation,
+		"budgetTimeout":         budgetTimeout,
+		"idleTimeout":           idleTimeout,
+		"profileTimeout":        profileTimeout,
+		"requestTimeout":        requestTimeout,
+		"buildLuaList":          buildLuaList,
+		"buildCustomCounters":   buildCustomCounters,
+		"buildOIDCPolicies":     buildOIDCPolicies,
+		"buildGroupRoutes":      buildGroupRoutes,
+		"buildTLSHeaders":       buildTLSHeaders,
+		"needsClientCert":       needsClientCert,
+		"locationBackend":       locationBackend,
+		"serviceMeshHost":       serviceMeshHost,
+		"buildAccessLog":        buildAccessLog,
+		"accessLogSamples":      accessLogSamples,
+		"accessLogSampleVar":    accessLogSampleVar,
+		"buildLocaleMaps":       buildLocaleMaps,
+		"buildWellKnown":        buildWellKnown,
+		"buildServerHeaders":    buildServerHeaders,
+		"buildBanner":           buildBanner,
+		"buildRequestTiers":     buildRequestTiers,
 		"serverConfig": func(all config.TemplateConfig, server *ingress.Server) interface{} {
 			return struct{ First, Second interface{} }{all, server}
 		},
@@ -148,6 +185,456 @@ func formatIP(input string) string {
 	return fmt.Sprintf("[%s]", input)
 }
 
+// budgetTimeout returns the proxy timeout in seconds capped to the latency
+// budget of the location, rounded up to whole seconds
+func budgetTimeout(timeout int, b budget.Config) int {
+	if b.Latency <= 0 {
+		return timeout
+	}
+	if max := (b.Latency + 999) / 1000; max < timeout {
+		return max
+	}
+	return timeout
+}
+
+// idleTimeout returns the idle timeout of the location, if set, in place
+// of the proxy timeout
+func idleTimeout(timeout int, ws websocket.Config) int {
+	if ws.IdleTimeout > 0 {
+		return ws.IdleTimeout
+	}
+	return timeout
+}
+
+// profileTimeout returns the proxy timeout extended to the minimum
+// timeout of the profile of the location
+func profileTimeout(timeout int, p profile.Config) int {
+	if p.Timeout > timeout {
+		return p.Timeout
+	}
+	return timeout
+}
+
+// requestTimeout returns the time in milliseconds the location waits for
+// the response of a request: the latency budget or the proxy read timeout
+func requestTimeout(loc *ingress.Location) int {
+	timeout := profileTimeout(loc.Proxy.ReadTimeout, loc.Profile) * 1000
+	if loc.Budget.Latency > 0 && loc.Budget.Latency < timeout {
+		return loc.Budget.Latency
+	}
+	return timeout
+}
+
+// buildLuaList returns the Lua table with the quoted values
+func buildLuaList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return fmt.Sprintf("{%v}", strings.Join(quoted, ", "))
+}
+
+// buildOIDCPolicies returns the Lua table with the rules of the
+// OIDCRoutePolicies of a location
+func buildOIDCPolicies(rules []oidcpolicy.Rule) string {
+	defs := make([]string, 0, len(rules))
+	for _, r := range rules {
+		claims := make([]string, 0, len(r.ForwardClaims))
+		for _, c := range r.ForwardClaims {
+			claims = append(claims, fmt.Sprintf("{claim = %q, header = %q}", c.Claim, c.Header))
+		}
+		def := fmt.Sprintf("policy = %q, path = %q, audiences = %v, scopes = %v, claims = {%v}",
+			r.Policy, r.Path, buildLuaList(r.Audiences), buildLuaList(r.Scopes), strings.Join(claims, ", "))
+		if r.Reject {
+			def += ", reject = true"
+		}
+		defs = append(defs, fmt.Sprintf("{%v}", def))
+	}
+	return fmt.Sprintf("{%v}", strings.Join(defs, ", "))
+}
+
+// buildGroupRoutes returns the Lua table with the group routes of a
+// location. The routes without an upstream keep the backend of the location.
+func buildGroupRoutes(c grouprouting.Config) string {
+	routes := make([]string, 0, len(c.Routes))
+	for _, r := range c.Routes {
+		fields := []string{fmt.Sprintf("group = %q", r.Group)}
+		switch {
+		case r.Deny:
+			fields = append(fields, "deny = true")
+		case r.Upstream != "":
+			fields = append(fields, fmt.Sprintf("upstream = %q", r.Upstream))
+		}
+		routes = append(routes, fmt.Sprintf("{%v}", strings.Join(fields, ", ")))
+	}
+	return fmt.Sprintf("{%v}", strings.Join(routes, ", "))
+}
+
+// buildCustomCounters returns the Lua table with the counter definitions
+func buildCustomCounters(counters []customcounters.Counter) string {
+	defs := make([]string, 0, len(counters))
+	for _, c := range counters {
+		fields := []string{fmt.Sprintf("name = %q", c.Name)}
+		if c.Status != "" {
+			fields = append(fields, fmt.Sprintf("status = %q", c.Status))
+		}
+		if c.Method != "" {
+			fields = append(fields, fmt.Sprintf("method = %q", c.Method))
+		}
+		if c.Header != "" {
+			fields = append(fields, fmt.Sprintf("header = %q", c.Header))
+		}
+		defs = append(defs, fmt.Sprintf("{%v}", strings.Join(fields, ", ")))
+	}
+	return fmt.Sprintf("{%v}", strings.Join(defs, ", "))
+}
+
+// accessLogPercent returns the percentage of the requests logged by a
+// sampled access log, like 0.5 or 10
+func accessLogPercent(c accesslog.Config) string {
+	percent := strconv.FormatFloat(c.Sample*100, 'f', 2, 64)
+	return strings.TrimSuffix(strings.TrimRight(percent, "0"), ".")
+}
+
+// accessLogSamples returns the percentages of the sampled access logs of
+// the locations, sorted and without duplicates
+func accessLogSamples(servers []*ingress.Server) []string {
+	found := map[string]bool{}
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if location.AccessLog.Sampled() {
+				found[accessLogPercent(location.AccessLog)] = true
+			}
+		}
+	}
+
+	percents := make([]string, 0, len(found))
+	for percent := range found {
+		percents = append(percents, percent)
+	}
+	sort.Strings(percents)
+	return percents
+}
+
+// accessLogSampleVar returns the variable set to 1 for a percentage of the
+// requests
+func accessLogSampleVar(percent string) string {
+	return "$access_log_sample_" + strings.Replace(percent, ".", "_", -1)
+}
+
+// localeUpstreamVar returns the variable with the upstream of the locale
+// variant of a location
+func localeUpstreamVar(host, path string) string {
+	sum := sha1.Sum([]byte(host + path))
+	return "$locale_upstream_" + hex.EncodeToString(sum[:])[:8]
+}
+
+// localeMatch returns the regular expression of the map matching a value
+// of the header. The values of Accept-Language are matched with the first
+// language, with or without region, like fr in fr-CA,en;q=0.8.
+func localeMatch(header, value string) string {
+	if header == locale.AcceptLanguage {
+		return fmt.Sprintf(`"~*^%v(?:[-,;]|$)"`, value)
+	}
+	return fmt.Sprintf(`"~*^%v$"`, value)
+}
+
+// buildLocaleMaps returns a map per location with locale variants, setting
+// the upstream of the request from the value of the header. The requests
+// without a variant use the backend of the location. The variants without
+// an upstream, like a missing service, are ignored.
+func buildLocaleMaps(servers []*ingress.Server, b interface{}) string {
+	backends, ok := b.([]*ingress.Backend)
+	if !ok {
+		glog.Errorf("expected an '[]*ingress.Backend' type but %T was returned", b)
+		return ""
+	}
+
+	available := map[string]bool{}
+	for _, backend := range backends {
+		available[backend.Name] = backend.ClusterIP != ""
+	}
+
+	var maps []string
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if !location.Locale.Enabled() || location.Ingress == nil {
+				continue
+			}
+			header := strings.Replace(location.Locale.Header, "-", "_", -1)
+			lines := []string{
+				fmt.Sprintf("map $http_%v %v {", header, localeUpstreamVar(server.Hostname, location.Path)),
+				fmt.Sprintf("        default %q;", location.Backend),
+			}
+			namespace := location.Ingress.GetNamespace()
+			for _, variant := range location.Locale.Variants {
+				name := fmt.Sprintf("%v-%v-%v", namespace, variant.Service, variant.Port)
+				if !available[name] {
+					glog.Warningf("ignoring locale %v of location %v%v: upstream %v is not available",
+						variant.Value, server.Hostname, location.Path, name)
+					continue
+				}
+				lines = append(lines, fmt.Sprintf("        %v %q;", localeMatch(location.Locale.Header, variant.Value), name))
+			}
+			lines = append(lines, "    }")
+			maps = append(maps, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(maps, "\n    ")
+}
+
+// buildAccessLog returns the access_log directive of a location. The files
+// are created in the directory of the access log of the controller.
+func buildAccessLog(cfg config.Configuration, c accesslog.Config) string {
+	path := cfg.AccessLogPath
+	switch c.Destination {
+	case "":
+	case accesslog.Off:
+		return "access_log off;"
+	case accesslog.Stdout:
+		path = "/dev/stdout"
+	case accesslog.Stderr:
+		path = "/dev/stderr"
+	default:
+		path = filepath.Join(filepath.Dir(cfg.AccessLogPath), c.Destination+".log")
+	}
+
+	// the format set in the ConfigMap, or the default one of NGINX
+	if cfg.UpstreamLogFormat() != "" {
+		path += " upstreaminfo"
+	}
+	if c.Sampled() {
+		return fmt.Sprintf("access_log %v if=%v;", path, accessLogSampleVar(accessLogPercent(c)))
+	}
+	return fmt.Sprintf("access_log %v;", path)
+}
+
+// wellKnownDollar is the variable with a literal $, as the text of a
+// return directive expands the variables
+const wellKnownDollar = "well_known_dollar"
+
+// wellKnownText returns the quoted text of a return directive
+func wellKnownText(text string) string {
+	return `"` + strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"$", "${"+wellKnownDollar+"}",
+	).Replace(text) + `"`
+}
+
+// buildWellKnown returns the locations of the well-known paths of the
+// ConfigMap, except the paths with an exact location in the server
+func buildWellKnown(cfg config.Configuration, server *ingress.Server) string {
+	exact := map[string]bool{}
+	for _, location := range server.Locations {
+		if location.LocationModifier == "=" {
+			exact[location.Path] = true
+		}
+	}
+
+	var locations []string
+	add := func(path string, directives ...string) {
+		if exact[path] {
+			glog.Warningf("the well-known path %v is served by an Ingress of the server %v", path, server.Hostname)
+			return
+		}
+		locations = append(locations, fmt.Sprintf("location = %v {\n            %v\n        }", path, strings.Join(directives, "\n            ")))
+	}
+
+	if cfg.SecurityTxt != "" {
+		add("/.well-known/security.txt", "default_type text/plain;", "return 200 "+wellKnownText(cfg.SecurityTxt)+";")
+		add("/security.txt", "return 301 /.well-known/security.txt;")
+	}
+	if cfg.RobotsTxt != "" {
+		add("/robots.txt", "default_type text/plain;", "return 200 "+wellKnownText(cfg.RobotsTxt)+";")
+	}
+	if cfg.ChangePasswordURL != "" {
+		add("/.well-known/change-password", "return 302 "+cfg.ChangePasswordURL+";")
+	}
+	return strings.Join(locations, "\n\n        ")
+}
+
+// serverHeaders are the response headers of every server, unless the
+// defaults of the host replace them
+var serverHeaders = [][2]string{
+	{"X-Frame-Options", "SAMEORIGIN"},
+	{"X-Content-Type-Options", "nosniff"},
+	{"X-XSS-Protection", "1; mode=block"},
+	{"Strict-Transport-Security", "max-age=31536000; includeSubDomains"},
+}
+
+// defaultBannerColor is the background of the banners without a color
+const defaultBannerColor = "#c8102e"
+
+// defaultsOfHost returns the defaults of the ConfigMap for a hostname. The
+// hostname wins over the wildcard of its parent domain.
+func defaultsOfHost(cfg config.Configuration, hostname string) (config.HostDefaults, bool) {
+	hostname = strings.ToLower(hostname)
+	if d, ok := cfg.HostDefaults[hostname]; ok {
+		return d, true
+	}
+	if i := strings.Index(hostname, "."); i > 0 {
+		if d, ok := cfg.HostDefaults["*"+hostname[i:]]; ok {
+			return d, true
+		}
+	}
+	return config.HostDefaults{}, false
+}
+
+// buildServerHeaders returns the add_header directives of a server, with
+// the headers of the host defaults replacing the headers of every server
+func buildServerHeaders(cfg config.Configuration, server *ingress.Server) string {
+	d, _ := defaultsOfHost(cfg, server.Hostname)
+	headers := map[string]string{}
+	for name, value := range d.Headers {
+		headers[strings.ToLower(name)] = value
+	}
+
+	var lines []string
+	for _, h := range serverHeaders {
+		value := h[1]
+		if v, ok := headers[strings.ToLower(h[0])]; ok {
+			value = v
+			delete(headers, strings.ToLower(h[0]))
+		}
+		lines = append(lines, fmt.Sprintf("add_header %v %q;", h[0], value))
+	}
+
+	var extra []string
+	for name := range d.Headers {
+		if _, ok := headers[strings.ToLower(name)]; ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		lines = append(lines, fmt.Sprintf("add_header %v %q;", name, d.Headers[name]))
+	}
+	return strings.Join(lines, "\n        ")
+}
+
+// buildBanner returns the directives that insert the banner of the host
+// defaults after the head of the HTML responses, where the browsers start
+// the body. The sub_filter of ngx_http_sub_module, built in the image,
+// only replaces strings, ignoring the case.
+func buildBanner(cfg config.Configuration, server *ingress.Server) string {
+	d, ok := defaultsOfHost(cfg, server.Hostname)
+	if !ok || d.Banner == "" {
+		return ""
+	}
+	color := d.BannerColor
+	if color == "" {
+		color = defaultBannerColor
+	}
+
+	text := strings.NewReplacer("$", "&#36;", "'", "&#39;").Replace(html.EscapeString(d.Banner))
+	return fmt.Sprintf(`proxy_set_header Accept-Encoding "";
+            sub_filter_types text/html;
+            sub_filter_once on;
+            sub_filter '</head>' '</head><div style="position:sticky;top:0;z-index:2147483647;padding:4px;text-align:center;font:bold 14px sans-serif;color:#fff;background:%v">%v</div>';`,
+		color, text)
+}
+
+// buildRequestTiers returns the Lua tables with the request tiers and the
+// classifiers of the ConfigMap, or the default classifiers
+func buildRequestTiers(cfg config.Configuration) string {
+	names := make([]string, 0, len(cfg.RequestTiers))
+	for name := range cfg.RequestTiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tiers := make([]string, 0, len(names))
+	for _, name := range names {
+		t := cfg.RequestTiers[name]
+		tiers = append(tiers, fmt.Sprintf("[%q] = { rate = %v, burst = %v, max_concurrent = %v, queue_timeout = %v, priority = %v }",
+			name, t.Rate, t.Burst, t.MaxConcurrent, t.QueueTimeout, t.Priority))
+	}
+
+	classifiers := cfg.RequestClassifiers
+	if len(classifiers) == 0 {
+		classifiers = config.DefaultRequestClassifiers
+	}
+	defs := make([]string, 0, len(classifiers))
+	for _, c := range classifiers {
+		fields := []string{fmt.Sprintf("tier = %q", c.Tier)}
+		if len(c.Methods) > 0 {
+			methods := make([]string, 0, len(c.Methods))
+			for _, m := range c.Methods {
+				methods = append(methods, fmt.Sprintf("[%q] = true", m))
+			}
+			fields = append(fields, fmt.Sprintf("methods = { %v }", strings.Join(methods, ", ")))
+		}
+		if c.Path != "" {
+			fields = append(fields, fmt.Sprintf("path = %q", c.Path))
+		}
+		defs = append(defs, fmt.Sprintf("{ %v }", strings.Join(fields, ", ")))
+	}
+
+	return fmt.Sprintf("{ %v }, { %v }", strings.Join(tiers, ", "), strings.Join(defs, ", "))
+}
+
+// tlsFingerprint sets $tls_fingerprint to a JA3 style hash of the TLS
+// version, ciphers and curves offered by the client
+const tlsFingerprint = `set_by_lua_block $tls_fingerprint { if not ngx.var.ssl_protocol then return "" end return ngx.md5(ngx.var.ssl_protocol .. "," .. (ngx.var.ssl_ciphers or "") .. "," .. (ngx.var.ssl_curves or "")) }`
+
+// buildTLSHeaders returns the directives that send the values of the TLS
+// connection to the backend
+func buildTLSHeaders(names []string) string {
+	var lines []string
+	for _, name := range names {
+		h, ok := tlsheaders.Headers[name]
+		if !ok {
+			continue
+		}
+		if name == "fingerprint" {
+			lines = append(lines, tlsFingerprint)
+		}
+		lines = append(lines, fmt.Sprintf("proxy_set_header %v %v;", h.Name, h.Variable))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// needsClientCert returns true if a location of the server sends
+// values of the client certificate to the backend or checks its
+// revocation
+func needsClientCert(server *ingress.Server) bool {
+	for _, loc := range server.Locations {
+		if tlsheaders.HasClientCertificate(loc.TLSHeaders) || loc.ClientCertRevocation.Enabled() ||
+			loc.BreakGlass.Method == breakglass.ClientCertificate {
+			return true
+		}
+	}
+	return false
+}
+
+// locationBackend returns the backend of the location, or an empty backend
+// if it is not found
+func locationBackend(backends []*ingress.Backend, loc *ingress.Location) *ingress.Backend {
+	for _, b := range backends {
+		if b.Name == loc.Backend {
+			return b
+		}
+	}
+	return &ingress.Backend{}
+}
+
+// serviceMeshHost returns the name of the Service of the backend in the
+// cluster domain with the port of the Service, used by the service mesh
+// sidecars to route the requests, or an empty string if it is not known
+func serviceMeshHost(b *ingress.Backend, domain string) string {
+	if b.Service == nil {
+		return ""
+	}
+	for _, p := range b.Service.Spec.Ports {
+		if (b.Port.Type == intstr.Int && p.Port == b.Port.IntVal) || (b.Port.Type == intstr.String && p.Name == b.Port.StrVal) {
+			return fmt.Sprintf("%v.%v.svc.%v:%v", b.Service.Name, b.Service.Namespace, domain, p.Port)
+		}
+	}
+	return ""
+}
+
 // buildResolvers returns the resolvers reading the /etc/resolv.conf file
 func buildResolvers(input interface{}) string {
 	// NGINX need IPV6 addresses to be surrounded by brackets
@@ -226,7 +713,20 @@ func buildSSLVeify(b interface{}, loc interface{}) string {
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
 			if backend.Secure {
-				if backend.SecureCACert.Secret == "" {
+				if backend.UpstreamIdentity.Enabled() {
+					// the chain and the DNS name of the SVIDs are verified on every
+					// connection, their SPIFFE IDs by the controller. Without an SVID
+					// of the controller no endpoint is balanced
+					if backend.SVID.CAFileName == "" || backend.Service == nil {
+						sslBlock = "proxy_ssl_verify off;"
+					} else {
+						sslBlock = fmt.Sprintf(`proxy_ssl_verify on;
+	    proxy_ssl_verify_depth 3;
+	    proxy_ssl_trusted_certificate %s;
+	    proxy_ssl_name %s;`, backend.SVID.CAFileName,
+							upstreamidentity.ServerName(backend.Service.Namespace, backend.Service.Name))
+					}
+				} else if backend.SecureCACert.Secret == "" {
 					sslBlock = "proxy_ssl_verify off;"
 				} else {
 					sslBlock = fmt.Sprintf("proxy_ssl_trusted_certificate %s;", backend.SecureCACert.CAFileName)
@@ -259,7 +759,14 @@ func buildClientCAAuth(b interface{}, loc interface{}) string {
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
 			if backend.Secure {
-				if backend.ClientCACert.Secret != "" {
+				if backend.UpstreamIdentity.Enabled() {
+					if backend.SVID.PemFileName != "" {
+						sslProxyBlock = fmt.Sprintf(`
+	    proxy_ssl_certificate %s;
+	    proxy_ssl_certificate_key %s;
+	    `, backend.SVID.PemFileName, backend.SVID.PemFileName)
+					}
+				} else if backend.ClientCACert.Secret != "" {
 					sslProxyBlock = fmt.Sprintf(`
 	    proxy_ssl_certificate %s;
 	    proxy_ssl_certificate_key %s;
@@ -295,6 +802,15 @@ func buildProxyPass(host string, b interface{}, loc interface{}) string {
 	proto := "http"
 
 	upstreamName := location.Backend
+	if location.Locale.Enabled() {
+		upstreamName = localeUpstreamVar(host, location.Path)
+	}
+	if location.Canary.Upstream != "" {
+		upstreamName = "$canary_upstream"
+	}
+	if location.GroupRouting.HasUpstreams() {
+		upstreamName = "$group_upstream"
+	}
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
 			if backend.Secure {
@@ -17,12 +17,36 @@ limitations under the License.
 package template
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
 	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/accesslog"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/budget"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/canary"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/certrevocation"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/customcounters"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/grouprouting"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/locale"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/profile"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/proxy"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/websocket"
+	"github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+	"github.com/stolostron/management-ingress/pkg/ingress/oidcpolicy"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
 )
 
@@ -115,6 +139,403 @@ func TestFormatIP(t *testing.T) {
 	}
 }
 
+func TestBudgetTimeout(t *testing.T) {
+	cases := map[string]struct {
+		Timeout int
+		Budget  budget.Config
+		Output  int
+	}{
+		"no-budget":     {60, budget.Config{}, 60},
+		"budget":        {60, budget.Config{Latency: 2000}, 2},
+		"rounded-up":    {60, budget.Config{Latency: 1500}, 2},
+		"below-timeout": {5, budget.Config{Latency: 30000}, 5},
+		"size-only":     {60, budget.Config{ResponseSize: 1024}, 60},
+		"sub-second":    {60, budget.Config{Latency: 100}, 1},
+	}
+	for k, tc := range cases {
+		res := budgetTimeout(tc.Timeout, tc.Budget)
+		if res != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, res)
+		}
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	if res := idleTimeout(60, websocket.Config{}); res != 60 {
+		t.Errorf("expected the proxy timeout but returned %v", res)
+	}
+	if res := idleTimeout(60, websocket.Config{IdleTimeout: 900}); res != 900 {
+		t.Errorf("expected the idle timeout but returned %v", res)
+	}
+}
+
+func TestProfileTimeout(t *testing.T) {
+	if res := profileTimeout(60, profile.Config{}); res != 60 {
+		t.Errorf("expected the proxy timeout but returned %v", res)
+	}
+	if res := profileTimeout(60, profile.Config{Name: profile.Upload, Timeout: 3600}); res != 3600 {
+		t.Errorf("expected the profile timeout but returned %v", res)
+	}
+	if res := profileTimeout(7200, profile.Config{Name: profile.Upload, Timeout: 3600}); res != 7200 {
+		t.Errorf("expected the longer proxy timeout but returned %v", res)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	cases := map[string]struct {
+		Location *ingress.Location
+		Output   int
+	}{
+		"proxy":         {&ingress.Location{Proxy: proxy.Config{ReadTimeout: 60}}, 60000},
+		"budget":        {&ingress.Location{Proxy: proxy.Config{ReadTimeout: 60}, Budget: budget.Config{Latency: 1500}}, 1500},
+		"profile":       {&ingress.Location{Proxy: proxy.Config{ReadTimeout: 60}, Profile: profile.Config{Name: profile.Upload, Timeout: 3600}}, 3600000},
+		"longer-budget": {&ingress.Location{Proxy: proxy.Config{ReadTimeout: 5}, Budget: budget.Config{Latency: 30000}}, 5000},
+	}
+	for k, tc := range cases {
+		res := requestTimeout(tc.Location)
+		if res != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, res)
+		}
+	}
+}
+
+func TestBuildLuaList(t *testing.T) {
+	if res := buildLuaList([]string{"legacy-auth", "sign"}); res != `{"legacy-auth", "sign"}` {
+		t.Errorf("expected a Lua table but returned %v", res)
+	}
+	if res := buildLuaList(nil); res != "{}" {
+		t.Errorf("expected an empty Lua table but returned %v", res)
+	}
+}
+
+func TestBuildCustomCounters(t *testing.T) {
+	counters := []customcounters.Counter{
+		{Name: "imports", Method: "POST", Status: "2"},
+		{Name: "tagged", Header: "x-tag"},
+	}
+	expected := `{{name = "imports", status = "2", method = "POST"}, {name = "tagged", header = "x-tag"}}`
+	if res := buildCustomCounters(counters); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+}
+
+func TestBuildOIDCPolicies(t *testing.T) {
+	rules := []oidcpolicy.Rule{
+		{Policy: "console", Path: "/api", Audiences: []string{"console"}, Scopes: []string{"openid", "console:read"},
+			ForwardClaims: []oidcpolicy.ClaimHeader{{Claim: "email", Header: "X-Forwarded-Email"}}},
+		{Policy: "platform", Path: "/", Reject: true},
+	}
+	expected := `{{policy = "console", path = "/api", audiences = {"console"}, scopes = {"openid", "console:read"}, ` +
+		`claims = {{claim = "email", header = "X-Forwarded-Email"}}}, {policy = "platform", path = "/", audiences = {}, scopes = {}, claims = {}, reject = true}}`
+	if res := buildOIDCPolicies(rules); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+}
+
+func TestBuildGroupRoutes(t *testing.T) {
+	c := grouprouting.Config{Routes: []grouprouting.Route{
+		{Group: "system:cluster-admins", Service: "admin-console", Port: 3000, Upstream: "default-admin-console-3000"},
+		{Group: "auditors", Deny: true},
+		{Group: "developers", Service: "dev-console", Port: 3000},
+	}}
+	expected := `{{group = "system:cluster-admins", upstream = "default-admin-console-3000"}, {group = "auditors", deny = true}, {group = "developers"}}`
+	if res := buildGroupRoutes(c); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+
+	loc := &ingress.Location{Path: "/", Backend: "default-console-3000", GroupRouting: c}
+	if pp := buildProxyPass("example.com", []*ingress.Backend{}, loc); !strings.Contains(pp, "proxy_pass http://$group_upstream;") {
+		t.Errorf("expected the upstream selected by the groups but returned %v", pp)
+	}
+}
+
+func TestBuildAccessLog(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.AccessLogPath = "/var/log/nginx/access.log"
+	if res := buildAccessLog(cfg, accesslog.Config{}); res != "access_log /var/log/nginx/access.log;" {
+		t.Errorf("expected the default format of NGINX but returned %v", res)
+	}
+
+	cfg.LogFormatUpstream = "$remote_addr [$cost_tag]"
+	testCases := map[string]struct {
+		c        accesslog.Config
+		expected string
+	}{
+		"default":        {accesslog.Config{}, "access_log /var/log/nginx/access.log upstreaminfo;"},
+		"off":            {accesslog.Config{Destination: accesslog.Off, Sample: 0.5}, "access_log off;"},
+		"stdout":         {accesslog.Config{Destination: accesslog.Stdout}, "access_log /dev/stdout upstreaminfo;"},
+		"file":           {accesslog.Config{Destination: "audit", Sample: 1}, "access_log /var/log/nginx/audit.log upstreaminfo;"},
+		"sampled":        {accesslog.Config{Sample: 0.1}, "access_log /var/log/nginx/access.log upstreaminfo if=$access_log_sample_10;"},
+		"sampled stderr": {accesslog.Config{Destination: accesslog.Stderr, Sample: 0.0025}, "access_log /dev/stderr upstreaminfo if=$access_log_sample_0_25;"},
+	}
+	for name, tc := range testCases {
+		if res := buildAccessLog(cfg, tc.c); res != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", name, tc.expected, res)
+		}
+	}
+
+	servers := []*ingress.Server{
+		{Locations: []*ingress.Location{
+			{AccessLog: accesslog.Config{Sample: 0.1}},
+			{AccessLog: accesslog.Config{Destination: "health", Sample: 0.0025}},
+			{AccessLog: accesslog.Config{Sample: 1}},
+		}},
+		{Locations: []*ingress.Location{{AccessLog: accesslog.Config{Sample: 0.1}}, {}}},
+	}
+	if res := accessLogSamples(servers); !reflect.DeepEqual(res, []string{"0.25", "10"}) {
+		t.Errorf("expected the percentages 0.25 and 10 but returned %v", res)
+	}
+}
+
+func TestBuildLocaleMaps(t *testing.T) {
+	ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "ns"}}
+	loc := &ingress.Location{
+		Path:    "/console",
+		Backend: "ns-console-3000",
+		Ingress: ing,
+		Locale: locale.Config{Header: locale.AcceptLanguage, Variants: []locale.Variant{
+			{Value: "fr", Service: "console-fr", Port: 3000},
+			{Value: "ja", Service: "console-ja", Port: 3000},
+		}},
+	}
+	servers := []*ingress.Server{{Hostname: "_", Locations: []*ingress.Location{loc, {Path: "/"}}}}
+	backends := []*ingress.Backend{
+		{Name: "ns-console-3000", ClusterIP: "10.0.0.1"},
+		{Name: "ns-console-fr-3000", ClusterIP: "10.0.0.2"},
+		{Name: "ns-console-ja-3000"},
+	}
+
+	variable := localeUpstreamVar("_", "/console")
+	expected := "map $http_accept_language " + variable + ` {
+        default "ns-console-3000";
+        "~*^fr(?:[-,;]|$)" "ns-console-fr-3000";
+    }`
+	if res := buildLocaleMaps(servers, backends); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+
+	loc.Locale.Header = "x-theme"
+	if res := buildLocaleMaps(servers, backends); !strings.Contains(res, `map $http_x_theme`) || !strings.Contains(res, `"~*^fr$"`) {
+		t.Errorf("expected an exact match of the custom header but returned %v", res)
+	}
+
+	if res := buildProxyPass("_", backends, loc); res != "proxy_pass http://"+variable+";" {
+		t.Errorf("expected the upstream of the locale but returned %v", res)
+	}
+}
+
+func TestBuildWellKnown(t *testing.T) {
+	cfg := config.Configuration{
+		SecurityTxt:       "Contact: mailto:security@example.com\nPolicy: \"https://example.com/$policy\"",
+		RobotsTxt:         "User-agent: *\nDisallow: /",
+		ChangePasswordURL: "https://oauth.example.com/password",
+	}
+	server := &ingress.Server{Hostname: "console.example.com", Locations: []*ingress.Location{
+		{Path: "/robots.txt", LocationModifier: "="},
+		{Path: "/"},
+	}}
+
+	res := buildWellKnown(cfg, server)
+	for _, expected := range []string{
+		`return 200 "Contact: mailto:security@example.com\nPolicy: \"https://example.com/${well_known_dollar}policy\"";`,
+		"location = /security.txt {\n            return 301 /.well-known/security.txt;",
+		"location = /.well-known/change-password {\n            return 302 https://oauth.example.com/password;",
+	} {
+		if !strings.Contains(res, expected) {
+			t.Errorf("expected %v in %v", expected, res)
+		}
+	}
+	if strings.Contains(res, "location = /robots.txt") {
+		t.Errorf("expected the exact location of the Ingress to be kept but returned %v", res)
+	}
+
+	if res := buildWellKnown(config.Configuration{}, server); res != "" {
+		t.Errorf("expected no location without configuration but returned %v", res)
+	}
+}
+
+func TestBuildServerHeaders(t *testing.T) {
+	cfg := config.Configuration{HostDefaults: map[string]config.HostDefaults{
+		"*.apps.example.com": {Headers: map[string]string{"x-frame-options": "DENY", "X-Environment": "production"}},
+		"console.apps.example.com": {
+			Headers: map[string]string{"Content-Security-Policy": "frame-ancestors 'self'"},
+			Banner:  "<Production> $cluster",
+		},
+	}}
+
+	expected := `add_header X-Frame-Options "DENY";
+        add_header X-Content-Type-Options "nosniff";
+        add_header X-XSS-Protection "1; mode=block";
+        add_header Strict-Transport-Security "max-age=31536000; includeSubDomains";
+        add_header X-Environment "production";`
+	if res := buildServerHeaders(cfg, &ingress.Server{Hostname: "grafana.apps.example.com"}); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+
+	console := &ingress.Server{Hostname: "console.apps.example.com"}
+	res := buildServerHeaders(cfg, console)
+	if !strings.Contains(res, `add_header X-Frame-Options "SAMEORIGIN";`) || !strings.Contains(res, `add_header Content-Security-Policy "frame-ancestors 'self'";`) {
+		t.Errorf("expected the defaults of the host to win over the wildcard but returned %v", res)
+	}
+
+	res = buildBanner(cfg, console)
+	for _, expected := range []string{"sub_filter_once on;", "sub_filter '</head>' '</head><div", "background:#c8102e", "&lt;Production&gt; &#36;cluster</div>"} {
+		if !strings.Contains(res, expected) {
+			t.Errorf("expected %v in %v", expected, res)
+		}
+	}
+	if res := buildBanner(cfg, &ingress.Server{Hostname: "grafana.apps.example.com"}); res != "" {
+		t.Errorf("expected no banner without one in the defaults but returned %v", res)
+	}
+}
+
+// builtinModuleDirectives are the directives of the banner and the
+// configure option of their module in the Dockerfile
+var builtinModuleDirectives = map[string]string{
+	"proxy_set_header": "",
+	"sub_filter":       "--with-http_sub_module",
+	"sub_filter_once":  "--with-http_sub_module",
+	"sub_filter_types": "--with-http_sub_module",
+}
+
+func TestBuildBannerDirectives(t *testing.T) {
+	cfg := config.Configuration{HostDefaults: map[string]config.HostDefaults{
+		"console.apps.example.com": {Banner: "PRODUCTION"},
+	}}
+	banner := buildBanner(cfg, &ingress.Server{Hostname: "console.apps.example.com"})
+
+	dockerfile, err := ioutil.ReadFile("../../../../Dockerfile")
+	if err != nil {
+		t.Fatalf("unexpected error reading the Dockerfile: %v", err)
+	}
+	for _, line := range strings.Split(banner, "\n") {
+		directive := strings.Fields(line)[0]
+		option, ok := builtinModuleDirectives[directive]
+		if !ok {
+			t.Errorf("expected only the directives of the modules of the image but got %v", directive)
+			continue
+		}
+		if !strings.Contains(string(dockerfile), option) {
+			t.Errorf("expected the module of %v to be built in the image with %v", directive, option)
+		}
+	}
+
+	// the configuration is tested with the NGINX binary when installed
+	nginx, err := exec.LookPath("nginx")
+	if err != nil {
+		t.Skip("nginx is not installed")
+	}
+	prefix, err := ioutil.TempDir("", "banner")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(prefix)
+	conf := fmt.Sprintf(`pid %v/nginx.pid;
+error_log %v/error.log;
+events {}
+http {
+    access_log off;
+    server {
+        listen 127.0.0.1:8080;
+        location / {
+            %v
+            proxy_pass http://127.0.0.1:8081;
+        }
+    }
+}
+`, prefix, prefix, banner)
+	if err := ioutil.WriteFile(filepath.Join(prefix, "nginx.conf"), []byte(conf), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out, err := exec.Command(nginx, "-t", "-p", prefix, "-c", filepath.Join(prefix, "nginx.conf")).CombinedOutput(); err != nil {
+		t.Errorf("expected a valid configuration but nginx -t returned %v: %s", err, out)
+	}
+}
+
+func TestBuildRequestTiers(t *testing.T) {
+	cfg := config.Configuration{
+		RequestTiers: map[string]config.RequestTier{
+			"static":    {Rate: 100, Burst: 200},
+			"api-write": {Rate: 0.5, MaxConcurrent: 20, QueueTimeout: 5, Priority: 1},
+		},
+		RequestClassifiers: []config.RequestClassifier{
+			{Tier: "static", Methods: []string{"GET"}, Path: `\.js$`},
+			{Tier: "api-write"},
+		},
+	}
+
+	expected := `{ ["api-write"] = { rate = 0.5, burst = 0, max_concurrent = 20, queue_timeout = 5, priority = 1 }, ` +
+		`["static"] = { rate = 100, burst = 200, max_concurrent = 0, queue_timeout = 0, priority = 0 } }, ` +
+		`{ { tier = "static", methods = { ["GET"] = true }, path = "\\.js$" }, { tier = "api-write" } }`
+	if res := buildRequestTiers(cfg); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+
+	cfg.RequestClassifiers = nil
+	if res := buildRequestTiers(cfg); !strings.Contains(res, `{ tier = "auth", path = `) {
+		t.Errorf("expected the default classifiers but returned %v", res)
+	}
+}
+
+func TestBuildTLSHeaders(t *testing.T) {
+	expected := "proxy_set_header X-TLS-SNI $ssl_server_name;\nproxy_set_header X-TLS-Client-Subject $ssl_client_s_dn;"
+	if res := buildTLSHeaders([]string{"sni", "client-subject"}); res != expected {
+		t.Errorf("expected %v but returned %v", expected, res)
+	}
+	if res := buildTLSHeaders([]string{"fingerprint"}); !strings.HasPrefix(res, "set_by_lua_block $tls_fingerprint") {
+		t.Errorf("expected the fingerprint to be set but returned %v", res)
+	}
+}
+
+func TestNeedsClientCert(t *testing.T) {
+	server := &ingress.Server{Locations: []*ingress.Location{{TLSHeaders: []string{"sni"}}}}
+	if needsClientCert(server) {
+		t.Errorf("expected no client certificate for %v", server.Locations[0].TLSHeaders)
+	}
+	server.Locations = append(server.Locations, &ingress.Location{TLSHeaders: []string{"client-fingerprint"}})
+	if !needsClientCert(server) {
+		t.Errorf("expected a client certificate for client-fingerprint")
+	}
+	server = &ingress.Server{Locations: []*ingress.Location{{ClientCertRevocation: certrevocation.Config{Secret: "default/cluster-ca"}}}}
+	if !needsClientCert(server) {
+		t.Errorf("expected a client certificate for the revocation checks")
+	}
+}
+
+func TestLocationBackend(t *testing.T) {
+	backends := []*ingress.Backend{
+		{Name: "search-api-8080", UpstreamHashBy: "$arg_cluster", HashLoadFactor: 1.25},
+		{Name: "console-ui-3000"},
+	}
+	if b := locationBackend(backends, &ingress.Location{Backend: "search-api-8080"}); b.HashLoadFactor != 1.25 {
+		t.Errorf("expected the backend of the location but returned %+v", b)
+	}
+	if b := locationBackend(backends, &ingress.Location{Backend: "missing-80"}); b == nil || b.Name != "" {
+		t.Errorf("expected an empty backend but returned %+v", b)
+	}
+}
+
+func TestServiceMeshHost(t *testing.T) {
+	svc := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "hub", Name: "search-api"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Name: "https", Port: 443}, {Name: "http", Port: 8080}}},
+	}
+	testCases := []struct {
+		backend  *ingress.Backend
+		expected string
+	}{
+		{&ingress.Backend{Service: svc, Port: intstr.FromInt(8080)}, "search-api.hub.svc.cluster.local:8080"},
+		{&ingress.Backend{Service: svc, Port: intstr.FromString("https")}, "search-api.hub.svc.cluster.local:443"},
+		{&ingress.Backend{Service: svc, Port: intstr.FromInt(80)}, ""},
+		{&ingress.Backend{Port: intstr.FromInt(80)}, ""},
+	}
+	for _, tc := range testCases {
+		if host := serviceMeshHost(tc.backend, "cluster.local"); host != tc.expected {
+			t.Errorf("expected %q but returned %q", tc.expected, host)
+		}
+	}
+}
+
 func TestBuildLocation(t *testing.T) {
 	for k, tc := range tmplFuncTestcases {
 		loc := &ingress.Location{
@@ -157,6 +578,22 @@ func TestBuildProxyPass(t *testing.T) {
 	}
 }
 
+func TestBuildProxyPassCanary(t *testing.T) {
+	loc := &ingress.Location{
+		Path:    "/",
+		Backend: "console-80",
+		Canary:  canary.Config{Service: "console-canary", Port: 80, Weight: 10},
+	}
+	if pp := buildProxyPass("example.com", []*ingress.Backend{}, loc); !strings.Contains(pp, "proxy_pass http://console-80;") {
+		t.Errorf("expected the backend without the upstream of the canary but returned %v", pp)
+	}
+
+	loc.Canary.Upstream = "default-console-canary-80"
+	if pp := buildProxyPass("example.com", []*ingress.Backend{}, loc); !strings.Contains(pp, "proxy_pass http://$canary_upstream;") {
+		t.Errorf("expected the upstream selected by the canary but returned %v", pp)
+	}
+}
+
 func TestBuildClientBodyBufferSize(t *testing.T) {
 	a := isValidClientBodyBufferSize("1000")
 	if a != true {
@@ -242,6 +679,21 @@ func TestBuildVerifySSL(t *testing.T) {
 	if sslBackend != validBackend {
 		t.Errorf("Expected '%v' but returned '%v'", validBackend, sslBackend)
 	}
+
+	// the SVIDs of the backends verifying SPIFFE IDs are verified on every connection
+	backends[0].UpstreamIdentity = upstreamidentity.Config{IDs: []string{"spiffe://example.org/api"}}
+	backends[0].Service = &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "hub", Name: "api"}}
+	if sslBackend := buildSSLVeify(backends, loc); sslBackend != validBackend {
+		t.Errorf("Expected '%v' without an X509-SVID but returned '%v'", validBackend, sslBackend)
+	}
+	backends[0].SVID = resolver.AuthSSLCert{CAFileName: "/ssl/spiffe-bundle.pem"}
+	validBackend = `proxy_ssl_verify on;
+	    proxy_ssl_verify_depth 3;
+	    proxy_ssl_trusted_certificate /ssl/spiffe-bundle.pem;
+	    proxy_ssl_name api.hub.svc;`
+	if sslBackend := buildSSLVeify(backends, loc); sslBackend != validBackend {
+		t.Errorf("Expected '%v' but returned '%v'", validBackend, sslBackend)
+	}
 }
 
 func TestBuildClientCAAuth(t *testing.T) {
@@ -272,4 +724,19 @@ func TestBuildClientCAAuth(t *testing.T) {
 	if sslBackend != validBackend {
 		t.Errorf("Expected '%v' but returned '%v'", validBackend, sslBackend)
 	}
+
+	// the X509-SVID is presented to the backends verifying SPIFFE IDs
+	backends[0].UpstreamIdentity = upstreamidentity.Config{IDs: []string{"spiffe://example.org/api"}}
+	backends[0].SVID = resolver.AuthSSLCert{PemFileName: "/ssl/spiffe-svid.pem"}
+	validBackend = `
+	    proxy_ssl_certificate /ssl/spiffe-svid.pem;
+	    proxy_ssl_certificate_key /ssl/spiffe-svid.pem;
+	    `
+	if sslBackend := buildClientCAAuth(backends, loc); sslBackend != validBackend {
+		t.Errorf("Expected '%v' but returned '%v'", validBackend, sslBackend)
+	}
+	backends[0].SVID = resolver.AuthSSLCert{}
+	if sslBackend := buildClientCAAuth(backends, loc); sslBackend != "" {
+		t.Errorf("Expected no certificate without an X509-SVID but returned '%v'", sslBackend)
+	}
 }
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+)
+
+// unixSocketUpstreamName returns the name of the upstream of the Unix
+// socket of an Ingress. The socket is not shared with other Ingresses.
+func unixSocketUpstreamName(ing *networking.Ingress) string {
+	return fmt.Sprintf("%v-%v-unix", ing.GetNamespace(), ing.GetName())
+}
+
+// unixSocketAllowed returns true if the path is in one of the directories
+// of --unix-socket-dir
+func (n *NGINXController) unixSocketAllowed(path string) bool {
+	for _, dir := range n.cfg.UnixSocketDirs {
+		if strings.HasPrefix(path, filepath.Clean(dir)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUnixSocket returns an error if the path is not a Unix socket in one
+// of the directories of --unix-socket-dir
+func (n *NGINXController) checkUnixSocket(path string) error {
+	if !n.unixSocketAllowed(path) {
+		return fmt.Errorf("the Unix socket %v is not in a directory of --unix-socket-dir", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("the Unix socket %v is not available: %v", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%v is not a Unix socket", path)
+	}
+	return nil
+}
+
+// createUnixSocketUpstream creates the upstream of the paths of an Ingress
+// with the unix-socket annotation. Without the socket the upstream has no
+// address, so the locations are not created until a later sync finds it.
+func (n *NGINXController) createUnixSocketUpstream(upstreams map[string]*ingress.Backend, ing *networking.Ingress, anns *annotations.Ingress) {
+	name := unixSocketUpstreamName(ing)
+	glog.V(3).Infof("creating upstream %v of the Unix socket %v", name, anns.UnixSocket.Path)
+
+	ups := newUpstream(name)
+	ups.Secure = anns.SecureUpstream.Secure
+	ups.SecureCACert = anns.SecureUpstream.CACert
+	ups.ClientCACert = anns.SecureUpstream.ClientCACert
+	upstreams[name] = ups
+
+	if err := n.checkUnixSocket(anns.UnixSocket.Path); err != nil {
+		glog.Warningf("ignoring the paths of ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		n.recorder.Eventf(ing, apiv1.EventTypeWarning, "UnixSocketUnavailable", err.Error())
+		return
+	}
+	ups.UnixSocket = anns.UnixSocket.Path
+}
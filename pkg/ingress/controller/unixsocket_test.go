@@ -0,0 +1,41 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sockets")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error listening on %v: %v", socket, err)
+	}
+	defer l.Close()
+	regular := filepath.Join(dir, "agent.conf")
+	if err := ioutil.WriteFile(regular, []byte{}, 0600); err != nil {
+		t.Fatalf("unexpected error writing %v: %v", regular, err)
+	}
+
+	n := &NGINXController{cfg: &Configuration{UnixSocketDirs: []string{dir + "/"}}}
+	if err := n.checkUnixSocket(socket); err != nil {
+		t.Errorf("unexpected error checking %v: %v", socket, err)
+	}
+	for _, path := range []string{regular, filepath.Join(dir, "missing.sock"), "/run/docker.sock"} {
+		if err := n.checkUnixSocket(path); err == nil {
+			t.Errorf("expected an error checking %v", path)
+		}
+	}
+}
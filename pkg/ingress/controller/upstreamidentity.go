@@ -0,0 +1,281 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+	"github.com/stolostron/management-ingress/pkg/ingress/spiffe"
+)
+
+const (
+	// svidFile and svidBundleFile are the files, in the SSL directory,
+	// with the X509-SVID of the controller and the bundle of its trust
+	// domain
+	svidFile       = "spiffe-svid.pem"
+	svidBundleFile = "spiffe-bundle.pem"
+
+	// identityDialTimeout is the timeout of the TLS handshake with an
+	// endpoint to verify its SPIFFE ID
+	identityDialTimeout = 5 * time.Second
+)
+
+// workloadIdentity keeps the X509-SVID of the controller, obtained from
+// the SPIFFE Workload API, and the endpoints of the backends with the
+// upstream-spiffe-id annotation whose SPIFFE ID was verified. NGINX
+// verifies on every connection the chain of the endpoint with the bundle of
+// the trust domain and the DNS name of the service in its SVID, but it can
+// not check URI SANs, so the controller verifies the SPIFFE IDs of the
+// endpoints and only the verified ones are balanced.
+type workloadIdentity struct {
+	dir string
+	// trigger requests a verification of the endpoints
+	trigger chan struct{}
+
+	mu    sync.Mutex
+	svid  *spiffe.SVID
+	files resolver.AuthSSLCert
+	// verified contains the verified endpoints as <backend> <endpoint>
+	verified map[string]bool
+}
+
+func newWorkloadIdentity(dir string) *workloadIdentity {
+	return &workloadIdentity{
+		dir:      dir,
+		trigger:  make(chan struct{}, 1),
+		verified: map[string]bool{},
+	}
+}
+
+// update writes the X509-SVID and the bundle in the SSL directory
+func (w *workloadIdentity) update(svid *spiffe.SVID) error {
+	content, err := svid.PEM()
+	if err != nil {
+		return err
+	}
+	bundle := svid.BundlePEM()
+
+	files := resolver.AuthSSLCert{
+		Secret:      svid.ID,
+		PemFileName: filepath.Join(w.dir, svidFile),
+		CAFileName:  filepath.Join(w.dir, svidBundleFile),
+	}
+	if err := writeFileAtomic(files.PemFileName, content, 0600); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(files.CAFileName, bundle, 0644); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(append(content, bundle...))
+	files.PemSHA = hex.EncodeToString(sum[:])
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.svid = svid
+	w.files = files
+	return nil
+}
+
+// writeFileAtomic writes a file NGINX can read at any time
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// current returns the X509-SVID and its files, nil before the Workload
+// API sends the first one
+func (w *workloadIdentity) current() (*spiffe.SVID, resolver.AuthSSLCert) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.svid, w.files
+}
+
+// refresh requests a verification of the endpoints without waiting for it
+func (w *workloadIdentity) refresh() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// allowed returns true if NGINX can send the requests of the backend to
+// the endpoint: the backend does not verify the SPIFFE IDs or the ID of
+// the endpoint was verified
+func (w *workloadIdentity) allowed(b *ingress.Backend, endpoint string) bool {
+	if !b.UpstreamIdentity.Enabled() {
+		return true
+	}
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.verified[b.Name+" "+endpoint]
+}
+
+// verify checks the SPIFFE IDs of the endpoints of the backends with the
+// upstream-spiffe-id annotation with a TLS handshake presenting the SVID
+// of the controller. It returns true if the verified endpoints changed.
+func (w *workloadIdentity) verify(ctx context.Context, backends []*ingress.Backend, endpoints func(string) (*apiv1.Endpoints, bool)) bool {
+	svid, _ := w.current()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	verified := map[string]bool{}
+	for _, b := range backends {
+		if !b.UpstreamIdentity.Enabled() || b.Service == nil || svid == nil {
+			continue
+		}
+		ep, ok := endpoints(fmt.Sprintf("%v/%v", b.Service.Namespace, b.Service.Name))
+		if !ok {
+			continue
+		}
+		for _, e := range backendEndpoints(b, ep) {
+			wg.Add(1)
+			go func(b *ingress.Backend, endpoint string) {
+				defer wg.Done()
+				name := upstreamidentity.ServerName(b.Service.Namespace, b.Service.Name)
+				if err := dialIdentity(ctx, endpoint, name, svid, b.UpstreamIdentity); err != nil {
+					glog.Warningf("excluding endpoint %v of backend %v: %v", endpoint, b.Name, err)
+					return
+				}
+				mu.Lock()
+				verified[b.Name+" "+endpoint] = true
+				mu.Unlock()
+			}(b, e)
+		}
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := len(verified) != len(w.verified)
+	for key := range verified {
+		if !w.verified[key] {
+			changed = true
+		}
+	}
+	w.verified = verified
+	return changed
+}
+
+// dialIdentity returns an error if the endpoint does not present an SVID
+// of the trust domain with one of the accepted SPIFFE IDs and the DNS name
+// NGINX verifies
+func dialIdentity(ctx context.Context, endpoint, name string, svid *spiffe.SVID, cfg upstreamidentity.Config) error {
+	verify, err := svid.VerifyPeer(cfg.IDs)
+	if err != nil {
+		return err
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{
+		Certificates: []tls.Certificate{svid.TLSCertificate()},
+		// the chain and the SPIFFE ID are verified by VerifyPeer
+		InsecureSkipVerify:    true, // #nosec G402
+		VerifyPeerCertificate: verify,
+		MinVersion:            tls.VersionTLS12,
+	}}
+	ctx, cancel := context.WithTimeout(ctx, identityDialTimeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	// #nosec
+	defer conn.Close()
+
+	leaf := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+	if err := leaf.VerifyHostname(name); err != nil {
+		return fmt.Errorf("the SVID does not have the DNS name %v verified by NGINX: %v", name, err)
+	}
+	return nil
+}
+
+// setUpstreamIdentity configures the verification of the SPIFFE IDs of an
+// upstream. The first Ingress with the annotation referencing the
+// upstream is used. The endpoints must accept TLS.
+func (n *NGINXController) setUpstreamIdentity(ups *ingress.Backend, cfg upstreamidentity.Config) {
+	if ups.UpstreamIdentity.Enabled() || !cfg.Enabled() {
+		return
+	}
+	ups.UpstreamIdentity = cfg
+	ups.Secure = true
+	if n.identity != nil {
+		_, ups.SVID = n.identity.current()
+	}
+}
+
+// hasUpstreamIdentity returns true if a backend of the configuration
+// verifies the SPIFFE IDs of its endpoints
+func hasUpstreamIdentity(cfg *ingress.Configuration) bool {
+	for _, b := range cfg.Backends {
+		if b.UpstreamIdentity.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// watchWorkloadIdentity streams the X509-SVIDs of the controller from the
+// Workload API. A new SVID changes the backends with the
+// upstream-spiffe-id annotation, so NGINX is reloaded with it.
+func (n *NGINXController) watchWorkloadIdentity(ctx context.Context) {
+	err := spiffe.Watch(ctx, n.cfg.SPIFFESocket, func(svid *spiffe.SVID) {
+		if err := n.identity.update(svid); err != nil {
+			glog.Errorf("unexpected error writing the X509-SVID %v: %v", svid.ID, err)
+			return
+		}
+		glog.Infof("received the X509-SVID %v valid until %v", svid.ID, svid.Certificates[0].NotAfter.Format(time.RFC3339))
+		n.syncQueue.Enqueue(&networking.Ingress{})
+		n.identity.refresh()
+	})
+	if err != nil {
+		glog.Errorf("unexpected error watching the SPIFFE Workload API: %v", err)
+	}
+}
+
+// runUpstreamIdentity verifies the SPIFFE IDs of the endpoints
+// periodically and when they change, and writes the verified ones in the
+// backend endpoints file
+func (n *NGINXController) runUpstreamIdentity(ctx context.Context) {
+	ticker := time.NewTicker(n.cfg.UpstreamIdentityInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.identity.trigger:
+		}
+
+		n.runningConfigLock.RLock()
+		backends := n.runningConfig.Backends
+		n.runningConfigLock.RUnlock()
+		if !n.identity.verify(ctx, backends, n.endpointsByKey) {
+			continue
+		}
+
+		n.runningConfigLock.RLock()
+		n.updateBalancedEndpoints(n.runningConfig)
+		n.runningConfigLock.RUnlock()
+	}
+}
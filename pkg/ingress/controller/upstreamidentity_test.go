@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+	"github.com/stolostron/management-ingress/pkg/ingress/spiffe"
+)
+
+// newSVID returns an SVID with the id and the DNS names issued by the CA, a
+// new one if ca is nil
+func newSVID(t *testing.T, ca *spiffe.SVID, id string, dnsNames ...string) *spiffe.SVID {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	u, _ := url.Parse(id)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{u},
+		DNSNames:     dnsNames,
+	}
+	if ca == nil {
+		tmpl.Subject = pkix.Name{CommonName: "spire"}
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return &spiffe.SVID{ID: id, Certificates: []*x509.Certificate{cert}, Key: key, Bundle: []*x509.Certificate{cert}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Certificates[0], key.Public(), ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &spiffe.SVID{ID: id, Certificates: []*x509.Certificate{cert}, Key: key, Bundle: ca.Bundle}
+}
+
+// serveSVID accepts TLS connections with the SVID, requiring a client
+// certificate, and returns the port
+func serveSVID(t *testing.T, svid *spiffe.SVID) int32 {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{svid.TLSCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    svid.BundlePool(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return int32(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestUpstreamIdentity(t *testing.T) {
+	ca := newSVID(t, nil, "spiffe://example.org")
+	api := serveSVID(t, newSVID(t, ca, "spiffe://example.org/ns/hub/sa/api", "api.hub.svc"))
+	other := serveSVID(t, newSVID(t, ca, "spiffe://example.org/ns/hub/sa/other", "api.hub.svc"))
+	foreign := serveSVID(t, newSVID(t, newSVID(t, nil, "spiffe://other.org"), "spiffe://example.org/ns/hub/sa/api", "api.hub.svc"))
+	unnamed := serveSVID(t, newSVID(t, ca, "spiffe://example.org/ns/hub/sa/api"))
+
+	svc := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "hub", Name: "api"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Name: "https", Port: 443}}},
+	}
+	var subsets []apiv1.EndpointSubset
+	for _, port := range []int32{api, other, foreign, unnamed} {
+		subsets = append(subsets, apiv1.EndpointSubset{
+			Addresses: []apiv1.EndpointAddress{{IP: "127.0.0.1"}},
+			Ports:     []apiv1.EndpointPort{{Name: "https", Port: port}},
+		})
+	}
+	lookup := func(key string) (*apiv1.Endpoints, bool) {
+		return &apiv1.Endpoints{Subsets: subsets}, key == "hub/api"
+	}
+	backends := []*ingress.Backend{{
+		Name:             "hub-api-443",
+		Service:          svc,
+		Port:             intstr.FromInt(443),
+		UpstreamIdentity: upstreamidentity.Config{IDs: []string{"spiffe://example.org/ns/hub/sa/api"}},
+	}}
+
+	// the endpoints are not balanced before they are verified
+	w := newWorkloadIdentity(t.TempDir())
+	content, _ := balancedEndpoints(backends, lookup, nil, w)
+	if len(content) != 0 {
+		t.Errorf("expected no endpoints before the verification but returned %q", content)
+	}
+	if w.verify(context.TODO(), backends, lookup) {
+		t.Errorf("expected no verified endpoints without an SVID")
+	}
+
+	if err := w.update(newSVID(t, ca, "spiffe://example.org/ns/management-ingress/sa/controller")); err != nil {
+		t.Fatal(err)
+	}
+	_, files := w.current()
+	if files.PemFileName != filepath.Join(w.dir, svidFile) || files.PemSHA == "" {
+		t.Errorf("unexpected SVID files %+v", files)
+	}
+	if b, err := ioutil.ReadFile(files.PemFileName); err != nil {
+		t.Fatal(err)
+	} else if _, err := tls.X509KeyPair(b, b); err != nil {
+		t.Errorf("expected a valid key pair in %v but returned %v", files.PemFileName, err)
+	}
+
+	if !w.verify(context.TODO(), backends, lookup) {
+		t.Errorf("expected the verified endpoints to change")
+	}
+	content, _ = balancedEndpoints(backends, lookup, nil, w)
+	expected := fmt.Sprintf("hub-api-443 127.0.0.1:%v\n", api)
+	if string(content) != expected {
+		t.Errorf("expected %q but returned %q", expected, content)
+	}
+	if w.verify(context.TODO(), backends, lookup) {
+		t.Errorf("expected the same verified endpoints")
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+)
+
+// ValidationReport is the result of validating Ingresses against the
+// running model without applying them
+type ValidationReport struct {
+	// Valid is false if any Ingress has errors
+	Valid     bool                `json:"valid"`
+	Ingresses []IngressValidation `json:"ingresses"`
+}
+
+// IngressValidation contains the problems found in an Ingress. Errors
+// would break or change the routing, warnings may be fixed by other
+// manifests applied at the same time, like a missing Service.
+type IngressValidation struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Ignored is true if the Ingress is not handled by this controller
+	Ignored  bool     `json:"ignored,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DecodeIngresses reads the Ingresses of a stream of YAML documents or JSON
+// objects, including the items of List objects. Other kinds are skipped.
+func DecodeIngresses(r io.Reader) ([]*networking.Ingress, error) {
+	var ings []*networking.Ingress
+
+	var decode func(raw json.RawMessage) error
+	decode = func(raw json.RawMessage) error {
+		var obj struct {
+			APIVersion string            `json:"apiVersion"`
+			Kind       string            `json:"kind"`
+			Items      []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+
+		switch {
+		case obj.Kind == "List" || obj.Kind == "IngressList":
+			for _, item := range obj.Items {
+				if err := decode(item); err != nil {
+					return err
+				}
+			}
+		case obj.Kind == "Ingress" && obj.APIVersion == networking.SchemeGroupVersion.String():
+			ing := &networking.Ingress{}
+			if err := json.Unmarshal(raw, ing); err != nil {
+				return err
+			}
+			ings = append(ings, ing)
+		}
+		return nil
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return ings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := decode(raw); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Validate checks the Ingresses against the running model and the cluster
+// state: the annotations, the TLS secrets, the backend Services and the
+// paths already served by other Ingresses. Nothing is applied.
+func (n *NGINXController) Validate(ings []*networking.Ingress) *ValidationReport {
+	report := &ValidationReport{Valid: true}
+
+	// owners contains the Ingress serving each host and path, of the
+	// running model and of the validated Ingresses
+	owners := map[string]string{}
+	n.runningConfigLock.RLock()
+	if n.runningConfig != nil {
+		for _, server := range n.runningConfig.Servers {
+			for _, loc := range server.Locations {
+				if loc.Ingress != nil {
+					owners[server.Hostname+loc.Path] = fmt.Sprintf("%v/%v", loc.Ingress.Namespace, loc.Ingress.Name)
+				}
+			}
+		}
+	}
+	n.runningConfigLock.RUnlock()
+
+	// validated Ingresses replace their running version
+	validated := map[string]bool{}
+	for _, ing := range ings {
+		validated[fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)] = true
+	}
+	for path, owner := range owners {
+		if validated[owner] {
+			delete(owners, path)
+		}
+	}
+
+	for _, ing := range ings {
+		v := n.validateIngress(ing, owners)
+		if len(v.Errors) > 0 {
+			report.Valid = false
+		}
+		report.Ingresses = append(report.Ingresses, v)
+	}
+	return report
+}
+
+func (n *NGINXController) validateIngress(ing *networking.Ingress, owners map[string]string) IngressValidation {
+	v := IngressValidation{
+		Namespace: ing.Namespace,
+		Name:      ing.Name,
+		Ignored:   !n.isValidIngress(ing),
+	}
+	if ing.Namespace == "" || ing.Name == "" {
+		v.Errors = append(v.Errors, "the namespace and name are required")
+		return v
+	}
+	if v.Ignored {
+		return v
+	}
+	key := fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+
+	anns := n.annotations.Extract(ing)
+	for _, err := range anns.Errors {
+		v.Errors = append(v.Errors, err.Error())
+	}
+	if anns.IPFamily.Serve == ipfamily.IPv6 && !n.ipv6Enabled() {
+		v.Errors = append(v.Errors, "the hosts can not be served on IPv6 addresses: IPv6 is not enabled")
+	}
+	for _, name := range anns.LuaFilters {
+		if !n.luaFilters.Has(name) {
+			v.Errors = append(v.Errors, fmt.Sprintf("the Lua filter %v is not in the signed bundle", name))
+		}
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		if err := n.validateTLSSecret(fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName), tls.Hosts, &v); err != nil {
+			v.Errors = append(v.Errors, err.Error())
+		}
+	}
+
+	if anns.UnixSocket.Path != "" {
+		if !n.unixSocketAllowed(anns.UnixSocket.Path) {
+			v.Errors = append(v.Errors, fmt.Sprintf("the Unix socket %v is not in a directory of --unix-socket-dir", anns.UnixSocket.Path))
+		} else if err := n.checkUnixSocket(anns.UnixSocket.Path); err != nil {
+			v.Warnings = append(v.Warnings, err.Error())
+		}
+	}
+	if ing.Spec.DefaultBackend != nil {
+		n.validateBackend(ing.Namespace, ing.Spec.DefaultBackend, anns.IPFamily.Upstream, &v)
+	}
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if anns.UnixSocket.Path == "" {
+				n.validateBackend(ing.Namespace, &path.Backend, anns.IPFamily.Upstream, &v)
+			}
+
+			nginxPath := rootLocation
+			if path.Path != "" {
+				nginxPath = path.Path
+			}
+			if owner, ok := owners[host+nginxPath]; ok && owner != key {
+				v.Errors = append(v.Errors, fmt.Sprintf("the path %v%v is already served by the ingress %v", host, nginxPath, owner))
+				continue
+			}
+			owners[host+nginxPath] = key
+		}
+	}
+
+	return v
+}
+
+// validateTLSSecret returns an error if the secret does not contain a valid
+// certificate, and adds a warning for the hosts it does not cover
+func (n *NGINXController) validateTLSSecret(name string, hosts []string, v *IngressValidation) error {
+	secret, err := n.listers.Secret.GetByName(name)
+	if err != nil {
+		return fmt.Errorf("the TLS secret %v is not available: %v", name, err)
+	}
+	if len(secret.Data[apiv1.TLSPrivateKeyKey]) == 0 {
+		return fmt.Errorf("the TLS secret %v has no %v", name, apiv1.TLSPrivateKeyKey)
+	}
+	block, _ := pem.Decode(secret.Data[apiv1.TLSCertKey])
+	if block == nil {
+		return fmt.Errorf("the TLS secret %v has no PEM certificate in %v", name, apiv1.TLSCertKey)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("the TLS secret %v has an invalid certificate: %v", name, err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("the certificate of the TLS secret %v expired on %v", name, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			v.Warnings = append(v.Warnings, fmt.Sprintf("the certificate of the TLS secret %v is not valid for %v", name, host))
+		}
+	}
+	return nil
+}
+
+// validateBackend adds a warning if the Service of the backend or its port
+// do not exist
+func (n *NGINXController) validateBackend(namespace string, backend *networking.IngressBackend, family string, v *IngressValidation) {
+	if backend.Service == nil {
+		return
+	}
+	name := fmt.Sprintf("%v/%v", namespace, backend.Service.Name)
+	svc, err := n.listers.Service.GetByName(name)
+	if err != nil {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("the service %v does not exist", name))
+		return
+	}
+	if family != "" && !hasIPFamily(svc, family) {
+		v.Errors = append(v.Errors, fmt.Sprintf("the service %v has no %v ClusterIP", name, family))
+	}
+
+	port := backend.Service.Port
+	for _, p := range svc.Spec.Ports {
+		if (port.Name != "" && p.Name == port.Name) || (port.Name == "" && p.Port == port.Number) {
+			return
+		}
+	}
+	v.Warnings = append(v.Warnings, fmt.Sprintf("the service %v has no port %v", name, servicePortName(port)))
+}
+
+func servicePortName(port networking.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprintf("%v", port.Number)
+}
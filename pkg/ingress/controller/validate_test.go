@@ -0,0 +1,123 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+)
+
+func TestDecodeIngresses(t *testing.T) {
+	manifests := `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: console
+  namespace: default
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: console
+---
+{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"name": "api", "namespace": "default"}}]}
+`
+	ings, err := DecodeIngresses(strings.NewReader(manifests))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ings) != 2 || ings[0].Name != "console" || ings[1].Name != "api" {
+		t.Errorf("expected the console and api Ingresses but returned %v", ings)
+	}
+
+	if _, err := DecodeIngresses(strings.NewReader("kind: [")); err == nil {
+		t.Errorf("expected an error decoding invalid YAML")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	running := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "running"}}
+
+	sl := &ingress.StoreLister{}
+	sl.Secret.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.Service.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	sl.Service.Add(&apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "console"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Name: "http", Port: 3000}}},
+	})
+
+	n := &NGINXController{
+		cfg:     &Configuration{},
+		listers: sl,
+		runningConfig: &ingress.Configuration{
+			Servers: []*ingress.Server{{
+				Hostname:  "example.com",
+				Locations: []*ingress.Location{{Path: "/api", Ingress: running}},
+			}},
+		},
+	}
+	n.annotations = annotations.NewAnnotationExtractor(n)
+
+	newIngress := func(name string, anns map[string]string, paths ...string) *networking.Ingress {
+		if anns == nil {
+			anns = map[string]string{}
+		}
+		anns[class.IngressKey] = class.IngressClass
+		ing := &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: anns},
+			Spec: networking.IngressSpec{Rules: []networking.IngressRule{{
+				Host:             "example.com",
+				IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{}},
+			}}},
+		}
+		for _, p := range paths {
+			ing.Spec.Rules[0].HTTP.Paths = append(ing.Spec.Rules[0].HTTP.Paths, networking.HTTPIngressPath{
+				Path: p,
+				Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+					Name: "console",
+					Port: networking.ServiceBackendPort{Name: "http"},
+				}},
+			})
+		}
+		return ing
+	}
+
+	valid := newIngress("console", nil, "/console")
+	report := n.Validate([]*networking.Ingress{valid})
+	if !report.Valid || len(report.Ingresses[0].Errors) != 0 || len(report.Ingresses[0].Warnings) != 0 {
+		t.Errorf("expected a valid report but returned %+v", report)
+	}
+
+	conflict := newIngress("conflict", nil, "/api")
+	duplicated := newIngress("duplicated", nil, "/console")
+	invalid := newIngress("invalid", map[string]string{"ingress.open-cluster-management.io/proxy-read-timeout": "soon"})
+	tls := newIngress("tls", nil)
+	tls.Spec.TLS = []networking.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "missing"}}
+	report = n.Validate([]*networking.Ingress{valid, conflict, duplicated, invalid, tls})
+	if report.Valid {
+		t.Errorf("expected an invalid report")
+	}
+	for _, v := range report.Ingresses[1:] {
+		if len(v.Errors) != 1 {
+			t.Errorf("expected one error in %v but returned %v", v.Name, v.Errors)
+		}
+	}
+
+	// the running Ingress can be changed
+	moved := newIngress("running", nil, "/api")
+	moved.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name = "api"
+	report = n.Validate([]*networking.Ingress{moved})
+	if !report.Valid || len(report.Ingresses[0].Warnings) != 1 {
+		t.Errorf("expected a valid report with a missing service warning but returned %+v", report)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/vip"
+)
+
+const (
+	// dataPlaneTimeout is the maximum time NGINX takes to answer the
+	// health check of the data plane
+	dataPlaneTimeout = time.Second
+	// vipRetryInterval is the interval between the attempts to obtain the
+	// pod of the controller before announcing the VIP
+	vipRetryInterval = 30 * time.Second
+)
+
+// dataPlaneHealthy returns an error if NGINX does not answer requests, or
+// the controller is stopping
+func (n *NGINXController) dataPlaneHealthy(ctx context.Context) error {
+	if n.shuttingDown() {
+		return fmt.Errorf("the controller is shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dataPlaneTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://127.0.0.1:%v/healthz", n.cfg.ListenPorts.Internal)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nginx returned %v", resp.Status)
+	}
+	return nil
+}
+
+// runVIP announces the VIP until ctx is done. Like the status, the VIP is
+// not announced while the pod of the controller cannot be obtained, and
+// NGINX keeps serving.
+func (n *NGINXController) runVIP(ctx context.Context) {
+	var announcer *vip.Announcer
+	err := wait.PollImmediateUntil(vipRetryInterval, func() (bool, error) {
+		a, err := n.newVIPAnnouncer(ctx)
+		if err != nil {
+			glog.Warningf("the VIP %v is not announced until the pod information is available: %v", n.cfg.VIP, err)
+			return false, nil
+		}
+		announcer = a
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		return
+	}
+	announcer.Run(ctx)
+}
+
+// newVIPAnnouncer returns the announcer of the VIP, elected among the
+// replicas of the same ingress class. It is not a leader task: the status
+// leader may have a failing data plane and keeps the leadership.
+func (n *NGINXController) newVIPAnnouncer(ctx context.Context) (*vip.Announcer, error) {
+	name, namespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE")
+	pod, err := n.cfg.Client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get POD information: %v", err)
+	}
+
+	lockNamespace, lockName := n.vipLock(namespace)
+	return vip.New(vip.Config{
+		Address:   n.cfg.VIP,
+		AgentURL:  n.cfg.VIPAgentURL,
+		Node:      pod.Spec.NodeName,
+		Interval:  n.cfg.VIPInterval,
+		Healthy:   n.dataPlaneHealthy,
+		Client:    n.cfg.Client,
+		LockType:  n.cfg.ElectionLockType,
+		LockName:  lockName,
+		Namespace: lockNamespace,
+		Identity:  name,
+	}), nil
+}
+
+// vipLock returns the namespace and the name of the lock of the election of
+// the VIP: the ones of the status election with a -vip suffix, so the
+// controllers sharing a namespace do not share the VIP
+func (n *NGINXController) vipLock(podNamespace string) (string, string) {
+	namespace := podNamespace
+	if n.cfg.ElectionNamespace != "" {
+		namespace = n.cfg.ElectionNamespace
+	}
+	if n.cfg.ElectionLockName != "" {
+		return namespace, n.cfg.ElectionLockName + "-vip"
+	}
+
+	ingressClass := class.DefaultClass
+	if class.IngressClass != "" {
+		ingressClass = class.IngressClass
+	}
+	return namespace, fmt.Sprintf("%v-vip-%v", n.cfg.ElectionID, ingressClass)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+)
+
+func TestNewVIPAnnouncer(t *testing.T) {
+	defer os.Setenv("POD_NAME", os.Getenv("POD_NAME"))
+	defer os.Setenv("POD_NAMESPACE", os.Getenv("POD_NAMESPACE"))
+	os.Setenv("POD_NAME", "management-ingress-0")
+	os.Setenv("POD_NAMESPACE", "ocm")
+
+	client := fake.NewSimpleClientset()
+	n := &NGINXController{cfg: &Configuration{Client: client, VIP: "192.0.2.10", ElectionID: "ingress-controller-leader"}}
+
+	// the controller keeps running without the pod
+	if _, err := n.newVIPAnnouncer(context.TODO()); err == nil {
+		t.Errorf("expected an error without the pod of the controller")
+	}
+
+	_, err := client.CoreV1().Pods("ocm").Create(context.TODO(), &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "management-ingress-0", Namespace: "ocm"},
+		Spec:       apiv1.PodSpec{NodeName: "worker-1"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a, err := n.newVIPAnnouncer(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Node != "worker-1" || a.Identity != "management-ingress-0" {
+		t.Errorf("expected the announcer of worker-1 but returned %+v", a.Config)
+	}
+}
+
+func TestVIPLock(t *testing.T) {
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	testCases := []struct {
+		cfg       Configuration
+		namespace string
+		name      string
+	}{
+		{Configuration{ElectionID: "ingress-controller-leader"}, "ocm", "ingress-controller-leader-vip-" + class.DefaultClass},
+		{Configuration{ElectionID: "ingress-controller-leader", ElectionLockName: "hub-ingress"}, "ocm", "hub-ingress-vip"},
+		{Configuration{ElectionID: "ingress-controller-leader", ElectionLockName: "hub-ingress", ElectionNamespace: "operator"}, "operator", "hub-ingress-vip"},
+		{Configuration{ElectionID: "ingress-controller-leader", ElectionNamespace: "operator"}, "operator", "ingress-controller-leader-vip-" + class.DefaultClass},
+	}
+
+	for _, tc := range testCases {
+		cfg := tc.cfg
+		n := &NGINXController{cfg: &cfg}
+		namespace, name := n.vipLock("ocm")
+		if namespace != tc.namespace || name != tc.name {
+			t.Errorf("expected the lock %v/%v but returned %v/%v", tc.namespace, tc.name, namespace, name)
+		}
+	}
+}
@@ -58,6 +58,16 @@ func (e InvalidContent) Error() string {
 	return e.Name
 }
 
+// AppendInvalidContent returns an InvalidContent error with the messages
+// of err, if not nil, and next, so the parsers of several annotations
+// report all the invalid ones
+func AppendInvalidContent(err, next error) error {
+	if err == nil {
+		return next
+	}
+	return InvalidContent{Name: err.Error() + ", " + next.Error()}
+}
+
 // LocationDenied error
 type LocationDenied struct {
 	Reason error
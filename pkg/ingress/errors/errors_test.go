@@ -50,3 +50,15 @@ func TestInvalidContent(t *testing.T) {
 		t.Error("expected false")
 	}
 }
+
+func TestAppendInvalidContent(t *testing.T) {
+	err := AppendInvalidContent(nil, NewInvalidAnnotationContent("a", 1))
+	err = AppendInvalidContent(err, NewInvalidAnnotationContent("b", 2))
+	if !IsInvalidContent(err) {
+		t.Errorf("expected an invalid content error but returned %T", err)
+	}
+	expected := "the annotation a does not contain a valid value (1), the annotation b does not contain a valid value (2)"
+	if err.Error() != expected {
+		t.Errorf("expected %q but returned %q", expected, err.Error())
+	}
+}
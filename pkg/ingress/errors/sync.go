@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package errors
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// Category classifies the errors of the requests to the API server, to
+// count them and to choose how they are retried
+type Category string
+
+const (
+	// Transient errors, like timeouts or an unavailable API server, are
+	// retried with an exponential backoff
+	Transient Category = "transient"
+	// Conflict errors, the object changed or was deleted since it was
+	// read, are retried after reading it again, unless it was deleted
+	Conflict Category = "conflict"
+	// Validation errors, the API server rejected the object, are not
+	// retried
+	Validation Category = "validation"
+	// Permission errors, the ServiceAccount of the controller is not
+	// allowed to make the request, are not retried
+	Permission Category = "permission"
+)
+
+// SyncError is an error of a sync with the API server with its Category,
+// for the errors not returned by the API server
+type SyncError struct {
+	Category Category
+	Err      error
+}
+
+func (e SyncError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the sync
+func (e SyncError) Unwrap() error {
+	return e.Err
+}
+
+// NewSyncError returns a new SyncError of the category
+func NewSyncError(category Category, err error) error {
+	return SyncError{Category: category, Err: err}
+}
+
+// CategoryOf returns the Category of err, the one of the SyncError it
+// wraps, if any, or the one of the status returned by the API server.
+// Errors without a status, like network errors, are Transient.
+func CategoryOf(err error) Category {
+	var syncErr SyncError
+	if errors.As(err, &syncErr) {
+		return syncErr.Category
+	}
+
+	switch {
+	case apierrors.IsConflict(err), apierrors.IsNotFound(err), apierrors.IsGone(err),
+		apierrors.IsAlreadyExists(err):
+		return Conflict
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return Permission
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), apierrors.IsMethodNotSupported(err),
+		apierrors.IsNotAcceptable(err), apierrors.IsUnsupportedMediaType(err),
+		apierrors.IsRequestEntityTooLargeError(err):
+		return Validation
+	}
+	return Transient
+}
+
+// IsRetryable checks if err is an error which can succeed when the request
+// is made again
+func IsRetryable(err error) bool {
+	switch CategoryOf(err) {
+	case Transient:
+		return true
+	case Conflict:
+		return !apierrors.IsNotFound(err) && !apierrors.IsGone(err)
+	}
+	return false
+}
+
+// Retry runs fn until it succeeds or returns an error which is not
+// retryable, waiting between the attempts with the backoff of the Category
+// of the error: retry.DefaultBackoff for the Transient errors and
+// retry.DefaultRetry for the conflicts. fn must read the objects it
+// updates again in every attempt.
+func Retry(fn func() error) error {
+	transient, conflict := retry.DefaultBackoff, retry.DefaultRetry
+	backoffs := map[Category]*wait.Backoff{
+		Transient: &transient,
+		Conflict:  &conflict,
+	}
+
+	for {
+		err := fn()
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		backoff := backoffs[CategoryOf(err)]
+		if backoff.Steps <= 1 {
+			return err
+		}
+		time.Sleep(backoff.Step())
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package errors
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestCategoryOf(t *testing.T) {
+	gr := schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}
+	tests := []struct {
+		err      error
+		category Category
+	}{
+		{apierrors.NewConflict(gr, "demo", errors.New("changed")), Conflict},
+		{apierrors.NewNotFound(gr, "demo"), Conflict},
+		{apierrors.NewForbidden(gr, "demo", errors.New("denied")), Permission},
+		{apierrors.NewUnauthorized("expired"), Permission},
+		{apierrors.NewInvalid(schema.GroupKind{Group: gr.Group, Kind: "Ingress"}, "demo", field.ErrorList{}), Validation},
+		{apierrors.NewBadRequest("demo"), Validation},
+		{apierrors.NewServerTimeout(gr, "update", 1), Transient},
+		{apierrors.NewTooManyRequests("demo", 1), Transient},
+		{errors.New("connection refused"), Transient},
+		{errors.Wrap(apierrors.NewForbidden(gr, "demo", errors.New("denied")), "updating"), Permission},
+		{NewSyncError(Validation, errors.New("demo")), Validation},
+		{errors.Wrap(NewSyncError(Permission, errors.New("demo")), "updating"), Permission},
+	}
+
+	for _, test := range tests {
+		if category := CategoryOf(test.err); category != test.category {
+			t.Errorf("expected %v for %v but got %v", test.category, test.err, category)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	gr := schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}
+	if !IsRetryable(apierrors.NewConflict(gr, "demo", errors.New("changed"))) {
+		t.Error("expected true")
+	}
+	if !IsRetryable(errors.New("connection refused")) {
+		t.Error("expected true")
+	}
+	if IsRetryable(apierrors.NewNotFound(gr, "demo")) {
+		t.Error("expected false")
+	}
+	if IsRetryable(apierrors.NewForbidden(gr, "demo", errors.New("denied"))) {
+		t.Error("expected false")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	gr := schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}
+
+	attempts := 0
+	err := Retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return apierrors.NewConflict(gr, "demo", errors.New("changed"))
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expected a success after 3 attempts but got %v after %v", err, attempts)
+	}
+
+	attempts = 0
+	err = Retry(func() error {
+		attempts++
+		return apierrors.NewForbidden(gr, "demo", errors.New("denied"))
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("expected a failure after 1 attempt but got %v after %v", err, attempts)
+	}
+
+	attempts = 0
+	err = Retry(func() error {
+		attempts++
+		return apierrors.NewConflict(gr, "demo", errors.New("changed"))
+	})
+	if err == nil || attempts != 5 {
+		t.Errorf("expected a failure after 5 attempts but got %v after %v", err, attempts)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package externaldns publishes the DNS records of the hosts served by the
+// controller for external-dns: the target and TTL annotations of the
+// Ingresses and, optionally, DNSEndpoint resources.
+package externaldns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// TargetAnnotation overrides the targets of the records of an Ingress
+	TargetAnnotation = "external-dns.alpha.kubernetes.io/target"
+	// TTLAnnotation is the TTL in seconds of the records of an Ingress
+	TTLAnnotation = "external-dns.alpha.kubernetes.io/ttl"
+	// PublishedAnnotation is the target annotation last written by the
+	// controller. A different target annotation is set by the user and is
+	// kept.
+	PublishedAnnotation = "management-ingress.open-cluster-management.io/external-dns-target"
+
+	// managedByLabel marks the DNSEndpoints created by the controller,
+	// the other ones are never changed
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "management-ingress"
+)
+
+// DNSEndpoints is the resource of the DNSEndpoint CRD of external-dns
+var DNSEndpoints = schema.GroupVersionResource{Group: "externaldns.k8s.io", Version: "v1alpha1", Resource: "dnsendpoints"}
+
+// Config contains the DNS records published for external-dns
+type Config struct {
+	// Targets replace the published addresses of the Ingresses in the
+	// records, like the address of a cloud load balancer
+	Targets []string
+	// TTL of the records, zero for the default of the DNS provider
+	TTL time.Duration
+	// Endpoints creates a DNSEndpoint per Ingress, named like the Ingress,
+	// with Dynamic. The annotations are not written then, so external-dns
+	// can use the crd source instead of the ingress source
+	Endpoints bool
+	Dynamic   dynamic.Interface
+}
+
+// Publisher writes the DNS records of the Ingresses
+type Publisher struct {
+	Config
+	client clientset.Interface
+
+	mu sync.Mutex
+	// published are the records of the DNSEndpoints last written, by
+	// namespace/name of the Ingress, so the unchanged ones are not read
+	published map[string]string
+}
+
+// New returns a Publisher updating the Ingresses with client
+func New(cfg Config, client clientset.Interface) *Publisher {
+	return &Publisher{Config: cfg, client: client, published: map[string]string{}}
+}
+
+// userTargets returns the targets of the target annotation of the Ingress
+// when it is set by the user rather than by the controller
+func userTargets(ing *networking.Ingress) []string {
+	value, ok := ing.GetAnnotations()[TargetAnnotation]
+	if !ok || value == "" || value == ing.GetAnnotations()[PublishedAnnotation] {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// targets returns the targets of the records: the override or the
+// addresses of the status
+func (p *Publisher) targets(status []apiv1.LoadBalancerIngress) []string {
+	if len(p.Targets) > 0 {
+		return p.Targets
+	}
+	var targets []string
+	for _, lb := range status {
+		if lb.IP != "" {
+			targets = append(targets, lb.IP)
+		} else if lb.Hostname != "" {
+			targets = append(targets, lb.Hostname)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Sync publishes the records of the hosts of the Ingress with the
+// addresses of its status. The records are removed when there are no
+// targets, like when the last replica of the controller stops. The
+// records are compared with the cached Ingress, and with the DNSEndpoint
+// last written, so the API server is only called when they change. The
+// target annotation set by the user is kept, and used by the DNSEndpoint.
+func (p *Publisher) Sync(ctx context.Context, ing *networking.Ingress, status []apiv1.LoadBalancerIngress) error {
+	targets := p.targets(status)
+	if p.Endpoints {
+		if user := userTargets(ing); len(user) > 0 && len(targets) > 0 {
+			targets = user
+		}
+		return p.syncEndpoint(ctx, ing, targets)
+	}
+	if len(userTargets(ing)) > 0 {
+		return nil
+	}
+	return p.syncAnnotations(ctx, ing, targets)
+}
+
+// annotations returns the values of the annotations of the targets, nil
+// to remove them
+func (p *Publisher) annotations(targets []string) map[string]interface{} {
+	anns := map[string]interface{}{TargetAnnotation: nil, TTLAnnotation: nil, PublishedAnnotation: nil}
+	if len(targets) > 0 {
+		anns[TargetAnnotation] = strings.Join(targets, ",")
+		anns[PublishedAnnotation] = anns[TargetAnnotation]
+		if p.TTL > 0 {
+			anns[TTLAnnotation] = strconv.Itoa(int(p.TTL / time.Second))
+		}
+	}
+	return anns
+}
+
+func (p *Publisher) syncAnnotations(ctx context.Context, ing *networking.Ingress, targets []string) error {
+	anns := p.annotations(targets)
+	current := ing.GetAnnotations()
+	changed := false
+	for k, v := range anns {
+		value, ok := current[k]
+		if (v == nil && ok) || (v != nil && v != value) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": anns},
+	})
+	if err != nil {
+		return err
+	}
+	glog.V(2).Infof("updating the external-dns annotations of ingress %v/%v to %v", ing.Namespace, ing.Name, targets)
+	_, err = p.client.NetworkingV1().Ingresses(ing.Namespace).Patch(ctx, ing.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// endpoints returns the endpoints of the DNSEndpoint of the hosts: an A,
+// AAAA or CNAME record per type of target. A CNAME has only one target.
+func (p *Publisher) endpoints(hosts, targets []string) []interface{} {
+	byType := map[string][]interface{}{}
+	for _, t := range targets {
+		ip := net.ParseIP(t)
+		switch {
+		case ip == nil:
+			if len(byType["CNAME"]) == 0 {
+				byType["CNAME"] = append(byType["CNAME"], t)
+			}
+		case ip.To4() != nil:
+			byType["A"] = append(byType["A"], t)
+		default:
+			byType["AAAA"] = append(byType["AAAA"], t)
+		}
+	}
+
+	var endpoints []interface{}
+	for _, host := range hosts {
+		for _, recordType := range []string{"A", "AAAA", "CNAME"} {
+			if len(byType[recordType]) == 0 {
+				continue
+			}
+			ep := map[string]interface{}{
+				"dnsName":    host,
+				"recordType": recordType,
+				"targets":    byType[recordType],
+			}
+			if p.TTL > 0 {
+				ep["recordTTL"] = int64(p.TTL / time.Second)
+			}
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return endpoints
+}
+
+// hosts returns the sorted hosts of the rules of the Ingress
+func hosts(ing *networking.Ingress) []string {
+	seen := map[string]bool{}
+	var hosts []string
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host != "" && !seen[rule.Host] {
+			seen[rule.Host] = true
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// dnsEndpoint returns the DNSEndpoint of the Ingress, owned by it so it is
+// deleted with the Ingress
+func (p *Publisher) dnsEndpoint(ing *networking.Ingress, endpoints []interface{}) *unstructured.Unstructured {
+	controller := true
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": DNSEndpoints.GroupVersion().String(),
+		"kind":       "DNSEndpoint",
+		"spec":       map[string]interface{}{"endpoints": endpoints},
+	}}
+	obj.SetNamespace(ing.Namespace)
+	obj.SetName(ing.Name)
+	obj.SetLabels(map[string]string{managedByLabel: managedBy})
+	obj.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "Ingress",
+		Name:       ing.Name,
+		UID:        ing.UID,
+		Controller: &controller,
+	}})
+	return obj
+}
+
+func (p *Publisher) syncEndpoint(ctx context.Context, ing *networking.Ingress, targets []string) error {
+	key := ing.Namespace + "/" + ing.Name
+	endpoints := p.endpoints(hosts(ing), targets)
+	records := fmt.Sprintf("%v %v", ing.UID, endpoints)
+	p.mu.Lock()
+	published, ok := p.published[key]
+	p.mu.Unlock()
+	if ok && published == records {
+		return nil
+	}
+
+	err := p.writeEndpoint(ctx, ing, endpoints)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		delete(p.published, key)
+		return err
+	}
+	p.published[key] = records
+	return nil
+}
+
+// writeEndpoint creates, updates or deletes the DNSEndpoint of the Ingress
+// with the endpoints
+func (p *Publisher) writeEndpoint(ctx context.Context, ing *networking.Ingress, endpoints []interface{}) error {
+	client := p.Dynamic.Resource(DNSEndpoints).Namespace(ing.Namespace)
+	current, err := client.Get(ctx, ing.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && current.GetLabels()[managedByLabel] != managedBy {
+		return fmt.Errorf("the DNSEndpoint %v/%v is not managed by the controller", ing.Namespace, ing.Name)
+	}
+
+	if len(endpoints) == 0 {
+		if err != nil {
+			return nil
+		}
+		glog.V(2).Infof("deleting the DNSEndpoint of ingress %v/%v", ing.Namespace, ing.Name)
+		return client.Delete(ctx, ing.Name, metav1.DeleteOptions{})
+	}
+
+	desired := p.dnsEndpoint(ing, endpoints)
+	if err != nil {
+		glog.V(2).Infof("creating the DNSEndpoint of ingress %v/%v", ing.Namespace, ing.Name)
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{})
+		return err
+	}
+	if reflect.DeepEqual(current.Object["spec"], desired.Object["spec"]) {
+		return nil
+	}
+	glog.V(2).Infof("updating the DNSEndpoint of ingress %v/%v", ing.Namespace, ing.Name)
+	desired.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.Update(ctx, desired, metav1.UpdateOptions{})
+	return err
+}
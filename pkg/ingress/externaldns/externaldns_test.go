@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package externaldns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: apiv1.NamespaceDefault,
+			UID:       "uid",
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{
+				{Host: "b.example.com"},
+				{Host: "a.example.com"},
+				{Host: "a.example.com"},
+			},
+		},
+	}
+}
+
+func TestSyncAnnotations(t *testing.T) {
+	ing := buildIngress()
+	client := fake.NewSimpleClientset(ing)
+	p := New(Config{TTL: time.Minute}, client)
+
+	status := []apiv1.LoadBalancerIngress{{IP: "10.0.0.2"}, {IP: "10.0.0.1"}}
+	if err := p.Sync(context.TODO(), ing, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ing, _ = client.NetworkingV1().Ingresses(ing.Namespace).Get(context.TODO(), ing.Name, metav1.GetOptions{})
+	if v := ing.Annotations[TargetAnnotation]; v != "10.0.0.1,10.0.0.2" {
+		t.Errorf("expected the addresses of the status as targets but got %q", v)
+	}
+	if v := ing.Annotations[TTLAnnotation]; v != "60" {
+		t.Errorf("expected a TTL of 60 but got %q", v)
+	}
+
+	actions := len(client.Actions())
+	if err := p.Sync(context.TODO(), ing, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.Actions()) != actions {
+		t.Errorf("expected no patch of unchanged annotations")
+	}
+
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ing, _ = client.NetworkingV1().Ingresses(ing.Namespace).Get(context.TODO(), ing.Name, metav1.GetOptions{})
+	if _, ok := ing.Annotations[TargetAnnotation]; ok {
+		t.Errorf("expected the target annotation to be removed without addresses")
+	}
+	if _, ok := ing.Annotations[TTLAnnotation]; ok {
+		t.Errorf("expected the TTL annotation to be removed without addresses")
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	p := New(Config{}, nil)
+
+	endpoints := p.endpoints([]string{"a.example.com"}, []string{"10.0.0.1", "fd00::1", "lb1.example.com", "lb2.example.com"})
+	expected := []interface{}{
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "A", "targets": []interface{}{"10.0.0.1"}},
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "AAAA", "targets": []interface{}{"fd00::1"}},
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "CNAME", "targets": []interface{}{"lb1.example.com"}},
+	}
+	if !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("expected %v but got %v", expected, endpoints)
+	}
+}
+
+func TestSyncEndpoint(t *testing.T) {
+	ing := buildIngress()
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	p := New(Config{Endpoints: true, Targets: []string{"192.0.2.1"}, Dynamic: dyn}, nil)
+	client := dyn.Resource(DNSEndpoints).Namespace(ing.Namespace)
+
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj, err := client.Get(context.TODO(), ing.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected a DNSEndpoint but got %v", err)
+	}
+	if refs := obj.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != ing.UID {
+		t.Errorf("expected the DNSEndpoint to be owned by the ingress but got %v", refs)
+	}
+	endpoints := obj.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})
+	if len(endpoints) != 2 {
+		t.Errorf("expected a record per host but got %v", endpoints)
+	}
+
+	ing.Spec.Rules = ing.Spec.Rules[:1]
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj, _ = client.Get(context.TODO(), ing.Name, metav1.GetOptions{})
+	endpoints = obj.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})
+	if len(endpoints) != 1 {
+		t.Errorf("expected the DNSEndpoint to be updated but got %v", endpoints)
+	}
+
+	ing.Spec.Rules = nil
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Get(context.TODO(), ing.Name, metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the DNSEndpoint to be deleted without hosts")
+	}
+
+	obj.SetLabels(nil)
+	obj.SetResourceVersion("")
+	if _, err := client.Create(context.TODO(), obj, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Sync(context.TODO(), buildIngress(), nil); err == nil {
+		t.Errorf("expected an error updating a DNSEndpoint not managed by the controller")
+	}
+}
+
+func TestSyncUserTargets(t *testing.T) {
+	ing := buildIngress()
+	ing.Annotations = map[string]string{TargetAnnotation: "lb.example.com"}
+	client := fake.NewSimpleClientset(ing)
+	p := New(Config{}, client)
+
+	status := []apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+	if err := p.Sync(context.TODO(), ing, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("expected the target annotation of the user to be kept but got %v", client.Actions())
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	p = New(Config{Endpoints: true, Dynamic: dyn}, nil)
+	if err := p.Sync(context.TODO(), ing, status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj, err := dyn.Resource(DNSEndpoints).Namespace(ing.Namespace).Get(context.TODO(), ing.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected a DNSEndpoint but got %v", err)
+	}
+	endpoints := obj.Object["spec"].(map[string]interface{})["endpoints"].([]interface{})
+	if rt := endpoints[0].(map[string]interface{})["recordType"]; rt != "CNAME" {
+		t.Errorf("expected a CNAME to the target of the user but got %v", endpoints)
+	}
+}
+
+func TestSyncEndpointUnchanged(t *testing.T) {
+	ing := buildIngress()
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	p := New(Config{Endpoints: true, Targets: []string{"192.0.2.1"}, Dynamic: dyn}, nil)
+
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actions := len(dyn.Actions())
+	if err := p.Sync(context.TODO(), ing, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dyn.Actions()) != actions {
+		t.Errorf("expected no call for unchanged records but got %v", dyn.Actions()[actions:])
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build !nolua
+
+package filters
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// Extension is the extension of the filter files in the bundle
+	Extension = ".lua"
+	// SignatureExtension is appended to the name of a filter file to
+	// obtain the file with its base64 encoded ed25519 signature
+	SignatureExtension = ".sig"
+
+	// MaxSize is the maximum size of a filter file
+	MaxSize = 64 * 1024
+)
+
+// ReadPublicKey reads an ed25519 public key in PEM format
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%v does not contain a PEM encoded key", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%v does not contain an ed25519 public key", path)
+	}
+	return pub, nil
+}
+
+// Bundle contains the filters with a valid signature, by name
+type Bundle map[string][]byte
+
+// Load reads the filters of the directory. Filters without a valid name,
+// too big or without a valid signature are skipped and returned as errors.
+func Load(dir string, key ed25519.PublicKey) (Bundle, []error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	b := Bundle{}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), Extension) {
+			continue
+		}
+
+		name := strings.TrimSuffix(f.Name(), Extension)
+		if !NameRegex.MatchString(name) {
+			errs = append(errs, fmt.Errorf("filter %v: invalid name", f.Name()))
+			continue
+		}
+		if f.Size() > MaxSize {
+			errs = append(errs, fmt.Errorf("filter %v: bigger than %v bytes", name, MaxSize))
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("filter %v: %v", name, err))
+			continue
+		}
+
+		if err := verify(filepath.Join(dir, f.Name()+SignatureExtension), content, key); err != nil {
+			errs = append(errs, fmt.Errorf("filter %v: %v", name, err))
+			continue
+		}
+
+		b[name] = content
+	}
+
+	return b, errs
+}
+
+func verify(path string, content []byte, key ed25519.PublicKey) error {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read signature: %v", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	if !ed25519.Verify(key, content, sig) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// Names returns the sorted names of the filters
+func (b Bundle) Names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Install replaces the content of dir with the filters of the bundle
+func (b Bundle) Install(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for name, content := range b {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+Extension), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+//go:build !nolua
+
+package filters
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFilter(t *testing.T, dir, name string, content []byte, key ed25519.PrivateKey) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name+Extension), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if key == nil {
+		return
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, content))
+	if err := ioutil.WriteFile(filepath.Join(dir, name+Extension+SignatureExtension), []byte(sig+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "filters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := []byte("return { access = function() end }")
+	writeFilter(t, dir, "legacy-auth", valid, priv)
+	writeFilter(t, dir, "unsigned", valid, nil)
+	writeFilter(t, dir, "other-key", valid, other)
+	writeFilter(t, dir, "Invalid_Name", valid, priv)
+	writeFilter(t, dir, "too-big", make([]byte, MaxSize+1), priv)
+
+	b, errs := Load(dir, pub)
+	if !reflect.DeepEqual(b.Names(), []string{"legacy-auth"}) {
+		t.Errorf("expected only the signed filter but returned %v", b.Names())
+	}
+	if len(errs) != 4 {
+		t.Errorf("expected 4 errors but returned %v", errs)
+	}
+
+	installDir := filepath.Join(dir, "installed")
+	if err := b.Install(installDir); err != nil {
+		t.Fatalf("unexpected error installing bundle: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(installDir, "legacy-auth.lua"))
+	if err != nil || string(content) != string(valid) {
+		t.Errorf("expected installed filter but returned %q (%v)", content, err)
+	}
+}
+
+func TestReadPublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ioutil.TempFile("", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	f.Close()
+
+	key, err := ReadPublicKey(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error reading key: %v", err)
+	}
+	if !key.Equal(pub) {
+		t.Errorf("expected the generated key")
+	}
+}
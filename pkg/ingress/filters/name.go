@@ -0,0 +1,12 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package filters loads the signed bundle of Lua request filters that
+// Ingresses can run with the lua-filters annotation. Only the names of the
+// filters are compiled in the controller built with the nolua tag.
+package filters
+
+import "regexp"
+
+// NameRegex matches the valid filter names
+var NameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
@@ -0,0 +1,241 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package handshake publishes the capabilities of every replica of the
+// controller in a ConfigMap read by the management-ingress operator. The
+// operator only enables a feature once every live replica supports it, so
+// the new features are rolled out safely while a hub runs mixed versions.
+package handshake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ProtocolVersion is the version of the documents published by the
+	// controller. It changes when a field is removed or changes meaning.
+	ProtocolVersion = 1
+
+	requestTimeout = 10 * time.Second
+)
+
+// Capabilities is the document published by a replica, in the key of its
+// pod name
+type Capabilities struct {
+	Protocol int    `json:"protocol"`
+	Release  string `json:"release"`
+	// Annotations are the annotations of the Ingresses the replica reads
+	Annotations []string `json:"annotations"`
+	// CRDs are the custom resources the replica reads or writes, like
+	// dnsendpoints.externaldns.k8s.io/v1alpha1
+	CRDs []string `json:"crds"`
+	// Modes are the enabled modes of operation, like update-status
+	Modes []string `json:"modes"`
+	// Subsystems are the optional subsystems compiled in and available
+	Subsystems []string `json:"subsystems"`
+	// Plugins are the registered annotation plugins
+	Plugins      []string        `json:"plugins"`
+	FeatureGates map[string]bool `json:"featureGates"`
+	// Updated is the time of the last publication of the replica
+	Updated metav1.Time `json:"updated"`
+}
+
+// Publisher keeps the capabilities of the replica in the ConfigMap
+type Publisher struct {
+	Client    clientset.Interface
+	Namespace string
+	Name      string
+	// Pod is the key of the replica in the ConfigMap
+	Pod string
+	// Interval between the publications. The entries not updated in three
+	// intervals belong to replicas that are gone.
+	Interval     time.Duration
+	Capabilities func() Capabilities
+}
+
+// Run publishes the capabilities until ctx is done, then removes them
+func (p *Publisher) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := p.publish(ctx); err != nil {
+			glog.Warningf("unexpected error publishing the capabilities in ConfigMap %v/%v: %v", p.Namespace, p.Name, err)
+		}
+	}, p.Interval)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := p.withdraw(ctx); err != nil {
+		glog.Warningf("unexpected error removing the capabilities from ConfigMap %v/%v: %v", p.Namespace, p.Name, err)
+	}
+}
+
+// publish writes the current capabilities in the key of the replica
+func (p *Publisher) publish(ctx context.Context) error {
+	c := p.Capabilities()
+	c.Protocol = ProtocolVersion
+	c.Updated = metav1.Now()
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cms := p.Client.CoreV1().ConfigMaps(p.Namespace)
+		cm, err := cms.Get(ctx, p.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			cm = &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name},
+				Data:       map[string]string{p.Pod: string(b)},
+			}
+			_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+			if k8serrors.IsAlreadyExists(err) {
+				// created by another replica, retried as a conflict
+				return k8serrors.NewConflict(apiv1.Resource("configmaps"), p.Name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[p.Pod] = string(b)
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// withdraw removes the key of the replica
+func (p *Publisher) withdraw(ctx context.Context) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cms := p.Client.CoreV1().ConfigMaps(p.Namespace)
+		cm, err := cms.Get(ctx, p.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := cm.Data[p.Pod]; !ok {
+			return nil
+		}
+		delete(cm.Data, p.Pod)
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Negotiated contains the capabilities shared by the live replicas
+type Negotiated struct {
+	// Replicas are the pods of the live replicas
+	Replicas []string `json:"replicas"`
+	// Releases are the releases running in the hub
+	Releases []string `json:"releases"`
+	// Protocol is the oldest protocol version of the replicas
+	Protocol    int      `json:"protocol"`
+	Annotations []string `json:"annotations"`
+	CRDs        []string `json:"crds"`
+	Modes       []string `json:"modes"`
+	Subsystems  []string `json:"subsystems"`
+	Plugins     []string `json:"plugins"`
+	// FeatureGates are the feature gates known by every replica, enabled
+	// if they are enabled in all of them
+	FeatureGates map[string]bool `json:"featureGates"`
+}
+
+// Negotiate returns the capabilities supported by every replica that
+// published its capabilities in the ConfigMap within maxAge of now. The
+// entries with an unknown protocol, from newer releases, are compared with
+// the fields of this protocol.
+func Negotiate(cm *apiv1.ConfigMap, now time.Time, maxAge time.Duration) (*Negotiated, error) {
+	pods := make([]string, 0, len(cm.Data))
+	for pod := range cm.Data {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+
+	n := &Negotiated{}
+	releases := map[string]bool{}
+	for _, pod := range pods {
+		var c Capabilities
+		if err := json.Unmarshal([]byte(cm.Data[pod]), &c); err != nil {
+			return nil, fmt.Errorf("invalid capabilities of %v: %v", pod, err)
+		}
+		if now.Sub(c.Updated.Time) > maxAge {
+			continue
+		}
+
+		if len(n.Replicas) == 0 {
+			n.Protocol = c.Protocol
+			n.Annotations, n.CRDs, n.Modes = sorted(c.Annotations), sorted(c.CRDs), sorted(c.Modes)
+			n.Subsystems, n.Plugins = sorted(c.Subsystems), sorted(c.Plugins)
+			n.FeatureGates = intersectGates(c.FeatureGates, c.FeatureGates)
+		} else {
+			if c.Protocol < n.Protocol {
+				n.Protocol = c.Protocol
+			}
+			n.Annotations = intersect(n.Annotations, c.Annotations)
+			n.CRDs = intersect(n.CRDs, c.CRDs)
+			n.Modes = intersect(n.Modes, c.Modes)
+			n.Subsystems = intersect(n.Subsystems, c.Subsystems)
+			n.Plugins = intersect(n.Plugins, c.Plugins)
+			n.FeatureGates = intersectGates(n.FeatureGates, c.FeatureGates)
+		}
+		n.Replicas = append(n.Replicas, pod)
+		releases[c.Release] = true
+	}
+	if len(n.Replicas) == 0 {
+		return nil, fmt.Errorf("no replica published its capabilities in the last %v", maxAge)
+	}
+
+	for r := range releases {
+		n.Releases = append(n.Releases, r)
+	}
+	sort.Strings(n.Releases)
+	return n, nil
+}
+
+func sorted(values []string) []string {
+	out := append([]string{}, values...)
+	sort.Strings(out)
+	return out
+}
+
+// intersect returns the sorted values present in a and b
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	out := []string{}
+	for _, v := range a {
+		if in[v] {
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func intersectGates(a, b map[string]bool) map[string]bool {
+	out := map[string]bool{}
+	for name, enabled := range a {
+		if other, ok := b[name]; ok {
+			out[name] = enabled && other
+		}
+	}
+	return out
+}
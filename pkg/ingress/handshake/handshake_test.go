@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package handshake
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPublisher(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	newPublisher := func(pod, release string) *Publisher {
+		return &Publisher{
+			Client:    client,
+			Namespace: "ingress",
+			Name:      "management-ingress-capabilities",
+			Pod:       pod,
+			Interval:  time.Minute,
+			Capabilities: func() Capabilities {
+				return Capabilities{Release: release, Modes: []string{"update-status"}}
+			},
+		}
+	}
+	a, b := newPublisher("ingress-a", "2.7.0"), newPublisher("ingress-b", "2.8.0")
+
+	ctx := context.Background()
+	for _, p := range []*Publisher{a, b} {
+		if err := p.publish(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ingress").Get(ctx, "management-ingress-capabilities", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cm.Data) != 2 {
+		t.Fatalf("expected the capabilities of 2 replicas but returned %v", cm.Data)
+	}
+	var c Capabilities
+	if err := json.Unmarshal([]byte(cm.Data["ingress-b"]), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Protocol != ProtocolVersion || c.Release != "2.8.0" || c.Updated.IsZero() {
+		t.Errorf("unexpected capabilities %+v", c)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	a.Run(runCtx)
+	cm, err = client.CoreV1().ConfigMaps("ingress").Get(ctx, "management-ingress-capabilities", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data["ingress-a"]; ok || len(cm.Data) != 1 {
+		t.Errorf("expected the capabilities of ingress-a to be removed but returned %v", cm.Data)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	now := time.Now()
+	entry := func(c Capabilities, age time.Duration) string {
+		c.Updated = metav1.NewTime(now.Add(-age))
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		"ingress-a": entry(Capabilities{
+			Protocol:     1,
+			Release:      "2.7.0",
+			Annotations:  []string{"auth-type", "profile"},
+			Modes:        []string{"vip", "update-status"},
+			FeatureGates: map[string]bool{"ExternalDNSEndpoints": true},
+		}, time.Minute),
+		"ingress-b": entry(Capabilities{
+			Protocol:     2,
+			Release:      "2.8.0",
+			Annotations:  []string{"profile", "auth-type", "locale"},
+			CRDs:         []string{"dnsendpoints.externaldns.k8s.io/v1alpha1"},
+			Modes:        []string{"update-status", "vip"},
+			FeatureGates: map[string]bool{"ExternalDNSEndpoints": false, "NewFeature": true},
+		}, 0),
+		// gone replica
+		"ingress-c": entry(Capabilities{Protocol: 1, Release: "2.6.0"}, time.Hour),
+	}}
+
+	n, err := Negotiate(cm, now, 3*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Negotiated{
+		Replicas:     []string{"ingress-a", "ingress-b"},
+		Releases:     []string{"2.7.0", "2.8.0"},
+		Protocol:     1,
+		Annotations:  []string{"auth-type", "profile"},
+		CRDs:         []string{},
+		Modes:        []string{"update-status", "vip"},
+		Subsystems:   []string{},
+		Plugins:      []string{},
+		FeatureGates: map[string]bool{"ExternalDNSEndpoints": false},
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, n)
+	}
+
+	if _, err := Negotiate(cm, now.Add(time.Hour), 3*time.Minute); err == nil {
+		t.Errorf("expected an error without live replicas")
+	}
+	cm.Data["ingress-d"] = "{"
+	if _, err := Negotiate(cm, now, 3*time.Minute); err == nil {
+		t.Errorf("expected an error with invalid capabilities")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package history keeps the last NGINX configurations rendered by the
+// controller, so an administrator can compare them and roll back to one.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	configSuffix   = ".conf"
+	revisionSuffix = ".json"
+)
+
+// ErrNotFound is returned for the revisions not in the history
+var ErrNotFound = errors.New("the revision does not exist")
+
+// Revision describes a rendered configuration
+type Revision struct {
+	ID int `json:"id"`
+	// Trigger is the reason of the reload, like the Ingress that changed
+	// or the revision restored by a rollback
+	Trigger   string    `json:"trigger"`
+	Timestamp time.Time `json:"timestamp"`
+	// ModelHash identifies the model the configuration was rendered from
+	ModelHash string `json:"modelHash"`
+}
+
+// History stores the configurations in a directory as <id>.conf, with
+// their revision in <id>.json, and removes the oldest ones
+type History struct {
+	dir  string
+	size int
+
+	mu sync.Mutex
+}
+
+// New returns a History keeping the last size configurations in dir
+func New(dir string, size int) *History {
+	return &History{dir: dir, size: size}
+}
+
+// ModelHash returns the hash of the JSON encoding of a model
+func ModelHash(model interface{}) string {
+	b, err := json.Marshal(model)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record stores a configuration as a new revision
+func (h *History) Record(trigger, modelHash string, content []byte) (*Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return nil, err
+	}
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &Revision{
+		ID:        1,
+		Trigger:   trigger,
+		Timestamp: time.Now().UTC(),
+		ModelHash: modelHash,
+	}
+	if len(ids) > 0 {
+		rev.ID = ids[len(ids)-1] + 1
+	}
+	b, err := json.Marshal(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	// the configuration is written first, so a revision always has one
+	if err := ioutil.WriteFile(h.Path(rev.ID), content, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(h.dir, strconv.Itoa(rev.ID)+revisionSuffix), b, 0600); err != nil {
+		return nil, err
+	}
+
+	ids = append(ids, rev.ID)
+	for len(ids) > h.size {
+		_ = os.Remove(filepath.Join(h.dir, strconv.Itoa(ids[0])+revisionSuffix))
+		_ = os.Remove(h.Path(ids[0]))
+		ids = ids[1:]
+	}
+	return rev, nil
+}
+
+// List returns the revisions, the newest first
+func (h *History) List() ([]Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		rev, err := h.revision(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	return revisions, nil
+}
+
+// Get returns a revision and its configuration
+func (h *History) Get(id int) (*Revision, []byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rev, err := h.revision(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := ioutil.ReadFile(h.Path(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rev, content, nil
+}
+
+// Path returns the file with the configuration of a revision
+func (h *History) Path(id int) string {
+	return filepath.Join(h.dir, strconv.Itoa(id)+configSuffix)
+}
+
+func (h *History) revision(id int) (*Revision, error) {
+	b, err := ioutil.ReadFile(filepath.Join(h.dir, strconv.Itoa(id)+revisionSuffix))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("revision %v: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rev := &Revision{}
+	if err := json.Unmarshal(b, rev); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// ids returns the identifiers of the stored revisions, sorted
+func (h *History) ids() ([]int, error) {
+	files, err := ioutil.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), revisionSuffix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), revisionSuffix))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
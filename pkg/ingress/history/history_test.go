@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := New(filepath.Join(dir, "revisions"), 2)
+	if revisions, err := h.List(); err != nil || len(revisions) != 0 {
+		t.Errorf("expected an empty history but returned %v (%v)", revisions, err)
+	}
+
+	hash := ModelHash(&ingress.Configuration{Servers: []*ingress.Server{{Hostname: "example.com"}}})
+	for i, content := range []string{"events { a }", "events { b }", "events { c }"} {
+		rev, err := h.Record("ingress default/console", hash, []byte(content))
+		if err != nil {
+			t.Fatalf("unexpected error recording a revision: %v", err)
+		}
+		if rev.ID != i+1 {
+			t.Errorf("expected the revision %v but returned %v", i+1, rev.ID)
+		}
+	}
+
+	revisions, err := h.List()
+	if err != nil {
+		t.Fatalf("unexpected error listing the revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].ID != 3 || revisions[1].ID != 2 {
+		t.Errorf("expected the last two revisions, the newest first, but returned %+v", revisions)
+	}
+	if _, _, err := h.Get(1); err == nil {
+		t.Errorf("expected the oldest revision to be removed")
+	}
+
+	rev, content, err := h.Get(2)
+	if err != nil {
+		t.Fatalf("unexpected error reading a revision: %v", err)
+	}
+	if string(content) != "events { b }" || rev.ModelHash != hash || rev.Trigger != "ingress default/console" {
+		t.Errorf("unexpected revision %+v with %q", rev, content)
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package metric
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// PrometheusNamespace is the prefix used in all the metrics exposed by the controller
+	PrometheusNamespace = "management_ingress"
+)
+
+var (
+	configReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "config_reloads_total",
+			Help:      "Cumulative number of NGINX configuration reloads by result",
+		},
+		[]string{"result"},
+	)
+
+	lastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload attempt was successful",
+		})
+
+	leakSuspected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "leak_suspected_total",
+			Help:      "Number of times the leak detector reported monotonic growth of a resource",
+		},
+		[]string{"resource"},
+	)
+
+	queueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "queue_depth",
+			Help:      "Number of keys waiting to be processed",
+		},
+		[]string{"queue"},
+	)
+
+	queueEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "queue_events_total",
+			Help:      "Number of events received by a queue by result (queued, merged or dropped)",
+		},
+		[]string{"queue", "result"},
+	)
+
+	nginxMasterUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "nginx_master_up",
+			Help:      "Whether the NGINX master process is running",
+		})
+
+	nginxMasterRestarts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "nginx_master_restarts_total",
+			Help:      "Number of times the NGINX master process was restarted after an unexpected exit",
+		})
+
+	nginxSignalErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "nginx_signal_errors_total",
+			Help:      "Number of signals that could not be sent to the NGINX master process",
+		},
+		[]string{"signal"},
+	)
+
+	backendReachable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "backend_reachable",
+			Help:      "Whether the last preflight checks of a backend succeeded",
+		},
+		[]string{"backend"},
+	)
+
+	backendCheckFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "backend_check_failures_total",
+			Help:      "Number of failed preflight checks by type (dns, tcp or tls)",
+		},
+		[]string{"check"},
+	)
+
+	pendingChanges = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "pending_changes",
+			Help:      "Number of frozen Ingresses with changes waiting for approval",
+		})
+
+	clientCertificateChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "client_certificate_checks_total",
+			Help:      "Number of revocation checks of client certificates by result (valid, revoked or unknown)",
+		},
+		[]string{"result"},
+	)
+
+	renderDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "config_render_duration_seconds",
+			Help:      "Time spent rendering the NGINX configuration template",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		})
+
+	shadowRenders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "template_shadow_renders_total",
+			Help:      "Number of shadow renders of the candidate template by result (match, differ or error)",
+		},
+		[]string{"result"},
+	)
+
+	shadowDifferingLines = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "template_shadow_differing_lines",
+			Help:      "Number of lines that differ between the active and the candidate template in the last shadow render",
+		})
+
+	colocatedReplicas = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "colocated_replicas",
+			Help:      "Number of controller replicas sharing a node with another replica",
+		})
+
+	authCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "auth_cache_requests_total",
+			Help:      "Number of token validations by result of the decision cache (hit or miss)",
+		},
+		[]string{"result"},
+	)
+
+	authCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "auth_cache_entries",
+			Help:      "Number of decisions in the token validation cache",
+		})
+
+	startupGateMissing = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "startup_gate_missing_references",
+			Help:      "Number of references required to start that do not exist",
+		})
+
+	breakGlassActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "break_glass_active",
+			Help:      "Whether the routes with the break-glass-auth annotation accept their alternate authentication",
+		})
+
+	breakGlassRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "break_glass_requests_total",
+			Help:      "Number of requests checked with the break-glass authentication by method and result (accepted or rejected)",
+		},
+		[]string{"method", "result"},
+	)
+
+	reloadVerifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "reload_verifications_total",
+			Help:      "Number of verifications of the canary routes after a reload by result (pass, rollback or regressed)",
+		},
+		[]string{"result"},
+	)
+
+	statusDegraded = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "status_degraded",
+			Help:      "Whether the status of the Ingresses is not updated because the pod of the controller cannot be obtained",
+		})
+
+	statusRepairs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "status_repairs_total",
+			Help:      "Number of Ingresses checked by the status repair by result (valid, repaired, failed or dry-run)",
+		},
+		[]string{"result"},
+	)
+
+	statusLeader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "status_leader",
+			Help:      "Whether this replica is the leader updating the status of the Ingresses",
+		})
+
+	leaderTasksRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "leader_task_running",
+			Help:      "Whether a subsystem run only by the status leader is running in this replica",
+		},
+		[]string{"task"},
+	)
+
+	statusSkippedIngresses = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "status_skipped_ingresses",
+			Help:      "Number of Ingresses of the class whose status is not updated because of the skip-status annotation",
+		})
+
+	syncErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: PrometheusNamespace,
+			Name:      "sync_errors_total",
+			Help:      "Number of failed requests to the API server by component and category (transient, conflict, validation or permission)",
+		},
+		[]string{"component", "category"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(configReloads, lastReloadSuccess, renderDuration, leakSuspected, queueDepth, queueEvents,
+		nginxMasterUp, nginxMasterRestarts, nginxSignalErrors, backendReachable, backendCheckFailures, pendingChanges,
+		clientCertificateChecks, shadowRenders, shadowDifferingLines,
+		colocatedReplicas, authCacheRequests, authCacheEntries, startupGateMissing,
+		reloadVerifications, breakGlassActive, breakGlassRequests, statusRepairs,
+		statusDegraded, statusLeader, leaderTasksRunning, statusSkippedIngresses, syncErrors)
+}
+
+// IncReloadCount increments the counter of successful reloads
+func IncReloadCount() {
+	configReloads.WithLabelValues("success").Inc()
+	lastReloadSuccess.Set(1)
+}
+
+// IncReloadErrorCount increments the counter of failed reloads
+func IncReloadErrorCount() {
+	configReloads.WithLabelValues("error").Inc()
+	lastReloadSuccess.Set(0)
+}
+
+// ObserveRenderDuration records the time required to render the configuration
+func ObserveRenderDuration(d time.Duration) {
+	renderDuration.Observe(d.Seconds())
+}
+
+// IncLeakSuspected increments the counter of suspected leaks of a resource
+func IncLeakSuspected(resource string) {
+	leakSuspected.WithLabelValues(resource).Inc()
+}
+
+// SetQueueDepth sets the number of keys waiting in a queue
+func SetQueueDepth(queue string, depth int) {
+	queueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+// IncQueueEvent increments the counter of events received by a queue
+func IncQueueEvent(queue, result string) {
+	queueEvents.WithLabelValues(queue, result).Inc()
+}
+
+// SetNginxMasterUp sets whether the NGINX master process is running
+func SetNginxMasterUp(up bool) {
+	if up {
+		nginxMasterUp.Set(1)
+		return
+	}
+	nginxMasterUp.Set(0)
+}
+
+// IncNginxMasterRestarts increments the counter of NGINX master process restarts
+func IncNginxMasterRestarts() {
+	nginxMasterRestarts.Inc()
+}
+
+// IncNginxSignalError increments the counter of signals that could not be sent
+func IncNginxSignalError(signal string) {
+	nginxSignalErrors.WithLabelValues(signal).Inc()
+}
+
+// SetBackendReachable sets whether the preflight checks of a backend succeeded
+func SetBackendReachable(backend string, reachable bool) {
+	if reachable {
+		backendReachable.WithLabelValues(backend).Set(1)
+		return
+	}
+	backendReachable.WithLabelValues(backend).Set(0)
+}
+
+// DeleteBackendReachable removes a backend that is no longer configured
+func DeleteBackendReachable(backend string) {
+	backendReachable.DeleteLabelValues(backend)
+}
+
+// IncBackendCheckFailure increments the counter of failed preflight checks
+func IncBackendCheckFailure(check string) {
+	backendCheckFailures.WithLabelValues(check).Inc()
+}
+
+// SetPendingChanges sets the number of Ingresses with changes waiting for approval
+func SetPendingChanges(count int) {
+	pendingChanges.Set(float64(count))
+}
+
+// IncClientCertificateCheck increments the counter of revocation checks of
+// client certificates
+func IncClientCertificateCheck(result string) {
+	clientCertificateChecks.WithLabelValues(result).Inc()
+}
+
+// IncShadowRender increments the counter of shadow renders of the candidate
+// template and sets the number of lines that differ
+func IncShadowRender(result string, differingLines int) {
+	shadowRenders.WithLabelValues(result).Inc()
+	shadowDifferingLines.Set(float64(differingLines))
+}
+
+// SetColocatedReplicas sets the number of replicas sharing a node with
+// another replica
+func SetColocatedReplicas(count int) {
+	colocatedReplicas.Set(float64(count))
+}
+
+// IncAuthCache increments the counter of token validations by the result
+// of the decision cache and sets its number of entries
+func IncAuthCache(hit bool, entries int) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	authCacheRequests.WithLabelValues(result).Inc()
+	authCacheEntries.Set(float64(entries))
+}
+
+// SetStartupGateMissing sets the number of references required to start
+// that do not exist
+func SetStartupGateMissing(count int) {
+	startupGateMissing.Set(float64(count))
+}
+
+// IncReloadVerification increments the counter of verifications of the
+// canary routes after a reload
+func IncReloadVerification(result string) {
+	reloadVerifications.WithLabelValues(result).Inc()
+}
+
+// SetBreakGlassActive sets whether the break-glass authentication is active
+func SetBreakGlassActive(active bool) {
+	if active {
+		breakGlassActive.Set(1)
+		return
+	}
+	breakGlassActive.Set(0)
+}
+
+// IncBreakGlassRequest increments the counter of requests checked with the
+// break-glass authentication
+func IncBreakGlassRequest(method, result string) {
+	breakGlassRequests.WithLabelValues(method, result).Inc()
+}
+
+// SetStatusDegraded sets whether the status of the Ingresses is not
+// updated because the pod of the controller cannot be obtained
+func SetStatusDegraded(degraded bool) {
+	if degraded {
+		statusDegraded.Set(1)
+		return
+	}
+	statusDegraded.Set(0)
+}
+
+// IncStatusRepair increments the counter of Ingresses checked by the status
+// repair
+func IncStatusRepair(result string) {
+	statusRepairs.WithLabelValues(result).Inc()
+}
+
+// SetStatusLeader sets whether this replica is the leader updating the
+// status of the Ingresses
+func SetStatusLeader(leader bool) {
+	if leader {
+		statusLeader.Set(1)
+		return
+	}
+	statusLeader.Set(0)
+}
+
+// SetLeaderTaskRunning sets whether a subsystem run only by the status
+// leader is running
+func SetLeaderTaskRunning(task string, running bool) {
+	if running {
+		leaderTasksRunning.WithLabelValues(task).Set(1)
+		return
+	}
+	leaderTasksRunning.WithLabelValues(task).Set(0)
+}
+
+// SetStatusSkippedIngresses sets the number of Ingresses whose status is
+// not updated because of the skip-status annotation
+func SetStatusSkippedIngresses(count int) {
+	statusSkippedIngresses.Set(float64(count))
+}
+
+// IncSyncError increments the counter of failed requests to the API server
+// of a component by category of error
+func IncSyncError(component, category string) {
+	syncErrors.WithLabelValues(component, category).Inc()
+}
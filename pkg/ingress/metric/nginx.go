@@ -0,0 +1,291 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package metric
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	budgetViolationsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "budget_violations_total"),
+		"Number of requests that exceeded the latency or response size budget of an Ingress",
+		[]string{"namespace", "ingress", "budget"}, nil)
+
+	customRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "custom_requests_total"),
+		"Number of requests matching the conditions of the counters defined in the custom-counters annotation",
+		[]string{"namespace", "ingress", "counter"}, nil)
+
+	rejectedRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "rejected_requests_total"),
+		"Number of requests rejected by the request normalization",
+		[]string{"namespace", "ingress", "reason"}, nil)
+
+	websocketSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "websocket_sessions"),
+		"Number of open websocket sessions of the locations with limits",
+		[]string{"namespace", "ingress", "location"}, nil)
+
+	websocketRejectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "websocket_rejections_total"),
+		"Number of websocket sessions rejected because a limit was reached",
+		[]string{"namespace", "ingress", "limit"}, nil)
+
+	taggedReceivedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "tagged_request_bytes_total"),
+		"Number of bytes received in the requests, by the tag set with the cost-tag annotation",
+		[]string{"namespace", "ingress", "tag"}, nil)
+
+	taggedSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "tagged_response_bytes_total"),
+		"Number of bytes sent in the responses, by the tag set with the cost-tag annotation",
+		[]string{"namespace", "ingress", "tag"}, nil)
+
+	websocketClosesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "websocket_closes_total"),
+		"Number of websocket sessions closed, by reason",
+		[]string{"namespace", "ingress", "reason"}, nil)
+
+	uploadsInProgressDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "uploads_in_progress"),
+		"Number of requests streaming a body to the backend in the locations with the upload profile",
+		[]string{"namespace", "ingress", "location"}, nil)
+
+	uploadBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "upload_bytes_total"),
+		"Number of bytes received in the finished requests of the locations with the upload profile",
+		[]string{"namespace", "ingress", "location"}, nil)
+
+	uploadRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "upload_requests_total"),
+		"Number of finished upload requests, by result",
+		[]string{"namespace", "ingress", "result"}, nil)
+
+	backendRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "backend_requests_total"),
+		"Number of finished requests proxied to a backend Service",
+		[]string{"namespace", "ingress", "service"}, nil)
+
+	backendActiveRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "backend_active_requests"),
+		"Number of requests being proxied to a backend Service",
+		[]string{"namespace", "ingress", "service"}, nil)
+
+	surgeRejectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "surge_rejections_total"),
+		"Number of requests rejected by the surge protection while the backend had too few ready pods, by reason",
+		[]string{"namespace", "ingress", "reason"}, nil)
+
+	fairnessRejectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "fairness_rejections_total"),
+		"Number of requests rejected because the client had too many requests in progress, by client key",
+		[]string{"namespace", "ingress", "key"}, nil)
+
+	tierRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "tier_requests_total"),
+		"Number of requests classified in each tier of the request-tiers ConfigMap key",
+		[]string{"namespace", "ingress", "tier"}, nil)
+
+	tierRejectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "tier_rejections_total"),
+		"Number of requests rejected by the rate or the concurrency limit of their tier",
+		[]string{"namespace", "ingress", "tier"}, nil)
+
+	ejectedEndpointsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(PrometheusNamespace, "", "ejected_endpoints"),
+		"Endpoints of a backend currently ejected by the outlier detection, by reason",
+		[]string{"backend", "endpoint", "reason"}, nil)
+)
+
+// NginxCounterCollector exposes counters kept by NGINX in a shared dict
+type NginxCounterCollector struct {
+	url       string
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	client    *http.Client
+}
+
+// NewBudgetCollector returns a collector that reads the budget violations
+// from the internal NGINX server listening on port
+func NewBudgetCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/budget-violations", budgetViolationsDesc)
+}
+
+// NewCustomCounterCollector returns a collector that reads the custom
+// counters from the internal NGINX server listening on port
+func NewCustomCounterCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/custom-counters", customRequestsDesc)
+}
+
+// NewRejectedRequestCollector returns a collector that reads the requests
+// rejected by the request normalization from the internal NGINX server
+func NewRejectedRequestCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/normalization-rejections", rejectedRequestsDesc)
+}
+
+// NewWebsocketSessionCollector returns a collector that reads the open
+// websocket sessions from the internal NGINX server
+func NewWebsocketSessionCollector(port int) *NginxCounterCollector {
+	c := newNginxCounterCollector(port, "/websocket-sessions", websocketSessionsDesc)
+	c.valueType = prometheus.GaugeValue
+	return c
+}
+
+// NewWebsocketRejectionCollector returns a collector that reads the rejected
+// websocket sessions from the internal NGINX server
+func NewWebsocketRejectionCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/websocket-rejections", websocketRejectionsDesc)
+}
+
+// NewWebsocketCloseCollector returns a collector that reads the reasons the
+// websocket sessions were closed from the internal NGINX server
+func NewWebsocketCloseCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/websocket-closes", websocketClosesDesc)
+}
+
+// NewTaggedRequestBytesCollector returns a collector that reads the bytes
+// received per cost tag from the internal NGINX server
+func NewTaggedRequestBytesCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/cost-bytes-received", taggedReceivedDesc)
+}
+
+// NewTaggedResponseBytesCollector returns a collector that reads the bytes
+// sent per cost tag from the internal NGINX server
+func NewTaggedResponseBytesCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/cost-bytes-sent", taggedSentDesc)
+}
+
+// NewUploadsInProgressCollector returns a collector that reads the uploads
+// in progress from the internal NGINX server
+func NewUploadsInProgressCollector(port int) *NginxCounterCollector {
+	c := newNginxCounterCollector(port, "/uploads-in-progress", uploadsInProgressDesc)
+	c.valueType = prometheus.GaugeValue
+	return c
+}
+
+// NewUploadBytesCollector returns a collector that reads the bytes received
+// by the upload locations from the internal NGINX server
+func NewUploadBytesCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/upload-bytes", uploadBytesDesc)
+}
+
+// NewUploadRequestCollector returns a collector that reads the results of
+// the upload requests from the internal NGINX server
+func NewUploadRequestCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/upload-requests", uploadRequestsDesc)
+}
+
+// NewBackendRequestCollector returns a collector that reads the requests
+// proxied to each backend Service from the internal NGINX server
+func NewBackendRequestCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/backend-requests", backendRequestsDesc)
+}
+
+// NewBackendActiveRequestCollector returns a collector that reads the
+// requests in progress of each backend Service from the internal NGINX
+// server
+func NewBackendActiveRequestCollector(port int) *NginxCounterCollector {
+	c := newNginxCounterCollector(port, "/backend-active-requests", backendActiveRequestsDesc)
+	c.valueType = prometheus.GaugeValue
+	return c
+}
+
+// NewSurgeRejectionCollector returns a collector that reads the requests
+// rejected by the surge protection from the internal NGINX server
+func NewSurgeRejectionCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/surge-rejections", surgeRejectionsDesc)
+}
+
+// NewFairnessRejectionCollector returns a collector that reads the requests
+// rejected by the per client limits from the internal NGINX server
+func NewFairnessRejectionCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/fairness-rejections", fairnessRejectionsDesc)
+}
+
+// NewTierRequestCollector returns a collector that reads the requests
+// classified in each tier from the internal NGINX server
+func NewTierRequestCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/tier-requests", tierRequestsDesc)
+}
+
+// NewTierRejectionCollector returns a collector that reads the requests
+// rejected by the limits of their tier from the internal NGINX server
+func NewTierRejectionCollector(port int) *NginxCounterCollector {
+	return newNginxCounterCollector(port, "/tier-rejections", tierRejectionsDesc)
+}
+
+// NewEjectedEndpointCollector returns a collector that reads the endpoints
+// ejected by the outlier detection from the internal NGINX server
+func NewEjectedEndpointCollector(port int) *NginxCounterCollector {
+	c := newNginxCounterCollector(port, "/ejected-endpoints", ejectedEndpointsDesc)
+	c.valueType = prometheus.GaugeValue
+	return c
+}
+
+func newNginxCounterCollector(port int, path string, desc *prometheus.Desc) *NginxCounterCollector {
+	return &NginxCounterCollector{
+		url:       fmt.Sprintf("http://127.0.0.1:%v%v", port, path),
+		desc:      desc,
+		valueType: prometheus.CounterValue,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *NginxCounterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *NginxCounterCollector) Collect(ch chan<- prometheus.Metric) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		glog.V(3).Infof("unexpected error reading %v: %v", c.url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		glog.V(3).Infof("unexpected status reading %v: %v", c.url, resp.StatusCode)
+		return
+	}
+
+	for _, v := range parseNginxCounters(resp.Body) {
+		ch <- prometheus.MustNewConstMetric(c.desc, c.valueType, v.count, v.labels...)
+	}
+}
+
+type nginxCounter struct {
+	labels []string
+	count  float64
+}
+
+// parseNginxCounters reads the lines "<namespace> <ingress> <name> <count>"
+// written by NGINX and skips the malformed ones
+func parseNginxCounters(r io.Reader) []nginxCounter {
+	var res []nginxCounter
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		count, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			continue
+		}
+		res = append(res, nginxCounter{fields[:3], count})
+	}
+	return res
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package metric
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNginxCounters(t *testing.T) {
+	in := `default api latency 3
+default api response_size 1
+broken line
+kube-system console latency NaN?
+`
+	expected := []nginxCounter{
+		{[]string{"default", "api", "latency"}, 3},
+		{[]string{"default", "api", "response_size"}, 1},
+	}
+
+	res := parseNginxCounters(strings.NewReader(in))
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, res)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modelcache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+const (
+	modelFile  = "model.json"
+	configFile = "nginx.conf"
+	// sslFiles is the directory of the copies of the files of the SSL
+	// directory
+	sslFiles = "ssl"
+)
+
+// Cache stores the last ingress model and the NGINX configuration rendered
+// from it in a local directory so a restarting controller can serve the
+// previous configuration while the informers are synced. The certificates
+// and keys of the SSL directory referenced by the configuration are kept
+// too, as the SSL directory may be an emptyDir recreated with the pod.
+type Cache struct {
+	dir    string
+	sslDir string
+}
+
+// New returns a cache that uses the given directory and keeps the files of
+// sslDir, none if empty
+func New(dir, sslDir string) *Cache {
+	return &Cache{dir: dir, sslDir: sslDir}
+}
+
+// Save writes the model, the rendered configuration and the files of the
+// SSL directory. The files are replaced atomically to avoid leaving a
+// partial cache after a crash.
+func (c *Cache) Save(model *ingress.Configuration, content []byte) error {
+	b, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	// the files referenced by the configuration are saved before it
+	if err := c.saveSSLFiles(); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(c.dir, configFile), content); err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(c.dir, modelFile), b)
+}
+
+// saveSSLFiles copies the regular files of the SSL directory to the cache,
+// and removes the copies of the files that do not exist anymore
+func (c *Cache) saveSSLFiles() error {
+	if c.sslDir == "" {
+		return nil
+	}
+
+	dst := filepath.Join(c.dir, sslFiles)
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(c.sslDir)
+	if err != nil {
+		return err
+	}
+	saved := map[string]bool{}
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(c.sslDir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dst, f.Name()), content); err != nil {
+			return err
+		}
+		saved[f.Name()] = true
+	}
+
+	copies, err := ioutil.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, f := range copies {
+		if !saved[f.Name()] {
+			_ = os.Remove(filepath.Join(dst, f.Name()))
+		}
+	}
+	return nil
+}
+
+// RestoreSSLFiles writes the cached files of the SSL directory missing in
+// it, like after the pod is recreated, so the cached configuration finds
+// its certificates
+func (c *Cache) RestoreSSLFiles() error {
+	if c.sslDir == "" {
+		return nil
+	}
+
+	src := filepath.Join(c.dir, sslFiles)
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(c.sslDir, 0700); err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(c.sslDir, f.Name())
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(src, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := writeFile(path, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load returns the cached model and configuration
+func (c *Cache) Load() (*ingress.Configuration, []byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(c.dir, modelFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	model := &ingress.Configuration{}
+	if err := json.Unmarshal(b, model); err != nil {
+		return nil, nil, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(c.dir, configFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return model, content, nil
+}
+
+func writeFile(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
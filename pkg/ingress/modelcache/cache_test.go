@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modelcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modelcache")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := New(filepath.Join(dir, "cache"), "")
+	if _, _, err := c.Load(); err == nil {
+		t.Errorf("expected an error loading an empty cache")
+	}
+
+	model := &ingress.Configuration{
+		Backends: []*ingress.Backend{{Name: "default-echo-80"}},
+		Servers:  []*ingress.Server{{Hostname: "example.com"}},
+	}
+	content := []byte("events {}")
+
+	if err := c.Save(model, content); err != nil {
+		t.Fatalf("unexpected error saving the cache: %v", err)
+	}
+
+	m, b, err := c.Load()
+	if err != nil {
+		t.Fatalf("unexpected error loading the cache: %v", err)
+	}
+
+	if !m.Equal(model) {
+		t.Errorf("expected %v but returned %v", model, m)
+	}
+	if string(b) != string(content) {
+		t.Errorf("expected %q but returned %q", content, b)
+	}
+}
+
+func TestRestoreSSLFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modelcache")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sslDir := filepath.Join(dir, "ssl")
+	if err := os.MkdirAll(sslDir, 0700); err != nil {
+		t.Fatalf("unexpected error creating the SSL directory: %v", err)
+	}
+	cert := filepath.Join(sslDir, "default-console.pem")
+	if err := ioutil.WriteFile(cert, []byte("certificate"), 0600); err != nil {
+		t.Fatalf("unexpected error writing the certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sslDir, "default-old.pem"), []byte("old"), 0600); err != nil {
+		t.Fatalf("unexpected error writing the certificate: %v", err)
+	}
+
+	c := New(filepath.Join(dir, "cache"), sslDir)
+	if err := c.Save(&ingress.Configuration{}, []byte("ssl_certificate "+cert+";")); err != nil {
+		t.Fatalf("unexpected error saving the cache: %v", err)
+	}
+	// the copies of the removed certificates are removed with the next save
+	if err := os.Remove(filepath.Join(sslDir, "default-old.pem")); err != nil {
+		t.Fatalf("unexpected error removing the certificate: %v", err)
+	}
+	if err := c.Save(&ingress.Configuration{}, []byte("ssl_certificate "+cert+";")); err != nil {
+		t.Fatalf("unexpected error saving the cache: %v", err)
+	}
+
+	// the pod is recreated with an empty SSL directory
+	if err := os.RemoveAll(sslDir); err != nil {
+		t.Fatalf("unexpected error removing the SSL directory: %v", err)
+	}
+	if err := c.RestoreSSLFiles(); err != nil {
+		t.Fatalf("unexpected error restoring the certificates: %v", err)
+	}
+
+	_, content, err := c.Load()
+	if err != nil {
+		t.Fatalf("unexpected error loading the cache: %v", err)
+	}
+	if string(content) != "ssl_certificate "+cert+";" {
+		t.Errorf("expected the configuration referencing %v but returned %q", cert, content)
+	}
+	b, err := ioutil.ReadFile(cert)
+	if err != nil || string(b) != "certificate" {
+		t.Errorf("expected the certificate restored but returned %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(sslDir, "default-old.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected the removed certificate not restored but returned %v", err)
+	}
+
+	// the files written after the restart are kept
+	if err := ioutil.WriteFile(cert, []byte("new certificate"), 0600); err != nil {
+		t.Fatalf("unexpected error writing the certificate: %v", err)
+	}
+	if err := c.RestoreSSLFiles(); err != nil {
+		t.Fatalf("unexpected error restoring the certificates: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(cert); string(b) != "new certificate" {
+		t.Errorf("expected the new certificate kept but returned %q", b)
+	}
+}
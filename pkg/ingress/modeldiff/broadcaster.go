@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modeldiff
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// subscriberBuffer is the number of events kept for a slow subscriber
+// before new events are dropped, and the number of events kept to resume
+// the streams
+const subscriberBuffer = 64
+
+// Event contains the changes applied in one reload or the reason
+// why the reload failed
+type Event struct {
+	// Sequence increases with every event. A snapshot has the sequence of
+	// the last event it contains.
+	Sequence  uint64    `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+	// Snapshot is set when the changes add the whole running model, sent
+	// to the streams that can not resume from the events kept
+	Snapshot bool     `json:"snapshot,omitempty"`
+	Changes  []Change `json:"changes,omitempty"`
+	// ReloadError is set when the new configuration could not be applied
+	ReloadError string `json:"reloadError,omitempty"`
+}
+
+// subscriber receives the events in ch. The streams are disconnected
+// when they do not read the events, instead of missing them, so they
+// resume from the last one received.
+type subscriber struct {
+	ch         chan Event
+	disconnect bool
+}
+
+// Broadcaster sends the model events to every subscriber
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]*subscriber
+	// seq is the sequence of the last event. It starts at the time of
+	// creation, so the sequences of a restarted controller are newer than
+	// the ones received before.
+	seq uint64
+	// history contains the last events, to resume the streams
+	history []Event
+	// model is the running model of the snapshots
+	model *ingress.Configuration
+}
+
+// NewBroadcaster returns a broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers: map[chan Event]*subscriber{},
+		seq:         uint64(time.Now().UnixNano()),
+	}
+}
+
+// Subscribe returns a channel that receives the published events and a
+// function that must be called to stop receiving them
+func (b *Broadcaster) Subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(false)
+}
+
+// Stream returns the events to send first to a client resuming after the
+// sequence since, the new events, and a function that must be called to
+// stop receiving them. The first events are the ones kept after since, or
+// a snapshot of the model if they are not kept or resume is false. The
+// channel is closed when the client does not read the events.
+func (b *Broadcaster) Stream(since uint64, resume bool) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	switch {
+	case resume && since == b.seq:
+	case resume && since < b.seq && len(b.history) > 0 && since+1 >= b.history[0].Sequence:
+		for _, e := range b.history {
+			if e.Sequence > since {
+				backlog = append(backlog, e)
+			}
+		}
+	default:
+		backlog = []Event{{
+			Sequence:  b.seq,
+			Timestamp: time.Now(),
+			Snapshot:  true,
+			Changes:   Diff(nil, b.model),
+		}}
+	}
+
+	ch, cancel := b.subscribe(true)
+	return backlog, ch, cancel
+}
+
+func (b *Broadcaster) subscribe(disconnect bool) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = &subscriber{ch: ch, disconnect: disconnect}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SetModel replaces the running model of the snapshots. It must be set
+// before the event of its changes is published.
+func (b *Broadcaster) SetModel(cfg *ingress.Configuration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.model = cfg
+}
+
+// Publish sends the event to the subscribers. Subscribers that are not
+// reading the events do not block the controller: they miss the event,
+// or are disconnected if they are streams.
+func (b *Broadcaster) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Sequence = b.seq
+	b.history = append(b.history, e)
+	if len(b.history) > subscriberBuffer {
+		b.history = b.history[1:]
+	}
+
+	for ch, s := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			if s.disconnect {
+				glog.Warningf("disconnecting slow model stream")
+				delete(b.subscribers, ch)
+				close(ch)
+				continue
+			}
+			glog.Warningf("dropping model event for slow subscriber")
+		}
+	}
+}
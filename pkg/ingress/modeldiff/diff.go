@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modeldiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+const (
+	// KindBackend identifies changes in the list of backends
+	KindBackend = "backend"
+	// KindServer identifies changes in the list of servers
+	KindServer = "server"
+	// KindLocation identifies changes in the locations of a server
+	KindLocation = "location"
+	// KindCertificate identifies a rotation of the certificate of a server
+	KindCertificate = "certificate"
+
+	// ActionAdded indicates the element is new
+	ActionAdded = "added"
+	// ActionRemoved indicates the element does not exist anymore
+	ActionRemoved = "removed"
+	// ActionChanged indicates the element exists in both models with different content
+	ActionChanged = "changed"
+)
+
+// Change describes one difference between two ingress models
+type Change struct {
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	// Name is the backend name, the server hostname or <hostname><path> for locations
+	Name string `json:"name"`
+}
+
+// Diff returns the changes required to go from the old to the new model.
+// The result is sorted by kind and name.
+func Diff(old, cur *ingress.Configuration) []Change {
+	if old == nil {
+		old = &ingress.Configuration{}
+	}
+	if cur == nil {
+		cur = &ingress.Configuration{}
+	}
+
+	var changes []Change
+
+	oldBackends := map[string]*ingress.Backend{}
+	for _, b := range old.Backends {
+		oldBackends[b.Name] = b
+	}
+	curBackends := map[string]*ingress.Backend{}
+	for _, b := range cur.Backends {
+		curBackends[b.Name] = b
+		ob, ok := oldBackends[b.Name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{KindBackend, ActionAdded, b.Name})
+		case !ob.Equal(b):
+			changes = append(changes, Change{KindBackend, ActionChanged, b.Name})
+		}
+	}
+	for name := range oldBackends {
+		if _, ok := curBackends[name]; !ok {
+			changes = append(changes, Change{KindBackend, ActionRemoved, name})
+		}
+	}
+
+	oldServers := map[string]*ingress.Server{}
+	for _, s := range old.Servers {
+		oldServers[s.Hostname] = s
+	}
+	curServers := map[string]*ingress.Server{}
+	for _, s := range cur.Servers {
+		curServers[s.Hostname] = s
+		prev, ok := oldServers[s.Hostname]
+		if !ok {
+			changes = append(changes, Change{KindServer, ActionAdded, s.Hostname})
+			continue
+		}
+
+		if prev.SSLPemChecksum != s.SSLPemChecksum {
+			action := ActionChanged
+			if prev.SSLPemChecksum == "" {
+				action = ActionAdded
+			} else if s.SSLPemChecksum == "" {
+				action = ActionRemoved
+			}
+			changes = append(changes, Change{KindCertificate, action, s.Hostname})
+		}
+
+		changes = append(changes, diffLocations(prev, s)...)
+	}
+	for name := range oldServers {
+		if _, ok := curServers[name]; !ok {
+			changes = append(changes, Change{KindServer, ActionRemoved, name})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+func diffLocations(old, cur *ingress.Server) []Change {
+	var changes []Change
+
+	oldLocations := map[string]*ingress.Location{}
+	for _, l := range old.Locations {
+		oldLocations[l.Path] = l
+	}
+	curLocations := map[string]*ingress.Location{}
+	for _, l := range cur.Locations {
+		curLocations[l.Path] = l
+		name := fmt.Sprintf("%v%v", cur.Hostname, l.Path)
+		ol, ok := oldLocations[l.Path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{KindLocation, ActionAdded, name})
+		case !ol.Equal(l):
+			changes = append(changes, Change{KindLocation, ActionChanged, name})
+		}
+	}
+	for path := range oldLocations {
+		if _, ok := curLocations[path]; !ok {
+			changes = append(changes, Change{KindLocation, ActionRemoved, fmt.Sprintf("%v%v", cur.Hostname, path)})
+		}
+	}
+
+	return changes
+}
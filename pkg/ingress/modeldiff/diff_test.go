@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modeldiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func TestDiff(t *testing.T) {
+	old := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{Name: "default-a-80"},
+			{Name: "default-b-80", Secure: false},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname:       "foo.bar",
+				SSLPemChecksum: "1",
+				Locations:      []*ingress.Location{{Path: "/a"}, {Path: "/b"}},
+			},
+			{Hostname: "old.bar"},
+		},
+	}
+
+	cur := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{Name: "default-b-80", Secure: true},
+			{Name: "default-c-80"},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname:       "foo.bar",
+				SSLPemChecksum: "2",
+				Locations:      []*ingress.Location{{Path: "/a"}, {Path: "/c"}},
+			},
+			{Hostname: "new.bar"},
+		},
+	}
+
+	expected := []Change{
+		{KindBackend, ActionRemoved, "default-a-80"},
+		{KindBackend, ActionChanged, "default-b-80"},
+		{KindBackend, ActionAdded, "default-c-80"},
+		{KindCertificate, ActionChanged, "foo.bar"},
+		{KindLocation, ActionRemoved, "foo.bar/b"},
+		{KindLocation, ActionAdded, "foo.bar/c"},
+		{KindServer, ActionAdded, "new.bar"},
+		{KindServer, ActionRemoved, "old.bar"},
+	}
+
+	changes := Diff(old, cur)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v but returned %v", expected, changes)
+	}
+
+	if changes := Diff(cur, cur); len(changes) != 0 {
+		t.Errorf("expected no changes but returned %v", changes)
+	}
+
+	if changes := Diff(nil, cur); len(changes) != 4 {
+		t.Errorf("expected 4 changes from an empty model but returned %v", changes)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modeldiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// Authorizer validates the bearer token of a request
+type Authorizer interface {
+	Authorize(ctx context.Context, token string) error
+}
+
+// TokenAuthorizer accepts ServiceAccount (or any other Kubernetes) tokens
+// of users allowed to list Ingresses in all namespaces
+type TokenAuthorizer struct {
+	Client clientset.Interface
+	// Verb is the action on the Ingresses the users must be allowed to
+	// do, list if empty
+	Verb string
+}
+
+// Authorize validates the token with a TokenReview and checks the permissions
+// of the user with a SubjectAccessReview
+func (a TokenAuthorizer) Authorize(ctx context.Context, token string) error {
+	return a.AuthorizeNamespace(ctx, token, "")
+}
+
+// AuthorizeNamespace is Authorize for the Ingresses of a namespace, all the
+// namespaces if empty
+func (a TokenAuthorizer) AuthorizeNamespace(ctx context.Context, token, namespace string) error {
+	tr, err := a.Client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !tr.Status.Authenticated {
+		return fmt.Errorf("invalid token: %v", tr.Status.Error)
+	}
+
+	verb := a.Verb
+	if verb == "" {
+		verb = "list"
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range tr.Status.User.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar, err := a.Client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   tr.Status.User.Username,
+			UID:    tr.Status.User.UID,
+			Groups: tr.Status.User.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     "networking.k8s.io",
+				Resource:  "ingresses",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !sar.Status.Allowed {
+		return fmt.Errorf("user %v cannot %v ingresses", tr.Status.User.Username, verb)
+	}
+
+	return nil
+}
+
+// RequireToken only accepts GET requests with a bearer token accepted by auth
+func RequireToken(auth Authorizer, h http.Handler) http.Handler {
+	return RequireMethodToken(auth, http.MethodGet, h)
+}
+
+// RequireMethodToken only accepts requests with the method and a bearer
+// token accepted by auth
+func RequireMethodToken(auth Authorizer, method string, h http.Handler) http.Handler {
+	return requireToken(method, func(r *http.Request, token string) error {
+		return auth.Authorize(r.Context(), token)
+	}, h)
+}
+
+// RequireNamespaceToken only accepts requests with the method and a bearer
+// token accepted by auth in the namespace of the query parameter
+func RequireNamespaceToken(auth TokenAuthorizer, method, param string, h http.Handler) http.Handler {
+	return requireToken(method, func(r *http.Request, token string) error {
+		namespace := r.URL.Query().Get(param)
+		if namespace == "" {
+			return fmt.Errorf("missing %v", param)
+		}
+		return auth.AuthorizeNamespace(r.Context(), token, namespace)
+	}, h)
+}
+
+func requireToken(method string, authorize func(*http.Request, string) error, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if err := authorize(r, token); err != nil {
+			glog.V(2).Infof("rejecting request to %v: %v", r.URL.Path, err)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// streamWriteTimeout is the maximum time to write an event to a stream,
+// which replaces the write timeout of the server for the streams
+const streamWriteTimeout = 30 * time.Second
+
+type connKey struct{}
+
+// ConnContext adds the connection to the context of its requests, so the
+// streams can replace its write deadline. It is the ConnContext of the
+// server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// Handler streams the model events as newline delimited JSON until the
+// client closes the connection. The stream starts with a snapshot of the
+// model, or with the events after the sequence of the since query
+// parameter when they are kept, so the clients resume without missing
+// events. The events after a snapshot may repeat changes it contains.
+func Handler(b *Broadcaster, auth Authorizer) http.Handler {
+	return RequireToken(auth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		var since uint64
+		resume := false
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			since, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid since", http.StatusBadRequest)
+				return
+			}
+			resume = true
+		}
+
+		backlog, events, cancel := b.Stream(since, resume)
+		defer cancel()
+
+		conn, _ := r.Context().Value(connKey{}).(net.Conn)
+		deadline := func() {
+			if conn != nil {
+				conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		deadline()
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		enc := json.NewEncoder(w)
+		write := func(e Event) bool {
+			deadline()
+			if err := enc.Encode(e); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+		for _, e := range backlog {
+			if !write(e) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-events:
+				// a slow client is disconnected to resume
+				if !ok || !write(e) {
+					return
+				}
+			}
+		}
+	}))
+}
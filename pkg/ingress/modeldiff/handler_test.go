@@ -0,0 +1,211 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package modeldiff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+type fakeAuthorizer struct {
+	token string
+}
+
+func (a fakeAuthorizer) Authorize(ctx context.Context, token string) error {
+	if token != a.token {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
+
+func TestHandlerAuth(t *testing.T) {
+	srv := httptest.NewServer(Handler(NewBroadcaster(), fakeAuthorizer{"valid"}))
+	defer srv.Close()
+
+	testCases := []struct {
+		header   string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"valid", http.StatusUnauthorized},
+		{"Bearer invalid", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("expected %v for %q but returned %v", tc.expected, tc.header, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandlerStream(t *testing.T) {
+	b := NewBroadcaster()
+	srv := httptest.NewServer(Handler(b, fakeAuthorizer{"valid"}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but returned %v", resp.StatusCode)
+	}
+
+	go func() {
+		// the subscription is created before the headers are sent
+		b.Publish(Event{
+			Timestamp: time.Now(),
+			Changes:   []Change{{KindBackend, ActionAdded, "default-a-80"}},
+		})
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var events []Event
+	for len(events) < 2 && scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("unexpected error decoding event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected a snapshot and an event: %v", scanner.Err())
+	}
+
+	if !events[0].Snapshot {
+		t.Errorf("expected the stream to start with a snapshot but returned %v", events[0])
+	}
+	e := events[1]
+	if len(e.Changes) != 1 || e.Changes[0].Name != "default-a-80" {
+		t.Errorf("unexpected event %v", e)
+	}
+	if e.Sequence != events[0].Sequence+1 {
+		t.Errorf("expected the sequence %v but returned %v", events[0].Sequence+1, e.Sequence)
+	}
+}
+
+func TestBroadcasterStream(t *testing.T) {
+	b := NewBroadcaster()
+	b.SetModel(&ingress.Configuration{Backends: []*ingress.Backend{{Name: "default-a-80"}}})
+	backlog, _, cancel := b.Stream(0, false)
+	cancel()
+	if len(backlog) != 1 || !backlog[0].Snapshot || len(backlog[0].Changes) != 1 {
+		t.Fatalf("expected a snapshot of the model but returned %v", backlog)
+	}
+	start := backlog[0].Sequence
+
+	for i := 0; i < 3; i++ {
+		b.Publish(Event{Timestamp: time.Now()})
+	}
+	backlog, _, cancel = b.Stream(start+1, true)
+	cancel()
+	if len(backlog) != 2 || backlog[0].Sequence != start+2 || backlog[1].Sequence != start+3 {
+		t.Errorf("expected the events after %v but returned %v", start+1, backlog)
+	}
+	backlog, _, cancel = b.Stream(start+3, true)
+	cancel()
+	if len(backlog) != 0 {
+		t.Errorf("expected no event for a stream up to date but returned %v", backlog)
+	}
+
+	// the events of another controller, or not kept, need a snapshot
+	for _, since := range []uint64{start + 10, 1} {
+		backlog, _, cancel = b.Stream(since, true)
+		cancel()
+		if len(backlog) != 1 || !backlog[0].Snapshot || backlog[0].Sequence != start+3 {
+			t.Errorf("expected a snapshot resuming from %v but returned %v", since, backlog)
+		}
+	}
+
+	// a stream not reading the events is closed, a subscriber misses them
+	_, stream, cancelStream := b.Stream(0, false)
+	defer cancelStream()
+	events, cancelSub := b.Subscribe()
+	defer cancelSub()
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(Event{Timestamp: time.Now()})
+	}
+	n := 0
+	for range stream {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected the slow stream closed after %v events but returned %v", subscriberBuffer, n)
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("expected the subscriber to keep %v events but returned %v", subscriberBuffer, len(events))
+	}
+}
+
+func TestRequireNamespaceToken(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		tr.Status.Authenticated = tr.Spec.Token == "approver"
+		tr.Status.User.Username = tr.Spec.Token
+		return true, tr, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = attrs.Verb == "approve" && attrs.Namespace == "team-a"
+		return true, sar, nil
+	})
+
+	h := RequireNamespaceToken(TokenAuthorizer{Client: client, Verb: "approve"}, http.MethodPost, "namespace",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) }))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	testCases := []struct {
+		token     string
+		namespace string
+		expected  int
+	}{
+		{"approver", "team-a", http.StatusAccepted},
+		{"approver", "team-b", http.StatusForbidden},
+		{"approver", "", http.StatusForbidden},
+		{"other", "team-a", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"?namespace="+tc.namespace, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("expected %v for %v in %q but returned %v", tc.expected, tc.token, tc.namespace, resp.StatusCode)
+		}
+	}
+}
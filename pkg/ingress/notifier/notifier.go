@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+)
+
+const (
+	// SignatureHeader contains the HMAC-SHA256 of the body using the shared secret
+	SignatureHeader = "X-Management-Ingress-Signature"
+)
+
+// Config configures the webhook notifier
+type Config struct {
+	// URLs receive a POST request for every event
+	URLs []string
+	// Secret is the key used to sign the payload. The signature is not sent if empty
+	Secret []byte
+	// Retries is the number of attempts after a failed delivery
+	Retries int
+	// Timeout of each request
+	Timeout time.Duration
+}
+
+// Payload is the JSON document sent to the webhooks
+type Payload struct {
+	// Source identifies the controller that sent the notification
+	Source string `json:"source"`
+	modeldiff.Event
+}
+
+// Notifier sends the model events to the configured webhooks
+type Notifier struct {
+	Config
+
+	source  string
+	client  *http.Client
+	backoff wait.Backoff
+}
+
+// New returns a notifier. The source is included in every payload to
+// identify the sender, usually the name of the pod.
+func New(cfg Config, source string) *Notifier {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Notifier{
+		Config: cfg,
+		source: source,
+		client: &http.Client{Timeout: cfg.Timeout},
+		backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    cfg.Retries + 1,
+		},
+	}
+}
+
+// Run sends the received events until the channel is closed
+func (n *Notifier) Run(events <-chan modeldiff.Event) {
+	for e := range events {
+		body, err := json.Marshal(Payload{Source: n.source, Event: e})
+		if err != nil {
+			glog.Errorf("unexpected error encoding webhook payload: %v", err)
+			continue
+		}
+
+		for _, url := range n.URLs {
+			if err := n.send(url, body); err != nil {
+				glog.Warningf("unable to notify webhook %v: %v", url, err)
+			}
+		}
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	// #nosec
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *Notifier) send(url string, body []byte) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(n.backoff, func() (bool, error) {
+		lastErr = n.post(url, body)
+		if lastErr != nil {
+			glog.V(2).Infof("webhook %v failed: %v", url, lastErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return lastErr
+	}
+	return err
+}
+
+func (n *Notifier) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.Secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	// #nosec
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
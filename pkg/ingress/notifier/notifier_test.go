@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/modeldiff"
+)
+
+func TestNotifier(t *testing.T) {
+	secret := []byte("secret")
+
+	var calls int32
+	received := make(chan Payload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first attempt to force a retry
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != "sha256="+Sign(secret, body) {
+			t.Errorf("invalid signature %v", r.Header.Get(SignatureHeader))
+		}
+
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("unexpected error decoding payload: %v", err)
+		}
+		received <- p
+	}))
+	defer srv.Close()
+
+	n := New(Config{URLs: []string{srv.URL}, Secret: secret, Retries: 2}, "pod-a")
+	n.backoff.Duration = 10 * time.Millisecond
+
+	events := make(chan modeldiff.Event, 1)
+	events <- modeldiff.Event{ReloadError: "invalid configuration"}
+	close(events)
+	n.Run(events)
+
+	select {
+	case p := <-received:
+		if p.Source != "pod-a" || p.ReloadError != "invalid configuration" {
+			t.Errorf("unexpected payload %v", p)
+		}
+	default:
+		t.Errorf("expected a notification")
+	}
+
+	if c := atomic.LoadInt32(&calls); c != 2 {
+		t.Errorf("expected 2 attempts but returned %v", c)
+	}
+}
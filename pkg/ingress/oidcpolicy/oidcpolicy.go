@@ -0,0 +1,228 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package oidcpolicy maps the routes of the Ingresses to the OIDC audiences
+// and scopes their tokens require, and the claims forwarded to the backends,
+// with the cluster scoped OIDCRoutePolicy resources. The policies are owned
+// by the security teams with their own RBAC, instead of the annotations of
+// the Ingresses.
+package oidcpolicy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Resource is the resource of the OIDCRoutePolicy CRD
+var Resource = schema.GroupVersionResource{Group: "ingress.open-cluster-management.io", Version: "v1alpha1", Resource: "oidcroutepolicies"}
+
+var (
+	headerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	// the values are written in Lua strings of the configuration
+	valueRegex = regexp.MustCompile(`^[A-Za-z0-9._:/@~-]+$`)
+)
+
+// ClaimHeader forwards a claim of the token in a request header
+type ClaimHeader struct {
+	Claim  string `json:"claim"`
+	Header string `json:"header"`
+}
+
+// Rule contains the requirements of the tokens of the requests to a route
+type Rule struct {
+	// Host is the host of the route, like console.example.com or
+	// *.example.com, any host if empty
+	Host string `json:"host,omitempty"`
+	// Path is the prefix of the paths of the route, / if empty
+	Path string `json:"path,omitempty"`
+	// Audiences are the audiences accepted, one of them must be in the aud
+	// claim. Any audience if empty
+	Audiences []string `json:"audiences,omitempty"`
+	// Scopes are the scopes required, all of them must be in the scope or
+	// scp claim
+	Scopes []string `json:"scopes,omitempty"`
+	// ForwardClaims are the claims sent to the backend in headers
+	ForwardClaims []ClaimHeader `json:"forwardClaims,omitempty"`
+	// Policy is the name of the OIDCRoutePolicy of the rule
+	Policy string `json:"policy,omitempty"`
+	// Reject rejects the requests of the rule, set by the controller on the
+	// locations whose tokens can not be verified
+	Reject bool `json:"reject,omitempty"`
+}
+
+// Equal tests for equality between two Rule types
+func (r1 *Rule) Equal(r2 *Rule) bool {
+	if r1 == r2 {
+		return true
+	}
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	if r1.Host != r2.Host || r1.Path != r2.Path || r1.Policy != r2.Policy || r1.Reject != r2.Reject {
+		return false
+	}
+	if !equalStrings(r1.Audiences, r2.Audiences) || !equalStrings(r1.Scopes, r2.Scopes) {
+		return false
+	}
+	if len(r1.ForwardClaims) != len(r2.ForwardClaims) {
+		return false
+	}
+	for i := range r1.ForwardClaims {
+		if r1.ForwardClaims[i] != r2.ForwardClaims[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy is an OIDCRoutePolicy
+type Policy struct {
+	Name  string
+	Rules []Rule
+}
+
+type policySpec struct {
+	Spec struct {
+		Rules []Rule `json:"rules"`
+	} `json:"spec"`
+}
+
+// FromUnstructured returns the policy of an OIDCRoutePolicy, or an error if
+// its rules are not valid
+func FromUnstructured(obj *unstructured.Unstructured) (*Policy, error) {
+	spec := policySpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OIDCRoutePolicy %v: %v", obj.GetName(), err)
+	}
+
+	p := &Policy{Name: obj.GetName()}
+	for i, r := range spec.Spec.Rules {
+		if err := validRule(&r); err != nil {
+			return nil, fmt.Errorf("invalid rule %v of the OIDCRoutePolicy %v: %v", i, p.Name, err)
+		}
+		if r.Path == "" {
+			r.Path = "/"
+		}
+		r.Policy = p.Name
+		p.Rules = append(p.Rules, r)
+	}
+	return p, nil
+}
+
+func validRule(r *Rule) error {
+	if r.Host != "" {
+		if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(r.Host, "*.")); len(errs) > 0 {
+			return fmt.Errorf("host %q: %v", r.Host, strings.Join(errs, ", "))
+		}
+	}
+	if r.Path != "" && (!strings.HasPrefix(r.Path, "/") || strings.ContainsAny(r.Path, "\"\\ ")) {
+		return fmt.Errorf("path %q must be an absolute path", r.Path)
+	}
+	for _, v := range append(append([]string{}, r.Audiences...), r.Scopes...) {
+		if !valueRegex.MatchString(v) {
+			return fmt.Errorf("invalid audience or scope %q", v)
+		}
+	}
+	for _, c := range r.ForwardClaims {
+		if !valueRegex.MatchString(c.Claim) {
+			return fmt.Errorf("invalid claim %q", c.Claim)
+		}
+		if !headerRegex.MatchString(c.Header) {
+			return fmt.Errorf("invalid header %q of the claim %v", c.Header, c.Claim)
+		}
+	}
+	return nil
+}
+
+// hostRank returns how specific the host of a rule is for a server, or -1
+// if it does not match
+func hostRank(pattern, host string) int {
+	switch {
+	case pattern == "":
+		return 0
+	case strings.EqualFold(pattern, host):
+		return 2
+	case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(strings.ToLower(host), strings.ToLower(pattern[1:])):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// underPath returns true if the path is prefix, or a path under it
+func underPath(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// Match returns the rules that apply to the requests of a location of the
+// server, the most specific first: the rules of the most specific host,
+// and among them the longest paths. The rules of the paths under the path
+// of the location only apply to some of its requests.
+func Match(policies []Policy, host, path string) []Rule {
+	type ranked struct {
+		rule Rule
+		rank int
+	}
+
+	var matched []ranked
+	best := map[string]int{}
+	for _, p := range policies {
+		for _, r := range p.Rules {
+			rank := hostRank(r.Host, host)
+			if rank < 0 || !(underPath(path, r.Path) || underPath(r.Path, path)) {
+				continue
+			}
+			matched = append(matched, ranked{r, rank})
+			if rank > best[r.Path] {
+				best[r.Path] = rank
+			}
+		}
+	}
+
+	var rules []Rule
+	for _, m := range matched {
+		// a path is only required by the rules of its most specific host
+		if m.rank == best[m.rule.Path] {
+			rules = append(rules, m.rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].Path) != len(rules[j].Path) {
+			return len(rules[i].Path) > len(rules[j].Path)
+		}
+		return rules[i].Policy < rules[j].Policy
+	})
+
+	// the requests are checked by the first rule of their path, the rules
+	// above the path of the location are only needed up to the first one
+	// covering all its requests
+	for i, r := range rules {
+		if underPath(path, r.Path) {
+			return rules[:i+1]
+		}
+	}
+	return rules
+}
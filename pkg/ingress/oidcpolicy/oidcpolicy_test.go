@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package oidcpolicy
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func policy(name string, rules ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "ingress.open-cluster-management.io/v1alpha1",
+		"kind":       "OIDCRoutePolicy",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"rules": rules},
+	}}
+}
+
+func TestFromUnstructured(t *testing.T) {
+	p, err := FromUnstructured(policy("console", map[string]interface{}{
+		"host":          "*.apps.example.com",
+		"audiences":     []interface{}{"console"},
+		"scopes":        []interface{}{"openid", "console:read"},
+		"forwardClaims": []interface{}{map[string]interface{}{"claim": "email", "header": "X-Forwarded-Email"}},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.Rules) != 1 || p.Rules[0].Path != "/" || p.Rules[0].Policy != "console" || len(p.Rules[0].Scopes) != 2 {
+		t.Errorf("unexpected policy %+v", p)
+	}
+
+	for _, rule := range []map[string]interface{}{
+		{"host": "bad_host"},
+		{"path": "relative"},
+		{"scopes": []interface{}{"read\";"}},
+		{"forwardClaims": []interface{}{map[string]interface{}{"claim": "email", "header": "X Email"}}},
+	} {
+		if _, err := FromUnstructured(policy("invalid", rule)); err == nil {
+			t.Errorf("expected an error for the rule %v", rule)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	policies := []Policy{
+		{Name: "platform", Rules: []Rule{
+			{Host: "*.example.com", Path: "/", Audiences: []string{"platform"}, Policy: "platform"},
+			{Host: "console.example.com", Path: "/api", Audiences: []string{"console"}, Policy: "platform"},
+		}},
+		{Name: "search", Rules: []Rule{
+			{Path: "/api/search", Scopes: []string{"search"}, Policy: "search"},
+			{Host: "other.com", Path: "/", Policy: "search"},
+		}},
+	}
+
+	testCases := []struct {
+		host, path string
+		expected   []string
+	}{
+		{"console.example.com", "/", []string{"/api/search", "/api", "/"}},
+		{"console.example.com", "/api", []string{"/api/search", "/api"}},
+		{"console.example.com", "/api/search/v1", []string{"/api/search"}},
+		{"grafana.example.com", "/dashboards", []string{"/"}},
+		{"unknown.io", "/dashboards", nil},
+	}
+	for _, tc := range testCases {
+		rules := Match(policies, tc.host, tc.path)
+		var paths []string
+		for _, r := range rules {
+			paths = append(paths, r.Path)
+		}
+		if !equalStrings(paths, tc.expected) {
+			t.Errorf("%v%v: expected the rules of %v but returned %v", tc.host, tc.path, tc.expected, paths)
+		}
+	}
+}
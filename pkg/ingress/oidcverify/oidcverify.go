@@ -0,0 +1,426 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package oidcverify verifies the signature and the registered claims of
+// the JWT bearer tokens issued by the OIDC issuer, with the keys of its
+// JWKS, for the locations with OIDCRoutePolicies.
+package oidcverify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// ClaimsHeader returns the claims of the verified token, the base64url
+	// encoded JSON object of the payload
+	ClaimsHeader = "X-Verified-Claims"
+
+	// leeway is the clock skew accepted in the exp and nbf claims
+	leeway = time.Minute
+	// minRefresh is the minimum time between the fetches of the JWKS for
+	// tokens signed with an unknown key
+	minRefresh = time.Minute
+)
+
+// algorithms are the hashes of the signature algorithms accepted. The
+// symmetric algorithms and none are rejected.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// caFiles are the CAs of the cluster trusted for the issuer, in addition
+// to the CAs of the system
+var caFiles = []string{
+	"/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+	"/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt",
+}
+
+// invalidError is the rejection of a token, unlike the errors fetching the
+// keys of the issuer
+type invalidError struct {
+	reason string
+}
+
+func (e invalidError) Error() string {
+	return e.reason
+}
+
+// IsInvalid returns true if the error is the rejection of the token
+func IsInvalid(err error) bool {
+	_, ok := err.(invalidError)
+	return ok
+}
+
+func invalid(format string, args ...interface{}) error {
+	return invalidError{fmt.Sprintf(format, args...)}
+}
+
+// Verifier verifies the tokens of an issuer. The keys of the issuer are
+// fetched from the jwks_uri of its discovery document, and fetched again
+// for the tokens signed with an unknown key.
+type Verifier struct {
+	issuer string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// New returns a Verifier of the tokens of the issuer. The CAs of the system
+// and of the cluster are trusted if client is nil.
+func New(issuer string, client *http.Client) *Verifier {
+	if client == nil {
+		client = defaultClient()
+	}
+	return &Verifier{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func defaultClient() *http.Client {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range caFiles {
+		if b, err := ioutil.ReadFile(f); err == nil {
+			pool.AppendCertsFromPEM(b)
+		}
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify returns the claims of the token if it is signed by a key of the
+// issuer, issued by it and not expired
+func (v *Verifier) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("the token is not a JWS")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, invalid("invalid header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("invalid signature: %v", err)
+	}
+
+	key, err := v.key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("invalid payload: %v", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims checks the issuer and the validity period of the token
+func (v *Verifier) checkClaims(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != v.issuer {
+		return invalid("unexpected issuer %q", iss)
+	}
+
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return invalid("the token does not expire")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return invalid("the token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return invalid("the token is not valid yet")
+	}
+	return nil
+}
+
+// key returns the key of the issuer with the ID, the only key of the issuer
+// without ID
+func (v *Verifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	if !v.fetched.IsZero() && v.now().Sub(v.fetched) < minRefresh {
+		return nil, invalid("unknown key %q", kid)
+	}
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, fmt.Errorf("fetching the keys of the issuer %v: %v", v.issuer, err)
+	}
+	v.keys = keys
+	v.fetched = v.now()
+
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, invalid("unknown key %q", kid)
+}
+
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys returns the signing keys of the JWKS of the issuer by ID
+func (v *Verifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.get(v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+		return nil, fmt.Errorf("the discovery document is of the issuer %q", discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("the discovery document has no jwks_uri")
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.get(discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			glog.Warningf("ignoring key %q of the issuer %v: %v", k.Kid, v.issuer, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (v *Verifier) get(url string, obj interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v from %v", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("the point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature verifies the signature of the signing input with the
+// key, for the asymmetric algorithms only
+func verifySignature(alg string, key crypto.PublicKey, input string, signature []byte) error {
+	hash, ok := algorithms[alg]
+	if !ok {
+		return invalid("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return invalid("the key of the token is not a RSA key")
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, nil)
+		}
+		if err != nil {
+			return invalid("invalid signature")
+		}
+	default:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return invalid("the key of the token is not an EC key")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid("invalid signature")
+		}
+	}
+	return nil
+}
+
+func decodeSegment(seg string, obj interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, obj)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Handler verifies the bearer token of the subrequests sent by NGINX and
+// returns its claims in ClaimsHeader. It returns 401 for the invalid tokens
+// and 503 when the keys of the issuer can not be fetched. It only accepts
+// requests from the loopback interface.
+func Handler(v *Verifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := v.Verify(token)
+		if err != nil {
+			if !IsInvalid(err) {
+				glog.Warningf("error verifying the token: %v", err)
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			glog.V(2).Infof("rejecting token: %v", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		b, err := json.Marshal(claims)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(ClaimsHeader, base64.RawURLEncoding.EncodeToString(b))
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package oidcverify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeIssuer serves the discovery document and the JWKS of an RSA and an
+// EC key
+type fakeIssuer struct {
+	srv     *httptest.Server
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	fetches int
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &fakeIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": i.srv.URL, "jwks_uri": i.srv.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		i.fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X.Bytes()), "y": enc(ecKey.Y.Bytes())},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": enc(rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	})
+	i.srv = httptest.NewServer(mux)
+	return i
+}
+
+// token returns a token signed with the key of kid, with the claims of the
+// issuer valid for an hour and claims
+func (i *fakeIssuer) token(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	payload := map[string]interface{}{"iss": i.srv.URL, "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	p, _ := json.Marshal(payload)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, signErr := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		sig, err = make([]byte, 64), signErr
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	default:
+		sig = []byte("signature")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.srv.Close()
+	v := New(issuer.srv.URL+"/", issuer.srv.Client())
+
+	rsaToken := issuer.token(t, "RS256", "rsa", map[string]interface{}{"aud": "console"})
+	testCases := map[string]struct {
+		token string
+		valid bool
+	}{
+		"rsa":         {rsaToken, true},
+		"ec":          {issuer.token(t, "ES256", "ec", nil), true},
+		"tampered":    {rsaToken[:len(rsaToken)-4] + "AAAA", false},
+		"wrong key":   {issuer.token(t, "RS256", "ec", nil), false},
+		"unknown key": {issuer.token(t, "RS256", "other", nil), false},
+		"enc key":     {issuer.token(t, "RS256", "enc", nil), false},
+		"none":        {issuer.token(t, "none", "rsa", nil), false},
+		"hmac":        {issuer.token(t, "HS256", "rsa", nil), false},
+		"issuer":      {issuer.token(t, "RS256", "rsa", map[string]interface{}{"iss": "https://other"}), false},
+		"expired":     {issuer.token(t, "RS256", "rsa", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}), false},
+		"no exp":      {issuer.token(t, "RS256", "rsa", map[string]interface{}{"exp": nil}), false},
+		"nbf":         {issuer.token(t, "RS256", "rsa", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()}), false},
+		"not a jws":   {"token", false},
+	}
+
+	for name, tc := range testCases {
+		claims, err := v.Verify(tc.token)
+		if (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid %v but returned %v", name, tc.valid, err)
+		}
+		if err != nil && !IsInvalid(err) {
+			t.Errorf("%v: expected the rejection of the token but returned %v", name, err)
+		}
+		if name == "rsa" && claims["aud"] != "console" {
+			t.Errorf("expected the claims of the token but returned %v", claims)
+		}
+	}
+	// the unknown keys do not fetch the keys for every token
+	if issuer.fetches != 1 {
+		t.Errorf("expected the keys to be fetched once but returned %v", issuer.fetches)
+	}
+}
+
+func TestVerifyUnavailable(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	token := issuer.token(t, "RS256", "rsa", nil)
+	issuer.srv.Close()
+
+	if _, err := New(issuer.srv.URL, nil).Verify(token); err == nil || IsInvalid(err) {
+		t.Errorf("expected an error fetching the keys but returned %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.srv.Close()
+	srv := httptest.NewServer(Handler(New(issuer.srv.URL, issuer.srv.Client())))
+	defer srv.Close()
+
+	testCases := []struct {
+		header   string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer invalid", http.StatusUnauthorized},
+		{"Bearer " + issuer.token(t, "RS256", "rsa", map[string]interface{}{"email": "admin@example.com"}), http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("expected %v for %q but returned %v", tc.expected, tc.header, resp.StatusCode)
+		}
+		if tc.expected != http.StatusOK {
+			continue
+		}
+		var claims map[string]interface{}
+		if err := decodeSegment(resp.Header.Get(ClaimsHeader), &claims); err != nil || claims["email"] != "admin@example.com" {
+			t.Errorf("expected the claims of the token but returned %v (%v)", claims, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package preflight verifies the DNS resolution and the TCP and TLS
+// reachability of the backends referenced in the configuration.
+package preflight
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// CheckDNS is the resolution of the host name
+	CheckDNS = "dns"
+	// CheckTCP is the connection to the address
+	CheckTCP = "tcp"
+	// CheckTLS is the TLS handshake
+	CheckTLS = "tls"
+)
+
+// Target is an endpoint to verify
+type Target struct {
+	// Name identifies the target, like the name of the backend
+	Name string
+	// Host is resolved when it is not empty
+	Host string
+	// Address is the ip or host name used to connect
+	Address string
+	Port    int
+	// TLS indicates that the TLS handshake must succeed. The certificate
+	// is not verified.
+	TLS bool
+}
+
+// Error is returned when a check of a target fails
+type Error struct {
+	Check string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v check failed: %v", e.Check, e.Err)
+}
+
+// Checker verifies targets
+type Checker struct {
+	Timeout  time.Duration
+	Resolver *net.Resolver
+}
+
+// Check runs the checks of the target and returns the first failure as *Error
+func (c Checker) Check(ctx context.Context, t Target) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if t.Host != "" {
+		r := c.Resolver
+		if r == nil {
+			r = net.DefaultResolver
+		}
+		if _, err := r.LookupHost(ctx, t.Host); err != nil {
+			return &Error{CheckDNS, err}
+		}
+	}
+
+	d := &net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.Address, strconv.Itoa(t.Port)))
+	if err != nil {
+		return &Error{CheckTCP, err}
+	}
+	defer conn.Close()
+
+	if !t.TLS {
+		return nil
+	}
+
+	serverName := t.Host
+	if serverName == "" {
+		serverName = t.Address
+	}
+	tc := tls.Client(conn, &tls.Config{
+		ServerName: serverName,
+		// backends commonly use certificates of the service CA, only the
+		// handshake is verified
+		InsecureSkipVerify: true, // #nosec
+	})
+	if deadline, ok := ctx.Deadline(); ok {
+		tc.SetDeadline(deadline)
+	}
+	if err := tc.Handshake(); err != nil {
+		return &Error{CheckTLS, err}
+	}
+
+	return nil
+}
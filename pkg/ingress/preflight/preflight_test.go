@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package preflight
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func target(t *testing.T, rawURL string, tls bool) Target {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return Target{Name: "test", Address: u.Hostname(), Port: port, TLS: tls}
+}
+
+func TestCheck(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	// a port without listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + l.Addr().String()
+	l.Close()
+
+	c := Checker{Timeout: 2 * time.Second}
+
+	testCases := []struct {
+		name   string
+		target Target
+		check  string
+	}{
+		{"tcp", target(t, plain.URL, false), ""},
+		{"tls", target(t, secure.URL, true), ""},
+		{"closed", target(t, closed, false), CheckTCP},
+		{"no-tls", target(t, plain.URL, true), CheckTLS},
+		{"dns", Target{Host: "does-not-exist.invalid", Address: "127.0.0.1", Port: 1}, CheckDNS},
+	}
+
+	for _, tc := range testCases {
+		err := c.Check(context.TODO(), tc.target)
+		if tc.check == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tc.name, err)
+			}
+			continue
+		}
+
+		perr, ok := err.(*Error)
+		if !ok || perr.Check != tc.check {
+			t.Errorf("%v: expected a %v error but returned %v", tc.name, tc.check, err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505 -- the CertID hashes of RFC 6960
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// The OCSP messages of RFC 6960 used to check a client certificate
+
+var (
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+		"1.3.101.112":           x509.PureEd25519,
+	}
+)
+
+// maxResponseSize is the maximum size of an OCSP response
+const maxResponseSize = 1 << 20
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []singleRequest
+}
+
+type singleRequest struct {
+	Cert certID
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"explicit,tag:0,default:0,optional"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+	Extensions     []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponse struct {
+	CertID     certID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    revokedInfo      `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// newCertID returns the SHA-1 CertID of the certificate
+func newCertID(cert, issuer *x509.Certificate) (certID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return certID{}, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)          // #nosec G401
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign()) // #nosec G401
+	return certID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		IssuerNameHash: nameHash[:],
+		IssuerKeyHash:  keyHash[:],
+		SerialNumber:   cert.SerialNumber,
+	}, nil
+}
+
+func (id certID) equal(other certID) bool {
+	return id.HashAlgorithm.Algorithm.Equal(other.HashAlgorithm.Algorithm) &&
+		bytes.Equal(id.IssuerNameHash, other.IssuerNameHash) &&
+		bytes.Equal(id.IssuerKeyHash, other.IssuerKeyHash) &&
+		id.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+// queryOCSP sends the request of the certificate to its first OCSP
+// responder and returns nil if it is good, ErrRevoked if it is revoked,
+// and the next update of the response
+func (c *Checker) queryOCSP(ctx context.Context, cert, issuer *x509.Certificate, now time.Time) (time.Time, error) {
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return time.Time{}, err
+	}
+	body, err := asn1.Marshal(ocspRequest{TBSRequest: tbsRequest{RequestList: []singleRequest{{Cert: id}}}})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("the OCSP responder %v returned %v", cert.OCSPServer[0], resp.Status)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	single, err := parseResponse(raw, id, issuer, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case bool(single.Good):
+		return single.NextUpdate, nil
+	case bool(single.Unknown):
+		return time.Time{}, fmt.Errorf("the OCSP responder does not know the certificate")
+	default:
+		return single.NextUpdate, ErrRevoked
+	}
+}
+
+// parseResponse verifies the signature of an OCSP response, by the issuer
+// or a responder certificate delegated by the issuer, and returns the
+// status of the certificate id
+func parseResponse(raw []byte, id certID, issuer *x509.Certificate, now time.Time) (*singleResponse, error) {
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %v", err)
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("the OCSP responder returned the status %v", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidBasicResponse) {
+		return nil, fmt.Errorf("unsupported OCSP response type %v", resp.Response.ResponseType)
+	}
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %v", err)
+	}
+
+	algorithm, ok := signatureAlgorithms[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported OCSP signature algorithm %v", basic.SignatureAlgorithm.Algorithm)
+	}
+	signer := issuer
+	if len(basic.Certificates) > 0 {
+		responder, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCSP responder certificate: %v", err)
+		}
+		if !bytes.Equal(responder.Raw, issuer.Raw) {
+			if err := responder.CheckSignatureFrom(issuer); err != nil {
+				return nil, fmt.Errorf("the OCSP responder certificate is not issued by the CA: %v", err)
+			}
+			if !hasOCSPSigning(responder) {
+				return nil, fmt.Errorf("the OCSP responder certificate can not sign OCSP responses")
+			}
+			signer = responder
+		}
+	}
+	if err := signer.CheckSignature(algorithm, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response signature: %v", err)
+	}
+
+	for i := range basic.TBSResponseData.Responses {
+		single := &basic.TBSResponseData.Responses[i]
+		if !single.CertID.equal(id) {
+			continue
+		}
+		if !single.NextUpdate.IsZero() && now.After(single.NextUpdate) {
+			return nil, fmt.Errorf("the OCSP response expired on %v", single.NextUpdate.Format(time.RFC3339))
+		}
+		return single, nil
+	}
+	return nil, fmt.Errorf("the OCSP response does not contain the certificate")
+}
+
+func hasOCSPSigning(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package revocation checks the client certificates of the locations with
+// the client-cert-revocation-secret annotation against the CRLs of the
+// Secret and, optionally, the OCSP responders of the certificates.
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// SecretHeader contains the URL encoded <namespace>/<name> of the Secret
+	// with the CAs and the CRLs
+	SecretHeader = "X-Revocation-Secret"
+	// OCSPHeader is true if the OCSP responders must be checked
+	OCSPHeader = "X-Revocation-OCSP"
+	// CertificateHeader contains the URL encoded PEM client certificate
+	CertificateHeader = "X-Client-Certificate"
+
+	// CAKey is the key of the Secret with the PEM certificates of the CAs
+	// issuing the client certificates
+	CAKey = "ca.crt"
+	// CRLSuffix is the suffix of the keys of the Secret with the PEM or DER
+	// CRLs
+	CRLSuffix = ".crl"
+
+	// ocspTTL is the maximum time an OCSP response is cached, and
+	// failedTTL the time a failed OCSP check is cached
+	ocspTTL   = 5 * time.Minute
+	failedTTL = 30 * time.Second
+
+	maxCacheSize = 4096
+)
+
+var (
+	// ErrRevoked is returned for the revoked certificates
+	ErrRevoked = errors.New("the certificate is revoked")
+	// ErrUnknown is returned when the status of a certificate can not be
+	// checked: it was not issued by a CA of the Secret, the CRL expired or
+	// the OCSP responder did not answer
+	ErrUnknown = errors.New("the revocation status of the certificate is unknown")
+)
+
+// SecretGetter returns a Secret by <namespace>/<name>
+type SecretGetter func(name string) (*apiv1.Secret, error)
+
+// authority contains the parsed CAs and CRLs of a Secret
+type authority struct {
+	version string
+	cas     []*x509.Certificate
+	crls    []*x509.RevocationList
+}
+
+type ocspEntry struct {
+	err     error
+	expires time.Time
+}
+
+// Checker checks the revocation of client certificates and caches the
+// parsed Secrets and the OCSP responses
+type Checker struct {
+	secret SecretGetter
+	client *http.Client
+
+	mu          sync.Mutex
+	authorities map[string]*authority
+	ocsp        map[string]ocspEntry
+}
+
+// New returns a Checker reading the CAs and CRLs with secret
+func New(secret SecretGetter) *Checker {
+	return &Checker{
+		secret:      secret,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		authorities: map[string]*authority{},
+		ocsp:        map[string]ocspEntry{},
+	}
+}
+
+// authority returns the CAs and CRLs of a Secret, parsed again when the
+// Secret changes
+func (c *Checker) authority(name string) (*authority, error) {
+	secret, err := c.secret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a, ok := c.authorities[name]; ok && a.version == secret.ResourceVersion {
+		return a, nil
+	}
+
+	a, err := parseAuthority(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revocation secret %v: %v", name, err)
+	}
+	a.version = secret.ResourceVersion
+	c.authorities[name] = a
+	return a, nil
+}
+
+// parseAuthority parses the CAs of the Secret and the CRLs signed by them
+func parseAuthority(data map[string][]byte) (*authority, error) {
+	a := &authority{}
+	rest := data[CAKey]
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA certificate: %v", err)
+		}
+		a.cas = append(a.cas, ca)
+	}
+	if len(a.cas) == 0 {
+		return nil, fmt.Errorf("there are no CA certificates in %v", CAKey)
+	}
+
+	for key, value := range data {
+		if !strings.HasSuffix(key, CRLSuffix) {
+			continue
+		}
+		der := value
+		if block, _ := pem.Decode(value); block != nil {
+			der = block.Bytes
+		}
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRL %v: %v", key, err)
+		}
+		if findIssuer(a.cas, crl.CheckSignatureFrom) == nil {
+			return nil, fmt.Errorf("the CRL %v is not signed by a CA of %v", key, CAKey)
+		}
+		a.crls = append(a.crls, crl)
+	}
+	return a, nil
+}
+
+// findIssuer returns the CA whose signature check succeeds
+func findIssuer(cas []*x509.Certificate, check func(*x509.Certificate) error) *x509.Certificate {
+	for _, ca := range cas {
+		if check(ca) == nil {
+			return ca
+		}
+	}
+	return nil
+}
+
+// Check returns nil if the certificate is not revoked, ErrRevoked or
+// ErrUnknown. The certificate is revoked if a CRL of its issuer or, when
+// checkOCSP is true, its OCSP responder says so. An expired CRL is only
+// trusted for the revoked certificates.
+func (c *Checker) Check(ctx context.Context, secret string, cert *x509.Certificate, checkOCSP bool, now time.Time) error {
+	a, err := c.authority(secret)
+	if err != nil {
+		glog.Warningf("unexpected error checking the revocation of a client certificate: %v", err)
+		return ErrUnknown
+	}
+	issuer := findIssuer(a.cas, cert.CheckSignatureFrom)
+	if issuer == nil {
+		return ErrUnknown
+	}
+
+	var known bool
+	for _, crl := range a.crls {
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return ErrRevoked
+			}
+		}
+		if crl.NextUpdate.IsZero() || now.Before(crl.NextUpdate) {
+			known = true
+		}
+	}
+
+	if checkOCSP && len(cert.OCSPServer) > 0 {
+		err := c.checkOCSP(ctx, cert, issuer, now)
+		if err == nil {
+			return nil
+		}
+		if err == ErrRevoked || !known {
+			return err
+		}
+	}
+	if !known {
+		return ErrUnknown
+	}
+	return nil
+}
+
+// checkOCSP asks the OCSP responder of the certificate and caches the
+// answer until the next update of the response or ocspTTL
+func (c *Checker) checkOCSP(ctx context.Context, cert, issuer *x509.Certificate, now time.Time) error {
+	key := string(issuer.RawSubjectPublicKeyInfo) + cert.SerialNumber.String()
+
+	c.mu.Lock()
+	e, ok := c.ocsp[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.err
+	}
+
+	e = ocspEntry{expires: now.Add(ocspTTL)}
+	nextUpdate, err := c.queryOCSP(ctx, cert, issuer, now)
+	switch {
+	case err == nil || err == ErrRevoked:
+		e.err = err
+		if !nextUpdate.IsZero() && nextUpdate.Before(e.expires) {
+			e.expires = nextUpdate
+		}
+	default:
+		glog.Warningf("unexpected error checking the OCSP status of the client certificate %v: %v", cert.Subject, err)
+		e.err = ErrUnknown
+		e.expires = now.Add(failedTTL)
+	}
+
+	c.mu.Lock()
+	if len(c.ocsp) >= maxCacheSize {
+		for k, v := range c.ocsp {
+			if !now.Before(v.expires) {
+				delete(c.ocsp, k)
+			}
+		}
+		if len(c.ocsp) >= maxCacheSize {
+			c.ocsp = map[string]ocspEntry{}
+		}
+	}
+	c.ocsp[key] = e
+	c.mu.Unlock()
+	return e.err
+}
+
+// Handler checks the client certificate of the subrequests sent by NGINX.
+// It answers 200 if the certificate is valid, 403 if it is revoked and 503
+// if its status is unknown. It only accepts requests from the loopback
+// interface.
+func Handler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		value, err := url.QueryUnescape(r.Header.Get(CertificateHeader))
+		if err != nil {
+			http.Error(w, "invalid client certificate", http.StatusBadRequest)
+			return
+		}
+		block, _ := pem.Decode([]byte(value))
+		if block == nil {
+			http.Error(w, "missing client certificate", http.StatusBadRequest)
+			return
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			http.Error(w, "invalid client certificate", http.StatusBadRequest)
+			return
+		}
+
+		// the argument of the subrequest is not decoded by NGINX
+		secret, err := url.QueryUnescape(r.Header.Get(SecretHeader))
+		if err != nil {
+			http.Error(w, "invalid secret", http.StatusBadRequest)
+			return
+		}
+
+		switch err := c.Check(r.Context(), secret, cert, r.Header.Get(OCSPHeader) == "true", time.Now()); err {
+		case nil:
+			metric.IncClientCertificateCheck("valid")
+			w.WriteHeader(http.StatusOK)
+		case ErrRevoked:
+			glog.V(2).Infof("rejecting revoked client certificate %v (serial %v)", cert.Subject, cert.SerialNumber)
+			metric.IncClientCertificateCheck("revoked")
+			http.Error(w, "revoked", http.StatusForbidden)
+		default:
+			metric.IncClientCertificateCheck("unknown")
+			http.Error(w, "unknown", http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package revocation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, ocspServer string) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "cluster1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if ocspServer != "" {
+		tmpl.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func (ca *testCA) crl(t *testing.T, nextUpdate time.Time, serials ...int64) []byte {
+	var entries []x509.RevocationListEntry
+	for _, s := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(s), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// ocspResponse returns a response of the CA with the status of the
+// certificate: good, revoked or unknown
+func (ca *testCA) ocspResponse(t *testing.T, cert *x509.Certificate, status string) []byte {
+	id, err := newCertID(cert, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := singleResponse{CertID: id, ThisUpdate: time.Now().Add(-time.Minute).UTC(), NextUpdate: time.Now().Add(time.Hour).UTC()}
+	switch status {
+	case "good":
+		single.Good = true
+	case "revoked":
+		single.Revoked = revokedInfo{RevocationTime: time.Now().Add(-time.Minute).UTC()}
+	default:
+		single.Unknown = true
+	}
+	tbs, err := asn1.Marshal(responseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: ca.cert.RawSubject},
+		ProducedAt:     time.Now().UTC(),
+		Responses:      []singleResponse{single},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(tbs)
+	signature, err := ca.key.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := asn1.Marshal(basicResponse{
+		TBSResponseData:    responseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := asn1.Marshal(ocspResponse{Response: responseBytes{ResponseType: oidBasicResponse, Response: basic}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func secretGetter(data map[string][]byte) SecretGetter {
+	return func(name string) (*apiv1.Secret, error) {
+		return &apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"}, Data: data}, nil
+	}
+}
+
+func caPEM(ca *testCA) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+func TestCheckCRL(t *testing.T) {
+	ca := newTestCA(t, "hub-ca")
+	other := newTestCA(t, "other-ca")
+	valid, revoked := ca.issue(t, 10, ""), ca.issue(t, 11, "")
+	now := time.Now()
+
+	testCases := map[string]struct {
+		data     map[string][]byte
+		cert     *x509.Certificate
+		expected error
+	}{
+		"valid":          {map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, now.Add(time.Hour), 11)}, valid, nil},
+		"revoked":        {map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, now.Add(time.Hour), 11)}, revoked, ErrRevoked},
+		"other-ca":       {map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, now.Add(time.Hour), 11)}, other.issue(t, 10, ""), ErrUnknown},
+		"expired-crl":    {map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, now.Add(-time.Hour), 11)}, valid, ErrUnknown},
+		"expired-revoke": {map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, now.Add(-time.Hour), 11)}, revoked, ErrRevoked},
+		"no-crl":         {map[string][]byte{CAKey: caPEM(ca)}, valid, ErrUnknown},
+		"foreign-crl":    {map[string][]byte{CAKey: caPEM(ca), "other.crl": other.crl(t, now.Add(time.Hour), 11)}, revoked, ErrUnknown},
+	}
+
+	for name, tc := range testCases {
+		c := New(secretGetter(tc.data))
+		if err := c.Check(context.TODO(), "default/crl", tc.cert, false, now); err != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", name, tc.expected, err)
+		}
+	}
+}
+
+func TestCheckOCSP(t *testing.T) {
+	ca := newTestCA(t, "hub-ca")
+	var requests int32
+	statuses := map[int64]string{}
+	var certs map[int64]*x509.Certificate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serial := req.TBSRequest.RequestList[0].Cert.SerialNumber.Int64()
+		w.Write(ca.ocspResponse(t, certs[serial], statuses[serial]))
+	}))
+	defer server.Close()
+
+	certs = map[int64]*x509.Certificate{1: ca.issue(t, 1, server.URL), 2: ca.issue(t, 2, server.URL), 3: ca.issue(t, 3, server.URL)}
+	statuses[1], statuses[2], statuses[3] = "good", "revoked", "unknown"
+	down := ca.issue(t, 4, "http://127.0.0.1:1")
+
+	c := New(secretGetter(map[string][]byte{CAKey: caPEM(ca)}))
+	now := time.Now()
+	for serial, expected := range map[int64]error{1: nil, 2: ErrRevoked, 3: ErrUnknown} {
+		if err := c.Check(context.TODO(), "default/ocsp", certs[serial], true, now); err != expected {
+			t.Errorf("serial %v: expected %v but returned %v", serial, expected, err)
+		}
+	}
+	if err := c.Check(context.TODO(), "default/ocsp", down, true, now); err != ErrUnknown {
+		t.Errorf("expected an unknown status when the responder is down but returned %v", err)
+	}
+
+	// the responses are cached
+	before := atomic.LoadInt32(&requests)
+	if err := c.Check(context.TODO(), "default/ocsp", certs[2], true, now.Add(time.Minute)); err != ErrRevoked {
+		t.Errorf("expected the cached revoked status but returned %v", err)
+	}
+	if after := atomic.LoadInt32(&requests); after != before {
+		t.Errorf("expected no OCSP request but the responder received %v", after-before)
+	}
+
+	// without OCSP only the CRLs are checked
+	if err := c.Check(context.TODO(), "default/ocsp", certs[2], false, now); err != ErrUnknown {
+		t.Errorf("expected an unknown status without OCSP but returned %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ca := newTestCA(t, "hub-ca")
+	valid, revoked := ca.issue(t, 10, ""), ca.issue(t, 11, "")
+	getter := secretGetter(map[string][]byte{CAKey: caPEM(ca), "hub.crl": ca.crl(t, time.Now().Add(time.Hour), 11)})
+	h := Handler(New(func(name string) (*apiv1.Secret, error) {
+		if name != "default/crl" {
+			return nil, fmt.Errorf("secret %v not found", name)
+		}
+		return getter(name)
+	}))
+
+	testCases := map[string]struct {
+		remote   string
+		cert     *x509.Certificate
+		expected int
+	}{
+		"valid":        {"127.0.0.1:4000", valid, http.StatusOK},
+		"revoked":      {"127.0.0.1:4000", revoked, http.StatusForbidden},
+		"no-cert":      {"127.0.0.1:4000", nil, http.StatusBadRequest},
+		"not-loopback": {"10.0.0.1:4000", valid, http.StatusForbidden},
+	}
+
+	for name, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/auth/client-certificate", nil)
+		r.RemoteAddr = tc.remote
+		r.Header.Set(SecretHeader, "default%2Fcrl")
+		if tc.cert != nil {
+			r.Header.Set(CertificateHeader, url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}))))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", name, tc.expected, w.Code)
+		}
+	}
+}
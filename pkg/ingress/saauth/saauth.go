@@ -0,0 +1,346 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package saauth authenticates in-cluster clients with their projected
+// ServiceAccount tokens, for the locations with the service-account
+// auth type, and returns the groups of the bearer tokens reviewed by the
+// API server, for the group routes.
+package saauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// AllowedHeader contains the URL encoded comma separated list of allowed
+	// ServiceAccounts, from the argument of the subrequest
+	AllowedHeader = "X-Allowed-Service-Accounts"
+	// ServiceAccountHeader returns the authenticated ServiceAccount as <namespace>/<name>
+	ServiceAccountHeader = "X-Service-Account"
+	// UserHeader returns the user of the token reviewed by GroupsHandler
+	UserHeader = "X-User"
+	// GroupsHeader returns the URL encoded groups of the authenticated
+	// token, comma separated
+	GroupsHeader = "X-Groups"
+
+	serviceAccountPrefix = "system:serviceaccount:"
+)
+
+// review kinds, part of the keys of the cache
+const (
+	serviceAccountReview = "serviceaccount"
+	userReview           = "user"
+)
+
+// identity is the user and the groups of an authenticated token
+type identity struct {
+	user   string
+	groups []string
+}
+
+type entry struct {
+	identity identity
+	err      error
+	expires  time.Time
+}
+
+// Authenticator validates tokens with TokenReviews bound to an audience
+// and caches the results
+type Authenticator struct {
+	client     clientset.Interface
+	audience   string
+	ttl        time.Duration
+	maxEntries int
+	// salt is the random key of the hashes of the tokens in the cache, so
+	// they can not be compared with the hashes of known tokens
+	salt []byte
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]entry
+}
+
+// New returns an Authenticator accepting the tokens issued for audience.
+// The results are cached for ttl, up to maxEntries tokens. The cache is
+// disabled if ttl or maxEntries is zero.
+func New(client clientset.Interface, audience string, ttl time.Duration, maxEntries int) *Authenticator {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		glog.Fatalf("unexpected error generating the salt of the token cache: %v", err)
+	}
+	return &Authenticator{
+		client:     client,
+		audience:   audience,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		salt:       salt,
+		cache:      make(map[[sha256.Size]byte]entry),
+	}
+}
+
+// cacheKey returns the salted hash of the review kind and the token
+func (a *Authenticator) cacheKey(kind, token string) [sha256.Size]byte {
+	var key [sha256.Size]byte
+	h := hmac.New(sha256.New, a.salt)
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(token))
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// Authenticate returns the ServiceAccount of the token as <namespace>/<name>
+// and its groups. The token must be bound to the audience.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (string, []string, error) {
+	id, err := a.cached(ctx, serviceAccountReview, token, a.reviewServiceAccount)
+	return id.user, id.groups, err
+}
+
+// Groups returns the user and the groups of a token of the API server,
+// like the OAuth access tokens of the users
+func (a *Authenticator) Groups(ctx context.Context, token string) (string, []string, error) {
+	id, err := a.cached(ctx, userReview, token, a.reviewUser)
+	return id.user, id.groups, err
+}
+
+// cached returns the cached result of the review of the token, or reviews it
+func (a *Authenticator) cached(ctx context.Context, kind, token string,
+	review func(context.Context, string) (identity, error)) (identity, error) {
+	if a.ttl <= 0 || a.maxEntries <= 0 {
+		return review(ctx, token)
+	}
+
+	key := a.cacheKey(kind, token)
+	now := time.Now()
+
+	a.mu.Lock()
+	e, ok := a.cache[key]
+	hit := ok && now.Before(e.expires)
+	metric.IncAuthCache(hit, len(a.cache))
+	a.mu.Unlock()
+	if hit {
+		return e.identity, e.err
+	}
+
+	id, err := review(ctx, token)
+	if err != nil && !isRejected(err) {
+		// do not cache errors of the API server
+		return identity{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= a.maxEntries {
+		for k, e := range a.cache {
+			if now.After(e.expires) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= a.maxEntries {
+			a.cache = make(map[[sha256.Size]byte]entry)
+		}
+	}
+	a.cache[key] = entry{id, err, now.Add(a.ttl)}
+
+	return id, err
+}
+
+type rejectedError struct {
+	reason string
+}
+
+func (e rejectedError) Error() string {
+	return e.reason
+}
+
+func isRejected(err error) bool {
+	_, ok := err.(rejectedError)
+	return ok
+}
+
+// tokenReview reviews the token for the audiences, the audiences of the API
+// server when empty
+func (a *Authenticator) tokenReview(ctx context.Context, token string, audiences []string) (*authenticationv1.TokenReview, error) {
+	tr, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !tr.Status.Authenticated {
+		return nil, rejectedError{fmt.Sprintf("invalid token: %v", tr.Status.Error)}
+	}
+	return tr, nil
+}
+
+func (a *Authenticator) reviewUser(ctx context.Context, token string) (identity, error) {
+	tr, err := a.tokenReview(ctx, token, nil)
+	if err != nil {
+		return identity{}, err
+	}
+	return identity{tr.Status.User.Username, tr.Status.User.Groups}, nil
+}
+
+func (a *Authenticator) reviewServiceAccount(ctx context.Context, token string) (identity, error) {
+	tr, err := a.tokenReview(ctx, token, []string{a.audience})
+	if err != nil {
+		return identity{}, err
+	}
+
+	// API servers without support for audiences ignore them
+	audience := false
+	for _, aud := range tr.Status.Audiences {
+		if aud == a.audience {
+			audience = true
+		}
+	}
+	if !audience {
+		return identity{}, rejectedError{fmt.Sprintf("the token is not bound to the audience %v", a.audience)}
+	}
+
+	parts := strings.Split(strings.TrimPrefix(tr.Status.User.Username, serviceAccountPrefix), ":")
+	if !strings.HasPrefix(tr.Status.User.Username, serviceAccountPrefix) || len(parts) != 2 {
+		return identity{}, rejectedError{fmt.Sprintf("user %v is not a service account", tr.Status.User.Username)}
+	}
+
+	return identity{parts[0] + "/" + parts[1], tr.Status.User.Groups}, nil
+}
+
+// encodeGroups returns the URL encoded groups, comma separated
+func encodeGroups(groups []string) string {
+	encoded := make([]string, 0, len(groups))
+	for _, g := range groups {
+		encoded = append(encoded, url.QueryEscape(g))
+	}
+	return strings.Join(encoded, ",")
+}
+
+// fromLoopback returns true if the request comes from the loopback interface
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	return err == nil && ip != nil && ip.IsLoopback()
+}
+
+// bearerToken returns the bearer token of the request, empty without one
+func bearerToken(r *http.Request) string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == r.Header.Get("Authorization") {
+		return ""
+	}
+	return token
+}
+
+// Allowed returns true if the ServiceAccount, formatted as <namespace>/<name>,
+// matches an element of the list. <namespace>/* matches every ServiceAccount
+// of the namespace.
+func Allowed(sa string, allowed []string) bool {
+	ns := strings.SplitN(sa, "/", 2)[0]
+	for _, a := range allowed {
+		if a == sa || a == ns+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler validates the bearer token of the subrequests sent by NGINX and
+// checks the ServiceAccount is in the AllowedHeader list. It only accepts
+// requests from the loopback interface.
+func Handler(a *Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromLoopback(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		sa, groups, err := a.Authenticate(r.Context(), token)
+		if err != nil {
+			glog.V(2).Infof("rejecting service account token: %v", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// the argument of the subrequest is not decoded by NGINX
+		v, err := url.QueryUnescape(r.Header.Get(AllowedHeader))
+		if err != nil {
+			http.Error(w, "invalid allowed service accounts", http.StatusBadRequest)
+			return
+		}
+		var allowed []string
+		if v != "" {
+			allowed = strings.Split(v, ",")
+		}
+		if !Allowed(sa, allowed) {
+			glog.V(2).Infof("service account %v is not allowed", sa)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set(ServiceAccountHeader, sa)
+		w.Header().Set(GroupsHeader, encodeGroups(groups))
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// GroupsHandler returns in UserHeader and GroupsHeader the user and the
+// groups of the bearer token of the subrequests sent by NGINX, reviewed by
+// the API server. It returns 401
+// for the tokens rejected by the API server and 503 when the review fails.
+// It only accepts requests from the loopback interface.
+func GroupsHandler(a *Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromLoopback(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		user, groups, err := a.Groups(r.Context(), token)
+		if err != nil {
+			if !isRejected(err) {
+				glog.Warningf("error reviewing the token of the group routes: %v", err)
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			glog.V(2).Infof("rejecting token of the group routes: %v", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set(UserHeader, user)
+		w.Header().Set(GroupsHeader, encodeGroups(groups))
+		w.WriteHeader(http.StatusOK)
+	})
+}
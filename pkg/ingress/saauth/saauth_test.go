@@ -0,0 +1,253 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package saauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClient returns a client accepting the tokens in users, issued for
+// audience for the service accounts and for the API server for the others.
+// The users are in the groups system:authenticated and <user>s.
+func newFakeClient(audience string, users map[string]string, reviews *int) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		user, ok := users[tr.Spec.Token]
+		audiences := []string{audience}
+		if !strings.HasPrefix(user, serviceAccountPrefix) {
+			audiences = nil
+		}
+		if ok && reflect.DeepEqual(tr.Spec.Audiences, audiences) {
+			tr.Status.Authenticated = true
+			tr.Status.Audiences = tr.Spec.Audiences
+			tr.Status.User.Username = user
+			tr.Status.User.Groups = []string{"system:authenticated", user + "s"}
+		}
+		return true, tr, nil
+	})
+	return client
+}
+
+func TestAuthenticate(t *testing.T) {
+	var reviews int
+	client := newFakeClient("management-ingress", map[string]string{
+		"sa":   "system:serviceaccount:open-cluster-management:import-controller",
+		"user": "admin",
+	}, &reviews)
+
+	a := New(client, "management-ingress", time.Minute, 16)
+
+	sa, groups, err := a.Authenticate(context.TODO(), "sa")
+	if err != nil || sa != "open-cluster-management/import-controller" {
+		t.Errorf("expected the service account but returned %v (%v)", sa, err)
+	}
+	if len(groups) != 2 || groups[0] != "system:authenticated" {
+		t.Errorf("expected the groups of the service account but returned %v", groups)
+	}
+	if _, _, err := a.Authenticate(context.TODO(), "sa"); err != nil || reviews != 1 {
+		t.Errorf("expected a cached result but returned %v after %v reviews", err, reviews)
+	}
+
+	if _, _, err := a.Authenticate(context.TODO(), "user"); err == nil {
+		t.Errorf("expected an error for a user token")
+	}
+	if _, _, err := a.Authenticate(context.TODO(), "invalid"); err == nil {
+		t.Errorf("expected an error for an invalid token")
+	}
+
+	other := New(client, "other", time.Minute, 16)
+	if _, _, err := other.Authenticate(context.TODO(), "sa"); err == nil {
+		t.Errorf("expected an error for a token of another audience")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	var reviews int
+	client := newFakeClient("management-ingress", map[string]string{
+		"sa":   "system:serviceaccount:ns:a",
+		"user": "admin",
+	}, &reviews)
+
+	a := New(client, "management-ingress", time.Minute, 16)
+
+	user, groups, err := a.Groups(context.TODO(), "user")
+	if err != nil || user != "admin" || !reflect.DeepEqual(groups, []string{"system:authenticated", "admins"}) {
+		t.Errorf("expected the groups of the user but returned %v %v (%v)", user, groups, err)
+	}
+	if _, _, err := a.Groups(context.TODO(), "user"); err != nil || reviews != 1 {
+		t.Errorf("expected a cached result but returned %v after %v reviews", err, reviews)
+	}
+	// the results of the service account reviews are not reused
+	if _, _, err := a.Authenticate(context.TODO(), "user"); err == nil || reviews != 2 {
+		t.Errorf("expected a review of the service account but returned %v after %v reviews", err, reviews)
+	}
+	if _, _, err := a.Groups(context.TODO(), "sa"); err == nil {
+		t.Errorf("expected an error for a token of another audience")
+	}
+}
+
+func TestAuthenticateCache(t *testing.T) {
+	var reviews int
+	client := newFakeClient("management-ingress", map[string]string{
+		"a": "system:serviceaccount:ns:a",
+		"b": "system:serviceaccount:ns:b",
+	}, &reviews)
+
+	a := New(client, "management-ingress", time.Minute, 1)
+	for _, token := range []string{"a", "b", "b", "a"} {
+		if _, _, err := a.Authenticate(context.TODO(), token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reviews != 3 || len(a.cache) != 1 {
+		t.Errorf("expected the cache to be limited to one token but returned %v reviews and %v entries", reviews, len(a.cache))
+	}
+	if other := New(client, "management-ingress", time.Minute, 1); other.cacheKey(serviceAccountReview, "a") == a.cacheKey(serviceAccountReview, "a") {
+		t.Errorf("expected the keys of the tokens to be salted")
+	}
+
+	reviews = 0
+	disabled := New(client, "management-ingress", 0, 16)
+	for i := 0; i < 2; i++ {
+		if _, _, err := disabled.Authenticate(context.TODO(), "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reviews != 2 || len(disabled.cache) != 0 {
+		t.Errorf("expected a review per validation without a cache but returned %v", reviews)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	testCases := []struct {
+		sa       string
+		allowed  []string
+		expected bool
+	}{
+		{"ns/a", []string{"ns/a"}, true},
+		{"ns/a", []string{"ns/b", "ns/*"}, true},
+		{"ns/a", []string{"other/*", "ns/b"}, false},
+		{"ns/a", nil, false},
+	}
+
+	for _, tc := range testCases {
+		if res := Allowed(tc.sa, tc.allowed); res != tc.expected {
+			t.Errorf("expected %v for %v in %v but returned %v", tc.expected, tc.sa, tc.allowed, res)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var reviews int
+	client := newFakeClient("management-ingress", map[string]string{
+		"sa": "system:serviceaccount:ns:a",
+	}, &reviews)
+	srv := httptest.NewServer(Handler(New(client, "management-ingress", time.Minute, 16)))
+	defer srv.Close()
+
+	testCases := []struct {
+		header   string
+		allowed  string
+		expected int
+	}{
+		{"", "ns/a", http.StatusUnauthorized},
+		{"Bearer invalid", "ns/a", http.StatusUnauthorized},
+		{"Bearer sa", "ns/b", http.StatusForbidden},
+		{"Bearer sa", "", http.StatusForbidden},
+		{"Bearer sa", "ns/b,ns/a", http.StatusOK},
+		{"Bearer sa", url.QueryEscape("ns/b,ns/a"), http.StatusOK},
+		{"Bearer sa", "ns%2Fb%2Cns%3Ab", http.StatusForbidden},
+		{"Bearer sa", "ns%2", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		req.Header.Set(AllowedHeader, tc.allowed)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("expected %v for %q and %q but returned %v", tc.expected, tc.header, tc.allowed, resp.StatusCode)
+		}
+		if tc.expected == http.StatusOK && resp.Header.Get(ServiceAccountHeader) != "ns/a" {
+			t.Errorf("expected the service account header but returned %q", resp.Header.Get(ServiceAccountHeader))
+		}
+		if tc.expected == http.StatusOK && resp.Header.Get(GroupsHeader) != "system%3Aauthenticated,system%3Aserviceaccount%3Ans%3Aas" {
+			t.Errorf("expected the groups header but returned %q", resp.Header.Get(GroupsHeader))
+		}
+	}
+}
+
+func TestGroupsHandler(t *testing.T) {
+	var reviews int
+	client := newFakeClient("management-ingress", map[string]string{
+		"user": "admin",
+	}, &reviews)
+	srv := httptest.NewServer(GroupsHandler(New(client, "management-ingress", time.Minute, 16)))
+	defer srv.Close()
+
+	testCases := []struct {
+		header   string
+		expected int
+		groups   string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"Bearer invalid", http.StatusUnauthorized, ""},
+		{"Bearer user", http.StatusOK, "system%3Aauthenticated,admins"},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if tc.expected == http.StatusOK && resp.Header.Get(UserHeader) != "admin" {
+			t.Errorf("expected the user header but returned %q", resp.Header.Get(UserHeader))
+		}
+		if resp.StatusCode != tc.expected || resp.Header.Get(GroupsHeader) != tc.groups {
+			t.Errorf("expected %v %q for %q but returned %v %q", tc.expected, tc.groups, tc.header,
+				resp.StatusCode, resp.Header.Get(GroupsHeader))
+		}
+	}
+
+	// the errors of the API server are not rejections of the token
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer other")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %v but returned %v", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
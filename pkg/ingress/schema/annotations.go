@@ -0,0 +1,154 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package schema
+
+import (
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+// annotations are the annotations read by the parsers, without the prefix.
+// A new annotation is added here with the release that introduces it, the
+// annotations without a release exist since the Baseline.
+var annotations = []Option{
+	{Name: "auth-type", Type: "string", Enum: []string{"id-token", "access-token", "service-account"},
+		Description: "Authentication method of the location"},
+	{Name: "allowed-service-accounts", Type: "string", Since: "2.8.0",
+		Description: "ServiceAccounts allowed with the service-account auth type, <namespace>/<name> or <namespace>/*, comma separated"},
+	{Name: "authz-type", Type: "string", Enum: []string{"rbac"},
+		Description: "Authorization method of the location"},
+	{Name: "rewrite-target", Type: "string", Description: "Target URI where the traffic must be redirected"},
+	{Name: "add-base-url", Type: "boolean", Default: false,
+		Description: "Add a base tag in the head of the HTML responses of the backend"},
+	{Name: "base-url-scheme", Type: "string", Description: "Scheme of the base tag of add-base-url"},
+	{Name: "app-root", Type: "string", Description: "Path where the requests to / are redirected"},
+	{Name: "configuration-snippet", Type: "string", Description: "Additional configuration of the NGINX location"},
+	{Name: "secure-backends", Type: "boolean", Default: false, Description: "Use HTTPS to reach the backends"},
+	{Name: "secure-verify-ca-secret", Type: "string",
+		Description: "Secret with the CA certificate used to verify the backends"},
+	{Name: "secure-client-ca-secret", Type: "string",
+		Description: "Secret with the client certificate and key presented to the backends"},
+	{Name: "upstream-uri", Type: "string", Description: "URI of the backend"},
+	{Name: "location-modifier", Type: "string", Enum: []string{"=", "~", "~*"},
+		Description: "Modifier of the NGINX location"},
+	{Name: "proxy-connect-timeout", Type: "string", Format: "duration", Description: "Timeout to connect to the backend"},
+	{Name: "proxy-send-timeout", Type: "string", Format: "duration", Description: "Timeout to send the request to the backend"},
+	{Name: "proxy-read-timeout", Type: "string", Format: "duration", Description: "Timeout to read the response of the backend"},
+	{Name: "proxy-buffer-size", Type: "string", Format: "size", Description: "Size of the buffer of the responses"},
+	{Name: "proxy-body-size", Type: "string", Format: "size", Description: "Maximum size of the request body"},
+	{Name: "connection-proxy-header", Type: "string", Description: "Connection header sent to the backend"},
+	{Name: "x-forwarded-prefix", Type: "boolean", Default: false,
+		Description: "Send the path of the location in the X-Forwarded-Prefix header"},
+	{Name: "backup-service", Type: "string", Since: "2.8.0",
+		Description: "Service, <name>:<port> in the same namespace, used when the backend does not accept connections"},
+	{Name: "upstream-hash-by", Type: "string", Description: "NGINX variable used to hash the requests to the pods"},
+	{Name: "upstream-hash-load-factor", Type: "number", Since: "2.8.0",
+		Description: "With upstream-hash-by, bound the requests in progress of a pod to this factor of the average"},
+	{Name: "slow-start", Type: "string", Since: "2.8.0", Format: "duration",
+		Description: "Time in which the share of the requests of a new pod grows to the share of the other pods"},
+	{Name: "outlier-detection", Type: "boolean", Since: "2.8.0", Default: false,
+		Description: "Eject the pods that fail consecutive requests from the balancing"},
+	{Name: "outlier-consecutive-failures", Type: "integer", Since: "2.8.0", Default: 5,
+		Description: "Consecutive 5xx or slow responses that eject a pod"},
+	{Name: "outlier-max-latency", Type: "string", Since: "2.8.0", Format: "duration",
+		Description: "Time after which a response counts as a failure"},
+	{Name: "outlier-ejection-time", Type: "string", Since: "2.8.0", Format: "duration", Default: "30s", Description: "Time a pod is ejected"},
+	{Name: "outlier-max-ejection-percent", Type: "integer", Since: "2.8.0", Default: 10,
+		Description: "Maximum percentage of the pods ejected at the same time"},
+	{Name: "service-mesh", Type: "string", Since: "2.8.0", Enum: []string{"istio", "linkerd"},
+		Description: "Service mesh of the pods of the backends"},
+	{Name: "upstream-spiffe-id", Type: "string", Since: "2.8.0",
+		Description: "SPIFFE IDs, comma separated, of the pods the requests are sent to over mTLS"},
+	{Name: "ip-family", Type: "string", Since: "2.8.0", Enum: []string{"ipv4", "ipv6"},
+		Description: "Only serve the hosts on the addresses of this family"},
+	{Name: "upstream-ip-family", Type: "string", Since: "2.8.0", Enum: []string{"ipv4", "ipv6"},
+		Description: "Send the requests to the ClusterIP and pods of this family"},
+	{Name: "lua-filters", Type: "string", Since: "2.8.0", Description: "Lua filters of the signed bundle run in the locations, comma separated"},
+	{Name: "custom-counters", Type: "string", Since: "2.8.0",
+		Description: "Counters of the requests matching all their conditions, like failed=status:5xx"},
+	{Name: "client-cert-revocation-secret", Type: "string", Since: "2.8.0",
+		Description: "Secret with the CAs and the CRLs used to reject revoked client certificates"},
+	{Name: "client-cert-ocsp", Type: "boolean", Since: "2.8.0", Default: false,
+		Description: "Also check the OCSP responder of the client certificates"},
+	{Name: "client-cert-revocation-policy", Type: "string", Since: "2.8.0", Enum: []string{"soft-fail", "hard-fail"}, Default: "soft-fail",
+		Description: "What to do with the client certificates whose revocation status is unknown"},
+	{Name: "tls-headers", Type: "string", Since: "2.8.0",
+		Description: "Values of the TLS connection sent to the backend in X-TLS-* headers, comma separated"},
+	{Name: "max-websocket-connections", Type: "integer", Since: "2.8.0", Description: "Maximum concurrent websocket sessions of the location"},
+	{Name: "max-websocket-connections-per-ip", Type: "integer", Since: "2.8.0",
+		Description: "Maximum concurrent websocket sessions of the location from the same client address"},
+	{Name: "idle-timeout", Type: "string", Since: "2.8.0", Format: "duration",
+		Description: "Time without data after which the upgraded and streaming connections are closed"},
+	{Name: "cost-tag", Type: "string", Since: "2.8.0", Description: "Sources of the cost attribution tag of the requests, | separated"},
+	{Name: "disable-compression", Type: "boolean", Since: "2.8.0", Default: false, Description: "Do not compress the responses"},
+	{Name: "cache-control", Type: "string", Since: "2.8.0", Description: "Cache-Control directives of the responses"},
+	{Name: "cache-control-mode", Type: "string", Since: "2.8.0", Enum: []string{"override", "append"}, Default: "override",
+		Description: "Replace the Cache-Control header of the backend or append the directives to it"},
+	{Name: "etag", Type: "string", Since: "2.8.0", Enum: []string{"keep", "strip"}, Default: "keep",
+		Description: "Keep or remove the ETag header of the backend"},
+	{Name: "strip-set-cookie", Type: "boolean", Since: "2.8.0", Default: false, Description: "Remove the cookies set by the backend"},
+	{Name: "signed-url-secret", Type: "string", Since: "2.8.0",
+		Description: "Secret with the keys of the signed URLs accepted by the location"},
+	{Name: "replay-protection-secret", Type: "string", Since: "2.8.0",
+		Description: "Secret with the keys of the signed webhooks accepted by the location, once"},
+	{Name: "replay-protection-window", Type: "integer", Since: "2.8.0", Default: 300,
+		Description: "Seconds between the timestamp of a signed webhook and its reception"},
+	{Name: "profile", Type: "string", Since: "2.8.0", Enum: []string{"upload"}, Description: "Predefined settings of the location"},
+	{Name: "surge-protection", Type: "string", Since: "2.8.0", Enum: []string{"reject", "queue"},
+		Description: "Limit the requests to the backend while most of its pods are not ready"},
+	{Name: "surge-min-ready-percent", Type: "integer", Since: "2.8.0", Default: 50,
+		Description: "Percentage of ready pods below which the backend is protected"},
+	{Name: "surge-requests-per-pod", Type: "integer", Since: "2.8.0", Default: 10,
+		Description: "Concurrent requests allowed per ready pod while the backend is protected"},
+	{Name: "surge-queue-timeout", Type: "string", Since: "2.8.0", Format: "duration", Default: "5s",
+		Description: "Maximum time a request waits in queue mode"},
+	{Name: "surge-retry-after", Type: "string", Since: "2.8.0", Format: "duration", Default: "10s",
+		Description: "Retry-After of the rejected requests"},
+	{Name: "max-requests-per-client", Type: "integer", Since: "2.8.0",
+		Description: "Maximum concurrent requests of the same client in the location"},
+	{Name: "fairness-key", Type: "string", Since: "2.8.0", Enum: []string{"ip", "subject"}, Default: "ip",
+		Description: "How the clients of max-requests-per-client are identified"},
+	{Name: "latency-budget", Type: "string", Since: "2.8.0", Format: "duration",
+		Description: "Maximum time to serve a request, caps the proxy timeouts"},
+	{Name: "max-response-size", Type: "string", Since: "2.8.0", Format: "size",
+		Description: "Maximum size of the response body, longer responses are truncated"},
+	{Name: "deadline-header", Type: "string", Since: "2.8.0", Enum: []string{"x-request-deadline", "grpc-timeout"},
+		Description: "Header with the remaining time of the request sent to the backend"},
+	{Name: "shared-certificate", Type: "string", Since: "2.8.0",
+		Description: "Alias of the certificate of the platform namespace used by the TLS hosts without a secret"},
+	{Name: "access-log-destination", Type: "string", Since: "2.8.0",
+		Description: "Access log of the locations: off, stdout, stderr or the name of a file in the directory of the access log"},
+	{Name: "access-log-sample", Type: "number", Since: "2.8.0",
+		Description: "Ratio of the requests of the locations logged, between 0 and 1"},
+	{Name: "locale-backends", Type: "string", Since: "2.8.0",
+		Description: "Variants of the backend selected by the value of the locale header, <value>=<service>:<port>, comma separated"},
+	{Name: "locale-header", Type: "string", Since: "2.8.0", Default: "Accept-Language",
+		Description: "Header whose value selects the variant of the backend"},
+	{Name: "unix-socket", Type: "string", Since: "2.8.0",
+		Description: "Unix socket in a directory of --unix-socket-dir the paths are proxied to instead of their services"},
+	{Name: "canary-backend", Type: "string", Since: "2.8.0",
+		Description: "Canary of the backends of the Ingress, as <service>:<port>"},
+	{Name: "canary-weight", Type: "integer", Since: "2.8.0", Default: 0,
+		Description: "Percentage of the requests sent to the canary backend, applied without a reload"},
+	{Name: "canary-header", Type: "string", Since: "2.8.0",
+		Description: "Requests sent to the canary backend whatever the weight, as <header>=<value>"},
+	{Name: "group-routes", Type: "string", Since: "2.8.0",
+		Description: "Routes of the groups of the authenticated caller, reviewed by the API server, <group>=<service>:<port> or <group>=deny, comma separated. Requires auth-type"},
+	{Name: "group-claim", Type: "string", Since: "2.8.0",
+		Description: "Rejected: the groups of the group routes are not read from the claims of the token"},
+	{Name: "break-glass-auth", Type: "string", Since: "2.8.0",
+		Description: "Authentication accepted while the OIDC issuer is unreachable, client-certificate or token, requires auth-type"},
+	{Name: "break-glass-secret", Type: "string", Since: "2.8.0",
+		Description: "Secret with the ca.crt or the token of the break-glass authentication"},
+}
+
+// Annotations returns the options of the annotations, with the prefix of
+// the controller
+func Annotations() []Option {
+	options := make([]Option, 0, len(annotations))
+	for _, o := range annotations {
+		o.Name = parser.GetAnnotationWithPrefix(o.Name)
+		options = append(options, o)
+	}
+	return options
+}
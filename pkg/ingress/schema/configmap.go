@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package schema
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/controller/config"
+)
+
+// derivedKeys are the fields of the configuration set from other keys of
+// the ConfigMap, with the option of that key
+var derivedKeys = map[string]*Option{
+	"bind-address-ipv4": {Name: "bind-address", Type: "array",
+		Description: "IPv4 and IPv6 addresses where NGINX listens, all the addresses if empty"},
+	"bind-address-ipv6": nil,
+}
+
+// ConfigMap returns the options of the ConfigMap, the fields of the NGINX
+// configuration with their default value. A field can set the release that
+// introduced it and its feature gate with the since and featureGate tags.
+func ConfigMap() []Option {
+	def := reflect.ValueOf(config.NewDefault())
+	typ := def.Type()
+
+	var options []Option
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if o, ok := derivedKeys[name]; ok {
+			if o != nil {
+				options = append(options, *o)
+			}
+			continue
+		}
+
+		o := Option{
+			Name:        name,
+			Since:       field.Tag.Get("since"),
+			FeatureGate: field.Tag.Get("featureGate"),
+		}
+		value := def.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			o.Type, o.Default = "boolean", value.Bool()
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			o.Type, o.Default = "integer", value.Int()
+		case reflect.Float32, reflect.Float64:
+			o.Type, o.Default = "number", value.Float()
+		case reflect.Slice:
+			o.Type = "array"
+			if value.Len() > 0 {
+				o.Default = value.Interface()
+			}
+		default:
+			o.Type = "string"
+			if value.String() != "" {
+				o.Default = value.String()
+			}
+		}
+		options = append(options, o)
+	}
+	return options
+}
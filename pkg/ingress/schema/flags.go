@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package schema
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// SinceAnnotation is the pflag annotation of a flag with the release
+	// that introduced it, like flags.SetAnnotation(name, SinceAnnotation,
+	// []string{"2.8.0"})
+	SinceAnnotation = "schema.since"
+	// FeatureGateAnnotation is the pflag annotation of a flag with the
+	// feature gate that enables it
+	FeatureGateAnnotation = "schema.feature-gate"
+)
+
+// Flags returns the options of the visible flags
+func Flags(flags *pflag.FlagSet) []Option {
+	var options []Option
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		o := Option{
+			Name:        f.Name,
+			Description: strings.Join(strings.Fields(f.Usage), " "),
+			Since:       firstAnnotation(f, SinceAnnotation),
+			FeatureGate: firstAnnotation(f, FeatureGateAnnotation),
+		}
+		o.Type, o.Format, o.Default = flagType(f.Value.Type(), f.DefValue)
+		options = append(options, o)
+	})
+	return options
+}
+
+func firstAnnotation(f *pflag.Flag, key string) string {
+	if values := f.Annotations[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// flagType returns the JSON Schema type, format and default of a flag of a
+// pflag type
+func flagType(typ, def string) (string, string, interface{}) {
+	switch {
+	case typ == "bool":
+		b, _ := strconv.ParseBool(def)
+		return "boolean", "", b
+	case strings.HasPrefix(typ, "int") || strings.HasPrefix(typ, "uint"):
+		if strings.HasSuffix(typ, "Slice") {
+			return "array", "", nil
+		}
+		n, err := strconv.ParseInt(def, 10, 64)
+		if err != nil {
+			return "integer", "", nil
+		}
+		return "integer", "", n
+	case strings.HasPrefix(typ, "float"):
+		n, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return "number", "", nil
+		}
+		return "number", "", n
+	case typ == "duration":
+		return "string", "duration", def
+	case strings.HasSuffix(typ, "Slice") || strings.HasSuffix(typ, "Array"):
+		// the default of a slice is formatted like [a,b]
+		values := strings.TrimSuffix(strings.TrimPrefix(def, "["), "]")
+		if values == "" {
+			return "array", "", nil
+		}
+		return "array", "", strings.Split(values, ",")
+	case strings.HasPrefix(typ, "stringTo"):
+		return "object", "", nil
+	}
+	if def == "" {
+		return "string", "", nil
+	}
+	return "string", "", def
+}
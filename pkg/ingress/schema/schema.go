@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package schema describes the flags, ConfigMap keys and annotations
+// supported by the controller in a JSON Schema document, usable as the
+// schema of an OpenAPI 3.1 document, to validate the configurations.
+package schema
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// Draft is the JSON Schema dialect of the document
+	Draft = "https://json-schema.org/draft/2020-12/schema"
+
+	// Baseline is the release of the first schema. The options that
+	// existed before it are reported since this release, the new ones
+	// with the release that introduces them.
+	Baseline = "2.7.0"
+)
+
+// Option is a flag, a ConfigMap key or an annotation
+type Option struct {
+	Name string
+	// Type is the JSON Schema type of the value: string, boolean,
+	// integer, number, array or object
+	Type string
+	// Format of the string values, like duration or size
+	Format string
+	// Enum contains the allowed values, if restricted
+	Enum        []string
+	Default     interface{}
+	Description string
+	// Since is the release that introduced the option
+	Since string
+	// FeatureGate enables the option, empty if always enabled
+	FeatureGate string
+}
+
+// property returns the JSON Schema of the option
+func (o Option) property() map[string]interface{} {
+	p := map[string]interface{}{"type": o.Type}
+	if o.Type == "array" {
+		p["items"] = map[string]string{"type": "string"}
+	}
+	if o.Type == "object" {
+		p["additionalProperties"] = map[string]string{"type": "string"}
+	}
+	if o.Format != "" {
+		p["format"] = o.Format
+	}
+	if len(o.Enum) > 0 {
+		p["enum"] = o.Enum
+	}
+	if o.Default != nil {
+		p["default"] = o.Default
+	}
+	if o.Description != "" {
+		p["description"] = o.Description
+	}
+	since := o.Since
+	if since == "" {
+		since = Baseline
+	}
+	p["x-since"] = since
+	if o.FeatureGate != "" {
+		p["x-feature-gate"] = o.FeatureGate
+	}
+	return p
+}
+
+// Schema contains the options supported by a release of the controller
+type Schema struct {
+	Release     string
+	Flags       []Option
+	ConfigMap   []Option
+	Annotations []Option
+	// FeatureGates contains the feature gates with their default state
+	FeatureGates map[string]bool
+}
+
+// New returns the schema of a release with the flags of the controller and
+// the default state of its feature gates
+func New(release string, flags *pflag.FlagSet, gates map[string]bool) *Schema {
+	return &Schema{
+		Release:      release,
+		Flags:        Flags(flags),
+		ConfigMap:    ConfigMap(),
+		Annotations:  Annotations(),
+		FeatureGates: gates,
+	}
+}
+
+// MarshalJSON returns the JSON Schema document of a configuration with the
+// flags, the ConfigMap and the annotations of the Ingresses
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"$schema":     Draft,
+		"title":       "management-ingress configuration",
+		"type":        "object",
+		"x-release":   s.Release,
+		"description": "Flags of the controller, keys of its ConfigMap and annotations of the Ingresses",
+		"properties": map[string]interface{}{
+			"flags": object(s.Flags, false, "Flags of the controller, without the leading --"),
+			"configMap": object(s.ConfigMap, false, "Keys of the ConfigMap of --configmap. The values are strings, "+
+				"parsed to the type of the key, and the arrays are comma separated"),
+			// the Ingresses have the annotations of other tools
+			"annotations": object(s.Annotations, true, "Annotations of the Ingresses. The values are strings, "+
+				"parsed to the type of the annotation. Other annotations are ignored"),
+		},
+		"x-feature-gates": s.FeatureGates,
+	})
+}
+
+// object returns the JSON Schema of an object with the options as
+// properties, and other properties if additional is true
+func object(options []Option, additional bool, description string) map[string]interface{} {
+	sorted := append([]Option(nil), options...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	properties := map[string]interface{}{}
+	for _, o := range sorted {
+		properties[o.Name] = o.property()
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"description":          description,
+		"additionalProperties": additional,
+		"properties":           properties,
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package schema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ingannotations "github.com/stolostron/management-ingress/pkg/ingress/annotations"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
+)
+
+// annotationRegexp matches the annotations read by the parsers
+var annotationRegexp = regexp.MustCompile(`(?:Get\w*Annotation|AnnotationWithPrefix)\("([a-z0-9-]+)"`)
+
+func TestAnnotationsCatalog(t *testing.T) {
+	read := map[string]bool{}
+	err := filepath.Walk("../annotations", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range annotationRegexp.FindAllStringSubmatch(string(b), -1) {
+			read[m[1]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	known := map[string]bool{}
+	for _, o := range annotations {
+		if known[o.Name] {
+			t.Errorf("annotation %v is duplicated", o.Name)
+		}
+		known[o.Name] = true
+		if !read[o.Name] {
+			t.Errorf("annotation %v is not read by any parser", o.Name)
+		}
+	}
+	for name := range read {
+		if !known[name] {
+			t.Errorf("annotation %v is missing in the schema", name)
+		}
+	}
+}
+
+func TestAnnotationEnums(t *testing.T) {
+	ec := ingannotations.NewAnnotationExtractor(&resolver.Mock{})
+	for _, o := range annotations {
+		for _, value := range o.Enum {
+			ing := &networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+				Name:        "foo",
+				Namespace:   "default",
+				Annotations: map[string]string{parser.GetAnnotationWithPrefix(o.Name): value},
+			}}
+			if errs := ec.Extract(ing).Errors; len(errs) > 0 {
+				t.Errorf("expected %v of annotation %v to be valid but returned %v", value, o.Name, errs)
+			}
+		}
+	}
+}
+
+func TestConfigMap(t *testing.T) {
+	options := map[string]Option{}
+	for _, o := range ConfigMap() {
+		options[o.Name] = o
+	}
+
+	if _, ok := options["bind-address-ipv4"]; ok {
+		t.Errorf("expected bind-address-ipv4 to be replaced by bind-address")
+	}
+	if o := options["bind-address"]; o.Type != "array" {
+		t.Errorf("expected the array bind-address but returned %+v", o)
+	}
+	if o := options["http-redirect-code"]; o.Type != "integer" || o.Default != int64(308) {
+		t.Errorf("expected the integer http-redirect-code with default 308 but returned %+v", o)
+	}
+	if o := options["access-log-path"]; o.Type != "string" || o.Default != "/var/log/nginx/access.log" {
+		t.Errorf("expected the string access-log-path with a default but returned %+v", o)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.Bool("update-status", true, `Update the status
+		of the Ingresses.`)
+	flags.Int("http-port", 8080, "")
+	flags.Duration("sync-period", 10*time.Minute, "")
+	flags.StringSlice("webhook-url", nil, "")
+	flags.StringToString("feature-gates", nil, "")
+	flags.String("vip", "", "")
+	flags.SetAnnotation("vip", SinceAnnotation, []string{"2.8.0"})
+	flags.String("hidden", "", "")
+	flags.MarkHidden("hidden")
+
+	expected := []Option{
+		{Name: "feature-gates", Type: "object"},
+		{Name: "http-port", Type: "integer", Default: int64(8080)},
+		{Name: "sync-period", Type: "string", Format: "duration", Default: "10m0s"},
+		{Name: "update-status", Type: "boolean", Default: true, Description: "Update the status of the Ingresses."},
+		{Name: "vip", Type: "string", Since: "2.8.0"},
+		{Name: "webhook-url", Type: "array"},
+	}
+	if got := Flags(flags); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, got)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.Int("http-port", 8080, "HTTP port")
+
+	b, err := json.Marshal(New("2.8.0", flags, map[string]bool{"ExternalDNSEndpoints": false}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Schema     string `json:"$schema"`
+		Release    string `json:"x-release"`
+		Properties map[string]struct {
+			AdditionalProperties bool                              `json:"additionalProperties"`
+			Properties           map[string]map[string]interface{} `json:"properties"`
+		} `json:"properties"`
+		FeatureGates map[string]bool `json:"x-feature-gates"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema != Draft || doc.Release != "2.8.0" {
+		t.Errorf("unexpected $schema %v or release %v", doc.Schema, doc.Release)
+	}
+	port := doc.Properties["flags"].Properties["http-port"]
+	if port["type"] != "integer" || port["default"] != float64(8080) || port["x-since"] != Baseline {
+		t.Errorf("unexpected schema of http-port: %v", port)
+	}
+	authType := doc.Properties["annotations"].Properties[parser.GetAnnotationWithPrefix("auth-type")]
+	if authType["type"] != "string" || len(authType["enum"].([]interface{})) != 3 {
+		t.Errorf("unexpected schema of auth-type: %v", authType)
+	}
+	if _, ok := doc.Properties["configMap"].Properties["ssl-protocols"]; !ok {
+		t.Errorf("expected the ConfigMap key ssl-protocols")
+	}
+	if _, ok := doc.FeatureGates["ExternalDNSEndpoints"]; !ok {
+		t.Errorf("expected the feature gates")
+	}
+	if !doc.Properties["annotations"].AdditionalProperties || doc.Properties["flags"].AdditionalProperties {
+		t.Errorf("expected other annotations to be allowed, but not other flags")
+	}
+}
+
+func TestAnnotationsSince(t *testing.T) {
+	// the annotations of the Baseline release
+	baseline := map[string]bool{
+		"add-base-url": true, "app-root": true, "auth-type": true, "authz-type": true, "base-url-scheme": true,
+		"configuration-snippet": true, "connection-proxy-header": true, "location-modifier": true,
+		"proxy-body-size": true, "proxy-buffer-size": true, "proxy-connect-timeout": true, "proxy-read-timeout": true,
+		"proxy-send-timeout": true, "rewrite-target": true, "secure-backends": true, "secure-client-ca-secret": true,
+		"secure-verify-ca-secret": true, "upstream-hash-by": true, "upstream-uri": true, "x-forwarded-prefix": true,
+	}
+	for _, o := range annotations {
+		if baseline[o.Name] != (o.Since == "") {
+			t.Errorf("expected the annotation %v of the baseline %v but returned the release %q", o.Name, baseline[o.Name], o.Since)
+		}
+	}
+}
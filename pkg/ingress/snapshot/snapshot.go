@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+// Version of the snapshot format. Increment it on incompatible changes.
+const Version = 1
+
+// Certificate references the certificate used by a server
+type Certificate struct {
+	Hostname   string    `json:"hostname"`
+	File       string    `json:"file"`
+	Checksum   string    `json:"checksum"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// Snapshot is the effective routing state of a controller
+type Snapshot struct {
+	Version           int                    `json:"version"`
+	Created           time.Time              `json:"created"`
+	ControllerRelease string                 `json:"controllerRelease"`
+	Model             *ingress.Configuration `json:"model"`
+	Certificates      []Certificate          `json:"certificates"`
+	// Config is the rendered NGINX configuration
+	Config string `json:"config"`
+}
+
+// New returns a snapshot of the model and the configuration
+func New(model *ingress.Configuration, config []byte, release string) *Snapshot {
+	s := &Snapshot{
+		Version:           Version,
+		Created:           time.Now().UTC(),
+		ControllerRelease: release,
+		Model:             model,
+		Config:            string(config),
+	}
+
+	for _, srv := range model.Servers {
+		if srv.SSLCertificate == "" {
+			continue
+		}
+		s.Certificates = append(s.Certificates, Certificate{
+			Hostname:   srv.Hostname,
+			File:       srv.SSLCertificate,
+			Checksum:   srv.SSLPemChecksum,
+			ExpireTime: srv.SSLExpireTime,
+		})
+	}
+
+	return s
+}
+
+// Read decodes a snapshot and checks the format version
+func Read(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+
+	if s.Version != Version {
+		return nil, fmt.Errorf("unsupported snapshot version %v (expected %v)", s.Version, Version)
+	}
+	if s.Model == nil {
+		return nil, fmt.Errorf("snapshot without model")
+	}
+
+	return s, nil
+}
+
+// Write encodes the snapshot
+func (s *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Validate checks that the Ingresses, Services and TLS secrets referenced in
+// the snapshot exist in the target cluster. It returns one error per
+// missing object.
+func (s *Snapshot) Validate(ctx context.Context, client clientset.Interface) []error {
+	ingresses := map[string]bool{}
+	services := map[string]bool{}
+	secrets := map[string]bool{}
+
+	for _, srv := range s.Model.Servers {
+		for _, loc := range srv.Locations {
+			if loc.Ingress != nil {
+				ingresses[key(loc.Ingress.Namespace, loc.Ingress.Name)] = true
+				for _, tls := range loc.Ingress.Spec.TLS {
+					if tls.SecretName != "" {
+						secrets[key(loc.Ingress.Namespace, tls.SecretName)] = true
+					}
+				}
+			}
+			if loc.Service != nil {
+				services[key(loc.Service.Namespace, loc.Service.Name)] = true
+			}
+		}
+	}
+
+	var errs []error
+	check := func(kind string, keys map[string]bool, get func(ns, name string) error) {
+		for _, k := range sortedKeys(keys) {
+			ns, name := split(k)
+			if err := get(ns, name); err != nil {
+				if apierrors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("%v %v not found", kind, k))
+					continue
+				}
+				errs = append(errs, fmt.Errorf("unexpected error reading %v %v: %v", kind, k, err))
+			}
+		}
+	}
+
+	check("ingress", ingresses, func(ns, name string) error {
+		_, err := client.NetworkingV1().Ingresses(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	check("service", services, func(ns, name string) error {
+		_, err := client.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	check("secret", secrets, func(ns, name string) error {
+		_, err := client.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+
+	return errs
+}
+
+func key(ns, name string) string {
+	return fmt.Sprintf("%v/%v", ns, name)
+}
+
+func split(k string) (string, string) {
+	parts := strings.SplitN(k, "/", 2)
+	return parts[0], parts[1]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stolostron/management-ingress/pkg/ingress"
+)
+
+func buildModel() *ingress.Configuration {
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "ocm"},
+		Spec: networking.IngressSpec{
+			TLS: []networking.IngressTLS{{SecretName: "console-tls"}},
+		},
+	}
+	svc := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "ocm"}}
+
+	return &ingress.Configuration{
+		Backends: []*ingress.Backend{{Name: "ocm-console-443"}},
+		Servers: []*ingress.Server{{
+			Hostname:       "console.example.com",
+			SSLCertificate: "/opt/ibm/router/nginx/ssl/ocm-console-tls.pem",
+			SSLPemChecksum: "abc",
+			Locations:      []*ingress.Location{{Path: "/", Ingress: ing, Service: svc}},
+		}},
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	s := New(buildModel(), []byte("events {}"), "2.5.0")
+	if len(s.Certificates) != 1 || s.Certificates[0].Checksum != "abc" {
+		t.Errorf("unexpected certificates %v", s.Certificates)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+
+	r, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	if r.Config != "events {}" || r.ControllerRelease != "2.5.0" {
+		t.Errorf("unexpected snapshot %v", r)
+	}
+
+	if _, err := Read(strings.NewReader(`{"version": 99, "model": {}}`)); err == nil {
+		t.Errorf("expected an error reading an unsupported version")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := New(buildModel(), nil, "")
+
+	client := fake.NewSimpleClientset(
+		&networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "ocm"}},
+		&apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "console", Namespace: "ocm"}},
+	)
+
+	errs := s.Validate(context.TODO(), client)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "secret ocm/console-tls not found") {
+		t.Errorf("expected a missing secret but returned %v", errs)
+	}
+}
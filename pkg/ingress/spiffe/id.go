@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package spiffe obtains the X509-SVID of the controller from the SPIFFE
+// Workload API and verifies the SPIFFE IDs of the backends.
+package spiffe
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidateID returns an error if id is not a SPIFFE ID: a spiffe URI with
+// a lowercase trust domain and a path without empty, dot or percent
+// encoded segments
+func ValidateID(id string) error {
+	u, err := url.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid SPIFFE ID %q: %v", id, err)
+	}
+	if u.Scheme != "spiffe" {
+		return fmt.Errorf("invalid SPIFFE ID %q: the scheme must be spiffe", id)
+	}
+	if u.Host == "" || u.Host != strings.ToLower(u.Host) || u.Port() != "" {
+		return fmt.Errorf("invalid SPIFFE ID %q: invalid trust domain", id)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" || u.Opaque != "" || strings.Contains(id, "%") {
+		return fmt.Errorf("invalid SPIFFE ID %q: only the trust domain and the path are allowed", id)
+	}
+	if u.Path != "" {
+		for _, segment := range strings.Split(u.Path, "/")[1:] {
+			if segment == "" || segment == "." || segment == ".." {
+				return fmt.Errorf("invalid SPIFFE ID %q: invalid path", id)
+			}
+		}
+	}
+	return nil
+}
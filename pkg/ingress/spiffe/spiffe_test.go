@@ -0,0 +1,198 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package spiffe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/proto/spiffe/workload"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spire"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, id string) *SVID {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if id != "" {
+		u, _ := url.Parse(id)
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &SVID{ID: id, Certificates: []*x509.Certificate{cert}, Key: key, Bundle: []*x509.Certificate{ca.cert}}
+}
+
+// workloadAPI streams the SVIDs to the clients sending the security header
+type workloadAPI struct {
+	workload.UnimplementedSpiffeWorkloadAPIServer
+	t     *testing.T
+	svids []*SVID
+}
+
+func (a workloadAPI) FetchX509SVID(_ *workload.X509SVIDRequest, stream workload.SpiffeWorkloadAPI_FetchX509SVIDServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if len(md.Get("workload.spiffe.io")) == 0 {
+		return status.Error(codes.InvalidArgument, "missing the security header")
+	}
+	for _, svid := range a.svids {
+		key, err := x509.MarshalPKCS8PrivateKey(svid.Key)
+		if err != nil {
+			a.t.Error(err)
+			return err
+		}
+		err = stream.Send(&workload.X509SVIDResponse{Svids: []*workload.X509SVID{{
+			SpiffeId:    svid.ID,
+			X509Svid:    svid.Certificates[0].Raw,
+			X509SvidKey: key,
+			Bundle:      svid.Bundle[0].Raw,
+		}}})
+		if err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func TestValidateID(t *testing.T) {
+	testCases := map[string]bool{
+		"spiffe://example.org/ns/default/sa/api": true,
+		"spiffe://example.org":                   true,
+		"https://example.org/ns/default":         false,
+		"spiffe://Example.org/ns/default":        false,
+		"spiffe://example.org:8080/api":          false,
+		"spiffe://example.org/ns//api":           false,
+		"spiffe://example.org/ns/../api":         false,
+		"spiffe://example.org/api?x=1":           false,
+		"spiffe://example.org/a%2Fb":             false,
+		"spiffe:///api":                          false,
+	}
+	for id, valid := range testCases {
+		if err := ValidateID(id); (err == nil) != valid {
+			t.Errorf("%v: expected valid %v but returned %v", id, valid, err)
+		}
+	}
+}
+
+func TestVerifyPeer(t *testing.T) {
+	ca, other := newTestCA(t), newTestCA(t)
+	verify, err := ca.issue(t, "spiffe://example.org/ingress").VerifyPeer([]string{"spiffe://example.org/api", "spiffe://example.org/web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := map[string]struct {
+		svid  *SVID
+		valid bool
+	}{
+		"allowed":     {ca.issue(t, "spiffe://example.org/api"), true},
+		"not-allowed": {ca.issue(t, "spiffe://example.org/db"), false},
+		"no-id":       {ca.issue(t, ""), false},
+		"other-ca":    {other.issue(t, "spiffe://example.org/api"), false},
+	}
+	for name, tc := range testCases {
+		err := verify([][]byte{tc.svid.Certificates[0].Raw}, nil)
+		if (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid %v but returned %v", name, tc.valid, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ca := newTestCA(t)
+	svids := []*SVID{ca.issue(t, "spiffe://example.org/ingress"), ca.issue(t, "spiffe://example.org/ingress")}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	workload.RegisterSpiffeWorkloadAPIServer(server, workloadAPI{t: t, svids: svids})
+	go server.Serve(l)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var received []*SVID
+	err = Watch(ctx, "unix://"+socket, func(svid *SVID) {
+		received = append(received, svid)
+		if len(received) == len(svids) {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != len(svids) {
+		t.Fatalf("expected %v SVIDs but received %v", len(svids), len(received))
+	}
+	for i, svid := range received {
+		if svid.ID != svids[i].ID || !svid.Certificates[0].Equal(svids[i].Certificates[0]) || !svid.Bundle[0].Equal(ca.cert) {
+			t.Errorf("expected the SVID %v but received %v", i, svid.Certificates[0].SerialNumber)
+		}
+		if _, err := tls.X509KeyPair(mustPEM(t, svid), mustPEM(t, svid)); err != nil {
+			t.Errorf("expected a valid PEM key pair but returned %v", err)
+		}
+	}
+
+	if err := Watch(ctx, "tcp://127.0.0.1:8081", nil); err == nil {
+		t.Errorf("expected an error with a TCP socket")
+	}
+}
+
+func mustPEM(t *testing.T, svid *SVID) []byte {
+	b, err := svid.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package spiffe
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+// SVID is an X509-SVID of the workload with the X.509 bundle of its trust
+// domain
+type SVID struct {
+	ID string
+	// Certificates is the chain of the SVID, leaf first
+	Certificates []*x509.Certificate
+	Key          crypto.Signer
+	// Bundle contains the CAs of the trust domain
+	Bundle []*x509.Certificate
+}
+
+// PEM returns the certificates and the key of the SVID in PEM format
+func (s *SVID) PEM() ([]byte, error) {
+	key, err := x509.MarshalPKCS8PrivateKey(s.Key)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, cert := range s.Certificates {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	return buf.Bytes(), nil
+}
+
+// BundlePEM returns the CAs of the trust domain in PEM format
+func (s *SVID) BundlePEM() []byte {
+	var buf bytes.Buffer
+	for _, cert := range s.Bundle {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// TLSCertificate returns the SVID as a client certificate
+func (s *SVID) TLSCertificate() tls.Certificate {
+	cert := tls.Certificate{PrivateKey: s.Key, Leaf: s.Certificates[0]}
+	for _, c := range s.Certificates {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert
+}
+
+// BundlePool returns the CAs of the trust domain
+func (s *SVID) BundlePool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range s.Bundle {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// VerifyPeer returns a function for tls.Config.VerifyPeerCertificate that
+// verifies the chain of the peer with the bundle of the trust domain of the
+// SVID, ignoring the host names, and accepts the SPIFFE IDs of ids. The
+// chains are not verified by the TLS library, which only checks DNS names,
+// so InsecureSkipVerify must be set.
+func (s *SVID) VerifyPeer(ids []string) (func([][]byte, [][]*x509.Certificate) error, error) {
+	id, err := spiffeid.FromString(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]spiffeid.ID, 0, len(ids))
+	for _, raw := range ids {
+		a, err := spiffeid.FromString(raw)
+		if err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, a)
+	}
+
+	bundle := x509bundle.FromX509Authorities(id.TrustDomain(), s.Bundle)
+	return tlsconfig.VerifyPeerCertificate(bundle, tlsconfig.AuthorizeOneOf(allowed...)), nil
+}
+
+// socketPath returns the path of the Workload API socket, with the
+// unix:///path format of SPIFFE_ENDPOINT_SOCKET or a plain path
+func socketPath(socket string) (string, error) {
+	switch {
+	case strings.HasPrefix(socket, "unix://"):
+		socket = strings.TrimPrefix(socket, "unix://")
+	case strings.HasPrefix(socket, "unix:"):
+		socket = strings.TrimPrefix(socket, "unix:")
+	case strings.Contains(socket, "://"):
+		return "", fmt.Errorf("the SPIFFE Workload API socket %v is not a unix socket", socket)
+	}
+	if !strings.HasPrefix(socket, "/") {
+		return "", fmt.Errorf("the SPIFFE Workload API socket %v is not an absolute path", socket)
+	}
+	return socket, nil
+}
+
+// watcher receives the X.509 contexts of the Workload API
+type watcher struct {
+	socket string
+	update func(*SVID)
+}
+
+func (w watcher) OnX509ContextUpdate(c *workloadapi.X509Context) {
+	svid, err := fromX509Context(c)
+	if err != nil {
+		glog.Warningf("unexpected X509-SVID from the SPIFFE Workload API %v: %v", w.socket, err)
+		return
+	}
+	w.update(svid)
+}
+
+func (w watcher) OnX509ContextWatchError(err error) {
+	glog.Warningf("unexpected error reading the X509-SVIDs of the SPIFFE Workload API %v: %v", w.socket, err)
+}
+
+// Watch streams the X509-SVIDs of the Workload API listening on socket and
+// calls update with the default SVID, the first one, of every response
+// until ctx is done. The stream is opened again after an error.
+func Watch(ctx context.Context, socket string, update func(*SVID)) error {
+	path, err := socketPath(socket)
+	if err != nil {
+		return err
+	}
+
+	client, err := workloadapi.New(ctx, workloadapi.WithAddr("unix://"+path))
+	if err != nil {
+		return err
+	}
+	// #nosec
+	defer client.Close()
+
+	err = client.WatchX509Context(ctx, watcher{socket: socket, update: update})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// fromX509Context returns the default SVID of an X.509 context with the
+// bundle of its trust domain
+func fromX509Context(c *workloadapi.X509Context) (*SVID, error) {
+	if len(c.SVIDs) == 0 {
+		return nil, fmt.Errorf("the X.509 context does not contain an SVID")
+	}
+	svid := c.DefaultSVID()
+	signer, ok := svid.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported X509-SVID key %T", svid.PrivateKey)
+	}
+	bundle, err := c.Bundles.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	if err != nil {
+		return nil, err
+	}
+	if len(bundle.X509Authorities()) == 0 {
+		return nil, fmt.Errorf("the X.509 bundle of %v is empty", svid.ID.TrustDomain())
+	}
+
+	return &SVID{
+		ID:           svid.ID.String(),
+		Certificates: svid.Certificates,
+		Key:          signer,
+		Bundle:       bundle.X509Authorities(),
+	}, nil
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+const (
+	// AddressIP publishes the IPs of the nodes of the replicas
+	AddressIP = "ip"
+	// AddressHostname publishes the hostnames of the nodes of the
+	// replicas, for the external-dns setups requiring hostnames
+	AddressHostname = "hostname"
+	// AddressBoth publishes both the IP and the hostname of each node
+	AddressBoth = "both"
+)
+
+// AddressTypes are the addresses of the nodes of the replicas which can be
+// published in the status of the Ingresses
+var AddressTypes = []string{AddressIP, AddressHostname, AddressBoth}
+
+// IsValidAddressType returns true if addressType is one of the
+// AddressTypes
+func IsValidAddressType(addressType string) bool {
+	for _, t := range AddressTypes {
+		if t == addressType {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFamilyDual publishes both the IPv4 and the IPv6 addresses of the
+// nodes of the replicas in the dual-stack clusters
+const IPFamilyDual = "dual"
+
+// IPFamilies are the families of the IPs of the nodes of the replicas
+// which can be published in the status of the Ingresses
+var IPFamilies = []string{ipfamily.IPv4, ipfamily.IPv6, IPFamilyDual}
+
+// IsValidIPFamily returns true if family is one of the IPFamilies
+func IsValidIPFamily(family string) bool {
+	for _, f := range IPFamilies {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeAddressTypes are the types of the addresses of the nodes which can
+// be published in the status of the Ingresses, in order of preference
+var NodeAddressTypes = []apiv1.NodeAddressType{
+	apiv1.NodeInternalIP,
+	apiv1.NodeExternalIP,
+	apiv1.NodeInternalDNS,
+	apiv1.NodeExternalDNS,
+	apiv1.NodeHostName,
+}
+
+// DefaultNodeAddressTypes publishes the internal IPs of the nodes
+var DefaultNodeAddressTypes = []apiv1.NodeAddressType{apiv1.NodeInternalIP}
+
+// IsValidNodeAddressType returns true if addressType is one of the
+// NodeAddressTypes
+func IsValidNodeAddressType(addressType string) bool {
+	for _, t := range NodeAddressTypes {
+		if string(t) == addressType {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredAddresses returns the address of the node of each family of the
+// IPFamily, IPv4 before IPv6, of the first of the NodeAddressPreference
+// the node has an address of the family of. The name of the node, like
+// its InternalDNS address, is returned if it has no IP of these types.
+func (s *statusSync) preferredAddresses(nodeName string) []string {
+	families := []string{ipfamily.IPv4, ipfamily.IPv6}
+	if s.IPFamily == ipfamily.IPv4 || s.IPFamily == ipfamily.IPv6 {
+		families = []string{s.IPFamily}
+	}
+	preference := s.NodeAddressPreference
+	if len(preference) == 0 {
+		preference = DefaultNodeAddressTypes
+	}
+
+	addrs := k8s.GetNodeAddresses(s.Client, nodeName, preference)
+	selected := []string{}
+	for _, family := range families {
+		for _, addr := range addrs {
+			if ipfamily.Matches(family, addr) {
+				selected = append(selected, addr)
+				break
+			}
+		}
+	}
+	if len(selected) > 0 {
+		return selected
+	}
+
+	for _, addr := range addrs {
+		if net.ParseIP(addr) == nil {
+			return []string{addr}
+		}
+	}
+	return selected
+}
+
+// nodeAddresses returns the addresses of the node published for the
+// replicas running on it, according to the AddressType
+func (s *statusSync) nodeAddresses(nodeName string) []string {
+	addrs := []string{}
+	if s.AddressType != AddressHostname {
+		addrs = append(addrs, s.preferredAddresses(nodeName)...)
+	}
+	if s.AddressType == AddressHostname || s.AddressType == AddressBoth {
+		if hostname := k8s.GetNodeHostname(s.Client, nodeName); hostname != "" {
+			addrs = append(addrs, hostname)
+		}
+	}
+	return addrs
+}
+
+// normalizeIP returns the canonical text of an IP address, like 2001:db8::1
+// for 2001:DB8:0::1 or 10.0.0.1 for ::ffff:10.0.0.1, or the address
+// unchanged if it is not an IP
+func normalizeIP(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	return ip.String()
+}
+
+// normalizeHostname returns a hostname in lowercase without the trailing
+// dot of the fully qualified names
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// normalizeStatus returns the canonical form of the addresses of a status:
+// the IPs in their canonical text, the hostnames in lowercase, without the
+// duplicates and in the order of lessLoadBalancerIngress. The addresses
+// are not modified.
+func normalizeStatus(addrs []apiv1.LoadBalancerIngress) []apiv1.LoadBalancerIngress {
+	lbi := make([]apiv1.LoadBalancerIngress, 0, len(addrs))
+	seen := map[[2]string]bool{}
+	for _, addr := range addrs {
+		addr.IP = normalizeIP(addr.IP)
+		addr.Hostname = normalizeHostname(addr.Hostname)
+
+		key := [2]string{addr.IP, addr.Hostname}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		lbi = append(lbi, addr)
+	}
+
+	sort.SliceStable(lbi, lessLoadBalancerIngress(lbi))
+	return lbi
+}
+
+// lessLoadBalancerIngress orders the addresses with a hostname first, by
+// hostname, then by IP, the IPv4 addresses before the IPv6 ones
+func lessLoadBalancerIngress(addrs []apiv1.LoadBalancerIngress) func(int, int) bool {
+	return func(a, b int) bool {
+		if (addrs[a].Hostname == "") != (addrs[b].Hostname == "") {
+			return addrs[a].Hostname != ""
+		}
+		if addrs[a].Hostname != addrs[b].Hostname {
+			return addrs[a].Hostname < addrs[b].Hostname
+		}
+		return lessIP(addrs[a].IP, addrs[b].IP)
+	}
+}
+
+// lessIP orders the IPv4 addresses before the IPv6 ones, by value, and the
+// values that are not IPs after them, as text
+func lessIP(a, b string) bool {
+	ipa, ipb := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipa == nil && ipb == nil:
+		return a < b
+	case ipa == nil || ipb == nil:
+		return ipb == nil
+	}
+
+	v4a, v4b := ipa.To4(), ipb.To4()
+	switch {
+	case v4a != nil && v4b != nil:
+		return bytes.Compare(v4a, v4b) < 0
+	case v4a != nil || v4b != nil:
+		return v4a != nil
+	}
+	return bytes.Compare(ipa.To16(), ipb.To16()) < 0
+}
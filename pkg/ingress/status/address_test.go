@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/ipfamily"
+)
+
+func TestNormalizeStatus(t *testing.T) {
+	fooTests := []struct {
+		name     string
+		addrs    []apiv1.LoadBalancerIngress
+		expected []apiv1.LoadBalancerIngress
+	}{
+		{"empty", nil, []apiv1.LoadBalancerIngress{}},
+		{"hostnames in lowercase without trailing dot",
+			[]apiv1.LoadBalancerIngress{{Hostname: "Hub.Example.COM."}},
+			[]apiv1.LoadBalancerIngress{{Hostname: "hub.example.com"}}},
+		{"canonical IPv6 and IPv4-mapped addresses",
+			[]apiv1.LoadBalancerIngress{{IP: "2001:DB8:0:0::68"}, {IP: "::ffff:10.0.0.1"}},
+			[]apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}, {IP: "2001:db8::68"}}},
+		{"equivalent addresses deduplicated",
+			[]apiv1.LoadBalancerIngress{{IP: "2001:db8::68"}, {Hostname: "hub"}, {IP: "2001:0db8::0068"}, {Hostname: "HUB."}},
+			[]apiv1.LoadBalancerIngress{{Hostname: "hub"}, {IP: "2001:db8::68"}}},
+		{"hostnames first, then IPv4 by value, then IPv6",
+			[]apiv1.LoadBalancerIngress{{IP: "fd00::1"}, {IP: "10.0.0.2"}, {IP: "9.0.0.1"}, {Hostname: "b"}, {Hostname: "a", IP: "10.0.0.1"}},
+			[]apiv1.LoadBalancerIngress{{Hostname: "a", IP: "10.0.0.1"}, {Hostname: "b"}, {IP: "9.0.0.1"}, {IP: "10.0.0.2"}, {IP: "fd00::1"}}},
+	}
+
+	for _, fooTest := range fooTests {
+		r := normalizeStatus(fooTest.addrs)
+		if !ingressSliceEqual(r, fooTest.expected) {
+			t.Errorf("%v: returned %v but expected %v", fooTest.name, r, fooTest.expected)
+		}
+	}
+}
+
+func TestNormalizeStatusStableOrder(t *testing.T) {
+	a := normalizeStatus([]apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}, {Hostname: "hub"}, {IP: "2001:db8::1"}})
+	b := normalizeStatus([]apiv1.LoadBalancerIngress{{IP: "2001:DB8::1"}, {IP: "10.0.0.1"}, {Hostname: "hub"}})
+	if !ingressSliceEqual(a, b) {
+		t.Errorf("expected the same order for the same addresses but returned %v and %v", a, b)
+	}
+}
+
+func TestNormalizeStatusKeepsInput(t *testing.T) {
+	addrs := []apiv1.LoadBalancerIngress{{IP: "10.0.0.2"}, {Hostname: "HUB"}}
+	normalizeStatus(addrs)
+	if addrs[0].IP != "10.0.0.2" || addrs[1].Hostname != "HUB" {
+		t.Errorf("expected the addresses unchanged but got %v", addrs)
+	}
+}
+
+func TestPreferredAddresses(t *testing.T) {
+	fk := buildStatusSync()
+	fk.Client = testclient.NewSimpleClientset(&apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "dual"},
+		Status: apiv1.NodeStatus{
+			Addresses: []apiv1.NodeAddress{
+				{Type: apiv1.NodeInternalIP, Address: "fd00::1"},
+				{Type: apiv1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: apiv1.NodeInternalIP, Address: "10.0.0.2"},
+				{Type: apiv1.NodeExternalIP, Address: "192.168.0.1"},
+			},
+		},
+	})
+
+	for family, expected := range map[string][]string{
+		"":            {"10.0.0.1", "fd00::1"},
+		IPFamilyDual:  {"10.0.0.1", "fd00::1"},
+		ipfamily.IPv4: {"10.0.0.1"},
+		ipfamily.IPv6: {"fd00::1"},
+	} {
+		fk.IPFamily = family
+		ips := fk.preferredAddresses("dual")
+		if len(ips) != len(expected) {
+			t.Fatalf("expected %v for %q but got %v", expected, family, ips)
+		}
+		for i := range expected {
+			if ips[i] != expected[i] {
+				t.Errorf("expected %v for %q but got %v", expected, family, ips)
+			}
+		}
+	}
+}
+
+func TestPreferredAddressesFallback(t *testing.T) {
+	fk := buildStatusSync()
+	fk.Client = testclient.NewSimpleClientset(&apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cloud"},
+		Status: apiv1.NodeStatus{
+			Addresses: []apiv1.NodeAddress{
+				{Type: apiv1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: apiv1.NodeInternalIP, Address: "fd00::1"},
+				{Type: apiv1.NodeExternalIP, Address: "192.168.0.1"},
+				{Type: apiv1.NodeInternalDNS, Address: "node.cluster.internal"},
+			},
+		},
+	})
+
+	fooTests := []struct {
+		preference []apiv1.NodeAddressType
+		expected   []string
+	}{
+		{nil, []string{"10.0.0.1", "fd00::1"}},
+		{[]apiv1.NodeAddressType{apiv1.NodeExternalIP, apiv1.NodeInternalIP}, []string{"192.168.0.1", "fd00::1"}},
+		{[]apiv1.NodeAddressType{apiv1.NodeExternalDNS, apiv1.NodeExternalIP}, []string{"192.168.0.1"}},
+		{[]apiv1.NodeAddressType{apiv1.NodeInternalDNS}, []string{"node.cluster.internal"}},
+		{[]apiv1.NodeAddressType{apiv1.NodeHostName}, []string{}},
+	}
+
+	for _, fooTest := range fooTests {
+		fk.NodeAddressPreference = fooTest.preference
+		addrs := fk.preferredAddresses("cloud")
+		if len(addrs) != len(fooTest.expected) {
+			t.Fatalf("expected %v for %v but got %v", fooTest.expected, fooTest.preference, addrs)
+		}
+		for i := range fooTest.expected {
+			if addrs[i] != fooTest.expected[i] {
+				t.Errorf("expected %v for %v but got %v", fooTest.expected, fooTest.preference, addrs)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// DeschedulerEvictAnnotation marks a pod as evictable for the descheduler.
+// It does not evict the pod: a strategy of the descheduler, like
+// RemoveDuplicates, must select it.
+const DeschedulerEvictAnnotation = "descheduler.alpha.kubernetes.io/evict"
+
+// colocationState keeps the co-located replicas last reported by node, so
+// the warnings and the events are only emitted when they change
+type colocationState struct {
+	mu    sync.Mutex
+	nodes map[string]string
+}
+
+// update records the co-located replicas by node and returns the nodes
+// whose replicas changed, and the nodes without co-located replicas anymore
+func (c *colocationState) update(nodes map[string]string) ([]string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var changed, resolved []string
+	for node, replicas := range nodes {
+		if c.nodes[node] != replicas {
+			changed = append(changed, node)
+		}
+	}
+	for node := range c.nodes {
+		if _, ok := nodes[node]; !ok {
+			resolved = append(resolved, node)
+		}
+	}
+	c.nodes = nodes
+	sort.Strings(changed)
+	sort.Strings(resolved)
+	return changed, resolved
+}
+
+// colocatedReplicas returns the scheduled replicas that share a node with
+// another replica, by node, the oldest first
+func colocatedReplicas(pods []apiv1.Pod) map[string][]apiv1.Pod {
+	byNode := map[string][]apiv1.Pod{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		byNode[pod.Spec.NodeName] = append(byNode[pod.Spec.NodeName], pod)
+	}
+
+	for node, replicas := range byNode {
+		if len(replicas) < 2 {
+			delete(byNode, node)
+			continue
+		}
+		sort.Slice(replicas, func(i, j int) bool {
+			a, b := replicas[i].CreationTimestamp, replicas[j].CreationTimestamp
+			if !a.Equal(&b) {
+				return a.Before(&b)
+			}
+			return replicas[i].Name < replicas[j].Name
+		})
+	}
+	return byNode
+}
+
+// checkColocation warns about the replicas running on the same node, as
+// they publish a single address and a failure of the node affects all of
+// them. The warnings and the events are only emitted when the co-located
+// replicas of a node change. With DeschedulerHint, all the co-located
+// replicas but the oldest are marked as evictable, which is only logged
+// with DryRun.
+func (s *statusSync) checkColocation() {
+	pods, err := s.runningPods()
+	if err != nil {
+		glog.Errorf("unexpected error listing the controller pods: %v", err)
+		return
+	}
+
+	evict := map[string]bool{}
+	count := 0
+	colocated := colocatedReplicas(pods)
+	nodes := make(map[string]string, len(colocated))
+	for node, replicas := range colocated {
+		count += len(replicas)
+
+		names := make([]string, 0, len(replicas))
+		for i, pod := range replicas {
+			names = append(names, pod.Name)
+			if i > 0 {
+				evict[pod.Name] = true
+			}
+		}
+		nodes[node] = strings.Join(names, ", ")
+	}
+	metric.SetColocatedReplicas(count)
+
+	changed, resolved := s.colocation.update(nodes)
+	for _, node := range changed {
+		glog.Warningf("replicas %v run on the same node %v, reducing the availability of the controller", nodes[node], node)
+		for i := range colocated[node] {
+			if s.Recorder != nil {
+				s.Recorder.Eventf(&colocated[node][i], apiv1.EventTypeWarning, "ColocatedReplicas",
+					"replicas %v run on the same node %v, reducing the availability of the controller", nodes[node], node)
+			}
+		}
+	}
+	for _, node := range resolved {
+		glog.Infof("the replicas of the controller do not share the node %v anymore", node)
+	}
+
+	if !s.DeschedulerHint {
+		return
+	}
+	for _, pod := range pods {
+		_, hinted := pod.Annotations[DeschedulerEvictAnnotation]
+		if hinted == evict[pod.Name] {
+			continue
+		}
+		if s.DryRun {
+			glog.Infof("dry run: the descheduler hint of pod %v would be set to %v", pod.Name, evict[pod.Name])
+			continue
+		}
+		if err := s.setDeschedulerHint(pod, evict[pod.Name]); err != nil {
+			glog.Errorf("unexpected error updating the descheduler hint of pod %v: %v", pod.Name, err)
+		}
+	}
+}
+
+// setDeschedulerHint adds or removes the evict annotation of a pod
+func (s *statusSync) setDeschedulerHint(pod apiv1.Pod, evict bool) error {
+	var value interface{}
+	if evict {
+		value = "true"
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{DeschedulerEvictAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("setting the descheduler hint of pod %v to %v", pod.Name, evict)
+	_, err = s.Client.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+func buildReplica(name, node string, age time.Duration, annotations map[string]string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         apiv1.NamespaceDefault,
+			Labels:            map[string]string{"app": "management-ingress"},
+			Annotations:       annotations,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		},
+		Spec: apiv1.PodSpec{NodeName: node},
+	}
+}
+
+func TestCheckColocation(t *testing.T) {
+	hinted := map[string]string{DeschedulerEvictAnnotation: "true"}
+	client := testclient.NewSimpleClientset(
+		buildReplica("ingress-a", "node-1", time.Hour, nil),
+		buildReplica("ingress-b", "node-1", time.Minute, nil),
+		buildReplica("ingress-c", "node-2", time.Hour, hinted),
+		buildReplica("ingress-d", "", time.Second, nil),
+	)
+	recorder := record.NewFakeRecorder(10)
+	s := statusSync{
+		pod: &k8s.PodInfo{
+			Name:      "ingress-a",
+			Namespace: apiv1.NamespaceDefault,
+			Labels:    map[string]string{"app": "management-ingress"},
+		},
+		Config: Config{
+			Client:          client,
+			Recorder:        recorder,
+			DeschedulerHint: true,
+			DryRun:          true,
+		},
+		colocation: &colocationState{},
+	}
+
+	// the dry run does not patch the pods
+	s.checkColocation()
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected an event per co-located replica but returned %v", len(recorder.Events))
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Errorf("expected no patch in a dry run but returned %v", action)
+		}
+	}
+
+	// the events are only recorded when the co-located replicas change
+	s.DryRun = false
+	s.checkColocation()
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected no new events without changes but returned %v", len(recorder.Events))
+	}
+	for name, expected := range map[string]bool{"ingress-a": false, "ingress-b": true, "ingress-c": false, "ingress-d": false} {
+		pod, err := client.CoreV1().Pods(apiv1.NamespaceDefault).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := pod.Annotations[DeschedulerEvictAnnotation]; ok != expected {
+			t.Errorf("expected the descheduler hint of %v to be %v", name, expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+// degradedRetryInterval is the interval between the attempts to obtain the
+// pod of the controller in the degraded mode
+const degradedRetryInterval = 30 * time.Second
+
+// degradedSync does not update the status while the pod of the controller
+// cannot be obtained, so NGINX keeps serving, and retries to create the
+// status sync every degradedRetryInterval
+type degradedSync struct {
+	config Config
+
+	mu sync.RWMutex
+	// sync is the status sync, nil while degraded
+	sync Sync
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	// newSync creates the status sync every interval, replaced in the
+	// tests
+	newSync  func(Config) (Sync, error)
+	interval time.Duration
+}
+
+func newDegradedSync(config Config, err error) *degradedSync {
+	glog.Errorf("the status of the Ingresses is not updated until the pod information is available: %v", err)
+	metric.SetStatusDegraded(true)
+	if config.Recorder != nil && k8s.IsRunningInPod() {
+		pod := &apiv1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: os.Getenv("POD_NAMESPACE"),
+			Name:      os.Getenv("POD_NAME"),
+		}
+		config.Recorder.Eventf(pod, apiv1.EventTypeWarning, "StatusDegraded",
+			"the status of the Ingresses is not updated: %v", err)
+	}
+
+	return &degradedSync{
+		config:   config,
+		stopCh:   make(chan struct{}),
+		newSync:  newStatusSync,
+		interval: degradedRetryInterval,
+	}
+}
+
+// current returns the status sync, nil while degraded
+func (d *degradedSync) current() Sync {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sync
+}
+
+// Run retries to create the status sync until it succeeds or Shutdown is
+// called, then runs it
+func (d *degradedSync) Run() {
+	err := wait.PollUntil(d.interval, func() (bool, error) {
+		st, err := d.newSync(d.config)
+		if err != nil {
+			glog.Warningf("the status of the Ingresses is still not updated: %v", err)
+			return false, nil
+		}
+
+		d.mu.Lock()
+		d.sync = st
+		d.mu.Unlock()
+		return true, nil
+	}, d.stopCh)
+	if err != nil {
+		return
+	}
+
+	glog.Infof("the pod information is available, updating the status of the Ingresses")
+	metric.SetStatusDegraded(false)
+	d.current().Run()
+}
+
+// Shutdown stops the retries, or the status sync once created
+func (d *degradedSync) Shutdown() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	if st := d.current(); st != nil {
+		st.Shutdown()
+	}
+}
+
+// IsLeader returns false while degraded
+func (d *degradedSync) IsLeader() bool {
+	if st := d.current(); st != nil {
+		return st.IsLeader()
+	}
+	return false
+}
+
+// Trigger requests a sync of the status, ignored while degraded
+func (d *degradedSync) Trigger() {
+	if st := d.current(); st != nil {
+		st.Trigger()
+	}
+}
+
+// StartRepair returns ErrNotLeader while degraded
+func (d *degradedSync) StartRepair() error {
+	if st := d.current(); st != nil {
+		return st.StartRepair()
+	}
+	return ErrNotLeader
+}
+
+// LastRepair returns nil while degraded
+func (d *degradedSync) LastRepair() *RepairReport {
+	if st := d.current(); st != nil {
+		return st.LastRepair()
+	}
+	return nil
+}
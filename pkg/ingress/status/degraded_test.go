@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSync records the calls of the degraded sync
+type fakeSync struct {
+	running  int32
+	shutdown int32
+}
+
+func (f *fakeSync) Run()                      { atomic.StoreInt32(&f.running, 1) }
+func (f *fakeSync) Shutdown()                 { atomic.StoreInt32(&f.shutdown, 1) }
+func (f *fakeSync) IsLeader() bool            { return true }
+func (f *fakeSync) Trigger()                  {}
+func (f *fakeSync) StartRepair() error        { return nil }
+func (f *fakeSync) LastRepair() *RepairReport { return nil }
+
+func TestDegradedSyncRecovers(t *testing.T) {
+	d := newDegradedSync(Config{}, errors.New("pod not found"))
+	d.interval = 10 * time.Millisecond
+
+	st := &fakeSync{}
+	var attempts int32
+	d.newSync = func(Config) (Sync, error) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return nil, errors.New("pod not found")
+		}
+		return st, nil
+	}
+
+	if d.IsLeader() {
+		t.Errorf("expected a degraded sync not to be the leader")
+	}
+	if err := d.StartRepair(); err != ErrNotLeader {
+		t.Errorf("expected %v while degraded but returned %v", ErrNotLeader, err)
+	}
+
+	d.Run()
+
+	if atomic.LoadInt32(&st.running) != 1 {
+		t.Fatalf("expected the status sync to run after %v attempts", attempts)
+	}
+	if !d.IsLeader() {
+		t.Errorf("expected the leadership of the status sync")
+	}
+	d.Shutdown()
+	if atomic.LoadInt32(&st.shutdown) != 1 {
+		t.Errorf("expected the status sync to be shut down")
+	}
+}
+
+func TestDegradedSyncShutdown(t *testing.T) {
+	d := newDegradedSync(Config{}, errors.New("pod not found"))
+	d.interval = 10 * time.Millisecond
+	d.newSync = func(Config) (Sync, error) {
+		return nil, errors.New("pod not found")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.Run()
+		close(done)
+	}()
+	d.Shutdown()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected the retries to stop after the shutdown")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// LeaderTasks are the subsystems run only by one replica, the one elected
+// to update the status of the Ingresses, instead of each one running its
+// own election
+type LeaderTasks struct {
+	mu    sync.Mutex
+	tasks []leaderTask
+	// ctx is the context of the current leadership, nil while this
+	// replica is not the leader
+	ctx context.Context
+}
+
+// leaderTask is a subsystem run while this replica is the leader
+type leaderTask struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// NewLeaderTasks returns an empty set of LeaderTasks
+func NewLeaderTasks() *LeaderTasks {
+	return &LeaderTasks{}
+}
+
+// Register adds a subsystem run while this replica is the leader, started
+// at once if it already is. The context passed to run is canceled when the
+// leadership is lost, and run must return then.
+func (t *LeaderTasks) Register(name string, run func(ctx context.Context)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	task := leaderTask{name: name, run: run}
+	t.tasks = append(t.tasks, task)
+	if t.ctx != nil {
+		task.start(t.ctx)
+	}
+}
+
+// startAll starts the tasks when this replica becomes the leader, with ctx
+// canceled when the leadership is lost
+func (t *LeaderTasks) startAll(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ctx = ctx
+	for _, task := range t.tasks {
+		task.start(ctx)
+	}
+}
+
+// stopAll records the loss of the leadership, the context of the tasks
+// is already canceled
+func (t *LeaderTasks) stopAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = nil
+}
+
+// leadership returns the context of the current leadership, nil while
+// this replica is not the leader
+func (t *LeaderTasks) leadership() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ctx
+}
+
+// start runs the task in the background until it returns
+func (task leaderTask) start(ctx context.Context) {
+	glog.V(2).Infof("starting leader task %v", task.name)
+	metric.SetLeaderTaskRunning(task.name, true)
+	go func() {
+		defer metric.SetLeaderTaskRunning(task.name, false)
+		task.run(ctx)
+		glog.V(2).Infof("leader task %v stopped", task.name)
+	}()
+}
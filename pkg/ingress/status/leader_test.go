@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaderTasks(t *testing.T) {
+	tasks := NewLeaderTasks()
+
+	started := make(chan string, 2)
+	stopped := make(chan string, 2)
+	run := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			started <- name
+			<-ctx.Done()
+			stopped <- name
+		}
+	}
+
+	tasks.Register("before", run("before"))
+	select {
+	case name := <-started:
+		t.Fatalf("expected no task started before the leadership but %v started", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tasks.startAll(ctx)
+	tasks.Register("after", run("after"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the tasks registered before and after the leadership to start")
+		}
+	}
+
+	cancel()
+	tasks.stopAll()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the tasks to stop with the leadership")
+		}
+	}
+
+	tasks.Register("late", run("late"))
+	select {
+	case name := <-started:
+		t.Errorf("expected no task started after the leadership but %v started", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// DefaultLockType elects the leader with both a ConfigMap and a Lease
+const DefaultLockType = resourcelock.ConfigMapsLeasesResourceLock
+
+// LockTypes are the resources supported to elect the leader: the
+// ConfigMaps of the previous releases, the Leases, or both to migrate from
+// the ConfigMaps to the Leases without two leaders
+var LockTypes = []string{
+	resourcelock.ConfigMapsResourceLock,
+	resourcelock.LeasesResourceLock,
+	resourcelock.ConfigMapsLeasesResourceLock,
+}
+
+// IsValidLockType returns true if lockType is one of the LockTypes
+func IsValidLockType(lockType string) bool {
+	for _, t := range LockTypes {
+		if t == lockType {
+			return true
+		}
+	}
+	return false
+}
+
+// lockMeta returns the name and the namespace of the lock of the election
+// of the pod, owned by the pod in its namespace
+func lockMeta(config Config, pod *apiv1.Pod) metav1.ObjectMeta {
+	// we need to use the defined ingress class to allow multiple leaders
+	// in order to update information about ingress status
+	electionID := fmt.Sprintf("%v-%v", config.ElectionID, config.DefaultIngressClass)
+	if config.IngressClass != "" {
+		electionID = fmt.Sprintf("%v-%v", config.ElectionID, config.IngressClass)
+	}
+	if config.ElectionLockName != "" {
+		electionID = config.ElectionLockName
+	}
+
+	meta := metav1.ObjectMeta{
+		Namespace: pod.Namespace,
+		Name:      electionID,
+	}
+	if config.ElectionNamespace != "" {
+		meta.Namespace = config.ElectionNamespace
+	}
+	// the owners must be in the namespace of the lock
+	if meta.Namespace == pod.Namespace {
+		blockOwnerDeletion := true
+		isController := true
+		meta.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion:         "v1",
+				Kind:               "Pod",
+				Name:               pod.Name,
+				UID:                pod.UID,
+				BlockOwnerDeletion: &blockOwnerDeletion,
+				Controller:         &isController,
+			},
+		}
+	}
+	return meta
+}
+
+// NewResourceLock returns the lock of lockType with the name and namespace
+// of meta
+func NewResourceLock(lockType string, meta metav1.ObjectMeta, client clientset.Interface,
+	rlc resourcelock.ResourceLockConfig) (resourcelock.Interface, error) {
+	configMapLock := &resourcelock.ConfigMapLock{
+		ConfigMapMeta: meta,
+		Client:        client.CoreV1(),
+		LockConfig:    rlc,
+	}
+	leaseLock := &resourcelock.LeaseLock{
+		LeaseMeta:  meta,
+		Client:     client.CoordinationV1(),
+		LockConfig: rlc,
+	}
+
+	switch lockType {
+	case resourcelock.ConfigMapsResourceLock:
+		return configMapLock, nil
+	case resourcelock.LeasesResourceLock:
+		return leaseLock, nil
+	case resourcelock.ConfigMapsLeasesResourceLock:
+		return &resourcelock.MultiLock{
+			Primary:   configMapLock,
+			Secondary: leaseLock,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid lock type %q, expected one of %v", lockType, LockTypes)
+	}
+}
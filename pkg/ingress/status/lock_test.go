@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestNewResourceLock(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "default", Name: "ingress-controller-leader-nginx"}
+	rlc := resourcelock.ResourceLockConfig{Identity: "foo_base_pod"}
+
+	fooTests := []struct {
+		lockType string
+		er       string
+	}{
+		{resourcelock.ConfigMapsResourceLock, "default/ingress-controller-leader-nginx"},
+		{resourcelock.LeasesResourceLock, "default/ingress-controller-leader-nginx"},
+		{resourcelock.ConfigMapsLeasesResourceLock, "default/ingress-controller-leader-nginx"},
+	}
+
+	for _, fooTest := range fooTests {
+		lock, err := NewResourceLock(fooTest.lockType, meta, testclient.NewSimpleClientset(), rlc)
+		if err != nil {
+			t.Fatalf("unexpected error with lock type %v: %v", fooTest.lockType, err)
+		}
+		if lock.Describe() != fooTest.er || lock.Identity() != "foo_base_pod" {
+			t.Errorf("returned %v (%v) but expected %v", lock.Describe(), lock.Identity(), fooTest.er)
+		}
+	}
+
+	if _, ok := mustLock(t, resourcelock.ConfigMapsLeasesResourceLock).(*resourcelock.MultiLock); !ok {
+		t.Errorf("expected a lock with the ConfigMap and the Lease")
+	}
+	if _, ok := mustLock(t, resourcelock.LeasesResourceLock).(*resourcelock.LeaseLock); !ok {
+		t.Errorf("expected a Lease lock")
+	}
+
+	if _, err := NewResourceLock("endpoints", meta, testclient.NewSimpleClientset(), rlc); err == nil {
+		t.Errorf("expected an error for the endpoints lock")
+	}
+	if IsValidLockType("endpoints") || !IsValidLockType(DefaultLockType) {
+		t.Errorf("unexpected validation of the lock types")
+	}
+}
+
+func mustLock(t *testing.T, lockType string) resourcelock.Interface {
+	lock, err := NewResourceLock(lockType, metav1.ObjectMeta{Namespace: "default", Name: "leader"},
+		testclient.NewSimpleClientset(), resourcelock.ResourceLockConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return lock
+}
+
+func TestLockMeta(t *testing.T) {
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "hub", Name: "foo_base_pod", UID: "uid"}}
+
+	fooTests := []struct {
+		config Config
+		ns     string
+		name   string
+		owned  bool
+	}{
+		{Config{ElectionID: "ingress-controller-leader", DefaultIngressClass: "nginx"}, "hub", "ingress-controller-leader-nginx", true},
+		{Config{ElectionID: "ingress-controller-leader", DefaultIngressClass: "nginx", IngressClass: "ocm"}, "hub", "ingress-controller-leader-ocm", true},
+		{Config{ElectionID: "ingress-controller-leader", ElectionLockName: "hub-ingress"}, "hub", "hub-ingress", true},
+		{Config{ElectionID: "ingress-controller-leader", ElectionLockName: "hub-ingress", ElectionNamespace: "operator"}, "operator", "hub-ingress", false},
+	}
+
+	for _, fooTest := range fooTests {
+		meta := lockMeta(fooTest.config, pod)
+		if meta.Namespace != fooTest.ns || meta.Name != fooTest.name {
+			t.Errorf("expected lock %v/%v but returned %v/%v", fooTest.ns, fooTest.name, meta.Namespace, meta.Name)
+		}
+		if owned := len(meta.OwnerReferences) == 1 && meta.OwnerReferences[0].UID == pod.UID; owned != fooTest.owned {
+			t.Errorf("expected the lock %v/%v owned by the pod: %v, but returned %v", meta.Namespace, meta.Name, fooTest.owned, meta.OwnerReferences)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+// repairPageSize is the number of Ingresses listed in each request of a
+// repair
+const repairPageSize = 500
+
+var (
+	// ErrNotLeader is returned for the repairs requested to a replica that
+	// does not update the status of the Ingresses
+	ErrNotLeader = errors.New("this replica is not the status update leader")
+	// ErrRepairInProgress is returned for the repairs requested while
+	// another one runs
+	ErrRepairInProgress = errors.New("a status repair is already in progress")
+)
+
+// RepairReport is the result of a repair of the status of the Ingresses
+type RepairReport struct {
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// Status is the status expected in the Ingresses
+	Status []apiv1.LoadBalancerIngress `json:"status"`
+	// Scanned is the number of Ingresses of the class checked
+	Scanned int `json:"scanned"`
+	// Repaired is the number of Ingresses whose status was updated
+	Repaired int `json:"repaired"`
+	// Failed are the Ingresses, as namespace/name, whose status could not
+	// be updated
+	Failed []string `json:"failed,omitempty"`
+	// Error is the reason the repair stopped before the end, if any
+	Error string `json:"error,omitempty"`
+}
+
+// repairState keeps the repair in progress, or the last one, shared by
+// the copies of the statusSync
+type repairState struct {
+	mu      sync.Mutex
+	running bool
+	last    *RepairReport
+}
+
+// StartRepair starts a repair of the status of all the Ingresses of the
+// class in the background, stopped when the leadership is lost. It fails
+// if this replica is not the leader or a repair is in progress.
+func (s statusSync) StartRepair() error {
+	ctx := s.LeaderTasks.leadership()
+	if !s.elector.IsLeader() || ctx == nil {
+		return ErrNotLeader
+	}
+	report, err := s.beginRepair()
+	if err != nil {
+		return err
+	}
+	go s.runRepair(ctx, report)
+	return nil
+}
+
+// LastRepair returns the repair in progress or the last one, nil if none
+// was started
+func (s statusSync) LastRepair() *RepairReport {
+	s.repairs.mu.Lock()
+	defer s.repairs.mu.Unlock()
+	if s.repairs.last == nil {
+		return nil
+	}
+	last := *s.repairs.last
+	last.Failed = append([]string(nil), last.Failed...)
+	return &last
+}
+
+// beginRepair marks a repair as in progress
+func (s *statusSync) beginRepair() (*RepairReport, error) {
+	s.repairs.mu.Lock()
+	defer s.repairs.mu.Unlock()
+	if s.repairs.running {
+		return nil, ErrRepairInProgress
+	}
+	s.repairs.running = true
+	s.repairs.last = &RepairReport{StartedAt: s.Clock.Now()}
+	return s.repairs.last, nil
+}
+
+// runRepair repairs the status and records the end of the repair
+func (s *statusSync) runRepair(ctx context.Context, report *RepairReport) {
+	err := s.repair(ctx, report)
+
+	s.repairs.mu.Lock()
+	defer s.repairs.mu.Unlock()
+	now := s.Clock.Now()
+	report.FinishedAt = &now
+	if err != nil {
+		report.Error = err.Error()
+		glog.Errorf("status repair stopped after %v Ingresses: %v", report.Scanned, err)
+	} else {
+		glog.Infof("status repair finished: %v Ingresses checked, %v repaired, %v failed",
+			report.Scanned, report.Repaired, len(report.Failed))
+	}
+	s.repairs.running = false
+}
+
+// repairPeriodically starts a repair every RepairInterval while ctx is
+// not done
+func (s *statusSync) repairPeriodically(ctx context.Context) {
+	ticker := s.Clock.NewTicker(s.RepairInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		report, err := s.beginRepair()
+		if err != nil {
+			glog.V(2).Infof("skipping scheduled status repair: %v", err)
+			continue
+		}
+		s.runRepair(ctx, report)
+	}
+}
+
+// repair lists all the Ingresses of the class from the API server, not
+// the cache, and updates the ones whose status differs from the current
+// addresses, but the ones with the skip-status annotation, with at most
+// RepairQPS requests per second
+func (s *statusSync) repair(ctx context.Context, report *RepairReport) error {
+	status, err := s.currentStatus()
+	if err != nil {
+		return err
+	}
+
+	limiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	if s.RepairQPS > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(s.RepairQPS, 1)
+	}
+
+	s.repairs.mu.Lock()
+	report.Status = status
+	s.repairs.mu.Unlock()
+
+	ingClient := s.Client.NetworkingV1().Ingresses(metav1.NamespaceAll)
+	opts := metav1.ListOptions{Limit: repairPageSize}
+	for {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		ings, err := ingClient.List(ctx, opts)
+		if err != nil {
+			return errors.Wrap(err, "unexpected error listing Ingresses")
+		}
+
+		for i := range ings.Items {
+			ing := &ings.Items[i]
+			if !class.IsValid(ing) || !isStatusManaged(ing) {
+				continue
+			}
+
+			curIPs := normalizeStatus(ing.Status.LoadBalancer.Ingress)
+			if ingressSliceEqual(status, curIPs) {
+				s.recordRepair(report, ing.Namespace, ing.Name, "valid")
+				continue
+			}
+
+			if s.DryRun {
+				logDryRun("repair", ing, status)
+				s.recordRepair(report, ing.Namespace, ing.Name, "dry-run")
+				continue
+			}
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+			glog.Infof("repairing Ingress %v/%v status from %v to %v", ing.Namespace, ing.Name, curIPs, status)
+			if err := patchStatus(ctx, s.Client, ing.Namespace, ing.Name, status); err != nil {
+				syncError("status-repair", err, "error repairing the status of ingress %v/%v", ing.Namespace, ing.Name)
+				s.recordRepair(report, ing.Namespace, ing.Name, "failed")
+				continue
+			}
+			s.recordRepair(report, ing.Namespace, ing.Name, "repaired")
+		}
+
+		if ings.Continue == "" {
+			return nil
+		}
+		opts.Continue = ings.Continue
+	}
+}
+
+// recordRepair counts an Ingress checked by a repair
+func (s *statusSync) recordRepair(report *RepairReport, namespace, name, result string) {
+	metric.IncStatusRepair(result)
+
+	s.repairs.mu.Lock()
+	defer s.repairs.mu.Unlock()
+	report.Scanned++
+	switch result {
+	case "repaired":
+		report.Repaired++
+	case "failed":
+		report.Failed = append(report.Failed, fmt.Sprintf("%v/%v", namespace, name))
+	}
+}
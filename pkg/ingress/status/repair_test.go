@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+)
+
+func TestRepair(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	fk := buildStatusSync()
+
+	report, err := fk.beginRepair()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := fk.beginRepair(); err != ErrRepairInProgress {
+		t.Errorf("expected %v with a repair in progress but returned %v", ErrRepairInProgress, err)
+	}
+
+	fk.runRepair(context.TODO(), report)
+
+	last := fk.LastRepair()
+	if last == nil || last.FinishedAt == nil {
+		t.Fatalf("expected a finished repair but returned %+v", last)
+	}
+	if last.Error != "" || last.Scanned != 2 || last.Repaired != 2 || len(last.Failed) != 0 {
+		t.Errorf("expected 2 Ingresses checked and repaired but returned %+v", last)
+	}
+
+	for _, name := range []string{"foo_ingress_1", "foo_ingress_2"} {
+		ing, err := fk.Client.NetworkingV1().Ingresses(apiv1.NamespaceDefault).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []apiv1.LoadBalancerIngress{{IP: "11.0.0.1"}}
+		if !ingressSliceEqual(ing.Status.LoadBalancer.Ingress, expected) {
+			t.Errorf("expected status %v in ingress %v but got %v", expected, name, ing.Status.LoadBalancer.Ingress)
+		}
+	}
+
+	ing, _ := fk.Client.NetworkingV1().Ingresses(apiv1.NamespaceDefault).Get(context.TODO(), "foo_ingress_different_class", metav1.GetOptions{})
+	if ing.Status.LoadBalancer.Ingress[0].IP != "0.0.0.0" {
+		t.Errorf("expected the status of an Ingress of another class unchanged but got %v", ing.Status.LoadBalancer.Ingress)
+	}
+
+	report, err = fk.beginRepair()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fk.runRepair(context.TODO(), report)
+	if last := fk.LastRepair(); last.Scanned != 2 || last.Repaired != 0 {
+		t.Errorf("expected 2 valid Ingresses but returned %+v", last)
+	}
+}
@@ -21,11 +21,11 @@ package status
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
-	"sort"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -36,6 +36,9 @@ import (
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -44,19 +47,45 @@ import (
 	"k8s.io/client-go/tools/record"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+	ingerrors "github.com/stolostron/management-ingress/pkg/ingress/errors"
+	"github.com/stolostron/management-ingress/pkg/ingress/externaldns"
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
 	"github.com/stolostron/management-ingress/pkg/ingress/store"
+	"github.com/stolostron/management-ingress/pkg/ingress/targetgroup"
 	"github.com/stolostron/management-ingress/pkg/k8s"
 	"github.com/stolostron/management-ingress/pkg/task"
 )
 
 const (
-	updateInterval = 60 * time.Second
+	// skipStatusAnnotation excludes an Ingress from the status updates
+	skipStatusAnnotation = "skip-status"
+
+	// DefaultUpdateInterval is the interval between the syncs of the
+	// status besides the ones of the changes of the watched addresses
+	DefaultUpdateInterval = 10 * time.Minute
+
+	// DefaultUpdateWorkers is the number of Ingresses whose status is
+	// updated concurrently
+	DefaultUpdateWorkers = 10
+
+	// campaignInterval is the time between the loss of the leadership
+	// and the next campaign
+	campaignInterval = time.Second
 )
 
 // Sync ...
 type Sync interface {
 	Run()
 	Shutdown()
+	// IsLeader returns true if this instance updates the Ingresses
+	IsLeader() bool
+	// Trigger requests a sync of the status
+	Trigger()
+	// StartRepair starts a repair of the status of all the Ingresses
+	StartRepair() error
+	// LastRepair returns the repair in progress or the last one
+	LastRepair() *RepairReport
 }
 
 // Config ...
@@ -64,11 +93,118 @@ type Config struct {
 	Client clientset.Interface
 
 	ElectionID string
+	// LockType is the resource of the leader election, one of the
+	// LockTypes. DefaultLockType if empty
+	LockType string
+	// ElectionNamespace is the namespace of the lock, the one of the pod
+	// if empty
+	ElectionNamespace string
+	// ElectionLockName is the name of the lock, ElectionID and the class
+	// if empty
+	ElectionLockName string
+
+	// UpdateStatusOnShutdown removes the address from the Ingresses when
+	// the last replica stops
+	UpdateStatusOnShutdown bool
+	// ShutdownGrace is the time the leader waits on shutdown for a new
+	// replica, which keeps the status, before removing its address from
+	// the Ingresses. Disabled if zero
+	ShutdownGrace time.Duration
+	// DryRun logs the changes of the status of the Ingresses, and of their
+	// repairs, without writing them nor the summary
+	DryRun bool
+
+	// LeaderTasks are the subsystems run by the leader besides the sync
+	// of the status. Created if nil
+	LeaderTasks *LeaderTasks
+
+	// UpdateInterval is the interval between the syncs of the status
+	// besides the ones of the changes of the watched addresses.
+	// DefaultUpdateInterval if zero
+	UpdateInterval time.Duration
+	// UpdateWorkers is the number of Ingresses whose status is updated
+	// concurrently. DefaultUpdateWorkers if zero
+	UpdateWorkers int
 
 	IngressLister store.IngressLister
 
 	DefaultIngressClass string
 	IngressClass        string
+
+	// ExternalDNS publishes the DNS records of the Ingresses with their
+	// status. Nil if disabled
+	ExternalDNS *externaldns.Publisher
+
+	// VIP is the address published in the Ingresses instead of the
+	// addresses of the replicas, if set
+	VIP string
+
+	// PublishService is the Service, as namespace/name, whose load
+	// balancer addresses are published in the Ingresses instead of the
+	// addresses of the nodes of the replicas, if set
+	PublishService string
+
+	// PublishStatusAddresses are the IPs and hostnames published in the
+	// Ingresses instead of the addresses of the replicas, if set
+	PublishStatusAddresses []string
+
+	// AddressType selects the addresses of the nodes of the replicas
+	// published in the Ingresses, one of the AddressTypes. AddressIP if
+	// empty
+	AddressType string
+	// IPFamily selects the families of the IPs of the nodes published in
+	// the Ingresses, one of the IPFamilies. IPFamilyDual if empty
+	IPFamily string
+	// NodeAddressPreference are the types of the addresses of the nodes
+	// published in the Ingresses, the first one the node has is used.
+	// DefaultNodeAddressTypes if empty
+	NodeAddressPreference []apiv1.NodeAddressType
+
+	// TargetGroup registers the ready replicas in a cloud load balancer
+	// every TargetGroupInterval. Nil if disabled
+	TargetGroup         *targetgroup.Registrar
+	TargetGroupInterval time.Duration
+
+	// Recorder receives the warnings about co-located replicas, and the
+	// removals of the status on shutdown. Optional
+	Recorder record.EventRecorder
+	// DeschedulerHint marks the replicas sharing a node with an older one
+	// as evictable for the descheduler
+	DeschedulerHint bool
+
+	// RepairQPS limits the requests per second to the API server of the
+	// repairs of the status. Unlimited if zero
+	RepairQPS float32
+	// RepairInterval is the interval between the repairs of the status
+	// started by the leader. Disabled if zero
+	RepairInterval time.Duration
+
+	// SummaryNamespace and SummaryName are the ConfigMap where the leader
+	// publishes the ClusterIngressStatus. Disabled if empty
+	SummaryNamespace string
+	SummaryName      string
+
+	// GetPodDetails returns the pod of the controller.
+	// k8s.GetPodDetails if nil
+	GetPodDetails func(clientset.Interface) (*k8s.PodInfo, error)
+	// NewElector creates the Elector of the leader.
+	// leaderelection.NewLeaderElector if nil
+	NewElector func(leaderelection.LeaderElectionConfig) (Elector, error)
+	// Clock measures the intervals of the syncs and of the repairs of the
+	// status. The real clock if nil
+	Clock clock.Clock
+}
+
+// Elector elects the replica updating the status of the Ingresses
+type Elector interface {
+	// Run runs the election until ctx is done, calling the callbacks
+	Run(ctx context.Context)
+	IsLeader() bool
+}
+
+// newLeaderElector returns the Elector of client-go
+func newLeaderElector(lec leaderelection.LeaderElectionConfig) (Elector, error) {
+	return leaderelection.NewLeaderElector(lec)
 }
 
 // statusSync keeps the status IP in each Ingress rule updated executing a periodic check
@@ -81,26 +217,75 @@ type statusSync struct {
 	// pod contains runtime information about this pod
 	pod *k8s.PodInfo
 
-	elector *leaderelection.LeaderElector
+	elector Elector
 	// workqueue used to keep in sync the status IP/s
 	// in the Ingress rules
 	syncQueue *task.Queue
+
+	// repairs keeps the state of the repairs of the status
+	repairs *repairState
+	// watches keeps the watched pods of the controller
+	watches *addressWatch
+	// colocation keeps the co-located replicas last reported
+	colocation *colocationState
+
+	// stopCh is closed by Shutdown, ending the campaigns
+	stopCh   chan struct{}
+	stopOnce *sync.Once
+	// campaignInterval is the time between the loss of the leadership and
+	// the next campaign, replaced in the tests
+	campaignInterval time.Duration
 }
 
-// Run starts the loop to keep the status in sync
+// Run takes part in the election until Shutdown is called. The replica
+// campaigns again after losing the leadership, so the leader tasks start
+// again when it is elected.
 func (s statusSync) Run() {
-	s.elector.Run(context.TODO())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopCh
+		cancel()
+	}()
+	wait.UntilWithContext(ctx, s.elector.Run, s.campaignInterval)
+}
+
+// IsLeader returns true if this instance is the status update leader
+func (s statusSync) IsLeader() bool {
+	return s.elector.IsLeader()
 }
 
 // Shutdown stop the sync. In case the instance is the leader it will remove the current IP
-// if there is no other instances running.
+// if there is no other instances running, unless UpdateStatusOnShutdown is false.
 func (s statusSync) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
 	go s.syncQueue.Shutdown()
 	// remove IP from Ingress
 	if !s.elector.IsLeader() {
 		return
 	}
 
+	if s.TargetGroup != nil {
+		pod, err := s.Client.CoreV1().Pods(s.pod.Namespace).Get(context.TODO(), s.pod.Name, metav1.GetOptions{})
+		var pods []apiv1.Pod
+		if err == nil {
+			pods, err = s.runningPods()
+		}
+		if err == nil {
+			err = s.TargetGroup.Deregister(context.TODO(), pod, pods)
+		}
+		if err != nil {
+			glog.Errorf("unexpected error deregistering from the target group: %v", err)
+		}
+	}
+
+	if !s.UpdateStatusOnShutdown {
+		glog.Infof("keeping the status of the Ingresses (--update-status-on-shutdown=false)")
+		return
+	}
+
 	glog.Infof("updating status of Ingress rules (remove)")
 
 	addrs, err := s.runningAddresses()
@@ -120,8 +305,58 @@ func (s statusSync) Shutdown() {
 		return
 	}
 
+	if s.waitForReplacement() {
+		glog.Infof("leaving status update for the new replica")
+		return
+	}
+
 	glog.Infof("removing address from ingress status (%v)", addrs)
-	s.updateStatus([]apiv1.LoadBalancerIngress{})
+	removed, _ := s.updateStatus([]apiv1.LoadBalancerIngress{})
+	s.recordRemoval(removed, addrs)
+}
+
+// waitForReplacement waits up to ShutdownGrace for another replica of the
+// controller, like the new one of an upgrade, to run, and returns true if
+// it does
+func (s *statusSync) waitForReplacement() bool {
+	if s.ShutdownGrace <= 0 {
+		return false
+	}
+
+	glog.Infof("waiting up to %v for a new replica before removing the address from ingress status", s.ShutdownGrace)
+	timeout := s.Clock.After(s.ShutdownGrace)
+	ticker := s.Clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeout:
+			return false
+		case <-ticker.C():
+		}
+		if s.isRunningMultiplePods() {
+			return true
+		}
+	}
+}
+
+// recordRemoval records an event in each Ingress whose addresses were
+// removed on shutdown, and one in the pod of the controller
+func (s *statusSync) recordRemoval(ings []*networking.Ingress, addrs []string) {
+	if s.Recorder == nil || len(ings) == 0 {
+		return
+	}
+
+	for _, ing := range ings {
+		s.Recorder.Eventf(ing, apiv1.EventTypeNormal, "StatusRemoved",
+			"the addresses %v were removed from the status, the last replica of the controller stopped", addrs)
+	}
+	pod := &apiv1.ObjectReference{
+		Kind:      "Pod",
+		Namespace: s.pod.Namespace,
+		Name:      s.pod.Name,
+	}
+	s.Recorder.Eventf(pod, apiv1.EventTypeNormal, "StatusRemoved",
+		"the addresses %v were removed from the status of %v Ingresses on shutdown", addrs, len(ings))
 }
 
 func (s *statusSync) sync(key interface{}) error {
@@ -129,58 +364,116 @@ func (s *statusSync) sync(key interface{}) error {
 		glog.V(2).Infof("skipping Ingress status update (shutting down in progress)")
 		return nil
 	}
+	// the syncs queued before the loss of the leadership are dropped
+	if !s.elector.IsLeader() {
+		glog.V(2).Infof("skipping Ingress status update (not the leader)")
+		return nil
+	}
 
-	addrs, err := s.runningAddresses()
+	s.checkColocation()
+
+	status, err := s.currentStatus()
 	if err != nil {
 		return err
 	}
-	s.updateStatus(sliceToStatus(addrs))
+	// the queue syncs again with a backoff when an update failed
+	_, err = s.updateStatus(status)
+	return err
+}
+
+// currentStatus returns the addresses published in the Ingresses
+func (s *statusSync) currentStatus() ([]apiv1.LoadBalancerIngress, error) {
+	if s.VIP != "" {
+		return sliceToStatus([]string{s.VIP}), nil
+	}
+
+	addrs, err := s.runningAddresses()
+	if err != nil {
+		return nil, err
+	}
+	return sliceToStatus(addrs), nil
+}
 
-	return nil
+// syncTargetGroup registers the ready replicas in the target group
+func (s *statusSync) syncTargetGroup(ctx context.Context) {
+	pods, err := s.runningPods()
+	if err != nil {
+		glog.Errorf("unexpected error listing the controller pods: %v", err)
+		return
+	}
+	if err := s.TargetGroup.Sync(ctx, pods); err != nil {
+		glog.Errorf("unexpected error updating the target group: %v", err)
+	}
 }
 
 func (s statusSync) keyfunc(input interface{}) (interface{}, error) {
 	return input, nil
 }
 
-// NewStatusSyncer returns a new Sync instance
+// NewStatusSyncer returns a new Sync instance. When the pod of the
+// controller cannot be obtained, the returned Sync does not update the
+// status and retries until it succeeds.
 func NewStatusSyncer(config Config) Sync {
-	pod, err := k8s.GetPodDetails(config.Client)
+	st, err := newStatusSync(config)
 	if err != nil {
-		glog.Fatalf("unexpected error obtaining pod information: %v", err)
+		return newDegradedSync(config, err)
+	}
+	metric.SetStatusDegraded(false)
+	return st
+}
+
+// newStatusSync returns the Sync of the pod of the controller
+func newStatusSync(config Config) (Sync, error) {
+	if config.GetPodDetails == nil {
+		config.GetPodDetails = k8s.GetPodDetails
+	}
+	if config.NewElector == nil {
+		config.NewElector = newLeaderElector
+	}
+	if config.Clock == nil {
+		config.Clock = clock.RealClock{}
+	}
+
+	pod, err := config.GetPodDetails(config.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "unexpected error obtaining pod information")
 	}
 
 	st := statusSync{
 		pod: pod,
 
 		Config: config,
+
+		repairs:    &repairState{},
+		watches:    &addressWatch{},
+		colocation: &colocationState{},
+
+		stopCh:           make(chan struct{}),
+		stopOnce:         &sync.Once{},
+		campaignInterval: campaignInterval,
+	}
+	if st.UpdateInterval <= 0 {
+		st.UpdateInterval = DefaultUpdateInterval
+	}
+	if st.UpdateWorkers <= 0 {
+		st.UpdateWorkers = DefaultUpdateWorkers
 	}
 	st.syncQueue = task.NewCustomTaskQueue(st.sync, st.keyfunc)
 
-	// we need to use the defined ingress class to allow multiple leaders
-	// in order to update information about ingress status
-	electionID := fmt.Sprintf("%v-%v", config.ElectionID, config.DefaultIngressClass)
-	if config.IngressClass != "" {
-		electionID = fmt.Sprintf("%v-%v", config.ElectionID, config.IngressClass)
+	if st.LeaderTasks == nil {
+		st.LeaderTasks = NewLeaderTasks()
 	}
 
-	var stopCh chan struct{}
 	callbacks := leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
 			glog.V(2).Infof("I am the new status update leader")
-			stopCh = make(chan struct{})
-			go st.syncQueue.Run(time.Second, stopCh)
-			err = wait.PollUntil(updateInterval, func() (bool, error) {
-				// send a dummy object to the queue to force a sync
-				st.syncQueue.Enqueue("sync status")
-				return false, nil
-			}, stopCh)
-			if err != nil {
-				glog.Fatalf("failed to force a sync")
-			}
+			metric.SetStatusLeader(true)
+			st.LeaderTasks.startAll(ctx)
 		},
 		OnStoppedLeading: func() {
 			glog.V(2).Infof("I am not status update leader anymore")
+			metric.SetStatusLeader(false)
+			st.LeaderTasks.stopAll()
 		},
 		OnNewLeader: func(identity string) {
 			glog.Infof("new leader elected: %v", identity)
@@ -195,38 +488,26 @@ func NewStatusSyncer(config Config) Sync {
 		Host:      hostname,
 	})
 
-	podObj, _ := config.Client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
-	if podObj == nil {
-		glog.Fatalf("unable to get POD information")
-	}
-
-	blockOwnerDeletion := true
-	isController := true
-	lock := resourcelock.ConfigMapLock{
-		ConfigMapMeta: metav1.ObjectMeta{
-			Namespace: podObj.Namespace,
-			Name:      electionID,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "v1",
-					Kind:               "Pod",
-					Name:               podObj.Name,
-					UID:                podObj.UID,
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &isController,
-				},
-			},
-		},
-		Client: config.Client.CoreV1(),
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity:      podObj.Name,
-			EventRecorder: recorder,
-		},
+	podObj, err := config.Client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get POD information")
+	}
+
+	lockType := config.LockType
+	if lockType == "" {
+		lockType = DefaultLockType
+	}
+	lock, err := NewResourceLock(lockType, lockMeta(config, podObj), config.Client, resourcelock.ResourceLockConfig{
+		Identity:      podObj.Name,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	ttl := 30 * time.Second
-	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
-		Lock:          &lock,
+	le, err := config.NewElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
 		LeaseDuration: ttl,
 		RenewDeadline: ttl / 2,
 		RetryPeriod:   ttl / 4,
@@ -234,36 +515,116 @@ func NewStatusSyncer(config Config) Sync {
 	})
 
 	if err != nil {
-		glog.Fatalf("unexpected error starting leader election: %v", err)
+		return nil, errors.Wrap(err, "unexpected error starting leader election")
 	}
 
 	st.elector = le
-	return st
+	// a single worker for all the leaderships, stopped by Shutdown
+	go st.syncQueue.Run(time.Second, st.stopCh)
+
+	st.LeaderTasks.Register("status-sync", st.runSync)
+	st.LeaderTasks.Register("status-address-watch", func(ctx context.Context) {
+		st.watchAddresses(ctx.Done())
+	})
+	if st.TargetGroup != nil {
+		st.LeaderTasks.Register("aws-target-group", func(ctx context.Context) {
+			wait.UntilWithContext(ctx, st.syncTargetGroup, st.TargetGroupInterval)
+		})
+	}
+	if st.RepairInterval > 0 {
+		st.LeaderTasks.Register("status-repair", st.repairPeriodically)
+	}
+	return st, nil
+}
+
+// runSync syncs the status at once and every UpdateInterval until ctx is
+// done. The worker of the queue runs for all the leaderships.
+func (s *statusSync) runSync(ctx context.Context) {
+	ticker := s.Clock.NewTicker(s.UpdateInterval)
+	defer ticker.Stop()
+	for {
+		// send a dummy object to the queue to force a sync
+		s.syncQueue.Enqueue("sync status")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
 }
 
 // runningAddresses returns a list of IP addresses and/or FQDN where the
 // ingress controller is currently running
 func (s *statusSync) runningAddresses() ([]string, error) {
+	if len(s.PublishStatusAddresses) > 0 {
+		return s.PublishStatusAddresses, nil
+	}
+
+	if s.PublishService != "" {
+		return s.publishServiceAddresses()
+	}
+
 	addrs := []string{}
 
 	// get information about all the pods running the ingress controller
-	pods, err := s.Client.CoreV1().Pods(s.pod.Namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: labels.SelectorFromSet(s.pod.Labels).String(),
-	})
+	pods, err := s.runningPods()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, pod := range pods.Items {
-		name := k8s.GetNodeIPOrName(s.Client, pod.Spec.NodeName, true)
-		if !stringInSlice(name, addrs) {
-			addrs = append(addrs, name)
+	for _, pod := range pods {
+		for _, addr := range s.nodeAddresses(pod.Spec.NodeName) {
+			if !stringInSlice(addr, addrs) {
+				addrs = append(addrs, addr)
+			}
 		}
 	}
 
 	return addrs, nil
 }
 
+// publishServiceAddresses returns the load balancer addresses and the
+// external IPs of the PublishService
+func (s *statusSync) publishServiceAddresses() ([]string, error) {
+	ns, name, err := k8s.ParseNameNS(s.PublishService)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := s.Client.CoreV1().Services(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unexpected error searching Service %v", s.PublishService))
+	}
+
+	addrs := []string{}
+	for _, lbi := range svc.Status.LoadBalancer.Ingress {
+		if lbi.IP != "" {
+			addrs = append(addrs, lbi.IP)
+		} else if lbi.Hostname != "" {
+			addrs = append(addrs, lbi.Hostname)
+		}
+	}
+	addrs = append(addrs, svc.Spec.ExternalIPs...)
+
+	return addrs, nil
+}
+
+// runningPods returns the pods running the ingress controller, from the
+// watch of the leader if available
+func (s *statusSync) runningPods() ([]apiv1.Pod, error) {
+	if pods, ok := s.watches.cachedPods(); ok {
+		return pods, nil
+	}
+
+	pods, err := s.Client.CoreV1().Pods(s.pod.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(s.pod.Labels).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
 // stringInSlice returns true if s is in list
 func stringInSlice(s string, list []string) bool {
 	for _, v := range list {
@@ -276,17 +637,16 @@ func stringInSlice(s string, list []string) bool {
 }
 
 func (s *statusSync) isRunningMultiplePods() bool {
-	pods, err := s.Client.CoreV1().Pods(s.pod.Namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: labels.SelectorFromSet(s.pod.Labels).String(),
-	})
+	pods, err := s.runningPods()
 	if err != nil {
 		return false
 	}
 
-	return len(pods.Items) > 1
+	return len(pods) > 1
 }
 
-// sliceToStatus converts a slice of IP and/or hostnames to LoadBalancerIngress
+// sliceToStatus converts a slice of IP and/or hostnames to the normalized
+// LoadBalancerIngress
 func sliceToStatus(endpoints []string) []apiv1.LoadBalancerIngress {
 	lbi := []apiv1.LoadBalancerIngress{}
 	for _, ep := range endpoints {
@@ -297,81 +657,193 @@ func sliceToStatus(endpoints []string) []apiv1.LoadBalancerIngress {
 		}
 	}
 
-	sort.SliceStable(lbi, func(a, b int) bool {
-		return lbi[a].IP < lbi[b].IP
-	})
-
-	return lbi
+	return normalizeStatus(lbi)
 }
 
-// updateStatus changes the status information of Ingress rules
-func (s *statusSync) updateStatus(newIngressPoint []apiv1.LoadBalancerIngress) {
+// updateStatus changes the status information of Ingress rules and returns
+// the Ingresses whose status changed. It returns an error when the status
+// of an Ingress was not updated after the retries of runUpdate but can be
+// updated by a later sync.
+func (s *statusSync) updateStatus(newIngressPoint []apiv1.LoadBalancerIngress) ([]*networking.Ingress, error) {
 	ings := s.IngressLister.List()
+	newIngressPoint = normalizeStatus(newIngressPoint)
 
-	p := pool.NewLimited(10)
+	workers := s.UpdateWorkers
+	if workers <= 0 {
+		workers = DefaultUpdateWorkers
+	}
+	p := pool.NewLimited(uint(workers))
 	defer p.Close()
 
 	batch := p.Batch()
 
+	skipped := 0
 	for _, cur := range ings {
 		ing := cur.(*networking.Ingress)
 
 		if !class.IsValid(ing) {
 			continue
 		}
+		if !isStatusManaged(ing) {
+			glog.V(3).Infof("skipping update of Ingress %v/%v (skip-status)", ing.Namespace, ing.Name)
+			skipped++
+			continue
+		}
 
-		batch.Queue(runUpdate(ing, newIngressPoint, s.Client))
+		batch.Queue(runUpdate(ing, newIngressPoint, s.Client, s.ExternalDNS, s.DryRun))
 	}
 
 	batch.QueueComplete()
-	batch.WaitAll()
+
+	updated := []*networking.Ingress{}
+	var lastErr error
+	failed := 0
+	for wu := range batch.Results() {
+		if err := wu.Error(); err != nil && ingerrors.IsRetryable(err) {
+			lastErr = err
+			failed++
+		}
+		if ing, ok := wu.Value().(*networking.Ingress); ok {
+			updated = append(updated, ing)
+		}
+	}
+	metric.SetStatusSkippedIngresses(skipped)
+
+	s.publishSummary(newIngressPoint)
+
+	if lastErr != nil {
+		return updated, errors.Wrapf(lastErr, "the status of %v Ingresses was not updated", failed)
+	}
+	return updated, nil
 }
 
+// runUpdate returns the update of the status of the Ingress of the cache.
+// The Ingress is not read from the API server: the status of the cached
+// object is compared, and the merge patch of patchStatus does not need its
+// current version. In dry run the change is only logged. The value of the
+// work is the Ingress when its status is changed.
 func runUpdate(ing *networking.Ingress, status []apiv1.LoadBalancerIngress,
-	client clientset.Interface) pool.WorkFunc {
+	client clientset.Interface, dns *externaldns.Publisher, dryRun bool) pool.WorkFunc {
 	return func(wu pool.WorkUnit) (interface{}, error) {
 		if wu.IsCancelled() {
 			return nil, nil
 		}
 
-		sort.SliceStable(status, lessLoadBalancerIngress(status))
-
-		curIPs := ing.Status.LoadBalancer.Ingress
-		sort.SliceStable(curIPs, lessLoadBalancerIngress(curIPs))
+		if dryRun {
+			logDryRun("update", ing, status)
+			return true, nil
+		}
 
+		// the Ingress of the cache is not modified
+		curIPs := normalizeStatus(ing.Status.LoadBalancer.Ingress)
 		if ingressSliceEqual(status, curIPs) {
 			glog.V(3).Infof("skipping update of Ingress %v/%v (no change)", ing.Namespace, ing.Name)
+			// the records only change with the hosts then, compared
+			// without calling the API server
+			syncDNS(dns, ing, status)
 			return true, nil
 		}
 
-		ingClient := client.NetworkingV1().Ingresses(ing.Namespace)
-
-		currIng, err := ingClient.Get(context.TODO(), ing.Name, metav1.GetOptions{})
+		glog.Infof("updating Ingress %v/%v status to %v", ing.Namespace, ing.Name, status)
+		err := ingerrors.Retry(func() error {
+			return patchStatus(context.TODO(), client, ing.Namespace, ing.Name, status)
+		})
 		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("unexpected error searching Ingress %v/%v", ing.Namespace, ing.Name))
+			return nil, syncError("status", err, "error updating the status of ingress %v/%v", ing.Namespace, ing.Name)
 		}
+		syncDNS(dns, ing, status)
 
-		glog.Infof("updating Ingress %v/%v status to %v", currIng.Namespace, currIng.Name, status)
-		currIng.Status.LoadBalancer.Ingress = status
-		_, err = ingClient.UpdateStatus(context.TODO(), currIng, metav1.UpdateOptions{})
-		if err != nil {
-			glog.Warningf("error updating ingress rule: %v", err)
-		}
+		return ing, nil
+	}
+}
 
-		return true, nil
+// syncDNS publishes the DNS records of the Ingress with the addresses of
+// the status, if enabled
+func syncDNS(dns *externaldns.Publisher, ing *networking.Ingress, status []apiv1.LoadBalancerIngress) {
+	if dns == nil {
+		return
+	}
+	if err := dns.Sync(context.TODO(), ing, status); err != nil {
+		glog.Warningf("unexpected error publishing the DNS records of ingress %v/%v: %v", ing.Namespace, ing.Name, err)
 	}
 }
 
-func lessLoadBalancerIngress(addrs []apiv1.LoadBalancerIngress) func(int, int) bool {
-	return func(a, b int) bool {
-		switch strings.Compare(addrs[a].Hostname, addrs[b].Hostname) {
-		case -1:
-			return true
-		case 1:
-			return false
+// logDryRun logs the addresses added to and removed from the status of the
+// Ingress by an action not made in dry run
+func logDryRun(action string, ing *networking.Ingress, status []apiv1.LoadBalancerIngress) {
+	curIPs := normalizeStatus(ing.Status.LoadBalancer.Ingress)
+	if ingressSliceEqual(status, curIPs) {
+		glog.V(3).Infof("dry run: skipping %v of Ingress %v/%v (no change)", action, ing.Namespace, ing.Name)
+		return
+	}
+	added, removed := statusDiff(curIPs, status)
+	glog.Infof("dry run: would %v Ingress %v/%v status from %v to %v (added %v, removed %v)",
+		action, ing.Namespace, ing.Name, addressList(curIPs), addressList(status), added, removed)
+}
+
+// statusDiff returns the addresses of status missing in cur, and the ones
+// of cur missing in status
+func statusDiff(cur, status []apiv1.LoadBalancerIngress) (added, removed []string) {
+	curAddrs := sets.NewString(addressList(cur)...)
+	addrs := sets.NewString(addressList(status)...)
+	return addrs.Difference(curAddrs).List(), curAddrs.Difference(addrs).List()
+}
+
+// addressList returns the IPs and hostnames of the status
+func addressList(status []apiv1.LoadBalancerIngress) []string {
+	addrs := []string{}
+	for _, lbi := range status {
+		if lbi.IP != "" {
+			addrs = append(addrs, lbi.IP)
+		}
+		if lbi.Hostname != "" {
+			addrs = append(addrs, lbi.Hostname)
 		}
-		return addrs[a].IP < addrs[b].IP
 	}
+	return addrs
+}
+
+// isStatusManaged returns false if the Ingress has the skip-status
+// annotation, for the Ingresses whose status is set by another system
+func isStatusManaged(ing *networking.Ingress) bool {
+	skip, err := parser.GetBoolAnnotation(skipStatusAnnotation, ing)
+	return err != nil || !skip
+}
+
+// patchStatus replaces the addresses in the status of an Ingress with a
+// merge patch of the status subresource, which does not conflict with the
+// changes of the Ingress by other controllers
+func patchStatus(ctx context.Context, client clientset.Interface, namespace, name string,
+	status []apiv1.LoadBalancerIngress) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"ingress": status,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.NetworkingV1().Ingresses(namespace).Patch(ctx, name, types.MergePatchType, patch,
+		metav1.PatchOptions{}, "status")
+	return err
+}
+
+// syncError counts and logs an error of a request to the API server of the
+// component, and returns it with the message
+func syncError(component string, err error, format string, args ...interface{}) error {
+	category := ingerrors.CategoryOf(err)
+	metric.IncSyncError(component, string(category))
+
+	err = errors.Wrapf(err, format, args...)
+	switch category {
+	case ingerrors.Permission, ingerrors.Validation:
+		glog.Errorf("%v (%v, not retried)", err, category)
+	default:
+		glog.Warningf("%v (%v)", err, category)
+	}
+	return err
 }
 
 func ingressSliceEqual(lhs, rhs []apiv1.LoadBalancerIngress) bool {
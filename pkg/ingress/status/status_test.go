@@ -17,12 +17,20 @@ limitations under the License.
 package status
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
@@ -242,9 +250,11 @@ func buildStatusSync() statusSync {
 			},
 		},
 		syncQueue: task.NewTaskQueue(fakeSynFn),
+		repairs:   &repairState{},
 		Config: Config{
 			Client:        buildSimpleClientSet(),
 			IngressLister: buildIngressListener(),
+			Clock:         clock.RealClock{},
 		},
 	}
 }
@@ -284,6 +294,193 @@ func TestRunningAddresessWithPods(t *testing.T) {
 	}
 }
 
+func TestRunningAddresessWithAddressType(t *testing.T) {
+	fk := buildStatusSync()
+
+	for addressType, expected := range map[string][]string{
+		AddressIP:       {"11.0.0.1"},
+		AddressHostname: {"foo_node_2"},
+		AddressBoth:     {"11.0.0.1", "foo_node_2"},
+	} {
+		fk.AddressType = addressType
+		r, err := fk.runningAddresses()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r) != len(expected) {
+			t.Fatalf("returned %v but expected %v for %v", r, expected, addressType)
+		}
+		for i := range expected {
+			if r[i] != expected[i] {
+				t.Errorf("returned %v but expected %v for %v", r, expected, addressType)
+			}
+		}
+	}
+}
+
+func TestRunningAddresessWithPublishService(t *testing.T) {
+	fk := buildStatusSync()
+	fk.PublishService = "default/foo"
+
+	r, err := fk.runningAddresses()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "foo4"}
+	if len(r) != len(expected) {
+		t.Fatalf("returned %v but expected %v", r, expected)
+	}
+	for i := range expected {
+		if r[i] != expected[i] {
+			t.Errorf("returned %v but expected %v", r, expected)
+		}
+	}
+
+	fk.PublishService = "default/foo_missing"
+	if _, err := fk.runningAddresses(); err == nil {
+		t.Errorf("expected an error for a Service that does not exist")
+	}
+}
+
+func TestRunningAddresessWithPublishStatusAddresses(t *testing.T) {
+	fk := buildStatusSync()
+	fk.PublishService = "default/foo"
+	fk.PublishStatusAddresses = []string{"192.168.1.10", "hub.example.com"}
+
+	r, err := fk.runningAddresses()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r) != 2 || r[0] != "192.168.1.10" || r[1] != "hub.example.com" {
+		t.Errorf("returned %v but expected %v", r, fk.PublishStatusAddresses)
+	}
+}
+
+func TestUpdateStatusFromCache(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	fk := buildStatusSync()
+	client := fk.Client.(*testclient.Clientset)
+	client.ClearActions()
+
+	if _, err := fk.updateStatus(buildLoadBalancerIngressByIP()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "ingresses" && action.GetVerb() != "patch" {
+			t.Errorf("expected only patches of the Ingresses but got %v", action)
+		}
+	}
+	if len(client.Actions()) != 1 {
+		t.Errorf("expected a patch of the Ingress whose cached status differs but got %v", client.Actions())
+	}
+}
+
+func TestUpdateStatusRequeue(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	gr := schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}
+	tests := []struct {
+		err     error
+		requeue bool
+	}{
+		{nil, false},
+		{apierrors.NewConflict(gr, "foo_ingress_1", errors.New("changed")), true},
+		{apierrors.NewForbidden(gr, "foo_ingress_1", errors.New("denied")), false},
+	}
+
+	for _, test := range tests {
+		fk := buildStatusSync()
+		client := fk.Client.(*testclient.Clientset)
+		patchErr := test.err
+		client.PrependReactor("patch", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return patchErr != nil, nil, patchErr
+		})
+
+		_, err := fk.updateStatus(buildLoadBalancerIngressByIP())
+		if (err != nil) != test.requeue {
+			t.Errorf("expected a requeue %v with the error %v but got %v", test.requeue, test.err, err)
+		}
+	}
+}
+
+func TestUpdateStatusDryRun(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	fk := buildStatusSync()
+	fk.DryRun = true
+	client := fk.Client.(*testclient.Clientset)
+	client.ClearActions()
+
+	if _, err := fk.updateStatus(buildLoadBalancerIngressByIP()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("expected no request in dry run but got %v", client.Actions())
+	}
+}
+
+func TestStatusDiff(t *testing.T) {
+	cur := []apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}, {IP: "10.0.0.2", Hostname: "foo2"}}
+	status := []apiv1.LoadBalancerIngress{{IP: "10.0.0.2"}, {Hostname: "foo3"}}
+
+	added, removed := statusDiff(cur, status)
+	if !reflect.DeepEqual(added, []string{"foo3"}) {
+		t.Errorf("expected foo3 added but got %v", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"10.0.0.1", "foo2"}) {
+		t.Errorf("expected 10.0.0.1 and foo2 removed but got %v", removed)
+	}
+}
+
+func TestIsStatusManaged(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"":      true,
+		"true":  false,
+		"false": true,
+		"demo":  true,
+	} {
+		ing := &networking.Ingress{}
+		if value != "" {
+			ing.Annotations = map[string]string{"ingress.open-cluster-management.io/skip-status": value}
+		}
+		if managed := isStatusManaged(ing); managed != expected {
+			t.Errorf("expected %v with skip-status %q but returned %v", expected, value, managed)
+		}
+	}
+}
+
+func TestPatchStatus(t *testing.T) {
+	fk := buildStatusSync()
+	client := fk.Client.(*testclient.Clientset)
+	client.ClearActions()
+
+	expected := []apiv1.LoadBalancerIngress{{IP: "10.0.0.5"}}
+	err := patchStatus(context.TODO(), client, apiv1.NamespaceDefault, "foo_ingress_1", expected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actions := client.Actions()
+	if len(actions) != 1 || actions[0].GetVerb() != "patch" || actions[0].GetSubresource() != "status" {
+		t.Fatalf("expected a patch of the status but got %v", actions)
+	}
+	ing, err := client.NetworkingV1().Ingresses(apiv1.NamespaceDefault).Get(context.TODO(), "foo_ingress_1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ingressSliceEqual(ing.Status.LoadBalancer.Ingress, expected) {
+		t.Errorf("expected status %v but got %v", expected, ing.Status.LoadBalancer.Ingress)
+	}
+}
+
 func TestSliceToStatus(t *testing.T) {
 	fkEndpoints := []string{
 		"10.0.0.1",
@@ -0,0 +1,157 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	ingerrors "github.com/stolostron/management-ingress/pkg/ingress/errors"
+)
+
+// SummaryKey is the key of the ConfigMap of the summary with the
+// ClusterIngressStatus document
+const SummaryKey = "status"
+
+// ClusterIngressStatus summarizes the entry points of the hub published by
+// the leader, so the clients do not need to list all the Ingresses
+type ClusterIngressStatus struct {
+	// Addresses are the addresses published in the status of the Ingresses
+	Addresses []apiv1.LoadBalancerIngress `json:"addresses"`
+	// Hosts are the hosts of the rules of the Ingresses of the class
+	Hosts []string `json:"hosts"`
+	// Ingresses is the number of Ingresses of the class
+	Ingresses int `json:"ingresses"`
+	// Leader is the pod of the replica updating the status
+	Leader string `json:"leader"`
+	// Replicas and ReadyReplicas are the number of replicas of the
+	// controller and of the ready ones
+	Replicas      int `json:"replicas"`
+	ReadyReplicas int `json:"readyReplicas"`
+	// Updated is the time of the last change of the summary
+	Updated metav1.Time `json:"updated"`
+}
+
+// summary returns the ClusterIngressStatus of the addresses of the status
+func (s *statusSync) summary(status []apiv1.LoadBalancerIngress) (*ClusterIngressStatus, error) {
+	pods, err := s.runningPods()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := &ClusterIngressStatus{
+		Addresses: status,
+		Hosts:     []string{},
+		Leader:    s.pod.Name,
+		Replicas:  len(pods),
+	}
+	for i := range pods {
+		if isReady(&pods[i]) {
+			sum.ReadyReplicas++
+		}
+	}
+
+	hosts := map[string]bool{}
+	for _, cur := range s.IngressLister.List() {
+		ing := cur.(*networking.Ingress)
+		if !class.IsValid(ing) {
+			continue
+		}
+		sum.Ingresses++
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != "" && !hosts[rule.Host] {
+				hosts[rule.Host] = true
+				sum.Hosts = append(sum.Hosts, rule.Host)
+			}
+		}
+	}
+	sort.Strings(sum.Hosts)
+
+	return sum, nil
+}
+
+// publishSummary writes the ClusterIngressStatus of the addresses of the
+// status in the ConfigMap of SummaryNamespace and SummaryName, when it
+// changes
+func (s *statusSync) publishSummary(status []apiv1.LoadBalancerIngress) {
+	if s.SummaryName == "" || s.DryRun {
+		return
+	}
+
+	sum, err := s.summary(status)
+	if err == nil {
+		err = s.writeSummary(context.TODO(), sum)
+	}
+	if err != nil {
+		syncError("status-summary", err, "unexpected error publishing the status summary in ConfigMap %v/%v", s.SummaryNamespace, s.SummaryName)
+	}
+}
+
+// writeSummary updates the ConfigMap of the summary, unless the summary
+// only differs in the time of the last change. The retryable errors are
+// retried.
+func (s *statusSync) writeSummary(ctx context.Context, sum *ClusterIngressStatus) error {
+	return ingerrors.Retry(func() error {
+		cms := s.Client.CoreV1().ConfigMaps(s.SummaryNamespace)
+		cm, err := cms.Get(ctx, s.SummaryName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			sum.Updated = metav1.Now()
+			b, err := json.Marshal(sum)
+			if err != nil {
+				return err
+			}
+			cm = &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: s.SummaryNamespace, Name: s.SummaryName},
+				Data:       map[string]string{SummaryKey: string(b)},
+			}
+			_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		var cur ClusterIngressStatus
+		if err := json.Unmarshal([]byte(cm.Data[SummaryKey]), &cur); err == nil {
+			sum.Updated = cur.Updated
+			if b, err := json.Marshal(sum); err == nil && string(b) == cm.Data[SummaryKey] {
+				return nil
+			}
+		}
+
+		sum.Updated = metav1.Now()
+		b, err := json.Marshal(sum)
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[SummaryKey] = string(b)
+		glog.V(2).Infof("updating the status summary in ConfigMap %v/%v", s.SummaryNamespace, s.SummaryName)
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// isReady returns true if the pod accepts requests and is not terminating
+func isReady(pod *apiv1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
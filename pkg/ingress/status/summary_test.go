@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+)
+
+func TestPublishSummary(t *testing.T) {
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	fk := buildStatusSync()
+	fk.SummaryNamespace = apiv1.NamespaceDefault
+	fk.SummaryName = "management-ingress-status"
+
+	fk.publishSummary(sliceToStatus([]string{"11.0.0.1"}))
+
+	cm, err := fk.Client.CoreV1().ConfigMaps(apiv1.NamespaceDefault).Get(context.TODO(), fk.SummaryName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var sum ClusterIngressStatus
+	if err := json.Unmarshal([]byte(cm.Data[SummaryKey]), &sum); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ingressSliceEqual(sum.Addresses, []apiv1.LoadBalancerIngress{{IP: "11.0.0.1"}}) {
+		t.Errorf("expected the address 11.0.0.1 but got %v", sum.Addresses)
+	}
+	if sum.Leader != "foo_base_pod" || sum.Replicas != 1 || sum.ReadyReplicas != 0 || sum.Ingresses != 2 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+
+	published := cm.Data[SummaryKey]
+	fk.publishSummary(sliceToStatus([]string{"11.0.0.1"}))
+	cm, _ = fk.Client.CoreV1().ConfigMaps(apiv1.NamespaceDefault).Get(context.TODO(), fk.SummaryName, metav1.GetOptions{})
+	if cm.Data[SummaryKey] != published {
+		t.Errorf("expected the summary unchanged but got %v", cm.Data[SummaryKey])
+	}
+
+	fk.publishSummary(sliceToStatus([]string{}))
+	cm, _ = fk.Client.CoreV1().ConfigMaps(apiv1.NamespaceDefault).Get(context.TODO(), fk.SummaryName, metav1.GetOptions{})
+	if err := json.Unmarshal([]byte(cm.Data[SummaryKey]), &sum); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sum.Addresses) != 0 {
+		t.Errorf("expected no address but got %v", sum.Addresses)
+	}
+}
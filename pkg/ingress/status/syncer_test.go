@@ -0,0 +1,399 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	clientset "k8s.io/client-go/kubernetes"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/k8s"
+	"github.com/stolostron/management-ingress/pkg/task"
+)
+
+// fakeElector is an Elector whose leadership is set by the tests. Run
+// returns at once, like after the loss of the leadership.
+type fakeElector struct {
+	mu     sync.Mutex
+	config leaderelection.LeaderElectionConfig
+	leader bool
+	runs   int
+}
+
+func (e *fakeElector) Run(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runs++
+}
+
+func (e *fakeElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *fakeElector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leader = leader
+}
+
+func (e *fakeElector) campaigns() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.runs
+}
+
+// buildSyncerConfig returns the Config of a status syncer of the pod
+// foo_base_pod with a fakeElector
+func buildSyncerConfig(t *testing.T, elector *fakeElector) Config {
+	client := buildSimpleClientSet()
+	_, err := client.CoreV1().Pods(apiv1.NamespaceDefault).Create(context.TODO(), &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo_base_pod",
+			Namespace: apiv1.NamespaceDefault,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return Config{
+		Client:                 client,
+		IngressLister:          buildIngressListener(),
+		ElectionID:             "ingress-controller-leader",
+		UpdateStatusOnShutdown: true,
+		GetPodDetails: func(clientset.Interface) (*k8s.PodInfo, error) {
+			return &k8s.PodInfo{
+				Name:      "foo_base_pod",
+				Namespace: apiv1.NamespaceDefault,
+				Labels: map[string]string{
+					"lable_sig": "foo_pod",
+				},
+			}, nil
+		},
+		NewElector: func(lec leaderelection.LeaderElectionConfig) (Elector, error) {
+			elector.config = lec
+			return elector, nil
+		},
+		Clock: clock.NewFakeClock(time.Now()),
+	}
+}
+
+func TestNewStatusSyncerPodError(t *testing.T) {
+	config := buildSyncerConfig(t, &fakeElector{})
+	config.GetPodDetails = func(clientset.Interface) (*k8s.PodInfo, error) {
+		return nil, errors.New("no pod")
+	}
+
+	if _, ok := NewStatusSyncer(config).(*degradedSync); !ok {
+		t.Errorf("expected a degraded sync without the pod information")
+	}
+}
+
+func TestNewStatusSyncerDefaults(t *testing.T) {
+	sync, ok := NewStatusSyncer(buildSyncerConfig(t, &fakeElector{})).(statusSync)
+	if !ok {
+		t.Fatalf("expected a status sync")
+	}
+	if sync.UpdateInterval != DefaultUpdateInterval {
+		t.Errorf("expected the update interval %v but got %v", DefaultUpdateInterval, sync.UpdateInterval)
+	}
+	if sync.UpdateWorkers != DefaultUpdateWorkers {
+		t.Errorf("expected %v update workers but got %v", DefaultUpdateWorkers, sync.UpdateWorkers)
+	}
+}
+
+func TestStatusSyncerLeadership(t *testing.T) {
+	elector := &fakeElector{}
+	config := buildSyncerConfig(t, elector)
+	config.LeaderTasks = NewLeaderTasks()
+	started := make(chan struct{}, 1)
+	config.LeaderTasks.Register("test", func(ctx context.Context) {
+		started <- struct{}{}
+	})
+
+	st, ok := NewStatusSyncer(config).(statusSync)
+	if !ok {
+		t.Fatalf("expected a status sync")
+	}
+	st.campaignInterval = 10 * time.Millisecond
+	var sync Sync = st
+
+	// the replica campaigns again after losing the leadership
+	done := make(chan struct{})
+	go func() {
+		sync.Run()
+		close(done)
+	}()
+	for i := 0; i < 100 && elector.campaigns() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elector.campaigns() < 2 {
+		t.Errorf("expected the replica to campaign again but it campaigned %v times", elector.campaigns())
+	}
+
+	// the repairs run with the context of the leadership
+	elector.setLeader(true)
+	if err := sync.StartRepair(); err != ErrNotLeader {
+		t.Errorf("expected %v before the leadership started but returned %v", ErrNotLeader, err)
+	}
+
+	// the tasks start with every leadership and return at once with a
+	// canceled context
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		elector.setLeader(true)
+		elector.config.Callbacks.OnStartedLeading(ctx)
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the leader tasks to start with the leadership %v", i+1)
+		}
+		if !sync.IsLeader() {
+			t.Errorf("expected the leader")
+		}
+
+		elector.setLeader(false)
+		elector.config.Callbacks.OnStoppedLeading()
+		config.LeaderTasks.mu.Lock()
+		leading := config.LeaderTasks.ctx != nil
+		config.LeaderTasks.mu.Unlock()
+		if leading {
+			t.Errorf("expected the leader tasks to stop with the leadership")
+		}
+		if err := sync.StartRepair(); err != ErrNotLeader {
+			t.Errorf("expected %v after the leadership but returned %v", ErrNotLeader, err)
+		}
+	}
+
+	sync.Shutdown()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected the campaigns to stop on shutdown")
+	}
+}
+
+func TestStatusSyncerTriggerNotLeader(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	config := buildSyncerConfig(t, &fakeElector{})
+	config.PublishStatusAddresses = []string{"192.0.2.1"}
+	client := config.Client.(*testclient.Clientset)
+	st := NewStatusSyncer(config).(statusSync)
+	defer st.Shutdown()
+	client.ClearActions()
+
+	// the syncs queued are counted
+	var queued int32
+	queue := task.NewTaskQueue(func(interface{}) error {
+		atomic.AddInt32(&queued, 1)
+		return nil
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go queue.Run(time.Second, stopCh)
+	notLeader := st
+	notLeader.syncQueue = queue
+
+	notLeader.Trigger()
+	st.Trigger()
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&queued); n != 0 {
+		t.Errorf("expected no sync queued by a replica that is not the leader but %v were", n)
+	}
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "ingresses" && action.GetVerb() == "patch" {
+			t.Errorf("expected no status update by a replica that is not the leader but got %v", action)
+		}
+	}
+}
+
+func TestStatusSyncerLostLeadership(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	elector := &fakeElector{leader: true}
+	config := buildSyncerConfig(t, elector)
+	config.PublishStatusAddresses = []string{"192.0.2.1"}
+	client := config.Client.(*testclient.Clientset)
+	sync := NewStatusSyncer(config)
+	defer sync.Shutdown()
+
+	patched := func() bool {
+		for _, action := range client.Actions() {
+			if action.GetResource().Resource == "ingresses" && action.GetVerb() == "patch" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// the leader updates the status
+	sync.Trigger()
+	for i := 0; i < 100 && !patched(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !patched() {
+		t.Fatalf("expected the leader to update the status")
+	}
+
+	elector.setLeader(false)
+	elector.config.Callbacks.OnStoppedLeading()
+	client.ClearActions()
+	sync.Trigger()
+	time.Sleep(100 * time.Millisecond)
+	if patched() {
+		t.Errorf("expected no status update after the loss of the leadership but got %v", client.Actions())
+	}
+}
+
+func TestStatusSyncerShutdown(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	testCases := []struct {
+		leader   bool
+		update   bool
+		expected bool
+	}{
+		{false, true, false},
+		{true, true, true},
+		// --update-status-on-shutdown=false keeps the status
+		{true, false, false},
+	}
+
+	for _, tc := range testCases {
+		config := buildSyncerConfig(t, &fakeElector{leader: tc.leader})
+		config.UpdateStatusOnShutdown = tc.update
+		recorder := record.NewFakeRecorder(10)
+		config.Recorder = recorder
+		client := config.Client.(*testclient.Clientset)
+		sync := NewStatusSyncer(config)
+		client.ClearActions()
+
+		sync.Shutdown()
+
+		patched := 0
+		for _, action := range client.Actions() {
+			if action.GetResource().Resource == "ingresses" && action.GetVerb() == "patch" {
+				patched++
+			}
+		}
+		if (patched > 0) != tc.expected {
+			t.Errorf("expected the status removed on shutdown %v as leader %v with update %v but got %v",
+				tc.expected, tc.leader, tc.update, client.Actions())
+		}
+		// an event in each Ingress changed and one in the pod
+		if tc.expected && len(recorder.Events) != patched+1 {
+			t.Errorf("expected %v events but got %v", patched+1, len(recorder.Events))
+		}
+		if !tc.expected && len(recorder.Events) != 0 {
+			t.Errorf("expected no events but got %v", len(recorder.Events))
+		}
+	}
+}
+
+func TestStatusSyncerShutdownGrace(t *testing.T) {
+	// the Ingresses without class are handled by the controllers without class
+	defer func(ingressClass string) { class.IngressClass = ingressClass }(class.IngressClass)
+	class.IngressClass = ""
+
+	for _, replaced := range []bool{false, true} {
+		config := buildSyncerConfig(t, &fakeElector{leader: true})
+		config.ShutdownGrace = 10 * time.Second
+		fakeClock := config.Clock.(*clock.FakeClock)
+		client := config.Client.(*testclient.Clientset)
+		sync := NewStatusSyncer(config)
+		client.ClearActions()
+
+		done := make(chan struct{})
+		go func() {
+			sync.Shutdown()
+			close(done)
+		}()
+		// the grace starts when the shutdown waits on the clock
+		for i := 0; i < 100 && !fakeClock.HasWaiters(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		// no replica in the first ticks of the grace
+		for i := 0; i < 3; i++ {
+			fakeClock.Step(time.Second)
+			time.Sleep(10 * time.Millisecond)
+		}
+		if isDone(done) {
+			t.Fatalf("expected the shutdown to wait for a new replica")
+		}
+
+		if replaced {
+			_, err := client.CoreV1().Pods(apiv1.NamespaceDefault).Create(context.TODO(), &apiv1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo4",
+					Namespace: apiv1.NamespaceDefault,
+					Labels: map[string]string{
+						"lable_sig": "foo_pod",
+					},
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// the new replica ends the grace at a tick, before the timeout
+			for i := 0; i < 6 && !isDone(done); i++ {
+				fakeClock.Step(time.Second)
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !isDone(done) {
+				t.Fatalf("expected the shutdown to end at the tick with the new replica")
+			}
+		} else {
+			// the grace ends after 10 ticks
+			for i := 0; i < 100 && !isDone(done); i++ {
+				fakeClock.Step(time.Second)
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !isDone(done) {
+				t.Fatalf("expected the shutdown to end after the grace")
+			}
+		}
+
+		patched := false
+		for _, action := range client.Actions() {
+			if action.GetResource().Resource == "ingresses" && action.GetVerb() == "patch" {
+				patched = true
+			}
+		}
+		if patched == replaced {
+			t.Errorf("expected the status removed %v with a new replica %v", !replaced, replaced)
+		}
+	}
+}
+
+// isDone returns true if ch is closed
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stolostron/management-ingress/pkg/k8s"
+)
+
+// addressWatch keeps the pods of the controller and the PublishService
+// watched by the leader, shared by the copies of the statusSync
+type addressWatch struct {
+	mu sync.RWMutex
+	// pods is nil until the leader watches the pods
+	pods cache.Store
+}
+
+// cachedPods returns the watched pods of the controller sorted by name,
+// false if they are not watched
+func (w *addressWatch) cachedPods() ([]apiv1.Pod, bool) {
+	if w == nil {
+		return nil, false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.pods == nil {
+		return nil, false
+	}
+
+	pods := []apiv1.Pod{}
+	for _, obj := range w.pods.List() {
+		pods = append(pods, *obj.(*apiv1.Pod))
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, true
+}
+
+// setPods sets the store of the watched pods, nil once they are not
+// watched
+func (w *addressWatch) setPods(pods cache.Store) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pods = pods
+}
+
+// Trigger requests a sync of the status, like for a new Ingress. It is
+// ignored by the replicas that are not the leader, whose Ingress
+// informers see the same changes.
+func (s statusSync) Trigger() {
+	if !s.elector.IsLeader() {
+		return
+	}
+	s.syncQueue.Enqueue("sync status")
+}
+
+// watchAddresses watches the pods of the controller and the
+// PublishService until stopCh is closed, and syncs the status when the
+// addresses of the replicas can change
+func (s *statusSync) watchAddresses(stopCh <-chan struct{}) {
+	selector := labels.SelectorFromSet(s.pod.Labels).String()
+	podStore, podInformer := cache.NewInformer(
+		cache.NewFilteredListWatchFromClient(s.Client.CoreV1().RESTClient(), "pods", s.pod.Namespace,
+			func(options *metav1.ListOptions) {
+				options.LabelSelector = selector
+			}),
+		&apiv1.Pod{}, 0, cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				s.Trigger()
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				if podAddressChanged(old.(*apiv1.Pod), cur.(*apiv1.Pod)) {
+					s.Trigger()
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				s.Trigger()
+			},
+		})
+	go podInformer.Run(stopCh)
+
+	if s.PublishService != "" {
+		ns, name, err := k8s.ParseNameNS(s.PublishService)
+		if err != nil {
+			glog.Errorf("unexpected error watching Service %v: %v", s.PublishService, err)
+		} else {
+			_, svcInformer := cache.NewInformer(
+				cache.NewListWatchFromClient(s.Client.CoreV1().RESTClient(), "services", ns,
+					fields.OneTermEqualSelector("metadata.name", name)),
+				&apiv1.Service{}, 0, cache.ResourceEventHandlerFuncs{
+					AddFunc: func(obj interface{}) {
+						s.Trigger()
+					},
+					UpdateFunc: func(old, cur interface{}) {
+						if serviceAddressChanged(old.(*apiv1.Service), cur.(*apiv1.Service)) {
+							s.Trigger()
+						}
+					},
+					DeleteFunc: func(obj interface{}) {
+						s.Trigger()
+					},
+				})
+			go svcInformer.Run(stopCh)
+		}
+	}
+
+	if !cache.WaitForCacheSync(stopCh, podInformer.HasSynced) {
+		return
+	}
+	s.watches.setPods(podStore)
+	<-stopCh
+	s.watches.setPods(nil)
+}
+
+// podAddressChanged returns true if the address published for a replica
+// can differ between the versions of its pod
+func podAddressChanged(old, cur *apiv1.Pod) bool {
+	return old.Spec.NodeName != cur.Spec.NodeName ||
+		(old.DeletionTimestamp == nil) != (cur.DeletionTimestamp == nil)
+}
+
+// serviceAddressChanged returns true if the addresses of the versions of
+// the PublishService differ
+func serviceAddressChanged(old, cur *apiv1.Service) bool {
+	return !reflect.DeepEqual(old.Status.LoadBalancer, cur.Status.LoadBalancer) ||
+		!reflect.DeepEqual(old.Spec.ExternalIPs, cur.Spec.ExternalIPs)
+}
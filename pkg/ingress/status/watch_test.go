@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package status
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodAddressChanged(t *testing.T) {
+	now := metav1.Now()
+	pod := &apiv1.Pod{Spec: apiv1.PodSpec{NodeName: "node-1"}}
+
+	moved := pod.DeepCopy()
+	moved.Spec.NodeName = "node-2"
+	terminating := pod.DeepCopy()
+	terminating.DeletionTimestamp = &now
+	ready := pod.DeepCopy()
+	ready.Status.Conditions = []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionTrue}}
+
+	fooTests := []struct {
+		cur *apiv1.Pod
+		er  bool
+	}{
+		{pod, false},
+		{moved, true},
+		{terminating, true},
+		{ready, false},
+	}
+
+	for _, fooTest := range fooTests {
+		if r := podAddressChanged(pod, fooTest.cur); r != fooTest.er {
+			t.Errorf("returned %v but expected %v for %+v", r, fooTest.er, fooTest.cur)
+		}
+	}
+}
+
+func TestServiceAddressChanged(t *testing.T) {
+	svc := &apiv1.Service{}
+	svc.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+
+	lb := svc.DeepCopy()
+	lb.Status.LoadBalancer.Ingress[0].IP = "10.0.0.2"
+	external := svc.DeepCopy()
+	external.Spec.ExternalIPs = []string{"10.0.0.3"}
+	labeled := svc.DeepCopy()
+	labeled.Labels = map[string]string{"app": "management-ingress"}
+
+	fooTests := []struct {
+		cur *apiv1.Service
+		er  bool
+	}{
+		{svc, false},
+		{lb, true},
+		{external, true},
+		{labeled, false},
+	}
+
+	for _, fooTest := range fooTests {
+		if r := serviceAddressChanged(svc, fooTest.cur); r != fooTest.er {
+			t.Errorf("returned %v but expected %v for %+v", r, fooTest.er, fooTest.cur)
+		}
+	}
+}
+
+func TestRunningPodsFromWatch(t *testing.T) {
+	fk := buildStatusSync()
+	fk.watches = &addressWatch{}
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: apiv1.NamespaceDefault}})
+	store.Add(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: apiv1.NamespaceDefault}})
+	fk.watches.setPods(store)
+
+	pods, err := fk.runningPods()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pods) != 2 || pods[0].Name != "a" || pods[1].Name != "b" {
+		t.Errorf("expected the watched pods a and b but returned %v", pods)
+	}
+
+	fk.watches.setPods(nil)
+	pods, _ = fk.runningPods()
+	if len(pods) != 1 || pods[0].Name != "foo1" {
+		t.Errorf("expected the pods listed from the API server but returned %v", pods)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -100,6 +101,24 @@ func (s *EndpointLister) GetServiceEndpoints(svc *apiv1.Service) (*apiv1.Endpoin
 	return nil, fmt.Errorf("could not find endpoints for service: %v", svc.Name)
 }
 
+// EndpointSliceLister makes a Store that lists EndpointSlices.
+type EndpointSliceLister struct {
+	cache.Store
+}
+
+// GetServiceEndpointSlices returns the EndpointSlices of a service, matched
+// on the service name label.
+func (s *EndpointSliceLister) GetServiceEndpointSlices(namespace, name string) []*discovery.EndpointSlice {
+	var slices []*discovery.EndpointSlice
+	for _, m := range s.Store.List() {
+		slice := m.(*discovery.EndpointSlice)
+		if slice.Namespace == namespace && slice.Labels[discovery.LabelServiceName] == name {
+			slices = append(slices, slice)
+		}
+	}
+	return slices
+}
+
 // SSLCertTracker holds a store of referenced Secrets in Ingress rules
 type SSLCertTracker struct {
 	cache.ThreadSafeStore
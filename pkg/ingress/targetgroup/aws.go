@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package targetgroup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+const elbService = "elasticloadbalancing"
+
+// Target is a target of a target group
+type Target struct {
+	// ID is the IP address or the EC2 instance ID
+	ID   string
+	Port int
+	// State is the health state of a registered target, like healthy
+	// or draining
+	State string
+}
+
+// key identifies the target in a target group
+func (t Target) key() string {
+	return fmt.Sprintf("%v:%v", t.ID, t.Port)
+}
+
+// ParseARN returns the region of the ARN of a target group
+func ParseARN(arn string) (string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != elbService || !strings.HasPrefix(parts[5], "targetgroup/") {
+		return "", fmt.Errorf("%q is not the ARN of a target group", arn)
+	}
+	if parts[3] == "" {
+		return "", fmt.Errorf("the ARN %q has no region", arn)
+	}
+	return parts[3], nil
+}
+
+// Client calls the Elastic Load Balancing v2 API
+type Client struct {
+	ELB elbv2iface.ELBV2API
+}
+
+// NewClient returns a Client of the region with the credentials of the
+// default chain of the AWS SDK: the IAM role of the ServiceAccount
+// (AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE), the keys of the
+// environment or the role of the instance
+func NewClient(region string) (*Client, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{ELB: elbv2.New(sess)}, nil
+}
+
+// Describe returns the targets registered in the target group
+func (c *Client) Describe(ctx context.Context, arn string) ([]Target, error) {
+	out, err := c.ELB.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(arn),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(out.TargetHealthDescriptions))
+	for _, d := range out.TargetHealthDescriptions {
+		t := Target{ID: aws.StringValue(d.Target.Id), Port: int(aws.Int64Value(d.Target.Port))}
+		if d.TargetHealth != nil {
+			t.State = aws.StringValue(d.TargetHealth.State)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// Tags returns the tags of the target group
+func (c *Client) Tags(ctx context.Context, arn string) (map[string]string, error) {
+	out, err := c.ELB.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{
+		ResourceArns: []*string{aws.String(arn)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, d := range out.TagDescriptions {
+		for _, tag := range d.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// Register adds the targets to the target group
+func (c *Client) Register(ctx context.Context, arn string, targets []Target) error {
+	_, err := c.ELB.RegisterTargetsWithContext(ctx, &elbv2.RegisterTargetsInput{
+		TargetGroupArn: aws.String(arn),
+		Targets:        targetDescriptions(targets),
+	})
+	return err
+}
+
+// Deregister removes the targets from the target group. They are drained
+// with the deregistration delay of the target group.
+func (c *Client) Deregister(ctx context.Context, arn string, targets []Target) error {
+	_, err := c.ELB.DeregisterTargetsWithContext(ctx, &elbv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(arn),
+		Targets:        targetDescriptions(targets),
+	})
+	return err
+}
+
+func targetDescriptions(targets []Target) []*elbv2.TargetDescription {
+	descriptions := make([]*elbv2.TargetDescription, 0, len(targets))
+	for _, t := range targets {
+		descriptions = append(descriptions, &elbv2.TargetDescription{
+			Id:   aws.String(t.ID),
+			Port: aws.Int64(int64(t.Port)),
+		})
+	}
+	return descriptions
+}
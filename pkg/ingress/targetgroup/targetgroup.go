@@ -0,0 +1,231 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package targetgroup registers the replicas of the controller in the
+// target group of an AWS Network or Application Load Balancer, so the
+// health checks of the target group reach the controller directly.
+package targetgroup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// TargetTypeIP registers the IP addresses of the pods
+	TargetTypeIP = "ip"
+	// TargetTypeInstance registers the EC2 instances of the nodes
+	TargetTypeInstance = "instance"
+
+	// OwnerTag is the tag of the target groups whose targets are all
+	// managed by the controller with the value of the tag
+	OwnerTag = "management-ingress.open-cluster-management.io/owner"
+)
+
+// API is the subset of the Elastic Load Balancing API used to manage the
+// targets
+type API interface {
+	Describe(ctx context.Context, arn string) ([]Target, error)
+	Tags(ctx context.Context, arn string) (map[string]string, error)
+	Register(ctx context.Context, arn string, targets []Target) error
+	Deregister(ctx context.Context, arn string, targets []Target) error
+}
+
+// Config contains the target group of the replicas
+type Config struct {
+	ARN        string
+	TargetType string
+	// Port of the targets, the port where NGINX listens with host
+	// networking or the port of the pods
+	Port int
+	// Owner identifies the controller in the OwnerTag of the target group
+	Owner string
+	API   API
+}
+
+// Registrar keeps the ready replicas registered in the target group
+type Registrar struct {
+	Config
+	client clientset.Interface
+}
+
+// New returns a Registrar reading the nodes of the replicas with client
+func New(cfg Config, client clientset.Interface) (*Registrar, error) {
+	if cfg.TargetType != TargetTypeIP && cfg.TargetType != TargetTypeInstance {
+		return nil, fmt.Errorf("invalid target type %q, expected %v or %v", cfg.TargetType, TargetTypeIP, TargetTypeInstance)
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("invalid target port %v", cfg.Port)
+	}
+	return &Registrar{Config: cfg, client: client}, nil
+}
+
+// instanceID returns the EC2 instance ID of the provider ID of a node, like
+// aws:///us-east-1a/i-0123456789abcdef0
+func instanceID(providerID string) (string, error) {
+	if !strings.HasPrefix(providerID, "aws://") {
+		return "", fmt.Errorf("%q is not an AWS provider ID", providerID)
+	}
+	id := providerID[strings.LastIndex(providerID, "/")+1:]
+	if !strings.HasPrefix(id, "i-") {
+		return "", fmt.Errorf("%q has no EC2 instance ID", providerID)
+	}
+	return id, nil
+}
+
+// isReady returns true if the pod accepts requests and is not terminating
+func isReady(pod *apiv1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// target returns the target of a replica
+func (r *Registrar) target(ctx context.Context, pod *apiv1.Pod) (Target, error) {
+	if r.TargetType == TargetTypeIP {
+		if pod.Status.PodIP == "" {
+			return Target{}, fmt.Errorf("pod %v/%v has no IP address", pod.Namespace, pod.Name)
+		}
+		return Target{ID: pod.Status.PodIP, Port: r.Port}, nil
+	}
+
+	node, err := r.client.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return Target{}, err
+	}
+	id, err := instanceID(node.Spec.ProviderID)
+	if err != nil {
+		return Target{}, fmt.Errorf("node %v: %v", node.Name, err)
+	}
+	return Target{ID: id, Port: r.Port}, nil
+}
+
+// targets returns the targets of the ready replicas and of the other
+// replicas, not ready or terminating
+func (r *Registrar) targets(ctx context.Context, pods []apiv1.Pod) (map[string]Target, map[string]Target) {
+	ready, other := map[string]Target{}, map[string]Target{}
+	for i := range pods {
+		pod := &pods[i]
+		t, err := r.target(ctx, pod)
+		if err != nil {
+			if isReady(pod) {
+				glog.Warningf("unexpected error obtaining the target of pod %v/%v: %v", pod.Namespace, pod.Name, err)
+			}
+			continue
+		}
+		if isReady(pod) {
+			ready[t.key()] = t
+		} else {
+			other[t.key()] = t
+		}
+	}
+	// an instance with a ready replica stays registered
+	for key := range ready {
+		delete(other, key)
+	}
+	return ready, other
+}
+
+// owned returns true if the OwnerTag of the target group is the Owner of
+// the registrar, so all its targets are managed by the controller
+func (r *Registrar) owned(ctx context.Context) (bool, error) {
+	if r.Owner == "" {
+		return false, nil
+	}
+	tags, err := r.API.Tags(ctx, r.ARN)
+	if err != nil {
+		return false, err
+	}
+	return tags[OwnerTag] == r.Owner, nil
+}
+
+// Sync registers the ready replicas in the target group and deregisters the
+// replicas that are not ready or terminating. When the target group is
+// owned by the controller, the other targets, like the replicas that were
+// removed, are also deregistered.
+func (r *Registrar) Sync(ctx context.Context, pods []apiv1.Pod) error {
+	desired, unready := r.targets(ctx, pods)
+
+	registered, err := r.API.Describe(ctx, r.ARN)
+	if err != nil {
+		return err
+	}
+	owned, err := r.owned(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stale []Target
+	for _, t := range registered {
+		if _, ok := desired[t.key()]; ok {
+			delete(desired, t.key())
+			continue
+		}
+		if _, ok := unready[t.key()]; (ok || owned) && t.State != "draining" {
+			stale = append(stale, t)
+		}
+	}
+
+	missing := make([]Target, 0, len(desired))
+	for _, t := range desired {
+		missing = append(missing, t)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].key() < missing[j].key() })
+
+	if len(missing) > 0 {
+		glog.Infof("registering %v in target group %v", keys(missing), r.ARN)
+		if err := r.API.Register(ctx, r.ARN, missing); err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		glog.Infof("deregistering %v from target group %v", keys(stale), r.ARN)
+		if err := r.API.Deregister(ctx, r.ARN, stale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deregister removes a replica from the target group, like the leader when
+// it stops, unless another ready replica of pods has the same target, like
+// an instance running several replicas
+func (r *Registrar) Deregister(ctx context.Context, pod *apiv1.Pod, pods []apiv1.Pod) error {
+	t, err := r.target(ctx, pod)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		other := &pods[i]
+		if other.Name == pod.Name || !isReady(other) {
+			continue
+		}
+		if o, err := r.target(ctx, other); err == nil && o.key() == t.key() {
+			glog.Infof("keeping %v in target group %v with the ready replica %v", t.key(), r.ARN, other.Name)
+			return nil
+		}
+	}
+	glog.Infof("deregistering %v from target group %v", t.key(), r.ARN)
+	return r.API.Deregister(ctx, r.ARN, []Target{t})
+}
+
+func keys(targets []Target) []string {
+	keys := make([]string, 0, len(targets))
+	for _, t := range targets {
+		keys = append(keys, t.key())
+	}
+	return keys
+}
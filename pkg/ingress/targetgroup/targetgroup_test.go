@@ -0,0 +1,247 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package targetgroup
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseARN(t *testing.T) {
+	region, err := ParseARN("arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/hub/73e2d6bc24d8a067")
+	if err != nil || region != "eu-west-1" {
+		t.Errorf("expected the region eu-west-1 but got %q (%v)", region, err)
+	}
+	for _, arn := range []string{"", "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/hub/1",
+		"arn:aws:s3:::bucket", "arn:aws:elasticloadbalancing::123456789012:targetgroup/hub/1"} {
+		if _, err := ParseARN(arn); err == nil {
+			t.Errorf("expected an error parsing %q", arn)
+		}
+	}
+}
+
+// fakeELB records the calls of the Elastic Load Balancing v2 API
+type fakeELB struct {
+	elbv2iface.ELBV2API
+	registered []*elbv2.TargetDescription
+}
+
+func (f *fakeELB) DescribeTargetHealthWithContext(ctx aws.Context, in *elbv2.DescribeTargetHealthInput, opts ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []*elbv2.TargetHealthDescription{{
+		Target:       &elbv2.TargetDescription{Id: aws.String("10.0.0.1"), Port: aws.Int64(443)},
+		TargetHealth: &elbv2.TargetHealth{State: aws.String("healthy")},
+	}}}, nil
+}
+
+func (f *fakeELB) DescribeTagsWithContext(ctx aws.Context, in *elbv2.DescribeTagsInput, opts ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	return &elbv2.DescribeTagsOutput{TagDescriptions: []*elbv2.TagDescription{{
+		ResourceArn: in.ResourceArns[0],
+		Tags:        []*elbv2.Tag{{Key: aws.String(OwnerTag), Value: aws.String("ns/election")}},
+	}}}, nil
+}
+
+func (f *fakeELB) RegisterTargetsWithContext(ctx aws.Context, in *elbv2.RegisterTargetsInput, opts ...request.Option) (*elbv2.RegisterTargetsOutput, error) {
+	f.registered = append(f.registered, in.Targets...)
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (f *fakeELB) DeregisterTargetsWithContext(ctx aws.Context, in *elbv2.DeregisterTargetsInput, opts ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, "not found", nil)
+}
+
+func TestClient(t *testing.T) {
+	elb := &fakeELB{}
+	c := &Client{ELB: elb}
+
+	targets, err := c.Describe(context.TODO(), "arn")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Target{{ID: "10.0.0.1", Port: 443, State: "healthy"}}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected %v but got %v", expected, targets)
+	}
+
+	tags, err := c.Tags(context.TODO(), "arn")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tags[OwnerTag] != "ns/election" {
+		t.Errorf("expected the owner tag but got %v", tags)
+	}
+
+	if err := c.Register(context.TODO(), "arn", []Target{{ID: "10.0.0.2", Port: 443}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(elb.registered) != 1 || aws.StringValue(elb.registered[0].Id) != "10.0.0.2" {
+		t.Errorf("expected the target 10.0.0.2 but got %v", elb.registered)
+	}
+
+	if err := c.Deregister(context.TODO(), "arn", []Target{{ID: "10.0.0.2", Port: 443}}); err == nil {
+		t.Errorf("expected the error of the API")
+	}
+}
+
+type fakeAPI struct {
+	registered   []Target
+	deregistered []Target
+	current      []Target
+	tags         map[string]string
+}
+
+func (f *fakeAPI) Describe(ctx context.Context, arn string) ([]Target, error) {
+	return f.current, nil
+}
+
+func (f *fakeAPI) Tags(ctx context.Context, arn string) (map[string]string, error) {
+	return f.tags, nil
+}
+
+func (f *fakeAPI) Register(ctx context.Context, arn string, targets []Target) error {
+	f.registered = append(f.registered, targets...)
+	return nil
+}
+
+func (f *fakeAPI) Deregister(ctx context.Context, arn string, targets []Target) error {
+	f.deregistered = append(f.deregistered, targets...)
+	return nil
+}
+
+func buildPod(name, ip, node string, ready bool) apiv1.Pod {
+	status := apiv1.ConditionFalse
+	if ready {
+		status = apiv1.ConditionTrue
+	}
+	return apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec:       apiv1.PodSpec{NodeName: node},
+		Status: apiv1.PodStatus{
+			PodIP:      ip,
+			Conditions: []apiv1.PodCondition{{Type: apiv1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestSyncIP(t *testing.T) {
+	terminating := buildPod("c", "10.0.0.3", "node", true)
+	now := metav1.Now()
+	terminating.DeletionTimestamp = &now
+	pods := []apiv1.Pod{
+		buildPod("a", "10.0.0.1", "node", true),
+		buildPod("b", "10.0.0.2", "node", true),
+		terminating,
+		buildPod("d", "10.0.0.4", "node", false),
+	}
+
+	// only the targets of the replicas are deregistered from a target group
+	// not owned by the controller
+	for _, owned := range []bool{false, true} {
+		api := &fakeAPI{current: []Target{
+			{ID: "10.0.0.1", Port: 443, State: "healthy"},
+			{ID: "10.0.0.9", Port: 443, State: "healthy"},
+			{ID: "10.0.0.8", Port: 443, State: "draining"},
+			{ID: "10.0.0.3", Port: 443, State: "healthy"},
+		}}
+		if owned {
+			api.tags = map[string]string{OwnerTag: "ns/election"}
+		}
+		r, err := New(Config{ARN: "arn", TargetType: TargetTypeIP, Port: 443, Owner: "ns/election", API: api}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := r.Sync(context.TODO(), pods); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []Target{{ID: "10.0.0.2", Port: 443}}
+		if !reflect.DeepEqual(api.registered, expected) {
+			t.Errorf("expected to register %v but got %v", expected, api.registered)
+		}
+		sort.Slice(api.deregistered, func(i, j int) bool { return api.deregistered[i].ID < api.deregistered[j].ID })
+		expected = []Target{{ID: "10.0.0.3", Port: 443, State: "healthy"}}
+		if owned {
+			expected = append(expected, Target{ID: "10.0.0.9", Port: 443, State: "healthy"})
+		}
+		if !reflect.DeepEqual(api.deregistered, expected) {
+			t.Errorf("expected to deregister %v with the target group owned %v but got %v", expected, owned, api.deregistered)
+		}
+	}
+}
+
+func TestSyncInstance(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}, Spec: apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789abcdef0"}},
+		&apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}, Spec: apiv1.NodeSpec{ProviderID: "gce://project/zone/node-b"}},
+	)
+	api := &fakeAPI{}
+	r, err := New(Config{ARN: "arn", TargetType: TargetTypeInstance, Port: 443, API: api}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pods := []apiv1.Pod{
+		buildPod("a", "10.0.0.1", "node-a", true),
+		buildPod("b", "10.0.0.2", "node-a", true),
+		buildPod("c", "10.0.0.3", "node-b", true),
+	}
+	if err := r.Sync(context.TODO(), pods); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Target{{ID: "i-0123456789abcdef0", Port: 443}}
+	if !reflect.DeepEqual(api.registered, expected) {
+		t.Errorf("expected to register %v but got %v", expected, api.registered)
+	}
+}
+
+func TestDeregisterInstance(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}, Spec: apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789abcdef0"}},
+		&apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}, Spec: apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0fedcba9876543210"}},
+	)
+	api := &fakeAPI{}
+	r, err := New(Config{ARN: "arn", TargetType: TargetTypeInstance, Port: 443, API: api}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	leader := buildPod("a", "10.0.0.1", "node-a", false)
+	pods := []apiv1.Pod{leader, buildPod("b", "10.0.0.2", "node-a", true), buildPod("c", "10.0.0.3", "node-b", true)}
+	if err := r.Deregister(context.TODO(), &leader, pods); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(api.deregistered) != 0 {
+		t.Errorf("expected to keep the instance with another ready replica but deregistered %v", api.deregistered)
+	}
+
+	pods[1] = buildPod("b", "10.0.0.2", "node-a", false)
+	if err := r.Deregister(context.TODO(), &leader, pods); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Target{{ID: "i-0123456789abcdef0", Port: 443}}
+	if !reflect.DeepEqual(api.deregistered, expected) {
+		t.Errorf("expected to deregister %v but got %v", expected, api.deregistered)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{TargetType: "lambda", Port: 443}, nil); err == nil {
+		t.Errorf("expected an error with an invalid target type")
+	}
+	if _, err := New(Config{TargetType: TargetTypeIP}, nil); err == nil {
+		t.Errorf("expected an error without a port")
+	}
+}
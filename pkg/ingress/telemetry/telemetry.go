@@ -0,0 +1,193 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package telemetry reports the anonymized usage of the features of the
+// controller, when the administrator opts in. A report only contains the
+// names of the annotations and modes in use, and counts rounded to a power
+// of two: never the names of the resources, hosts, paths or addresses.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// SchemaVersion is the version of the reports
+	SchemaVersion = 1
+
+	// firstReportDelay leaves time to the controller to sync the
+	// Ingresses before the first report
+	firstReportDelay = 10 * time.Minute
+	requestTimeout   = 30 * time.Second
+
+	// installationKey is the key of the installation ID in its ConfigMap
+	installationKey = "installation"
+)
+
+// InstallationConfigMap is the name of the ConfigMap, in the namespace of
+// the controller, with the installation ID of the reports
+const InstallationConfigMap = "management-ingress-telemetry"
+
+// Scale contains the size of the configuration, rounded to a power of two
+type Scale struct {
+	Ingresses int `json:"ingresses"`
+	Hosts     int `json:"hosts"`
+	Backends  int `json:"backends"`
+}
+
+// Report is the document sent to the endpoint
+type Report struct {
+	Schema int `json:"schema"`
+	// Installation is an anonymous ID of the cluster, stable between
+	// restarts to count the installations
+	Installation string `json:"installation"`
+	Release      string `json:"release"`
+	// Annotations contains the number of Ingresses using each known
+	// annotation, without the prefix, rounded to a power of two
+	Annotations map[string]int `json:"annotations"`
+	Modes       []string       `json:"modes"`
+	Scale       Scale          `json:"scale"`
+}
+
+// Round returns the power of two nearest to n from above, 0 if n is 0, so
+// the reports do not identify an installation by its exact counts
+func Round(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// LoadInstallationID returns the anonymous ID of the installation, a random
+// value kept in the ConfigMap, created with a new ID if it does not exist.
+// The ID is not derived from the cluster, so it can not be linked to it.
+func LoadInstallationID(ctx context.Context, client clientset.Interface, namespace, name string) (string, error) {
+	cms := client.CoreV1().ConfigMaps(namespace)
+	cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+	if err == nil && cm.Data[installationKey] != "" {
+		return cm.Data[installationKey], nil
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	if err == nil {
+		// the ConfigMap exists without an ID
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[installationKey] = id
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = cms.Create(ctx, &apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string]string{installationKey: id},
+		}, metav1.CreateOptions{})
+	}
+	if apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err) {
+		// another replica created the ID first
+		return LoadInstallationID(ctx, client, namespace, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Anonymize rounds the counts of the report and sorts its modes
+func (r *Report) Anonymize() {
+	for name, count := range r.Annotations {
+		r.Annotations[name] = Round(count)
+	}
+	r.Scale = Scale{
+		Ingresses: Round(r.Scale.Ingresses),
+		Hosts:     Round(r.Scale.Hosts),
+		Backends:  Round(r.Scale.Backends),
+	}
+	sort.Strings(r.Modes)
+}
+
+// Reporter sends the reports to the endpoint
+type Reporter struct {
+	Endpoint string
+	Interval time.Duration
+	// Collect returns the usage of the features
+	Collect    func() Report
+	HTTPClient *http.Client
+}
+
+// Report returns the anonymized report sent to the endpoint
+func (r *Reporter) Report() Report {
+	report := r.Collect()
+	report.Schema = SchemaVersion
+	report.Anonymize()
+	return report
+}
+
+// Run sends a report every interval until ctx is done, the first one after
+// the Ingresses are synced
+func (r *Reporter) Run(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(firstReportDelay):
+	}
+
+	wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.send(ctx); err != nil {
+			glog.Warningf("unexpected error sending the telemetry report: %v", err)
+		}
+	}, r.Interval, 0.1, true)
+}
+
+func (r *Reporter) send(ctx context.Context) error {
+	body, err := json.Marshal(r.Report())
+	if err != nil {
+		return err
+	}
+	glog.V(2).Infof("sending telemetry report to %v: %s", r.Endpoint, body)
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("the telemetry endpoint returned %v", resp.Status)
+	}
+	return nil
+}
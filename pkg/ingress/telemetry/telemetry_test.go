@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRound(t *testing.T) {
+	for n, expected := range map[int]int{0: 0, 1: 1, 2: 2, 3: 4, 4: 4, 5: 8, 100: 128, 1024: 1024, 1025: 2048} {
+		if got := Round(n); got != expected {
+			t.Errorf("expected %v to be rounded to %v but returned %v", n, expected, got)
+		}
+	}
+}
+
+func TestLoadInstallationID(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+	id, err := LoadInstallationID(context.TODO(), client, "ingress", InstallationConfigMap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(id) != 16 {
+		t.Errorf("expected an ID of 16 characters but returned %v", id)
+	}
+
+	// the ID is kept between restarts
+	again, err := LoadInstallationID(context.TODO(), client, "ingress", InstallationConfigMap)
+	if err != nil || again != id {
+		t.Errorf("expected the ID %v to be kept but returned %v %v", id, again, err)
+	}
+
+	other, _ := LoadInstallationID(context.TODO(), testclient.NewSimpleClientset(), "ingress", InstallationConfigMap)
+	if other == id {
+		t.Errorf("expected a random ID per installation")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var received Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	r := &Reporter{
+		Endpoint: server.URL,
+		Collect: func() Report {
+			return Report{
+				Installation: "0123456789abcdef",
+				Release:      "2.8.0",
+				Annotations:  map[string]int{"auth-type": 37, "profile": 1},
+				Modes:        []string{"vip", "update-status"},
+				Scale:        Scale{Ingresses: 37, Hosts: 12, Backends: 40},
+			}
+		},
+	}
+	if err := r.send(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Report{
+		Schema:       SchemaVersion,
+		Installation: "0123456789abcdef",
+		Release:      "2.8.0",
+		Annotations:  map[string]int{"auth-type": 64, "profile": 1},
+		Modes:        []string{"update-status", "vip"},
+		Scale:        Scale{Ingresses: 64, Hosts: 16, Backends: 64},
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %+v but received %+v", expected, received)
+	}
+
+	r.Endpoint = server.URL + "/missing\x7f"
+	if err := r.send(context.Background()); err == nil {
+		t.Errorf("expected an error with an invalid endpoint")
+	}
+}
@@ -18,9 +18,29 @@ import (
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/accesslog"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/breakglass"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/budget"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/cachepolicy"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/canary"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/certrevocation"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/connection"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/customcounters"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/deadline"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/fairness"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/grouprouting"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/locale"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/outlier"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/profile"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/proxy"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/replayprotection"
 	"github.com/stolostron/management-ingress/pkg/ingress/annotations/rewrite"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/servicemesh"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/signedurl"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/surge"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/upstreamidentity"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/websocket"
+	"github.com/stolostron/management-ingress/pkg/ingress/oidcpolicy"
 	"github.com/stolostron/management-ingress/pkg/ingress/resolver"
 	"github.com/stolostron/management-ingress/pkg/ingress/store"
 )
@@ -38,6 +58,8 @@ const (
 	IDToken = "id-token"
 	// AccessToken auth type
 	AccessToken = "access-token"
+	// ServiceAccount auth type
+	ServiceAccount = "service-account"
 )
 
 // StoreLister returns the configured stores for ingresses, services,
@@ -46,6 +68,7 @@ type StoreLister struct {
 	Ingress           store.IngressLister
 	Service           store.ServiceLister
 	Endpoint          store.EndpointLister
+	EndpointSlice     store.EndpointSliceLister
 	Secret            store.SecretLister
 	ConfigMap         store.ConfigMapLister
 	IngressAnnotation store.IngressAnnotationsLister
@@ -81,6 +104,57 @@ type Backend struct {
 	ClientCACert resolver.AuthSSLCert `json:"clientCACert"`
 	// Consistent hashing by NGINX variable
 	UpstreamHashBy string `json:"upstream-hash-by,omitempty"`
+	// HashLoadFactor bounds the requests in progress of each endpoint to
+	// this factor of the average when hashing by UpstreamHashBy. The
+	// requests are sent to the endpoints instead of the ClusterIP.
+	HashLoadFactor float64 `json:"hashLoadFactor,omitempty"`
+	// SlowStart is the time in seconds in which the share of the requests
+	// sent to a new endpoint grows to the share of the other endpoints.
+	// The requests are sent to the endpoints instead of the ClusterIP.
+	SlowStart int `json:"slowStart,omitempty"`
+	// OutlierDetection ejects the endpoints with consecutive failures.
+	// The requests are sent to the endpoints instead of the ClusterIP.
+	OutlierDetection outlier.Config `json:"outlierDetection,omitempty"`
+	// UpstreamIdentity contains the SPIFFE IDs accepted from the endpoints.
+	// The requests are only sent to the endpoints whose ID was verified.
+	UpstreamIdentity upstreamidentity.Config `json:"upstreamIdentity,omitempty"`
+	// SVID has the file names and SHA-256 of the X509-SVID and the bundle of
+	// the controller, presented to the backends with an UpstreamIdentity
+	SVID resolver.AuthSSLCert `json:"svid"`
+	// ServiceMesh is the service mesh of the pods. The requests are sent
+	// in plain HTTP to the ClusterIP, with the headers used by the mesh
+	// to route them, and the endpoints are never balanced by NGINX.
+	ServiceMesh servicemesh.Config `json:"serviceMesh,omitempty"`
+	// IPFamily is the address family of the ClusterIP and the endpoints
+	// the requests are sent to, the primary family of the Service if empty
+	IPFamily string `json:"ipFamily,omitempty"`
+	// Backup is the server used when the endpoints do not accept connections
+	Backup *BackupServer `json:"backup,omitempty"`
+	// UnixSocket is the path of the Unix socket the requests are sent to
+	// instead of a Service
+	UnixSocket string `json:"unixSocket,omitempty"`
+}
+
+// HasAddress returns true if the requests can be sent to the backend
+func (b *Backend) HasAddress() bool {
+	return b.ClusterIP != "" || b.UnixSocket != ""
+}
+
+// BalancesEndpoints returns true if NGINX selects the endpoint of each
+// request instead of sending it to the ClusterIP
+func (b *Backend) BalancesEndpoints() bool {
+	if b.ServiceMesh.Enabled() {
+		return false
+	}
+	return b.HashLoadFactor > 0 || b.SlowStart > 0 || b.OutlierDetection.Enabled || b.UpstreamIdentity.Enabled()
+}
+
+// BackupServer describes the Service used as backup of a Backend
+type BackupServer struct {
+	// Service is the backup service formatted as <namespace>/<name>
+	Service   string `json:"service"`
+	ClusterIP string `json:"clusterIP"`
+	Port      int    `json:"port"`
 }
 
 // Server describes a website
@@ -103,6 +177,9 @@ type Server struct {
 	SSLPemChecksum string `json:"sslPemChecksum"`
 	// Alias return the alias of the server name
 	Alias string `json:"alias,omitempty"`
+	// IPFamily is the only address family the server listens on, both if
+	// empty
+	IPFamily string `json:"ipFamily,omitempty"`
 }
 
 // Location describes an URI inside a server.
@@ -159,4 +236,93 @@ type Location struct {
 	// to be used in connections against endpoints
 	// +optional
 	Proxy proxy.Config `json:"proxy,omitempty"`
+	// Budget contains the latency and response size budgets of the location
+	// +optional
+	Budget budget.Config `json:"budget,omitempty"`
+	// LuaFilters contains the names of the Lua filters run in the location
+	// +optional
+	LuaFilters []string `json:"luaFilters,omitempty"`
+	// CustomCounters contains the counters of requests defined for the location
+	// +optional
+	CustomCounters []customcounters.Counter `json:"customCounters,omitempty"`
+	// AllowedServiceAccounts contains the ServiceAccounts allowed in the
+	// location when AuthType is service-account
+	// +optional
+	AllowedServiceAccounts []string `json:"allowedServiceAccounts,omitempty"`
+	// TLSHeaders contains the values of the TLS connection sent to the backend
+	// in request headers
+	// +optional
+	TLSHeaders []string `json:"tlsHeaders,omitempty"`
+	// Websocket contains the limits of concurrent websocket sessions
+	// +optional
+	Websocket websocket.Config `json:"websocket,omitempty"`
+	// CostTag contains the sources of the cost attribution tag of the
+	// requests, tried in order
+	// +optional
+	CostTag []string `json:"costTag,omitempty"`
+	// DisableCompression disables the compression of the responses
+	// +optional
+	DisableCompression bool `json:"disableCompression,omitempty"`
+	// CachePolicy contains the cache headers of the responses
+	// +optional
+	CachePolicy cachepolicy.Config `json:"cachePolicy,omitempty"`
+	// SignedURL contains the keys used to validate the signed URLs
+	// required in the location
+	// +optional
+	SignedURL signedurl.Config `json:"signedURL,omitempty"`
+	// ReplayProtection contains the keys used to validate the signed
+	// webhooks received in the location and their window
+	// +optional
+	ReplayProtection replayprotection.Config `json:"replayProtection,omitempty"`
+	// Profile contains the settings of the predefined profile of the
+	// location, like upload
+	// +optional
+	Profile profile.Config `json:"profile,omitempty"`
+	// SurgeProtection limits the requests sent to the backend while most
+	// of its pods are not ready
+	// +optional
+	SurgeProtection surge.Config `json:"surgeProtection,omitempty"`
+	// Fairness limits the requests in progress of each client
+	// +optional
+	Fairness fairness.Config `json:"fairness,omitempty"`
+	// Deadline sends the remaining time of the requests to the backend
+	// +optional
+	Deadline deadline.Config `json:"deadline,omitempty"`
+	// ClientCertRevocation checks the revocation of the client
+	// certificates
+	// +optional
+	ClientCertRevocation certrevocation.Config `json:"clientCertRevocation,omitempty"`
+	// AccessLog sends the access log of the location to another
+	// destination and logs only a sample of its requests
+	// +optional
+	AccessLog accesslog.Config `json:"accessLog,omitempty"`
+	// Locale routes the requests to a variant of the backend by the
+	// value of a header
+	// +optional
+	Locale locale.Config `json:"locale,omitempty"`
+	// Canary sends a share of the requests to the canary backend of the
+	// Ingress
+	// +optional
+	Canary canary.Config `json:"canary,omitempty"`
+	// GroupRouting sends the requests to a Service, or rejects them, by the
+	// groups of the authenticated caller
+	// +optional
+	GroupRouting grouprouting.Config `json:"groupRouting,omitempty"`
+	// BreakGlass is the alternate authentication accepted while the OIDC
+	// issuer is unreachable
+	// +optional
+	BreakGlass breakglass.Config `json:"breakGlass,omitempty"`
+	// OIDCPolicies are the rules of the OIDCRoutePolicies the tokens of
+	// the requests must satisfy, the most specific first
+	// +optional
+	OIDCPolicies []oidcpolicy.Rule `json:"oidcPolicies,omitempty"`
+	// Plugins contains the data of the annotation plugins, by plugin name,
+	// like $location.Plugins.<name> in the template
+	// +optional
+	Plugins map[string]interface{} `json:"plugins,omitempty"`
+	// FailedPlugins are the annotation plugins that failed without a
+	// previous result for the Ingress. The requests are rejected with 503
+	// while it is not empty.
+	// +optional
+	FailedPlugins []string `json:"failedPlugins,omitempty"`
 }
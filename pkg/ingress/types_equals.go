@@ -16,6 +16,8 @@ limitations under the License.
 
 package ingress
 
+import "reflect"
+
 // Equal tests for equality between two Configuration types
 func (c1 *Configuration) Equal(c2 *Configuration) bool {
 	if c1 == c2 {
@@ -98,6 +100,36 @@ func (b1 *Backend) Equal(b2 *Backend) bool {
 	if b1.UpstreamHashBy != b2.UpstreamHashBy {
 		return false
 	}
+	if b1.HashLoadFactor != b2.HashLoadFactor {
+		return false
+	}
+	if b1.SlowStart != b2.SlowStart {
+		return false
+	}
+	if !(&b1.OutlierDetection).Equal(&b2.OutlierDetection) {
+		return false
+	}
+	if !(&b1.UpstreamIdentity).Equal(&b2.UpstreamIdentity) {
+		return false
+	}
+	if !(&b1.SVID).Equal(&b2.SVID) {
+		return false
+	}
+	if !(&b1.ServiceMesh).Equal(&b2.ServiceMesh) {
+		return false
+	}
+	if b1.IPFamily != b2.IPFamily {
+		return false
+	}
+	if (b1.Backup == nil) != (b2.Backup == nil) {
+		return false
+	}
+	if b1.Backup != nil && *b1.Backup != *b2.Backup {
+		return false
+	}
+	if b1.UnixSocket != b2.UnixSocket {
+		return false
+	}
 	if b1.ClusterIP != b2.ClusterIP {
 		return false
 	}
@@ -119,6 +151,9 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.Alias != s2.Alias {
 		return false
 	}
+	if s1.IPFamily != s2.IPFamily {
+		return false
+	}
 	if s1.SSLCertificate != s2.SSLCertificate {
 		return false
 	}
@@ -185,6 +220,9 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if l1.XForwardedPrefix != l2.XForwardedPrefix {
 		return false
 	}
+	if l1.DisableCompression != l2.DisableCompression {
+		return false
+	}
 	if l1.AuthType != l2.AuthType {
 		return false
 	}
@@ -203,6 +241,105 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if !(&l1.Connection).Equal(&l2.Connection) {
 		return false
 	}
+	if !(&l1.Budget).Equal(&l2.Budget) {
+		return false
+	}
+	if !(&l1.Websocket).Equal(&l2.Websocket) {
+		return false
+	}
+	if !(&l1.CachePolicy).Equal(&l2.CachePolicy) {
+		return false
+	}
+	if !(&l1.SignedURL).Equal(&l2.SignedURL) {
+		return false
+	}
+	if !(&l1.ReplayProtection).Equal(&l2.ReplayProtection) {
+		return false
+	}
+	if !(&l1.Profile).Equal(&l2.Profile) {
+		return false
+	}
+	if !(&l1.SurgeProtection).Equal(&l2.SurgeProtection) {
+		return false
+	}
+	if !(&l1.Fairness).Equal(&l2.Fairness) {
+		return false
+	}
+	if !(&l1.Deadline).Equal(&l2.Deadline) {
+		return false
+	}
+	if !(&l1.ClientCertRevocation).Equal(&l2.ClientCertRevocation) {
+		return false
+	}
+	if !(&l1.AccessLog).Equal(&l2.AccessLog) {
+		return false
+	}
+	if !(&l1.Locale).Equal(&l2.Locale) {
+		return false
+	}
+	if !(&l1.Canary).Equal(&l2.Canary) {
+		return false
+	}
+	if !(&l1.GroupRouting).Equal(&l2.GroupRouting) {
+		return false
+	}
+	if !(&l1.BreakGlass).Equal(&l2.BreakGlass) {
+		return false
+	}
+	if len(l1.OIDCPolicies) != len(l2.OIDCPolicies) {
+		return false
+	}
+	for i := range l1.OIDCPolicies {
+		if !(&l1.OIDCPolicies[i]).Equal(&l2.OIDCPolicies[i]) {
+			return false
+		}
+	}
+	if !reflect.DeepEqual(l1.Plugins, l2.Plugins) {
+		return false
+	}
+	if !reflect.DeepEqual(l1.FailedPlugins, l2.FailedPlugins) {
+		return false
+	}
+	if len(l1.LuaFilters) != len(l2.LuaFilters) {
+		return false
+	}
+	for i := range l1.LuaFilters {
+		if l1.LuaFilters[i] != l2.LuaFilters[i] {
+			return false
+		}
+	}
+	if len(l1.AllowedServiceAccounts) != len(l2.AllowedServiceAccounts) {
+		return false
+	}
+	for i := range l1.AllowedServiceAccounts {
+		if l1.AllowedServiceAccounts[i] != l2.AllowedServiceAccounts[i] {
+			return false
+		}
+	}
+	if len(l1.CostTag) != len(l2.CostTag) {
+		return false
+	}
+	for i := range l1.CostTag {
+		if l1.CostTag[i] != l2.CostTag[i] {
+			return false
+		}
+	}
+	if len(l1.TLSHeaders) != len(l2.TLSHeaders) {
+		return false
+	}
+	for i := range l1.TLSHeaders {
+		if l1.TLSHeaders[i] != l2.TLSHeaders[i] {
+			return false
+		}
+	}
+	if len(l1.CustomCounters) != len(l2.CustomCounters) {
+		return false
+	}
+	for i := range l1.CustomCounters {
+		if l1.CustomCounters[i] != l2.CustomCounters[i] {
+			return false
+		}
+	}
 
 	return true
 }
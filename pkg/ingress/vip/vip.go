@@ -0,0 +1,223 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package vip announces a virtual IP address from one replica of the
+// controller, elected among the replicas with a healthy data plane. The
+// announcement, with gratuitous ARP or BGP, is made by an agent of the
+// node, like MetalLB or a BGP speaker, through its HTTP API.
+//
+// The VIP has its own election instead of being a leader task of the
+// status election: only the replicas with a healthy data plane take part
+// in it, and its leader releases the leadership when NGINX fails, while
+// the status leader keeps it as long as the controller runs.
+package vip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/status"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	requestTimeout       = 5 * time.Second
+)
+
+// Config contains the VIP and the agent announcing it
+type Config struct {
+	// Address is the VIP
+	Address string
+	// AgentURL is the base URL of the API of the agent of the node
+	AgentURL string
+	// Node is the node of the replica, sent to the agent
+	Node string
+	// Interval between the checks of the data plane. The VIP is announced
+	// again at every check, so a restarted agent announces it too
+	Interval time.Duration
+	// Healthy returns an error when the data plane of the replica can't
+	// serve the requests sent to the VIP
+	Healthy func(ctx context.Context) error
+
+	Client clientset.Interface
+	// LockType is the resource of the election, one of the LockTypes of
+	// the status election. status.DefaultLockType if empty
+	LockType string
+	// LockName is the name of the lock of the election, in Namespace
+	LockName  string
+	Namespace string
+	// Identity of the replica in the election
+	Identity string
+	// LeaseDuration of the election, 15s if zero. A VIP whose leader
+	// died is announced by another replica after it.
+	LeaseDuration time.Duration
+
+	HTTPClient *http.Client
+}
+
+// Announcer announces the VIP when the replica is the leader
+type Announcer struct {
+	Config
+}
+
+// New returns an Announcer of the VIP
+func New(cfg Config) *Announcer {
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.LockType == "" {
+		cfg.LockType = status.DefaultLockType
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: requestTimeout}
+	}
+	return &Announcer{Config: cfg}
+}
+
+// Run takes part in the election while the data plane is healthy, until
+// ctx is done. The leader announces the VIP and, when its data plane
+// fails, withdraws it and releases the leadership to another replica.
+func (a *Announcer) Run(ctx context.Context) {
+	for {
+		err := wait.PollImmediateUntil(a.Interval, func() (bool, error) {
+			if err := a.Healthy(ctx); err != nil {
+				glog.V(2).Infof("not taking part in the election of VIP %v: %v", a.Address, err)
+				return false, nil
+			}
+			return true, nil
+		}, ctx.Done())
+		if err != nil {
+			return
+		}
+
+		a.campaign(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// campaign runs the election until the leadership is lost or released,
+// which the election of the status can't do for a failing data plane
+func (a *Announcer) campaign(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lock, err := status.NewResourceLock(a.LockType, metav1.ObjectMeta{Namespace: a.Namespace, Name: a.LockName},
+		a.Client, resourcelock.ResourceLockConfig{Identity: a.Identity})
+	if err != nil {
+		glog.Errorf("unexpected error creating the lock of VIP %v: %v", a.Address, err)
+		return
+	}
+	// the VIP is only withdrawn by a replica that announced it, once it
+	// stopped announcing it
+	var mu sync.Mutex
+	var led chan struct{}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   a.LeaseDuration,
+		RenewDeadline:   a.LeaseDuration * 2 / 3,
+		RetryPeriod:     a.LeaseDuration / 5,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				done := make(chan struct{})
+				mu.Lock()
+				led = done
+				mu.Unlock()
+				defer close(done)
+
+				glog.Infof("announcing VIP %v", a.Address)
+				a.lead(ctx, cancel)
+			},
+			OnStoppedLeading: func() {
+				mu.Lock()
+				done := led
+				mu.Unlock()
+				if done == nil {
+					return
+				}
+				<-done
+
+				glog.Infof("withdrawing VIP %v", a.Address)
+				if err := a.withdraw(context.Background()); err != nil {
+					glog.Errorf("unexpected error withdrawing VIP %v: %v", a.Address, err)
+				}
+			},
+		},
+	})
+	if err != nil {
+		glog.Errorf("unexpected error starting the election of VIP %v: %v", a.Address, err)
+		return
+	}
+	le.Run(ctx)
+}
+
+// lead announces the VIP until ctx is done or the data plane fails, then
+// calls release
+func (a *Announcer) lead(ctx context.Context, release func()) {
+	wait.JitterUntil(func() {
+		if err := a.Healthy(ctx); err != nil {
+			glog.Warningf("releasing VIP %v, the data plane is not healthy: %v", a.Address, err)
+			release()
+			return
+		}
+		if err := a.announce(ctx); err != nil {
+			glog.Errorf("unexpected error announcing VIP %v: %v", a.Address, err)
+		}
+	}, a.Interval, 0, true, ctx.Done())
+}
+
+// request is the body sent to the agent
+type request struct {
+	Address string `json:"address"`
+	Node    string `json:"node"`
+}
+
+func (a *Announcer) announce(ctx context.Context) error {
+	return a.call(ctx, http.MethodPut)
+}
+
+func (a *Announcer) withdraw(ctx context.Context) error {
+	return a.call(ctx, http.MethodDelete)
+}
+
+// call sends the VIP to the agent: PUT to announce it and DELETE to
+// withdraw it, in <agent>/v1/vips/<address>
+func (a *Announcer) call(ctx context.Context, method string) error {
+	body, err := json.Marshal(request{Address: a.Address, Node: a.Node})
+	if err != nil {
+		return err
+	}
+	u := strings.TrimSuffix(a.AgentURL, "/") + "/v1/vips/" + url.PathEscape(a.Address)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		return fmt.Errorf("the agent returned %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package vip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// agent records the node announcing the VIP
+type agent struct {
+	mu       sync.Mutex
+	node     string
+	withdraw []string
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.URL.Path != "/v1/vips/192.0.2.10" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		a.node = req.Node
+	case http.MethodDelete:
+		a.withdraw = append(a.withdraw, req.Node)
+		if a.node == req.Node {
+			a.node = ""
+		}
+	}
+}
+
+func (a *agent) announcedBy() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.node
+}
+
+func TestAnnouncer(t *testing.T) {
+	ag := &agent{}
+	server := httptest.NewServer(ag)
+	defer server.Close()
+
+	client := fake.NewSimpleClientset()
+	health := map[string]*atomic.Value{}
+	newAnnouncer := func(node string) *Announcer {
+		health[node] = &atomic.Value{}
+		health[node].Store(true)
+		return New(Config{
+			Address:  "192.0.2.10",
+			AgentURL: server.URL + "/",
+			Node:     node,
+			Interval: 50 * time.Millisecond,
+			Healthy: func(ctx context.Context) error {
+				if !health[node].Load().(bool) {
+					return fmt.Errorf("nginx is not running")
+				}
+				return nil
+			},
+			Client:        client,
+			LockName:      "ingress-controller-leader-vip",
+			Namespace:     "ns",
+			Identity:      node,
+			LeaseDuration: time.Second,
+		})
+	}
+	a1, a2 := newAnnouncer("node-1"), newAnnouncer("node-2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go a1.Run(ctx)
+	if err := wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return ag.announcedBy() == "node-1", nil
+	}); err != nil {
+		t.Fatalf("expected the VIP to be announced by node-1")
+	}
+
+	go a2.Run(ctx)
+	time.Sleep(500 * time.Millisecond)
+	if n := ag.announcedBy(); n != "node-1" {
+		t.Fatalf("expected the VIP to stay on node-1 but got %q", n)
+	}
+
+	health["node-1"].Store(false)
+	if err := wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return ag.announcedBy() == "node-2", nil
+	}); err != nil {
+		t.Fatalf("expected the VIP to move to node-2 when the data plane of node-1 fails")
+	}
+
+	ag.mu.Lock()
+	withdraw := ag.withdraw
+	ag.mu.Unlock()
+	if len(withdraw) != 1 || withdraw[0] != "node-1" {
+		t.Errorf("expected only node-1 to withdraw the VIP but got %v", withdraw)
+	}
+}
@@ -21,6 +21,7 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -42,30 +43,57 @@ func ParseNameNS(input string) (string, string, error) {
 
 // GetNodeIPOrName returns the IP address or the name of a node in the cluster
 func GetNodeIPOrName(kubeClient clientset.Interface, name string, useInternalIP bool) string {
-	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
-	if err != nil {
+	ips := GetNodeIPs(kubeClient, name, useInternalIP)
+	if len(ips) == 0 {
 		return ""
 	}
+	return ips[0]
+}
 
+// GetNodeIPs returns the internal or external IP addresses of a node in the
+// cluster, an IPv4 and an IPv6 one in the dual-stack clusters
+func GetNodeIPs(kubeClient clientset.Interface, name string, useInternalIP bool) []string {
 	if useInternalIP {
+		return GetNodeAddresses(kubeClient, name, []apiv1.NodeAddressType{apiv1.NodeInternalIP})
+	}
+	return GetNodeAddresses(kubeClient, name, []apiv1.NodeAddressType{apiv1.NodeExternalIP})
+}
+
+// GetNodeAddresses returns the addresses of a node in the cluster of the
+// types, ordered by the position of their type in types
+func GetNodeAddresses(kubeClient clientset.Interface, name string, types []apiv1.NodeAddressType) []string {
+	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+
+	addrs := []string{}
+	for _, addressType := range types {
 		for _, address := range node.Status.Addresses {
-			if address.Type == apiv1.NodeInternalIP {
-				if address.Address != "" {
-					return address.Address
-				}
+			if address.Type == addressType && address.Address != "" {
+				addrs = append(addrs, address.Address)
 			}
 		}
-	} else {
-		for _, address := range node.Status.Addresses {
-			if address.Type == apiv1.NodeExternalIP {
-				if address.Address != "" {
-					return address.Address
-				}
-			}
+	}
+
+	return addrs
+}
+
+// GetNodeHostname returns the hostname of a node in the cluster, or its
+// name if the node has no hostname address
+func GetNodeHostname(kubeClient clientset.Interface, name string) string {
+	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+
+	for _, address := range node.Status.Addresses {
+		if address.Type == apiv1.NodeHostName && address.Address != "" {
+			return address.Address
 		}
 	}
 
-	return ""
+	return node.Name
 }
 
 // PodInfo contains runtime information about the pod running the Ingres controller
@@ -78,14 +106,23 @@ type PodInfo struct {
 	Labels map[string]string
 }
 
+// ErrNotInPod is returned by GetPodDetails when the controller does not
+// run in a pod, like out of the cluster with a kubeconfig
+var ErrNotInPod = errors.New("unable to get POD information (missing POD_NAME or POD_NAMESPACE environment variable)")
+
+// IsRunningInPod returns true if the controller knows the pod it runs in
+func IsRunningInPod() bool {
+	return os.Getenv("POD_NAME") != "" && os.Getenv("POD_NAMESPACE") != ""
+}
+
 // GetPodDetails returns runtime information about the pod:
 // name, namespace and IP of the node where it is running
 func GetPodDetails(kubeClient clientset.Interface) (*PodInfo, error) {
 	podName := os.Getenv("POD_NAME")
 	podNs := os.Getenv("POD_NAMESPACE")
 
-	if podName == "" || podNs == "" {
-		return nil, fmt.Errorf("unable to get POD information (missing POD_NAME or POD_NAMESPACE environment variable")
+	if !IsRunningInPod() {
+		return nil, ErrNotInPod
 	}
 
 	pod, _ := kubeClient.CoreV1().Pods(podNs).Get(context.TODO(), podName, metav1.GetOptions{})
@@ -171,13 +171,40 @@ func TestGetNodeIP(t *testing.T) {
 	}
 }
 
+func TestGetNodeHostname(t *testing.T) {
+	cs := testclient.NewSimpleClientset(&apiv1.NodeList{Items: []apiv1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+			Status: apiv1.NodeStatus{
+				Addresses: []apiv1.NodeAddress{
+					{Type: apiv1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: apiv1.NodeHostName, Address: "demo.example.com"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		},
+	}})
+
+	for name, expected := range map[string]string{
+		"demo":    "demo.example.com",
+		"other":   "other",
+		"missing": "",
+	} {
+		if hostname := GetNodeHostname(cs, name); hostname != expected {
+			t.Errorf("expected %q for %v, but returned %q", expected, name, hostname)
+		}
+	}
+}
+
 func TestGetPodDetails(t *testing.T) {
 	// POD_NAME & POD_NAMESPACE not exist
 	os.Setenv("POD_NAME", "")
 	os.Setenv("POD_NAMESPACE", "")
 	_, err1 := GetPodDetails(testclient.NewSimpleClientset())
-	if err1 == nil {
-		t.Errorf("expected an error but returned nil")
+	if err1 != ErrNotInPod {
+		t.Errorf("expected %v but returned %v", ErrNotInPod, err1)
 	}
 
 	// POD_NAME not exist
@@ -200,7 +227,7 @@ func TestGetPodDetails(t *testing.T) {
 	os.Setenv("POD_NAME", "testpod")
 	os.Setenv("POD_NAMESPACE", apiv1.NamespaceDefault)
 	_, err4 := GetPodDetails(testclient.NewSimpleClientset())
-	if err4 == nil {
+	if err4 == nil || err4 == ErrNotInPod {
 		t.Errorf("expected an error but returned nil")
 	}
 
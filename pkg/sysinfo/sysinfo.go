@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package sysinfo
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+var (
+	// CgroupRoot is the mount point of the cgroup filesystem
+	CgroupRoot = "/sys/fs/cgroup"
+)
+
+// unlimited is the value reported by cgroup v1 when no memory limit is set
+// (page counter max rounded down to a page boundary)
+const unlimited = int64(math.MaxInt64 / 4096 * 4096)
+
+// NumCPU returns the number of CPUs available to the process. When the
+// process runs inside a cgroup with a CPU quota the quota is used, rounded
+// up to the next integer. Otherwise runtime.NumCPU() is returned.
+func NumCPU() int {
+	n := runtime.NumCPU()
+
+	quota, period, ok := cpuQuota()
+	if !ok || quota <= 0 || period <= 0 {
+		return n
+	}
+
+	cpus := int(math.Ceil(float64(quota) / float64(period)))
+	if cpus < 1 {
+		cpus = 1
+	}
+	if cpus > n {
+		cpus = n
+	}
+
+	glog.V(2).Infof("cgroup CPU quota %v/%v (%v CPUs)", quota, period, cpus)
+	return cpus
+}
+
+// MemoryLimit returns the memory limit, in bytes, of the cgroup the process
+// runs in. Zero means there is no limit or it cannot be determined.
+func MemoryLimit() int64 {
+	// cgroup v2
+	if v, err := readString("memory.max"); err == nil {
+		if v == "max" {
+			return 0
+		}
+		return parseLimit(v)
+	}
+
+	// cgroup v1
+	if v, err := readString("memory/memory.limit_in_bytes"); err == nil {
+		return parseLimit(v)
+	}
+
+	return 0
+}
+
+// CacheLineSize returns the size in bytes of the L1 cache line of the
+// architecture the binary was compiled for
+func CacheLineSize() int {
+	switch runtime.GOARCH {
+	case "ppc64", "ppc64le":
+		return 128
+	case "s390x":
+		return 256
+	default:
+		return 64
+	}
+}
+
+// cpuQuota returns the CPU quota and period in microseconds
+func cpuQuota() (int64, int64, bool) {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if v, err := readString("cpu.max"); err == nil {
+		fields := strings.Fields(v)
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, 0, false
+		}
+		quota, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		period, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		return quota, period, true
+	}
+
+	// cgroup v1
+	q, err := readString("cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, 0, false
+	}
+	p, err := readString("cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, 0, false
+	}
+	quota, err := strconv.ParseInt(q, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	period, err := strconv.ParseInt(p, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return quota, period, true
+}
+
+func parseLimit(v string) int64 {
+	l, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || l <= 0 || l >= unlimited {
+		return 0
+	}
+	return l
+}
+
+func readString(name string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(CgroupRoot, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package sysinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func withCgroupFiles(t *testing.T, files map[string]string) func() {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	old := CgroupRoot
+	CgroupRoot = dir
+	return func() {
+		CgroupRoot = old
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNumCPU(t *testing.T) {
+	testCases := []struct {
+		files    map[string]string
+		expected int
+	}{
+		{map[string]string{"cpu.max": "150000 100000\n"}, 2},
+		{map[string]string{"cpu.max": "50000 100000\n"}, 1},
+		{map[string]string{"cpu.max": "max 100000\n"}, runtime.NumCPU()},
+		{map[string]string{"cpu/cpu.cfs_quota_us": "100000", "cpu/cpu.cfs_period_us": "100000"}, 1},
+		{map[string]string{"cpu/cpu.cfs_quota_us": "-1", "cpu/cpu.cfs_period_us": "100000"}, runtime.NumCPU()},
+		{map[string]string{}, runtime.NumCPU()},
+	}
+
+	for _, tc := range testCases {
+		cleanup := withCgroupFiles(t, tc.files)
+		expected := tc.expected
+		if expected > runtime.NumCPU() {
+			expected = runtime.NumCPU()
+		}
+		if n := NumCPU(); n != expected {
+			t.Errorf("returned %v but expected %v for %v", n, expected, tc.files)
+		}
+		cleanup()
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	testCases := []struct {
+		files    map[string]string
+		expected int64
+	}{
+		{map[string]string{"memory.max": "536870912\n"}, 536870912},
+		{map[string]string{"memory.max": "max\n"}, 0},
+		{map[string]string{"memory/memory.limit_in_bytes": "268435456"}, 268435456},
+		{map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712"}, 0},
+		{map[string]string{}, 0},
+	}
+
+	for _, tc := range testCases {
+		cleanup := withCgroupFiles(t, tc.files)
+		if m := MemoryLimit(); m != tc.expected {
+			t.Errorf("returned %v but expected %v for %v", m, tc.expected, tc.files)
+		}
+		cleanup()
+	}
+}
@@ -18,6 +18,7 @@ package task
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -25,6 +26,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
 )
 
 var (
@@ -35,6 +38,9 @@ var (
 // given sync function for every work item inserted.
 // The queue uses an internal timestamp that allows the removal of certain elements
 // which timestamp is older than the last successful get operation.
+// Items with the same key are merged while they wait to be processed. When the
+// queue is bounded, new keys are dropped once the limit is reached: every sync
+// regenerates the whole model so the pending items already cover the change.
 type Queue struct {
 	// queue is the work queue the worker polls
 	queue workqueue.RateLimitingInterface
@@ -46,6 +52,15 @@ type Queue struct {
 	fn func(obj interface{}) (interface{}, error)
 
 	lastSync int64
+
+	// name identifies the queue in the metrics
+	name string
+	// maxLen is the maximum number of pending keys. Zero means unbounded
+	maxLen int
+
+	mu sync.Mutex
+	// pending contains the timestamp of the last enqueue of every waiting key
+	pending map[interface{}]int64
 }
 
 // Element represents one item of the queue
@@ -73,10 +88,56 @@ func (t *Queue) Enqueue(obj interface{}) {
 		glog.Errorf("%v", err)
 		return
 	}
-	t.queue.Add(Element{
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pending[key]; ok {
+		t.pending[key] = ts
+		metric.IncQueueEvent(t.name, "merged")
+		return
+	}
+
+	if t.maxLen > 0 && len(t.pending) >= t.maxLen {
+		glog.V(3).Infof("queue %v is full, dropping %v", t.name, key)
+		metric.IncQueueEvent(t.name, "dropped")
+		return
+	}
+
+	t.pending[key] = ts
+	metric.IncQueueEvent(t.name, "queued")
+	metric.SetQueueDepth(t.name, len(t.pending))
+	t.queue.Add(key)
+}
+
+// pop returns the element for the given key and removes it from the pending keys
+func (t *Queue) pop(key interface{}) Element {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ts, ok := t.pending[key]
+	if !ok {
+		ts = time.Now().UnixNano()
+	}
+	delete(t.pending, key)
+	metric.SetQueueDepth(t.name, len(t.pending))
+
+	return Element{
 		Key:       key,
 		Timestamp: ts,
-	})
+	}
+}
+
+// requeue adds the key back to the queue after a failed sync
+func (t *Queue) requeue(key interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pending[key]; !ok {
+		t.pending[key] = time.Now().UnixNano()
+		metric.SetQueueDepth(t.name, len(t.pending))
+	}
+	t.queue.AddRateLimited(key)
 }
 
 func (t *Queue) defaultKeyFunc(obj interface{}) (interface{}, error) {
@@ -100,7 +161,7 @@ func (t *Queue) worker() {
 		}
 		ts := time.Now().UnixNano()
 
-		item := key.(Element)
+		item := t.pop(key)
 		if t.lastSync > item.Timestamp {
 			glog.V(3).Infof("skipping %v sync (%v > %v)", item.Key, t.lastSync, item.Timestamp)
 			t.queue.Forget(key)
@@ -109,12 +170,9 @@ func (t *Queue) worker() {
 		}
 
 		glog.V(3).Infof("syncing %v", item.Key)
-		if err := t.sync(key); err != nil {
+		if err := t.sync(item); err != nil {
 			glog.Warningf("requeuing %v, err %v", item.Key, err)
-			t.queue.AddRateLimited(Element{
-				Key:       item.Key,
-				Timestamp: time.Now().UnixNano(),
-			})
+			t.requeue(key)
 		} else {
 			t.queue.Forget(key)
 			t.lastSync = ts
@@ -153,11 +211,20 @@ func NewTaskQueue(syncFn func(interface{}) error) *Queue {
 
 // NewCustomTaskQueue ...
 func NewCustomTaskQueue(syncFn func(interface{}) error, fn func(interface{}) (interface{}, error)) *Queue {
+	return NewBoundedTaskQueue("", 0, syncFn, fn)
+}
+
+// NewBoundedTaskQueue creates a task queue that keeps at most maxLen pending keys.
+// The name is used as label in the queue metrics.
+func NewBoundedTaskQueue(name string, maxLen int, syncFn func(interface{}) error, fn func(interface{}) (interface{}, error)) *Queue {
 	q := &Queue{
 		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
 		sync:       syncFn,
 		workerDone: make(chan bool),
 		fn:         fn,
+		name:       name,
+		maxLen:     maxLen,
+		pending:    map[interface{}]int64{},
 	}
 
 	if fn == nil {
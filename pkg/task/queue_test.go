@@ -157,3 +157,37 @@ func TestSkipEnqueue(t *testing.T) {
 	// shutdown queue before exit
 	q.Shutdown()
 }
+
+func mockIdentityKeyFn(obj interface{}) (interface{}, error) {
+	return obj, nil
+}
+
+func TestBoundedEnqueue(t *testing.T) {
+	// initialize result
+	atomic.StoreUint32(&sr, 0)
+	q := NewBoundedTaskQueue("test", 2, mockSynFn, mockIdentityKeyFn)
+	// enqueue before running the worker so every key is pending
+	q.Enqueue("a")
+	q.Enqueue("b")
+	q.Enqueue("a")
+	q.Enqueue("c")
+	q.Enqueue("d")
+
+	// "a" is merged and "c" and "d" are dropped
+	if len(q.pending) != 2 {
+		t.Errorf("pending should contain 2 keys, but contains %d", len(q.pending))
+	}
+
+	stopCh := make(chan struct{})
+	// run queue
+	go q.Run(time.Second, stopCh)
+	// wait for 'mockSynFn'
+	time.Sleep(time.Millisecond * 10)
+	// "b" is older than the sync of "a" so it is skipped
+	if atomic.LoadUint32(&sr) != 1 {
+		t.Errorf("sr should be 1, but is %d", sr)
+	}
+
+	// shutdown queue before exit
+	q.Shutdown()
+}
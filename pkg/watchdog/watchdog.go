@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package watchdog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/metric"
+)
+
+const (
+	// HeapResource identifies growth of the heap in use
+	HeapResource = "heap"
+	// GoroutineResource identifies growth of the number of goroutines
+	GoroutineResource = "goroutines"
+)
+
+// Config configures the leak detector
+type Config struct {
+	// Interval between samples
+	Interval time.Duration
+	// Window is the number of consecutive samples that must grow before a leak is reported
+	Window int
+	// Threshold is the minimum relative growth (0.5 = 50%) between the first
+	// and the last sample of the window
+	Threshold float64
+	// ProfileDir is the directory where heap and goroutine profiles are written
+	ProfileDir string
+	// Notify is called when a leak is suspected
+	Notify func(resource, message string)
+}
+
+// Watchdog periodically samples the heap and goroutine counts and reports
+// monotonic growth beyond the configured threshold
+type Watchdog struct {
+	Config
+
+	heap       []uint64
+	goroutines []uint64
+}
+
+// New returns a new leak detector
+func New(cfg Config) *Watchdog {
+	if cfg.Window < 2 {
+		cfg.Window = 2
+	}
+
+	return &Watchdog{Config: cfg}
+}
+
+// Run samples the process until stopCh is closed
+func (w *Watchdog) Run(stopCh <-chan struct{}) {
+	glog.Infof("starting leak detector (interval=%v window=%v threshold=%v)", w.Interval, w.Window, w.Threshold)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			w.sample()
+		}
+	}
+}
+
+func (w *Watchdog) sample() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	w.heap = appendSample(w.heap, ms.HeapInuse, w.Window)
+	w.goroutines = appendSample(w.goroutines, uint64(runtime.NumGoroutine()), w.Window)
+
+	if isGrowing(w.heap, w.Window, w.Threshold) {
+		w.report(HeapResource, w.heap)
+		w.heap = nil
+	}
+
+	if isGrowing(w.goroutines, w.Window, w.Threshold) {
+		w.report(GoroutineResource, w.goroutines)
+		w.goroutines = nil
+	}
+}
+
+func (w *Watchdog) report(resource string, samples []uint64) {
+	msg := fmt.Sprintf("possible %v leak: grew from %v to %v in %v samples",
+		resource, samples[0], samples[len(samples)-1], len(samples))
+	glog.Warning(msg)
+
+	metric.IncLeakSuspected(resource)
+
+	if w.ProfileDir != "" {
+		path, err := writeProfile(w.ProfileDir, resource)
+		if err != nil {
+			glog.Errorf("unexpected error writing %v profile: %v", resource, err)
+		} else {
+			msg = fmt.Sprintf("%v (profile %v)", msg, path)
+		}
+	}
+
+	if w.Notify != nil {
+		w.Notify(resource, msg)
+	}
+}
+
+// appendSample adds v to samples keeping at most max elements
+func appendSample(samples []uint64, v uint64, max int) []uint64 {
+	samples = append(samples, v)
+	if len(samples) > max {
+		samples = samples[len(samples)-max:]
+	}
+	return samples
+}
+
+// isGrowing returns true if the window is full, every sample is greater than
+// or equal to the previous one and the total growth exceeds the threshold
+func isGrowing(samples []uint64, window int, threshold float64) bool {
+	if len(samples) < window || samples[0] == 0 {
+		return false
+	}
+
+	for i := 1; i < len(samples); i++ {
+		if samples[i] < samples[i-1] {
+			return false
+		}
+	}
+
+	growth := float64(samples[len(samples)-1]-samples[0]) / float64(samples[0])
+	return growth > threshold
+}
+
+func writeProfile(dir, resource string) (string, error) {
+	name := "heap"
+	if resource == GoroutineResource {
+		name = "goroutine"
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%v-%v.pprof", name, time.Now().Unix()))
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	// #nosec
+	defer f.Close()
+
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package watchdog
+
+import (
+	"testing"
+)
+
+func TestIsGrowing(t *testing.T) {
+	testCases := []struct {
+		samples   []uint64
+		window    int
+		threshold float64
+		expected  bool
+	}{
+		{[]uint64{100, 120, 150, 200}, 4, 0.5, true},
+		{[]uint64{100, 120, 150, 140}, 4, 0.5, false},
+		{[]uint64{100, 110, 120, 130}, 4, 0.5, false},
+		{[]uint64{100, 200}, 4, 0.5, false},
+		{[]uint64{0, 100, 200, 300}, 4, 0.5, false},
+		{nil, 4, 0.5, false},
+	}
+
+	for _, tc := range testCases {
+		if r := isGrowing(tc.samples, tc.window, tc.threshold); r != tc.expected {
+			t.Errorf("returned %v but expected %v for %v", r, tc.expected, tc.samples)
+		}
+	}
+}
+
+func TestAppendSample(t *testing.T) {
+	var samples []uint64
+	for i := uint64(0); i < 10; i++ {
+		samples = appendSample(samples, i, 3)
+	}
+
+	if len(samples) != 3 {
+		t.Fatalf("returned %v samples but expected 3", len(samples))
+	}
+	if samples[0] != 7 || samples[2] != 9 {
+		t.Errorf("unexpected samples %v", samples)
+	}
+}
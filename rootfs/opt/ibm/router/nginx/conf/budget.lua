@@ -0,0 +1,68 @@
+-- Enforces the latency and response size budgets declared with the
+-- latency-budget and max-response-size annotations and counts the
+-- violations per Ingress in the budget_violations shared dict.
+
+local violations = ngx.shared.budget_violations
+
+local function record(kind)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. kind
+    local _, err = violations:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record budget violation: ", err)
+    end
+end
+
+-- header_filter removes the length of the response, which no longer
+-- matches the body once truncated
+local function header_filter()
+    ngx.header.content_length = nil
+end
+
+-- body_filter truncates the response body after max_bytes
+local function body_filter(max_bytes)
+    local ctx = ngx.ctx
+    if ctx.budget_truncated then
+        ngx.arg[1] = ""
+        ngx.arg[2] = true
+        return
+    end
+
+    local chunk = ngx.arg[1]
+    local sent = (ctx.budget_sent or 0) + #chunk
+    if sent > max_bytes then
+        ngx.arg[1] = string.sub(chunk, 1, max_bytes - (ctx.budget_sent or 0))
+        ngx.arg[2] = true
+        ctx.budget_truncated = true
+        ngx.log(ngx.NOTICE, "response of ", ngx.var.request_uri, " truncated after ", max_bytes, " bytes")
+        record("response_size")
+    end
+    ctx.budget_sent = sent
+end
+
+-- log counts the requests that took longer than latency_ms
+local function log(latency_ms)
+    local elapsed = tonumber(ngx.var.request_time) or 0
+    if elapsed * 1000 > latency_ms then
+        record("latency")
+    end
+end
+
+-- report writes one line per Ingress and budget with the number of violations
+local function report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(violations:get_keys(0)) do
+        local count = violations:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+-- Expose interface.
+local _M = {}
+_M.header_filter = header_filter
+_M.body_filter = body_filter
+_M.log = log
+_M.report = report
+
+return _M
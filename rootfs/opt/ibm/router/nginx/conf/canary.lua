@@ -0,0 +1,59 @@
+-- Sends a share of the requests of the locations with the canary-backend
+-- annotation to the canary upstream. The controller writes the weight and
+-- the header route of each Ingress to the canary weights file, so they
+-- change without a reload. The requests with the value of the header route
+-- always go to the canary, the rest with the probability of the weight.
+-- Ingresses missing from the file send no requests to the canary.
+
+local _M = {}
+
+-- the workers read the weights file again after refresh seconds
+local refresh = 1
+local cache = { loaded = 0, ingresses = {} }
+local seeded = false
+
+local function weights(file, ingress)
+    local now = ngx.now()
+    if now - cache.loaded >= refresh then
+        local ingresses = {}
+        local f, err = io.open(file, "r")
+        if f then
+            for line in f:lines() do
+                local key, weight, header, value = string.match(line, "^(%S+)%s+(%d+)%s+(%S+)%s+(%S+)$")
+                if key then
+                    ingresses[key] = { weight = tonumber(weight), header = header, value = value }
+                end
+            end
+            f:close()
+        else
+            ngx.log(ngx.WARN, "failed to open canary weights ", file, ": ", err)
+        end
+        cache.loaded = now
+        cache.ingresses = ingresses
+    end
+    return cache.ingresses[ingress]
+end
+
+-- route sets $canary_upstream to the canary upstream when the request is
+-- selected for the canary of the Ingress
+function _M.route(file, ingress, upstream)
+    local w = weights(file, ingress)
+    if not w then
+        return
+    end
+
+    if w.header ~= "-" and ngx.req.get_headers()[w.header] == w.value then
+        ngx.var.canary_upstream = upstream
+        return
+    end
+
+    if not seeded then
+        math.randomseed(ngx.now() * 1000 + ngx.worker.pid())
+        seeded = true
+    end
+    if w.weight > 0 and math.random(100) <= w.weight then
+        ngx.var.canary_upstream = upstream
+    end
+end
+
+return _M
@@ -0,0 +1,46 @@
+-- Checks the revocation of the client certificates in the locations with
+-- the client-cert-revocation-secret annotation. The certificate is checked
+-- against the CRLs of the secret and its OCSP responder by the controller,
+-- which is called with a subrequest to the /_client_cert_revocation
+-- location of the server and caches the results. The requests without a
+-- client certificate are not checked.
+
+local _M = {}
+
+local function exit_forbidden()
+    ngx.status = ngx.HTTP_FORBIDDEN
+    ngx.header["Content-Type"] = "text/html; charset=UTF-8"
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+end
+
+-- check_or_exit rejects the requests with a revoked client certificate and,
+-- with the hard-fail policy, the ones whose status is unknown
+function _M.check_or_exit(secret, ocsp, policy)
+    local cert = ngx.var.ssl_client_escaped_cert
+    if not cert or cert == "" then
+        return
+    end
+
+    local res = ngx.location.capture("/_client_cert_revocation", {
+        method = ngx.HTTP_GET,
+        args = { secret = secret, ocsp = tostring(ocsp) },
+    })
+    if res.status == ngx.HTTP_OK then
+        return
+    end
+    if res.status == ngx.HTTP_FORBIDDEN then
+        ngx.log(ngx.NOTICE, "rejecting revoked client certificate ", ngx.var.ssl_client_s_dn)
+        return exit_forbidden()
+    end
+
+    if res.status ~= ngx.HTTP_SERVICE_UNAVAILABLE then
+        ngx.log(ngx.ERR, "unexpected status checking the revocation of the client certificate: ", res.status)
+    end
+    if policy == "hard-fail" then
+        ngx.log(ngx.NOTICE, "rejecting client certificate ", ngx.var.ssl_client_s_dn, " with unknown revocation status")
+        return exit_forbidden()
+    end
+    ngx.log(ngx.WARN, "accepting client certificate ", ngx.var.ssl_client_s_dn, " with unknown revocation status")
+end
+
+return _M
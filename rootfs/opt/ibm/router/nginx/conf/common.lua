@@ -34,6 +34,63 @@ function common.get_file_content(path)
 end
 
 
+function common.append_response_header(name, value)
+    -- Append value to the comma separated list of the response header.
+    local current = ngx.header[name]
+    if type(current) == "table" then
+        current = table.concat(current, ", ")
+    end
+    if current and current ~= "" then
+        ngx.header[name] = current .. ", " .. value
+    else
+        ngx.header[name] = value
+    end
+end
+
+
+-- keys files are only rewritten with a reload, the workers read them once
+local keys_cache = {}
+
+function common.load_keys(keys_file, kind)
+    -- Return the base64 decoded keys of a "<kid> <key>" per line file of
+    -- the Secret of a location, by kid. kind names the keys in the logs.
+    local keys = keys_cache[keys_file]
+    if keys then
+        return keys
+    end
+
+    keys = {}
+    local f, err = io.open(keys_file, "r")
+    if not f then
+        ngx.log(ngx.ERR, "failed to open ", kind, " keys ", keys_file, ": ", err)
+        return keys
+    end
+    for line in f:lines() do
+        local kid, key = string.match(line, "^(%S+)%s+(%S+)$")
+        if kid then
+            keys[kid] = ngx.decode_base64(key)
+        end
+    end
+    f:close()
+
+    keys_cache[keys_file] = keys
+    return keys
+end
+
+
+function common.equals(a, b)
+    -- Compare two strings in a time independent of their content.
+    if #a ~= #b then
+        return false
+    end
+    local diff = 0
+    for i = 1, #a do
+        diff = bit.bor(diff, bit.bxor(string.byte(a, i), string.byte(b, i)))
+    end
+    return diff == 0
+end
+
+
 -- Monkey-patch string table.
 
 function string:split(sep)
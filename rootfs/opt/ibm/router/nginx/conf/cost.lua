@@ -0,0 +1,98 @@
+-- Tags the requests of the locations with the cost-tag annotation with a
+-- tenant or project identifier, written in $cost_tag for the access log,
+-- and counts the bytes received and sent per tag in the cost_bytes shared
+-- dict.
+
+local cjson = require "cjson.safe"
+
+local _M = {}
+
+local bytes = ngx.shared.cost_bytes
+
+-- claim returns a claim of the JWT bearer token of the request. The token
+-- is not verified, the location must validate it.
+local function claim(name)
+    local auth_header = ngx.var.http_authorization
+    if not auth_header then
+        return nil
+    end
+    local _, _, payload = string.find(auth_header, "^Bearer%s+[%w_-]+%.([%w_-]+)%.")
+    if not payload then
+        return nil
+    end
+
+    payload = string.gsub(string.gsub(payload, "-", "+"), "_", "/")
+    payload = payload .. string.rep("=", (4 - #payload % 4) % 4)
+    local claims = cjson.decode(ngx.decode_base64(payload) or "")
+    if type(claims) ~= "table" then
+        return nil
+    end
+    local value = claims[name]
+    if type(value) == "string" or type(value) == "number" then
+        return tostring(value)
+    end
+    return nil
+end
+
+local function lookup(source)
+    if source == "namespace" then
+        return ngx.var.namespace
+    end
+    local _, _, kind, name = string.find(source, "^(%a+):(.+)$")
+    if kind == "header" then
+        local value = ngx.req.get_headers()[name]
+        if type(value) == "table" then
+            value = value[1]
+        end
+        return value
+    end
+    if kind == "claim" then
+        return claim(name)
+    end
+    return nil
+end
+
+-- tag sets $cost_tag to the value of the first source found
+function _M.tag(sources)
+    for _, source in ipairs(sources) do
+        local value = lookup(source)
+        if value and value ~= "" then
+            -- tags are metric labels and access log fields
+            ngx.var.cost_tag = string.sub(string.gsub(value, "[^%w._-]", "_"), 1, 63)
+            return
+        end
+    end
+end
+
+-- log adds the bytes of the request to its tag
+function _M.log()
+    local tag = ngx.var.cost_tag
+    if not tag or tag == "-" then
+        return
+    end
+    local prefix = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. tag
+    local _, err = bytes:incr("received " .. prefix, tonumber(ngx.var.request_length) or 0, 0)
+    if not err then
+        _, err = bytes:incr("sent " .. prefix, tonumber(ngx.var.bytes_sent) or 0, 0)
+    end
+    if err then
+        ngx.log(ngx.WARN, "failed to record tagged bytes: ", err)
+    end
+end
+
+-- report writes one line per Ingress and tag with the bytes in direction
+-- (received or sent)
+function _M.report(direction)
+    ngx.header["Content-Type"] = "text/plain"
+    local prefix = direction .. " "
+    for _, key in ipairs(bytes:get_keys(0)) do
+        if string.sub(key, 1, #prefix) == prefix then
+            local count = bytes:get(key)
+            if count then
+                ngx.say(string.sub(key, #prefix + 1), " ", count)
+            end
+        end
+    end
+end
+
+return _M
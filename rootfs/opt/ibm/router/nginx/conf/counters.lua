@@ -0,0 +1,48 @@
+-- Counts the requests matching the counters defined with the custom-counters
+-- annotation, per Ingress, in the custom_counters shared dict.
+
+local counters = ngx.shared.custom_counters
+
+local function matches(c)
+    if c.status and string.sub(ngx.var.status, 1, #c.status) ~= c.status then
+        return false
+    end
+    if c.method and ngx.req.get_method() ~= c.method then
+        return false
+    end
+    if c.header and not ngx.req.get_headers()[c.header] then
+        return false
+    end
+    return true
+end
+
+-- log increments the counters matching the request
+local function log(defs)
+    for _, c in ipairs(defs) do
+        if matches(c) then
+            local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. c.name
+            local _, err = counters:incr(key, 1, 0)
+            if err then
+                ngx.log(ngx.WARN, "failed to increment counter ", c.name, ": ", err)
+            end
+        end
+    end
+end
+
+-- report writes one line per Ingress and counter with its value
+local function report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(counters:get_keys(0)) do
+        local count = counters:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+-- Expose interface.
+local _M = {}
+_M.log = log
+_M.report = report
+
+return _M
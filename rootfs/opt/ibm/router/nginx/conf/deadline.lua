@@ -0,0 +1,61 @@
+-- Sends the remaining time of the request to the backend in the locations
+-- with the deadline-header annotation, so the backend can stop the work
+-- whose response would arrive after the client stopped waiting. The
+-- remaining time is the timeout of the location minus the time spent in
+-- NGINX, like in the surge protection queue, and a shorter deadline sent
+-- by the client is kept.
+
+local _M = {}
+
+local grpc_units = { H = 3600000, M = 60000, S = 1000, m = 1, u = 0.001, n = 0.000001 }
+
+-- incoming returns the deadline in milliseconds sent by the client in the
+-- header, or nil
+local function incoming(header)
+    local value = ngx.req.get_headers()[header]
+    if type(value) ~= "string" then
+        return nil
+    end
+    if header == "grpc-timeout" then
+        local n, unit = string.match(value, "^(%d+)([HMSmun])$")
+        if not n then
+            return nil
+        end
+        return tonumber(n) * grpc_units[unit]
+    end
+    return tonumber(string.match(value, "^%d+$"))
+end
+
+-- format returns the value of the header for the remaining milliseconds
+local function format(header, remaining)
+    if header == "grpc-timeout" then
+        -- the values have at most 8 digits
+        if remaining < 100000000 then
+            return remaining .. "m"
+        end
+        return math.floor(remaining / 1000) .. "S"
+    end
+    return tostring(remaining)
+end
+
+-- set sends the remaining time of the request in the header, x-request-deadline
+-- or grpc-timeout, and rejects the requests without time left with a 504.
+-- It must run at the end of the access phase. A zero timeout is not
+-- enforced.
+function _M.set(header, timeout)
+    if timeout <= 0 then
+        return
+    end
+    ngx.update_time()
+    local remaining = timeout - math.floor((ngx.now() - ngx.req.start_time()) * 1000)
+    local client = incoming(header)
+    if client and client < remaining then
+        remaining = math.floor(client)
+    end
+    if remaining <= 0 then
+        return ngx.exit(ngx.HTTP_GATEWAY_TIMEOUT)
+    end
+    ngx.req.set_header(header, format(header, remaining))
+end
+
+return _M
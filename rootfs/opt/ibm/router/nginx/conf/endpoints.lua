@@ -0,0 +1,373 @@
+-- Balances the requests of the upstreams with the upstream-hash-load-factor,
+-- slow-start, outlier-detection or upstream-spiffe-id annotations to the
+-- ready endpoints of their Service, written by the controller to the
+-- endpoints file, instead of the ClusterIP.
+--
+-- With a load factor, the upstreams use consistent hashing with bounded
+-- load: a request goes to the first endpoint of the hash ring, from the
+-- position of its upstream-hash-by key, whose requests in progress are
+-- below factor times the average. Hot keys spill over to the next
+-- endpoints of the ring instead of overloading one pod. The requests in
+-- progress per endpoint are kept in the chash_load shared dict.
+--
+-- With slow start, the endpoints first seen ready less than slow_start
+-- seconds ago get a share of the requests, or of the capacity with a load
+-- factor, that grows linearly with the time since they were ready. The
+-- times are kept in the slow_start shared dict, keyed by upstream and
+-- endpoint, so the ramp goes on after a reload. The endpoints of an
+-- upstream not seen before, like after a restart, are not ramped up.
+--
+-- With outlier detection, the endpoints that fail consecutive requests,
+-- with a 5xx status or a response slower than the maximum latency, are
+-- ejected from the balancing for the ejection time, kept in the outliers
+-- shared dict. At most max_ejection_percent of the endpoints, and at least
+-- one, are ejected at the same time, and the last endpoint is never ejected.
+--
+-- With upstream-spiffe-id, the file only has the endpoints whose SPIFFE ID
+-- was verified by the controller.
+--
+-- With --upstream-drain-period, the endpoints terminating but still serving
+-- follow with a - prefix during the drain period. They get no new requests
+-- while the upstream has ready endpoints, and take them when it has none.
+
+local balancer = require "ngx.balancer"
+
+local _M = {}
+
+local load = ngx.shared.chash_load
+local outliers = ngx.shared.outliers
+local slow_starts = ngx.shared.slow_start
+
+-- points of each endpoint in the ring
+local replicas = 100
+-- share of a new endpoint at the beginning of the slow start
+local min_weight = 0.05
+-- the workers read the endpoints file again after refresh seconds
+local refresh = 1
+local cache = { loaded = 0, upstreams = {} }
+
+local function build_ring(endpoints)
+    local points = {}
+    for _, endpoint in ipairs(endpoints) do
+        for i = 1, replicas do
+            points[#points + 1] = { hash = ngx.crc32_long(endpoint .. "#" .. i), endpoint = endpoint }
+        end
+    end
+    table.sort(points, function(a, b) return a.hash < b.hash end)
+    return points
+end
+
+-- parse returns the endpoints of a line of the endpoints file and the
+-- ready ones. The draining endpoints are only returned without ready
+-- endpoints.
+local function parse(fields)
+    local ready, draining = {}, {}
+    for _, field in ipairs(fields) do
+        if string.sub(field, 1, 1) == "-" then
+            draining[#draining + 1] = string.sub(field, 2)
+        else
+            ready[#ready + 1] = field
+        end
+    end
+    if #ready == 0 then
+        return draining, ready
+    end
+    return ready, ready
+end
+
+-- observe records the time the ready endpoints of the upstream were first
+-- seen ready, zero for the ones of an upstream not seen before, forgets the
+-- ones not ready anymore so they ramp up again, and returns the times. The
+-- first worker to see an endpoint sets its time.
+local function observe(name, ready, now)
+    local previous = slow_starts:get("upstream " .. name)
+    local current = {}
+    for _, endpoint in ipairs(ready) do
+        current[endpoint] = true
+        local ok, err = slow_starts:add(name .. " " .. endpoint, previous and now or 0)
+        if not ok and err ~= "exists" then
+            ngx.log(ngx.WARN, "failed to record the slow start of ", endpoint, ": ", err)
+        end
+    end
+    for endpoint in string.gmatch(previous or "", "%S+") do
+        if not current[endpoint] then
+            slow_starts:delete(name .. " " .. endpoint)
+        end
+    end
+    slow_starts:set("upstream " .. name, table.concat(ready, " "))
+
+    local since = {}
+    for _, endpoint in ipairs(ready) do
+        local t = slow_starts:get(name .. " " .. endpoint)
+        if t and t > 0 then
+            since[endpoint] = t
+        end
+    end
+    return since
+end
+
+local function upstream_endpoints(file, upstream)
+    local now = ngx.now()
+    if now - cache.loaded >= refresh then
+        local upstreams = {}
+        local f, err = io.open(file, "r")
+        if f then
+            for line in f:lines() do
+                local fields = {}
+                for field in string.gmatch(line, "%S+") do
+                    fields[#fields + 1] = field
+                end
+                local name = table.remove(fields, 1)
+                if name and #fields > 0 then
+                    -- keep the ring while the endpoints do not change
+                    local old = cache.upstreams[name]
+                    local key = table.concat(fields, " ")
+                    if old and old.key == key then
+                        upstreams[name] = old
+                    else
+                        local endpoints, ready = parse(fields)
+                        local since = observe(name, ready, now)
+                        upstreams[name] = { key = key, endpoints = endpoints, since = since }
+                    end
+                end
+            end
+            f:close()
+        else
+            ngx.log(ngx.WARN, "failed to open backend endpoints ", file, ": ", err)
+        end
+        cache.loaded = now
+        cache.upstreams = upstreams
+    end
+    return cache.upstreams[upstream]
+end
+
+-- weight returns the share of an endpoint relative to a warm endpoint
+local function weight(u, endpoint, slow_start, now)
+    local since = u.since[endpoint]
+    if slow_start <= 0 or not since then
+        return 1
+    end
+    return math.max(min_weight, math.min(1, (now - since) / slow_start))
+end
+
+-- first returns the index of the first point of the ring at or after hash
+local function first(points, hash)
+    local low, high = 1, #points
+    if hash > points[high].hash then
+        return 1
+    end
+    while low < high do
+        local mid = math.floor((low + high) / 2)
+        if points[mid].hash < hash then
+            low = mid + 1
+        else
+            high = mid
+        end
+    end
+    return low
+end
+
+local function set_peer(endpoint, host, port)
+    if endpoint then
+        host, port = string.match(endpoint, "^%[?(.-)%]?:(%d+)$")
+        port = tonumber(port)
+        ngx.ctx.endpoint = endpoint
+    end
+    local ok, err = balancer.set_current_peer(host, port)
+    if not ok then
+        ngx.log(ngx.ERR, "failed to set the upstream peer ", host, ":", port, ": ", err)
+        return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+    end
+end
+
+-- ejected returns the ejected endpoints of the upstream, limited to
+-- max_percent of them
+local function ejected(upstream, u, max_percent)
+    local skip = {}
+    if max_percent <= 0 or #u.endpoints < 2 then
+        return skip
+    end
+    local max = math.min(#u.endpoints - 1, math.max(1, math.floor(#u.endpoints * max_percent / 100)))
+    local count = 0
+    for _, endpoint in ipairs(u.endpoints) do
+        if count >= max then
+            break
+        end
+        if outliers:get("ejected " .. upstream .. " " .. endpoint) then
+            skip[endpoint] = true
+            count = count + 1
+        end
+    end
+    return skip
+end
+
+-- hashed returns the first endpoint of the ring from hash below its
+-- capacity, skipping the endpoints already tried by the request
+local function hashed(upstream, u, factor, slow_start, tried)
+    local now = ngx.now()
+    local total, weights = 0, 0
+    for _, endpoint in ipairs(u.endpoints) do
+        total = total + (load:get(upstream .. " " .. endpoint) or 0)
+        weights = weights + weight(u, endpoint, slow_start, now)
+    end
+
+    if not u.points then
+        u.points = build_ring(u.endpoints)
+    end
+    local points = u.points
+    local start = first(points, ngx.crc32_long(ngx.var.chash_key or ""))
+    local fallback
+    local seen = {}
+    for i = 0, #points - 1 do
+        local endpoint = points[(start - 1 + i) % #points + 1].endpoint
+        if not seen[endpoint] then
+            seen[endpoint] = true
+            if not tried[endpoint] then
+                fallback = fallback or endpoint
+                local capacity = math.ceil(factor * (total + 1) * weight(u, endpoint, slow_start, now) / weights)
+                if (load:get(upstream .. " " .. endpoint) or 0) + 1 <= capacity then
+                    return endpoint
+                end
+            end
+        end
+    end
+    return fallback or points[start].endpoint
+end
+
+-- weighted returns a random endpoint, with the share of the endpoints in
+-- slow start reduced, skipping the ejected endpoints
+local function weighted(u, slow_start, skip)
+    local now = ngx.now()
+    local weights = 0
+    for _, endpoint in ipairs(u.endpoints) do
+        if not skip[endpoint] then
+            weights = weights + weight(u, endpoint, slow_start, now)
+        end
+    end
+    local r = math.random() * weights
+    local last
+    for _, endpoint in ipairs(u.endpoints) do
+        if not skip[endpoint] then
+            last = endpoint
+            r = r - weight(u, endpoint, slow_start, now)
+            if r <= 0 then
+                return endpoint
+            end
+        end
+    end
+    return last
+end
+
+-- balance sets the endpoint of the request. The options are the load
+-- factor of the hashing, the slow_start period in seconds and the
+-- max_ejection_percent of the outlier detection, zero if disabled. The
+-- ClusterIP is used while the upstream has no ready endpoints. The
+-- upstreams verifying the SPIFFE IDs of their endpoints have no ClusterIP,
+-- so their requests fail until an endpoint is verified.
+function _M.balance(file, upstream, options, cluster_ip, port)
+    local u = upstream_endpoints(file, upstream)
+    if not u then
+        if cluster_ip == "" then
+            ngx.log(ngx.ERR, "no verified endpoints for upstream ", upstream)
+            return ngx.exit(ngx.HTTP_BAD_GATEWAY)
+        end
+        return set_peer(nil, cluster_ip, port)
+    end
+    local skip = ejected(upstream, u, options.max_ejection_percent)
+    if options.factor <= 0 then
+        return set_peer(weighted(u, options.slow_start, skip))
+    end
+
+    local ctx = ngx.ctx
+    -- a retry releases the endpoint of the previous try
+    if ctx.chash then
+        load:incr(ctx.chash, -1, 0)
+        ctx.chash = nil
+    end
+
+    ctx.chash_tried = ctx.chash_tried or {}
+    for endpoint in pairs(skip) do
+        ctx.chash_tried[endpoint] = true
+    end
+    local endpoint = hashed(upstream, u, options.factor, options.slow_start, ctx.chash_tried)
+    ctx.chash_tried[endpoint] = true
+
+    local key = upstream .. " " .. endpoint
+    local _, err = load:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to count hashed request: ", err)
+    else
+        ctx.chash = key
+    end
+    return set_peer(endpoint)
+end
+
+-- last returns the last value of an upstream variable, the one of the
+-- endpoint that answered
+local function last(value)
+    if not value then
+        return nil
+    end
+    return string.match(value, "([^%s,:]+)%s*$")
+end
+
+-- detect counts the consecutive failures of the endpoint of the request
+-- and ejects it after max_failures. A zero max_latency, in milliseconds,
+-- does not check the latency.
+local function detect(upstream, max_failures, max_latency, ejection_time)
+    local endpoint = ngx.ctx.endpoint
+    if not endpoint then
+        return
+    end
+
+    local reason
+    local status = tonumber(last(ngx.var.upstream_status))
+    local response_time = tonumber(last(ngx.var.upstream_response_time))
+    if not status or status >= 500 then
+        reason = "errors"
+    elseif max_latency > 0 and response_time and response_time * 1000 > max_latency then
+        reason = "latency"
+    end
+
+    local key = upstream .. " " .. endpoint
+    if not reason then
+        outliers:delete("failures " .. key)
+        return
+    end
+    local failures, err = outliers:incr("failures " .. key, 1, 0)
+    if not failures then
+        ngx.log(ngx.WARN, "failed to count endpoint failure: ", err)
+        return
+    end
+    if failures >= max_failures then
+        ngx.log(ngx.WARN, "ejecting endpoint ", endpoint, " of upstream ", upstream, " for ", ejection_time, "s after ", failures, " consecutive failures (", reason, ")")
+        outliers:delete("failures " .. key)
+        outliers:set("ejected " .. key, reason, ejection_time)
+    end
+end
+
+-- log ends the request counted in the balancer and, when max_failures is
+-- not zero, updates the outlier detection of the upstream
+function _M.log(upstream, max_failures, max_latency, ejection_time)
+    if ngx.ctx.chash then
+        load:incr(ngx.ctx.chash, -1, 0)
+    end
+    if max_failures > 0 then
+        detect(upstream, max_failures, max_latency, ejection_time)
+    end
+end
+
+-- report writes one line per ejected endpoint with its upstream, the
+-- reason and 1
+function _M.report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(outliers:get_keys(0)) do
+        local upstream, endpoint = string.match(key, "^ejected (%S+) (%S+)$")
+        local reason = outliers:get(key)
+        if upstream and reason then
+            ngx.say(upstream, " ", endpoint, " ", reason, " 1")
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,83 @@
+-- Limits the concurrent requests of each client in the locations with the
+-- max-requests-per-client annotation, so one client, like an automation
+-- account, can not use all the capacity of the backend. The requests in
+-- progress are kept per location and client in the fairness_requests
+-- shared dict and the rejections per Ingress in fairness_rejections.
+
+local saauth = require "saauth"
+
+local _M = {}
+
+local requests = ngx.shared.fairness_requests
+local rejections = ngx.shared.fairness_rejections
+
+-- client returns the key of the client of the request with the subject
+-- key: the ServiceAccount or the break-glass Secret authenticated in the
+-- location, or the user of the bearer token reviewed by the API server,
+-- never the headers of the client. The client address is used otherwise.
+local function client(key)
+    if key == "subject" then
+        if ngx.ctx.subject then
+            return ngx.ctx.subject, "subject"
+        end
+        local user = saauth.review()
+        if user then
+            return user, "subject"
+        end
+    end
+    return ngx.var.the_real_ip or ngx.var.remote_addr, "ip"
+end
+
+-- release ends a request of the route, and removes the route without
+-- requests in progress so the dict does not fill with the past clients
+local function release(route)
+    local count = requests:incr(route, -1, 0)
+    if count and count <= 0 then
+        requests:delete(route)
+    end
+end
+
+-- access counts the request of the client and rejects it when the client
+-- already has max requests in progress in the location. It must run after
+-- the authentication.
+function _M.access(path, max, key)
+    local id, kind = client(key)
+    local route = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. path .. " " .. id
+    local count, err = requests:incr(route, 1, 0)
+    if not count then
+        ngx.log(ngx.WARN, "failed to count client request: ", err)
+        return
+    end
+    if count > max then
+        release(route)
+        local _, err = rejections:incr((ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. kind, 1, 0)
+        if err then
+            ngx.log(ngx.WARN, "failed to record rejected client request: ", err)
+        end
+        ngx.header["Retry-After"] = 1
+        return ngx.exit(429)
+    end
+    ngx.ctx.fairness = route
+end
+
+-- log ends the request counted in the access phase
+function _M.log()
+    local route = ngx.ctx.fairness
+    if route then
+        release(route)
+    end
+end
+
+-- report writes one line per Ingress and client key with the number of
+-- rejected requests
+function _M.report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(rejections:get_keys(0)) do
+        local count = rejections:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+return _M
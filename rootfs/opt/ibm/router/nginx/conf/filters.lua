@@ -0,0 +1,163 @@
+-- Runs the signed Lua filters installed by the controller for the locations
+-- with the lua-filters annotation. A filter is a Lua chunk returning a table
+-- with the optional functions access, header_filter and body_filter. Filters
+-- run in a sandbox without access to io, os, require or the global table,
+-- with copies of the standard libraries and a subset of ngx to read and
+-- change the request and the response, and are aborted after
+-- max_instructions virtual machine instructions. pcall and xpcall are not
+-- available, so the limit can not be caught.
+
+local _M = {
+    dir = "",
+    max_instructions = 1000000,
+}
+
+-- loaded filters of the worker, by name
+local loaded = {}
+
+local function copy(t, names)
+    local c = {}
+    for k, v in pairs(t) do
+        if not names or names[k] then
+            c[k] = v
+        end
+    end
+    return c
+end
+
+local function set(...)
+    local s = {}
+    for _, name in ipairs({...}) do
+        s[name] = true
+    end
+    return s
+end
+
+local ngx_functions = set("exit", "log", "time", "now", "today", "utctime", "http_time", "null",
+    "encode_base64", "decode_base64", "md5", "sha1_bin", "crc32_short", "escape_uri", "unescape_uri",
+    "encode_args", "decode_args", "OK", "ERROR", "DECLINED",
+    "STDERR", "EMERG", "ALERT", "CRIT", "ERR", "WARN", "NOTICE", "INFO", "DEBUG")
+local req_functions = set("get_headers", "get_method", "get_uri_args", "http_version", "start_time",
+    "set_header", "clear_header")
+local re_functions = set("match", "find", "gmatch", "sub", "gsub")
+-- the fields of ngx read and written through the API of the request
+local ngx_fields = set("status")
+
+-- filter_ngx returns the subset of ngx available to the filters: no shared
+-- dicts, subrequests, redirections, sockets or timers
+local function filter_ngx()
+    local api = copy(ngx, ngx_functions)
+    for k, v in pairs(ngx) do
+        if type(k) == "string" and string.sub(k, 1, 5) == "HTTP_" then
+            api[k] = v
+        end
+    end
+    api.var = ngx.var
+    api.header = ngx.header
+    api.arg = ngx.arg
+    api.req = copy(ngx.req, req_functions)
+    api.resp = { get_headers = ngx.resp.get_headers }
+    api.re = copy(ngx.re, re_functions)
+
+    return setmetatable(api, {
+        __index = function(_, k)
+            if ngx_fields[k] then
+                return ngx[k]
+            end
+        end,
+        __newindex = function(_, k, v)
+            if not ngx_fields[k] then
+                error("ngx." .. tostring(k) .. " is not available to the filters", 2)
+            end
+            ngx[k] = v
+        end,
+    })
+end
+
+local function sandbox()
+    return {
+        ngx = filter_ngx(),
+        string = copy(string),
+        table = copy(table),
+        math = copy(math),
+        pairs = pairs,
+        ipairs = ipairs,
+        next = next,
+        select = select,
+        type = type,
+        tostring = tostring,
+        tonumber = tonumber,
+        error = error,
+        assert = assert,
+        unpack = unpack,
+    }
+end
+
+local function load(name)
+    local filter = loaded[name]
+    if filter then
+        return filter
+    end
+
+    local chunk, err = loadfile(_M.dir .. "/" .. name .. ".lua")
+    if not chunk then
+        return nil, err
+    end
+    setfenv(chunk, sandbox())
+
+    local ok, res = pcall(chunk)
+    if not ok then
+        return nil, res
+    end
+    if type(res) ~= "table" then
+        return nil, "filter must return a table"
+    end
+
+    -- the instruction count hook is not called from compiled code
+    for _, fn in pairs(res) do
+        if type(fn) == "function" then
+            jit.off(fn, true)
+        end
+    end
+
+    loaded[name] = res
+    return res
+end
+
+local function limit()
+    error("instruction limit exceeded", 2)
+end
+
+-- call runs the function of the filter for the phase with the instruction limit
+local function call(name, phase)
+    local filter, err = load(name)
+    if not filter then
+        return false, err
+    end
+
+    local fn = filter[phase]
+    if type(fn) ~= "function" then
+        return true
+    end
+
+    debug.sethook(limit, "", _M.max_instructions)
+    local ok, res = pcall(fn)
+    debug.sethook()
+    return ok, res
+end
+
+-- run executes the filters in order. Failures in the access phase reject
+-- the request, failures in other phases are only logged.
+function _M.run(phase, names)
+    for _, name in ipairs(names) do
+        local ok, err = call(name, phase)
+        if not ok then
+            ngx.log(ngx.ERR, "lua filter ", name, " failed in ", phase, ": ", err)
+            if phase == "access" then
+                return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+            end
+        end
+    end
+end
+
+return _M
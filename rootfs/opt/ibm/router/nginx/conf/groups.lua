@@ -0,0 +1,69 @@
+-- Routes the requests of the locations with the group-routes annotation by
+-- the groups of the authenticated caller. The groups are only read from the
+-- TokenReview of the bearer token by the API server, see saauth.review.
+-- The first route of a group of the caller sets $group_upstream, or rejects
+-- the request, and the requests without one keep the backend of the
+-- location, or of its canary.
+
+local saauth = require "saauth"
+
+local _M = {}
+
+-- parse returns the set of the URL encoded groups, comma separated
+local function parse(header)
+    local set = {}
+    for g in string.gmatch(header or "", "[^,]+") do
+        set[ngx.unescape_uri(g)] = true
+    end
+    return set
+end
+
+-- verified_groups returns the set of the groups of the bearer token, nil and
+-- the status of the review when the token can not be reviewed
+local function verified_groups()
+    local user, groups = saauth.review()
+    if not user then
+        return nil, groups
+    end
+    return parse(groups)
+end
+
+function _M.route(routes)
+    -- the canary of the location still applies to the other callers
+    local canary = ngx.var.canary_upstream
+    if canary and canary ~= "" then
+        ngx.var.group_upstream = canary
+    end
+
+    local member, status = verified_groups()
+    if not member then
+        -- the callers of a denied group must not skip the route with a
+        -- token that can not be reviewed
+        for _, r in ipairs(routes) do
+            if r.deny then
+                ngx.log(ngx.NOTICE, "request rejected by the group routes: the groups can not be verified")
+                if status == ngx.HTTP_UNAUTHORIZED then
+                    ngx.header["WWW-Authenticate"] = "Bearer"
+                end
+                return ngx.exit(status)
+            end
+        end
+        return
+    end
+
+    for _, r in ipairs(routes) do
+        if member[r.group] then
+            ngx.ctx.group = r.group
+            if r.deny then
+                ngx.log(ngx.NOTICE, "request of the group ", r.group, " rejected by the group routes")
+                return ngx.exit(ngx.HTTP_FORBIDDEN)
+            end
+            if r.upstream then
+                ngx.var.group_upstream = r.upstream
+            end
+            return
+        end
+    end
+end
+
+return _M
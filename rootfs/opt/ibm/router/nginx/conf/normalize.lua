@@ -0,0 +1,75 @@
+-- Rejects requests with ambiguous URIs or too many headers before they are
+-- proxied, and counts the rejections per Ingress and reason in the
+-- normalization_rejections shared dict.
+
+local _M = {
+    mode = "permissive",
+    max_headers = 100,
+}
+
+local rejections = ngx.shared.normalization_rejections
+
+local function reject(status, reason)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. reason
+    local _, err = rejections:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record rejected request: ", err)
+    end
+    ngx.log(ngx.NOTICE, "request rejected (", reason, "): ", ngx.var.request_uri)
+    return ngx.exit(status)
+end
+
+-- check_or_exit validates the request URI and headers following the
+-- strictness in mode (permissive or strict)
+function _M.check_or_exit()
+    local uri = ngx.var.request_uri or ""
+    local path = string.match(uri, "^[^?]*")
+
+    -- every % must start a valid escape sequence
+    for escape in string.gmatch(uri, "%%(..?)") do
+        if not string.match(escape, "^%x%x$") then
+            return reject(ngx.HTTP_BAD_REQUEST, "invalid_encoding")
+        end
+    end
+
+    if string.find(uri, "%z") or string.find(string.lower(uri), "%00", 1, true) then
+        return reject(ngx.HTTP_BAD_REQUEST, "nul")
+    end
+
+    local decoded = ngx.unescape_uri(path)
+    for segment in string.gmatch(decoded, "[^/\\]+") do
+        if segment == ".." then
+            return reject(ngx.HTTP_BAD_REQUEST, "traversal")
+        end
+    end
+
+    if _M.mode == "strict" then
+        local lower = string.lower(path)
+        -- encoded separators and double encoding are decoded differently by the backends
+        if string.find(lower, "%2f", 1, true) or string.find(lower, "%5c", 1, true) or
+                string.find(lower, "%25", 1, true) or string.find(path, "\\", 1, true) then
+            return reject(ngx.HTTP_BAD_REQUEST, "ambiguous_path")
+        end
+        if string.find(path, "//", 1, true) then
+            return reject(ngx.HTTP_BAD_REQUEST, "ambiguous_path")
+        end
+    end
+
+    local _, err = ngx.req.get_headers(_M.max_headers)
+    if err == "truncated" then
+        return reject(431, "too_many_headers")
+    end
+end
+
+-- report writes one line per Ingress and reason with the number of rejections
+function _M.report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(rejections:get_keys(0)) do
+        local count = rejections:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,141 @@
+-- Checks the audiences and scopes of the tokens of the requests to the
+-- routes of the OIDCRoutePolicy resources, and forwards their claims to the
+-- backends in headers. The rules of a location come from the controller,
+-- the most specific first, and a request is checked by the first rule of
+-- its path. The signature, the issuer and the expiration of the token are
+-- verified by the controller with the keys of the OIDC issuer, called with
+-- a subrequest to the /_oidc_verify location of the server.
+
+local cjson = require "cjson.safe"
+
+local _M = {}
+
+local function under_path(uri, path)
+    if path == "/" or uri == path then
+        return true
+    end
+    local prefix = string.gsub(path, "/$", "") .. "/"
+    return string.sub(uri, 1, #prefix) == prefix
+end
+
+-- values returns the set of the values of a claim, a string separated by
+-- spaces or a list
+local function values(claim)
+    local set = {}
+    if type(claim) == "string" then
+        for v in string.gmatch(claim, "%S+") do
+            set[v] = true
+        end
+    elseif type(claim) == "table" then
+        for _, v in ipairs(claim) do
+            set[tostring(v)] = true
+        end
+    end
+    return set
+end
+
+-- verified_claims returns the claims of the verified bearer token, nil and
+-- the status of the rejection otherwise
+local function verified_claims()
+    local auth_header = ngx.var.http_authorization
+    if auth_header == nil or not string.find(auth_header, "^Bearer%s+") then
+        return nil, ngx.HTTP_UNAUTHORIZED
+    end
+
+    local res = ngx.location.capture("/_oidc_verify", { method = ngx.HTTP_GET })
+    if res.status == ngx.HTTP_OK then
+        local payload = string.gsub(string.gsub(res.header["X-Verified-Claims"] or "", "-", "+"), "_", "/")
+        payload = payload .. string.rep("=", (4 - #payload % 4) % 4)
+        local claims = cjson.decode(ngx.decode_base64(payload) or "")
+        if type(claims) == "table" then
+            return claims
+        end
+        ngx.log(ngx.ERR, "invalid claims of the verified token")
+        return nil, ngx.HTTP_INTERNAL_SERVER_ERROR
+    end
+    if res.status == ngx.HTTP_UNAUTHORIZED then
+        return nil, ngx.HTTP_UNAUTHORIZED
+    end
+    ngx.log(ngx.ERR, "unexpected status verifying the token: ", res.status)
+    return nil, ngx.HTTP_SERVICE_UNAVAILABLE
+end
+
+local function forbidden(rule, reason)
+    ngx.log(ngx.NOTICE, "request rejected by the OIDCRoutePolicy ", rule.policy, ": ", reason)
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+end
+
+-- enforce rejects the requests whose token does not satisfy the first rule
+-- of their path, and sets the headers of its forwarded claims
+function _M.enforce(rules)
+    -- the forwarded headers are never taken from the client
+    for _, rule in ipairs(rules) do
+        for _, c in ipairs(rule.claims) do
+            ngx.req.clear_header(c.header)
+        end
+    end
+
+    local uri = ngx.var.uri
+    local rule
+    for _, r in ipairs(rules) do
+        if under_path(uri, r.path) then
+            rule = r
+            break
+        end
+    end
+    if not rule then
+        return
+    end
+
+    if rule.reject then
+        return forbidden(rule, "the tokens of the location can not be verified")
+    end
+
+    local token, status = verified_claims()
+    if not token then
+        ngx.log(ngx.NOTICE, "request without a verified JWT token rejected by the OIDCRoutePolicy ", rule.policy)
+        if status == ngx.HTTP_UNAUTHORIZED then
+            ngx.header["WWW-Authenticate"] = "Bearer"
+        end
+        return ngx.exit(status)
+    end
+
+    if #rule.audiences > 0 then
+        local aud = values(token.aud)
+        local accepted = false
+        for _, a in ipairs(rule.audiences) do
+            if aud[a] then
+                accepted = true
+                break
+            end
+        end
+        if not accepted then
+            return forbidden(rule, "audience not accepted")
+        end
+    end
+
+    if #rule.scopes > 0 then
+        local scopes = values(token.scope or token.scp)
+        for _, s in ipairs(rule.scopes) do
+            if not scopes[s] then
+                return forbidden(rule, "missing scope " .. s)
+            end
+        end
+    end
+
+    for _, c in ipairs(rule.claims) do
+        local value = token[c.claim]
+        if type(value) == "table" then
+            local list = {}
+            for _, v in ipairs(value) do
+                list[#list + 1] = tostring(v)
+            end
+            value = table.concat(list, ",")
+        end
+        if type(value) == "string" or type(value) == "number" or type(value) == "boolean" then
+            ngx.req.set_header(c.header, tostring(value))
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,94 @@
+-- Rejects the replayed webhooks of the locations with the
+-- replay-protection-secret annotation. A webhook carries the
+-- X-Webhook-Timestamp (Unix time), X-Webhook-Nonce, X-Webhook-Key-Id and
+-- X-Webhook-Signature headers, where the signature is the hex HMAC-SHA256 of
+-- "<path>\n<timestamp>\n<nonce>\n<body>" with the key of the Secret. A nonce
+-- is accepted once while its timestamp is in the window.
+
+local common = require "common"
+local hmac = require "resty.hmac"
+
+local _M = {}
+
+local nonces = ngx.shared.replay_nonces
+
+-- read_body returns the body of the request, buffered in memory or in a
+-- temporary file
+local function read_body()
+    ngx.req.read_body()
+    local body = ngx.req.get_body_data()
+    if body then
+        return body
+    end
+
+    local path = ngx.req.get_body_file()
+    if not path then
+        return ""
+    end
+    local f, err = io.open(path, "rb")
+    if not f then
+        ngx.log(ngx.ERR, "failed to read the body of the webhook: ", err)
+        return nil
+    end
+    body = f:read("*a")
+    f:close()
+    return body
+end
+
+local function deny(reason)
+    ngx.log(ngx.NOTICE, "webhook rejected (", reason, "): ", ngx.var.uri)
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+end
+
+-- check_or_exit denies the request unless it was signed with one of the
+-- keys of keys_file, its timestamp is less than window seconds away and its
+-- nonce was not used before
+function _M.check_or_exit(keys_file, window)
+    local timestamp = ngx.var.http_x_webhook_timestamp
+    local nonce = ngx.var.http_x_webhook_nonce
+    local kid = ngx.var.http_x_webhook_key_id
+    local signature = ngx.var.http_x_webhook_signature
+    if not timestamp or not nonce or not kid or not signature then
+        return deny("missing")
+    end
+    if not string.match(nonce, "^[%w_-]+$") or #nonce < 16 or #nonce > 128 then
+        return deny("nonce")
+    end
+
+    local t = tonumber(timestamp)
+    if not t or math.abs(ngx.time() - t) > window then
+        return deny("expired")
+    end
+
+    local key = common.load_keys(keys_file, "replay protection")[kid]
+    if not key then
+        return deny("unknown_key")
+    end
+
+    local body = read_body()
+    if not body then
+        return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+    end
+
+    local path = string.match(ngx.var.request_uri or "", "^[^?]*")
+    local payload = path .. "\n" .. timestamp .. "\n" .. nonce .. "\n" .. body
+    local expected = hmac:new(key, hmac.ALGOS.SHA256):final(payload, true)
+    if not common.equals(expected, string.lower(signature)) then
+        return deny("signature")
+    end
+
+    -- the nonce is kept while a request with its timestamp is accepted
+    local ok, err, forcible = nonces:add(keys_file .. " " .. kid .. " " .. nonce, true, 2 * window + 1)
+    if not ok then
+        if err == "exists" then
+            return deny("replay")
+        end
+        ngx.log(ngx.ERR, "failed to store the nonce of the webhook: ", err)
+        return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+    end
+    if forcible then
+        ngx.log(ngx.WARN, "replay_nonces is full, the oldest nonces were evicted")
+    end
+end
+
+return _M
@@ -0,0 +1,82 @@
+-- Authenticates in-cluster clients with their projected ServiceAccount
+-- token, and returns the user and the groups of the bearer tokens of the
+-- other clients. The tokens are reviewed by the controller, which is called
+-- with a subrequest to the /_service_account_auth or /_groups_auth location
+-- of the server.
+
+local function exit_with(status)
+    ngx.status = status
+    ngx.header["Content-Type"] = "text/html; charset=UTF-8"
+    if status == ngx.HTTP_UNAUTHORIZED then
+        ngx.header["WWW-Authenticate"] = "Bearer"
+    end
+    return ngx.exit(status)
+end
+
+-- validate_or_exit rejects the request unless it contains the token of one
+-- of the allowed ServiceAccounts, formatted as <namespace>/<name> or
+-- <namespace>/*
+local function validate_or_exit(allowed)
+    local auth_header = ngx.var.http_authorization
+    if auth_header == nil or not string.find(auth_header, "^Bearer%s+") then
+        ngx.log(ngx.NOTICE, "No service account token in request.")
+        return exit_with(ngx.HTTP_UNAUTHORIZED)
+    end
+
+    local res = ngx.location.capture("/_service_account_auth", {
+        method = ngx.HTTP_GET,
+        args = { allowed = table.concat(allowed, ",") },
+    })
+
+    if res.status == ngx.HTTP_OK then
+        ngx.req.set_header("X-Forwarded-Service-Account", res.header["X-Service-Account"])
+        ngx.ctx.subject = "serviceaccount:" .. (res.header["X-Service-Account"] or "")
+        ngx.ctx.review = {
+            user = "serviceaccount:" .. (res.header["X-Service-Account"] or ""),
+            groups = res.header["X-Groups"] or "",
+        }
+        return
+    end
+    if res.status == ngx.HTTP_FORBIDDEN then
+        return exit_with(ngx.HTTP_FORBIDDEN)
+    end
+    if res.status ~= ngx.HTTP_UNAUTHORIZED then
+        ngx.log(ngx.ERR, "unexpected status validating service account token: ", res.status)
+    end
+    return exit_with(ngx.HTTP_UNAUTHORIZED)
+end
+
+-- review returns the user and the URL encoded groups, comma separated, of
+-- the bearer token of the request reviewed by the API server, or nil and
+-- the status of the review: 401 without a valid token and 503 when the
+-- review fails. The result is kept for the request.
+local function review()
+    local r = ngx.ctx.review
+    if r then
+        return r.user, r.groups
+    end
+
+    local auth_header = ngx.var.http_authorization
+    if auth_header == nil or not string.find(auth_header, "^Bearer%s+") then
+        return nil, ngx.HTTP_UNAUTHORIZED
+    end
+
+    local res = ngx.location.capture("/_groups_auth", { method = ngx.HTTP_GET })
+    if res.status == ngx.HTTP_OK then
+        r = { user = "user:" .. (res.header["X-User"] or ""), groups = res.header["X-Groups"] or "" }
+        ngx.ctx.review = r
+        return r.user, r.groups
+    end
+    if res.status == ngx.HTTP_UNAUTHORIZED then
+        return nil, ngx.HTTP_UNAUTHORIZED
+    end
+    ngx.log(ngx.ERR, "unexpected status reviewing the bearer token: ", res.status)
+    return nil, ngx.HTTP_SERVICE_UNAVAILABLE
+end
+
+-- Expose interface.
+local _M = {}
+_M.validate_or_exit = validate_or_exit
+_M.review = review
+
+return _M
@@ -0,0 +1,43 @@
+-- Validates the signed URLs of the locations with the signed-url-secret
+-- annotation. A signed URL carries the expires, kid and signature query
+-- arguments, where signature is the hex HMAC-SHA256 of
+-- "<path>\n<expires>\n<kid>" with the key kid of the Secret.
+
+local common = require "common"
+local hmac = require "resty.hmac"
+
+local _M = {}
+
+local function deny(reason)
+    ngx.log(ngx.NOTICE, "signed URL rejected (", reason, "): ", ngx.var.uri)
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+end
+
+-- validate_or_exit denies the request unless its URL was signed with one of
+-- the keys of keys_file and has not expired
+function _M.validate_or_exit(keys_file)
+    local args = ngx.req.get_uri_args()
+    local expires, kid, signature = args.expires, args.kid, args.signature
+    if type(expires) ~= "string" or type(kid) ~= "string" or type(signature) ~= "string" then
+        return deny("missing")
+    end
+
+    local deadline = tonumber(expires)
+    if not deadline or deadline < ngx.time() then
+        return deny("expired")
+    end
+
+    local key = common.load_keys(keys_file, "signed URL")[kid]
+    if not key then
+        return deny("unknown_key")
+    end
+
+    local path = string.match(ngx.var.request_uri or "", "^[^?]*")
+    local payload = path .. "\n" .. expires .. "\n" .. kid
+    local expected = hmac:new(key, hmac.ALGOS.SHA256):final(payload, true)
+    if not common.equals(expected, string.lower(signature)) then
+        return deny("signature")
+    end
+end
+
+return _M
@@ -0,0 +1,117 @@
+-- Protects the backends of the locations with the surge-protection
+-- annotation while most of their pods are not ready, like during a rollout.
+-- The controller writes the ready and total pods of each Service to the
+-- readiness file. While the ready pods are below the minimum percentage,
+-- the requests in progress of the Service, kept in the surge_requests shared
+-- dict, are limited to a number per ready pod and the requests over the
+-- limit are rejected with 503 and Retry-After, or queued for a while before.
+-- Services missing from the file are not protected.
+
+local _M = {}
+
+local requests = ngx.shared.surge_requests
+local rejections = ngx.shared.surge_rejections
+
+-- the workers read the readiness file again after refresh seconds
+local refresh = 1
+local cache = { loaded = 0, services = {} }
+
+local function readiness(file, service)
+    local now = ngx.now()
+    if now - cache.loaded >= refresh then
+        local services = {}
+        local f, err = io.open(file, "r")
+        if f then
+            for line in f:lines() do
+                local key, ready, total = string.match(line, "^(%S+)%s+(%d+)%s+(%d+)$")
+                if key then
+                    services[key] = { ready = tonumber(ready), total = tonumber(total) }
+                end
+            end
+            f:close()
+        else
+            ngx.log(ngx.WARN, "failed to open backend readiness ", file, ": ", err)
+        end
+        cache.loaded = now
+        cache.services = services
+    end
+    return cache.services[service]
+end
+
+-- limit returns the maximum requests in progress of the Service, or nil if
+-- it is not protected
+local function limit(file, service, min_ready, per_pod)
+    local r = readiness(file, service)
+    if not r or r.total == 0 or r.ready * 100 >= r.total * min_ready then
+        return nil
+    end
+    return r.ready * per_pod
+end
+
+local function reject(reason, retry_after)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. reason
+    local _, err = rejections:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record surge rejection: ", err)
+    end
+    ngx.header["Retry-After"] = retry_after
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+end
+
+-- admit counts the request of the Service and returns true if it is under
+-- the limit
+local function admit(service, max)
+    local count, err = requests:incr(service, 1, 0)
+    if not count then
+        ngx.log(ngx.WARN, "failed to count surge request: ", err)
+        return true
+    end
+    if max and count > max then
+        requests:incr(service, -1, 0)
+        return false
+    end
+    return true
+end
+
+-- access counts the request in progress and, while the Service has too few
+-- ready pods, rejects it or queues it up to queue_timeout seconds when the
+-- limit is reached. mode is reject or queue.
+function _M.access(file, service, mode, min_ready, per_pod, queue_timeout, retry_after)
+    if not admit(service, limit(file, service, min_ready, per_pod)) then
+        if mode ~= "queue" then
+            return reject("limit", retry_after)
+        end
+
+        local deadline = ngx.now() + queue_timeout
+        repeat
+            ngx.sleep(0.05)
+            ngx.update_time()
+            if ngx.now() >= deadline then
+                return reject("timeout", retry_after)
+            end
+        until admit(service, limit(file, service, min_ready, per_pod))
+    end
+
+    ngx.ctx.surge = service
+end
+
+-- log ends the request admitted in the access phase
+function _M.log()
+    local service = ngx.ctx.surge
+    if service then
+        requests:incr(service, -1, 0)
+    end
+end
+
+-- report writes one line per Ingress and reason with the rejected requests
+function _M.report()
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(rejections:get_keys(0)) do
+        local count = rejections:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,175 @@
+-- Classifies the requests in tiers, like static assets, API reads, API
+-- writes and authentication, and applies the limits of the request-tiers
+-- ConfigMap key to each tier:
+-- * rate and burst limit the requests per second of each client address,
+--   the requests over the rate are delayed up to burst and rejected with
+--   429 after it
+-- * max_concurrent limits the requests in progress of the tier, the
+--   requests over the limit wait up to queue_timeout seconds, behind the
+--   waiting requests of the tiers with a higher priority, and are rejected
+--   with 503 after it
+-- The classified requests are counted per Ingress and tier in the
+-- tier_requests shared dict and the rejections in tier_rejections.
+
+local limit_req = require "resty.limit.req"
+
+local _M = { tiers = {}, classifiers = {} }
+
+local requests = ngx.shared.tier_requests
+local rejections = ngx.shared.tier_rejections
+local active = ngx.shared.tier_active
+
+-- configure sets the tiers, by name, and the ordered classifiers. It runs
+-- in every worker.
+function _M.configure(tiers, classifiers)
+    for name, tier in pairs(tiers) do
+        if tier.rate > 0 then
+            local limiter, err = limit_req.new("tier_rates", tier.rate, tier.burst)
+            if not limiter then
+                ngx.log(ngx.ERR, "failed to create the rate limit of the tier ", name, ": ", err)
+            end
+            tier.limiter = limiter
+        end
+        -- the waiting requests of these priorities go first
+        tier.higher = {}
+        for _, other in pairs(tiers) do
+            if other.priority > tier.priority then
+                tier.higher["queued " .. other.priority] = true
+            end
+        end
+    end
+    _M.tiers = tiers
+    _M.classifiers = classifiers
+end
+
+-- classify returns the tier of the first classifier matching the method
+-- and the path of the request
+function _M.classify()
+    local method = ngx.req.get_method()
+    local uri = ngx.var.uri
+    for _, c in ipairs(_M.classifiers) do
+        if (not c.methods or c.methods[method]) and (not c.path or ngx.re.find(uri, c.path, "jo")) then
+            return c.tier
+        end
+    end
+    return nil
+end
+
+local function count(dict, name)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. name
+    local _, err = dict:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to count the request of the tier ", name, ": ", err)
+    end
+end
+
+local function reject(name, status)
+    count(rejections, name)
+    ngx.header["Retry-After"] = 1
+    return ngx.exit(status)
+end
+
+-- admit counts the request in progress of the tier and returns true if it
+-- is under the limit
+local function admit(name, max)
+    local n, err = active:incr("active " .. name, 1, 0)
+    if not n then
+        ngx.log(ngx.WARN, "failed to count the request in progress of the tier ", name, ": ", err)
+        return true
+    end
+    if n > max then
+        active:incr("active " .. name, -1, 0)
+        return false
+    end
+    return true
+end
+
+-- waiting returns true while requests of a higher priority are queued
+local function waiting(tier)
+    for key in pairs(tier.higher) do
+        if (active:get(key) or 0) > 0 then
+            return true
+        end
+    end
+    return false
+end
+
+-- queue waits until the tier admits the request or the timeout expires
+local function queue(name, tier)
+    local key = "queued " .. tier.priority
+    active:incr(key, 1, 0)
+
+    local admitted = false
+    local deadline = ngx.now() + tier.queue_timeout
+    repeat
+        ngx.sleep(0.05)
+        ngx.update_time()
+        if not waiting(tier) then
+            admitted = admit(name, tier.max_concurrent)
+        end
+    until admitted or ngx.now() >= deadline
+
+    active:incr(key, -1, 0)
+    return admitted
+end
+
+-- access classifies the request and applies the limits of its tier
+function _M.access()
+    local name = _M.classify()
+    if not name then
+        return
+    end
+    count(requests, name)
+
+    local tier = _M.tiers[name]
+    if not tier then
+        return
+    end
+
+    if tier.limiter then
+        local delay, err = tier.limiter:incoming(name .. " " .. (ngx.var.the_real_ip or ngx.var.remote_addr), true)
+        if not delay then
+            if err == "rejected" then
+                return reject(name, 429)
+            end
+            ngx.log(ngx.WARN, "failed to limit the rate of the tier ", name, ": ", err)
+        elseif delay >= 0.001 then
+            ngx.sleep(delay)
+        end
+    end
+
+    if tier.max_concurrent > 0 then
+        if waiting(tier) or not admit(name, tier.max_concurrent) then
+            if not queue(name, tier) then
+                return reject(name, ngx.HTTP_SERVICE_UNAVAILABLE)
+            end
+        end
+        ngx.ctx.tier = name
+    end
+end
+
+-- log ends the request admitted in the access phase
+function _M.log()
+    local name = ngx.ctx.tier
+    if name then
+        active:incr("active " .. name, -1, 0)
+    end
+end
+
+-- report writes one line per Ingress and tier with the classified or the
+-- rejected requests
+function _M.report(kind)
+    local dict = requests
+    if kind == "rejections" then
+        dict = rejections
+    end
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(dict:get_keys(0)) do
+        local n = dict:get(key)
+        if n then
+            ngx.say(key, " ", n)
+        end
+    end
+end
+
+return _M
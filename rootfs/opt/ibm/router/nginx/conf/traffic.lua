@@ -0,0 +1,52 @@
+-- Counts the requests proxied to each backend Service and the requests in
+-- progress, kept in the backend_traffic shared dict per namespace, Ingress
+-- and Service. The controller exposes them as Prometheus metrics so the
+-- backends can be autoscaled on the traffic seen by the ingress controller.
+
+local _M = {}
+
+local traffic = ngx.shared.backend_traffic
+
+local function incr(key, value)
+    local _, err = traffic:incr(key, value, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record backend traffic: ", err)
+    end
+end
+
+-- access counts the request as active until the log phase
+function _M.access()
+    local service = ngx.var.service_name
+    if not service or service == "" then
+        return
+    end
+    local backend = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. service
+    incr("active " .. backend, 1)
+    ngx.ctx.backend_traffic = backend
+end
+
+-- log ends the request opened in the access phase
+function _M.log()
+    local backend = ngx.ctx.backend_traffic
+    if not backend then
+        return
+    end
+    incr("active " .. backend, -1)
+    incr("requests " .. backend, 1)
+end
+
+-- report writes one line per key of kind (active or requests) with its value
+function _M.report(kind)
+    ngx.header["Content-Type"] = "text/plain"
+    local prefix = kind .. " "
+    for _, key in ipairs(traffic:get_keys(0)) do
+        if string.sub(key, 1, #prefix) == prefix then
+            local count = traffic:get(key)
+            if count then
+                ngx.say(string.sub(key, #prefix + 1), " ", count)
+            end
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,72 @@
+-- Tracks the uploads of the locations with the upload profile: the requests
+-- streaming a body to the backend per location, the bytes received per
+-- location and the result of the upload requests per Ingress, kept in the
+-- uploads shared dict. NGINX does not expose the progress of a body being
+-- streamed, so the bytes are added when each request ends; resumable
+-- protocols like tus send the uploads in several requests.
+
+local _M = {}
+
+local uploads = ngx.shared.uploads
+
+local methods = { POST = true, PUT = true, PATCH = true }
+
+local function incr(key, value)
+    local _, err = uploads:incr(key, value, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record upload: ", err)
+    end
+end
+
+-- access counts the request as an upload in progress when it has a body
+function _M.access(path)
+    if not methods[ngx.req.get_method()] then
+        return
+    end
+    local route = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. path
+    incr("active " .. route, 1)
+    ngx.ctx.upload = route
+end
+
+-- result returns how an upload request ended: interrupted when the client
+-- or the backend closed the connection or timed out, failed when the
+-- backend rejected it
+local function result()
+    local status = ngx.status
+    if status == 499 or status == ngx.HTTP_REQUEST_TIMEOUT or status == ngx.HTTP_GATEWAY_TIMEOUT
+            or ngx.var.request_completion ~= "OK" then
+        return "interrupted"
+    end
+    if status >= 400 then
+        return "failed"
+    end
+    return "completed"
+end
+
+-- log ends the upload opened in the access phase
+function _M.log()
+    local route = ngx.ctx.upload
+    if not route then
+        return
+    end
+    incr("active " .. route, -1)
+    incr("bytes " .. route, tonumber(ngx.var.request_length) or 0)
+    incr("requests " .. (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. result(), 1)
+end
+
+-- report writes one line per key of kind (active, bytes or requests) with
+-- its value
+function _M.report(kind)
+    ngx.header["Content-Type"] = "text/plain"
+    local prefix = kind .. " "
+    for _, key in ipairs(uploads:get_keys(0)) do
+        if string.sub(key, 1, #prefix) == prefix then
+            local count = uploads:get(key)
+            if count then
+                ngx.say(string.sub(key, #prefix + 1), " ", count)
+            end
+        end
+    end
+end
+
+return _M
@@ -0,0 +1,127 @@
+-- Limits the concurrent websocket sessions of the locations with the
+-- max-websocket-connections annotations. The open sessions are kept per
+-- location in the websocket_sessions shared dict and per location and
+-- client address in websocket_clients, and the reasons the upgraded
+-- connections were closed per Ingress in websocket_closes.
+
+local _M = {}
+
+local sessions = ngx.shared.websocket_sessions
+local clients = ngx.shared.websocket_clients
+local rejections = ngx.shared.websocket_rejections
+local closes = ngx.shared.websocket_closes
+
+local function reject(limit)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. limit
+    local _, err = rejections:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record rejected websocket session: ", err)
+    end
+    return ngx.exit(429)
+end
+
+-- access opens a session for websocket upgrades and rejects it when a
+-- limit is reached. A zero limit is not enforced.
+function _M.access(max, max_per_client, path)
+    if string.lower(ngx.var.http_upgrade or "") ~= "websocket" then
+        return
+    end
+
+    local route = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. path
+    local count, err = sessions:incr(route, 1, 0)
+    if not count then
+        ngx.log(ngx.WARN, "failed to count websocket session: ", err)
+        return
+    end
+    if max > 0 and count > max then
+        sessions:incr(route, -1, 0)
+        return reject("location")
+    end
+
+    local client
+    if max_per_client > 0 then
+        client = route .. " " .. (ngx.var.the_real_ip or ngx.var.remote_addr)
+        local n = clients:incr(client, 1, 0)
+        if n and n > max_per_client then
+            clients:incr(client, -1, 0)
+            sessions:incr(route, -1, 0)
+            return reject("client")
+        end
+    end
+
+    ngx.ctx.websocket = { route = route, client = client }
+end
+
+-- close_reason returns why NGINX closed an upgraded connection. Connections
+-- closed by the client or the backend complete the request. The idle
+-- timeout is enforced by NGINX itself, with the proxy and send timeouts of
+-- the location reset by the data in either direction, and Lua can not see
+-- when the last data was sent: an incomplete session lasting at least
+-- idle_timeout seconds is counted as a timeout, which includes the errors
+-- of the sessions that old, and the shorter ones as errors.
+local function close_reason(idle_timeout)
+    if ngx.worker.exiting() then
+        return "shutdown"
+    end
+    if ngx.var.request_completion == "OK" then
+        return "closed"
+    end
+    if idle_timeout > 0 and ngx.now() - ngx.req.start_time() >= idle_timeout then
+        return "timeout"
+    end
+    return "error"
+end
+
+-- log closes the session opened in the access phase and records why the
+-- upgraded connection was closed
+function _M.log(idle_timeout)
+    local session = ngx.ctx.websocket
+    if not session then
+        return
+    end
+    sessions:incr(session.route, -1, 0)
+    if session.client then
+        clients:incr(session.client, -1, 0)
+    end
+
+    if ngx.status ~= ngx.HTTP_SWITCHING_PROTOCOLS then
+        return
+    end
+    local reason = close_reason(idle_timeout)
+    local key = (ngx.var.namespace or "-") .. " " .. (ngx.var.ingress_name or "-") .. " " .. reason
+    local _, err = closes:incr(key, 1, 0)
+    if err then
+        ngx.log(ngx.WARN, "failed to record closed websocket session: ", err)
+    end
+    ngx.log(ngx.INFO, "websocket session of ", ngx.var.request_uri, " closed (", reason, ") after ",
+        ngx.now() - ngx.req.start_time(), "s")
+end
+
+local function report(dict)
+    ngx.header["Content-Type"] = "text/plain"
+    for _, key in ipairs(dict:get_keys(0)) do
+        local count = dict:get(key)
+        if count then
+            ngx.say(key, " ", count)
+        end
+    end
+end
+
+-- report_sessions writes one line per location with the open sessions
+function _M.report_sessions()
+    report(sessions)
+end
+
+-- report_closes writes one line per Ingress and reason with the number
+-- of closed sessions
+function _M.report_closes()
+    report(closes)
+end
+
+-- report_rejections writes one line per Ingress and limit with the
+-- number of rejected sessions
+function _M.report_rejections()
+    report(rejections)
+end
+
+return _M
@@ -0,0 +1,50 @@
+//go:build e2e
+// +build e2e
+
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stolostron/management-ingress/test/e2e/framework"
+)
+
+func TestRewriteTarget(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+
+	f.EnsureIngress(f.NewIngress("echo", "rewrite.e2e.local", "/rewrite", map[string]string{
+		"rewrite-target": "/",
+	}))
+
+	f.WaitForResponse(f.URL(false, "/rewrite/hostname"), "rewrite.e2e.local", http.StatusOK)
+}
+
+func TestAppRoot(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+
+	f.EnsureIngress(f.NewIngress("echo", "approot.e2e.local", "/", map[string]string{
+		"app-root": "/app",
+	}))
+
+	resp := f.WaitForResponse(f.URL(false, "/"), "approot.e2e.local", http.StatusFound)
+	if loc := resp.Header.Get("Location"); loc == "" {
+		t.Errorf("expected a Location header in the redirect")
+	}
+}
+
+func TestIgnoredIngressClass(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+
+	ing := f.NewIngress("echo", "other.e2e.local", "/", nil)
+	ing.Annotations["kubernetes.io/ingress.class"] = "other"
+	f.EnsureIngress(ing)
+
+	f.WaitForResponse(f.URL(false, "/"), "other.e2e.local", http.StatusNotFound)
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package framework
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/class"
+	"github.com/stolostron/management-ingress/pkg/ingress/annotations/parser"
+)
+
+const (
+	// ControllerNamespace is the namespace where the controller under test runs
+	ControllerNamespace = "management-ingress-e2e"
+
+	// ElectionLockName is the Lease of the leader election of the controller
+	ElectionLockName = "management-ingress-leader"
+
+	// EchoImage is the image used as backend in the scenarios
+	EchoImage = "registry.k8s.io/e2e-test-images/agnhost:2.39"
+
+	// Poll is the interval between checks while waiting for a condition
+	Poll = 2 * time.Second
+
+	// Timeout is the maximum time to wait for a condition
+	Timeout = 3 * time.Minute
+)
+
+// Framework contains the clients and the namespace used by one scenario
+type Framework struct {
+	T         *testing.T
+	Client    kubernetes.Interface
+	Namespace string
+
+	// HTTPURL and HTTPSURL are the addresses of the data plane exposed by kind
+	HTTPURL  string
+	HTTPSURL string
+}
+
+// New creates a namespace for the scenario and removes it when the test finishes
+func New(t *testing.T) *Framework {
+	kubeconfig := os.Getenv("KUBECONFIG")
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		t.Fatalf("unexpected error reading kubeconfig: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error creating kubernetes client: %v", err)
+	}
+
+	ns, err := client.CoreV1().Namespaces().Create(context.TODO(), &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "e2e-"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error creating namespace: %v", err)
+	}
+
+	f := &Framework{
+		T:         t,
+		Client:    client,
+		Namespace: ns.Name,
+		HTTPURL:   getenv("E2E_HTTP_URL", "http://127.0.0.1:18080"),
+		HTTPSURL:  getenv("E2E_HTTPS_URL", "https://127.0.0.1:18443"),
+	}
+
+	t.Cleanup(func() {
+		err := client.CoreV1().Namespaces().Delete(context.TODO(), ns.Name, metav1.DeleteOptions{})
+		if err != nil {
+			t.Logf("unexpected error removing namespace %v: %v", ns.Name, err)
+		}
+	})
+
+	return f
+}
+
+// NewEchoDeployment creates a deployment and a service that echo the received requests
+func (f *Framework) NewEchoDeployment(name string) {
+	f.newEchoDeployment(name, true)
+}
+
+// NewUnreadyEchoDeployment creates an echo deployment and service whose pod
+// never becomes ready, like a backend during a rollout
+func (f *Framework) NewUnreadyEchoDeployment(name string) {
+	f.newEchoDeployment(name, false)
+}
+
+func (f *Framework) newEchoDeployment(name string, ready bool) {
+	replicas := int32(1)
+	labels := map[string]string{"app": name}
+
+	var probe *apiv1.Probe
+	if !ready {
+		// nothing listens on the port of the probe
+		probe = &apiv1.Probe{
+			Handler:       apiv1.Handler{TCPSocket: &apiv1.TCPSocketAction{Port: intstr.FromInt(9999)}},
+			PeriodSeconds: 1,
+		}
+	}
+
+	_, err := f.Client.AppsV1().Deployments(f.Namespace).Create(context.TODO(), &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{
+						Name:  "echo",
+						Image: EchoImage,
+						Args:  []string{"netexec", "--http-port=8080"},
+						Ports: []apiv1.ContainerPort{{ContainerPort: 8080}},
+
+						ReadinessProbe: probe,
+					}},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		f.T.Fatalf("unexpected error creating deployment %v: %v", name, err)
+	}
+
+	_, err = f.Client.CoreV1().Services(f.Namespace).Create(context.TODO(), &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiv1.ServiceSpec{
+			Selector: labels,
+			Ports:    []apiv1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(8080)}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		f.T.Fatalf("unexpected error creating service %v: %v", name, err)
+	}
+
+	err = wait.PollImmediate(Poll, Timeout, func() (bool, error) {
+		ep, err := f.Client.CoreV1().Endpoints(f.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, s := range ep.Subsets {
+			if ready && len(s.Addresses) > 0 || !ready && len(s.NotReadyAddresses) > 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		f.T.Fatalf("deployment %v is not running: %v", name, err)
+	}
+}
+
+// NewIngress returns an Ingress for the given host and path pointing to the
+// service with the same name. The annotations are added using the configured prefix.
+func (f *Framework) NewIngress(name, host, path string, annotations map[string]string) *networking.Ingress {
+	pathType := networking.PathTypePrefix
+	anns := map[string]string{
+		class.IngressKey: class.DefaultClass,
+	}
+	for k, v := range annotations {
+		anns[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   f.Namespace,
+			Annotations: anns,
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{
+				Host: host,
+				IngressRuleValue: networking.IngressRuleValue{
+					HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{
+							Path:     path,
+							PathType: &pathType,
+							Backend: networking.IngressBackend{
+								Service: &networking.IngressServiceBackend{
+									Name: name,
+									Port: networking.ServiceBackendPort{Number: 80},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// EnsureIngress creates the Ingress in the cluster
+func (f *Framework) EnsureIngress(ing *networking.Ingress) *networking.Ingress {
+	ing, err := f.Client.NetworkingV1().Ingresses(f.Namespace).Create(context.TODO(), ing, metav1.CreateOptions{})
+	if err != nil {
+		f.T.Fatalf("unexpected error creating ingress: %v", err)
+	}
+	return ing
+}
+
+// WaitForIngressStatus waits until the status of the Ingress contains at least one address
+func (f *Framework) WaitForIngressStatus(name string) *networking.Ingress {
+	var ing *networking.Ingress
+	err := wait.PollImmediate(Poll, Timeout, func() (bool, error) {
+		var err error
+		ing, err = f.Client.NetworkingV1().Ingresses(f.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return len(ing.Status.LoadBalancer.Ingress) > 0, nil
+	})
+	if err != nil {
+		f.T.Fatalf("ingress %v/%v does not contain a status: %v", f.Namespace, name, err)
+	}
+	return ing
+}
+
+// WaitForResponse sends requests to the data plane until the expected status code is returned
+func (f *Framework) WaitForResponse(url, host string, expected int) *http.Response {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			// #nosec
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: host},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var resp *http.Response
+	err := wait.PollImmediate(Poll, Timeout, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		req.Host = host
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return false, nil
+		}
+		resp.Body.Close()
+		return resp.StatusCode == expected, nil
+	})
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		f.T.Fatalf("expected status code %v from %v (host %v) but last returned %v: %v", expected, url, host, status, err)
+	}
+	return resp
+}
+
+// ControllerPods returns the pods running the controller under test
+func (f *Framework) ControllerPods() []apiv1.Pod {
+	pods, err := f.Client.CoreV1().Pods(ControllerNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app=management-ingress",
+	})
+	if err != nil {
+		f.T.Fatalf("unexpected error listing controller pods: %v", err)
+	}
+	return pods.Items
+}
+
+// Leader returns the name of the controller pod holding the lock of the
+// leader election, empty if none
+func (f *Framework) Leader() string {
+	lease, err := f.Client.CoordinationV1().Leases(ControllerNamespace).Get(context.TODO(), ElectionLockName, metav1.GetOptions{})
+	if err != nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// URL returns the data plane address for the given path
+func (f *Framework) URL(secure bool, path string) string {
+	if secure {
+		return fmt.Sprintf("%v%v", f.HTTPSURL, path)
+	}
+	return fmt.Sprintf("%v%v", f.HTTPURL, path)
+}
+
+func getenv(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,72 @@
+//go:build e2e
+// +build e2e
+
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stolostron/management-ingress/test/e2e/framework"
+)
+
+func TestStatusPublication(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+	f.EnsureIngress(f.NewIngress("echo", "status.e2e.local", "/", nil))
+
+	ing := f.WaitForIngressStatus("echo")
+	for _, lbi := range ing.Status.LoadBalancer.Ingress {
+		if lbi.IP == "" && lbi.Hostname == "" {
+			t.Errorf("unexpected empty address in status: %v", ing.Status.LoadBalancer.Ingress)
+		}
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+	f.EnsureIngress(f.NewIngress("echo", "failover.e2e.local", "/", nil))
+	f.WaitForIngressStatus("echo")
+
+	pods := f.ControllerPods()
+	if len(pods) < 2 {
+		t.Skipf("leader failover requires at least two controller pods (%v running)", len(pods))
+	}
+
+	leader := f.Leader()
+	if leader == "" {
+		t.Fatalf("expected a controller pod holding the lock %v", framework.ElectionLockName)
+	}
+	err := f.Client.CoreV1().Pods(framework.ControllerNamespace).Delete(context.TODO(), leader, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error removing the leader %v: %v", leader, err)
+	}
+
+	// another replica must take over
+	err = wait.PollImmediate(framework.Poll, framework.Timeout, func() (bool, error) {
+		current := f.Leader()
+		return current != "" && current != leader, nil
+	})
+	if err != nil {
+		t.Fatalf("no controller pod took over the leadership of %v: %v", leader, err)
+	}
+
+	f.EnsureIngress(f.NewIngress("echo-after-failover", "failover2.e2e.local", "/", nil))
+	err = wait.PollImmediate(framework.Poll, framework.Timeout, func() (bool, error) {
+		ing, err := f.Client.NetworkingV1().Ingresses(f.Namespace).Get(context.TODO(), "echo-after-failover", metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return len(ing.Status.LoadBalancer.Ingress) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("status was not updated after leader failover: %v", err)
+	}
+}
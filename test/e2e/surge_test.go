@@ -0,0 +1,37 @@
+//go:build e2e
+// +build e2e
+
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stolostron/management-ingress/test/e2e/framework"
+)
+
+func TestSurgeProtection(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+	f.NewUnreadyEchoDeployment("rollout")
+
+	f.EnsureIngress(f.NewIngress("echo", "surge.e2e.local", "/", map[string]string{
+		"surge-protection": "reject",
+	}))
+	f.EnsureIngress(f.NewIngress("rollout", "rollout.e2e.local", "/", map[string]string{
+		"surge-protection":  "reject",
+		"surge-retry-after": "30s",
+	}))
+
+	// the backend with all its pods ready is not protected
+	f.WaitForResponse(f.URL(false, "/hostname"), "surge.e2e.local", http.StatusOK)
+
+	// no request is allowed without ready pods
+	resp := f.WaitForResponse(f.URL(false, "/hostname"), "rollout.e2e.local", http.StatusServiceUnavailable)
+	if v := resp.Header.Get("Retry-After"); v != "30" {
+		t.Errorf("expected the Retry-After header of the surge protection but returned %q", v)
+	}
+}
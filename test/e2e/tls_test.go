@@ -0,0 +1,68 @@
+//go:build e2e
+// +build e2e
+
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stolostron/management-ingress/test/e2e/framework"
+)
+
+func TestTLSSecret(t *testing.T) {
+	f := framework.New(t)
+	f.NewEchoDeployment("echo")
+
+	host := "tls.e2e.local"
+	cert, key, err := framework.GenerateCertificate(host)
+	if err != nil {
+		t.Fatalf("unexpected error generating certificate: %v", err)
+	}
+
+	_, err = f.Client.CoreV1().Secrets(f.Namespace).Create(context.TODO(), &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo-tls"},
+		Type:       apiv1.SecretTypeTLS,
+		Data: map[string][]byte{
+			apiv1.TLSCertKey:       cert,
+			apiv1.TLSPrivateKeyKey: key,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error creating secret: %v", err)
+	}
+
+	ing := f.NewIngress("echo", host, "/", nil)
+	ing.Spec.TLS = []networking.IngressTLS{{Hosts: []string{host}, SecretName: "echo-tls"}}
+	f.EnsureIngress(ing)
+
+	f.WaitForResponse(f.URL(true, "/"), host, http.StatusOK)
+
+	u, _ := url.Parse(f.HTTPSURL)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", u.Host, &tls.Config{
+		// #nosec
+		InsecureSkipVerify: true,
+		ServerName:         host,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error connecting to %v: %v", u.Host, err)
+	}
+	defer conn.Close()
+
+	peer := conn.ConnectionState().PeerCertificates
+	if len(peer) == 0 || peer[0].VerifyHostname(host) != nil {
+		t.Errorf("expected the certificate for %v to be served", host)
+	}
+}